@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
 	"github.com/xenolf/lego/acme"
 )
 
@@ -24,6 +25,8 @@ type Account struct {
 	PrivateKey         []byte
 	DomainsCertificate DomainsCertificates
 	ChallengeCerts     map[string]*ChallengeCert
+	HTTPChallenges     map[string]map[string]string
+	PendingDomains     []Domain
 }
 
 // ChallengeCert stores a challenge certificate
@@ -59,6 +62,32 @@ func (a *Account) Init() error {
 	return nil
 }
 
+func (a *Account) isPending(domain Domain) bool {
+	for _, pendingDomain := range a.PendingDomains {
+		if reflect.DeepEqual(domain, pendingDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// addPendingDomain records a domain whose certificate has not been obtained yet
+func (a *Account) addPendingDomain(domain Domain) {
+	if !a.isPending(domain) {
+		a.PendingDomains = append(a.PendingDomains, domain)
+	}
+}
+
+// removePendingDomain forgets a domain once its certificate has been obtained
+func (a *Account) removePendingDomain(domain Domain) {
+	for i, pendingDomain := range a.PendingDomains {
+		if reflect.DeepEqual(domain, pendingDomain) {
+			a.PendingDomains = append(a.PendingDomains[:i], a.PendingDomains[i+1:]...)
+			return
+		}
+	}
+}
+
 // NewAccount creates an account
 func NewAccount(email string) (*Account, error) {
 	// Create a user. New accounts need an email and private key to start
@@ -197,6 +226,7 @@ func (dc *DomainsCertificates) addCertificateForDomains(acmeCert *Certificate, d
 func (dc *DomainsCertificates) getCertificateForDomain(domainToFind string) (*DomainsCertificate, bool) {
 	dc.lock.RLock()
 	defer dc.lock.RUnlock()
+	var wildcardCertificate *DomainsCertificate
 	for _, domainsCertificate := range dc.Certs {
 		domains := []string{}
 		domains = append(domains, domainsCertificate.Domains.Main)
@@ -205,9 +235,12 @@ func (dc *DomainsCertificates) getCertificateForDomain(domainToFind string) (*Do
 			if domain == domainToFind {
 				return domainsCertificate, true
 			}
+			if wildcardCertificate == nil && types.MatchDomain(domainToFind, domain) {
+				wildcardCertificate = domainsCertificate
+			}
 		}
 	}
-	return nil, false
+	return wildcardCertificate, wildcardCertificate != nil
 }
 
 func (dc *DomainsCertificates) exists(domainToFind Domain) (*DomainsCertificate, bool) {
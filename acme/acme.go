@@ -7,48 +7,100 @@ import (
 	"fmt"
 	"io/ioutil"
 	fmtlog "log"
+	"net/http"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BurntSushi/ty/fun"
 	"github.com/cenk/backoff"
+	"github.com/containous/flaeg"
 	"github.com/containous/staert"
 	"github.com/containous/traefik/cluster"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/types"
 	"github.com/eapache/channels"
+	"github.com/ryanuber/go-glob"
 	"github.com/xenolf/lego/acme"
 	"github.com/xenolf/lego/providers/dns"
 )
 
+// DefaultCAServer is the directory of the production Let's Encrypt CA, used when no CA server is configured
+const DefaultCAServer = "https://acme-v01.api.letsencrypt.org/directory"
+
 var (
 	// OSCPMustStaple enables OSCP stapling as from https://github.com/xenolf/lego/issues/270
 	OSCPMustStaple = false
+
+	// certificatesBatchSize is the number of certificates requested in a row before pausing
+	certificatesBatchSize = 10
+	// certificatesBatchInterval is the pause between two batches of certificate requests
+	certificatesBatchInterval = 10 * time.Second
+
+	// newCertificateBackOff creates the back-off used to retry a certificate request rejected
+	// because of CA rate limits, or whose challenges failed. The default randomization factor adds jitter between attempts.
+	newCertificateBackOff = func() backoff.BackOff {
+		ebo := backoff.NewExponentialBackOff()
+		ebo.InitialInterval = 30 * time.Second
+		ebo.MaxInterval = 30 * time.Minute
+		ebo.MaxElapsedTime = 3 * time.Hour
+		return ebo
+	}
 )
 
 // ACME allows to connect to lets encrypt and retrieve certs
 type ACME struct {
-	Email               string   `description:"Email address used for registration"`
-	Domains             []Domain `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
-	Storage             string   `description:"File or key used for certificates storage."`
-	StorageFile         string   // deprecated
-	OnDemand            bool     `description:"Enable on demand certificate. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."`
-	OnHostRule          bool     `description:"Enable certificate generation on frontends Host rules."`
-	CAServer            string   `description:"CA server to use."`
-	EntryPoint          string   `description:"Entrypoint to proxy acme challenge to."`
-	DNSProvider         string   `description:"Use a DNS based challenge provider rather than HTTPS."`
-	DelayDontCheckDNS   int      `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
-	ACMELogging         bool     `description:"Enable debug logging of ACME actions."`
+	Email               string         `description:"Email address used for registration"`
+	Domains             []Domain       `description:"SANs (alternative domains) to each main domain using format: --acme.domains='main.com,san1.com,san2.com' --acme.domains='main.net,san1.net,san2.net'"`
+	Storage             string         `description:"File or key used for certificates storage."`
+	StorageFile         string         // deprecated
+	OnDemand            bool           `description:"Enable on demand certificate. This will request a certificate from Let's Encrypt during the first TLS handshake for a hostname that does not yet have a certificate."`
+	OnDemandDomains     []string       `description:"Domain patterns (e.g. *.example.com) allowed to get an on demand certificate. All domains matching a frontend are allowed when empty."`
+	OnHostRule          bool           `description:"Enable certificate generation on frontends Host rules."`
+	CAServer            string         `description:"CA server to use."`
+	ChallengeRetries    int            `description:"Number of times a certificate request is tried again when its challenges fail."`
+	ChallengeTimeout    flaeg.Duration `description:"Maximum time waiting for a challenge to be ready."`
+	EntryPoint          string         `description:"Entrypoint to proxy acme challenge to."`
+	DNSProvider         string         `description:"Use a DNS based challenge provider rather than HTTPS."`
+	HTTPChallenge       *HTTPChallenge `description:"Use the HTTP-01 challenge served on an entrypoint rather than HTTPS."`
+	DelayDontCheckDNS   int            `description:"Assume DNS propagates after a delay in seconds rather than finding and querying nameservers."`
+	ACMELogging         bool           `description:"Enable debug logging of ACME actions."`
 	client              *acme.Client
 	defaultCertificate  *tls.Certificate
 	store               cluster.Store
 	challengeProvider   *challengeProvider
+	httpChallenge       httpChallengeProvider
 	checkOnDemandDomain func(domain string) bool
 	jobs                *channels.InfiniteChannel
 	TLSConfig           *tls.Config `description:"TLS config in case wildcard certs are used"`
+	onDemandLock        sync.Mutex
+	onDemandRequests    map[string]*onDemandRequest
+	requestsLock        sync.Mutex
+	requests            map[string]bool
+	hostRuleDomains     map[string]bool
+}
+
+// HTTPChallenge configures the HTTP-01 challenge, served under /.well-known/acme-challenge/ on the entrypoint
+type HTTPChallenge struct {
+	EntryPoint string `description:"Entrypoint serving the HTTP challenge, on port 80."`
+}
+
+// certificateRequest is the request of a certificate for a pending domain, tried again until it succeeds,
+// fails definitively, or its domain leaves the configuration
+type certificateRequest struct {
+	domain           Domain
+	challengeRetries int
+}
+
+// onDemandRequest holds the result of an on demand certificate request shared by concurrent handshakes
+type onDemandRequest struct {
+	done        chan struct{}
+	certificate *tls.Certificate
+	err         error
 }
 
 //Domains parse []Domain
@@ -92,6 +144,11 @@ type Domain struct {
 	SANs []string
 }
 
+// key identifies the domain and its SANs
+func (d Domain) key() string {
+	return strings.Join(append([]string{d.Main}, d.SANs...), ",")
+}
+
 func (a *ACME) init() error {
 	if a.ACMELogging {
 		acme.Logger = fmtlog.New(os.Stderr, "legolog: ", fmtlog.LstdFlags)
@@ -151,7 +208,8 @@ func (a *ACME) CreateClusterConfig(leadership *cluster.Leadership, tlsConfig *tl
 	}
 
 	a.store = datastore
-	a.challengeProvider = &challengeProvider{store: a.store}
+	a.challengeProvider = &challengeProvider{store: a.store, timeout: time.Duration(a.ChallengeTimeout)}
+	a.httpChallenge.setStore(a.store)
 
 	ticker := time.NewTicker(24 * time.Hour)
 	leadership.Pool.AddGoCtx(func(ctx context.Context) {
@@ -248,7 +306,8 @@ func (a *ACME) CreateLocalConfig(tlsConfig *tls.Config, checkOnDemandDomain func
 	a.TLSConfig = tlsConfig
 	localStore := NewLocalStore(a.Storage)
 	a.store = localStore
-	a.challengeProvider = &challengeProvider{store: a.store}
+	a.challengeProvider = &challengeProvider{store: a.store, timeout: time.Duration(a.ChallengeTimeout)}
+	a.httpChallenge.setStore(a.store)
 
 	var needRegister bool
 	var account *Account
@@ -341,6 +400,10 @@ func (a *ACME) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 		return domainCert.tlsCert, nil
 	}
 	if a.OnDemand {
+		if !a.isOnDemandDomainAllowed(domain) {
+			log.Debugf("ACME on demand certificate not allowed for %s", domain)
+			return nil, nil
+		}
 		if a.checkOnDemandDomain != nil && !a.checkOnDemandDomain(domain) {
 			return nil, nil
 		}
@@ -353,40 +416,254 @@ func (a *ACME) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 func (a *ACME) retrieveCertificates() {
 	a.jobs.In() <- func() {
 		log.Info("Retrieving ACME certificates...")
-		for _, domain := range a.Domains {
-			// check if cert isn't already loaded
-			account := a.store.Get().(*Account)
-			if _, exists := account.DomainsCertificate.exists(domain); !exists {
-				domains := []string{}
-				domains = append(domains, domain.Main)
-				domains = append(domains, domain.SANs...)
-				certificateResource, err := a.getDomainsCertificates(domains)
-				if err != nil {
-					log.Errorf("Error getting ACME certificate for domain %s: %s", domains, err.Error())
-					continue
-				}
-				transaction, object, err := a.store.Begin()
-				if err != nil {
-					log.Errorf("Error creating ACME store transaction from domain %s: %s", domain, err.Error())
-					continue
-				}
-				account = object.(*Account)
-				_, err = account.DomainsCertificate.addCertificateForDomains(certificateResource, domain)
-				if err != nil {
-					log.Errorf("Error adding ACME certificate for domain %s: %s", domains, err.Error())
-					continue
-				}
-
-				if err = transaction.Commit(account); err != nil {
-					log.Errorf("Error Saving ACME account %+v: %s", account, err.Error())
-					continue
-				}
+		domains := a.getDomainsToRetrieve()
+		if err := a.addPendingDomains(domains); err != nil {
+			log.Errorf("Error saving pending ACME domains %+v: %s", domains, err.Error())
+		}
+		for i, domain := range domains {
+			if i > 0 && i%certificatesBatchSize == 0 {
+				log.Debugf("Waiting %s before requesting next ACME certificates", certificatesBatchInterval)
+				time.Sleep(certificatesBatchInterval)
 			}
+			a.obtainCertificateForDomain(domain)
 		}
 		log.Info("Retrieved ACME certificates")
 	}
 }
 
+// getDomainsToRetrieve returns the configured domains without certificate, followed by
+// the domains left pending by a previous run which are still configured.
+// The pending domains of the frontend host rules are kept until the first configuration tells whether they are still used.
+func (a *ACME) getDomainsToRetrieve() []Domain {
+	account := a.store.Get().(*Account)
+	domains := []Domain{}
+	for _, domain := range a.Domains {
+		if _, exists := account.DomainsCertificate.exists(domain); !exists {
+			domains = append(domains, domain)
+		}
+	}
+	var removedDomains []Domain
+	for _, domain := range account.PendingDomains {
+		if !a.isDomainConfigured(domain) {
+			removedDomains = append(removedDomains, domain)
+			continue
+		}
+		if _, exists := account.DomainsCertificate.exists(domain); !exists && !isDomainInList(domain, domains) {
+			domains = append(domains, domain)
+		}
+	}
+	a.removeUnconfiguredDomains(removedDomains)
+	return domains
+}
+
+// isDomainConfigured checks if the domain is one of the ACME domains, or one of the frontend host rules
+// when certificates are generated for them. All of these are configured until the first configuration is loaded.
+func (a *ACME) isDomainConfigured(domain Domain) bool {
+	for _, configured := range a.Domains {
+		if configured.key() == domain.key() {
+			return true
+		}
+	}
+	if !a.OnHostRule {
+		return false
+	}
+	a.requestsLock.Lock()
+	defer a.requestsLock.Unlock()
+	return a.hostRuleDomains == nil || a.hostRuleDomains[domain.key()]
+}
+
+// UpdateHostRuleDomains records the domains of the frontend host rules of the current configuration:
+// the pending domains which are no longer configured are forgotten, and their certificate requests are not tried again.
+func (a *ACME) UpdateHostRuleDomains(hostRuleDomains [][]string) {
+	configured := make(map[string]bool, len(hostRuleDomains))
+	for _, domains := range hostRuleDomains {
+		if len(domains) > 0 {
+			domains = fun.Map(types.CanonicalDomain, domains).([]string)
+			configured[Domain{Main: domains[0], SANs: domains[1:]}.key()] = true
+		}
+	}
+	a.requestsLock.Lock()
+	a.hostRuleDomains = configured
+	a.requestsLock.Unlock()
+
+	if a.jobs == nil {
+		// no certificate requested yet
+		return
+	}
+	a.jobs.In() <- func() {
+		account := a.store.Get().(*Account)
+		var removedDomains []Domain
+		for _, domain := range account.PendingDomains {
+			if !a.isDomainConfigured(domain) {
+				removedDomains = append(removedDomains, domain)
+			}
+		}
+		a.removeUnconfiguredDomains(removedDomains)
+	}
+}
+
+// removeUnconfiguredDomains forgets the pending domains which are no longer configured.
+func (a *ACME) removeUnconfiguredDomains(domains []Domain) {
+	for _, domain := range domains {
+		log.Infof("Forgetting pending ACME domain %+v, no longer configured", domain)
+		a.removePendingDomain(domain)
+	}
+}
+
+func isDomainInList(domain Domain, domains []Domain) bool {
+	for _, d := range domains {
+		if reflect.DeepEqual(domain, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// addPendingDomains persists the domains for which a certificate is about to be requested,
+// so that a restart resumes their retrieval.
+func (a *ACME) addPendingDomains(domains []Domain) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	transaction, object, err := a.store.Begin()
+	if err != nil {
+		return err
+	}
+	account := object.(*Account)
+	for _, domain := range domains {
+		account.addPendingDomain(domain)
+	}
+	return transaction.Commit(account)
+}
+
+// removePendingDomain forgets a pending domain whose certificate request is over.
+func (a *ACME) removePendingDomain(domain Domain) {
+	transaction, object, err := a.store.Begin()
+	if err != nil {
+		log.Errorf("Error forgetting pending ACME domain %+v: %s", domain, err.Error())
+		return
+	}
+	account := object.(*Account)
+	if !account.isPending(domain) {
+		return
+	}
+	account.removePendingDomain(domain)
+	if err = transaction.Commit(account); err != nil {
+		log.Errorf("Error forgetting pending ACME domain %+v: %s", domain, err.Error())
+	}
+}
+
+// obtainCertificateForDomain requests a certificate for a pending domain, from a job, and stores it.
+// When the CA rate limits the request, or when its challenges fail up to ChallengeRetries times, the request is
+// tried again with back-off by its own goroutine, each attempt running in a new job: the other certificate
+// requests and renewals go on meanwhile. A domain whose certificate is already being requested is skipped.
+func (a *ACME) obtainCertificateForDomain(domain Domain) {
+	a.requestsLock.Lock()
+	if a.requests == nil {
+		a.requests = make(map[string]bool)
+	}
+	if a.requests[domain.key()] {
+		a.requestsLock.Unlock()
+		log.Debugf("ACME certificate for domain %+v already requested", domain)
+		return
+	}
+	a.requests[domain.key()] = true
+	a.requestsLock.Unlock()
+
+	request := &certificateRequest{domain: domain}
+	retry, err := a.attemptCertificateRequest(request)
+	if !retry {
+		a.endCertificateRequest(request, err)
+		return
+	}
+	safe.Go(func() {
+		a.retryCertificateRequest(request, err)
+	})
+}
+
+// retryCertificateRequest tries the certificate request again with back-off, until it succeeds, fails definitively,
+// or its domain leaves the configuration.
+func (a *ACME) retryCertificateRequest(request *certificateRequest, err error) {
+	ebo := newCertificateBackOff()
+	ebo.Reset()
+	for {
+		wait := ebo.NextBackOff()
+		if wait == backoff.Stop {
+			a.endCertificateRequest(request, err)
+			return
+		}
+		if isRateLimitError(err) {
+			log.Warnf("ACME rate limit reached for domain %+v, retrying in %s", request.domain, wait)
+		} else {
+			log.Warnf("ACME challenges failed for domain %+v, retrying in %s (%d/%d)", request.domain, wait, request.challengeRetries, a.ChallengeRetries)
+		}
+		time.Sleep(wait)
+
+		if !a.isDomainConfigured(request.domain) {
+			log.Infof("Canceling ACME certificate request for domain %+v, no longer configured", request.domain)
+			a.endCertificateRequest(request, nil)
+			return
+		}
+		var retry bool
+		attempted := make(chan struct{})
+		a.jobs.In() <- func() {
+			defer close(attempted)
+			retry, err = a.attemptCertificateRequest(request)
+		}
+		<-attempted
+		if !retry {
+			a.endCertificateRequest(request, err)
+			return
+		}
+	}
+}
+
+// attemptCertificateRequest requests the certificate once and stores it, returning whether the request should be tried again.
+func (a *ACME) attemptCertificateRequest(request *certificateRequest) (bool, error) {
+	domains := []string{}
+	domains = append(domains, request.domain.Main)
+	domains = append(domains, request.domain.SANs...)
+	certificateResource, err := a.getDomainsCertificates(domains)
+	switch {
+	case err == nil:
+	case isRateLimitError(err):
+		return true, err
+	case isChallengeError(err) && request.challengeRetries < a.ChallengeRetries:
+		request.challengeRetries++
+		return true, err
+	default:
+		return false, err
+	}
+
+	transaction, object, err := a.store.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error creating ACME store transaction: %v", err)
+	}
+	account := object.(*Account)
+	if _, err = account.DomainsCertificate.addCertificateForDomains(certificateResource, request.domain); err != nil {
+		return false, fmt.Errorf("error adding ACME certificate: %v", err)
+	}
+	account.removePendingDomain(request.domain)
+	if err = transaction.Commit(account); err != nil {
+		return false, fmt.Errorf("error saving ACME account %+v: %v", account, err)
+	}
+	return false, nil
+}
+
+// endCertificateRequest ends the certificate request of a domain, which is no longer pending,
+// the certificate being obtained or its request having failed definitively.
+func (a *ACME) endCertificateRequest(request *certificateRequest, err error) {
+	if err != nil {
+		log.Errorf("Error getting ACME certificate for domain %+v: %s", request.domain, err.Error())
+		a.removePendingDomain(request.domain)
+	} else {
+		log.Debugf("ACME certificate request for domain %+v is over", request.domain)
+	}
+	a.requestsLock.Lock()
+	delete(a.requests, request.domain.key())
+	a.requestsLock.Unlock()
+}
+
 func (a *ACME) renewCertificates() {
 	a.jobs.In() <- func() {
 		log.Debug("Testing certificate renew...")
@@ -450,11 +727,7 @@ func dnsOverrideDelay(delay int) error {
 
 func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 	log.Debug("Building ACME client...")
-	caServer := "https://acme-v01.api.letsencrypt.org/directory"
-	if len(a.CAServer) > 0 {
-		caServer = a.CAServer
-	}
-	client, err := acme.NewClient(caServer, account, acme.RSA4096)
+	client, err := acme.NewClient(a.getCAServer(), account, acme.RSA4096)
 	if err != nil {
 		return nil, err
 	}
@@ -475,6 +748,10 @@ func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.TLSSNI01})
 		err = client.SetChallengeProvider(acme.DNS01, provider)
+	} else if a.HTTPChallenge != nil {
+		log.Debugf("Using HTTP Challenge provider on entrypoint %s", a.HTTPChallenge.EntryPoint)
+		client.ExcludeChallenges([]acme.Challenge{acme.TLSSNI01, acme.DNS01})
+		err = client.SetChallengeProvider(acme.HTTP01, &a.httpChallenge)
 	} else {
 		client.ExcludeChallenges([]acme.Challenge{acme.HTTP01, acme.DNS01})
 		err = client.SetChallengeProvider(acme.TLSSNI01, a.challengeProvider)
@@ -486,8 +763,57 @@ func (a *ACME) buildACMEClient(account *Account) (*acme.Client, error) {
 	return client, nil
 }
 
+// getCAServer returns the directory URL of the CA server, the production Let's Encrypt one by default
+func (a *ACME) getCAServer() string {
+	if len(a.CAServer) > 0 {
+		return a.CAServer
+	}
+	return DefaultCAServer
+}
+
+// isOnDemandDomainAllowed checks the domain against the on demand domain patterns
+func (a *ACME) isOnDemandDomainAllowed(domain string) bool {
+	if len(a.OnDemandDomains) == 0 {
+		return true
+	}
+	for _, pattern := range a.OnDemandDomains {
+		if glob.Glob(types.CanonicalDomain(pattern), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCertificateOnDemand requests a certificate for the handshake domain.
+// Concurrent handshakes for the same domain wait for a single request to the CA.
 func (a *ACME) loadCertificateOnDemand(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	domain := types.CanonicalDomain(clientHello.ServerName)
+
+	a.onDemandLock.Lock()
+	if request, ok := a.onDemandRequests[domain]; ok {
+		a.onDemandLock.Unlock()
+		log.Debugf("Waiting for on demand certificate already requested for domain %s", domain)
+		<-request.done
+		return request.certificate, request.err
+	}
+	if a.onDemandRequests == nil {
+		a.onDemandRequests = make(map[string]*onDemandRequest)
+	}
+	request := &onDemandRequest{done: make(chan struct{})}
+	a.onDemandRequests[domain] = request
+	a.onDemandLock.Unlock()
+
+	request.certificate, request.err = a.obtainCertificateOnDemand(domain)
+	close(request.done)
+
+	a.onDemandLock.Lock()
+	delete(a.onDemandRequests, domain)
+	a.onDemandLock.Unlock()
+
+	return request.certificate, request.err
+}
+
+func (a *ACME) obtainCertificateOnDemand(domain string) (*tls.Certificate, error) {
 	account := a.store.Get().(*Account)
 	if certificateResource, ok := account.DomainsCertificate.getCertificateForDomain(domain); ok {
 		return certificateResource.tlsCert, nil
@@ -557,28 +883,10 @@ func (a *ACME) LoadCertificateForDomains(domains []string) {
 			// domain already exists
 			return
 		}
-		certificate, err := a.getDomainsCertificates(domains)
-		if err != nil {
-			log.Errorf("Error getting ACME certificates %+v : %v", domains, err)
-			return
-		}
-		log.Debugf("Got certificate for domains %+v", domains)
-		transaction, object, err := a.store.Begin()
-
-		if err != nil {
-			log.Errorf("Error creating transaction %+v : %v", domains, err)
-			return
-		}
-		account = object.(*Account)
-		_, err = account.DomainsCertificate.addCertificateForDomains(certificate, domain)
-		if err != nil {
-			log.Errorf("Error adding ACME certificates %+v : %v", domains, err)
-			return
-		}
-		if err = transaction.Commit(account); err != nil {
-			log.Errorf("Error Saving ACME account %+v: %v", account, err)
-			return
+		if err := a.addPendingDomains([]Domain{domain}); err != nil {
+			log.Errorf("Error saving pending ACME domains %+v : %v", domains, err)
 		}
+		a.obtainCertificateForDomain(domain)
 	}
 }
 
@@ -612,7 +920,7 @@ func (a *ACME) getDomainsCertificates(domains []string) (*Certificate, error) {
 	certificate, failures := a.client.ObtainCertificate(domains, bundle, nil, OSCPMustStaple)
 	if len(failures) > 0 {
 		log.Error(failures)
-		return nil, fmt.Errorf("Cannot obtain certificates %s+v", failures)
+		return nil, &obtainError{failures: failures}
 	}
 	log.Debugf("Loaded ACME certificates %s", domains)
 	return &Certificate{
@@ -624,6 +932,48 @@ func (a *ACME) getDomainsCertificates(domains []string) (*Certificate, error) {
 	}, nil
 }
 
+// obtainError holds the failures returned by the CA for a certificate request
+type obtainError struct {
+	failures map[string]error
+}
+
+func (e *obtainError) Error() string {
+	return fmt.Sprintf("Cannot obtain certificates %+v", e.failures)
+}
+
+// isRateLimitError checks if a certificate request has been rejected because of CA rate limits
+func isRateLimitError(err error) bool {
+	obtainErr, ok := err.(*obtainError)
+	if !ok {
+		return false
+	}
+	for _, failure := range obtainErr.failures {
+		if remoteErr, ok := failure.(acme.RemoteError); ok {
+			if remoteErr.StatusCode == http.StatusTooManyRequests || strings.HasSuffix(remoteErr.Type, ":rateLimited") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isChallengeError checks if a certificate request failed for another reason than a rejection by the CA,
+// such as a challenge that the CA could not validate
+func isChallengeError(err error) bool {
+	obtainErr, ok := err.(*obtainError)
+	if !ok {
+		return false
+	}
+	for _, failure := range obtainErr.failures {
+		switch failure.(type) {
+		case acme.RemoteError, acme.TOSError, acme.NonceError:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
 func (a *ACME) runJobs() {
 	safe.Go(func() {
 		for job := range a.jobs.Out() {
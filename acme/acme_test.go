@@ -1,16 +1,28 @@
 package acme
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/cenk/backoff"
+	"github.com/eapache/channels"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/xenolf/lego/acme"
 )
 
@@ -217,6 +229,35 @@ func TestRemoveDuplicates(t *testing.T) {
 	}
 }
 
+func TestGetCertificateForDomainWildcard(t *testing.T) {
+	wildcardCertificate := &DomainsCertificate{Domains: Domain{Main: "*.foo.com"}}
+	exactCertificate := &DomainsCertificate{Domains: Domain{Main: "bar.com", SANs: []string{"api.foo.com"}}}
+	domainsCertificates := DomainsCertificates{
+		Certs: []*DomainsCertificate{wildcardCertificate, exactCertificate},
+	}
+
+	testCases := []struct {
+		domain              string
+		expectedCertificate *DomainsCertificate
+	}{
+		{domain: "api.foo.com", expectedCertificate: exactCertificate},
+		{domain: "web.foo.com", expectedCertificate: wildcardCertificate},
+		{domain: "a.b.foo.com"},
+		{domain: "foo.com"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.domain, func(t *testing.T) {
+			t.Parallel()
+
+			certificate, ok := domainsCertificates.getCertificateForDomain(test.domain)
+			assert.Equal(t, test.expectedCertificate != nil, ok)
+			assert.Equal(t, test.expectedCertificate, certificate)
+		})
+	}
+}
+
 func TestNoPreCheckOverride(t *testing.T) {
 	acme.PreCheckDNS = nil // Irreversable - but not expecting real calls into this during testing process
 	err := dnsOverrideDelay(0)
@@ -294,3 +335,631 @@ func TestAcme_getProvidedCertificate(t *testing.T) {
 	certificate = a.getProvidedCertificate(domains)
 	assert.Nil(t, certificate)
 }
+
+// testAccountPrivateKey is a small RSA key, enough to sign requests sent to a mock ACME server
+const testAccountPrivateKey = `
+MIIBPAIBAAJBAMp2Ni92FfEur+CAvFkgC12LT4l9D53ApbBpDaXaJkzzks+KsLw9zyAxvlrfAyTCQ
+7tDnEnIltAXyQ0uOFUUdcMCAwEAAQJAK1FbipATZcT9cGVa5x7KD7usytftLW14heQUPXYNV80r/3
+lmnpvjL06dffRpwkYeN8DATQF/QOcy3NNNGDw/4QIhAPAKmiZFxA/qmRXsuU8Zhlzf16WrNZ68K64
+asn/h3qZrAiEA1+wFR3WXCPIolOvd7AHjfgcTKQNkoMPywU4FYUNQ1AkCIQDv8yk0qPjckD6HVCPJ
+llJh9MC0svjevGtNlxJoE3lmEQIhAKXy1wfZ32/XtcrnENPvi6lzxI0T94X7s5pP3aCoPPoJAiEAl
+cijFkALeQp/qyeXdFld2v9gUN3eCgljgcl0QweRoIc=---`
+
+// mockACMEServer answers the first rateLimited authorization requests (all of them if negative)
+// with a rate-limit error, and the next ones with fallbackStatus.
+// With http.StatusCreated, authorizations are valid and certificates are issued,
+// unless challengeStatus is set: authorizations are then pending on a tls-sni-01 challenge with this status.
+type mockACMEServer struct {
+	*httptest.Server
+	lock            sync.Mutex
+	rateLimited     int
+	fallbackStatus  int
+	challengeStatus string
+	authzTimes      []time.Time
+	validations     int
+	directories     int
+	issued          int
+}
+
+func newMockACMEServer(rateLimited int, fallbackStatus int) *mockACMEServer {
+	server := &mockACMEServer{rateLimited: rateLimited, fallbackStatus: fallbackStatus}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch {
+		case r.Method == http.MethodHead:
+			return
+		case r.URL.Path == "/acme/new-authz":
+			server.lock.Lock()
+			defer server.lock.Unlock()
+			server.authzTimes = append(server.authzTimes, time.Now())
+			if server.rateLimited < 0 || len(server.authzTimes) <= server.rateLimited {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"type":"urn:acme:error:rateLimited","detail":"Error creating new authz :: too many currently pending authorizations"}`))
+				return
+			}
+			if server.fallbackStatus == http.StatusCreated {
+				w.Header().Set("Link", "<"+server.URL+"/acme/new-cert>;rel=\"next\"")
+				w.Header().Set("Location", server.URL+"/acme/authz/1")
+				w.WriteHeader(http.StatusCreated)
+				if server.challengeStatus != "" {
+					w.Write([]byte(`{"status":"pending","challenges":[{"type":"tls-sni-01","uri":"` + server.URL + `/acme/challenge/1","token":"token"}],"combinations":[[0]]}`))
+					return
+				}
+				w.Write([]byte(`{"status":"valid"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(server.fallbackStatus)
+			w.Write([]byte(`{"type":"urn:acme:error:malformed","detail":"Invalid domain"}`))
+		case r.URL.Path == "/acme/challenge/1":
+			server.lock.Lock()
+			server.validations++
+			server.lock.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"type":"tls-sni-01","status":"` + server.challengeStatus + `","error":{"type":"urn:acme:error:connection","detail":"Connection refused"}}`))
+		case r.URL.Path == "/acme/new-cert":
+			cert, err := issueTestCertificate(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			server.lock.Lock()
+			server.issued++
+			server.lock.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			w.Write(cert)
+		default:
+			server.lock.Lock()
+			server.directories++
+			server.lock.Unlock()
+			w.Write([]byte(`{
+"new-authz": "` + server.URL + `/acme/new-authz",
+"new-cert": "` + server.URL + `/acme/new-cert",
+"new-reg": "` + server.URL + `/acme/new-reg",
+"revoke-cert": "` + server.URL + `/acme/revoke-cert"
+}`))
+		}
+	}))
+	return server
+}
+
+// issueTestCertificate creates a self-signed DER certificate for the CSR sent in a new-cert request
+func issueTestCertificate(r *http.Request) ([]byte, error) {
+	var signed struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&signed); err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(signed.Payload)
+	if err != nil {
+		return nil, err
+	}
+	var message struct {
+		Csr string `json:"csr"`
+	}
+	if err = json.Unmarshal(payload, &message); err != nil {
+		return nil, err
+	}
+	der, err := base64.URLEncoding.DecodeString(message.Csr)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	return x509.CreateCertificate(rand.Reader, template, template, csr.PublicKey, key)
+}
+
+func (s *mockACMEServer) issuedCertificates() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.issued
+}
+
+func (s *mockACMEServer) challengeValidations() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.validations
+}
+
+func (s *mockACMEServer) directoryRequests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.directories
+}
+
+func (s *mockACMEServer) authzRequests() []time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]time.Time{}, s.authzTimes...)
+}
+
+func newTestACMEClient(t *testing.T, server *mockACMEServer) *acme.Client {
+	account := &Account{Email: "f@f"}
+	account.PrivateKey, _ = base64.StdEncoding.DecodeString(testAccountPrivateKey)
+	account.Registration = &acme.RegistrationResource{NewAuthzURL: server.URL + "/acme/new-authz"}
+	client, err := acme.NewClient(server.URL, account, acme.EC256)
+	require.NoError(t, err)
+	return client
+}
+
+func withCertificateBackOff(interval time.Duration, maxElapsedTime time.Duration) func() {
+	previous := newCertificateBackOff
+	newCertificateBackOff = func() backoff.BackOff {
+		ebo := backoff.NewExponentialBackOff()
+		ebo.InitialInterval = interval
+		ebo.RandomizationFactor = 0
+		ebo.Multiplier = 2
+		ebo.MaxElapsedTime = maxElapsedTime
+		return ebo
+	}
+	return func() {
+		newCertificateBackOff = previous
+	}
+}
+
+func TestIsRateLimitError(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{
+			desc:     "too many requests status",
+			err:      &obtainError{failures: map[string]error{"foo.com": acme.RemoteError{StatusCode: http.StatusTooManyRequests}}},
+			expected: true,
+		},
+		{
+			desc:     "rate limited type",
+			err:      &obtainError{failures: map[string]error{"foo.com": acme.RemoteError{StatusCode: http.StatusForbidden, Type: "urn:acme:error:rateLimited"}}},
+			expected: true,
+		},
+		{
+			desc:     "other remote error",
+			err:      &obtainError{failures: map[string]error{"foo.com": acme.RemoteError{StatusCode: http.StatusBadRequest, Type: "urn:acme:error:malformed"}}},
+			expected: false,
+		},
+		{
+			desc:     "not an obtain error",
+			err:      acme.RemoteError{StatusCode: http.StatusTooManyRequests},
+			expected: false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, isRateLimitError(test.err))
+		})
+	}
+}
+
+// newTestRequestingACME creates an ACME running its jobs, which requests the certificates from the server
+// and stores them in the directory.
+func newTestRequestingACME(t *testing.T, server *mockACMEServer, dir string, pendingDomains ...Domain) *ACME {
+	store := NewLocalStore(dir + "/acme.json")
+	transaction, _, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, transaction.Commit(&Account{Email: "f@f", PendingDomains: pendingDomains}))
+
+	a := &ACME{
+		client:    newTestACMEClient(t, server),
+		store:     store,
+		jobs:      channels.NewInfiniteChannel(),
+		TLSConfig: &tls.Config{},
+	}
+	a.challengeProvider = &challengeProvider{store: store}
+	require.NoError(t, a.client.SetChallengeProvider(acme.TLSSNI01, a.challengeProvider))
+	a.runJobs()
+	return a
+}
+
+// waitCertificateRequests waits for the end of the certificate requests of the ACME.
+func waitCertificateRequests(t *testing.T, a *ACME) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		a.requestsLock.Lock()
+		requests := len(a.requests)
+		a.requestsLock.Unlock()
+		if requests == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the certificate requests should be over")
+}
+
+// waitJobs waits for the jobs queued before returning to be run, failing after the timeout.
+func waitJobs(t *testing.T, a *ACME, timeout time.Duration) {
+	done := make(chan struct{})
+	a.jobs.In() <- func() {
+		close(done)
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("the jobs should have been run")
+	}
+}
+
+func obtainCertificateInJob(a *ACME, domain Domain) {
+	a.jobs.In() <- func() {
+		a.obtainCertificateForDomain(domain)
+	}
+}
+
+func TestObtainCertificateForDomainBacksOffOnRateLimit(t *testing.T) {
+	defer withCertificateBackOff(50*time.Millisecond, 10*time.Second)()
+	server := newMockACMEServer(3, http.StatusBadRequest)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	domain := Domain{Main: "foo.com"}
+	a := newTestRequestingACME(t, server, dir, domain)
+	defer a.jobs.Close()
+	a.Domains = []Domain{domain}
+	obtainCertificateInJob(a, domain)
+	waitCertificateRequests(t, a)
+
+	// 3 rate limited attempts, then a failure which is not retried
+	requests := server.authzRequests()
+	require.Len(t, requests, 4)
+	expectedInterval := 50 * time.Millisecond
+	for i := 1; i < len(requests); i++ {
+		interval := requests[i].Sub(requests[i-1])
+		assert.True(t, interval >= expectedInterval, "attempt %d came after %s, expected at least %s", i, interval, expectedInterval)
+		expectedInterval *= 2
+	}
+	assert.Empty(t, a.store.Get().(*Account).PendingDomains, "the domain should no longer be pending after its definitive failure")
+}
+
+func TestObtainCertificateForDomainLimitsChallengeRetries(t *testing.T) {
+	defer withCertificateBackOff(10*time.Millisecond, 10*time.Second)()
+
+	testCases := []struct {
+		desc                string
+		challengeRetries    int
+		expectedValidations int
+	}{
+		{
+			desc:                "no retry by default",
+			expectedValidations: 1,
+		},
+		{
+			desc:                "configured retries",
+			challengeRetries:    2,
+			expectedValidations: 3,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			server := newMockACMEServer(0, http.StatusCreated)
+			server.challengeStatus = "invalid"
+			defer server.Close()
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			domain := Domain{Main: "foo.com"}
+			a := newTestRequestingACME(t, server, dir, domain)
+			defer a.jobs.Close()
+			a.Domains = []Domain{domain}
+			a.ChallengeRetries = test.challengeRetries
+			obtainCertificateInJob(a, domain)
+			waitCertificateRequests(t, a)
+
+			assert.Equal(t, test.expectedValidations, server.challengeValidations())
+			account := a.store.Get().(*Account)
+			assert.Empty(t, account.ChallengeCerts, "the challenge certificates should be cleaned up")
+			assert.Empty(t, account.PendingDomains)
+		})
+	}
+}
+
+func TestObtainCertificateForDomainRetriesOutsideJobs(t *testing.T) {
+	defer withCertificateBackOff(time.Hour, 3*time.Hour)()
+	server := newMockACMEServer(-1, http.StatusBadRequest)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	domain := Domain{Main: "foo.com"}
+	a := newTestRequestingACME(t, server, dir, domain)
+	defer a.jobs.Close()
+	a.Domains = []Domain{domain}
+	obtainCertificateInJob(a, domain)
+	// a domain being retried is not requested again
+	obtainCertificateInJob(a, domain)
+
+	// the jobs do not wait for the retry of the rate limited request
+	waitJobs(t, a, 5*time.Second)
+	assert.Len(t, server.authzRequests(), 1)
+	assert.Equal(t, []Domain{domain}, a.store.Get().(*Account).PendingDomains, "the domain should be pending until its retries are over")
+}
+
+func TestUpdateHostRuleDomainsForgetsRemovedDomains(t *testing.T) {
+	defer withCertificateBackOff(50*time.Millisecond, 10*time.Second)()
+	server := newMockACMEServer(-1, http.StatusBadRequest)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := newTestRequestingACME(t, server, dir)
+	defer a.jobs.Close()
+	a.OnHostRule = true
+	a.LoadCertificateForDomains([]string{"foo.com"})
+	a.LoadCertificateForDomains([]string{"bar.com", "www.bar.com"})
+	a.UpdateHostRuleDomains([][]string{{"bar.com", "www.bar.com"}})
+
+	waitJobs(t, a, 5*time.Second)
+	assert.Equal(t, []Domain{{Main: "bar.com", SANs: []string{"www.bar.com"}}}, a.store.Get().(*Account).PendingDomains)
+
+	a.UpdateHostRuleDomains(nil)
+	waitCertificateRequests(t, a)
+	assert.Empty(t, a.store.Get().(*Account).PendingDomains)
+	attempts := len(server.authzRequests())
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, server.authzRequests(), attempts, "the requests of the removed domains should not be tried again")
+}
+
+func TestBuildACMEClientUsesCAServer(t *testing.T) {
+	server := newMockACMEServer(0, http.StatusCreated)
+	defer server.Close()
+
+	account := &Account{Email: "f@f"}
+	account.PrivateKey, _ = base64.StdEncoding.DecodeString(testAccountPrivateKey)
+	a := ACME{CAServer: server.URL + "/directory"}
+	_, err := a.buildACMEClient(account)
+	require.NoError(t, err)
+	assert.Equal(t, 1, server.directoryRequests())
+
+	assert.Equal(t, DefaultCAServer, (&ACME{}).getCAServer())
+}
+
+func TestRetrieveCertificatesKeepsPendingDomains(t *testing.T) {
+	defer withCertificateBackOff(time.Hour, 3*time.Hour)()
+
+	testCases := []struct {
+		desc                   string
+		onHostRule             bool
+		expectedPendingDomains []Domain
+	}{
+		{
+			desc:                   "pending host rule domain kept until the configuration is loaded",
+			onHostRule:             true,
+			expectedPendingDomains: []Domain{{Main: "pending.com"}, {Main: "new.com", SANs: []string{"www.new.com"}}},
+		},
+		{
+			desc:                   "pending domain no longer configured",
+			expectedPendingDomains: []Domain{{Main: "new.com", SANs: []string{"www.new.com"}}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			server := newMockACMEServer(-1, http.StatusBadRequest)
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "acme")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			storageFile := dir + "/acme.json"
+
+			account := &Account{
+				Email:          "f@f",
+				PendingDomains: []Domain{{Main: "pending.com"}},
+			}
+			store := NewLocalStore(storageFile)
+			transaction, _, err := store.Begin()
+			require.NoError(t, err)
+			require.NoError(t, transaction.Commit(account))
+
+			a := &ACME{
+				Domains:    []Domain{{Main: "new.com", SANs: []string{"www.new.com"}}},
+				OnHostRule: test.onHostRule,
+				client:     newTestACMEClient(t, server),
+				store:      store,
+				jobs:       channels.NewInfiniteChannel(),
+			}
+			a.retrieveCertificates()
+			job := <-a.jobs.Out()
+			job.(func())()
+
+			assert.NotEmpty(t, server.authzRequests())
+
+			data, err := ioutil.ReadFile(storageFile)
+			require.NoError(t, err)
+			storedAccount := &Account{}
+			require.NoError(t, json.Unmarshal(data, storedAccount))
+			assert.Equal(t, test.expectedPendingDomains, storedAccount.PendingDomains)
+		})
+	}
+}
+
+func newTestOnDemandACME(t *testing.T, server *mockACMEServer, storageFile string) *ACME {
+	account := &Account{Email: "f@f"}
+	store := NewLocalStore(storageFile)
+	transaction, _, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, transaction.Commit(account))
+
+	a := &ACME{
+		OnDemand:        true,
+		OnDemandDomains: []string{"*.example.com"},
+		client:          newTestACMEClient(t, server),
+		store:           store,
+		TLSConfig:       &tls.Config{},
+	}
+	a.challengeProvider = &challengeProvider{store: store}
+	return a
+}
+
+func TestOnDemandHandshakeTriggersIssuance(t *testing.T) {
+	server := newMockACMEServer(0, http.StatusCreated)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := newTestOnDemandACME(t, server, dir+"/acme.json")
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		defer serverConn.Close()
+		tls.Server(serverConn, &tls.Config{GetCertificate: a.getCertificate}).Handshake()
+	}()
+	client := tls.Client(clientConn, &tls.Config{ServerName: "foo.example.com", InsecureSkipVerify: true})
+	require.NoError(t, client.Handshake())
+
+	peerCertificates := client.ConnectionState().PeerCertificates
+	require.NotEmpty(t, peerCertificates)
+	assert.Equal(t, "foo.example.com", peerCertificates[0].Subject.CommonName)
+	assert.Equal(t, 1, server.issuedCertificates())
+
+	account := a.store.Get().(*Account)
+	_, exists := account.DomainsCertificate.getCertificateForDomain("foo.example.com")
+	assert.True(t, exists, "the on demand certificate should be stored")
+}
+
+func TestOnDemandConcurrentHandshakesCoalesce(t *testing.T) {
+	server := newMockACMEServer(0, http.StatusCreated)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := newTestOnDemandACME(t, server, dir+"/acme.json")
+
+	var wg sync.WaitGroup
+	certificates := make([]*tls.Certificate, 10)
+	for i := range certificates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			certificates[i], _ = a.getCertificate(&tls.ClientHelloInfo{ServerName: "bar.example.com"})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, certificate := range certificates {
+		assert.NotNil(t, certificate)
+	}
+	assert.Equal(t, 1, server.issuedCertificates())
+}
+
+func TestOnDemandDomainNotAllowed(t *testing.T) {
+	server := newMockACMEServer(0, http.StatusCreated)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := newTestOnDemandACME(t, server, dir+"/acme.json")
+
+	certificate, err := a.getCertificate(&tls.ClientHelloInfo{ServerName: "foo.example.org"})
+	require.NoError(t, err)
+	assert.Nil(t, certificate)
+	assert.Empty(t, server.authzRequests())
+}
+
+func TestIsOnDemandDomainAllowed(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		patterns []string
+		domain   string
+		expected bool
+	}{
+		{
+			desc:     "no patterns",
+			domain:   "foo.com",
+			expected: true,
+		},
+		{
+			desc:     "exact match",
+			patterns: []string{"foo.com"},
+			domain:   "foo.com",
+			expected: true,
+		},
+		{
+			desc:     "wildcard match",
+			patterns: []string{"bar.com", "*.Foo.com"},
+			domain:   "www.foo.com",
+			expected: true,
+		},
+		{
+			desc:     "no match",
+			patterns: []string{"*.foo.com"},
+			domain:   "foo.com.evil.org",
+			expected: false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			a := &ACME{OnDemandDomains: test.patterns}
+			assert.Equal(t, test.expected, a.isOnDemandDomainAllowed(test.domain))
+		})
+	}
+}
+
+func TestHTTPChallengeHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := NewLocalStore(dir + "/acme.json")
+	transaction, _, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, transaction.Commit(&Account{Email: "f@f"}))
+
+	a := &ACME{HTTPChallenge: &HTTPChallenge{EntryPoint: "http"}}
+	handler := a.HTTPChallengeHandler()
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}
+	serve := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, target, nil), next)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve("http://foo.com/.well-known/acme-challenge/token").Code, "no store yet")
+
+	a.httpChallenge.setStore(store)
+	require.NoError(t, a.httpChallenge.Present("foo.com", "token", "token.key"))
+
+	recorder := serve("http://FOO.com:80/.well-known/acme-challenge/token")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "token.key", recorder.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("http://bar.com/.well-known/acme-challenge/token").Code, "other domain")
+	assert.Equal(t, http.StatusNotFound, serve("http://foo.com/.well-known/acme-challenge/other").Code, "other token")
+	assert.Equal(t, http.StatusTeapot, serve("http://foo.com/token").Code, "other path")
+
+	require.NoError(t, a.httpChallenge.CleanUp("foo.com", "token", "token.key"))
+	assert.Equal(t, http.StatusNotFound, serve("http://foo.com/.well-known/acme-challenge/token").Code, "cleaned up")
+	assert.Empty(t, store.Get().(*Account).HTTPChallenges)
+}
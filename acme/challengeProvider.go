@@ -14,11 +14,15 @@ import (
 	"github.com/xenolf/lego/acme"
 )
 
+// defaultChallengeTimeout is the maximum time waiting for a challenge to be ready, when not configured
+const defaultChallengeTimeout = 60 * time.Second
+
 var _ acme.ChallengeProviderTimeout = (*challengeProvider)(nil)
 
 type challengeProvider struct {
-	store cluster.Store
-	lock  sync.RWMutex
+	store   cluster.Store
+	lock    sync.RWMutex
+	timeout time.Duration
 }
 
 func (c *challengeProvider) getCertificate(domain string) (cert *tls.Certificate, exists bool) {
@@ -49,7 +53,7 @@ func (c *challengeProvider) getCertificate(domain string) (cert *tls.Certificate
 		log.Errorf("Error getting cert: %v, retrying in %s", err, time)
 	}
 	ebo := backoff.NewExponentialBackOff()
-	ebo.MaxElapsedTime = 60 * time.Second
+	ebo.MaxElapsedTime, _ = c.Timeout()
 	err := backoff.RetryNotify(safe.OperationWithRecover(operation), ebo, notify)
 	if err != nil {
 		log.Errorf("Error getting cert: %v", err)
@@ -93,5 +97,8 @@ func (c *challengeProvider) CleanUp(domain, token, keyAuth string) error {
 }
 
 func (c *challengeProvider) Timeout() (timeout, interval time.Duration) {
-	return 60 * time.Second, 5 * time.Second
+	if c.timeout > 0 {
+		return c.timeout, 5 * time.Second
+	}
+	return defaultChallengeTimeout, 5 * time.Second
 }
@@ -0,0 +1,101 @@
+package acme
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/containous/traefik/cluster"
+	"github.com/containous/traefik/log"
+	"github.com/xenolf/lego/acme"
+)
+
+// httpChallengePathPrefix is the path prefix of the HTTP-01 challenges, followed by their token
+const httpChallengePathPrefix = "/.well-known/acme-challenge/"
+
+var _ acme.ChallengeProvider = (*httpChallengeProvider)(nil)
+
+// httpChallengeProvider presents the HTTP-01 challenges through the store of the account,
+// for every Traefik instance of a cluster to answer them.
+type httpChallengeProvider struct {
+	lock  sync.RWMutex
+	store cluster.Store
+}
+
+func (c *httpChallengeProvider) setStore(store cluster.Store) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.store = store
+}
+
+// getKeyAuthorization returns the key authorization of the challenge of the token for the domain.
+func (c *httpChallengeProvider) getKeyAuthorization(token, domain string) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.store == nil {
+		return "", false
+	}
+	account := c.store.Get().(*Account)
+	keyAuth, ok := account.HTTPChallenges[token][domain]
+	return keyAuth, ok
+}
+
+func (c *httpChallengeProvider) Present(domain, token, keyAuth string) error {
+	log.Debugf("HTTP Challenge Present %s", domain)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	transaction, object, err := c.store.Begin()
+	if err != nil {
+		return err
+	}
+	account := object.(*Account)
+	if account.HTTPChallenges == nil {
+		account.HTTPChallenges = map[string]map[string]string{}
+	}
+	if account.HTTPChallenges[token] == nil {
+		account.HTTPChallenges[token] = map[string]string{}
+	}
+	account.HTTPChallenges[token][domain] = keyAuth
+	return transaction.Commit(account)
+}
+
+func (c *httpChallengeProvider) CleanUp(domain, token, keyAuth string) error {
+	log.Debugf("HTTP Challenge CleanUp %s", domain)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	transaction, object, err := c.store.Begin()
+	if err != nil {
+		return err
+	}
+	account := object.(*Account)
+	delete(account.HTTPChallenges[token], domain)
+	if len(account.HTTPChallenges[token]) == 0 {
+		delete(account.HTTPChallenges, token)
+	}
+	return transaction.Commit(account)
+}
+
+// HTTPChallengeHandler returns the handler answering the HTTP-01 challenges in progress,
+// the other requests being passed to the next handler.
+func (a *ACME) HTTPChallengeHandler() func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		if !strings.HasPrefix(r.URL.Path, httpChallengePathPrefix) || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next(rw, r)
+			return
+		}
+		domain, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			domain = r.Host
+		}
+		domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+		keyAuth, ok := a.httpChallenge.getKeyAuthorization(strings.TrimPrefix(r.URL.Path, httpChallengePathPrefix), domain)
+		if !ok {
+			log.Debugf("No HTTP challenge in progress for %s%s", r.Host, r.URL.Path)
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte(keyAuth))
+	}
+}
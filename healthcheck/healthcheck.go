@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +16,15 @@ import (
 	"github.com/vulcand/oxy/roundrobin"
 )
 
+const (
+	// ModeHTTP checks the servers with HTTP GET requests on the health check path, expecting 200 OK.
+	ModeHTTP = "http"
+	// ModeTCP checks that a TCP connection to the servers succeeds, for the backends without HTTP health endpoint.
+	ModeTCP = "tcp"
+	// DefaultTimeout is the default time within which a server must pass its health check.
+	DefaultTimeout = 5 * time.Second
+)
+
 var singleton *HealthCheck
 var once sync.Once
 
@@ -28,13 +38,30 @@ func GetHealthCheck() *HealthCheck {
 
 // Options are the public health check options.
 type Options struct {
+	// Mode is ModeHTTP or ModeTCP, ModeHTTP when unset.
+	Mode     string
 	Path     string
 	Port     int
+	Query    string
+	Headers  map[string]string
 	Interval time.Duration
 	LB       LoadBalancer
+	// SlowStart is the duration over which the weight of a recovered server ramps up, if any.
+	SlowStart time.Duration
+	// Weights are the configured weights of the servers, by URL.
+	Weights map[string]int
+	// HealthyThreshold and UnhealthyThreshold are the numbers of consecutive successful and failed health checks
+	// putting a server back in the load balancer and removing it, 1 when unset.
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	// Timeout is the time within which a server must pass its health check, DefaultTimeout when unset.
+	Timeout time.Duration
 }
 
 func (opt Options) String() string {
+	if opt.Mode == ModeTCP {
+		return fmt.Sprintf("[Mode: %s Interval: %s]", opt.Mode, opt.Interval)
+	}
 	return fmt.Sprintf("[Path: %s Interval: %s]", opt.Path, opt.Interval)
 }
 
@@ -43,12 +70,23 @@ type BackendHealthCheck struct {
 	Options
 	disabledURLs   []*url.URL
 	requestTimeout time.Duration
+	mutex          sync.RWMutex
+	// recoveries are the times the servers in slow start recovered at, by URL.
+	recoveries map[string]time.Time
+	// excludedURLs are the servers taken out of the load balancer through the API, not to be put back on recovery.
+	excludedURLs map[string]bool
+	// successes and failures are the numbers of consecutive successful health checks of the disabled servers,
+	// and of consecutive failed ones of the enabled servers, by URL.
+	successes map[string]int
+	failures  map[string]int
+	now       func() time.Time
 }
 
 //HealthCheck struct
 type HealthCheck struct {
 	Backends map[string]*BackendHealthCheck
 	cancel   context.CancelFunc
+	mutex    sync.RWMutex
 }
 
 // LoadBalancer includes functionality for load-balancing management.
@@ -66,15 +104,26 @@ func newHealthCheck() *HealthCheck {
 
 // NewBackendHealthCheck Instantiate a new BackendHealthCheck
 func NewBackendHealthCheck(options Options) *BackendHealthCheck {
+	requestTimeout := DefaultTimeout
+	if options.Timeout > 0 {
+		requestTimeout = options.Timeout
+	}
 	return &BackendHealthCheck{
 		Options:        options,
-		requestTimeout: 5 * time.Second,
+		requestTimeout: requestTimeout,
+		recoveries:     make(map[string]time.Time),
+		excludedURLs:   make(map[string]bool),
+		successes:      make(map[string]int),
+		failures:       make(map[string]int),
+		now:            time.Now,
 	}
 }
 
 //SetBackendsConfiguration set backends configuration
 func (hc *HealthCheck) SetBackendsConfiguration(parentCtx context.Context, backends map[string]*BackendHealthCheck) {
+	hc.mutex.Lock()
 	hc.Backends = backends
+	hc.mutex.Unlock()
 	if hc.cancel != nil {
 		hc.cancel()
 	}
@@ -90,11 +139,60 @@ func (hc *HealthCheck) SetBackendsConfiguration(parentCtx context.Context, backe
 	}
 }
 
+// GetBackend returns the health check of the named backend, if any.
+func (hc *HealthCheck) GetBackend(backendName string) (*BackendHealthCheck, bool) {
+	hc.mutex.RLock()
+	defer hc.mutex.RUnlock()
+	backend, ok := hc.Backends[backendName]
+	return backend, ok
+}
+
+// IsServerUp returns false if the server has been removed from the load balancer by the health check.
+func (backend *BackendHealthCheck) IsServerUp(serverURL *url.URL) bool {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	for _, disabledURL := range backend.disabledURLs {
+		if disabledURL.String() == serverURL.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// SetServerWeight changes the weight the server is put back in the load balancer with once it recovers.
+// A zero weight excludes the server, which is then left out of the load balancer even once it recovers.
+func (backend *BackendHealthCheck) SetServerWeight(serverURL *url.URL, weight int) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+
+	weights := make(map[string]int, len(backend.Weights)+1)
+	for key, value := range backend.Weights {
+		weights[key] = value
+	}
+	if weight > 0 {
+		weights[serverURL.String()] = weight
+	}
+	backend.Weights = weights
+	backend.excludedURLs[serverURL.String()] = weight == 0
+}
+
+func (backend *BackendHealthCheck) isExcluded(serverURL *url.URL) bool {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	return backend.excludedURLs[serverURL.String()]
+}
+
 func (hc *HealthCheck) execute(ctx context.Context, backendID string, backend *BackendHealthCheck) {
 	log.Debugf("Initial healthcheck for currentBackend %s ", backendID)
 	checkBackend(backend)
 	ticker := time.NewTicker(backend.Interval)
 	defer ticker.Stop()
+	var slowStartTick <-chan time.Time
+	if backend.SlowStart > 0 {
+		slowStartTicker := time.NewTicker(slowStartStep(backend.SlowStart))
+		defer slowStartTicker.Stop()
+		slowStartTick = slowStartTicker.C
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -103,6 +201,8 @@ func (hc *HealthCheck) execute(ctx context.Context, backendID string, backend *B
 		case <-ticker.C:
 			log.Debugf("Refreshing healthcheck for currentBackend %s ", backendID)
 			checkBackend(backend)
+		case <-slowStartTick:
+			backend.rampUpWeights()
 		}
 	}
 }
@@ -111,26 +211,79 @@ func checkBackend(currentBackend *BackendHealthCheck) {
 	enabledURLs := currentBackend.LB.Servers()
 	var newDisabledURLs []*url.URL
 	for _, url := range currentBackend.disabledURLs {
-		if checkHealth(url, currentBackend) {
-			log.Debugf("HealthCheck is up [%s]: Upsert in server list", url.String())
-			currentBackend.LB.UpsertServer(url, roundrobin.Weight(1))
-		} else {
-			log.Warnf("HealthCheck is still failing [%s]", url.String())
+		key := url.String()
+		if !checkHealth(url, currentBackend) {
+			log.Warnf("HealthCheck is still failing [%s]", key)
+			delete(currentBackend.successes, key)
 			newDisabledURLs = append(newDisabledURLs, url)
+			continue
 		}
+		currentBackend.successes[key]++
+		if successes := currentBackend.successes[key]; successes < threshold(currentBackend.HealthyThreshold) {
+			log.Debugf("HealthCheck is up [%s] %d time(s) in a row: Still out of the server list", key, successes)
+			newDisabledURLs = append(newDisabledURLs, url)
+			continue
+		}
+		log.Debugf("HealthCheck is up [%s]: Upsert in server list", key)
+		delete(currentBackend.successes, key)
+		currentBackend.recoverServer(url)
 	}
-	currentBackend.disabledURLs = newDisabledURLs
+	currentBackend.setDisabledURLs(newDisabledURLs)
 
 	for _, url := range enabledURLs {
-		if !checkHealth(url, currentBackend) {
-			log.Warnf("HealthCheck has failed [%s]: Remove from server list", url.String())
-			currentBackend.LB.RemoveServer(url)
-			currentBackend.disabledURLs = append(currentBackend.disabledURLs, url)
+		key := url.String()
+		if checkHealth(url, currentBackend) {
+			delete(currentBackend.failures, key)
+			continue
+		}
+		currentBackend.failures[key]++
+		if failures := currentBackend.failures[key]; failures < threshold(currentBackend.UnhealthyThreshold) {
+			log.Warnf("HealthCheck has failed [%s] %d time(s) in a row: Still in the server list", key, failures)
+			continue
 		}
+		log.Warnf("HealthCheck has failed [%s]: Remove from server list", key)
+		delete(currentBackend.failures, key)
+		currentBackend.LB.RemoveServer(url)
+		delete(currentBackend.recoveries, key)
+		newDisabledURLs = append(newDisabledURLs, url)
+		currentBackend.setDisabledURLs(newDisabledURLs)
+	}
+}
+
+// threshold returns the number of consecutive health checks of a threshold option, 1 when unset.
+func threshold(option int) int {
+	if option > 0 {
+		return option
 	}
+	return 1
+}
+
+func (backend *BackendHealthCheck) setDisabledURLs(disabledURLs []*url.URL) {
+	backend.mutex.Lock()
+	backend.disabledURLs = disabledURLs
+	backend.mutex.Unlock()
 }
 
 func (backend *BackendHealthCheck) newRequest(serverURL *url.URL) (*http.Request, error) {
+	req, err := backend.newBareRequest(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.Options.Query != "" {
+		req.URL.RawQuery = backend.Options.Query
+	}
+	for name, value := range backend.Options.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+	return req, nil
+}
+
+func (backend *BackendHealthCheck) newBareRequest(serverURL *url.URL) (*http.Request, error) {
 	if backend.Options.Port == 0 {
 		return http.NewRequest("GET", serverURL.String()+backend.Path, nil)
 	}
@@ -145,6 +298,9 @@ func (backend *BackendHealthCheck) newRequest(serverURL *url.URL) (*http.Request
 }
 
 func checkHealth(serverURL *url.URL, backend *BackendHealthCheck) bool {
+	if backend.Mode == ModeTCP {
+		return checkTCPHealth(serverURL, backend)
+	}
 	client := http.Client{
 		Timeout: backend.requestTimeout,
 	}
@@ -161,3 +317,23 @@ func checkHealth(serverURL *url.URL, backend *BackendHealthCheck) bool {
 	}
 	return err == nil && resp.StatusCode == 200
 }
+
+// checkTCPHealth returns whether a TCP connection to the server, on the health check port if any, succeeds within the timeout.
+func checkTCPHealth(serverURL *url.URL, backend *BackendHealthCheck) bool {
+	port := serverURL.Port()
+	switch {
+	case backend.Options.Port != 0:
+		port = strconv.Itoa(backend.Options.Port)
+	case port == "" && serverURL.Scheme == "https":
+		port = "443"
+	case port == "":
+		port = "80"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(serverURL.Hostname(), port), backend.requestTimeout)
+	if err != nil {
+		log.Debugf("TCP healthcheck of %s failed: %s", serverURL, err)
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -2,9 +2,11 @@ package healthcheck
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -122,6 +124,61 @@ func TestSetBackendsConfiguration(t *testing.T) {
 	}
 }
 
+func TestSlowStart(t *testing.T) {
+	lb, err := roundrobin.New(http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stableURL := testhelpers.MustParseURL("http://stable")
+	recoveredURL := testhelpers.MustParseURL("http://recovered")
+	lb.UpsertServer(stableURL, roundrobin.Weight(2))
+
+	now := time.Now()
+	backend := NewBackendHealthCheck(Options{
+		Path:      "/path",
+		Interval:  time.Minute,
+		LB:        lb,
+		SlowStart: time.Minute,
+		Weights:   map[string]int{stableURL.String(): 2, recoveredURL.String(): 2},
+	})
+	backend.now = func() time.Time { return now }
+
+	// share returns the share of the requests routed to the recovered server.
+	share := func() float64 {
+		var recovered int
+		for i := 0; i < 1000; i++ {
+			u, err := lb.NextServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.String() == recoveredURL.String() {
+				recovered++
+			}
+		}
+		return float64(recovered) / 1000
+	}
+
+	backend.recoverServer(recoveredURL)
+	if got := share(); got > 0.1 {
+		t.Errorf("got a share of %f right after the recovery, wanted at most 0.1", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	backend.rampUpWeights()
+	if got := share(); got < 0.3 || got > 0.4 {
+		t.Errorf("got a share of %f halfway through the slow start, wanted about 1/3", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	backend.rampUpWeights()
+	if got := share(); got != 0.5 {
+		t.Errorf("got a share of %f after the slow start, wanted 0.5", got)
+	}
+	if weight, _ := lb.ServerWeight(stableURL); weight != 2 {
+		t.Errorf("got the stable server weight %d after the slow start, wanted 2", weight)
+	}
+}
+
 func TestNewRequest(t *testing.T) {
 	tests := []struct {
 		desc     string
@@ -258,3 +315,248 @@ func (th *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		th.done()
 	}
 }
+
+func TestNewRequestWithQueryAndHeaders(t *testing.T) {
+	backend := NewBackendHealthCheck(
+		Options{
+			Path:    "/health",
+			Port:    8080,
+			Query:   "verbose=1",
+			Headers: map[string]string{"Authorization": "Bearer token", "Host": "health.localhost"},
+		})
+
+	req, err := backend.newRequest(testhelpers.MustParseURL("http://backend1:80"))
+	if err != nil {
+		t.Fatalf("failed to create new backend request: %s", err)
+	}
+
+	if actual := req.URL.String(); actual != "http://backend1:8080/health?verbose=1" {
+		t.Errorf("got %s for healthcheck URL, wanted http://backend1:8080/health?verbose=1", actual)
+	}
+	if actual := req.Header.Get("Authorization"); actual != "Bearer token" {
+		t.Errorf("got %q for Authorization header, wanted %q", actual, "Bearer token")
+	}
+	if req.Host != "health.localhost" {
+		t.Errorf("got %s for request host, wanted health.localhost", req.Host)
+	}
+}
+
+func TestCheckHealthWithHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("verbose") != "1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		desc        string
+		options     Options
+		wantHealthy bool
+	}{
+		{
+			desc:        "without the required header and query",
+			options:     Options{Path: "/health"},
+			wantHealthy: false,
+		},
+		{
+			desc:        "with the required header only",
+			options:     Options{Path: "/health", Headers: map[string]string{"Authorization": "Bearer token"}},
+			wantHealthy: false,
+		},
+		{
+			desc:        "with the required header and query",
+			options:     Options{Path: "/health", Query: "verbose=1", Headers: map[string]string{"Authorization": "Bearer token"}},
+			wantHealthy: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			backend := NewBackendHealthCheck(test.options)
+
+			if healthy := checkHealth(testhelpers.MustParseURL(ts.URL), backend); healthy != test.wantHealthy {
+				t.Errorf("got healthy %t, wanted %t", healthy, test.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestSetServerWeight(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	serverURL := testhelpers.MustParseURL(ts.URL)
+
+	tests := []struct {
+		desc          string
+		weight        int
+		wantRecovered bool
+		wantWeight    int
+	}{
+		{
+			desc:          "recovered with the new weight",
+			weight:        5,
+			wantRecovered: true,
+			wantWeight:    5,
+		},
+		{
+			desc:   "excluded with a zero weight",
+			weight: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			lb, err := roundrobin.New(http.NotFoundHandler())
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend := NewBackendHealthCheck(Options{Path: "/health", LB: lb, Weights: map[string]int{ts.URL: 2}})
+			backend.disabledURLs = []*url.URL{serverURL}
+
+			backend.SetServerWeight(serverURL, test.weight)
+			checkBackend(backend)
+
+			weight, recovered := lb.ServerWeight(serverURL)
+			if recovered != test.wantRecovered {
+				t.Fatalf("got recovered %t, wanted %t", recovered, test.wantRecovered)
+			}
+			if recovered && weight != test.wantWeight {
+				t.Errorf("got weight %d, wanted %d", weight, test.wantWeight)
+			}
+			if !backend.IsServerUp(serverURL) {
+				t.Error("the server should not be disabled anymore")
+			}
+		})
+	}
+}
+
+func TestCheckBackendThresholds(t *testing.T) {
+	tests := []struct {
+		desc               string
+		healthyThreshold   int
+		unhealthyThreshold int
+		healthSequence     []bool
+		wantUp             []bool
+	}{
+		{
+			desc:           "default thresholds",
+			healthSequence: []bool{false, true, false, true},
+			wantUp:         []bool{false, true, false, true},
+		},
+		{
+			desc:               "down after consecutive failures",
+			unhealthyThreshold: 3,
+			healthSequence:     []bool{false, false, true, false, false, false, true},
+			wantUp:             []bool{true, true, true, true, true, false, true},
+		},
+		{
+			desc:             "up after consecutive successes",
+			healthyThreshold: 2,
+			healthSequence:   []bool{false, true, false, true, true, false},
+			wantUp:           []bool{false, false, false, false, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ts := newTestServer(func() {}, test.healthSequence)
+			defer ts.Close()
+			serverURL := testhelpers.MustParseURL(ts.URL)
+
+			lb := &testLoadBalancer{RWMutex: &sync.RWMutex{}, servers: []*url.URL{serverURL}}
+			backend := NewBackendHealthCheck(Options{
+				Path:               "/path",
+				LB:                 lb,
+				HealthyThreshold:   test.healthyThreshold,
+				UnhealthyThreshold: test.unhealthyThreshold,
+			})
+
+			for i, wantUp := range test.wantUp {
+				checkBackend(backend)
+				if up := backend.IsServerUp(serverURL); up != wantUp {
+					t.Fatalf("health check %d: got up %t, wanted %t", i, up, wantUp)
+				}
+				if inLB := len(lb.Servers()) == 1; inLB != wantUp {
+					t.Fatalf("health check %d: got server in the load balancer %t, wanted %t", i, inLB, wantUp)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBackendTCPMode(t *testing.T) {
+	// The server accepts TCP connections without speaking HTTP.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	serverURL := testhelpers.MustParseURL("http://" + listener.Addr().String())
+
+	lb := &testLoadBalancer{RWMutex: &sync.RWMutex{}}
+	backend := NewBackendHealthCheck(Options{Mode: ModeTCP, LB: lb, Timeout: time.Second})
+	backend.disabledURLs = []*url.URL{serverURL}
+
+	checkBackend(backend)
+	if !backend.IsServerUp(serverURL) || len(lb.Servers()) != 1 {
+		t.Fatal("the server accepting TCP connections should be up")
+	}
+
+	listener.Close()
+	checkBackend(backend)
+	if backend.IsServerUp(serverURL) || len(lb.Servers()) != 0 {
+		t.Fatal("the server on a closed port should be down")
+	}
+}
+
+func TestCheckHealthTCPModePort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port, err := strconv.Atoi(testhelpers.MustParseURL("http://" + listener.Addr().String()).Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server URL port is closed, the health check port being the listening one.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL := testhelpers.MustParseURL("http://" + closed.Addr().String())
+	closed.Close()
+
+	if checkHealth(serverURL, NewBackendHealthCheck(Options{Mode: ModeTCP})) {
+		t.Error("the health check on the closed server port should fail")
+	}
+	if !checkHealth(serverURL, NewBackendHealthCheck(Options{Mode: ModeTCP, Port: port})) {
+		t.Error("the health check on the listening port should succeed")
+	}
+}
@@ -0,0 +1,83 @@
+package healthcheck
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+const (
+	// slowStartScale is the factor of the weights of the servers during the slow starts,
+	// for the recovered servers to start from a small share of the requests.
+	slowStartScale = 10
+	// slowStartSteps is the number of weight updates over a slow start.
+	slowStartSteps = 10
+)
+
+func slowStartStep(slowStart time.Duration) time.Duration {
+	if step := slowStart / slowStartSteps; step > 0 {
+		return step
+	}
+	return slowStart
+}
+
+// weight returns the configured weight of the server, the load balancer default being 1.
+func (backend *BackendHealthCheck) weight(u *url.URL) int {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	if weight := backend.Weights[u.String()]; weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// recoverServer puts a server back in the load balancer, with its configured weight or in slow start.
+// The servers excluded through the API are left out.
+func (backend *BackendHealthCheck) recoverServer(u *url.URL) {
+	if backend.isExcluded(u) {
+		log.Debugf("HealthCheck is up [%s] but the server is excluded: Not upserted", u)
+		return
+	}
+	if backend.SlowStart <= 0 {
+		backend.LB.UpsertServer(u, roundrobin.Weight(backend.weight(u)))
+		return
+	}
+	log.Debugf("Slow start of [%s] over %s", u, backend.SlowStart)
+	backend.recoveries[u.String()] = backend.now()
+	backend.LB.UpsertServer(u, roundrobin.Weight(1))
+	backend.rampUpWeights()
+}
+
+// rampUpWeights updates the weights of the servers while some recovered servers are in slow start:
+// the other servers weigh slowStartScale times their weight, and the weight of the recovered servers ramps
+// from 1 up to as much over the slow start. The weights are restored once all the slow starts are over.
+func (backend *BackendHealthCheck) rampUpWeights() {
+	if len(backend.recoveries) == 0 {
+		return
+	}
+
+	now := backend.now()
+	for key, recovery := range backend.recoveries {
+		if now.Sub(recovery) >= backend.SlowStart {
+			delete(backend.recoveries, key)
+		}
+	}
+
+	for _, u := range backend.LB.Servers() {
+		weight := backend.weight(u)
+		if len(backend.recoveries) > 0 {
+			weight *= slowStartScale
+			if recovery, ok := backend.recoveries[u.String()]; ok {
+				weight = int(int64(weight) * int64(now.Sub(recovery)) / int64(backend.SlowStart))
+				if weight < 1 {
+					weight = 1
+				}
+			}
+		}
+		if err := backend.LB.UpsertServer(u, roundrobin.Weight(weight)); err != nil {
+			log.Errorf("Failed to set the weight of [%s] during slow start: %s", u, err)
+		}
+	}
+}
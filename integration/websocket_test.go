@@ -234,3 +234,56 @@ func (suite *WebsocketSuite) TestWrongOriginIgnoredByServer(c *check.C) {
 	c.Assert(string(msg), checker.Equals, "OK")
 
 }
+
+func (suite *WebsocketSuite) TestSubprotocols(c *check.C) {
+	var upgrader = gorillawebsocket.Upgrader{Subprotocols: []string{"chat.v2", "chat.v1"}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			mt, message, err := c.ReadMessage()
+			if err != nil {
+				break
+			}
+			err = c.WriteMessage(mt, message)
+			if err != nil {
+				break
+			}
+		}
+	}))
+
+	file := suite.adaptFile(c, "fixtures/websocket/config.toml", struct {
+		WebsocketServer string
+	}{
+		WebsocketServer: srv.URL,
+	})
+
+	defer os.Remove(file)
+	cmd, _ := suite.cmdTraefik(withConfigFile(file), "--debug")
+
+	err := cmd.Start()
+
+	c.Assert(err, check.IsNil)
+	defer cmd.Process.Kill()
+
+	// wait for traefik
+	err = try.GetRequest("http://127.0.0.1:8080/api/providers", 10*time.Second, try.BodyContains("127.0.0.1"))
+	c.Assert(err, checker.IsNil)
+
+	dialer := gorillawebsocket.Dialer{Subprotocols: []string{"chat.v1", "chat.v2"}}
+	conn, resp, err := dialer.Dial("ws://127.0.0.1:8000/ws", nil)
+	c.Assert(err, checker.IsNil)
+	c.Assert(resp.Header.Get("Sec-Websocket-Protocol"), checker.Equals, "chat.v2")
+	c.Assert(conn.Subprotocol(), checker.Equals, "chat.v2")
+
+	conn.WriteMessage(gorillawebsocket.TextMessage, []byte("OK"))
+
+	_, msg, err := conn.ReadMessage()
+	c.Assert(err, checker.IsNil)
+
+	c.Assert(string(msg), checker.Equals, "OK")
+}
@@ -2,14 +2,23 @@ package log
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
 
 	"github.com/Sirupsen/logrus"
 )
 
 var (
 	logger *logrus.Entry
+
+	// fileLock protects the log file opened by OpenFile, and its path.
+	fileLock sync.Mutex
+	file     *os.File
+	filePath string
 )
 
 func init() {
@@ -26,6 +35,52 @@ func SetOutput(out io.Writer) {
 	logrus.SetOutput(out)
 }
 
+// OpenFile opens the log file at path, creating its directory when missing, and sets it as the standard logger output.
+func OpenFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create log path %s: %s", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("error opening log file %s: %s", path, err)
+	}
+
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	SetOutput(f)
+	if file != nil {
+		file.Close()
+	}
+	file = f
+	filePath = path
+	return nil
+}
+
+// CloseFile closes the log file opened by OpenFile, if any.
+func CloseFile() error {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// RotateFile reopens the log file opened by OpenFile at its path, for the logs to follow it once renamed by a log rotation.
+// The logs keep going to the former file when the new one cannot be opened.
+func RotateFile() error {
+	fileLock.Lock()
+	path := filePath
+	opened := file != nil
+	fileLock.Unlock()
+	if !opened {
+		return nil
+	}
+	return OpenFile(path)
+}
+
 // SetFormatter sets the standard logger formatter.
 func SetFormatter(formatter logrus.Formatter) {
 	logrus.SetFormatter(formatter)
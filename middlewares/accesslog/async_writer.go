@@ -0,0 +1,135 @@
+package accesslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+const (
+	// DefaultBufferSize is the default number of access log entries waiting to be written.
+	DefaultBufferSize = 1000
+	// DefaultFlushInterval is the default interval between two flushes of the written access log entries.
+	DefaultFlushInterval = time.Second
+
+	// writeBufferSize is the size of the buffer holding the written entries until they are flushed.
+	writeBufferSize = 64 * 1024
+)
+
+var errAsyncWriterClosed = errors.New("access log writer closed")
+
+// asyncWriter writes the access log entries to its output from its own goroutine, the entries waiting in a queue
+// of bufferSize entries. The written entries are flushed every flushInterval, or once the write buffer is full.
+// When the queue is full, the entries are dropped if dropOnOverflow, and otherwise wait for room.
+type asyncWriter struct {
+	entries        chan []byte
+	dropOnOverflow bool
+	flushInterval  time.Duration
+	dropped        uint64
+	// droppedCounter, if any, counts the dropped entries.
+	droppedCounter metrics.Counter
+	done           chan struct{}
+
+	// closeLock prevents the entries from being queued once the writer is closed.
+	closeLock sync.RWMutex
+	closed    bool
+
+	// lock protects the write buffer, swapped to another output by setOutput.
+	lock   sync.Mutex
+	buffer *bufio.Writer
+}
+
+func newAsyncWriter(out io.Writer, bufferSize int, flushInterval time.Duration, dropOnOverflow bool) *asyncWriter {
+	w := &asyncWriter{
+		entries:        make(chan []byte, bufferSize),
+		dropOnOverflow: dropOnOverflow,
+		flushInterval:  flushInterval,
+		done:           make(chan struct{}),
+		buffer:         bufio.NewWriterSize(out, writeBufferSize),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a copy of the entry, the formatters reusing their buffers.
+func (w *asyncWriter) Write(entry []byte) (int, error) {
+	w.closeLock.RLock()
+	defer w.closeLock.RUnlock()
+	if w.closed {
+		return 0, errAsyncWriterClosed
+	}
+
+	queued := append([]byte(nil), entry...)
+	if !w.dropOnOverflow {
+		w.entries <- queued
+		return len(entry), nil
+	}
+	select {
+	case w.entries <- queued:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+		if w.droppedCounter != nil {
+			w.droppedCounter.Add(1)
+		}
+	}
+	return len(entry), nil
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.flush()
+				return
+			}
+			w.lock.Lock()
+			w.buffer.Write(entry)
+			w.lock.Unlock()
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *asyncWriter) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buffer.Flush()
+}
+
+// setOutput flushes the written entries to the current output, and writes the next ones to the new output.
+func (w *asyncWriter) setOutput(out io.Writer) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buffer.Flush()
+	w.buffer.Reset(out)
+}
+
+// droppedEntries returns the number of entries dropped so far.
+func (w *asyncWriter) droppedEntries() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// close writes and flushes the queued entries, the entries written afterwards being rejected.
+func (w *asyncWriter) close() {
+	w.closeLock.Lock()
+	if w.closed {
+		w.closeLock.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.entries)
+	w.closeLock.Unlock()
+
+	<-w.done
+}
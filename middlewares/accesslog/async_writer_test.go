@@ -0,0 +1,150 @@
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stalledWriter blocks the writes until it is released, signaling the first one.
+type stalledWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+	lock    sync.Mutex
+	written bytes.Buffer
+}
+
+func newStalledWriter() *stalledWriter {
+	return &stalledWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.written.Write(p)
+}
+
+func (w *stalledWriter) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.written.String()
+}
+
+func TestLoggerBufferingFlushesOnClose(t *testing.T) {
+	tmpDir := createTempDir(t, "buffering")
+	defer os.RemoveAll(tmpDir)
+
+	logFilePath := filepath.Join(tmpDir, "access.log")
+	logger, err := NewLogHandler(&types.AccessLog{
+		FilePath:  logFilePath,
+		Format:    JSONFormat,
+		Buffering: &types.AccessLogBuffering{BufferSize: 10, FlushInterval: "1h"},
+	}, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%d", i), nil), logWriterTestHandlerFunc)
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, logger.Close())
+
+	logs := readJSONLogs(t, logFilePath)
+	require.Len(t, logs, 100, "no entry should be lost on close")
+	paths := map[interface{}]bool{}
+	for _, log := range logs {
+		paths[log[RequestPath]] = true
+	}
+	assert.Len(t, paths, 100)
+}
+
+func TestAsyncWriterDoesNotBlockWrites(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		dropOnOverflow  bool
+		writes          int
+		expectedDropped uint64
+	}{
+		{
+			desc:   "room in the buffer",
+			writes: 10,
+		},
+		{
+			desc:            "overflow dropped",
+			dropOnOverflow:  true,
+			writes:          25,
+			expectedDropped: 15,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			out := newStalledWriter()
+			writer := newAsyncWriter(out, 10, time.Millisecond, test.dropOnOverflow)
+
+			// The output is stalled by the first flush, with an entry already taken from the queue.
+			_, err := writer.Write([]byte("first\n"))
+			require.NoError(t, err)
+			select {
+			case <-out.started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the first entry was not flushed")
+			}
+
+			written := make(chan struct{})
+			go func() {
+				defer close(written)
+				for i := 0; i < test.writes; i++ {
+					writer.Write([]byte(fmt.Sprintf("entry %d\n", i)))
+				}
+			}()
+			select {
+			case <-written:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the writes blocked on the stalled output")
+			}
+			assert.Equal(t, test.expectedDropped, writer.droppedEntries())
+
+			close(out.release)
+			writer.close()
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			assert.Len(t, lines, 1+test.writes-int(test.expectedDropped))
+
+			_, err = writer.Write([]byte("closed\n"))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewLogHandlerInvalidBuffering(t *testing.T) {
+	for _, buffering := range []types.AccessLogBuffering{
+		{BufferSize: -1},
+		{FlushInterval: "often"},
+		{FlushInterval: "0s"},
+	} {
+		buffering := buffering
+		_, err := NewLogHandler(&types.AccessLog{Format: JSONFormat, Buffering: &buffering}, nil)
+		assert.Error(t, err, "%+v", buffering)
+	}
+}
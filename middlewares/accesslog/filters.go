@@ -0,0 +1,213 @@
+package accesslog
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/containous/traefik/types"
+)
+
+const (
+	// Keep keeps the matching requests, or a field, in the access log.
+	Keep = "keep"
+	// Drop drops the matching requests, or a field, from the access log.
+	Drop = "drop"
+	// Sample logs only one in the sample rate of the matching requests.
+	Sample = "sample"
+	// Redact replaces the value of a field with redactedValue.
+	Redact = "redact"
+
+	redactedValue = "REDACTED"
+)
+
+// statusCodeRange is an inclusive range of status codes.
+type statusCodeRange struct {
+	min, max int
+}
+
+// filter selects the requests matching all of its criteria, the unset ones matching any request.
+type filter struct {
+	path        *regexp.Regexp
+	userAgent   *regexp.Regexp
+	statusCodes []statusCodeRange
+	minDuration time.Duration
+	action      string
+	sampleRate  uint64
+	count       uint64
+}
+
+func newFilter(config types.AccessLogFilter) (*filter, error) {
+	f := &filter{action: config.Action}
+	if f.action == "" {
+		f.action = Drop
+	}
+
+	var err error
+	if len(config.Path) > 0 {
+		if f.path, err = regexp.Compile(config.Path); err != nil {
+			return nil, fmt.Errorf("invalid path filter %q: %s", config.Path, err)
+		}
+	}
+	if len(config.UserAgent) > 0 {
+		if f.userAgent, err = regexp.Compile(config.UserAgent); err != nil {
+			return nil, fmt.Errorf("invalid user agent filter %q: %s", config.UserAgent, err)
+		}
+	}
+	for _, codes := range config.StatusCodes {
+		codeRange, err := parseStatusCodeRange(codes)
+		if err != nil {
+			return nil, err
+		}
+		f.statusCodes = append(f.statusCodes, codeRange)
+	}
+	if len(config.MinDuration) > 0 {
+		if f.minDuration, err = time.ParseDuration(config.MinDuration); err != nil {
+			return nil, fmt.Errorf("invalid minimum duration filter %q: %s", config.MinDuration, err)
+		}
+	}
+
+	switch f.action {
+	case Keep, Drop:
+	case Sample:
+		if config.SampleRate < 1 {
+			return nil, fmt.Errorf("invalid sample rate %d, it must be at least 1", config.SampleRate)
+		}
+		f.sampleRate = uint64(config.SampleRate)
+	default:
+		return nil, fmt.Errorf("unsupported access log filter action: %s", f.action)
+	}
+	return f, nil
+}
+
+func parseStatusCodeRange(codes string) (statusCodeRange, error) {
+	bounds := strings.SplitN(codes, "-", 2)
+	min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return statusCodeRange{}, fmt.Errorf("invalid status codes filter %q: %s", codes, err)
+	}
+	max := min
+	if len(bounds) == 2 {
+		if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+			return statusCodeRange{}, fmt.Errorf("invalid status codes filter %q: %s", codes, err)
+		}
+	}
+	return statusCodeRange{min: min, max: max}, nil
+}
+
+func (f *filter) matches(req *http.Request, status int, duration time.Duration) bool {
+	if f.path != nil && !f.path.MatchString(req.URL.Path) {
+		return false
+	}
+	if f.userAgent != nil && !f.userAgent.MatchString(req.UserAgent()) {
+		return false
+	}
+	if len(f.statusCodes) > 0 {
+		var matched bool
+		for _, codeRange := range f.statusCodes {
+			if status >= codeRange.min && status <= codeRange.max {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return duration >= f.minDuration
+}
+
+// keep tells whether a matching request is logged, the sampled ones being the first of each sample.
+func (f *filter) keep() bool {
+	switch f.action {
+	case Keep:
+		return true
+	case Sample:
+		return (atomic.AddUint64(&f.count, 1)-1)%f.sampleRate == 0
+	default:
+		return false
+	}
+}
+
+// keepRequest tells whether the request is logged, as decided by the first of the filters it matches.
+// The requests matching none of the filters are logged.
+func keepRequest(filters []*filter, req *http.Request, status int, duration time.Duration) bool {
+	for _, f := range filters {
+		if f.matches(req, status, duration) {
+			return f.keep()
+		}
+	}
+	return true
+}
+
+// fieldModes holds the modes of the fields of the access log.
+type fieldModes struct {
+	defaultMode string
+	names       map[string]string
+	headers     map[string]string
+}
+
+func newFieldModes(config *types.AccessLogFields) (*fieldModes, error) {
+	modes := &fieldModes{
+		defaultMode: config.DefaultMode,
+		names:       config.Names,
+		headers:     make(map[string]string, len(config.Headers)),
+	}
+	if modes.defaultMode == "" {
+		modes.defaultMode = Keep
+	}
+	if err := checkFieldMode(modes.defaultMode); err != nil {
+		return nil, err
+	}
+	for _, mode := range config.Names {
+		if err := checkFieldMode(mode); err != nil {
+			return nil, err
+		}
+	}
+	for name, mode := range config.Headers {
+		if err := checkFieldMode(mode); err != nil {
+			return nil, err
+		}
+		modes.headers[http.CanonicalHeaderKey(name)] = mode
+	}
+	return modes, nil
+}
+
+func checkFieldMode(mode string) error {
+	switch mode {
+	case Keep, Drop, Redact:
+		return nil
+	default:
+		return fmt.Errorf("unsupported access log field mode: %s", mode)
+	}
+}
+
+// setField sets a field according to its mode.
+func setField(fields logrus.Fields, name string, value interface{}, mode string) {
+	switch mode {
+	case Drop:
+	case Redact:
+		fields[name] = redactedValue
+	default:
+		fields[name] = value
+	}
+}
+
+func (m *fieldModes) nameMode(name string) string {
+	if mode, ok := m.names[name]; ok {
+		return mode
+	}
+	return m.defaultMode
+}
+
+func (m *fieldModes) headerMode(name string) string {
+	if mode, ok := m.headers[http.CanonicalHeaderKey(name)]; ok {
+		return mode
+	}
+	return m.defaultMode
+}
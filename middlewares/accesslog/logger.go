@@ -8,11 +8,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/containous/traefik/types"
+	"github.com/go-kit/kit/metrics"
 )
 
 type key string
@@ -31,12 +33,38 @@ const (
 
 // LogHandler will write each request and its response to the access log.
 type LogHandler struct {
-	logger *logrus.Logger
-	file   *os.File
+	logger       *logrus.Logger
+	file         *os.File
+	filePath     string
+	proxyChecker *types.ProxyChecker
+	filters      []*filter
+	fieldModes   *fieldModes
+	// writer, if any, writes the logs asynchronously to the file.
+	writer *asyncWriter
+	// lock protects the logger output, swapped by Rotate.
+	lock sync.RWMutex
 }
 
-// NewLogHandler creates a new LogHandler
-func NewLogHandler(config *types.AccessLog) (*LogHandler, error) {
+// NewLogHandler creates a new LogHandler.
+// The client host is taken from X-Forwarded-For only for requests coming from a proxy trusted by proxyChecker.
+func NewLogHandler(config *types.AccessLog, proxyChecker *types.ProxyChecker) (*LogHandler, error) {
+	var filters []*filter
+	for _, filterConfig := range config.Filters {
+		f, err := newFilter(filterConfig)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	fieldModes := &fieldModes{defaultMode: Keep}
+	if config.Fields != nil {
+		var err error
+		if fieldModes, err = newFieldModes(config.Fields); err != nil {
+			return nil, err
+		}
+	}
+
 	file := os.Stdout
 	if len(config.FilePath) > 0 {
 		f, err := openAccessLogFile(config.FilePath)
@@ -57,13 +85,38 @@ func NewLogHandler(config *types.AccessLog) (*LogHandler, error) {
 		return nil, fmt.Errorf("unsupported access log format: %s", config.Format)
 	}
 
+	var writer *asyncWriter
+	if buffering := config.Buffering; buffering != nil {
+		bufferSize := DefaultBufferSize
+		if buffering.BufferSize < 0 {
+			return nil, fmt.Errorf("access log buffer size must not be negative, got %d", buffering.BufferSize)
+		}
+		if buffering.BufferSize > 0 {
+			bufferSize = buffering.BufferSize
+		}
+		flushInterval := DefaultFlushInterval
+		if len(buffering.FlushInterval) > 0 {
+			var err error
+			if flushInterval, err = time.ParseDuration(buffering.FlushInterval); err != nil {
+				return nil, fmt.Errorf("invalid access log flush interval %q: %s", buffering.FlushInterval, err)
+			}
+			if flushInterval <= 0 {
+				return nil, fmt.Errorf("access log flush interval must be positive, got %s", buffering.FlushInterval)
+			}
+		}
+		writer = newAsyncWriter(file, bufferSize, flushInterval, buffering.DropOnOverflow)
+	}
+
 	logger := &logrus.Logger{
 		Out:       file,
 		Formatter: formatter,
 		Hooks:     make(logrus.LevelHooks),
 		Level:     logrus.InfoLevel,
 	}
-	return &LogHandler{logger: logger, file: file}, nil
+	if writer != nil {
+		logger.Out = writer
+	}
+	return &LogHandler{logger: logger, file: file, filePath: config.FilePath, proxyChecker: proxyChecker, filters: filters, fieldModes: fieldModes, writer: writer}, nil
 }
 
 func openAccessLogFile(filePath string) (*os.File, error) {
@@ -87,6 +140,18 @@ func GetLogDataTable(req *http.Request) *LogData {
 	return req.Context().Value(DataTableKey).(*LogData)
 }
 
+// NewDiscardedLogData creates a handler giving the requests their own logging data, which is not logged,
+// for the handlers serving copies of the logged requests such as the mirrors of the frontends.
+func NewDiscardedLogData(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(DataTableKey).(*LogData); ok {
+			logDataTable := &LogData{Core: make(CoreLogData), Request: req.Header}
+			req = req.WithContext(context.WithValue(req.Context(), DataTableKey, logDataTable))
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
 func (l *LogHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
 	now := time.Now().UTC()
 	core := make(CoreLogData)
@@ -124,6 +189,9 @@ func (l *LogHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, next h
 
 	core[ClientAddr] = req.RemoteAddr
 	core[ClientHost], core[ClientPort] = silentSplitHostPort(req.RemoteAddr)
+	if l.proxyChecker.IsTrusted(req.RemoteAddr) {
+		core[ClientHost] = l.proxyChecker.ClientIP(req)
+	}
 	core[ClientUsername] = usernameIfPresent(req.URL)
 
 	crw := &captureResponseWriter{rw: rw}
@@ -131,14 +199,51 @@ func (l *LogHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request, next h
 	next.ServeHTTP(crw, reqWithDataTable)
 
 	logDataTable.DownstreamResponse = crw.Header()
-	l.logTheRoundTrip(logDataTable, crr, crw)
+	l.logTheRoundTrip(req, logDataTable, crr, crw)
+}
+
+// SetDroppedEntriesCounter sets the counter of the entries dropped by the asynchronous writes on overflow,
+// before the handler serves any request.
+func (l *LogHandler) SetDroppedEntriesCounter(counter metrics.Counter) {
+	if l.writer != nil {
+		l.writer.droppedCounter = counter
+	}
 }
 
-// Close closes the Logger (i.e. the file etc).
+// Close closes the Logger (i.e. the file etc), first writing the entries waiting to be written asynchronously.
 func (l *LogHandler) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.writer != nil {
+		l.writer.close()
+	}
 	return l.file.Close()
 }
 
+// Rotate reopens the log file at its path, for the logs to follow it once renamed by a log rotation.
+// The file is only swapped once the new one is open: the logs keep going to the former file when it cannot be opened.
+func (l *LogHandler) Rotate() error {
+	if len(l.filePath) == 0 {
+		return nil
+	}
+	file, err := openAccessLogFile(l.filePath)
+	if err != nil {
+		return fmt.Errorf("error reopening access log file: %s", err)
+	}
+
+	l.lock.Lock()
+	previous := l.file
+	l.file = file
+	if l.writer != nil {
+		l.writer.setOutput(file)
+	} else {
+		l.logger.Out = file
+	}
+	l.lock.Unlock()
+
+	return previous.Close()
+}
+
 func silentSplitHostPort(value string) (host string, port string) {
 	host, port, err := net.SplitHostPort(value)
 	if err != nil {
@@ -158,7 +263,7 @@ func usernameIfPresent(theURL *url.URL) string {
 }
 
 // Logging handler to log frontend name, backend name, and elapsed time
-func (l *LogHandler) logTheRoundTrip(logDataTable *LogData, crr *captureRequestReader, crw *captureResponseWriter) {
+func (l *LogHandler) logTheRoundTrip(req *http.Request, logDataTable *LogData, crr *captureRequestReader, crw *captureResponseWriter) {
 
 	core := logDataTable.Core
 
@@ -185,24 +290,30 @@ func (l *LogHandler) logTheRoundTrip(logDataTable *LogData, crr *captureRequestR
 		core[Overhead] = total
 	}
 
+	if !keepRequest(l.filters, req, crw.Status(), total) {
+		return
+	}
+
 	fields := logrus.Fields{}
 
 	for k, v := range logDataTable.Core {
-		fields[k] = v
+		setField(fields, k, v, l.fieldModes.nameMode(k))
 	}
 
 	for k := range logDataTable.Request {
-		fields["request_"+k] = logDataTable.Request.Get(k)
+		setField(fields, "request_"+k, logDataTable.Request.Get(k), l.fieldModes.headerMode(k))
 	}
 
 	for k := range logDataTable.OriginResponse {
-		fields["origin_"+k] = logDataTable.OriginResponse.Get(k)
+		setField(fields, "origin_"+k, logDataTable.OriginResponse.Get(k), l.fieldModes.headerMode(k))
 	}
 
 	for k := range logDataTable.DownstreamResponse {
-		fields["downstream_"+k] = logDataTable.DownstreamResponse.Get(k)
+		setField(fields, "downstream_"+k, logDataTable.DownstreamResponse.Get(k), l.fieldModes.headerMode(k))
 	}
 
+	l.lock.RLock()
+	defer l.lock.RUnlock()
 	l.logger.WithFields(fields).Println()
 }
 
@@ -18,10 +18,17 @@ type CommonLogFormatter struct{}
 func (f *CommonLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	b := &bytes.Buffer{}
 
-	timestamp := entry.Data[StartUTC].(time.Time).Format(commonLogTimeFormat)
-	elapsedMillis := entry.Data[Duration].(time.Duration).Nanoseconds() / 1000000
+	// The start time and duration are missing once dropped from the access log fields.
+	timestamp := "-"
+	if start, ok := entry.Data[StartUTC].(time.Time); ok {
+		timestamp = start.Format(commonLogTimeFormat)
+	}
+	var elapsedMillis int64
+	if duration, ok := entry.Data[Duration].(time.Duration); ok {
+		elapsedMillis = duration.Nanoseconds() / 1000000
+	}
 
-	_, err := fmt.Fprintf(b, "%s - %s [%s] \"%s %s %s\" %d %d %s %s %d %s %s %dms\n",
+	_, err := fmt.Fprintf(b, "%s - %s [%s] \"%s %s %s\" %v %v %s %s %v %s %s %dms\n",
 		entry.Data[ClientHost],
 		entry.Data[ClientUsername],
 		timestamp,
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/containous/traefik/types"
@@ -49,6 +50,40 @@ func TestLoggerCLF(t *testing.T) {
 	assertValidLogData(t, logData)
 }
 
+func TestLoggerRotate(t *testing.T) {
+	tmpDir := createTempDir(t, "rotate")
+	defer os.RemoveAll(tmpDir)
+
+	logFilePath := filepath.Join(tmpDir, "access.log")
+	logger, err := NewLogHandler(&types.AccessLog{FilePath: logFilePath, Format: CommonFormat}, nil)
+	require.NoError(t, err)
+	defer logger.Close()
+	logRequest := func(path string) {
+		logger.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil), logWriterTestHandlerFunc)
+	}
+
+	logRequest("/before")
+	require.NoError(t, os.Rename(logFilePath, logFilePath+".1"))
+	require.NoError(t, logger.Rotate())
+	logRequest("/after")
+
+	// The logs keep going to the current file when the new one cannot be opened.
+	require.NoError(t, os.Mkdir(logFilePath+".2", 0755))
+	logger.filePath = logFilePath + ".2"
+	assert.Error(t, logger.Rotate())
+	logRequest("/failed")
+
+	rotated, err := ioutil.ReadFile(logFilePath + ".1")
+	require.NoError(t, err)
+	assert.Contains(t, string(rotated), "/before")
+	assert.NotContains(t, string(rotated), "/after")
+
+	current, err := ioutil.ReadFile(logFilePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(current), "/after")
+	assert.Contains(t, string(current), "/failed")
+}
+
 func TestLoggerJSON(t *testing.T) {
 	tmpDir := createTempDir(t, JSONFormat)
 	defer os.RemoveAll(tmpDir)
@@ -160,6 +195,203 @@ func TestLoggerJSON(t *testing.T) {
 	assert.Equal(t, len(jsonData), assertCount, string(logData))
 }
 
+func TestLoggerClientHostWithTrustedProxies(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc               string
+		remoteAddr         string
+		expectedClientHost string
+	}{
+		{
+			desc:               "request from a trusted proxy",
+			remoteAddr:         "10.0.0.1:1234",
+			expectedClientHost: "1.2.3.4",
+		},
+		{
+			desc:               "request from an untrusted source",
+			remoteAddr:         "5.6.7.8:1234",
+			expectedClientHost: "5.6.7.8",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			tmpDir := createTempDir(t, JSONFormat)
+			defer os.RemoveAll(tmpDir)
+
+			logFilePath := filepath.Join(tmpDir, logFileNameSuffix)
+			logger, err := NewLogHandler(&types.AccessLog{FilePath: logFilePath, Format: JSONFormat}, proxyChecker)
+			require.NoError(t, err)
+			defer logger.Close()
+
+			req := &http.Request{
+				Header: map[string][]string{
+					"X-Forwarded-For": {"1.2.3.4"},
+				},
+				Method:     testMethod,
+				RemoteAddr: test.remoteAddr,
+				URL:        &url.URL{Path: testPath},
+			}
+			logger.ServeHTTP(httptest.NewRecorder(), req, logWriterTestHandlerFunc)
+
+			logData, err := ioutil.ReadFile(logFilePath)
+			require.NoError(t, err)
+
+			jsonData := make(map[string]interface{})
+			err = json.Unmarshal(logData, &jsonData)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedClientHost, jsonData[ClientHost])
+			assert.Equal(t, test.remoteAddr, jsonData[ClientAddr])
+		})
+	}
+}
+
+// readJSONLogs reads the JSON access logs of the file.
+func readJSONLogs(t *testing.T, logFilePath string) []map[string]interface{} {
+	logData, err := ioutil.ReadFile(logFilePath)
+	require.NoError(t, err)
+
+	var logs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(logData)), "\n") {
+		if line == "" {
+			continue
+		}
+		jsonData := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(line), &jsonData))
+		logs = append(logs, jsonData)
+	}
+	return logs
+}
+
+func TestLoggerFilters(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		filters       []types.AccessLogFilter
+		paths         []string
+		expectedPaths []string
+	}{
+		{
+			desc:          "health checks dropped",
+			filters:       []types.AccessLogFilter{{Path: "^/healthz$"}},
+			paths:         []string{"/healthz", "/api", "/healthz", "/"},
+			expectedPaths: []string{"/api", "/"},
+		},
+		{
+			desc:          "noisy path sampled",
+			filters:       []types.AccessLogFilter{{Path: "^/poll", Action: Sample, SampleRate: 3}},
+			paths:         []string{"/poll", "/poll", "/api", "/poll", "/poll", "/poll", "/poll", "/poll"},
+			expectedPaths: []string{"/poll", "/api", "/poll", "/poll"},
+		},
+		{
+			desc: "first matching filter applied",
+			filters: []types.AccessLogFilter{
+				{StatusCodes: []string{"500-599"}, Action: Keep},
+				{UserAgent: "^kube-probe/"},
+			},
+			paths:         []string{"/", "/error"},
+			expectedPaths: []string{"/error"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := createTempDir(t, JSONFormat)
+			defer os.RemoveAll(tmpDir)
+			logFilePath := filepath.Join(tmpDir, logFileNameSuffix)
+
+			logger, err := NewLogHandler(&types.AccessLog{FilePath: logFilePath, Format: JSONFormat, Filters: test.filters}, nil)
+			require.NoError(t, err)
+			defer logger.Close()
+
+			handler := func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/error" {
+					rw.WriteHeader(http.StatusInternalServerError)
+				}
+			}
+			for _, path := range test.paths {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set("User-Agent", "kube-probe/1.8")
+				logger.ServeHTTP(httptest.NewRecorder(), req, handler)
+			}
+
+			var paths []string
+			for _, log := range readJSONLogs(t, logFilePath) {
+				paths = append(paths, log[RequestPath].(string))
+			}
+			assert.Equal(t, test.expectedPaths, paths)
+		})
+	}
+}
+
+func TestLoggerFieldModes(t *testing.T) {
+	tmpDir := createTempDir(t, JSONFormat)
+	defer os.RemoveAll(tmpDir)
+	logFilePath := filepath.Join(tmpDir, logFileNameSuffix)
+
+	logger, err := NewLogHandler(&types.AccessLog{
+		FilePath: logFilePath,
+		Format:   JSONFormat,
+		Fields: &types.AccessLogFields{
+			Names:   map[string]string{ClientUsername: Drop},
+			Headers: map[string]string{"authorization": Redact},
+		},
+	}, nil)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("User-Agent", testUserAgent)
+	logger.ServeHTTP(httptest.NewRecorder(), req, logWriterTestHandlerFunc)
+
+	logs := readJSONLogs(t, logFilePath)
+	require.Len(t, logs, 1)
+	assert.NotContains(t, logs[0], ClientUsername)
+	assert.Equal(t, "REDACTED", logs[0]["request_Authorization"])
+	assert.Equal(t, testUserAgent, logs[0]["request_User-Agent"])
+}
+
+func TestNewLogHandlerInvalidFilters(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config types.AccessLog
+	}{
+		{
+			desc:   "invalid path",
+			config: types.AccessLog{Format: JSONFormat, Filters: []types.AccessLogFilter{{Path: "("}}},
+		},
+		{
+			desc:   "invalid status codes",
+			config: types.AccessLog{Format: JSONFormat, Filters: []types.AccessLogFilter{{StatusCodes: []string{"5xx"}}}},
+		},
+		{
+			desc:   "sample without rate",
+			config: types.AccessLog{Format: JSONFormat, Filters: []types.AccessLogFilter{{Action: Sample}}},
+		},
+		{
+			desc:   "unsupported field mode",
+			config: types.AccessLog{Format: JSONFormat, Fields: &types.AccessLogFields{DefaultMode: "hide"}},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewLogHandler(&test.config, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
 func TestNewLogHandlerOutputStdout(t *testing.T) {
 	file, restoreStdout := captureStdout(t)
 	defer restoreStdout()
@@ -217,7 +449,7 @@ func createTempDir(t *testing.T, prefix string) string {
 }
 
 func doLogging(t *testing.T, config *types.AccessLog) {
-	logger, err := NewLogHandler(config)
+	logger, err := NewLogHandler(config, nil)
 	defer logger.Close()
 	require.NoError(t, err)
 
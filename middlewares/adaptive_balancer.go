@@ -0,0 +1,252 @@
+package middlewares
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// DefaultLoadHeader is the response header in which the servers report their load to the AdaptiveBalancer.
+	DefaultLoadHeader = "X-Server-Load"
+
+	// adaptiveDecay is the time constant over which the load reported by a server decays: the reduction
+	// of its weight decreases by a factor e over it, for the weight to return to the configured one.
+	adaptiveDecay = 10 * time.Second
+	// adaptiveMinWeight is the ratio of its configured weight kept by a server reporting a full load,
+	// for it to still receive some requests and report its load again.
+	adaptiveMinWeight = 0.05
+)
+
+// serverLoadNames are the loads reported by name, the other ones being numbers between 0 and 1.
+var serverLoadNames = map[string]float64{
+	"low":    0,
+	"medium": 0.5,
+	"high":   0.9,
+}
+
+var _ healthcheck.LoadBalancer = (*AdaptiveBalancer)(nil)
+
+// adaptiveServer holds the last load reported by a server, and its smooth weighted round robin state.
+type adaptiveServer struct {
+	load     float64
+	reported time.Time
+	current  float64
+}
+
+// AdaptiveBalancer is a weighted round robin load balancer whose servers report their load in a response header,
+// either as low, medium or high, or as a number from 0 (idle) to 1 (saturated). The effective weight of a server
+// is its configured weight reduced by its load, the reduction decaying over time after its last report.
+// The header is removed from the responses.
+type AdaptiveBalancer struct {
+	next       http.Handler
+	sticky     *roundrobin.StickySession
+	errHandler utils.ErrorHandler
+	header     string
+	// servers holds the URLs and the weights of the servers, with the options of the round robin.
+	servers *roundrobin.RoundRobin
+	now     func() time.Time
+
+	lock  sync.Mutex
+	loads map[string]*adaptiveServer
+}
+
+// NewAdaptiveBalancer builds a new AdaptiveBalancer forwarding the requests to next, with the sticky sessions, if any,
+// reading the load of the servers in the given response header, or DefaultLoadHeader when empty.
+func NewAdaptiveBalancer(next http.Handler, sticky *roundrobin.StickySession, header string) *AdaptiveBalancer {
+	if len(header) == 0 {
+		header = DefaultLoadHeader
+	}
+	servers, _ := roundrobin.New(next)
+	return &AdaptiveBalancer{
+		next:       next,
+		sticky:     sticky,
+		errHandler: utils.DefaultHandler,
+		header:     header,
+		servers:    servers,
+		now:        time.Now,
+		loads:      make(map[string]*adaptiveServer),
+	}
+}
+
+// UpsertServer adds a server, or updates its weight.
+func (b *AdaptiveBalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	return b.servers.UpsertServer(u, options...)
+}
+
+// RemoveServer removes a server, along with its reported load.
+func (b *AdaptiveBalancer) RemoveServer(u *url.URL) error {
+	if err := b.servers.RemoveServer(u); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	delete(b.loads, u.String())
+	b.lock.Unlock()
+	return nil
+}
+
+// Servers returns the URLs of the servers.
+func (b *AdaptiveBalancer) Servers() []*url.URL {
+	return b.servers.Servers()
+}
+
+func (b *AdaptiveBalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *req
+	servers := b.Servers()
+
+	var server *url.URL
+	if b.sticky != nil {
+		stuck, present, err := b.sticky.GetBackend(&newReq, servers)
+		if err != nil {
+			b.errHandler.ServeHTTP(rw, req, err)
+			return
+		}
+		if present {
+			server = stuck
+		}
+	}
+	if server == nil {
+		var err error
+		if server, err = b.nextServer(servers); err != nil {
+			b.errHandler.ServeHTTP(rw, req, err)
+			return
+		}
+		if b.sticky != nil {
+			b.sticky.StickBackend(server, &rw)
+		}
+	}
+
+	newReq.URL = server
+	b.next.ServeHTTP(&loadReportResponseWriter{ResponseWriter: rw, balancer: b, server: server.String()}, &newReq)
+}
+
+// nextServer returns the server picked by the smooth weighted round robin over the effective weights.
+func (b *AdaptiveBalancer) nextServer(servers []*url.URL) (*url.URL, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("no servers in the pool")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := b.now()
+
+	var next *url.URL
+	var nextState *adaptiveServer
+	var total float64
+	for _, server := range servers {
+		weight, _ := b.servers.ServerWeight(server)
+		if weight <= 0 {
+			continue
+		}
+		state := b.state(server.String())
+		effective := state.weight(float64(weight), now)
+		state.current += effective
+		total += effective
+		if nextState == nil || state.current > nextState.current {
+			next = server
+			nextState = state
+		}
+	}
+	if next == nil {
+		return nil, errors.New("all servers have 0 weight")
+	}
+	nextState.current -= total
+	return utils.CopyURL(next), nil
+}
+
+func (b *AdaptiveBalancer) state(server string) *adaptiveServer {
+	state, ok := b.loads[server]
+	if !ok {
+		state = &adaptiveServer{}
+		b.loads[server] = state
+	}
+	return state
+}
+
+// report records the load reported by a server, ignoring the invalid ones.
+func (b *AdaptiveBalancer) report(server string, value string) {
+	load, ok := serverLoadNames[strings.ToLower(strings.TrimSpace(value))]
+	if !ok {
+		var err error
+		load, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || load < 0 || load > 1 {
+			log.Debugf("Ignoring invalid load %q reported by server %s", value, server)
+			return
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	state := b.state(server)
+	state.load = load
+	state.reported = b.now()
+}
+
+// weight returns the effective weight of the server, reduced by its load decayed since its report.
+func (s *adaptiveServer) weight(weight float64, now time.Time) float64 {
+	if s.load == 0 {
+		return weight
+	}
+	load := s.load * math.Exp(-float64(now.Sub(s.reported))/float64(adaptiveDecay))
+	return weight * math.Max(1-load, adaptiveMinWeight)
+}
+
+// loadReportResponseWriter reports the load of the server from the response headers, and removes it from them.
+type loadReportResponseWriter struct {
+	http.ResponseWriter
+	balancer    *AdaptiveBalancer
+	server      string
+	wroteHeader bool
+}
+
+func (w *loadReportResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if value := w.Header().Get(w.balancer.header); len(value) > 0 {
+		w.balancer.report(w.server, value)
+		w.Header().Del(w.balancer.header)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *loadReportResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (w *loadReportResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *loadReportResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *loadReportResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestAdaptiveBalancerLoadedServer(t *testing.T) {
+	now := time.Now()
+	requests := make(map[string]int)
+	reporting := true
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests[req.URL.Host]++
+		if req.URL.Host == "loaded" && reporting {
+			rw.Header().Set("X-Server-Load", "high")
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	balancer := NewAdaptiveBalancer(next, nil, "")
+	balancer.now = func() time.Time { return now }
+	require.NoError(t, balancer.UpsertServer(testhelpers.MustParseURL("http://idle"), roundrobin.Weight(1)))
+	require.NoError(t, balancer.UpsertServer(testhelpers.MustParseURL("http://loaded"), roundrobin.Weight(1)))
+
+	serve := func(count int) {
+		for i := 0; i < count; i++ {
+			recorder := httptest.NewRecorder()
+			balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Empty(t, recorder.Header().Get("X-Server-Load"), "the load header must be removed from the responses")
+		}
+	}
+
+	// The first requests are balanced evenly, until the loaded server reports its load.
+	serve(2)
+	assert.Equal(t, 1, requests["loaded"])
+
+	serve(100)
+	assert.Equal(t, 102, requests["idle"]+requests["loaded"])
+	assert.True(t, requests["loaded"] < 15, "the loaded server got %d requests", requests["loaded"])
+
+	// The load decays back to the configured weights: stop reporting it, and wait.
+	reporting = false
+	now = now.Add(time.Minute)
+	requests = make(map[string]int)
+	serve(100)
+	assert.InDelta(t, 50, requests["loaded"], 2)
+}
+
+func TestAdaptiveBalancerReportedLoads(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		load           string
+		expectedWeight float64
+	}{
+		{desc: "low", load: "low", expectedWeight: 10},
+		{desc: "medium", load: "Medium", expectedWeight: 5},
+		{desc: "high", load: "high", expectedWeight: 1},
+		{desc: "number", load: "0.25", expectedWeight: 7.5},
+		{desc: "full load", load: "1", expectedWeight: 10 * adaptiveMinWeight},
+		{desc: "invalid number", load: "2", expectedWeight: 10},
+		{desc: "invalid name", load: "busy", expectedWeight: 10},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Now()
+			balancer := NewAdaptiveBalancer(http.NotFoundHandler(), nil, "X-Load")
+			balancer.now = func() time.Time { return now }
+
+			balancer.report("http://server", test.load)
+			assert.InDelta(t, test.expectedWeight, balancer.state("http://server").weight(10, now), 0.001)
+		})
+	}
+}
+
+func TestAdaptiveBalancerServers(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Host))
+	})
+	balancer := NewAdaptiveBalancer(next, nil, "")
+
+	recorder := httptest.NewRecorder()
+	balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "no servers")
+
+	server1 := testhelpers.MustParseURL("http://server1")
+	server2 := testhelpers.MustParseURL("http://server2")
+	require.NoError(t, balancer.UpsertServer(server1, roundrobin.Weight(1)))
+	require.NoError(t, balancer.UpsertServer(server2, roundrobin.Weight(1)))
+	require.NoError(t, balancer.RemoveServer(server1))
+	require.Len(t, balancer.Servers(), 1)
+
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "server2", recorder.Body.String())
+	}
+}
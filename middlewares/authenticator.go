@@ -5,36 +5,50 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/abbot/go-http-auth"
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/secret"
 	"github.com/containous/traefik/types"
 	"github.com/urfave/negroni"
 )
 
+// defaultAuthRealm is the realm of the authentication challenges when none is configured.
+const defaultAuthRealm = "traefik"
+
 // Authenticator is a middleware that provides HTTP basic and digest authentication
 type Authenticator struct {
 	handler negroni.Handler
 	users   map[string]string
+	// digestLock guards the nonces of the digest authentication, which are not synchronized when challenging.
+	digestLock sync.Mutex
 }
 
-// NewAuthenticator builds a new Autenticator given a config
-func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
+// NewAuthenticator builds a new Autenticator given a config,
+// the responses of the unauthenticated requests being overridden by rejection, if any.
+func NewAuthenticator(authConfig *types.Auth, rejection *types.RejectionResponse) (*Authenticator, error) {
 	if authConfig == nil {
 		return nil, fmt.Errorf("Error creating Authenticator: auth is nil")
 	}
 	var err error
 	authenticator := Authenticator{}
+	realm := authConfig.Realm
+	if realm == "" {
+		realm = defaultAuthRealm
+	}
 	if authConfig.Basic != nil {
 		authenticator.users, err = parserBasicUsers(authConfig.Basic)
 		if err != nil {
 			return nil, err
 		}
-		basicAuth := auth.NewBasicAuthenticator("traefik", authenticator.secretBasic)
+		basicAuth := auth.NewBasicAuthenticator(realm, authenticator.secretBasic)
 		authenticator.handler = negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 			if username := basicAuth.CheckAuth(r); username == "" {
 				log.Debug("Basic auth failed...")
-				basicAuth.RequireAuth(w, r)
+				reject(w, r, rejection, func(w http.ResponseWriter) {
+					basicAuth.RequireAuth(w, r)
+				})
 			} else {
 				log.Debug("Basic auth success...")
 				if authConfig.HeaderField != "" {
@@ -48,13 +62,22 @@ func NewAuthenticator(authConfig *types.Auth) (*Authenticator, error) {
 		if err != nil {
 			return nil, err
 		}
-		digestAuth := auth.NewDigestAuthenticator("traefik", authenticator.secretDigest)
+		digestAuth := auth.NewDigestAuthenticator(realm, authenticator.secretDigest)
 		authenticator.handler = negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-			if username, _ := digestAuth.CheckAuth(r); username == "" {
+			authenticator.digestLock.Lock()
+			username, authInfo := digestAuth.CheckAuth(r)
+			if username == "" {
+				reject(w, r, rejection, func(w http.ResponseWriter) {
+					digestAuth.RequireAuth(w, r)
+				})
+				authenticator.digestLock.Unlock()
 				log.Debug("Digest auth failed...")
-				digestAuth.RequireAuth(w, r)
 			} else {
+				authenticator.digestLock.Unlock()
 				log.Debug("Digest auth success...")
+				if authInfo != nil {
+					w.Header().Set("Authentication-Info", *authInfo)
+				}
 				if authConfig.HeaderField != "" {
 					r.Header[authConfig.HeaderField] = []string{username}
 				}
@@ -73,7 +96,10 @@ func parserBasicUsers(basic *types.Basic) (map[string]string, error) {
 			return nil, err
 		}
 	}
-	userStrs = append(basic.Users, userStrs...)
+	userStrs, err := secret.ResolveAll(append(basic.Users, userStrs...))
+	if err != nil {
+		return nil, err
+	}
 	userMap := make(map[string]string)
 	for _, user := range userStrs {
 		split := strings.Split(user, ":")
@@ -93,7 +119,10 @@ func parserDigestUsers(digest *types.Digest) (map[string]string, error) {
 			return nil, err
 		}
 	}
-	userStrs = append(digest.Users, userStrs...)
+	userStrs, err := secret.ResolveAll(append(digest.Users, userStrs...))
+	if err != nil {
+		return nil, err
+	}
 	userMap := make(map[string]string)
 	for _, user := range userStrs {
 		split := strings.Split(user, ":")
@@ -1,16 +1,20 @@
 package middlewares
 
 import (
+	"crypto/md5"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/abbot/go-http-auth"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/negroni"
 )
 
@@ -70,14 +74,14 @@ func TestBasicAuthFail(t *testing.T) {
 		Basic: &types.Basic{
 			Users: []string{"test"},
 		},
-	})
+	}, nil)
 	assert.Contains(t, err.Error(), "Error parsing Authenticator user", "should contains")
 
 	authMiddleware, err = NewAuthenticator(&types.Auth{
 		Basic: &types.Basic{
 			Users: []string{"test:test"},
 		},
-	})
+	}, nil)
 	assert.NoError(t, err, "there should be no error")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -101,7 +105,7 @@ func TestBasicAuthSuccess(t *testing.T) {
 		Basic: &types.Basic{
 			Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
 		},
-	})
+	}, nil)
 	assert.NoError(t, err, "there should be no error")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -129,14 +133,14 @@ func TestDigestAuthFail(t *testing.T) {
 		Digest: &types.Digest{
 			Users: []string{"test"},
 		},
-	})
+	}, nil)
 	assert.Contains(t, err.Error(), "Error parsing Authenticator user", "should contains")
 
 	authMiddleware, err = NewAuthenticator(&types.Auth{
 		Digest: &types.Digest{
 			Users: []string{"test:traefik:test"},
 		},
-	})
+	}, nil)
 	assert.NoError(t, err, "there should be no error")
 	assert.NotNil(t, authMiddleware, "this should not be nil")
 
@@ -156,13 +160,114 @@ func TestDigestAuthFail(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "they should be equal")
 }
 
+func TestBasicAuthRealm(t *testing.T) {
+	authMiddleware, err := NewAuthenticator(&types.Auth{
+		Basic: &types.Basic{
+			Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		},
+		Realm: "internal",
+	}, nil)
+	require.NoError(t, err)
+
+	n := negroni.New(authMiddleware)
+	n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	n.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, `Basic realm="internal"`, recorder.Header().Get("WWW-Authenticate"))
+}
+
+// digestAuthorization answers the digest challenge of a response with the MD5 and qop=auth response of the credentials.
+func digestAuthorization(t *testing.T, challenge *http.Response, method, uri, username, password string) string {
+	params := auth.ParsePairs(strings.TrimPrefix(challenge.Header.Get("WWW-Authenticate"), "Digest "))
+	require.Equal(t, "MD5", params["algorithm"])
+	require.Equal(t, "auth", params["qop"])
+
+	h := func(data string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(data)))
+	}
+	ha1 := h(username + ":" + params["realm"] + ":" + password)
+	ha2 := h(method + ":" + uri)
+	cnonce := "0a4f113b"
+	nc := "00000001"
+	response := h(strings.Join([]string{ha1, params["nonce"], nc, cnonce, "auth", ha2}, ":"))
+
+	return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=MD5, qop=auth, nc=%s, cnonce="%s", response="%s", opaque="%s"`,
+		username, params["realm"], params["nonce"], uri, nc, cnonce, response, params["opaque"])
+}
+
+func TestDigestAuthExchange(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		password       string
+		expectedStatus int
+	}{
+		{
+			desc:           "valid password",
+			password:       "test",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "wrong password",
+			password:       "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// test:internal:test and test:traefik:test, only the users of the configured realm being authenticated.
+			authMiddleware, err := NewAuthenticator(&types.Auth{
+				Digest: &types.Digest{
+					Users: []string{"test:internal:58e466dded2e92ef67765043121c8be0", "test:traefik:a2688e031edb4be6a3797f3882655c05"},
+				},
+				Realm: "internal",
+			}, nil)
+			require.NoError(t, err)
+
+			n := negroni.New(authMiddleware)
+			n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "traefik")
+			}))
+			ts := httptest.NewServer(n)
+			defer ts.Close()
+
+			challenge, err := http.Get(ts.URL + "/path")
+			require.NoError(t, err)
+			challenge.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, challenge.StatusCode)
+			assert.True(t, strings.HasPrefix(challenge.Header.Get("WWW-Authenticate"), `Digest realm="internal", `))
+
+			req := testhelpers.MustNewRequest(http.MethodGet, ts.URL+"/path", nil)
+			req.Header.Set("Authorization", digestAuthorization(t, challenge, http.MethodGet, "/path", "test", test.password))
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, res.StatusCode)
+			if test.expectedStatus == http.StatusOK {
+				assert.Contains(t, res.Header.Get("Authentication-Info"), `qop="auth"`)
+				body, err := ioutil.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "traefik\n", string(body))
+			} else {
+				assert.True(t, strings.HasPrefix(res.Header.Get("WWW-Authenticate"), `Digest realm="internal", `))
+			}
+		})
+	}
+}
+
 func TestBasicAuthUserHeader(t *testing.T) {
 	authMiddleware, err := NewAuthenticator(&types.Auth{
 		Basic: &types.Basic{
 			Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
 		},
 		HeaderField: "X-WebAuth-User",
-	})
+	}, nil)
 	assert.NoError(t, err, "there should be no error")
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -186,3 +291,60 @@ func TestBasicAuthUserHeader(t *testing.T) {
 	assert.NoError(t, err, "there should be no error")
 	assert.Equal(t, "traefik\n", string(body), "they should be equal")
 }
+
+func TestBasicAuthRejection(t *testing.T) {
+	testCases := []struct {
+		desc                   string
+		rejection              *types.RejectionResponse
+		expectedStatus         int
+		expectedBody           string
+		expectedAuthentication bool
+	}{
+		{
+			desc:                   "default",
+			expectedStatus:         http.StatusUnauthorized,
+			expectedBody:           "401 Unauthorized\n",
+			expectedAuthentication: true,
+		},
+		{
+			desc:                   "custom body",
+			rejection:              &types.RejectionResponse{Body: "login required"},
+			expectedStatus:         http.StatusUnauthorized,
+			expectedBody:           "login required",
+			expectedAuthentication: true,
+		},
+		{
+			desc:           "custom status code",
+			rejection:      &types.RejectionResponse{StatusCode: http.StatusNotFound},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Not Found",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			authMiddleware, err := NewAuthenticator(&types.Auth{
+				Basic: &types.Basic{
+					Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+				},
+			}, test.rejection)
+			require.NoError(t, err)
+			n := negroni.New(authMiddleware)
+			n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "traefik")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			req.SetBasicAuth("test", "wrong")
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+			assert.Equal(t, test.expectedAuthentication, recorder.Header().Get("WWW-Authenticate") != "")
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/containous/traefik/types"
+)
+
+// DefaultAWSSigningMaxBodyBytes is the default size of the largest body buffered to be hashed.
+const DefaultAWSSigningMaxBodyBytes = 10 * 1024 * 1024
+
+// awsSigningHeaders are the headers of the signature, which are replaced in the signed requests.
+var awsSigningHeaders = []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token", "X-Amz-Content-Sha256"}
+
+// AWSSigningRoundTripper is a RoundTripper signing the requests with the AWS Signature Version 4,
+// for the backends of AWS services. The requests are sent with the host of the backend server,
+// which is part of the signature.
+type AWSSigningRoundTripper struct {
+	next            http.RoundTripper
+	signer          *v4.Signer
+	service         string
+	region          string
+	unsignedPayload bool
+	maxBodyBytes    int64
+	now             func() time.Time
+}
+
+// NewAWSSigningRoundTripper creates an AWSSigningRoundTripper sending the requests with the given RoundTripper,
+// signed with the configured credentials, or the ones of the AWS credential chain.
+func NewAWSSigningRoundTripper(next http.RoundTripper, config *types.AWSSigning) (*AWSSigningRoundTripper, error) {
+	if len(config.Service) == 0 {
+		return nil, errors.New("no AWS service to sign the requests for")
+	}
+	if len(config.Region) == 0 {
+		return nil, errors.New("no AWS region to sign the requests for")
+	}
+	if config.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("maximum body size of the signed requests must not be negative, got %d", config.MaxBodyBytes)
+	}
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = DefaultAWSSigningMaxBodyBytes
+	}
+
+	creds := credentials.NewChainCredentials(
+		[]credentials.Provider{
+			&credentials.StaticProvider{
+				Value: credentials.Value{
+					AccessKeyID:     config.AccessKeyID,
+					SecretAccessKey: config.SecretAccessKey,
+				},
+			},
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{},
+			defaults.RemoteCredProvider(*(defaults.Config()), defaults.Handlers()),
+		})
+	signer := v4.NewSigner(creds, func(signer *v4.Signer) {
+		// The body is attached to the request before signing it.
+		signer.DisableRequestBodyOverwrite = true
+	})
+
+	return &AWSSigningRoundTripper{
+		next:            next,
+		signer:          signer,
+		service:         config.Service,
+		region:          config.Region,
+		unsignedPayload: config.UnsignedPayload,
+		maxBodyBytes:    maxBodyBytes,
+		now:             time.Now,
+	}, nil
+}
+
+// RoundTrip signs a copy of the request, buffering its body to hash it unless the payload is unsigned, and sends it.
+// The bodies larger than the maximum body size are streamed with an unsigned payload.
+func (s *AWSSigningRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = cloneHeader(req.Header)
+	outReq.Host = ""
+	for _, header := range awsSigningHeaders {
+		outReq.Header.Del(header)
+	}
+
+	var body io.ReadSeeker
+	unsignedPayload := s.unsignedPayload || req.ContentLength > s.maxBodyBytes
+	if !unsignedPayload && req.Body != nil && req.Body != http.NoBody {
+		payload, err := ioutil.ReadAll(io.LimitReader(req.Body, s.maxBodyBytes+1))
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		if int64(len(payload)) > s.maxBodyBytes {
+			unsignedPayload = true
+			outReq.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(payload), req.Body), Closer: req.Body}
+		} else {
+			req.Body.Close()
+			body = bytes.NewReader(payload)
+			outReq.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		}
+	}
+	if unsignedPayload {
+		outReq.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	}
+
+	if _, err := s.signer.Sign(outReq, body, s.service, s.region, s.now()); err != nil {
+		return nil, err
+	}
+	return s.next.RoundTrip(outReq)
+}
+
+// cloneHeader returns a copy of the header, whose values can be modified without altering the original ones.
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
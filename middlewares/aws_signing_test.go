@@ -0,0 +1,187 @@
+package middlewares
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSSigningRoundTripper(t *testing.T) {
+	// The requests and signatures of the AWS Signature Version 4 test suite.
+	testCases := []struct {
+		desc                  string
+		method                string
+		url                   string
+		host                  string
+		header                map[string]string
+		body                  string
+		unsignedPayload       bool
+		expectedAuthorization string
+		expectedContentSha256 string
+	}{
+		{
+			desc:                  "query parameters",
+			method:                http.MethodGet,
+			url:                   "http://example.amazonaws.com/?Param2=value2&Param1=value1",
+			expectedAuthorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			desc:                  "forwarded host and previous signature replaced",
+			method:                http.MethodGet,
+			url:                   "http://example.amazonaws.com/?Param2=value2&Param1=value1",
+			host:                  "traefik.example.com",
+			header:                map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "X-Amz-Date": "20000101T000000Z"},
+			expectedAuthorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			desc:                  "signed body",
+			method:                http.MethodPost,
+			url:                   "http://example.amazonaws.com/",
+			header:                map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:                  "Param1=value1",
+			expectedAuthorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			desc:                  "unsigned payload",
+			method:                http.MethodPut,
+			url:                   "http://example.amazonaws.com/bucket/key",
+			body:                  "content",
+			unsignedPayload:       true,
+			expectedAuthorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=f2b3544e00e60083ed0384beb6d2a491d16b53dfc9391586d06444d59e433873",
+			expectedContentSha256: "UNSIGNED-PAYLOAD",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var sentReq *http.Request
+			var sentBody string
+			roundTripper, err := NewAWSSigningRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				sentReq = req
+				if req.Body != nil {
+					body, err := ioutil.ReadAll(req.Body)
+					require.NoError(t, err)
+					sentBody = string(body)
+				}
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}), &types.AWSSigning{
+				Service:         "service",
+				Region:          "us-east-1",
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				UnsignedPayload: test.unsignedPayload,
+			})
+			require.NoError(t, err)
+			roundTripper.now = func() time.Time {
+				return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+			}
+
+			var body io.Reader
+			if len(test.body) > 0 {
+				body = strings.NewReader(test.body)
+			}
+			req := testhelpers.MustNewRequest(test.method, test.url, body)
+			if len(test.host) > 0 {
+				req.Host = test.host
+			}
+			for name, value := range test.header {
+				req.Header.Set(name, value)
+			}
+
+			_, err = roundTripper.RoundTrip(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedAuthorization, sentReq.Header.Get("Authorization"))
+			assert.Equal(t, "20150830T123600Z", sentReq.Header.Get("X-Amz-Date"))
+			assert.Equal(t, test.expectedContentSha256, sentReq.Header.Get("X-Amz-Content-Sha256"))
+			assert.Empty(t, sentReq.Host, "the request must be sent with the signed host")
+			assert.Equal(t, test.body, sentBody)
+		})
+	}
+}
+
+func TestAWSSigningRoundTripperLargeBody(t *testing.T) {
+	testCases := []struct {
+		desc                  string
+		body                  string
+		contentLength         int64
+		expectedContentSha256 string
+	}{
+		{
+			desc:                  "buffered body",
+			body:                  "content",
+			contentLength:         -1,
+			expectedContentSha256: "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73",
+		},
+		{
+			desc:                  "larger body of unknown length",
+			body:                  "larger content",
+			contentLength:         -1,
+			expectedContentSha256: "UNSIGNED-PAYLOAD",
+		},
+		{
+			desc:                  "larger content length",
+			body:                  "larger content",
+			contentLength:         14,
+			expectedContentSha256: "UNSIGNED-PAYLOAD",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var sentReq *http.Request
+			var sentBody []byte
+			roundTripper, err := NewAWSSigningRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				sentReq = req
+				var err error
+				sentBody, err = ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				return &http.Response{StatusCode: http.StatusOK}, nil
+			}), &types.AWSSigning{
+				Service:         "s3",
+				Region:          "us-east-1",
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				MaxBodyBytes:    int64(len("content")),
+			})
+			require.NoError(t, err)
+
+			req := testhelpers.MustNewRequest(http.MethodPut, "http://example.amazonaws.com/bucket/key", ioutil.NopCloser(strings.NewReader(test.body)))
+			req.ContentLength = test.contentLength
+			req.Header.Set("X-Custom", "value")
+
+			_, err = roundTripper.RoundTrip(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedContentSha256, sentReq.Header.Get("X-Amz-Content-Sha256"))
+			assert.Equal(t, test.body, string(sentBody), "the whole body must be sent")
+			assert.Empty(t, req.Header.Get("Authorization"), "the original request must be left untouched")
+			assert.Equal(t, "value", sentReq.Header.Get("X-Custom"))
+		})
+	}
+}
+
+func TestNewAWSSigningRoundTripperMissingConfig(t *testing.T) {
+	_, err := NewAWSSigningRoundTripper(http.DefaultTransport, &types.AWSSigning{Region: "us-east-1"})
+	assert.Error(t, err)
+
+	_, err = NewAWSSigningRoundTripper(http.DefaultTransport, &types.AWSSigning{Service: "execute-api"})
+	assert.Error(t, err)
+
+	_, err = NewAWSSigningRoundTripper(http.DefaultTransport, &types.AWSSigning{Service: "execute-api", Region: "us-east-1", MaxBodyBytes: -1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// errBodyTooLarge is the error of the reads of a request body over the limit of its BodyLimit.
+var errBodyTooLarge = errors.New("http: request body too large")
+
+type bodyLimitKey struct{}
+
+// BodyLimit is a middleware limiting the size of the request bodies, without buffering them:
+// the requests with a Content-Length over the limit are rejected at once with 413 Request Entity Too Large,
+// and the read of the other bodies, e.g. chunked ones, fails once they exceed it, which is recorded with the request,
+// see BodyLimitExceeded.
+type BodyLimit struct {
+	maxBytes int64
+}
+
+// NewBodyLimit creates a new BodyLimit for the given maximum size of the bodies, in bytes.
+func NewBodyLimit(maxBytes int64) *BodyLimit {
+	return &BodyLimit{maxBytes: maxBytes}
+}
+
+func (b *BodyLimit) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.ContentLength > b.maxBytes {
+		WriteRequestTooLarge(rw, r, b.maxBytes)
+		return
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		body := &limitedBody{ReadCloser: r.Body, maxBytes: b.maxBytes, remaining: b.maxBytes}
+		body.outer, _ = r.Context().Value(bodyLimitKey{}).(*limitedBody)
+		r = r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, body))
+		r.Body = body
+	}
+	next(rw, r)
+}
+
+// BodyLimitExceeded returns the limit of the body of the request exceeded by its read, if any, the limits of the
+// entrypoint and of the frontend of the request being both checked.
+func BodyLimitExceeded(r *http.Request) (int64, bool) {
+	body, _ := r.Context().Value(bodyLimitKey{}).(*limitedBody)
+	for ; body != nil; body = body.outer {
+		if atomic.LoadInt32(&body.exceeded) != 0 {
+			return body.maxBytes, true
+		}
+	}
+	return 0, false
+}
+
+// limitedBody is a request body failing the reads once its limit is exceeded, the body being read by the forwarder
+// while its error is handled by the request.
+type limitedBody struct {
+	io.ReadCloser
+	maxBytes  int64
+	remaining int64
+	exceeded  int32
+	// outer is the limited body read by this one, if any, e.g. the one of the entrypoint for the one of a frontend.
+	outer *limitedBody
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&l.exceeded) != 0 {
+		return 0, errBodyTooLarge
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// One more byte than remaining is read, to know whether the body exceeds the limit.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	if int64(n) <= l.remaining {
+		l.remaining -= int64(n)
+		return n, err
+	}
+	n = int(l.remaining)
+	l.remaining = 0
+	atomic.StoreInt32(&l.exceeded, 1)
+	return n, errBodyTooLarge
+}
+
+// WriteRequestTooLarge writes the response to a request with a body over the given maximum size.
+func WriteRequestTooLarge(rw http.ResponseWriter, r *http.Request, maxBytes int64) {
+	if ProblemDetailsEnabled(r) {
+		WriteProblem(rw, http.StatusRequestEntityTooLarge, ProblemTypeRequestTooLarge, fmt.Sprintf("The request body exceeds %d bytes", maxBytes))
+		return
+	}
+	rw.WriteHeader(http.StatusRequestEntityTooLarge)
+	rw.Write([]byte(http.StatusText(http.StatusRequestEntityTooLarge)))
+}
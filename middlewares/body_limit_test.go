@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		body           string
+		chunked        bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			desc:           "content length under the limit",
+			body:           "0123456789",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "0123456789",
+		},
+		{
+			desc:           "content length over the limit",
+			body:           "0123456789a",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "chunked under the limit",
+			body:           "0123456789",
+			chunked:        true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "0123456789",
+		},
+		{
+			desc:           "chunked over the limit",
+			body:           "0123456789a",
+			chunked:        true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost", strings.NewReader(test.body))
+			if test.chunked {
+				req.ContentLength = -1
+			}
+			next := func(rw http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				if maxBytes, ok := BodyLimitExceeded(r); ok {
+					assert.Error(t, err)
+					WriteRequestTooLarge(rw, r, maxBytes)
+					return
+				}
+				require.NoError(t, err)
+				rw.Write(body)
+			}
+
+			recorder := httptest.NewRecorder()
+			NewBodyLimit(10).ServeHTTP(recorder, req, next)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestBodyLimitNested(t *testing.T) {
+	req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost", strings.NewReader("0123456789a"))
+	req.ContentLength = -1
+
+	var maxBytes int64
+	var exceeded bool
+	inner := func(rw http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		assert.Error(t, err)
+		maxBytes, exceeded = BodyLimitExceeded(r)
+	}
+	outer := func(rw http.ResponseWriter, r *http.Request) {
+		NewBodyLimit(20).ServeHTTP(rw, r, inner)
+	}
+	NewBodyLimit(10).ServeHTTP(httptest.NewRecorder(), req, outer)
+
+	assert.True(t, exceeded, "the outer limit should be reported")
+	assert.EqualValues(t, 10, maxBytes)
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/containous/traefik/log"
+	"github.com/urfave/negroni"
+)
+
+// Bypass is a middleware skipping the handler for the requests whose path matches one of its patterns,
+// with the syntax of path.Match, forwarding them directly to the next handler of the chain.
+// The requests with a path that is not clean, such as "/metrics/../admin", never skip the handler.
+type Bypass struct {
+	handler  negroni.Handler
+	name     string
+	patterns []string
+}
+
+// NewBypass creates a Bypass of the named handler for the path patterns, or returns the handler when there are none.
+// The malformed patterns never match, see ValidateBypassPattern.
+func NewBypass(handler negroni.Handler, name string, patterns []string) negroni.Handler {
+	if len(patterns) == 0 {
+		return handler
+	}
+	return &Bypass{handler: handler, name: name, patterns: patterns}
+}
+
+// ValidateBypassPattern returns an error when the path pattern is malformed.
+func ValidateBypassPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid bypass path pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+func (b *Bypass) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if b.matches(r.URL.Path) {
+		log.Debugf("Bypassing %s for %s", b.name, r.URL.Path)
+		next(rw, r)
+		return
+	}
+	b.handler.ServeHTTP(rw, r, next)
+}
+
+func (b *Bypass) matches(requestPath string) bool {
+	if cleaned := path.Clean(requestPath); cleaned != requestPath && cleaned+"/" != requestPath {
+		return false
+	}
+	for _, pattern := range b.patterns {
+		if ok, _ := path.Match(pattern, requestPath); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/negroni"
+)
+
+func TestBypass(t *testing.T) {
+	rejecter := negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	})
+	bypass := NewBypass(rejecter, "auth", []string{"/metrics", "/health/*"})
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/metrics", expectedStatus: http.StatusOK},
+		{path: "/health/live", expectedStatus: http.StatusOK},
+		{path: "/health/", expectedStatus: http.StatusOK},
+		{path: "/health/live/admin", expectedStatus: http.StatusUnauthorized},
+		{path: "/metrics/../admin", expectedStatus: http.StatusUnauthorized},
+		{path: "/health/..", expectedStatus: http.StatusUnauthorized},
+		{path: "/admin", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			n := negroni.New(bypass)
+			n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path = test.path
+			n.ServeHTTP(recorder, req)
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestNewBypassWithoutPatterns(t *testing.T) {
+	handler := negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {})
+	assert.IsType(t, handler, NewBypass(handler, "auth", nil), "the handler must be kept without patterns")
+}
+
+func TestValidateBypassPattern(t *testing.T) {
+	assert.NoError(t, ValidateBypassPattern("/health/*"))
+	assert.Error(t, ValidateBypassPattern("/metrics["))
+}
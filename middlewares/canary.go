@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/containous/traefik/types"
+)
+
+// Canary routes the requests either to a stable handler or to a canary one. The requests carrying one of its
+// headers or cookies are always routed to the canary, which overrides the percentage of the other requests
+// routed to it.
+type Canary struct {
+	stable  http.Handler
+	canary  http.Handler
+	percent int
+	headers map[string]string
+	cookies map[string]string
+}
+
+// NewCanary builds a new Canary routing the requests to stable or canary as configured.
+func NewCanary(stable, canary http.Handler, config *types.Canary) *Canary {
+	return &Canary{
+		stable:  stable,
+		canary:  canary,
+		percent: config.Percent,
+		headers: config.Headers,
+		cookies: config.Cookies,
+	}
+}
+
+func (c *Canary) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if c.isForced(req) || (c.percent > 0 && rand.Intn(100) < c.percent) {
+		c.canary.ServeHTTP(rw, req)
+		return
+	}
+	c.stable.ServeHTTP(rw, req)
+}
+
+// isForced returns whether the request carries one of the headers or cookies of the canary,
+// with the configured value or any value if it is empty.
+func (c *Canary) isForced(req *http.Request) bool {
+	for name, value := range c.headers {
+		if values, ok := req.Header[http.CanonicalHeaderKey(name)]; ok {
+			for _, v := range values {
+				if len(value) == 0 || v == value {
+					return true
+				}
+			}
+		}
+	}
+	for name, value := range c.cookies {
+		if cookie, err := req.Cookie(name); err == nil && (len(value) == 0 || cookie.Value == value) {
+			return true
+		}
+	}
+	return false
+}
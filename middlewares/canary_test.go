@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanary(t *testing.T) {
+	newHandler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Handler", name)
+		})
+	}
+
+	testCases := []struct {
+		desc            string
+		config          *types.Canary
+		request         func(req *http.Request)
+		expectedHandler string
+	}{
+		{
+			desc:            "stable by default",
+			config:          &types.Canary{Headers: map[string]string{"X-Canary": "true"}},
+			expectedHandler: "stable",
+		},
+		{
+			desc:   "header value",
+			config: &types.Canary{Headers: map[string]string{"X-Canary": "true"}},
+			request: func(req *http.Request) {
+				req.Header.Set("X-Canary", "true")
+			},
+			expectedHandler: "canary",
+		},
+		{
+			desc:   "header with another value",
+			config: &types.Canary{Headers: map[string]string{"X-Canary": "true"}},
+			request: func(req *http.Request) {
+				req.Header.Set("X-Canary", "no")
+			},
+			expectedHandler: "stable",
+		},
+		{
+			desc:   "header with any value",
+			config: &types.Canary{Headers: map[string]string{"x-canary": ""}},
+			request: func(req *http.Request) {
+				req.Header.Set("X-Canary", "no")
+			},
+			expectedHandler: "canary",
+		},
+		{
+			desc:   "cookie value",
+			config: &types.Canary{Cookies: map[string]string{"release": "beta"}},
+			request: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "release", Value: "beta"})
+			},
+			expectedHandler: "canary",
+		},
+		{
+			desc:            "all requests",
+			config:          &types.Canary{Percent: 100},
+			expectedHandler: "canary",
+		},
+		{
+			desc:   "forced over the percentage",
+			config: &types.Canary{Percent: 0, Headers: map[string]string{"X-Canary": ""}},
+			request: func(req *http.Request) {
+				req.Header.Set("X-Canary", "1")
+			},
+			expectedHandler: "canary",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			canary := NewCanary(newHandler("stable"), newHandler("canary"), test.config)
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+			if test.request != nil {
+				test.request(req)
+			}
+			recorder := httptest.NewRecorder()
+			canary.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedHandler, recorder.Header().Get("X-Handler"))
+		})
+	}
+}
+
+func TestCanaryPercent(t *testing.T) {
+	canaryRequests := 0
+	canary := NewCanary(http.NotFoundHandler(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		canaryRequests++
+	}), &types.Canary{Percent: 20})
+
+	for i := 0; i < 1000; i++ {
+		canary.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	}
+	assert.InDelta(t, 200, canaryRequests, 80)
+}
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"net/http"
 
+	"github.com/go-kit/kit/metrics"
 	"github.com/vulcand/oxy/cbreaker"
 )
 
@@ -13,6 +14,7 @@ type CircuitBreaker struct {
 
 // NewCircuitBreaker returns a new CircuitBreaker.
 func NewCircuitBreaker(next http.Handler, expression string, options ...cbreaker.CircuitBreakerOption) (*CircuitBreaker, error) {
+	options = append([]cbreaker.CircuitBreakerOption{cbreaker.Fallback(http.HandlerFunc(circuitOpen))}, options...)
 	circuitBreaker, err := cbreaker.New(next, expression, options...)
 	if err != nil {
 		return nil, err
@@ -20,6 +22,35 @@ func NewCircuitBreaker(next http.Handler, expression string, options ...cbreaker
 	return &CircuitBreaker{circuitBreaker}, nil
 }
 
+// CircuitBreakerTransitions returns the options of a circuit breaker counting its transitions with the counter,
+// with the state label set to open when it trips, and to closed when it is back to standby.
+func CircuitBreakerTransitions(counter metrics.Counter) []cbreaker.CircuitBreakerOption {
+	return []cbreaker.CircuitBreakerOption{
+		cbreaker.OnTripped(counterSideEffect{counter.With("state", "open")}),
+		cbreaker.OnStandby(counterSideEffect{counter.With("state", "closed")}),
+	}
+}
+
+// counterSideEffect is a side effect of a circuit breaker transition incrementing a counter.
+type counterSideEffect struct {
+	counter metrics.Counter
+}
+
+func (c counterSideEffect) Exec() error {
+	c.counter.Add(1)
+	return nil
+}
+
+// circuitOpen answers the requests while the circuit breaker is tripped, like the default fallback of oxy.
+func circuitOpen(rw http.ResponseWriter, req *http.Request) {
+	if ProblemDetailsEnabled(req) {
+		WriteProblem(rw, http.StatusServiceUnavailable, ProblemTypeCircuitOpen, "The circuit breaker of the backend is open")
+		return
+	}
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	rw.Write([]byte(http.StatusText(http.StatusServiceUnavailable)))
+}
+
 func (cb *CircuitBreaker) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	cb.circuitBreaker.ServeHTTP(rw, r)
 }
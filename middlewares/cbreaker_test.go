@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/cbreaker"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	counter, collector, err := NewPrometheusCircuitBreakerTransitionsCounter()
+	require.NoError(t, err)
+	transitions := func(state string) float64 {
+		metric := &dto.Metric{}
+		require.NoError(t, collector.(*prometheus.CounterVec).WithLabelValues("cbreaker-test", state).Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+	waitTransitions := func(state string, expected float64) {
+		// The side effects of the transitions are executed asynchronously.
+		deadline := time.Now().Add(5 * time.Second)
+		for transitions(state) < expected && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, expected, transitions(state), "%s transitions", state)
+	}
+
+	var failing int32 = 1
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+	options := append(CircuitBreakerTransitions(counter.With("backend", "cbreaker-test")),
+		cbreaker.FallbackDuration(50*time.Millisecond), cbreaker.RecoveryDuration(50*time.Millisecond), cbreaker.CheckPeriod(time.Millisecond))
+	circuitBreaker, err := NewCircuitBreaker(next, "ResponseCodeRatio(500, 600, 0, 600) > 0.5", options...)
+	require.NoError(t, err)
+	serve := func() int {
+		recorder := httptest.NewRecorder()
+		circuitBreaker.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil), nil)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, serve())
+	waitTransitions("open", 1)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(), "the circuit breaker should be open")
+	assert.Zero(t, transitions("closed"))
+
+	atomic.StoreInt32(&failing, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for transitions("closed") == 0 && time.Now().Before(deadline) {
+		serve()
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitTransitions("closed", 1)
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, float64(1), transitions("open"))
+}
@@ -2,24 +2,124 @@ package middlewares
 
 import (
 	"compress/gzip"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/containous/traefik/log"
 )
 
-// Compress is a middleware that allows redirection
-type Compress struct{}
+// Compress is a middleware compressing the responses with gzip.
+// Its zero value compresses all the responses at the default compression level.
+type Compress struct {
+	level int
+	types []string
+}
+
+// NewCompress creates a new Compress with a gzip compression level, from 1 (best speed) to 9 (best compression),
+// 0 meaning the default level, and the content types allowed to be compressed, all of them being when empty.
+// A type of the allow-list can end with "/*" to allow all its subtypes, e.g. "text/*".
+func NewCompress(level int, types []string) (*Compress, error) {
+	if level != 0 && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+		return nil, fmt.Errorf("invalid compression level %d, it must be between %d and %d", level, gzip.BestSpeed, gzip.BestCompression)
+	}
+	var allowed []string
+	for _, contentType := range types {
+		allowed = append(allowed, strings.ToLower(strings.TrimSpace(contentType)))
+	}
+	return &Compress{level: level, types: allowed}, nil
+}
 
 // ServerHTTP is a function used by Negroni
 func (c *Compress) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	gzipHandler(next).ServeHTTP(rw, r)
+	c.gzipHandler(next).ServeHTTP(rw, r)
 }
 
-func gzipHandler(h http.Handler) http.Handler {
-	wrapper, err := gziphandler.NewGzipHandler(gzip.DefaultCompression, gziphandler.DefaultMinSize, &gziphandler.GzipResponseWriterWrapper{})
+func (c *Compress) gzipHandler(h http.Handler) http.Handler {
+	level := c.level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var gw gziphandler.GzipWriter = &gziphandler.GzipResponseWriterWrapper{}
+	if len(c.types) > 0 {
+		gw = &contentTypeGzipWriter{types: c.types}
+	}
+	wrapper, err := gziphandler.NewGzipHandler(level, gziphandler.DefaultMinSize, gw)
 	if err != nil {
 		log.Error(err)
+		return h
 	}
 	return wrapper(h)
 }
+
+// contentTypeGzipWriter compresses the responses whose content type is in the allow-list,
+// the other ones being written as is.
+// The decision is taken on the first write, once the content type is known.
+type contentTypeGzipWriter struct {
+	gziphandler.GzipResponseWriterWrapper
+	types       []string
+	code        int
+	decided     bool
+	passThrough bool
+}
+
+func (w *contentTypeGzipWriter) WriteHeader(code int) {
+	if w.passThrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+}
+
+func (w *contentTypeGzipWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		contentType := w.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(b)
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.passThrough = !w.allowed(contentType)
+		w.flushCode()
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.GzipResponseWriterWrapper.Write(b)
+}
+
+func (w *contentTypeGzipWriter) Close() error {
+	w.flushCode()
+	if w.passThrough {
+		return nil
+	}
+	return w.GzipResponseWriterWrapper.Close()
+}
+
+// flushCode forwards the status code saved until the decision to compress the response or not.
+func (w *contentTypeGzipWriter) flushCode() {
+	if w.code == 0 {
+		return
+	}
+	if w.passThrough {
+		w.ResponseWriter.WriteHeader(w.code)
+	} else {
+		w.GzipResponseWriterWrapper.WriteHeader(w.code)
+	}
+	w.code = 0
+}
+
+func (w *contentTypeGzipWriter) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range w.types {
+		if allowed == mediaType || strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,18 @@
 package middlewares
 
 import (
+	"compress/gzip"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/negroni"
 )
 
@@ -147,3 +151,117 @@ func generateBytes(len int) []byte {
 	}
 	return value
 }
+
+func TestShouldCompressMoreWithHigherLevel(t *testing.T) {
+	words := strings.Fields("traefik compresses the responses of its backends depending on their content type")
+	random := rand.New(rand.NewSource(42))
+	var fakeBody []byte
+	for i := 0; i < 20000; i++ {
+		fakeBody = append(fakeBody, words[random.Intn(len(words))]...)
+		fakeBody = append(fakeBody, ' ')
+	}
+
+	compressedSize := func(level int) int {
+		comp, err := NewCompress(level, nil)
+		require.NoError(t, err)
+
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
+		req.Header.Add(acceptEncodingHeader, gzipValue)
+
+		rw := httptest.NewRecorder()
+		comp.ServeHTTP(rw, req, func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write(fakeBody)
+		})
+
+		require.Equal(t, gzipValue, rw.Header().Get(contentEncodingHeader))
+		size := rw.Body.Len()
+		reader, err := gzip.NewReader(rw.Body)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, fakeBody, body)
+		return size
+	}
+
+	assert.True(t, compressedSize(gzip.BestCompression) < compressedSize(gzip.BestSpeed), "the best compression level should produce a smaller output than the best speed one")
+}
+
+func TestNewCompressInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, 10} {
+		_, err := NewCompress(level, nil)
+		assert.Error(t, err, "level %d", level)
+	}
+}
+
+func TestShouldCompressOnlyAllowedContentTypes(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		contentType      string
+		statusCode       int
+		expectedEncoding string
+	}{
+		{
+			desc:             "allowed type",
+			contentType:      "text/html; charset=utf-8",
+			expectedEncoding: gzipValue,
+		},
+		{
+			desc:             "allowed subtype",
+			contentType:      "application/json",
+			expectedEncoding: gzipValue,
+		},
+		{
+			desc:        "not allowed type",
+			contentType: "image/png",
+		},
+		{
+			desc:        "not allowed type with a status code",
+			contentType: "image/png",
+			statusCode:  http.StatusCreated,
+		},
+		{
+			desc:        "not allowed detected type",
+			contentType: "",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			comp, err := NewCompress(0, []string{"text/*", "Application/JSON"})
+			require.NoError(t, err)
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
+			req.Header.Add(acceptEncodingHeader, gzipValue)
+
+			fakeBody := generateBytes(gziphandler.DefaultMinSize)
+			if test.contentType == "" {
+				// Detected as application/octet-stream.
+				fakeBody[0] = 0
+			}
+			rw := httptest.NewRecorder()
+			comp.ServeHTTP(rw, req, func(rw http.ResponseWriter, r *http.Request) {
+				if test.contentType != "" {
+					rw.Header().Set("Content-Type", test.contentType)
+				}
+				if test.statusCode != 0 {
+					rw.WriteHeader(test.statusCode)
+				}
+				rw.Write(fakeBody)
+			})
+
+			expectedStatusCode := test.statusCode
+			if expectedStatusCode == 0 {
+				expectedStatusCode = http.StatusOK
+			}
+			assert.Equal(t, expectedStatusCode, rw.Code)
+			assert.Equal(t, test.expectedEncoding, rw.Header().Get(contentEncodingHeader))
+			if test.expectedEncoding == "" {
+				assert.Equal(t, fakeBody, rw.Body.Bytes())
+			} else if assert.ObjectsAreEqualValues(rw.Body.Bytes(), fakeBody) {
+				assert.Fail(t, "expected a compressed body", "got %v", rw.Body.Bytes())
+			}
+		})
+	}
+}
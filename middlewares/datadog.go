@@ -22,18 +22,23 @@ var datadogTicker *time.Ticker
 
 // Metric names consistent with https://github.com/DataDog/integrations-extras/pull/64
 const (
-	ddMetricsReqsName    = "requests.total"
-	ddMetricsLatencyName = "request.duration"
+	ddMetricsReqsName     = "requests.total"
+	ddMetricsLatencyName  = "request.duration"
+	ddMetricsReqSizeName  = "request.size"
+	ddMetricsRespSizeName = "response.size"
 )
 
 // Datadog is an Implementation for Metrics that exposes datadog metrics for the latency
 // and the number of requests partitioned by status code and method.
 // - number of requests partitioned by status code and method
 // - request durations
+// - request and response body sizes
 // - amount of retries happened
 type Datadog struct {
 	reqsCounter          metrics.Counter
 	reqDurationHistogram metrics.Histogram
+	reqSizeHistogram     metrics.Histogram
+	respSizeHistogram    metrics.Histogram
 	retryCounter         metrics.Counter
 }
 
@@ -45,6 +50,14 @@ func (dd *Datadog) getReqDurationHistogram() metrics.Histogram {
 	return dd.reqDurationHistogram
 }
 
+func (dd *Datadog) getReqSizeHistogram() metrics.Histogram {
+	return dd.reqSizeHistogram
+}
+
+func (dd *Datadog) getRespSizeHistogram() metrics.Histogram {
+	return dd.respSizeHistogram
+}
+
 func (dd *Datadog) getRetryCounter() metrics.Counter {
 	return dd.retryCounter
 }
@@ -55,6 +68,8 @@ func NewDataDog(name string) *Datadog {
 
 	m.reqsCounter = datadogClient.NewCounter(ddMetricsReqsName, 1.0).With("service", name)
 	m.reqDurationHistogram = datadogClient.NewHistogram(ddMetricsLatencyName, 1.0).With("service", name)
+	m.reqSizeHistogram = datadogClient.NewHistogram(ddMetricsReqSizeName, 1.0).With("service", name)
+	m.respSizeHistogram = datadogClient.NewHistogram(ddMetricsRespSizeName, 1.0).With("service", name)
 
 	return &m
 }
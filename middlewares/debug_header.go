@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DebugHeader is the response header reporting the rule matched by a request, the server selected for it,
+// and the time taken to select it since the request was routed.
+const DebugHeader = "X-Traefik-Debug"
+
+type debugHeaderKey struct{}
+
+// debugInfo is the routing information of a request reported in its debug header.
+type debugInfo struct {
+	rule  string
+	start time.Time
+}
+
+// RoutingDebug is a middleware enabling the debug header on the responses to the requests matching a rule,
+// the header being set by the ServerDebug of the server they are forwarded to.
+type RoutingDebug struct {
+	rule string
+}
+
+// NewRoutingDebug creates a new RoutingDebug for the rule of a frontend.
+func NewRoutingDebug(rule string) *RoutingDebug {
+	return &RoutingDebug{rule: rule}
+}
+
+func (d *RoutingDebug) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	info := &debugInfo{rule: d.rule, start: time.Now()}
+	next(rw, r.WithContext(context.WithValue(r.Context(), debugHeaderKey{}, info)))
+}
+
+// ServerDebug is a handler setting the debug header, when enabled for the request, before forwarding it
+// to the server selected by the load balancer.
+type ServerDebug struct {
+	next http.Handler
+}
+
+// NewServerDebug creates a new ServerDebug forwarding to the next handler.
+func NewServerDebug(next http.Handler) *ServerDebug {
+	return &ServerDebug{next: next}
+}
+
+func (d *ServerDebug) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if info, ok := r.Context().Value(debugHeaderKey{}).(*debugInfo); ok {
+		server := r.URL.Scheme + "://" + r.URL.Host
+		rw.Header().Set(DebugHeader, fmt.Sprintf("rule=%q; server=%q; selection=%s", info.rule, server, time.Since(info.start)))
+	}
+	d.next.ServeHTTP(rw, r)
+}
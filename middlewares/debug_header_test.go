@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerDebug(t *testing.T) {
+	server := NewServerDebug(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://10.0.0.1:8080/api", nil))
+	assert.Empty(t, recorder.Header().Get(DebugHeader), "the header should be disabled by default")
+
+	recorder = httptest.NewRecorder()
+	NewRoutingDebug("Host:foo.bar;PathPrefix:/api").ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://10.0.0.1:8080/api", nil), server.ServeHTTP)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Regexp(t, regexp.MustCompile(`^rule="Host:foo.bar;PathPrefix:/api"; server="http://10.0.0.1:8080"; selection=\S+s$`), recorder.Header().Get(DebugHeader))
+}
@@ -23,6 +23,10 @@ func NewEmptyBackendHandler(lb healthcheck.LoadBalancer, next http.Handler) *Emp
 // invokes the next handler in the middleware chain.
 func (h *EmptyBackendHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	if len(h.lb.Servers()) == 0 {
+		if ProblemDetailsEnabled(r) {
+			WriteProblem(rw, http.StatusServiceUnavailable, ProblemTypeNoAvailableServer, "No server of the backend is available")
+			return
+		}
 		rw.WriteHeader(http.StatusServiceUnavailable)
 		rw.Write([]byte(http.StatusText(http.StatusServiceUnavailable)))
 	} else {
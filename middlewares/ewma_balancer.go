@@ -0,0 +1,187 @@
+package middlewares
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+// ewmaDecay is the time constant of the moving averages of the response times: the weight of a response time
+// in the average decays by a factor e over it, as does the average of an idle server, for it to be tried again.
+const ewmaDecay = 10 * time.Second
+
+var _ healthcheck.LoadBalancer = (*EWMABalancer)(nil)
+
+// ewmaServer holds the moving average of the response times of a server.
+type ewmaServer struct {
+	average  float64
+	updated  time.Time
+	pending  int
+	measured bool
+}
+
+// EWMABalancer is a weighted least-time load balancer. It tracks the exponentially weighted moving average
+// of the response times of its servers, and routes each request to the server with the lowest average,
+// multiplied by its pending requests and divided by its weight. The servers without any response time are tried first.
+type EWMABalancer struct {
+	next       http.Handler
+	sticky     *roundrobin.StickySession
+	errHandler utils.ErrorHandler
+	// servers holds the URLs and the weights of the servers, with the options of the round robin.
+	servers *roundrobin.RoundRobin
+	now     func() time.Time
+
+	lock      sync.Mutex
+	latencies map[string]*ewmaServer
+	offset    int
+}
+
+// NewEWMABalancer builds a new EWMABalancer forwarding the requests to next, with the sticky sessions, if any.
+func NewEWMABalancer(next http.Handler, sticky *roundrobin.StickySession) *EWMABalancer {
+	servers, _ := roundrobin.New(next)
+	return &EWMABalancer{
+		next:       next,
+		sticky:     sticky,
+		errHandler: utils.DefaultHandler,
+		servers:    servers,
+		now:        time.Now,
+		latencies:  make(map[string]*ewmaServer),
+	}
+}
+
+// UpsertServer adds a server, or updates its weight.
+func (b *EWMABalancer) UpsertServer(u *url.URL, options ...roundrobin.ServerOption) error {
+	return b.servers.UpsertServer(u, options...)
+}
+
+// RemoveServer removes a server, along with its response times.
+func (b *EWMABalancer) RemoveServer(u *url.URL) error {
+	if err := b.servers.RemoveServer(u); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	delete(b.latencies, u.String())
+	b.lock.Unlock()
+	return nil
+}
+
+// Servers returns the URLs of the servers.
+func (b *EWMABalancer) Servers() []*url.URL {
+	return b.servers.Servers()
+}
+
+func (b *EWMABalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *req
+	servers := b.Servers()
+
+	var server *url.URL
+	if b.sticky != nil {
+		stuck, present, err := b.sticky.GetBackend(&newReq, servers)
+		if err != nil {
+			b.errHandler.ServeHTTP(rw, req, err)
+			return
+		}
+		if present {
+			server = stuck
+		}
+	}
+	if server == nil {
+		var err error
+		if server, err = b.nextServer(servers); err != nil {
+			b.errHandler.ServeHTTP(rw, req, err)
+			return
+		}
+		if b.sticky != nil {
+			b.sticky.StickBackend(server, &rw)
+		}
+	}
+
+	latency := b.start(server)
+	start := b.now()
+	newReq.URL = server
+	b.next.ServeHTTP(rw, &newReq)
+	b.done(latency, b.now().Sub(start))
+}
+
+// nextServer returns the server with the lowest cost, starting from a rotating offset for the ties
+// to be spread over the servers.
+func (b *EWMABalancer) nextServer(servers []*url.URL) (*url.URL, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("no servers in the pool")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := b.now()
+	b.offset = (b.offset + 1) % len(servers)
+
+	var next *url.URL
+	lowestCost := math.Inf(1)
+	for i := range servers {
+		server := servers[(b.offset+i)%len(servers)]
+		weight, _ := b.servers.ServerWeight(server)
+		if weight <= 0 {
+			continue
+		}
+		if cost := b.latency(server).cost(now) / float64(weight); cost < lowestCost {
+			next = server
+			lowestCost = cost
+		}
+	}
+	if next == nil {
+		return nil, errors.New("all servers have 0 weight")
+	}
+	return utils.CopyURL(next), nil
+}
+
+func (b *EWMABalancer) latency(server *url.URL) *ewmaServer {
+	latency, ok := b.latencies[server.String()]
+	if !ok {
+		latency = &ewmaServer{}
+		b.latencies[server.String()] = latency
+	}
+	return latency
+}
+
+func (b *EWMABalancer) start(server *url.URL) *ewmaServer {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	latency := b.latency(server)
+	latency.pending++
+	return latency
+}
+
+func (b *EWMABalancer) done(latency *ewmaServer, responseTime time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	latency.pending--
+	latency.observe(b.now(), responseTime)
+}
+
+// cost returns the average response time, decayed since the last response, times the requests in progress and the next one.
+func (s *ewmaServer) cost(now time.Time) float64 {
+	if !s.measured {
+		return 0
+	}
+	decay := math.Exp(-float64(now.Sub(s.updated)) / float64(ewmaDecay))
+	return s.average * decay * float64(s.pending+1)
+}
+
+func (s *ewmaServer) observe(now time.Time, responseTime time.Duration) {
+	if !s.measured {
+		s.average = float64(responseTime)
+		s.measured = true
+	} else {
+		weight := math.Exp(-float64(now.Sub(s.updated)) / float64(ewmaDecay))
+		s.average = s.average*weight + float64(responseTime)*(1-weight)
+	}
+	s.updated = now
+}
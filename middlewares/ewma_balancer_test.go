@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestEWMABalancerSlowServer(t *testing.T) {
+	var lock sync.Mutex
+	requests := make(map[string]int)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		requests[req.URL.Host]++
+		lock.Unlock()
+		if req.URL.Host == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	balancer := NewEWMABalancer(next, nil)
+	require.NoError(t, balancer.UpsertServer(testhelpers.MustParseURL("http://fast")))
+	require.NoError(t, balancer.UpsertServer(testhelpers.MustParseURL("http://slow")))
+
+	for i := 0; i < 50; i++ {
+		recorder := httptest.NewRecorder()
+		balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	assert.Equal(t, 50, requests["fast"]+requests["slow"])
+	assert.True(t, requests["slow"] < 5, "the slow server got %d requests", requests["slow"])
+}
+
+func TestEWMABalancerServers(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.Host))
+	})
+	balancer := NewEWMABalancer(next, nil)
+
+	recorder := httptest.NewRecorder()
+	balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "no servers")
+
+	server1 := testhelpers.MustParseURL("http://server1")
+	server2 := testhelpers.MustParseURL("http://server2")
+	require.NoError(t, balancer.UpsertServer(server1, roundrobin.Weight(1)))
+	require.NoError(t, balancer.UpsertServer(server2, roundrobin.Weight(1)))
+	require.NoError(t, balancer.RemoveServer(server1))
+	require.Len(t, balancer.Servers(), 1)
+	assert.Equal(t, "http://server2", balancer.Servers()[0].String())
+
+	for i := 0; i < 3; i++ {
+		recorder := httptest.NewRecorder()
+		balancer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "server2", recorder.Body.String())
+	}
+}
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/containous/traefik/types"
+	"github.com/urfave/negroni"
+)
+
+// ForwardedURIHeader is the header carrying the original URI of the requests, before their path is rewritten.
+const ForwardedURIHeader = "X-Forwarded-Uri"
+
+var forwardedHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Port",
+	"X-Forwarded-Prefix",
+	"X-Forwarded-Proto",
+	"X-Forwarded-Server",
+	"X-Forwarded-Uri",
+	"X-Real-Ip",
+}
+
+// ForwardedHeaders is a middleware that removes the forwarded headers of the
+// requests which are not coming from a trusted proxy, so that the backends
+// and the forwarder only see the direct remote address.
+type ForwardedHeaders struct {
+	proxyChecker *types.ProxyChecker
+}
+
+// NewForwardedHeaders returns a new ForwardedHeaders middleware trusting the proxies of proxyChecker
+func NewForwardedHeaders(proxyChecker *types.ProxyChecker) *ForwardedHeaders {
+	return &ForwardedHeaders{proxyChecker: proxyChecker}
+}
+
+func (f *ForwardedHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !f.proxyChecker.IsTrusted(r.RemoteAddr) {
+		for _, header := range forwardedHeaders {
+			r.Header.Del(header)
+		}
+	}
+	next(rw, r)
+}
+
+// IsForwardedHTTPS checks if a request was received over HTTPS by a trusted proxy in front of Traefik,
+// from its X-Forwarded-Proto header.
+func IsForwardedHTTPS(r *http.Request, proxyChecker *types.ProxyChecker) bool {
+	return proxyChecker.IsTrusted(r.RemoteAddr) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// SecureRedirect redirects the requests to HTTPS with its redirect handler, except the ones forwarded
+// by a trusted proxy having received them over HTTPS, such as a load balancer terminating TLS,
+// which are already secure and would loop on the redirect.
+type SecureRedirect struct {
+	redirect     negroni.Handler
+	proxyChecker *types.ProxyChecker
+}
+
+// NewSecureRedirect returns a new SecureRedirect middleware trusting the proxies of proxyChecker
+func NewSecureRedirect(redirect negroni.Handler, proxyChecker *types.ProxyChecker) *SecureRedirect {
+	return &SecureRedirect{redirect: redirect, proxyChecker: proxyChecker}
+}
+
+func (s *SecureRedirect) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if IsForwardedHTTPS(r, s.proxyChecker) {
+		next(rw, r)
+		return
+	}
+	s.redirect.ServeHTTP(rw, r, next)
+}
+
+// ForwardedURI passes the original URI of the requests, path and query, to the backends in the X-Forwarded-Uri header,
+// before the next handlers rewrite it, e.g. stripping a prefix.
+// The header forwarded by a trusted proxy in front of Traefik is kept, holding the URI received by the proxy.
+type ForwardedURI struct {
+	Handler http.Handler
+}
+
+func (f *ForwardedURI) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if len(r.Header.Get(ForwardedURIHeader)) == 0 {
+		r.Header.Set(ForwardedURIHeader, r.URL.RequestURI())
+	}
+	f.Handler.ServeHTTP(rw, r)
+}
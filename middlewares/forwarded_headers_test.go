@@ -0,0 +1,160 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc                   string
+		remoteAddr             string
+		expectedXForwardedFor  string
+		expectedXForwardedHost string
+		expectedXForwardedURI  string
+	}{
+		{
+			desc:                   "request from a trusted proxy",
+			remoteAddr:             "10.0.0.1:1234",
+			expectedXForwardedFor:  "1.2.3.4",
+			expectedXForwardedHost: "foo.bar",
+			expectedXForwardedURI:  "/foo",
+		},
+		{
+			desc:       "request from an untrusted source",
+			remoteAddr: "5.6.7.8:1234",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", "1.2.3.4")
+			req.Header.Set("X-Forwarded-Host", "foo.bar")
+			req.Header.Set("X-Forwarded-Uri", "/foo")
+
+			var forwardedFor, forwardedHost, forwardedURI string
+			NewForwardedHeaders(proxyChecker).ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, r *http.Request) {
+				forwardedFor = r.Header.Get("X-Forwarded-For")
+				forwardedHost = r.Header.Get("X-Forwarded-Host")
+				forwardedURI = r.Header.Get("X-Forwarded-Uri")
+			})
+
+			assert.Equal(t, test.expectedXForwardedFor, forwardedFor)
+			assert.Equal(t, test.expectedXForwardedHost, forwardedHost)
+			assert.Equal(t, test.expectedXForwardedURI, forwardedURI)
+		})
+	}
+}
+
+func TestSecureRedirect(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"10.0.0.0/8"})
+	require.NoError(t, err)
+	redirect, err := NewRewrite(`^(?:https?://)?([^/:]+)(?::\d+)?(.*)$`, "https://$1:443$2", true)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc           string
+		remoteAddr     string
+		forwardedProto string
+		expectedCode   int
+	}{
+		{
+			desc:           "HTTPS forwarded by a trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedProto: "https",
+			expectedCode:   http.StatusOK,
+		},
+		{
+			desc:         "no forwarded proto",
+			remoteAddr:   "10.0.0.1:1234",
+			expectedCode: http.StatusFound,
+		},
+		{
+			desc:           "HTTP forwarded by a trusted proxy",
+			remoteAddr:     "10.0.0.1:1234",
+			forwardedProto: "http",
+			expectedCode:   http.StatusFound,
+		},
+		{
+			desc:           "HTTPS forwarded by an untrusted source",
+			remoteAddr:     "5.6.7.8:1234",
+			forwardedProto: "https",
+			expectedCode:   http.StatusFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/", nil)
+			req.RemoteAddr = test.remoteAddr
+			if len(test.forwardedProto) > 0 {
+				req.Header.Set("X-Forwarded-Proto", test.forwardedProto)
+			}
+
+			recorder := httptest.NewRecorder()
+			NewSecureRedirect(redirect, proxyChecker).ServeHTTP(recorder, req, func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestForwardedURI(t *testing.T) {
+	testCases := []struct {
+		desc                 string
+		forwardedURI         string
+		expectedForwardedURI string
+	}{
+		{
+			desc:                 "original URI",
+			expectedForwardedURI: "/api/users?id=1",
+		},
+		{
+			desc:                 "URI forwarded by a proxy",
+			forwardedURI:         "/public/api/users?id=1",
+			expectedForwardedURI: "/public/api/users?id=1",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/api/users?id=1", nil)
+			if len(test.forwardedURI) > 0 {
+				req.Header.Set(ForwardedURIHeader, test.forwardedURI)
+			}
+
+			var forwardedURI string
+			handler := &ForwardedURI{Handler: &StripPrefix{
+				Prefixes: []string{"/api"},
+				Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					forwardedURI = r.Header.Get(ForwardedURIHeader)
+				}),
+			}}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, test.expectedForwardedURI, forwardedURI)
+		})
+	}
+}
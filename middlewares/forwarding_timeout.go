@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ForwardingTimeoutRoundTripper is a RoundTripper bounding the whole duration of the forwarded requests,
+// from dialing the backend to reading the last byte of its response body.
+type ForwardingTimeoutRoundTripper struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// NewForwardingTimeoutRoundTripper creates a ForwardingTimeoutRoundTripper sending the requests with the given RoundTripper.
+func NewForwardingTimeoutRoundTripper(next http.RoundTripper, timeout time.Duration) *ForwardingTimeoutRoundTripper {
+	return &ForwardingTimeoutRoundTripper{next: next, timeout: timeout}
+}
+
+// RoundTrip sends the request, canceling it once the timeout elapsed.
+func (f *ForwardingTimeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), f.timeout)
+	resp, err := f.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The body is read after RoundTrip returned, the context is released once it is closed.
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the transport, if it can.
+func (f *ForwardingTimeoutRoundTripper) CloseIdleConnections() {
+	if closer, ok := f.next.(interface {
+		CloseIdleConnections()
+	}); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardingTimeoutRoundTripper(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		if req.URL.Path == "/slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-req.Context().Done():
+			}
+		}
+		rw.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	roundTripper := NewForwardingTimeoutRoundTripper(http.DefaultTransport, 200*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, ts.URL+"/fast", nil)
+	req.RequestURI = ""
+	resp, err := roundTripper.RoundTrip(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "body", string(body))
+
+	req = httptest.NewRequest(http.MethodGet, ts.URL+"/slow", nil)
+	req.RequestURI = ""
+	resp, err = roundTripper.RoundTrip(req)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the body read should have been interrupted by the timeout")
+	if netErr, ok := err.(net.Error); assert.True(t, ok, "error %v should be a net.Error", err) {
+		assert.True(t, netErr.Timeout())
+	}
+}
@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// grpcWebTrailersFlag flags the frame holding the trailers at the end of a grpc-web response body.
+	grpcWebTrailersFlag = 0x80
+)
+
+// GrpcWeb is a middleware translating the grpc-web requests to native gRPC ones for a gRPC backend,
+// and their responses back to grpc-web, the trailers of the response being sent in the last frame of its body.
+// The requests that are not grpc-web ones are passed through.
+type GrpcWeb struct{}
+
+// NewGrpcWeb builds a new GrpcWeb middleware.
+func NewGrpcWeb() *GrpcWeb {
+	return &GrpcWeb{}
+}
+
+func (g *GrpcWeb) ServeHTTP(rw http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, grpcWebContentType) {
+		next(rw, req)
+		return
+	}
+
+	// application/grpc-web-text+proto is translated to application/grpc+proto, and back in the response.
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+	subtype := strings.TrimPrefix(contentType, grpcWebContentType)
+	if text {
+		subtype = strings.TrimPrefix(contentType, grpcWebTextContentType)
+	}
+
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		outReq.Header[name] = values
+	}
+	outReq.Header.Set("Content-Type", grpcContentType+subtype)
+	outReq.Header.Del("X-Grpc-Web")
+	if text {
+		outReq.Body = ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, req.Body))
+		outReq.ContentLength = -1
+		outReq.Header.Del("Content-Length")
+	}
+
+	grpcWebRW := newGrpcWebResponseWriter(rw, contentType, text)
+	next(grpcWebRW, outReq)
+	grpcWebRW.finish()
+}
+
+// grpcWebResponseWriter writes a native gRPC response as a grpc-web one.
+type grpcWebResponseWriter struct {
+	rw          http.ResponseWriter
+	header      http.Header
+	contentType string
+	body        io.Writer
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func newGrpcWebResponseWriter(rw http.ResponseWriter, contentType string, text bool) *grpcWebResponseWriter {
+	grpcWebRW := &grpcWebResponseWriter{
+		rw:          rw,
+		header:      make(http.Header),
+		contentType: contentType,
+		body:        rw,
+	}
+	if text {
+		grpcWebRW.encoder = base64.NewEncoder(base64.StdEncoding, rw)
+		grpcWebRW.body = grpcWebRW.encoder
+	}
+	return grpcWebRW
+}
+
+func (g *grpcWebResponseWriter) Header() http.Header {
+	return g.header
+}
+
+func (g *grpcWebResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	for name, values := range g.header {
+		if name == "Trailer" || name == "Content-Length" || strings.HasPrefix(name, http.TrailerPrefix) {
+			continue
+		}
+		g.rw.Header()[name] = values
+	}
+	g.rw.Header().Set("Content-Type", g.contentType)
+	g.rw.WriteHeader(code)
+}
+
+func (g *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	return g.body.Write(b)
+}
+
+// Flush sends the frames written so far to the client.
+func (g *grpcWebResponseWriter) Flush() {
+	if flusher, ok := g.rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish writes the trailers of the gRPC response in the trailers frame ending the grpc-web body.
+func (g *grpcWebResponseWriter) finish() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+
+	trailers := make(http.Header)
+	for _, declared := range g.header["Trailer"] {
+		for _, name := range strings.Split(declared, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+			if values, ok := g.header[name]; ok {
+				trailers[name] = values
+			}
+		}
+	}
+	for name, values := range g.header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			trailers[textproto.CanonicalMIMEHeaderKey(strings.TrimPrefix(name, http.TrailerPrefix))] = values
+		}
+	}
+
+	if len(trailers) > 0 {
+		names := make([]string, 0, len(trailers))
+		for name := range trailers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var frame bytes.Buffer
+		for _, name := range names {
+			for _, value := range trailers[name] {
+				frame.WriteString(strings.ToLower(name) + ": " + value + "\r\n")
+			}
+		}
+		header := make([]byte, 5)
+		header[0] = grpcWebTrailersFlag
+		binary.BigEndian.PutUint32(header[1:], uint32(frame.Len()))
+		g.body.Write(header)
+		g.body.Write(frame.Bytes())
+	}
+
+	if g.encoder != nil {
+		g.encoder.Close()
+	}
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+// grpcFrame returns a gRPC length-prefixed frame of message.
+func grpcFrame(flag byte, message string) []byte {
+	length := len(message)
+	return append([]byte{flag, byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length)}, message...)
+}
+
+func TestGrpcWeb(t *testing.T) {
+	// A native gRPC unary handler, answering the message of the request prefixed with "hello ".
+	grpcHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		if req.Header.Get("Content-Type") != "application/grpc+proto" || len(body) < 5 {
+			rw.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/grpc+proto")
+		rw.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(grpcFrame(0, "hello "+string(body[5:])))
+		rw.Header().Set("Grpc-Status", "0")
+		rw.Header().Set("Grpc-Message", "OK")
+	})
+
+	handler := negroni.New(NewGrpcWeb())
+	handler.UseHandler(grpcHandler)
+
+	expectedBody := append(grpcFrame(0, "hello world"), grpcFrame(0x80, "grpc-message: OK\r\ngrpc-status: 0\r\n")...)
+
+	testCases := []struct {
+		desc                string
+		contentType         string
+		body                []byte
+		expectedContentType string
+		expectedBody        []byte
+	}{
+		{
+			desc:                "binary",
+			contentType:         "application/grpc-web+proto",
+			body:                grpcFrame(0, "world"),
+			expectedContentType: "application/grpc-web+proto",
+			expectedBody:        expectedBody,
+		},
+		{
+			desc:                "text",
+			contentType:         "application/grpc-web-text+proto",
+			body:                []byte(base64.StdEncoding.EncodeToString(grpcFrame(0, "world"))),
+			expectedContentType: "application/grpc-web-text+proto",
+			expectedBody:        []byte(base64.StdEncoding.EncodeToString(expectedBody)),
+		},
+		{
+			desc:                "not grpc-web",
+			contentType:         "application/grpc+proto",
+			body:                grpcFrame(0, "world"),
+			expectedContentType: "application/grpc+proto",
+			expectedBody:        grpcFrame(0, "hello world"),
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/helloworld.Greeter/SayHello", bytes.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expectedContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, test.expectedBody, recorder.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/containous/traefik/healthcheck"
+)
+
+// HeaderAffinity is a middleware pinning the requests carrying a header, or a cookie, to a server of the load balancer,
+// picked by the rendezvous hashing of its value: the requests with the same value hit the same server as long as it
+// is in the load balancer, and adding or removing a server of N only moves about 1/N of the values.
+// Each server is hashed virtualNodes times, its highest hash being compared to the ones of the other servers,
+// which evens out the share of the values of each server.
+// The requests without the header or cookie are balanced by next.
+type HeaderAffinity struct {
+	lb           healthcheck.LoadBalancer
+	header       string
+	cookie       string
+	virtualNodes int
+	forward      http.Handler
+	next         http.Handler
+}
+
+// NewHeaderAffinity creates a new HeaderAffinity hashing the value of the header, or of the cookie when the header
+// is empty, with each server hashed virtualNodes times, once when not positive. The requests carrying a value are
+// sent to the servers of the load balancer with forward, and the other ones to next.
+func NewHeaderAffinity(lb healthcheck.LoadBalancer, header string, cookie string, virtualNodes int, forward http.Handler, next http.Handler) *HeaderAffinity {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+	return &HeaderAffinity{lb: lb, header: header, cookie: cookie, virtualNodes: virtualNodes, forward: forward, next: next}
+}
+
+func (h *HeaderAffinity) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	value := h.value(r)
+	if len(value) == 0 {
+		h.next.ServeHTTP(rw, r)
+		return
+	}
+	server := h.pickServer(value)
+	if server == nil {
+		h.next.ServeHTTP(rw, r)
+		return
+	}
+
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *r
+	newReq.URL = server
+	h.forward.ServeHTTP(rw, &newReq)
+}
+
+// value returns the value of the header or cookie of the request, empty if missing.
+func (h *HeaderAffinity) value(r *http.Request) string {
+	if len(h.header) > 0 {
+		return r.Header.Get(h.header)
+	}
+	cookie, err := r.Cookie(h.cookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// pickServer returns the server with the highest hash of the value and its URL, if any.
+func (h *HeaderAffinity) pickServer(value string) *url.URL {
+	var picked *url.URL
+	var highest uint64
+	for _, server := range h.lb.Servers() {
+		for i := 0; i < h.virtualNodes; i++ {
+			hash := fnv.New64a()
+			hash.Write([]byte(value))
+			hash.Write([]byte{0})
+			hash.Write([]byte(server.String()))
+			if i > 0 {
+				hash.Write([]byte("#" + strconv.Itoa(i)))
+			}
+			// fnv is poorly spread on keys differing by their last bytes, such as the servers of a backend.
+			if sum := mix64(hash.Sum64()); picked == nil || sum > highest {
+				picked = server
+				highest = sum
+			}
+		}
+	}
+	return picked
+}
+
+// mix64 is the finalizer of MurmurHash3, spreading the bits of the hash.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
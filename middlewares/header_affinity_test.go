@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestHeaderAffinity(t *testing.T) {
+	forward := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Server", req.URL.String())
+	})
+	lb, err := roundrobin.New(forward)
+	require.NoError(t, err)
+	for _, server := range []string{"http://10.0.0.1", "http://10.0.0.2", "http://10.0.0.3"} {
+		require.NoError(t, lb.UpsertServer(testhelpers.MustParseURL(server)))
+	}
+	handler := NewHeaderAffinity(lb, "X-Tenant", "", 0, forward, lb)
+
+	serve := func(tenant string) string {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://frontend/", nil)
+		if len(tenant) > 0 {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Header().Get("X-Server")
+	}
+
+	servers := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		tenant := fmt.Sprintf("tenant-%d", i)
+		servers[tenant] = serve(tenant)
+		for j := 0; j < 5; j++ {
+			assert.Equal(t, servers[tenant], serve(tenant), "tenant %s moved to another server", tenant)
+		}
+	}
+	picked := make(map[string]bool)
+	for _, server := range servers {
+		picked[server] = true
+	}
+	assert.Len(t, picked, 3, "the tenants must be spread over all the servers")
+
+	// Without the header, the requests are balanced.
+	assert.NotEqual(t, serve(""), serve(""))
+
+	// Only the tenants of a removed server are moved.
+	removed := servers["tenant-0"]
+	require.NoError(t, lb.RemoveServer(testhelpers.MustParseURL(removed)))
+	for tenant, server := range servers {
+		if server == removed {
+			assert.NotEqual(t, removed, serve(tenant))
+		} else {
+			assert.Equal(t, server, serve(tenant), "tenant %s moved to another server", tenant)
+		}
+	}
+}
+
+func TestHeaderAffinityServerChanges(t *testing.T) {
+	const servers, keys = 10, 10000
+	forward := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Server", req.URL.String())
+	})
+	lb, err := roundrobin.New(forward)
+	require.NoError(t, err)
+	for i := 1; i <= servers; i++ {
+		require.NoError(t, lb.UpsertServer(testhelpers.MustParseURL(fmt.Sprintf("http://10.0.0.%d", i))))
+	}
+	handler := NewHeaderAffinity(lb, "", "session_id", 10, forward, lb)
+
+	serve := func(session string) string {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://frontend/", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Header().Get("X-Server")
+	}
+
+	picked := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		picked[session] = serve(session)
+		counts[picked[session]]++
+	}
+	assert.Equal(t, picked["session-0"], serve("session-0"))
+	require.Len(t, counts, servers, "the sessions must be spread over all the servers")
+	for server, count := range counts {
+		assert.InDelta(t, keys/servers, count, keys/servers/2, "server %s got an unbalanced share of the sessions", server)
+	}
+
+	// Removing one server of N only moves its sessions, about 1/N of them.
+	removed := picked["session-0"]
+	require.NoError(t, lb.RemoveServer(testhelpers.MustParseURL(removed)))
+	var moved int
+	for session, server := range picked {
+		current := serve(session)
+		if server == removed {
+			assert.NotEqual(t, removed, current)
+		} else {
+			assert.Equal(t, server, current, "session %s moved from a remaining server", session)
+		}
+		if current != server {
+			moved++
+		}
+	}
+	assert.InDelta(t, keys/servers, moved, keys/servers/2)
+
+	// Adding it back moves back the same sessions.
+	require.NoError(t, lb.UpsertServer(testhelpers.MustParseURL(removed)))
+	for session, server := range picked {
+		assert.Equal(t, server, serve(session))
+	}
+}
+
+func TestHeaderAffinityNoServer(t *testing.T) {
+	lb, err := roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	var fallback bool
+	handler := NewHeaderAffinity(lb, "X-Tenant", "", 0, http.NotFoundHandler(), http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fallback = true
+	}))
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://frontend/", nil)
+	req.Header.Set("X-Tenant", "tenant")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, fallback)
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+)
+
+type rawHeaderNamesKey struct{}
+
+// headerCaseExcluded are the headers which are kept in canonical form, as they are read by http.Transport.
+var headerCaseExcluded = map[string]bool{
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Expect":            true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Range":             true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// WithRawHeaderNames returns a shallow copy of the request carrying the names its headers were received with,
+// indexed by their canonical form.
+func WithRawHeaderNames(req *http.Request, rawHeaderNames map[string]string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), rawHeaderNamesKey{}, rawHeaderNames))
+}
+
+// HeaderCaseRoundTripper is a RoundTripper sending the request headers with the names they were received with,
+// instead of their canonical form, for the backends requiring the original case of the header names.
+type HeaderCaseRoundTripper struct {
+	next http.RoundTripper
+}
+
+// NewHeaderCaseRoundTripper creates a HeaderCaseRoundTripper sending the requests with the given RoundTripper.
+func NewHeaderCaseRoundTripper(next http.RoundTripper) *HeaderCaseRoundTripper {
+	return &HeaderCaseRoundTripper{next: next}
+}
+
+// RoundTrip sends the request with the original names of its headers.
+func (h *HeaderCaseRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rawHeaderNames, _ := req.Context().Value(rawHeaderNamesKey{}).(map[string]string)
+	if len(rawHeaderNames) == 0 {
+		return h.next.RoundTrip(req)
+	}
+
+	header := make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		if rawName, ok := rawHeaderNames[name]; ok && !headerCaseExcluded[name] {
+			name = rawName
+		}
+		header[name] = values
+	}
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = header
+	return h.next.RoundTrip(outReq)
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHeaderCaseRoundTripper(t *testing.T) {
+	var sentHeader http.Header
+	roundTripper := NewHeaderCaseRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sentHeader = req.Header
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Set("X-Clientid", "abc")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("X-Other", "def")
+	req = WithRawHeaderNames(req, map[string]string{
+		"X-Clientid":      "X-ClientId",
+		"Accept-Encoding": "accept-encoding",
+	})
+
+	_, err := roundTripper.RoundTrip(req)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.Header{
+		"X-ClientId":      {"abc"},
+		"Accept-Encoding": {"gzip"},
+		"X-Other":         {"def"},
+	}, sentHeader)
+	assert.Equal(t, "abc", req.Header.Get("X-Clientid"), "the original request must be left untouched")
+}
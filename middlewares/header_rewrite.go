@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"regexp"
+)
+
+// HeaderRewrite is a middleware rewriting the values of a response header matching a regular expression,
+// e.g. to replace the internal hostnames of the Location headers sent by the backends on redirects.
+type HeaderRewrite struct {
+	header      string
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// NewHeaderRewrite creates a HeaderRewrite middleware, replacing the parts of the values of the response header
+// matching regex with replacement, which may reference the capturing groups of regex ($1, ${name}...).
+func NewHeaderRewrite(header, regex, replacement string) (*HeaderRewrite, error) {
+	compiledRegex, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return &HeaderRewrite{
+		header:      http.CanonicalHeaderKey(header),
+		regex:       compiledRegex,
+		replacement: replacement,
+	}, nil
+}
+
+func (h *HeaderRewrite) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(&headerRewriteResponseWriter{ResponseWriter: rw, headerRewrite: h}, r)
+}
+
+func (h *HeaderRewrite) rewrite(headers http.Header) {
+	values := headers[h.header]
+	for i, value := range values {
+		values[i] = h.regex.ReplaceAllString(value, h.replacement)
+	}
+}
+
+// headerRewriteResponseWriter rewrites the response header right before it is written.
+type headerRewriteResponseWriter struct {
+	http.ResponseWriter
+	headerRewrite *HeaderRewrite
+	wroteHeader   bool
+}
+
+func (w *headerRewriteResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.headerRewrite.rewrite(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerRewriteResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (w *headerRewriteResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *headerRewriteResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *headerRewriteResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderRewrite(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		header         string
+		regex          string
+		replacement    string
+		values         []string
+		expectedValues []string
+	}{
+		{
+			desc:           "rewrite Location on redirect",
+			header:         "Location",
+			regex:          "^http://internal:8080/(.*)",
+			replacement:    "https://public.example.com/$1",
+			values:         []string{"http://internal:8080/login?next=/"},
+			expectedValues: []string{"https://public.example.com/login?next=/"},
+		},
+		{
+			desc:           "non matching value untouched",
+			header:         "Location",
+			regex:          "^http://internal:8080/(.*)",
+			replacement:    "https://public.example.com/$1",
+			values:         []string{"https://other.example.com/login"},
+			expectedValues: []string{"https://other.example.com/login"},
+		},
+		{
+			desc:           "multiple values",
+			header:         "link",
+			regex:          "internal:8080",
+			replacement:    "public.example.com",
+			values:         []string{"<http://internal:8080/a>; rel=next", "<http://other/b>; rel=prev"},
+			expectedValues: []string{"<http://public.example.com/a>; rel=next", "<http://other/b>; rel=prev"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			headerRewrite, err := NewHeaderRewrite(test.header, test.regex, test.replacement)
+			require.NoError(t, err)
+
+			next := func(rw http.ResponseWriter, r *http.Request) {
+				for _, value := range test.values {
+					rw.Header().Add(test.header, value)
+				}
+				rw.Header().Set("X-Other", "http://internal:8080/")
+				rw.WriteHeader(http.StatusFound)
+			}
+
+			recorder := httptest.NewRecorder()
+			headerRewrite.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil), next)
+
+			assert.Equal(t, http.StatusFound, recorder.Code)
+			assert.Equal(t, test.expectedValues, recorder.Header()[http.CanonicalHeaderKey(test.header)])
+			assert.Equal(t, "http://internal:8080/", recorder.Header().Get("X-Other"))
+		})
+	}
+}
+
+func TestNewHeaderRewriteInvalidRegex(t *testing.T) {
+	_, err := NewHeaderRewrite("Location", "(", "")
+	assert.Error(t, err)
+}
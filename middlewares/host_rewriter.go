@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vulcand/oxy/forward"
+)
+
+type hostOverrideKey struct{}
+
+// HostRewriter is a request rewriter of the forwarder, sending the requests of a backend with a fixed Host,
+// whether the client Host is passed or not. The X-Forwarded-Host header is set from the Host it overrides.
+// The Host override of the frontend of the request, if any, wins over the one of the backend.
+type HostRewriter struct {
+	next forward.ReqRewriter
+	host string
+}
+
+// NewHostRewriter creates a HostRewriter setting the host, if not empty, once the request has been rewritten by next,
+// if not nil.
+func NewHostRewriter(next forward.ReqRewriter, host string) *HostRewriter {
+	return &HostRewriter{next: next, host: host}
+}
+
+// Rewrite rewrites the Host of the request, and its Host header used to dial the websocket backends.
+func (r *HostRewriter) Rewrite(req *http.Request) {
+	if r.next != nil {
+		r.next.Rewrite(req)
+	}
+	host := r.host
+	if override, ok := req.Context().Value(hostOverrideKey{}).(string); ok {
+		host = override
+	}
+	if len(host) == 0 {
+		return
+	}
+	req.Host = host
+	if _, ok := req.Header["Host"]; ok {
+		req.Header.Set("Host", host)
+	}
+}
+
+// NewHostOverride returns a handler overriding the Host of the requests of a frontend, sent by the HostRewriter
+// of the forwarders of its backends. The forwarders being shared by the frontends of a backend, the override
+// travels with the request.
+func NewHostOverride(next http.Handler, host string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), hostOverrideKey{}, host)))
+	})
+}
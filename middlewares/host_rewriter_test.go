@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/vulcand/oxy/forward"
+)
+
+func TestHostRewriter(t *testing.T) {
+	rewriter := NewHostRewriter(&forward.HeaderRewriter{TrustForwardHeader: true}, "internal.svc")
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://backend:8080/", nil)
+	req.Host = "traefik.example.com"
+	rewriter.Rewrite(req)
+
+	assert.Equal(t, "internal.svc", req.Host)
+	assert.Equal(t, "traefik.example.com", req.Header.Get(forward.XForwardedHost))
+	assert.Empty(t, req.Header.Get("Host"))
+}
+
+func TestHostRewriterWebsocket(t *testing.T) {
+	rewriter := NewHostRewriter(nil, "internal.svc")
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "ws://backend:8080/", nil)
+	req.Host = "traefik.example.com"
+	req.Header.Set("Host", "traefik.example.com")
+	rewriter.Rewrite(req)
+
+	assert.Equal(t, "internal.svc", req.Host)
+	assert.Equal(t, "internal.svc", req.Header.Get("Host"))
+}
+
+func TestHostRewriterFrontendOverride(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		host         string
+		expectedHost string
+	}{
+		{
+			desc:         "override of the backend host",
+			host:         "internal.svc",
+			expectedHost: "canonical.svc",
+		},
+		{
+			desc:         "override without backend host",
+			expectedHost: "canonical.svc",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rewriter := NewHostRewriter(nil, test.host)
+			var rewritten *http.Request
+			handler := NewHostOverride(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rewritten = req
+				rewriter.Rewrite(req)
+			}), "canonical.svc")
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://backend:8080/", nil)
+			req.Host = "traefik.example.com"
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, test.expectedHost, rewritten.Host)
+		})
+	}
+}
+
+func TestHostRewriterNoHost(t *testing.T) {
+	rewriter := NewHostRewriter(nil, "")
+
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://backend:8080/", nil)
+	req.Host = "traefik.example.com"
+	rewriter.Rewrite(req)
+
+	assert.Equal(t, "traefik.example.com", req.Host)
+}
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// HTTPFallbackRetryInterval is the duration after which the TLS handshake is tried again with a server
+// from which the requests were downgraded to HTTP.
+const HTTPFallbackRetryInterval = time.Minute
+
+// HTTPFallbackRoundTripper is a RoundTripper sending the https requests over HTTP to the servers not answering
+// the TLS handshake with TLS, for the backends in transition to TLS. The servers failing the handshake otherwise,
+// e.g. with an invalid certificate, are not downgraded.
+// A downgraded server is sent the next requests over HTTP, until the handshake is tried again after HTTPFallbackRetryInterval.
+type HTTPFallbackRoundTripper struct {
+	next http.RoundTripper
+	now  func() time.Time
+
+	lock sync.Mutex
+	// downgrades holds the time until which the requests are sent over HTTP, by server host.
+	downgrades map[string]time.Time
+}
+
+// NewHTTPFallbackRoundTripper creates a HTTPFallbackRoundTripper sending the requests with the given RoundTripper.
+func NewHTTPFallbackRoundTripper(next http.RoundTripper) *HTTPFallbackRoundTripper {
+	return &HTTPFallbackRoundTripper{next: next, now: time.Now, downgrades: make(map[string]time.Time)}
+}
+
+// RoundTrip sends the request over https, or over HTTP once the server failed to answer the TLS handshake.
+func (h *HTTPFallbackRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return h.next.RoundTrip(req)
+	}
+	if h.downgraded(req.URL.Host) {
+		return h.next.RoundTrip(downgradeRequest(req))
+	}
+
+	// The body of the request is closed when the handshake fails, the request being retried only without it.
+	replayable := req.Body == nil || req.Body == http.NoBody
+	resp, err := h.next.RoundTrip(req)
+	if _, ok := err.(tls.RecordHeaderError); !ok {
+		return resp, err
+	}
+
+	log.Warnf("Server %s does not answer the TLS handshake, downgrading its requests to HTTP: %v", req.URL.Host, err)
+	h.lock.Lock()
+	h.downgrades[req.URL.Host] = h.now().Add(HTTPFallbackRetryInterval)
+	h.lock.Unlock()
+	if !replayable {
+		return nil, err
+	}
+	return h.next.RoundTrip(downgradeRequest(req))
+}
+
+// CloseIdleConnections closes the idle connections of the transport, if it can.
+func (h *HTTPFallbackRoundTripper) CloseIdleConnections() {
+	if closer, ok := h.next.(interface {
+		CloseIdleConnections()
+	}); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func (h *HTTPFallbackRoundTripper) downgraded(host string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	until, ok := h.downgrades[host]
+	if ok && !h.now().Before(until) {
+		delete(h.downgrades, host)
+		return false
+	}
+	return ok
+}
+
+// downgradeRequest returns a shallow copy of the request, sent over HTTP.
+func downgradeRequest(req *http.Request) *http.Request {
+	outReq := new(http.Request)
+	*outReq = *req
+	outURL := *req.URL
+	outURL.Scheme = "http"
+	outReq.URL = &outURL
+	return outReq
+}
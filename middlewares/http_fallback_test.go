@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPFallbackRoundTripper(t *testing.T) {
+	var schemes []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	transport := &http.Transport{}
+	recordingTransport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		schemes = append(schemes, req.URL.Scheme)
+		return transport.RoundTrip(req)
+	})
+	fallback := NewHTTPFallbackRoundTripper(recordingTransport)
+	now := time.Now()
+	fallback.now = func() time.Time { return now }
+
+	roundTrip := func() {
+		resp, err := fallback.RoundTrip(testhelpers.MustNewRequest(http.MethodGet, "https://"+host, nil))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	roundTrip()
+	assert.Equal(t, []string{"https", "http"}, schemes, "the request should be retried over HTTP")
+
+	roundTrip()
+	assert.Equal(t, []string{"https", "http", "http"}, schemes, "the server should stay downgraded")
+
+	now = now.Add(HTTPFallbackRetryInterval)
+	roundTrip()
+	assert.Equal(t, []string{"https", "http", "http", "https", "http"}, schemes, "the TLS handshake should be tried again")
+
+	_, err := fallback.RoundTrip(testhelpers.MustNewRequest(http.MethodPost, "https://"+strings.Replace(host, "127.0.0.1", "localhost", 1), strings.NewReader("body")))
+	assert.Error(t, err, "a request with a body should not be retried")
+}
+
+func TestHTTPFallbackRoundTripperInvalidCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var schemes []string
+	transport := &http.Transport{}
+	fallback := NewHTTPFallbackRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		schemes = append(schemes, req.URL.Scheme)
+		return transport.RoundTrip(req)
+	}))
+
+	_, err := fallback.RoundTrip(testhelpers.MustNewRequest(http.MethodGet, server.URL, nil))
+	assert.Error(t, err)
+	assert.Equal(t, []string{"https"}, schemes, "a server with an untrusted certificate should not be downgraded")
+}
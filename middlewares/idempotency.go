@@ -0,0 +1,249 @@
+package middlewares
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+const (
+	// DefaultIdempotencyHeader is the default header carrying the idempotency keys of the requests.
+	DefaultIdempotencyHeader = "Idempotency-Key"
+	// DefaultIdempotencyTTL is the default duration during which a response is replayed.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyMaxEntries is the default maximum number of responses kept by an Idempotency.
+	DefaultIdempotencyMaxEntries = 10000
+	// DefaultIdempotencyMaxEntrySize is the default maximum size of a body kept by an Idempotency.
+	DefaultIdempotencyMaxEntrySize = 1 << 20
+)
+
+// Idempotency is a middleware replaying the response to the first request carrying an idempotency key
+// to the next requests carrying the same one, by method, host, URI and client identity, for the duration of its TTL.
+// The client identity is given by the Authorization and Cookie headers and the client certificate, and a request
+// whose body differs from the one of the first request of its key is rejected with 422 Unprocessable Entity.
+// Only the 2xx responses are replayed, without their cookies: the requests are forwarded again after any other one.
+// The requests arriving while the first one is in progress wait for its response.
+// The least recently used responses are dropped once the maximum number of them is reached, the ones in progress
+// being kept.
+type Idempotency struct {
+	header       string
+	ttl          time.Duration
+	maxEntries   int
+	maxEntrySize int64
+	now          func() time.Time
+
+	lock sync.Mutex
+	// lru holds the idempotent responses, the most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// idempotentResponse is the response to the requests of an idempotency key, complete once done is closed.
+type idempotentResponse struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	stored      bool
+	code        int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// NewIdempotency creates an Idempotency from its configuration, the unset values taking their default.
+func NewIdempotency(config *types.Idempotency) (*Idempotency, error) {
+	idempotency := &Idempotency{
+		header:       DefaultIdempotencyHeader,
+		ttl:          DefaultIdempotencyTTL,
+		maxEntries:   DefaultIdempotencyMaxEntries,
+		maxEntrySize: DefaultIdempotencyMaxEntrySize,
+		now:          time.Now,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+	if len(config.Header) > 0 {
+		idempotency.header = config.Header
+	}
+	if len(config.TTL) > 0 {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL %q: %s", config.TTL, err)
+		}
+		if ttl <= 0 {
+			return nil, errors.New("TTL must be positive")
+		}
+		idempotency.ttl = ttl
+	}
+	if config.MaxEntries < 0 {
+		return nil, fmt.Errorf("maximum number of entries must not be negative, got %d", config.MaxEntries)
+	}
+	if config.MaxEntries > 0 {
+		idempotency.maxEntries = config.MaxEntries
+	}
+	if config.MaxEntrySize < 0 {
+		return nil, fmt.Errorf("maximum entry size must not be negative, got %d", config.MaxEntrySize)
+	}
+	if config.MaxEntrySize > 0 {
+		idempotency.maxEntrySize = config.MaxEntrySize
+	}
+	return idempotency, nil
+}
+
+func (i *Idempotency) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	idempotencyKey := r.Header.Get(i.header)
+	if len(idempotencyKey) == 0 || len(r.Header.Get("Upgrade")) > 0 {
+		next(rw, r)
+		return
+	}
+
+	var body []byte
+	if r.Body != nil && r.ContentLength != 0 {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(r.Body, i.maxEntrySize+1))
+		if err != nil || int64(len(body)) > i.maxEntrySize {
+			log.Debugf("Not deduplicating request %s %s, its body is larger than %d bytes", r.Method, r.URL, i.maxEntrySize)
+			r.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+			next(rw, r)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	fingerprint := sha256.Sum256(body)
+
+	key := r.Method + " " + r.Host + r.URL.RequestURI() + " " + idempotencyKey + " " + clientIdentity(r)
+	for {
+		response, first := i.acquire(key, fingerprint)
+		if first {
+			i.forward(rw, r, next, response)
+			return
+		}
+		if response.fingerprint != fingerprint {
+			log.Debugf("Rejecting the request to %s, its body differs from the one of the first request", key)
+			http.Error(rw, "Idempotency key reused with a different request body", http.StatusUnprocessableEntity)
+			return
+		}
+
+		select {
+		case <-response.done:
+		case <-r.Context().Done():
+			return
+		}
+		if response.stored {
+			log.Debugf("Replaying the response to %s", key)
+			i.replay(rw, response)
+			return
+		}
+		// The first request got no response to replay, this one is forwarded in turn.
+	}
+}
+
+// forward forwards the first request of the key, completing its response even when the next handler panics.
+func (i *Idempotency) forward(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc, response *idempotentResponse) {
+	recorder := &cacheResponseWriter{ResponseWriter: rw, maxSize: i.maxEntrySize}
+	served := false
+	defer func() {
+		if !served {
+			recorder.uncacheable = true
+		}
+		i.complete(response, recorder)
+	}()
+	next(recorder, r)
+	served = true
+}
+
+// acquire returns the response to the key, and whether this request is the first one, which is then to complete it
+// for the request body of the fingerprint.
+func (i *Idempotency) acquire(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if element, ok := i.entries[key]; ok {
+		response := element.Value.(*idempotentResponse)
+		if !response.stored || response.expires.After(i.now()) {
+			i.lru.MoveToFront(element)
+			return response, false
+		}
+		i.remove(element)
+	}
+
+	response := &idempotentResponse{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+	i.entries[key] = i.lru.PushFront(response)
+	// The responses in progress are kept, for their duplicates to keep waiting for them.
+	for element := i.lru.Back(); element != nil && i.lru.Len() > i.maxEntries; {
+		previous := element.Prev()
+		if element.Value.(*idempotentResponse).stored {
+			i.remove(element)
+		}
+		element = previous
+	}
+	return response, true
+}
+
+// complete stores the recorded response when it is to be replayed, and releases the requests waiting for it.
+func (i *Idempotency) complete(response *idempotentResponse, recorder *cacheResponseWriter) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	defer close(response.done)
+
+	element, ok := i.entries[response.key]
+	if !ok || element.Value != response {
+		return
+	}
+	code := recorder.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if code < 200 || code >= 300 || recorder.uncacheable {
+		i.remove(element)
+		return
+	}
+	response.stored = true
+	response.code = code
+	response.header = recorder.header
+	response.body = recorder.body.Bytes()
+	response.expires = i.now().Add(i.ttl)
+}
+
+// replay writes the stored response, without the cookies set for the client of the first request.
+func (i *Idempotency) replay(rw http.ResponseWriter, response *idempotentResponse) {
+	for name, values := range response.header {
+		if name == "Set-Cookie" {
+			continue
+		}
+		rw.Header()[name] = append([]string(nil), values...)
+	}
+	rw.WriteHeader(response.code)
+	rw.Write(response.body)
+}
+
+// remove removes a response, the lock being held.
+func (i *Idempotency) remove(element *list.Element) {
+	response := i.lru.Remove(element).(*idempotentResponse)
+	delete(i.entries, response.key)
+}
+
+// clientIdentity returns the hash of the credentials identifying the client of the request: its Authorization and
+// Cookie headers, and its certificate.
+func clientIdentity(r *http.Request) string {
+	hash := sha256.New()
+	for _, name := range []string{"Authorization", "Cookie"} {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(hash, "%s: %s\n", name, value)
+		}
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		hash.Write(r.TLS.PeerCertificates[0].Raw)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
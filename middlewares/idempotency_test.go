@@ -0,0 +1,280 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveIdempotent(idempotency *Idempotency, backend http.HandlerFunc, key string) *httptest.ResponseRecorder {
+	req := testhelpers.MustNewRequest(http.MethodPost, "http://frontend/payments", nil)
+	if len(key) > 0 {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	recorder := httptest.NewRecorder()
+	idempotency.ServeHTTP(recorder, req, backend)
+	return recorder
+}
+
+func TestIdempotency(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		code          int
+		keys          []string
+		expectedCalls int
+	}{
+		{
+			desc:          "duplicates replayed",
+			code:          http.StatusCreated,
+			keys:          []string{"a", "a", "a"},
+			expectedCalls: 1,
+		},
+		{
+			desc:          "distinct keys forwarded",
+			code:          http.StatusCreated,
+			keys:          []string{"a", "b", "c"},
+			expectedCalls: 3,
+		},
+		{
+			desc:          "requests without key forwarded",
+			code:          http.StatusCreated,
+			keys:          []string{"", ""},
+			expectedCalls: 2,
+		},
+		{
+			desc:          "error responses not replayed",
+			code:          http.StatusInternalServerError,
+			keys:          []string{"a", "a"},
+			expectedCalls: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			idempotency, err := NewIdempotency(&types.Idempotency{})
+			require.NoError(t, err)
+
+			calls := 0
+			backend := func(rw http.ResponseWriter, req *http.Request) {
+				calls++
+				rw.Header().Set("X-Call", strconv.Itoa(calls))
+				rw.WriteHeader(test.code)
+				rw.Write([]byte("payment " + strconv.Itoa(calls)))
+			}
+
+			var first *httptest.ResponseRecorder
+			for _, key := range test.keys {
+				recorder := serveIdempotent(idempotency, backend, key)
+				assert.Equal(t, test.code, recorder.Code)
+				if first == nil {
+					first = recorder
+				} else if test.expectedCalls == 1 {
+					assert.Equal(t, first.Body.String(), recorder.Body.String())
+					assert.Equal(t, "1", recorder.Header().Get("X-Call"))
+				}
+			}
+			assert.Equal(t, test.expectedCalls, calls)
+		})
+	}
+}
+
+func TestIdempotencyExpiration(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{TTL: "1m"})
+	require.NoError(t, err)
+	now := time.Now()
+	idempotency.now = func() time.Time { return now }
+
+	calls := 0
+	backend := func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+	}
+
+	serveIdempotent(idempotency, backend, "a")
+	serveIdempotent(idempotency, backend, "a")
+	assert.Equal(t, 1, calls)
+
+	now = now.Add(time.Minute)
+	serveIdempotent(idempotency, backend, "a")
+	assert.Equal(t, 2, calls, "the expired response should not be replayed")
+}
+
+func TestIdempotencyMaxEntries(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{MaxEntries: 2})
+	require.NoError(t, err)
+
+	calls := 0
+	backend := func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+	}
+
+	for _, key := range []string{"a", "b", "c", "c", "b", "a"} {
+		serveIdempotent(idempotency, backend, key)
+	}
+	assert.Equal(t, 4, calls, "the least recently used response should have been dropped")
+	assert.Equal(t, 2, idempotency.lru.Len())
+}
+
+func TestIdempotencyClientIdentity(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{})
+	require.NoError(t, err)
+
+	calls := 0
+	backend := func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: strconv.Itoa(calls)})
+		rw.WriteHeader(http.StatusCreated)
+	}
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := testhelpers.MustNewRequest(http.MethodPost, "http://frontend/payments", nil)
+		req.Header.Set("Idempotency-Key", "a")
+		req.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		idempotency.ServeHTTP(recorder, req, backend)
+		return recorder
+	}
+
+	assert.NotEmpty(t, serve("Bearer alice").Header().Get("Set-Cookie"))
+	assert.Equal(t, 1, calls)
+	assert.NotEmpty(t, serve("Bearer bob").Header().Get("Set-Cookie"))
+	assert.Equal(t, 2, calls, "the responses of a client must not be replayed to another one")
+
+	replayed := serve("Bearer alice")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Empty(t, replayed.Header().Get("Set-Cookie"), "the cookies must not be replayed")
+}
+
+func TestIdempotencyBodyMismatch(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{MaxEntrySize: 16})
+	require.NoError(t, err)
+
+	var bodies []string
+	backend := func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		rw.WriteHeader(http.StatusCreated)
+	}
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := testhelpers.MustNewRequest(http.MethodPost, "http://frontend/payments", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "a")
+		recorder := httptest.NewRecorder()
+		idempotency.ServeHTTP(recorder, req, backend)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusCreated, serve("amount=10").Code)
+	assert.Equal(t, http.StatusCreated, serve("amount=10").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve("amount=20").Code)
+	assert.Equal(t, []string{"amount=10"}, bodies)
+
+	// The bodies larger than the maximum entry size are forwarded with their key.
+	assert.Equal(t, http.StatusCreated, serve("amount=10&currency=EUR").Code)
+	assert.Equal(t, []string{"amount=10", "amount=10&currency=EUR"}, bodies)
+}
+
+func TestIdempotencyMaxEntriesInFlight(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{MaxEntries: 1})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveIdempotent(idempotency, func(rw http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-release
+		}, "a")
+	}()
+	<-started
+
+	serveIdempotent(idempotency, func(rw http.ResponseWriter, req *http.Request) {}, "b")
+	idempotency.lock.Lock()
+	var keys []string
+	for element := idempotency.lru.Front(); element != nil; element = element.Next() {
+		keys = append(keys, strings.Fields(element.Value.(*idempotentResponse).key)[2])
+	}
+	idempotency.lock.Unlock()
+	assert.Equal(t, []string{"b", "a"}, keys, "the response in progress must not be dropped")
+
+	close(release)
+	<-done
+	serveIdempotent(idempotency, func(rw http.ResponseWriter, req *http.Request) {}, "c")
+	assert.Equal(t, 1, idempotency.lru.Len())
+}
+
+func TestIdempotencyInFlightDuplicates(t *testing.T) {
+	idempotency, err := NewIdempotency(&types.Idempotency{})
+	require.NoError(t, err)
+
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	backend := func(rw http.ResponseWriter, req *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		<-release
+		rw.Write([]byte("payment " + strconv.Itoa(int(call))))
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 3)
+	serve := func(i int, key string) {
+		defer wg.Done()
+		responses[i] = serveIdempotent(idempotency, backend, key)
+	}
+
+	wg.Add(2)
+	go serve(0, "a")
+	<-started
+	go serve(1, "b")
+	<-started
+
+	// The duplicate waits for the response to the first request of its key.
+	wg.Add(1)
+	go serve(2, "a")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		idempotency.lock.Lock()
+		waiting := strings.HasPrefix(idempotency.lru.Front().Value.(*idempotentResponse).key, "POST frontend/payments a ")
+		idempotency.lock.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls), "the in-flight duplicate should not be forwarded")
+	assert.Equal(t, responses[0].Body.String(), responses[2].Body.String())
+	assert.NotEqual(t, responses[0].Body.String(), responses[1].Body.String())
+}
+
+func TestNewIdempotencyInvalid(t *testing.T) {
+	for _, config := range []types.Idempotency{
+		{TTL: "soon"},
+		{TTL: "-1s"},
+		{MaxEntries: -1},
+		{MaxEntrySize: -1},
+	} {
+		_, err := NewIdempotency(&config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
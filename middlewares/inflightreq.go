@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+// InFlightReqGlobalExtractor is the extractor putting all the requests in the same bucket.
+const InFlightReqGlobalExtractor = "global"
+
+// InFlightReq is a middleware limiting the number of requests processed simultaneously,
+// whatever the connections they are received on, per bucket of requests given by its extractor.
+type InFlightReq struct {
+	amount    int64
+	extractor utils.SourceExtractor
+	lock      sync.Mutex
+	inFlight  map[string]int64
+	rejection *types.RejectionResponse
+}
+
+// NewInFlightReq builds a new InFlightReq allowing amount simultaneous requests per bucket.
+// The extractor is "global", "client.ip" or "request.header.<name>"; the client IP is taken from
+// X-Forwarded-For only for requests coming from a proxy trusted by proxyChecker.
+// The responses of the requests rejected over the limit are overridden by rejection, if any.
+func NewInFlightReq(amount int64, extractorFunc string, proxyChecker *types.ProxyChecker, rejection *types.RejectionResponse) (*InFlightReq, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("maximum amount of in-flight requests must be positive, got %d", amount)
+	}
+
+	var extractor utils.SourceExtractor
+	switch extractorFunc {
+	case "", InFlightReqGlobalExtractor:
+		extractor = utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			return InFlightReqGlobalExtractor, 1, nil
+		})
+	case "client.ip":
+		extractor = utils.ExtractorFunc(func(req *http.Request) (string, int64, error) {
+			return proxyChecker.ClientIP(req), 1, nil
+		})
+	default:
+		var err error
+		extractor, err = utils.NewExtractor(extractorFunc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &InFlightReq{
+		amount:    amount,
+		extractor: extractor,
+		inFlight:  make(map[string]int64),
+		rejection: rejection,
+	}, nil
+}
+
+func (i *InFlightReq) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	source, _, err := i.extractor.Extract(r)
+	if err != nil {
+		log.Errorf("Error extracting the in-flight requests source: %v", err)
+		if ProblemDetailsEnabled(r) {
+			WriteProblem(w, http.StatusInternalServerError, ProblemTypeInternalError, "Error extracting the in-flight requests source")
+			return
+		}
+		http.Error(w, "Error extracting the in-flight requests source", http.StatusInternalServerError)
+		return
+	}
+
+	if !i.acquire(source) {
+		log.Debugf("Maximum amount of %d in-flight requests reached for %q", i.amount, source)
+		reject(w, r, i.rejection, func(w http.ResponseWriter) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		})
+		return
+	}
+	defer i.release(source)
+
+	next.ServeHTTP(w, r)
+}
+
+func (i *InFlightReq) acquire(source string) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.inFlight[source] >= i.amount {
+		return false
+	}
+	i.inFlight[source]++
+	return true
+}
+
+func (i *InFlightReq) release(source string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.inFlight[source]--
+	if i.inFlight[source] == 0 {
+		delete(i.inFlight, source)
+	}
+}
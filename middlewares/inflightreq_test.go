@@ -0,0 +1,148 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestInFlightReq(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		extractorFunc      string
+		otherBucketRequest func(req *http.Request)
+	}{
+		{
+			desc:          "global",
+			extractorFunc: "global",
+		},
+		{
+			desc:          "client IP",
+			extractorFunc: "client.ip",
+			otherBucketRequest: func(req *http.Request) {
+				req.RemoteAddr = "10.0.0.2:1234"
+			},
+		},
+		{
+			desc:          "header",
+			extractorFunc: "request.header.X-Client",
+			otherBucketRequest: func(req *http.Request) {
+				req.Header.Set("X-Client", "other")
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			const amount = 3
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/block" {
+					started <- struct{}{}
+					<-release
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			inFlightReq, err := NewInFlightReq(amount, test.extractorFunc, nil, nil)
+			require.NoError(t, err)
+			n := negroni.New(inFlightReq)
+			n.UseHandler(handler)
+
+			newRequest := func(path string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+				req.RemoteAddr = "10.0.0.1:1234"
+				req.Header.Set("X-Client", "client")
+				return req
+			}
+			serve := func(req *http.Request) int {
+				recorder := httptest.NewRecorder()
+				n.ServeHTTP(recorder, req)
+				return recorder.Code
+			}
+
+			// Saturate the limit with blocked requests.
+			var wg sync.WaitGroup
+			blockedCodes := make(chan int, amount)
+			for i := 0; i < amount; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					blockedCodes <- serve(newRequest("/block"))
+				}()
+			}
+			for i := 0; i < amount; i++ {
+				<-started
+			}
+
+			assert.Equal(t, http.StatusTooManyRequests, serve(newRequest("/")), "excess request")
+			if test.otherBucketRequest != nil {
+				req := newRequest("/")
+				test.otherBucketRequest(req)
+				assert.Equal(t, http.StatusOK, serve(req), "request of another bucket")
+			}
+
+			close(release)
+			wg.Wait()
+			close(blockedCodes)
+			for code := range blockedCodes {
+				assert.Equal(t, http.StatusOK, code)
+			}
+
+			assert.Equal(t, http.StatusOK, serve(newRequest("/")), "request once the limit is recovered")
+		})
+	}
+}
+
+func TestNewInFlightReqErrors(t *testing.T) {
+	_, err := NewInFlightReq(0, "global", nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewInFlightReq(10, "unknown", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestInFlightReqRejection(t *testing.T) {
+	rejection := &types.RejectionResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       "busy",
+		Headers:    map[string]string{"Retry-After": "10"},
+	}
+	inFlightReq, err := NewInFlightReq(1, "global", nil, rejection)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	n := negroni.New(inFlightReq)
+	n.UseHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+		close(done)
+	}()
+	<-started
+
+	recorder := httptest.NewRecorder()
+	n.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	close(release)
+	<-done
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "busy", recorder.Body.String())
+	assert.Equal(t, "10", recorder.Header().Get("Retry-After"))
+}
@@ -6,24 +6,29 @@ import (
 	"net/http"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
 	"github.com/pkg/errors"
 	"github.com/urfave/negroni"
 )
 
 // IPWhitelister is a middleware that provides Checks of the Requesting IP against a set of Whitelists
 type IPWhitelister struct {
-	handler    negroni.Handler
-	whitelists []*net.IPNet
+	handler      negroni.Handler
+	whitelists   []*net.IPNet
+	proxyChecker *types.ProxyChecker
+	rejection    *types.RejectionResponse
 }
 
-// NewIPWhitelister builds a new IPWhitelister given a list of CIDR-Strings to whitelist
-func NewIPWhitelister(whitelistStrings []string) (*IPWhitelister, error) {
+// NewIPWhitelister builds a new IPWhitelister given a list of CIDR-Strings to whitelist.
+// The client IP is taken from X-Forwarded-For only for requests coming from a proxy trusted by proxyChecker.
+// The responses of the rejected requests are overridden by rejection, if any.
+func NewIPWhitelister(whitelistStrings []string, proxyChecker *types.ProxyChecker, rejection *types.RejectionResponse) (*IPWhitelister, error) {
 
 	if len(whitelistStrings) == 0 {
 		return nil, errors.New("no whitelists provided")
 	}
 
-	whitelister := IPWhitelister{}
+	whitelister := IPWhitelister{proxyChecker: proxyChecker, rejection: rejection}
 
 	for _, whitelistString := range whitelistStrings {
 		_, whitelist, err := net.ParseCIDR(whitelistString)
@@ -40,15 +45,16 @@ func NewIPWhitelister(whitelistStrings []string) (*IPWhitelister, error) {
 }
 
 func (whitelister *IPWhitelister) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	remoteIP, err := ipFromRemoteAddr(r.RemoteAddr)
-	if err != nil {
+	clientIP := whitelister.proxyChecker.ClientIP(r)
+	remoteIP := net.ParseIP(clientIP)
+	if remoteIP == nil {
 		log.Warnf("unable to parse remote-address from header: %s - rejecting", r.RemoteAddr)
-		reject(w)
+		reject(w, r, whitelister.rejection, forbid)
 		return
 	}
 
 	for _, whitelist := range whitelister.whitelists {
-		if whitelist.Contains(*remoteIP) {
+		if whitelist.Contains(remoteIP) {
 			log.Debugf("source-IP %s matched whitelist %s - passing", remoteIP, whitelist)
 			next.ServeHTTP(w, r)
 			return
@@ -56,31 +62,17 @@ func (whitelister *IPWhitelister) handle(w http.ResponseWriter, r *http.Request,
 	}
 
 	log.Debugf("source-IP %s matched none of the whitelists - rejecting", remoteIP)
-	reject(w)
+	reject(w, r, whitelister.rejection, forbid)
 	return
 }
 
-func reject(w http.ResponseWriter) {
+func forbid(w http.ResponseWriter) {
 	statusCode := http.StatusForbidden
 
 	w.WriteHeader(statusCode)
 	w.Write([]byte(http.StatusText(statusCode)))
 }
 
-func ipFromRemoteAddr(addr string) (*net.IP, error) {
-	ip, _, err := net.SplitHostPort(addr)
-	if err != nil {
-		return nil, fmt.Errorf("can't extract IP/Port from address %s: %s", addr, err)
-	}
-
-	userIP := net.ParseIP(ip)
-	if userIP == nil {
-		return nil, fmt.Errorf("can't parse IP from address %s", ip)
-	}
-
-	return &userIP, nil
-}
-
 func (whitelister *IPWhitelister) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	whitelister.handler.ServeHTTP(rw, r, next)
 }
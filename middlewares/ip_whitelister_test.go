@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/negroni"
@@ -80,7 +81,7 @@ func TestNewIPWhitelister(t *testing.T) {
 		test := test
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
-			whitelister, err := NewIPWhitelister(test.whitelistStrings)
+			whitelister, err := NewIPWhitelister(test.whitelistStrings, nil, nil)
 			if test.errMessage != "" {
 				require.EqualError(t, err, test.errMessage)
 			} else {
@@ -270,7 +271,7 @@ func TestIPWhitelisterHandle(t *testing.T) {
 		test := test
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
-			whitelister, err := NewIPWhitelister(test.whitelistStrings)
+			whitelister, err := NewIPWhitelister(test.whitelistStrings, nil, nil)
 
 			require.NoError(t, err)
 			require.NotNil(t, whitelister)
@@ -305,3 +306,81 @@ func TestIPWhitelisterHandle(t *testing.T) {
 		})
 	}
 }
+
+func TestIPWhitelisterHandleWithTrustedProxies(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	whitelister, err := NewIPWhitelister([]string{"1.2.3.0/24", "10.0.0.0/8"}, proxyChecker, nil)
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc           string
+		remoteAddr     string
+		xForwardedFor  string
+		expectedStatus int
+	}{
+		{
+			desc:           "whitelisted client behind a trusted proxy",
+			remoteAddr:     "10.0.0.1:2342",
+			xForwardedFor:  "1.2.3.4",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "not whitelisted client behind a trusted proxy",
+			remoteAddr:     "10.0.0.1:2342",
+			xForwardedFor:  "8.8.8.8",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			desc:           "untrusted source forging X-Forwarded-For",
+			remoteAddr:     "8.8.8.8:2342",
+			xForwardedFor:  "1.2.3.4",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range cases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			n := negroni.New(whitelister)
+			n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "traefik")
+			}))
+
+			req := testhelpers.MustNewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Header.Set("X-Forwarded-For", test.xForwardedFor)
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestIPWhitelisterRejection(t *testing.T) {
+	rejection := &types.RejectionResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       "nothing here",
+		Headers:    map[string]string{"X-Rejected-By": "whitelist"},
+	}
+	whitelister, err := NewIPWhitelister([]string{"1.2.3.0/24"}, nil, rejection)
+	require.NoError(t, err)
+
+	n := negroni.New(whitelister)
+	n.UseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "traefik")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	req.RemoteAddr = "8.8.8.8:2342"
+	recorder := httptest.NewRecorder()
+	n.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "nothing here", recorder.Body.String())
+	assert.Equal(t, "whitelist", recorder.Header().Get("X-Rejected-By"))
+}
@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+// The policies applied to the requests exceeding the maximum connections of a backend.
+const (
+	MaxConnPolicyFailFast = "failfast"
+	MaxConnPolicyQueue    = "queue"
+)
+
+// MaxConnLimiter limits the connections to a backend per source given by its extractor.
+// With the failfast policy, the requests over the limit are rejected at once with 503 Service Unavailable.
+// With the queue policy, they wait for a connection to be released, and are rejected the same way
+// when the queue of their source is full or when they waited longer than the queue timeout.
+type MaxConnLimiter struct {
+	next         http.Handler
+	extractor    utils.SourceExtractor
+	amount       int64
+	queue        bool
+	queueSize    int64
+	queueTimeout time.Duration
+	lock         sync.Mutex
+	sources      map[string]*connSemaphore
+}
+
+type connSemaphore struct {
+	slots   chan struct{}
+	waiting int64
+	users   int64
+}
+
+// NewMaxConnLimiter creates a new MaxConnLimiter applying the policy of maxConn to the requests of next.
+// An empty queue size or queue timeout means no limit.
+// The responses of the requests rejected over the limit are overridden by their rejection, if any, see NewMaxConnRejection.
+func NewMaxConnLimiter(next http.Handler, extractor utils.SourceExtractor, maxConn *types.MaxConn) (*MaxConnLimiter, error) {
+	if maxConn.Amount <= 0 {
+		return nil, fmt.Errorf("maximum amount of connections must be positive, got %d", maxConn.Amount)
+	}
+	if maxConn.Policy != MaxConnPolicyFailFast && maxConn.Policy != MaxConnPolicyQueue {
+		return nil, fmt.Errorf("unknown max connections policy %q", maxConn.Policy)
+	}
+	if maxConn.QueueSize < 0 {
+		return nil, fmt.Errorf("max connections queue size must not be negative, got %d", maxConn.QueueSize)
+	}
+
+	var queueTimeout time.Duration
+	if len(maxConn.QueueTimeout) > 0 {
+		var err error
+		queueTimeout, err = time.ParseDuration(maxConn.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max connections queue timeout %q: %v", maxConn.QueueTimeout, err)
+		}
+	}
+
+	return &MaxConnLimiter{
+		next:         next,
+		extractor:    extractor,
+		amount:       maxConn.Amount,
+		queue:        maxConn.Policy == MaxConnPolicyQueue,
+		queueSize:    maxConn.QueueSize,
+		queueTimeout: queueTimeout,
+		sources:      make(map[string]*connSemaphore),
+	}, nil
+}
+
+func (m *MaxConnLimiter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	source, _, err := m.extractor.Extract(req)
+	if err != nil {
+		log.Errorf("Error extracting the max connections source: %v", err)
+		if ProblemDetailsEnabled(req) {
+			WriteProblem(rw, http.StatusInternalServerError, ProblemTypeInternalError, "Error extracting the max connections source")
+			return
+		}
+		http.Error(rw, "Error extracting the max connections source", http.StatusInternalServerError)
+		return
+	}
+
+	semaphore := m.enter(source)
+	defer m.leave(source, semaphore)
+
+	if !m.acquire(req, semaphore) {
+		log.Debugf("Maximum amount of %d connections reached for %q", m.amount, source)
+		reject(rw, req, maxConnRejection(req), func(rw http.ResponseWriter) {
+			http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		})
+		return
+	}
+	defer func() { <-semaphore.slots }()
+
+	m.next.ServeHTTP(rw, req)
+}
+
+// enter returns the semaphore of the source, kept until all its users left.
+func (m *MaxConnLimiter) enter(source string) *connSemaphore {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	semaphore, ok := m.sources[source]
+	if !ok {
+		semaphore = &connSemaphore{slots: make(chan struct{}, m.amount)}
+		m.sources[source] = semaphore
+	}
+	semaphore.users++
+	return semaphore
+}
+
+func (m *MaxConnLimiter) leave(source string, semaphore *connSemaphore) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	semaphore.users--
+	if semaphore.users == 0 {
+		delete(m.sources, source)
+	}
+}
+
+// acquire takes a connection of the semaphore, waiting for one in the queue with the queue policy.
+func (m *MaxConnLimiter) acquire(req *http.Request, semaphore *connSemaphore) bool {
+	select {
+	case semaphore.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if !m.queue {
+		return false
+	}
+
+	m.lock.Lock()
+	if m.queueSize > 0 && semaphore.waiting >= m.queueSize {
+		m.lock.Unlock()
+		return false
+	}
+	semaphore.waiting++
+	m.lock.Unlock()
+
+	defer func() {
+		m.lock.Lock()
+		semaphore.waiting--
+		m.lock.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if m.queueTimeout > 0 {
+		timer := time.NewTimer(m.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case semaphore.slots <- struct{}{}:
+		return true
+	case <-timeout:
+		return false
+	case <-req.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/utils"
+)
+
+func TestMaxConnLimiter(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		maxConn        *types.MaxConn
+		releaseAfter   time.Duration
+		expectedStatus int
+	}{
+		{
+			desc:           "fail fast",
+			maxConn:        &types.MaxConn{Amount: 1, Policy: MaxConnPolicyFailFast},
+			releaseAfter:   50 * time.Millisecond,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			desc:           "queued until a connection is released",
+			maxConn:        &types.MaxConn{Amount: 1, Policy: MaxConnPolicyQueue, QueueTimeout: "5s"},
+			releaseAfter:   50 * time.Millisecond,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "queued longer than the timeout",
+			maxConn:        &types.MaxConn{Amount: 1, Policy: MaxConnPolicyQueue, QueueTimeout: "50ms"},
+			releaseAfter:   time.Second,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/block" {
+					started <- struct{}{}
+					<-release
+				}
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			extractor, err := utils.NewExtractor("request.host")
+			require.NoError(t, err)
+			limiter, err := NewMaxConnLimiter(handler, extractor, test.maxConn)
+			require.NoError(t, err)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+			}()
+			<-started
+
+			timer := time.AfterFunc(test.releaseAfter, func() { close(release) })
+			defer timer.Stop()
+
+			recorder := httptest.NewRecorder()
+			limiter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+
+			// Another source is not limited by the saturated one.
+			if test.expectedStatus != http.StatusOK {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Host = "other.bar"
+				recorder = httptest.NewRecorder()
+				limiter.ServeHTTP(recorder, req)
+				assert.Equal(t, http.StatusOK, recorder.Code)
+			}
+
+			wg.Wait()
+			assert.Empty(t, limiter.sources)
+		})
+	}
+}
+
+func TestMaxConnLimiterQueueSize(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/block" {
+			started <- struct{}{}
+			<-release
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	extractor, err := utils.NewExtractor("request.host")
+	require.NoError(t, err)
+	limiter, err := NewMaxConnLimiter(handler, extractor, &types.MaxConn{Amount: 1, Policy: MaxConnPolicyQueue, QueueSize: 1})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		limiter.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+	}()
+	<-started
+
+	queued := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		limiter.ServeHTTP(queued, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	for queueLength := int64(0); queueLength != 1; {
+		time.Sleep(time.Millisecond)
+		limiter.lock.Lock()
+		if semaphore := limiter.sources["example.com"]; semaphore != nil {
+			queueLength = semaphore.waiting
+		}
+		limiter.lock.Unlock()
+	}
+
+	recorder := httptest.NewRecorder()
+	limiter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, http.StatusOK, queued.Code)
+}
+
+func TestNewMaxConnLimiterInvalid(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		maxConn *types.MaxConn
+	}{
+		{desc: "unknown policy", maxConn: &types.MaxConn{Amount: 1, Policy: "drop"}},
+		{desc: "no amount", maxConn: &types.MaxConn{Policy: MaxConnPolicyFailFast}},
+		{desc: "negative queue size", maxConn: &types.MaxConn{Amount: 1, Policy: MaxConnPolicyQueue, QueueSize: -1}},
+		{desc: "invalid queue timeout", maxConn: &types.MaxConn{Amount: 1, Policy: MaxConnPolicyQueue, QueueTimeout: "soon"}},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			extractor, err := utils.NewExtractor("client.ip")
+			require.NoError(t, err)
+			_, err = NewMaxConnLimiter(http.NotFoundHandler(), extractor, test.maxConn)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,6 +15,8 @@ import (
 type Metrics interface {
 	getReqsCounter() metrics.Counter
 	getReqDurationHistogram() metrics.Histogram
+	getReqSizeHistogram() metrics.Histogram
+	getRespSizeHistogram() metrics.Histogram
 	RetryMetrics
 }
 
@@ -28,6 +31,8 @@ type MultiMetrics struct {
 	wrappedMetrics       *[]Metrics
 	reqsCounter          metrics.Counter
 	reqDurationHistogram metrics.Histogram
+	reqSizeHistogram     metrics.Histogram
+	respSizeHistogram    metrics.Histogram
 	retryCounter         metrics.Counter
 }
 
@@ -35,11 +40,15 @@ type MultiMetrics struct {
 func NewMultiMetrics(manyMetrics []Metrics) *MultiMetrics {
 	counters := []metrics.Counter{}
 	histograms := []metrics.Histogram{}
+	reqSizeHistograms := []metrics.Histogram{}
+	respSizeHistograms := []metrics.Histogram{}
 	retryCounters := []metrics.Counter{}
 
 	for _, m := range manyMetrics {
 		counters = append(counters, m.getReqsCounter())
 		histograms = append(histograms, m.getReqDurationHistogram())
+		reqSizeHistograms = append(reqSizeHistograms, m.getReqSizeHistogram())
+		respSizeHistograms = append(respSizeHistograms, m.getRespSizeHistogram())
 		retryCounters = append(retryCounters, m.getRetryCounter())
 	}
 
@@ -48,6 +57,8 @@ func NewMultiMetrics(manyMetrics []Metrics) *MultiMetrics {
 	mm.wrappedMetrics = &manyMetrics
 	mm.reqsCounter = multi.NewCounter(counters...)
 	mm.reqDurationHistogram = multi.NewHistogram(histograms...)
+	mm.reqSizeHistogram = multi.NewHistogram(reqSizeHistograms...)
+	mm.respSizeHistogram = multi.NewHistogram(respSizeHistograms...)
 	mm.retryCounter = multi.NewCounter(retryCounters...)
 
 	return &mm
@@ -61,6 +72,14 @@ func (mm *MultiMetrics) getReqDurationHistogram() metrics.Histogram {
 	return mm.reqDurationHistogram
 }
 
+func (mm *MultiMetrics) getReqSizeHistogram() metrics.Histogram {
+	return mm.reqSizeHistogram
+}
+
+func (mm *MultiMetrics) getRespSizeHistogram() metrics.Histogram {
+	return mm.respSizeHistogram
+}
+
 func (mm *MultiMetrics) getRetryCounter() metrics.Counter {
 	return mm.retryCounter
 }
@@ -87,7 +106,11 @@ func NewMetricsWrapper(impl Metrics) *MetricsWrapper {
 
 func (m *MetricsWrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	start := time.Now()
-	prw := &responseRecorder{rw, http.StatusOK}
+	reqBody := &countingReadCloser{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = reqBody
+	}
+	prw := &sizeResponseRecorder{responseRecorder: &responseRecorder{rw, http.StatusOK}}
 	next(prw, r)
 
 	reqLabels := []string{"code", strconv.Itoa(prw.statusCode), "method", r.Method}
@@ -95,6 +118,35 @@ func (m *MetricsWrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request, next
 
 	reqDurationLabels := []string{"code", strconv.Itoa(prw.statusCode)}
 	m.Impl.getReqDurationHistogram().With(reqDurationLabels...).Observe(float64(time.Since(start).Seconds()))
+
+	m.Impl.getReqSizeHistogram().With("method", r.Method).Observe(float64(reqBody.size))
+	m.Impl.getRespSizeHistogram().With("code", strconv.Itoa(prw.statusCode)).Observe(float64(prw.size))
+}
+
+// countingReadCloser counts the bytes read from the wrapped request body
+// without buffering it.
+type countingReadCloser struct {
+	io.ReadCloser
+	size int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.size += int64(n)
+	return n, err
+}
+
+// sizeResponseRecorder counts the bytes written to the response body on top of
+// capturing the status code.
+type sizeResponseRecorder struct {
+	*responseRecorder
+	size int64
+}
+
+func (r *sizeResponseRecorder) Write(b []byte) (int, error) {
+	n, err := r.responseRecorder.Write(b)
+	r.size += int64(n)
+	return n, err
 }
 
 // MetricsRetryListener is an implementation of the RetryListener interface to
@@ -114,3 +166,28 @@ func (m *MetricsRetryListener) Retried(attempt int) {
 func NewMetricsRetryListener(retryMetrics RetryMetrics) RetryListener {
 	return &MetricsRetryListener{retryMetrics: retryMetrics}
 }
+
+// CounterRetryListener is an implementation of the RetryListener interface counting the retry attempts.
+type CounterRetryListener struct {
+	counter metrics.Counter
+}
+
+// Retried adds the retry to the counter.
+func (c *CounterRetryListener) Retried(attempt int) {
+	c.counter.Add(1)
+}
+
+// NewCounterRetryListener instantiates a CounterRetryListener with the given counter.
+func NewCounterRetryListener(counter metrics.Counter) RetryListener {
+	return &CounterRetryListener{counter: counter}
+}
+
+// RetryListeners is an implementation of the RetryListener interface informing several listeners.
+type RetryListeners []RetryListener
+
+// Retried informs the listeners of the retry.
+func (l RetryListeners) Retried(attempt int) {
+	for _, listener := range l {
+		listener.Retried(attempt)
+	}
+}
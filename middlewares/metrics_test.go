@@ -1,9 +1,17 @@
 package middlewares
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/containous/traefik/testhelpers"
 	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
 )
 
 func TestMetricsRetryListener(t *testing.T) {
@@ -22,6 +30,39 @@ func TestMetricsRetryListener(t *testing.T) {
 	}
 }
 
+func TestCounterRetryListeners(t *testing.T) {
+	retryMetrics := newCollectingMetrics()
+	counter := &collectingCounter{}
+	retryListener := RetryListeners{NewMetricsRetryListener(retryMetrics), NewCounterRetryListener(counter)}
+	retryListener.Retried(1)
+	retryListener.Retried(2)
+
+	assert.Equal(t, float64(2), retryMetrics.retryCounter.counterValue)
+	assert.Equal(t, float64(2), counter.counterValue)
+}
+
+func TestMetricsWrapperBodySizes(t *testing.T) {
+	sizeMetrics := newCollectingSizeMetrics()
+
+	n := negroni.New()
+	n.Use(NewMetricsWrapper(sizeMetrics))
+	n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("0123456789"))
+		rw.Write([]byte("01234"))
+	})
+
+	req := testhelpers.MustNewRequest(http.MethodPost, "http://localhost/", strings.NewReader(strings.Repeat("a", 42)))
+	n.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"method", http.MethodPost}, sizeMetrics.reqSizeHistogram.labels)
+	assert.Equal(t, []float64{42}, sizeMetrics.reqSizeHistogram.observations)
+	assert.Equal(t, []string{"code", "201"}, sizeMetrics.respSizeHistogram.labels)
+	assert.Equal(t, []float64{15}, sizeMetrics.respSizeHistogram.observations)
+}
+
 // collectingRetryMetrics is an implementation of the RetryMetrics interface that can be used inside tests to collect the times Add() was called.
 type collectingRetryMetrics struct {
 	retryCounter *collectingCounter
@@ -46,3 +87,57 @@ func (c *collectingCounter) With(labelValues ...string) metrics.Counter {
 func (c *collectingCounter) Add(delta float64) {
 	c.counterValue += delta
 }
+
+// collectingSizeMetrics is an implementation of the Metrics interface that can be used inside tests to collect the body size observations.
+type collectingSizeMetrics struct {
+	collectingRetryMetrics
+	reqSizeHistogram  *collectingHistogram
+	respSizeHistogram *collectingHistogram
+}
+
+func newCollectingSizeMetrics() collectingSizeMetrics {
+	return collectingSizeMetrics{
+		collectingRetryMetrics: newCollectingMetrics(),
+		reqSizeHistogram:       &collectingHistogram{},
+		respSizeHistogram:      &collectingHistogram{},
+	}
+}
+
+func (metrics collectingSizeMetrics) getReqsCounter() metrics.Counter {
+	return nopCounter{}
+}
+
+func (metrics collectingSizeMetrics) getReqDurationHistogram() metrics.Histogram {
+	return &collectingHistogram{}
+}
+
+func (metrics collectingSizeMetrics) getReqSizeHistogram() metrics.Histogram {
+	return metrics.reqSizeHistogram
+}
+
+func (metrics collectingSizeMetrics) getRespSizeHistogram() metrics.Histogram {
+	return metrics.respSizeHistogram
+}
+
+// collectingHistogram records the labels and values it was given.
+type collectingHistogram struct {
+	labels       []string
+	observations []float64
+}
+
+func (h *collectingHistogram) With(labelValues ...string) metrics.Histogram {
+	h.labels = append(h.labels, labelValues...)
+	return h
+}
+
+func (h *collectingHistogram) Observe(value float64) {
+	h.observations = append(h.observations, value)
+}
+
+type nopCounter struct{}
+
+func (c nopCounter) With(labelValues ...string) metrics.Counter {
+	return c
+}
+
+func (c nopCounter) Add(delta float64) {}
@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// DefaultMirrorMaxBodySize is the size of the largest request body mirrored, in bytes, when not configured.
+	DefaultMirrorMaxBodySize = 1 << 20
+	// MirrorMaxInFlight is the number of mirrored requests processed simultaneously, over which the requests are not mirrored.
+	MirrorMaxInFlight = 100
+)
+
+// Mirror forwards the requests to its handler, and duplicates a percentage of them to a mirror handler, asynchronously.
+// The responses of the mirror are discarded, and its failures never affect the responses of the handler.
+// The body of the mirrored requests is buffered in memory, up to a maximum size over which they are not mirrored,
+// as are the websocket upgrades and the requests received while too many mirrored ones are still in flight.
+type Mirror struct {
+	handler     http.Handler
+	mirror      http.Handler
+	percent     int
+	maxBodySize int64
+	inFlight    chan struct{}
+
+	lock  sync.Mutex
+	count int
+}
+
+// NewMirror builds a new Mirror duplicating the requests of handler to mirror as configured.
+func NewMirror(handler, mirror http.Handler, config *types.Mirror) *Mirror {
+	maxBodySize := config.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMirrorMaxBodySize
+	}
+	return &Mirror{
+		handler:     handler,
+		mirror:      mirror,
+		percent:     config.Percent,
+		maxBodySize: maxBodySize,
+		inFlight:    make(chan struct{}, MirrorMaxInFlight),
+	}
+}
+
+func (m *Mirror) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if !m.isMirrored() || strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		m.handler.ServeHTTP(rw, req)
+		return
+	}
+
+	var body []byte
+	if req.Body != nil && req.ContentLength != 0 {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, m.maxBodySize+1))
+		if err != nil || int64(len(body)) > m.maxBodySize {
+			log.Debugf("Not mirroring request %s %s, its body is larger than %d bytes", req.Method, req.URL, m.maxBodySize)
+			req.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			m.handler.ServeHTTP(rw, req)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	select {
+	case m.inFlight <- struct{}{}:
+		mirrorReq := m.copyRequest(req, body)
+		go func() {
+			defer func() {
+				<-m.inFlight
+				if err := recover(); err != nil {
+					log.Errorf("Error mirroring request %s %s: %v", mirrorReq.Method, mirrorReq.URL, err)
+				}
+			}()
+			m.mirror.ServeHTTP(newDiscardResponseWriter(), mirrorReq)
+		}()
+	default:
+		log.Debugf("Not mirroring request %s %s, %d mirrored requests are in flight", req.Method, req.URL, MirrorMaxInFlight)
+	}
+	m.handler.ServeHTTP(rw, req)
+}
+
+// isMirrored returns whether the next request is mirrored, spreading the percentage evenly over the requests.
+func (m *Mirror) isMirrored() bool {
+	if m.percent <= 0 {
+		return false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.count = m.count%100 + 1
+	return m.count*m.percent/100 > (m.count-1)*m.percent/100
+}
+
+// copyRequest copies the request for the mirror, detached from the cancellation of the original one.
+func (m *Mirror) copyRequest(req *http.Request, body []byte) *http.Request {
+	mirrorReq := req.WithContext(detachedContext{req.Context()})
+	mirrorReq.URL = utils.CopyURL(req.URL)
+	mirrorReq.Header = make(http.Header, len(req.Header))
+	utils.CopyHeaders(mirrorReq.Header, req.Header)
+	mirrorReq.Body = http.NoBody
+	if body != nil {
+		mirrorReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return mirrorReq
+}
+
+// detachedContext keeps the values of its parent context, without its deadline and cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// multiReadCloser reads the body of a request partially read, for mirroring or signing it.
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// discardResponseWriter discards the responses of the mirror.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: make(http.Header)}
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(code int) {}
+
+// Flush does nothing, the response being discarded.
+func (w *discardResponseWriter) Flush() {}
+
+// CloseNotify returns a channel never receiving, the mirror having no client.
+func (w *discardResponseWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mirrorBackend records the bodies of the requests it receives.
+type mirrorBackend struct {
+	lock   sync.Mutex
+	bodies []string
+}
+
+func (b *mirrorBackend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	b.lock.Lock()
+	b.bodies = append(b.bodies, string(body))
+	b.lock.Unlock()
+	rw.WriteHeader(http.StatusInternalServerError)
+	rw.Write([]byte("mirror"))
+}
+
+// waitBodies waits for the backend to receive count requests, and returns their bodies.
+func (b *mirrorBackend) waitBodies(t *testing.T, count int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.lock.Lock()
+		bodies := append([]string(nil), b.bodies...)
+		b.lock.Unlock()
+		if len(bodies) >= count || time.Now().After(deadline) {
+			return bodies
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func primaryHandler(t *testing.T, expectedBody string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedBody, string(body))
+		rw.Write([]byte("primary"))
+	})
+}
+
+func TestMirrorPercent(t *testing.T) {
+	mirror := &mirrorBackend{}
+	handler := NewMirror(primaryHandler(t, ""), mirror, &types.Mirror{Backend: "mirror", Percent: 25})
+
+	for i := 0; i < 100; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "primary", recorder.Body.String())
+	}
+
+	assert.Len(t, mirror.waitBodies(t, 25), 25)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, mirror.waitBodies(t, 0), 25, "the mirror must receive exactly its share")
+}
+
+func TestMirrorBody(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		body           string
+		maxBodySize    int64
+		expectedBodies []string
+	}{
+		{
+			desc:           "buffered body",
+			body:           "payload",
+			expectedBodies: []string{"payload"},
+		},
+		{
+			desc:           "body over the maximum size",
+			body:           "payload",
+			maxBodySize:    4,
+			expectedBodies: nil,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			mirror := &mirrorBackend{}
+			handler := NewMirror(primaryHandler(t, test.body), mirror, &types.Mirror{Backend: "mirror", Percent: 100, MaxBodySize: test.maxBodySize})
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body)))
+			assert.Equal(t, "primary", recorder.Body.String())
+
+			if test.expectedBodies == nil {
+				time.Sleep(50 * time.Millisecond)
+				assert.Empty(t, mirror.waitBodies(t, 0))
+				return
+			}
+			assert.Equal(t, test.expectedBodies, mirror.waitBodies(t, len(test.expectedBodies)))
+		})
+	}
+}
+
+func TestMirrorFailure(t *testing.T) {
+	released := make(chan struct{})
+	mirror := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		<-released
+		panic("mirror failure")
+	})
+	handler := NewMirror(primaryHandler(t, ""), mirror, &types.Mirror{Backend: "mirror", Percent: 100})
+
+	// The slow and failing mirror neither delays nor alters the responses.
+	for i := 0; i < MirrorMaxInFlight+10; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "primary", recorder.Body.String())
+	}
+	close(released)
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/urfave/negroni"
+)
+
+// PathRedirectRule redirects the requests whose path is under its prefix with its handler.
+type PathRedirectRule struct {
+	PathPrefix string
+	Handler    negroni.Handler
+}
+
+// PathRedirect redirects the requests with the handler of the first rule matching their path,
+// or with its default handler when no rule matches.
+// Without default handler, the requests matching no rule are forwarded to the next handler.
+type PathRedirect struct {
+	rules          []PathRedirectRule
+	defaultHandler negroni.Handler
+}
+
+// NewPathRedirect creates a new PathRedirect, the rules being matched in order.
+func NewPathRedirect(rules []PathRedirectRule, defaultHandler negroni.Handler) *PathRedirect {
+	return &PathRedirect{rules: rules, defaultHandler: defaultHandler}
+}
+
+func (p *PathRedirect) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	for _, rule := range p.rules {
+		if matchesPathPrefix(r.URL.Path, rule.PathPrefix) {
+			rule.Handler.ServeHTTP(rw, r, next)
+			return
+		}
+	}
+	if p.defaultHandler != nil {
+		p.defaultHandler.ServeHTTP(rw, r, next)
+		return
+	}
+	next(rw, r)
+}
+
+// matchesPathPrefix checks if the path is the prefix or under it, "/api" matching "/api/users" but not "/apiv2".
+func matchesPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/negroni"
+)
+
+func namedRedirect(name string) negroni.Handler {
+	return negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		rw.Header().Set("X-Redirect", name)
+	})
+}
+
+func TestPathRedirect(t *testing.T) {
+	rules := []PathRedirectRule{
+		{PathPrefix: "/api/v1", Handler: namedRedirect("v1")},
+		{PathPrefix: "/api/", Handler: namedRedirect("api")},
+	}
+
+	testCases := []struct {
+		desc             string
+		defaultHandler   negroni.Handler
+		path             string
+		expectedRedirect string
+	}{
+		{desc: "first matching rule", path: "/api/v1/users", expectedRedirect: "v1"},
+		{desc: "exact prefix", path: "/api", expectedRedirect: "api"},
+		{desc: "other rule", path: "/api/v2", expectedRedirect: "api"},
+		{desc: "prefix within a segment", path: "/apiv2", defaultHandler: namedRedirect("default"), expectedRedirect: "default"},
+		{desc: "no rule nor default", path: "/users", expectedRedirect: "next"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			n := negroni.New(NewPathRedirect(rules, test.defaultHandler))
+			n.UseHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("X-Redirect", "next")
+			})
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+			assert.Equal(t, test.expectedRedirect, recorder.Header().Get("X-Redirect"))
+		})
+	}
+}
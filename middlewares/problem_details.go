@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/urfave/negroni"
+)
+
+// The types of the problem details written for the error responses generated by Traefik.
+const (
+	ProblemTypeNotFound          = "urn:traefik:problem:not-found"
+	ProblemTypeNoAvailableServer = "urn:traefik:problem:no-available-server"
+	ProblemTypeCircuitOpen       = "urn:traefik:problem:circuit-open"
+	ProblemTypeBadGateway        = "urn:traefik:problem:bad-gateway"
+	ProblemTypeGatewayTimeout    = "urn:traefik:problem:gateway-timeout"
+	ProblemTypeRejected          = "urn:traefik:problem:rejected"
+	ProblemTypeInternalError     = "urn:traefik:problem:internal-error"
+	ProblemTypeRequestTooLarge   = "urn:traefik:problem:request-too-large"
+)
+
+type problemDetailsKey struct{}
+
+// Problem holds the RFC 7807 problem details of an error response.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ProblemDetails is a middleware making the error responses generated by Traefik for the requests,
+// rather than by their backends, RFC 7807 problem details with the "application/problem+json" content type.
+type ProblemDetails struct{}
+
+// NewProblemDetails creates a new ProblemDetails.
+func NewProblemDetails() *ProblemDetails {
+	return &ProblemDetails{}
+}
+
+func (p *ProblemDetails) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r.WithContext(context.WithValue(r.Context(), problemDetailsKey{}, true)))
+}
+
+// NewProblemDetailsHandler returns a handler enabling the problem details for the requests of the given handler.
+func NewProblemDetailsHandler(handler http.Handler) http.Handler {
+	n := negroni.New(NewProblemDetails())
+	n.UseHandler(handler)
+	return n
+}
+
+// ProblemDetailsEnabled checks if the error responses generated for the request are problem details.
+func ProblemDetailsEnabled(req *http.Request) bool {
+	enabled, _ := req.Context().Value(problemDetailsKey{}).(bool)
+	return enabled
+}
+
+// WriteProblem writes an error response with the problem details of the given type.
+func WriteProblem(rw http.ResponseWriter, statusCode int, problemType, detail string) {
+	body, _ := json.Marshal(Problem{
+		Type:   problemType,
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	})
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.Header().Del("Content-Length")
+	rw.WriteHeader(statusCode)
+	rw.Write(body)
+}
@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestProblemDetailsRejection(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		rejection       *types.RejectionResponse
+		expectedStatus  int
+		expectedBody    string
+		expectedProblem *Problem
+	}{
+		{
+			desc:            "default rejection",
+			expectedStatus:  http.StatusForbidden,
+			expectedProblem: &Problem{Type: ProblemTypeRejected, Title: "Forbidden", Status: http.StatusForbidden},
+		},
+		{
+			desc:            "configured status code",
+			rejection:       &types.RejectionResponse{StatusCode: http.StatusNotFound},
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: &Problem{Type: ProblemTypeRejected, Title: "Not Found", Status: http.StatusNotFound},
+		},
+		{
+			desc:           "configured body",
+			rejection:      &types.RejectionResponse{Body: "go away"},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "go away",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			whitelister, err := NewIPWhitelister([]string{"1.2.3.4/32"}, nil, test.rejection)
+			require.NoError(t, err)
+
+			n := negroni.New(NewProblemDetails(), whitelister)
+			recorder := httptest.NewRecorder()
+			n.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			if test.expectedProblem == nil {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+				return
+			}
+			assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			problem := &Problem{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), problem))
+			assert.Equal(t, test.expectedProblem, problem)
+		})
+	}
+}
+
+func TestProblemDetailsCircuitOpen(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		problemDetails      bool
+		expectedContentType string
+	}{
+		{desc: "plain", expectedContentType: ""},
+		{desc: "problem details", problemDetails: true, expectedContentType: "application/problem+json"},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			var handler http.Handler = http.HandlerFunc(circuitOpen)
+			if test.problemDetails {
+				handler = NewProblemDetailsHandler(handler)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			assert.Equal(t, test.expectedContentType, recorder.Header().Get("Content-Type"))
+		})
+	}
+}
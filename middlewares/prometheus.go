@@ -13,15 +13,30 @@ const (
 	reqsTotalName    = "traefik_requests_total"
 	reqDurationName  = "traefik_request_duration_seconds"
 	retriesTotalName = "traefik_backend_retries_total"
+	reqSizeName      = "traefik_request_size_bytes"
+	respSizeName     = "traefik_response_size_bytes"
+
+	tlsHandshakeErrorsTotalName = "traefik_tls_handshake_errors_total"
+	entryPointOpenConnsName     = "traefik_entrypoint_open_connections"
+	accessLogDroppedTotalName   = "traefik_accesslog_dropped_entries_total"
+
+	retryAttemptsTotalName             = "traefik_backend_retry_attempts_total"
+	circuitBreakerTransitionsTotalName = "traefik_backend_circuit_breaker_transitions_total"
 )
 
+var sizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
 // Prometheus is an Implementation for Metrics that exposes the following Prometheus metrics:
 // - number of requests partitioned by status code and method
 // - request durations partitioned by status code
+// - request body sizes partitioned by method
+// - response body sizes partitioned by status code
 // - amount of retries happened
 type Prometheus struct {
 	reqsCounter          metrics.Counter
 	reqDurationHistogram metrics.Histogram
+	reqSizeHistogram     metrics.Histogram
+	respSizeHistogram    metrics.Histogram
 	retryCounter         metrics.Counter
 }
 
@@ -33,6 +48,14 @@ func (p *Prometheus) getReqDurationHistogram() metrics.Histogram {
 	return p.reqDurationHistogram
 }
 
+func (p *Prometheus) getReqSizeHistogram() metrics.Histogram {
+	return p.reqSizeHistogram
+}
+
+func (p *Prometheus) getRespSizeHistogram() metrics.Histogram {
+	return p.respSizeHistogram
+}
+
 func (p *Prometheus) getRetryCounter() metrics.Counter {
 	return p.retryCounter
 }
@@ -97,9 +120,126 @@ func NewPrometheus(name string, config *types.Prometheus) (*Prometheus, []stdpro
 	prom.retryCounter = prometheus.NewCounter(cv)
 	collectors = append(collectors, cv)
 
+	hv = stdprometheus.NewHistogramVec(
+		stdprometheus.HistogramOpts{
+			Name:        reqSizeName,
+			Help:        "How many bytes were read from the request body, partitioned by method.",
+			ConstLabels: stdprometheus.Labels{"service": name},
+			Buckets:     sizeBuckets,
+		},
+		[]string{"method"},
+	)
+	hv, err = registerHistogramVec(hv)
+	if err != nil {
+		return nil, collectors, err
+	}
+	prom.reqSizeHistogram = prometheus.NewHistogram(hv)
+	collectors = append(collectors, hv)
+
+	hv = stdprometheus.NewHistogramVec(
+		stdprometheus.HistogramOpts{
+			Name:        respSizeName,
+			Help:        "How many bytes were written to the response body, partitioned by status code.",
+			ConstLabels: stdprometheus.Labels{"service": name},
+			Buckets:     sizeBuckets,
+		},
+		[]string{"code"},
+	)
+	hv, err = registerHistogramVec(hv)
+	if err != nil {
+		return nil, collectors, err
+	}
+	prom.respSizeHistogram = prometheus.NewHistogram(hv)
+	collectors = append(collectors, hv)
+
 	return &prom, collectors, nil
 }
 
+// NewPrometheusTLSHandshakeErrorsCounter returns a Prometheus counter of the TLS handshake errors,
+// partitioned by entrypoint and reason.
+func NewPrometheusTLSHandshakeErrorsCounter() (metrics.Counter, stdprometheus.Collector, error) {
+	cv := stdprometheus.NewCounterVec(
+		stdprometheus.CounterOpts{
+			Name: tlsHandshakeErrorsTotalName,
+			Help: "How many TLS handshakes failed, partitioned by entrypoint and reason.",
+		},
+		[]string{"entrypoint", "reason"},
+	)
+	cv, err := registerCounterVec(cv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prometheus.NewCounter(cv), cv, nil
+}
+
+// NewPrometheusAccessLogDroppedCounter returns a Prometheus counter of the access log entries dropped on overflow
+// by the asynchronous writes.
+func NewPrometheusAccessLogDroppedCounter() (metrics.Counter, stdprometheus.Collector, error) {
+	cv := stdprometheus.NewCounterVec(
+		stdprometheus.CounterOpts{
+			Name: accessLogDroppedTotalName,
+			Help: "How many access log entries were dropped because the buffer of the asynchronous writes was full.",
+		},
+		[]string{},
+	)
+	cv, err := registerCounterVec(cv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prometheus.NewCounter(cv), cv, nil
+}
+
+// NewPrometheusRetryAttemptsCounter returns a Prometheus counter of the retried attempts of the requests,
+// partitioned by backend.
+func NewPrometheusRetryAttemptsCounter() (metrics.Counter, stdprometheus.Collector, error) {
+	cv := stdprometheus.NewCounterVec(
+		stdprometheus.CounterOpts{
+			Name: retryAttemptsTotalName,
+			Help: "How many attempts of the requests were retried, partitioned by backend.",
+		},
+		[]string{"backend"},
+	)
+	cv, err := registerCounterVec(cv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prometheus.NewCounter(cv), cv, nil
+}
+
+// NewPrometheusCircuitBreakerTransitionsCounter returns a Prometheus counter of the transitions of the circuit breakers,
+// partitioned by backend and state: open when tripped, closed when back to standby.
+func NewPrometheusCircuitBreakerTransitionsCounter() (metrics.Counter, stdprometheus.Collector, error) {
+	cv := stdprometheus.NewCounterVec(
+		stdprometheus.CounterOpts{
+			Name: circuitBreakerTransitionsTotalName,
+			Help: "How many times the circuit breakers opened or closed, partitioned by backend and state.",
+		},
+		[]string{"backend", "state"},
+	)
+	cv, err := registerCounterVec(cv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prometheus.NewCounter(cv), cv, nil
+}
+
+// NewPrometheusEntryPointOpenConnsGauge returns a Prometheus gauge of the connections open on the entrypoints,
+// partitioned by entrypoint.
+func NewPrometheusEntryPointOpenConnsGauge() (metrics.Gauge, stdprometheus.Collector, error) {
+	gv := stdprometheus.NewGaugeVec(
+		stdprometheus.GaugeOpts{
+			Name: entryPointOpenConnsName,
+			Help: "How many connections are open on the entrypoints, partitioned by entrypoint.",
+		},
+		[]string{"entrypoint"},
+	)
+	gv, err := registerGaugeVec(gv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prometheus.NewGauge(gv), gv, nil
+}
+
 func registerCounterVec(cv *stdprometheus.CounterVec) (*stdprometheus.CounterVec, error) {
 	err := stdprometheus.Register(cv)
 
@@ -127,3 +267,17 @@ func registerHistogramVec(hv *stdprometheus.HistogramVec) (*stdprometheus.Histog
 
 	return hv, nil
 }
+
+func registerGaugeVec(gv *stdprometheus.GaugeVec) (*stdprometheus.GaugeVec, error) {
+	err := stdprometheus.Register(gv)
+
+	if err != nil {
+		e, ok := err.(stdprometheus.AlreadyRegisteredError)
+		if !ok {
+			return nil, fmt.Errorf("error registering GaugeVec: %s", e)
+		}
+		gv = e.ExistingCollector.(*stdprometheus.GaugeVec)
+	}
+
+	return gv, nil
+}
@@ -0,0 +1,214 @@
+package middlewares
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// PrometheusDrainRetain keeps the series of the removed backends as is during the grace period.
+	PrometheusDrainRetain = "retain"
+	// PrometheusDrainZero resets the series of the removed backends during the grace period,
+	// for the scrapers to see them zeroed before their removal.
+	PrometheusDrainZero = "zero"
+	// DefaultPrometheusDrainGracePeriod is the default duration during which the series of a removed backend are exposed.
+	DefaultPrometheusDrainGracePeriod = 5 * time.Minute
+)
+
+// drainedVec is a collector holding the series of the backends, identified by a label,
+// either constant for the collectors of a single backend, or variable for the collectors shared by all of them.
+type drainedVec struct {
+	collector stdprometheus.Collector
+	label     string
+	constant  bool
+}
+
+// removedBackend is a removed backend, whose series are removed once its grace period elapsed.
+type removedBackend struct {
+	vecs  []drainedVec
+	timer *time.Timer
+}
+
+// PrometheusDrainer drains the Prometheus series of the backends removed from the configuration: they are kept as is,
+// or zeroed, during the grace period before being removed, which bounds the memory held by the stale series.
+// A backend added back during its grace period keeps its series.
+type PrometheusDrainer struct {
+	config      *types.Prometheus
+	policy      string
+	gracePeriod time.Duration
+	// shared are the collectors holding the series of all the backends, by a backend label.
+	shared []drainedVec
+
+	lock     sync.Mutex
+	backends map[string]bool
+	draining map[string]*removedBackend
+}
+
+// NewPrometheusDrainer creates a PrometheusDrainer from the configuration of the Prometheus metrics,
+// which must hold the drain policy.
+func NewPrometheusDrainer(config *types.Prometheus) (*PrometheusDrainer, error) {
+	drainer := &PrometheusDrainer{
+		config:      config,
+		policy:      config.Drain.Policy,
+		gracePeriod: DefaultPrometheusDrainGracePeriod,
+		backends:    make(map[string]bool),
+		draining:    make(map[string]*removedBackend),
+	}
+	if len(drainer.policy) == 0 {
+		drainer.policy = PrometheusDrainRetain
+	}
+	if drainer.policy != PrometheusDrainRetain && drainer.policy != PrometheusDrainZero {
+		return nil, fmt.Errorf("invalid drain policy %q, must be %s or %s", config.Drain.Policy, PrometheusDrainRetain, PrometheusDrainZero)
+	}
+	if len(config.Drain.GracePeriod) > 0 {
+		gracePeriod, err := time.ParseDuration(config.Drain.GracePeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid drain grace period %q: %s", config.Drain.GracePeriod, err)
+		}
+		if gracePeriod < 0 {
+			return nil, fmt.Errorf("drain grace period must not be negative, got %s", gracePeriod)
+		}
+		drainer.gracePeriod = gracePeriod
+	}
+
+	_, retryAttempts, err := NewPrometheusRetryAttemptsCounter()
+	if err != nil {
+		return nil, err
+	}
+	_, circuitBreakerTransitions, err := NewPrometheusCircuitBreakerTransitionsCounter()
+	if err != nil {
+		return nil, err
+	}
+	drainer.shared = []drainedVec{
+		{collector: retryAttempts, label: "backend"},
+		{collector: circuitBreakerTransitions, label: "backend"},
+	}
+	return drainer, nil
+}
+
+// Update drains the series of the backends removed since the previous update, and keeps the ones of the backends
+// added back during their grace period.
+func (d *PrometheusDrainer) Update(backends []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	current := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		current[backend] = true
+		if draining, ok := d.draining[backend]; ok {
+			log.Debugf("Backend %s added back, keeping its metrics", backend)
+			draining.timer.Stop()
+			delete(d.draining, backend)
+		}
+	}
+	for backend := range d.backends {
+		if !current[backend] {
+			d.drain(backend)
+		}
+	}
+	d.backends = current
+}
+
+// drain starts the grace period of a removed backend, the lock being held.
+func (d *PrometheusDrainer) drain(backend string) {
+	// The collectors of the backend are registered already, NewPrometheus returns them.
+	_, collectors, err := NewPrometheus(backend, d.config)
+	if err != nil {
+		log.Errorf("Error draining the metrics of backend %s: %s", backend, err)
+		return
+	}
+	draining := &removedBackend{vecs: append([]drainedVec(nil), d.shared...)}
+	for _, collector := range collectors {
+		draining.vecs = append(draining.vecs, drainedVec{collector: collector, label: "service", constant: true})
+	}
+	if d.policy == PrometheusDrainZero {
+		for _, vec := range draining.vecs {
+			vec.zero(backend)
+		}
+	}
+	log.Debugf("Backend %s removed, draining its metrics for %s", backend, d.gracePeriod)
+	d.draining[backend] = draining
+	draining.timer = time.AfterFunc(d.gracePeriod, func() {
+		d.remove(backend, draining)
+	})
+}
+
+// remove removes the series of a backend at the end of its grace period, unless it was added back.
+func (d *PrometheusDrainer) remove(backend string, draining *removedBackend) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.draining[backend] != draining {
+		return
+	}
+	delete(d.draining, backend)
+	for _, vec := range draining.vecs {
+		vec.remove(backend)
+	}
+	log.Debugf("Removed the metrics of backend %s", backend)
+}
+
+// zero replaces the series of the backend by zeroed ones.
+func (v drainedVec) zero(backend string) {
+	for _, labels := range v.series(backend) {
+		switch vec := v.collector.(type) {
+		case *stdprometheus.CounterVec:
+			vec.Delete(labels)
+			vec.With(labels)
+		case *stdprometheus.HistogramVec:
+			vec.Delete(labels)
+			vec.With(labels)
+		}
+	}
+}
+
+// remove removes the series of the backend, unregistering the collector when it holds a single backend.
+func (v drainedVec) remove(backend string) {
+	if v.constant {
+		stdprometheus.Unregister(v.collector)
+		return
+	}
+	for _, labels := range v.series(backend) {
+		switch vec := v.collector.(type) {
+		case *stdprometheus.CounterVec:
+			vec.Delete(labels)
+		case *stdprometheus.HistogramVec:
+			vec.Delete(labels)
+		}
+	}
+}
+
+// series returns the variable labels of the series of the backend.
+func (v drainedVec) series(backend string) []stdprometheus.Labels {
+	metrics := make(chan stdprometheus.Metric)
+	go func() {
+		v.collector.Collect(metrics)
+		close(metrics)
+	}()
+
+	var series []stdprometheus.Labels
+	for metric := range metrics {
+		written := &dto.Metric{}
+		if err := metric.Write(written); err != nil {
+			continue
+		}
+		labels := make(stdprometheus.Labels)
+		for _, pair := range written.Label {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		if labels[v.label] != backend {
+			continue
+		}
+		if v.constant {
+			delete(labels, v.label)
+		}
+		series = append(series, labels)
+	}
+	return series
+}
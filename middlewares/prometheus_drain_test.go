@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findBackendSeries returns the series of the metric whose label has the value, nil if none.
+func findBackendSeries(t *testing.T, name, label, value string) *dto.Metric {
+	metricsFamilies, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	family := findMetricFamily(name, metricsFamilies)
+	if family == nil {
+		return nil
+	}
+	for _, metric := range family.Metric {
+		for _, pair := range metric.Label {
+			if pair.GetName() == label && pair.GetValue() == value {
+				return metric
+			}
+		}
+	}
+	return nil
+}
+
+// waitRemoved waits for the removal of the series of the metric whose label has the value.
+func waitRemoved(t *testing.T, name, label, value string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for findBackendSeries(t, name, label, value) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, findBackendSeries(t, name, label, value), "%s{%s=%q} should have been removed", name, label, value)
+}
+
+func TestPrometheusDrainer(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		policy        string
+		expectedValue float64
+	}{
+		{
+			desc:          "retained",
+			policy:        PrometheusDrainRetain,
+			expectedValue: 3,
+		},
+		{
+			desc:          "retained by default",
+			expectedValue: 3,
+		},
+		{
+			desc:          "zeroed",
+			policy:        PrometheusDrainZero,
+			expectedValue: 0,
+		},
+	}
+
+	for i, test := range testCases {
+		test := test
+		removed := []string{"drain-retained", "drain-default", "drain-zeroed"}[i]
+		kept := removed + "-kept"
+		t.Run(test.desc, func(t *testing.T) {
+			config := &types.Prometheus{Drain: &types.PrometheusDrain{Policy: test.policy, GracePeriod: "100ms"}}
+			drainer, err := NewPrometheusDrainer(config)
+			require.NoError(t, err)
+
+			retryAttempts, retryAttemptsCollector, err := NewPrometheusRetryAttemptsCounter()
+			require.NoError(t, err)
+			var cleanups []func()
+			defer func() {
+				for _, cleanup := range cleanups {
+					cleanup()
+				}
+			}()
+			for _, backend := range []string{removed, kept} {
+				prom, collectors, err := NewPrometheus(backend, config)
+				require.NoError(t, err)
+				backend := backend
+				cleanups = append(cleanups, func() {
+					for _, collector := range collectors {
+						prometheus.Unregister(collector)
+					}
+					retryAttemptsCollector.(*prometheus.CounterVec).DeleteLabelValues(backend)
+				})
+				prom.getReqsCounter().With("code", "200", "method", http.MethodGet).Add(3)
+				prom.getReqDurationHistogram().With("code", "200").Observe(0.2)
+				retryAttempts.With("backend", backend).Add(3)
+			}
+
+			drainer.Update([]string{removed, kept})
+			drainer.Update([]string{kept})
+
+			requests := findBackendSeries(t, reqsTotalName, "service", removed)
+			require.NotNil(t, requests, "the series of the removed backend must be exposed during the grace period")
+			assert.Equal(t, test.expectedValue, requests.GetCounter().GetValue())
+			durations := findBackendSeries(t, reqDurationName, "service", removed)
+			require.NotNil(t, durations)
+			assert.Equal(t, uint64(test.expectedValue/3), durations.GetHistogram().GetSampleCount())
+			retries := findBackendSeries(t, retryAttemptsTotalName, "backend", removed)
+			require.NotNil(t, retries)
+			assert.Equal(t, test.expectedValue, retries.GetCounter().GetValue())
+
+			waitRemoved(t, reqsTotalName, "service", removed)
+			waitRemoved(t, reqDurationName, "service", removed)
+			waitRemoved(t, retryAttemptsTotalName, "backend", removed)
+
+			requests = findBackendSeries(t, reqsTotalName, "service", kept)
+			require.NotNil(t, requests, "the series of the kept backend must not be drained")
+			assert.Equal(t, float64(3), requests.GetCounter().GetValue())
+			retries = findBackendSeries(t, retryAttemptsTotalName, "backend", kept)
+			require.NotNil(t, retries)
+			assert.Equal(t, float64(3), retries.GetCounter().GetValue())
+		})
+	}
+}
+
+func TestPrometheusDrainerBackendAddedBack(t *testing.T) {
+	config := &types.Prometheus{Drain: &types.PrometheusDrain{GracePeriod: "50ms"}}
+	drainer, err := NewPrometheusDrainer(config)
+	require.NoError(t, err)
+	prom, collectors, err := NewPrometheus("drain-added-back", config)
+	require.NoError(t, err)
+	defer func() {
+		for _, collector := range collectors {
+			prometheus.Unregister(collector)
+		}
+	}()
+	prom.getReqsCounter().With("code", "200", "method", http.MethodGet).Add(1)
+
+	drainer.Update([]string{"drain-added-back"})
+	drainer.Update(nil)
+	drainer.Update([]string{"drain-added-back"})
+	time.Sleep(100 * time.Millisecond)
+
+	requests := findBackendSeries(t, reqsTotalName, "service", "drain-added-back")
+	require.NotNil(t, requests, "the series of a backend added back during its grace period must be kept")
+	assert.Equal(t, float64(1), requests.GetCounter().GetValue())
+}
+
+func TestNewPrometheusDrainerInvalid(t *testing.T) {
+	for _, drain := range []types.PrometheusDrain{
+		{Policy: "forget"},
+		{GracePeriod: "soon"},
+		{GracePeriod: "-1s"},
+	} {
+		drain := drain
+		_, err := NewPrometheusDrainer(&types.Prometheus{Drain: &drain})
+		assert.Error(t, err, "%+v", drain)
+	}
+}
@@ -42,6 +42,12 @@ func TestPrometheus(t *testing.T) {
 	if !strings.Contains(body, reqDurationName) {
 		t.Errorf("body does not contain request duration entry '%s'", reqDurationName)
 	}
+	if !strings.Contains(body, reqSizeName) {
+		t.Errorf("body does not contain request size entry '%s'", reqSizeName)
+	}
+	if !strings.Contains(body, respSizeName) {
+		t.Errorf("body does not contain response size entry '%s'", respSizeName)
+	}
 	if !strings.Contains(body, retriesTotalName) {
 		t.Errorf("body does not contain total retries entry '%s'", retriesTotalName)
 	}
@@ -85,6 +91,34 @@ func TestPrometheus(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: reqSizeName,
+			labels: map[string]string{
+				"service": "test",
+				"method":  http.MethodGet,
+			},
+			assert: func(family *dto.MetricFamily) {
+				sc := family.Metric[0].Histogram.GetSampleCount()
+				expectedSc := uint64(2)
+				if sc != expectedSc {
+					t.Errorf("gathered metrics do not contain correct sample count for request size, got %d expected %d", sc, expectedSc)
+				}
+			},
+		},
+		{
+			name: respSizeName,
+			labels: map[string]string{
+				"service": "test",
+				"code":    "200",
+			},
+			assert: func(family *dto.MetricFamily) {
+				sc := family.Metric[0].Histogram.GetSampleCount()
+				expectedSc := uint64(2)
+				if sc != expectedSc {
+					t.Errorf("gathered metrics do not contain correct sample count for response size, got %d expected %d", sc, expectedSc)
+				}
+			},
+		},
 		{
 			name: retriesTotalName,
 			labels: map[string]string{
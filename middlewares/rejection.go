@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/containous/traefik/types"
+	"github.com/vulcand/oxy/connlimit"
+	"github.com/vulcand/oxy/utils"
+)
+
+// reject writes the response of a request rejected by a middleware: the default response written by writeDefault,
+// or the configured rejection instead. The headers of the default response, such as an authentication challenge,
+// are kept only when the rejection does not override its status code.
+// Without configured body, the problem details of the rejection are written when they are enabled for the request.
+func reject(rw http.ResponseWriter, req *http.Request, rejection *types.RejectionResponse, writeDefault func(http.ResponseWriter)) {
+	problemDetails := ProblemDetailsEnabled(req)
+	if rejection == nil && !problemDetails {
+		writeDefault(rw)
+		return
+	}
+	if rejection == nil {
+		rejection = &types.RejectionResponse{}
+	}
+
+	defaultResponse := &rejectionResponseWriter{header: make(http.Header), code: http.StatusOK}
+	writeDefault(defaultResponse)
+
+	statusCode := defaultResponse.code
+	if rejection.StatusCode != 0 && rejection.StatusCode != statusCode {
+		statusCode = rejection.StatusCode
+	} else {
+		for name, values := range defaultResponse.header {
+			if name != "Content-Type" && name != "Content-Length" {
+				rw.Header()[name] = values
+			}
+		}
+	}
+	for name, value := range rejection.Headers {
+		rw.Header().Set(name, value)
+	}
+
+	body := rejection.Body
+	if body == "" && problemDetails {
+		WriteProblem(rw, statusCode, ProblemTypeRejected, "")
+		return
+	}
+	if body == "" {
+		body = http.StatusText(statusCode)
+	}
+	rw.WriteHeader(statusCode)
+	rw.Write([]byte(body))
+}
+
+// rejectionResponseWriter records the status code and headers of a default rejection response, discarding its body.
+type rejectionResponseWriter struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+}
+
+func (r *rejectionResponseWriter) Header() http.Header {
+	return r.header
+}
+
+func (r *rejectionResponseWriter) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.code = code
+}
+
+func (r *rejectionResponseWriter) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+type maxConnRejectionKey struct{}
+
+// NewMaxConnRejection returns a handler overriding with rejection the responses of the requests of next rejected
+// over the maximum amount of connections of their backend, the backend being shared by the frontends.
+func NewMaxConnRejection(next http.Handler, rejection *types.RejectionResponse) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), maxConnRejectionKey{}, rejection)))
+	})
+}
+
+// maxConnRejection returns the rejection set by NewMaxConnRejection for the request, if any.
+func maxConnRejection(req *http.Request) *types.RejectionResponse {
+	rejection, _ := req.Context().Value(maxConnRejectionKey{}).(*types.RejectionResponse)
+	return rejection
+}
+
+// NewConnLimitErrorHandler builds the error handler of a connlimit, writing the rejection
+// of the requests exceeding the maximum amount of connections, see NewMaxConnRejection.
+func NewConnLimitErrorHandler() utils.ErrorHandler {
+	defaultHandler := &connlimit.ConnErrHandler{}
+	return utils.ErrorHandlerFunc(func(rw http.ResponseWriter, req *http.Request, err error) {
+		if _, ok := err.(*connlimit.MaxConnError); !ok {
+			defaultHandler.ServeHTTP(rw, req, err)
+			return
+		}
+		reject(rw, req, maxConnRejection(req), func(rw http.ResponseWriter) {
+			defaultHandler.ServeHTTP(rw, req, err)
+		})
+	})
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/vulcand/oxy/connlimit"
+)
+
+func TestConnLimitErrorHandler(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		rejection      *types.RejectionResponse
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			desc:           "default",
+			err:            &connlimit.MaxConnError{},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   "max connections reached: 0",
+		},
+		{
+			desc:           "rejection",
+			rejection:      &types.RejectionResponse{StatusCode: http.StatusServiceUnavailable},
+			err:            &connlimit.MaxConnError{},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "Service Unavailable",
+		},
+		{
+			desc:           "not a rejection",
+			rejection:      &types.RejectionResponse{StatusCode: http.StatusServiceUnavailable},
+			err:            errors.New("cannot extract the source"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   http.StatusText(http.StatusInternalServerError),
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			handler := NewMaxConnRejection(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				NewConnLimitErrorHandler().ServeHTTP(rw, req, test.err)
+			}), test.rejection)
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RequestNormalizer normalizes the target of the requests before they are matched against the rules of the frontends.
+// The absolute-form request URIs (e.g. "GET http://host/path") are turned into their origin-form with the host of the
+// URI, and the host is lowercased, without its trailing dot nor the default port of the scheme of the request.
+type RequestNormalizer struct{}
+
+// NewRequestNormalizer creates a new RequestNormalizer.
+func NewRequestNormalizer() *RequestNormalizer {
+	return &RequestNormalizer{}
+}
+
+func (n *RequestNormalizer) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	normalizeRequest(r)
+	next(rw, r)
+}
+
+func normalizeRequest(r *http.Request) {
+	if r.URL.IsAbs() {
+		if r.URL.Host != "" {
+			r.Host = r.URL.Host
+		}
+		r.URL.Scheme = ""
+		r.URL.Host = ""
+		r.URL.User = nil
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	host, port, err := net.SplitHostPort(strings.ToLower(r.Host))
+	if err != nil {
+		host, port = strings.ToLower(r.Host), ""
+	}
+	if trimmed := strings.TrimSuffix(host, "."); trimmed != "" {
+		host = trimmed
+	}
+	defaultPort := "80"
+	if r.TLS != nil {
+		defaultPort = "443"
+	}
+	if port == "" || port == defaultPort {
+		if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			host = "[" + host + "]"
+		}
+		r.Host = host
+		return
+	}
+	r.Host = net.JoinHostPort(host, port)
+}
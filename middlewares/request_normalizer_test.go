@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestNormalizer(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		target             string
+		host               string
+		tls                bool
+		expectedHost       string
+		expectedRequestURI string
+	}{
+		{
+			desc:               "origin-form",
+			target:             "/path?query=1",
+			host:               "foo.bar",
+			expectedHost:       "foo.bar",
+			expectedRequestURI: "/path?query=1",
+		},
+		{
+			desc:               "absolute-form",
+			target:             "http://foo.bar/path?query=1",
+			host:               "other.bar",
+			expectedHost:       "foo.bar",
+			expectedRequestURI: "/path?query=1",
+		},
+		{
+			desc:               "absolute-form with a port",
+			target:             "http://Foo.Bar:8080/path",
+			expectedHost:       "foo.bar:8080",
+			expectedRequestURI: "/path",
+		},
+		{
+			desc:               "uppercase host with a trailing dot",
+			target:             "/",
+			host:               "Foo.Bar.",
+			expectedHost:       "foo.bar",
+			expectedRequestURI: "/",
+		},
+		{
+			desc:               "default http port",
+			target:             "/",
+			host:               "foo.bar:80",
+			expectedHost:       "foo.bar",
+			expectedRequestURI: "/",
+		},
+		{
+			desc:               "default https port",
+			target:             "/",
+			host:               "foo.bar:443",
+			tls:                true,
+			expectedHost:       "foo.bar",
+			expectedRequestURI: "/",
+		},
+		{
+			desc:               "https port over http",
+			target:             "/",
+			host:               "foo.bar:443",
+			expectedHost:       "foo.bar:443",
+			expectedRequestURI: "/",
+		},
+		{
+			desc:               "IPv6 with the default port",
+			target:             "/",
+			host:               "[::1]:80",
+			expectedHost:       "[::1]",
+			expectedRequestURI: "/",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.host != "" {
+				req.Host = test.host
+			}
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			var received *http.Request
+			NewRequestNormalizer().ServeHTTP(httptest.NewRecorder(), req, func(rw http.ResponseWriter, r *http.Request) {
+				received = r
+			})
+
+			assert.Equal(t, test.expectedHost, received.Host)
+			assert.Equal(t, test.expectedRequestURI, received.RequestURI)
+			assert.False(t, received.URL.IsAbs())
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+const (
+	// DefaultResponseCacheMaxSize is the default maximum size of the bodies cached by a ResponseCache.
+	DefaultResponseCacheMaxSize = 16 << 20
+	// DefaultResponseCacheMaxEntrySize is the default maximum size of a body cached by a ResponseCache.
+	DefaultResponseCacheMaxEntrySize = 1 << 20
+)
+
+// ResponseCache is a middleware caching in memory the 200 responses to the GET requests, for the duration
+// given by their Cache-Control or Expires headers, or the default TTL when they have none.
+// The responses are cached by host and URI, plus the values of the request headers they vary on,
+// and the least recently used ones are evicted once the size of the cached bodies exceeds the maximum.
+type ResponseCache struct {
+	maxEntrySize int64
+	maxSize      int64
+	defaultTTL   time.Duration
+	now          func() time.Time
+
+	lock sync.Mutex
+	// lru holds the cached responses, the most recently used first.
+	lru *list.List
+	// variants holds the elements of lru by host and URI, one per value of the headers they vary on.
+	variants map[string][]*list.Element
+	size     int64
+}
+
+// cachedResponse is a cached response, with the request headers it varies on.
+type cachedResponse struct {
+	key     string
+	vary    map[string]string
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// NewResponseCache creates a ResponseCache from its configuration, the unset sizes taking their default.
+func NewResponseCache(config *types.ResponseCache) (*ResponseCache, error) {
+	cache := &ResponseCache{
+		maxEntrySize: DefaultResponseCacheMaxEntrySize,
+		maxSize:      DefaultResponseCacheMaxSize,
+		now:          time.Now,
+		lru:          list.New(),
+		variants:     make(map[string][]*list.Element),
+	}
+	if config.MaxSize > 0 {
+		cache.maxSize = config.MaxSize
+	}
+	if config.MaxEntrySize > 0 {
+		cache.maxEntrySize = config.MaxEntrySize
+	}
+	if cache.maxEntrySize > cache.maxSize {
+		return nil, fmt.Errorf("maximum entry size %d exceeding the maximum size %d", cache.maxEntrySize, cache.maxSize)
+	}
+	if len(config.DefaultTTL) > 0 {
+		ttl, err := time.ParseDuration(config.DefaultTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default TTL %q: %s", config.DefaultTTL, err)
+		}
+		if ttl < 0 {
+			return nil, errors.New("negative default TTL")
+		}
+		cache.defaultTTL = ttl
+	}
+	return cache, nil
+}
+
+func (c *ResponseCache) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Method != http.MethodGet || len(r.Header.Get("Upgrade")) > 0 {
+		next(rw, r)
+		return
+	}
+	directives := parseCacheControl(r.Header)
+	if _, ok := directives["no-store"]; ok {
+		next(rw, r)
+		return
+	}
+
+	key := r.Host + r.URL.RequestURI()
+	if _, ok := directives["no-cache"]; !ok {
+		if response := c.lookup(key, r); response != nil {
+			c.serve(rw, response)
+			return
+		}
+	}
+
+	recorder := &cacheResponseWriter{ResponseWriter: rw, maxSize: c.maxEntrySize}
+	next(recorder, r)
+	c.store(key, r, recorder)
+}
+
+// lookup returns the fresh cached response to the request, if any, dropping the expired ones.
+func (c *ResponseCache) lookup(key string, r *http.Request) *cachedResponse {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	// The expired responses are removed from the variants while ranging over them.
+	for _, element := range append([]*list.Element(nil), c.variants[key]...) {
+		response := element.Value.(*cachedResponse)
+		if !response.expires.After(now) {
+			c.remove(element)
+			continue
+		}
+		if response.matches(r) {
+			c.lru.MoveToFront(element)
+			return response
+		}
+	}
+	return nil
+}
+
+func (c *ResponseCache) serve(rw http.ResponseWriter, response *cachedResponse) {
+	for name, values := range response.header {
+		rw.Header()[name] = append([]string(nil), values...)
+	}
+	rw.Header().Set("Age", strconv.Itoa(int(c.now().Sub(response.stored)/time.Second)))
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(response.body)
+}
+
+// store caches the recorded response to the request when it is cacheable.
+func (c *ResponseCache) store(key string, r *http.Request, recorder *cacheResponseWriter) {
+	if recorder.code != http.StatusOK || recorder.uncacheable {
+		return
+	}
+	header := recorder.header
+	directives := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return
+		}
+	}
+	if len(header.Get("Set-Cookie")) > 0 {
+		return
+	}
+	if len(r.Header.Get("Authorization")) > 0 {
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if !public && !shared {
+			return
+		}
+	}
+
+	vary := make(map[string]string)
+	for _, values := range header["Vary"] {
+		for _, name := range strings.Split(values, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return
+			}
+			if len(name) > 0 {
+				vary[name] = strings.Join(r.Header[name], ",")
+			}
+		}
+	}
+
+	now := c.now()
+	ttl := c.freshness(header, directives, now)
+	var age time.Duration
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil && seconds > 0 {
+		age = time.Duration(seconds) * time.Second
+	}
+	if ttl-age <= 0 {
+		return
+	}
+
+	response := &cachedResponse{
+		key:     key,
+		vary:    vary,
+		header:  header,
+		body:    recorder.body.Bytes(),
+		stored:  now.Add(-age),
+		expires: now.Add(ttl - age),
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, element := range c.variants[key] {
+		if element.Value.(*cachedResponse).sameVariant(response) {
+			c.remove(element)
+			break
+		}
+	}
+	c.variants[key] = append(c.variants[key], c.lru.PushFront(response))
+	c.size += int64(len(response.body))
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	log.Debugf("Cached response to %s for %s", key, ttl-age)
+}
+
+// freshness returns the freshness lifetime of the response, from its Cache-Control or Expires headers,
+// or the default TTL.
+func (c *ResponseCache) freshness(header http.Header, directives map[string]string, now time.Time) time.Duration {
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if expires := header.Get("Expires"); len(expires) > 0 {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+		return expiresAt.Sub(now)
+	}
+	return c.defaultTTL
+}
+
+// remove removes a cached response, the lock being held.
+func (c *ResponseCache) remove(element *list.Element) {
+	response := c.lru.Remove(element).(*cachedResponse)
+	c.size -= int64(len(response.body))
+
+	variants := c.variants[response.key]
+	for i, variant := range variants {
+		if variant == element {
+			variants = append(variants[:i], variants[i+1:]...)
+			break
+		}
+	}
+	if len(variants) == 0 {
+		delete(c.variants, response.key)
+	} else {
+		c.variants[response.key] = variants
+	}
+}
+
+// matches returns whether the request has the values of the headers the response varies on.
+func (r *cachedResponse) matches(req *http.Request) bool {
+	for name, value := range r.vary {
+		if strings.Join(req.Header[name], ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *cachedResponse) sameVariant(other *cachedResponse) bool {
+	if len(r.vary) != len(other.vary) {
+		return false
+	}
+	for name, value := range r.vary {
+		if otherValue, ok := other.vary[name]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCacheControl returns the values of the Cache-Control directives, by lowercase name.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, values := range header["Cache-Control"] {
+		for _, directive := range strings.Split(values, ",") {
+			name, value := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+				directives[name] = value
+			}
+		}
+	}
+	return directives
+}
+
+// cacheResponseWriter writes the response to the client, recording it to be cached
+// unless its body exceeds the maximum size.
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	maxSize     int64
+	code        int
+	header      http.Header
+	body        bytes.Buffer
+	uncacheable bool
+}
+
+func (w *cacheResponseWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	w.code = code
+	w.header = cloneHeader(w.ResponseWriter.Header())
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.uncacheable {
+		if int64(w.body.Len()+len(b)) > w.maxSize {
+			w.uncacheable = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		w.uncacheable = true
+	}
+	return n, err
+}
+
+// Hijack hijacks the connection
+func (w *cacheResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.uncacheable = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *cacheResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *cacheResponseWriter) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
@@ -0,0 +1,239 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend answers with its number of calls, and the given status code and headers.
+type countingBackend struct {
+	calls  int
+	code   int
+	header map[string]string
+	body   string
+}
+
+func (b *countingBackend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b.calls++
+	for name, value := range b.header {
+		rw.Header().Set(name, value)
+	}
+	code := b.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	rw.WriteHeader(code)
+	if len(b.body) > 0 {
+		rw.Write([]byte(b.body))
+	} else {
+		rw.Write([]byte(req.Header.Get("Accept-Language") + " response"))
+	}
+}
+
+func serveCached(t *testing.T, cache *ResponseCache, backend http.Handler, method string, header map[string]string) *httptest.ResponseRecorder {
+	req := testhelpers.MustNewRequest(method, "http://frontend/resource?id=1", nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	cache.ServeHTTP(recorder, req, backend.ServeHTTP)
+	return recorder
+}
+
+func TestResponseCache(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		config         types.ResponseCache
+		method         string
+		code           int
+		responseHeader map[string]string
+		requestHeader  map[string]string
+		expectedCalls  int
+	}{
+		{
+			desc:           "cache hit",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			expectedCalls:  1,
+		},
+		{
+			desc:          "default TTL",
+			config:        types.ResponseCache{DefaultTTL: "1m"},
+			expectedCalls: 1,
+		},
+		{
+			desc:          "no freshness without default TTL",
+			expectedCalls: 2,
+		},
+		{
+			desc:           "expires header",
+			responseHeader: map[string]string{"Expires": time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)},
+			expectedCalls:  1,
+		},
+		{
+			desc:           "no-store request",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			requestHeader:  map[string]string{"Cache-Control": "no-store"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "no-cache request",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			requestHeader:  map[string]string{"Cache-Control": "no-cache"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "no-store response",
+			config:         types.ResponseCache{DefaultTTL: "1m"},
+			responseHeader: map[string]string{"Cache-Control": "no-store"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "no-cache response",
+			responseHeader: map[string]string{"Cache-Control": "no-cache, max-age=60"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "private response",
+			responseHeader: map[string]string{"Cache-Control": "private, max-age=60"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "response setting a cookie",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "session=1"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "vary on any header",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "*"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "authorized request",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			requestHeader:  map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "public response to an authorized request",
+			responseHeader: map[string]string{"Cache-Control": "public, max-age=60"},
+			requestHeader:  map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			expectedCalls:  1,
+		},
+		{
+			desc:           "not found response",
+			code:           http.StatusNotFound,
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "post request",
+			method:         http.MethodPost,
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			expectedCalls:  2,
+		},
+		{
+			desc:           "response exceeding the maximum entry size",
+			config:         types.ResponseCache{MaxEntrySize: 4},
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			expectedCalls:  2,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cache, err := NewResponseCache(&test.config)
+			require.NoError(t, err)
+			backend := &countingBackend{code: test.code, header: test.responseHeader}
+			method := test.method
+			if len(method) == 0 {
+				method = http.MethodGet
+			}
+
+			first := serveCached(t, cache, backend, method, test.requestHeader)
+			second := serveCached(t, cache, backend, method, test.requestHeader)
+
+			assert.Equal(t, test.expectedCalls, backend.calls)
+			assert.Equal(t, first.Code, second.Code)
+			assert.Equal(t, first.Body.String(), second.Body.String())
+			assert.Equal(t, first.Header().Get("Cache-Control"), second.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache, err := NewResponseCache(&types.ResponseCache{})
+	require.NoError(t, err)
+	cache.now = func() time.Time { return now }
+	backend := &countingBackend{header: map[string]string{"Cache-Control": "max-age=60", "Age": "10"}}
+
+	serveCached(t, cache, backend, http.MethodGet, nil)
+	now = now.Add(49 * time.Second)
+	response := serveCached(t, cache, backend, http.MethodGet, nil)
+	assert.Equal(t, 1, backend.calls)
+	assert.Equal(t, "59", response.Header().Get("Age"))
+
+	now = now.Add(time.Second)
+	serveCached(t, cache, backend, http.MethodGet, nil)
+	assert.Equal(t, 2, backend.calls, "the response must expire 60s after it was generated")
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	cache, err := NewResponseCache(&types.ResponseCache{})
+	require.NoError(t, err)
+	backend := &countingBackend{header: map[string]string{"Cache-Control": "max-age=60", "Vary": "Accept-Language"}}
+
+	for i := 0; i < 2; i++ {
+		for _, language := range []string{"en", "fr"} {
+			response := serveCached(t, cache, backend, http.MethodGet, map[string]string{"Accept-Language": language})
+			assert.Equal(t, language+" response", response.Body.String())
+		}
+	}
+	assert.Equal(t, 2, backend.calls)
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache, err := NewResponseCache(&types.ResponseCache{MaxSize: 20, MaxEntrySize: 10})
+	require.NoError(t, err)
+	backend := &countingBackend{header: map[string]string{"Cache-Control": "max-age=60"}, body: strings.Repeat("x", 8)}
+
+	serve := func(path string) {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://frontend"+path, nil)
+		cache.ServeHTTP(httptest.NewRecorder(), req, backend.ServeHTTP)
+	}
+
+	serve("/a")
+	serve("/b")
+	serve("/a")
+	assert.Equal(t, 2, backend.calls)
+
+	// The least recently used response of /b is evicted to cache /c.
+	serve("/c")
+	serve("/a")
+	serve("/c")
+	assert.Equal(t, 3, backend.calls)
+	serve("/b")
+	assert.Equal(t, 4, backend.calls)
+}
+
+func TestNewResponseCacheInvalidConfig(t *testing.T) {
+	_, err := NewResponseCache(&types.ResponseCache{DefaultTTL: "foo"})
+	assert.Error(t, err)
+
+	_, err = NewResponseCache(&types.ResponseCache{DefaultTTL: "-1s"})
+	assert.Error(t, err)
+
+	_, err = NewResponseCache(&types.ResponseCache{MaxSize: 10, MaxEntrySize: 20})
+	assert.Error(t, err)
+}
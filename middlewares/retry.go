@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"context"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/containous/traefik/log"
 	"github.com/vulcand/oxy/utils"
@@ -17,17 +19,61 @@ var (
 	_ Stateful = &retryResponseRecorder{}
 )
 
+// RetryBackoff is the delay between the attempts of the Retry middleware. The Interval is constant,
+// or doubled after each retry up to MaxInterval, if not zero, when Exponential. A zero Interval retries immediately.
+type RetryBackoff struct {
+	Exponential bool
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+type retryBackoffKey struct{}
+
+// NewRetryBackoff returns a handler overriding with backoff the backoff of the Retry middlewares retrying the requests
+// of next, the Retry middleware of a backend being shared by the frontends.
+func NewRetryBackoff(next http.Handler, backoff RetryBackoff) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), retryBackoffKey{}, backoff)))
+	})
+}
+
+// delay returns the backoff before the retry following the given number of attempts.
+func (b RetryBackoff) delay(attempts int) time.Duration {
+	delay := b.Interval
+	if !b.Exponential {
+		return delay
+	}
+	for i := 1; i < attempts && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if b.MaxInterval > 0 && delay > b.MaxInterval {
+		return b.MaxInterval
+	}
+	return delay
+}
+
 // Retry is a middleware that retries requests
 type Retry struct {
 	attempts int
+	backoff  RetryBackoff
+	timeout  time.Duration
 	next     http.Handler
 	listener RetryListener
 }
 
 // NewRetry returns a new Retry instance
 func NewRetry(attempts int, next http.Handler, listener RetryListener) *Retry {
+	return NewRetryWithBackoff(attempts, RetryBackoff{}, 0, next, listener)
+}
+
+// NewRetryWithBackoff returns a new Retry instance waiting for the backoff between the attempts,
+// unless overridden for the request, see NewRetryBackoff.
+// The requests are not retried once the backoff would end after the timeout since the first attempt, if not zero.
+func NewRetryWithBackoff(attempts int, backoff RetryBackoff, timeout time.Duration, next http.Handler, listener RetryListener) *Retry {
 	return &Retry{
 		attempts: attempts,
+		backoff:  backoff,
+		timeout:  timeout,
 		next:     next,
 		listener: listener,
 	}
@@ -42,6 +88,7 @@ func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		r.Body = ioutil.NopCloser(body)
 	}
 	attempts := 1
+	start := time.Now()
 	for {
 		netErrorOccurred := false
 		// We pass in a pointer to netErrorOccurred so that we can set it to true on network errors
@@ -52,7 +99,7 @@ func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		recorder.responseWriter = rw
 
 		retry.next.ServeHTTP(recorder, r.WithContext(newCtx))
-		if !netErrorOccurred || attempts >= retry.attempts {
+		if !netErrorOccurred || attempts >= retry.attempts || !retry.wait(r, start, attempts) {
 			utils.CopyHeaders(rw.Header(), recorder.Header())
 			rw.WriteHeader(recorder.Code)
 			rw.Write(recorder.Body.Bytes())
@@ -64,6 +111,32 @@ func (retry *Retry) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// wait waits for the backoff before the retry following the given number of attempts. It returns false,
+// not to retry the request, when the backoff would end after the timeout or the request is canceled meanwhile.
+func (retry *Retry) wait(r *http.Request, start time.Time, attempts int) bool {
+	backoff := retry.backoff
+	if requestBackoff, ok := r.Context().Value(retryBackoffKey{}).(RetryBackoff); ok {
+		backoff = requestBackoff
+	}
+	delay := backoff.delay(attempts)
+	if delay <= 0 {
+		return true
+	}
+	if retry.timeout > 0 && time.Since(start)+delay > retry.timeout {
+		log.Debugf("Backoff of %s exceeding the timeout, no more attempts for request: %v", delay, r.URL)
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
 // netErrorCtxKey is a custom type that is used as key for the context.
 type netErrorCtxKey string
 
@@ -7,6 +7,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRetry(t *testing.T) {
@@ -63,6 +67,100 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+func TestRetryBackoff(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		backoff        RetryBackoff
+		expectedDelays []time.Duration
+	}{
+		{
+			desc:           "no backoff",
+			expectedDelays: []time.Duration{0, 0, 0},
+		},
+		{
+			desc:           "constant backoff",
+			backoff:        RetryBackoff{Interval: 20 * time.Millisecond},
+			expectedDelays: []time.Duration{20 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			desc:           "exponential backoff",
+			backoff:        RetryBackoff{Exponential: true, Interval: 20 * time.Millisecond, MaxInterval: 60 * time.Millisecond},
+			expectedDelays: []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var calls []time.Time
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls = append(calls, time.Now())
+				DefaultNetErrorRecorder{}.Record(req.Context())
+				rw.WriteHeader(http.StatusBadGateway)
+			})
+			retry := NewRetryWithBackoff(len(test.expectedDelays)+1, test.backoff, 0, handler, &countingRetryListener{})
+
+			recorder := httptest.NewRecorder()
+			retry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+			assert.Equal(t, http.StatusBadGateway, recorder.Code)
+			require.Len(t, calls, len(test.expectedDelays)+1)
+			for i, expectedDelay := range test.expectedDelays {
+				delay := calls[i+1].Sub(calls[i])
+				assert.True(t, delay >= expectedDelay, "delay %s before attempt %d shorter than %s", delay, i+2, expectedDelay)
+				assert.True(t, delay < expectedDelay+25*time.Millisecond, "delay %s before attempt %d longer than %s", delay, i+2, expectedDelay)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffTimeout(t *testing.T) {
+	handler := &networkFailingHTTPHandler{failAtCalls: []int{1, 2, 3}, netErrorRecorder: &DefaultNetErrorRecorder{}}
+	listener := &countingRetryListener{}
+	backoff := RetryBackoff{Exponential: true, Interval: 20 * time.Millisecond}
+	retry := NewRetryWithBackoff(4, backoff, 100*time.Millisecond, handler, listener)
+
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	retry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	// After the backoffs of 20ms and 40ms, the third one of 80ms would end after the timeout.
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.Equal(t, 2, listener.timesCalled)
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+}
+
+func TestRetryBackoffOverride(t *testing.T) {
+	handler := &networkFailingHTTPHandler{failAtCalls: []int{1, 2}, netErrorRecorder: &DefaultNetErrorRecorder{}}
+	listener := &countingRetryListener{}
+	retry := NewRetryWithBackoff(3, RetryBackoff{Interval: time.Minute}, 0, handler, listener)
+
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	NewRetryBackoff(retry, RetryBackoff{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	// The request is retried at once, without the backoff of a minute of the retry middleware.
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, listener.timesCalled)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRetryBackoffCanceledRequest(t *testing.T) {
+	handler := &networkFailingHTTPHandler{failAtCalls: []int{1}, netErrorRecorder: &DefaultNetErrorRecorder{}}
+	listener := &countingRetryListener{}
+	retry := NewRetryWithBackoff(2, RetryBackoff{Interval: time.Minute}, 0, handler, listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	recorder := httptest.NewRecorder()
+	retry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil).WithContext(ctx))
+
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.Equal(t, 0, listener.timesCalled)
+}
+
 func TestDefaultNetErrorRecorderSuccess(t *testing.T) {
 	boolNetErrorOccurred := false
 	recorder := DefaultNetErrorRecorder{}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// The headers identifying the route matched by the requests.
+const (
+	RouteHeaderFrontend = "X-Traefik-Frontend"
+	RouteHeaderBackend  = "X-Traefik-Backend"
+)
+
+// RouteHeaders is a middleware adding to the responses the headers identifying the frontend
+// and the backend matched by the requests.
+type RouteHeaders struct {
+	frontend string
+	backend  string
+}
+
+// NewRouteHeaders creates a new RouteHeaders for the frontend and the backend of a route.
+func NewRouteHeaders(frontend, backend string) *RouteHeaders {
+	return &RouteHeaders{frontend: frontend, backend: backend}
+}
+
+func (h *RouteHeaders) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	rw.Header().Set(RouteHeaderFrontend, h.frontend)
+	rw.Header().Set(RouteHeaderBackend, h.backend)
+	next(rw, r)
+}
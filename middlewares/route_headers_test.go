@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHeaders(t *testing.T) {
+	routeHeaders := NewRouteHeaders("frontend1", "backend1")
+
+	recorder := httptest.NewRecorder()
+	routeHeaders.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil), func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, "frontend1", recorder.Header().Get(RouteHeaderFrontend))
+	assert.Equal(t, "backend1", recorder.Header().Get(RouteHeaderBackend))
+}
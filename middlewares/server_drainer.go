@@ -0,0 +1,179 @@
+package middlewares
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+	"github.com/vulcand/oxy/roundrobin"
+	"github.com/vulcand/oxy/utils"
+)
+
+const (
+	// DefaultDrainHeader is the response header in which the servers report they are draining to the ServerDrainer.
+	DefaultDrainHeader = "X-Draining"
+	// DefaultDrainTimeout is the time after which a draining server is added back to the load balancer.
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// drainingServer is a server removed from the load balancer since it answered with the drain header.
+type drainingServer struct {
+	since time.Time
+	timer *time.Timer
+}
+
+// ServerDrainer removes from the load balancer the servers answering with the drain header set to true, for them
+// to finish their current requests without receiving new ones. A draining server is added back with its configured
+// weight after the timeout, or as soon as it answers without the header a request sent after it started draining.
+// The last server of the load balancer is never removed. The header is removed from the responses.
+type ServerDrainer struct {
+	next    http.Handler
+	header  string
+	timeout time.Duration
+	// weights are the configured weights of the servers, by URL.
+	weights map[string]int
+	now     func() time.Time
+
+	lock     sync.Mutex
+	lb       healthcheck.LoadBalancer
+	draining map[string]*drainingServer
+}
+
+// NewServerDrainer creates a ServerDrainer forwarding the requests to next, reading the given response header,
+// or DefaultDrainHeader when empty, and adding back the servers after the timeout, or DefaultDrainTimeout when zero.
+func NewServerDrainer(next http.Handler, header string, timeout time.Duration, weights map[string]int) *ServerDrainer {
+	if len(header) == 0 {
+		header = DefaultDrainHeader
+	}
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	return &ServerDrainer{
+		next:     next,
+		header:   header,
+		timeout:  timeout,
+		weights:  weights,
+		now:      time.Now,
+		draining: make(map[string]*drainingServer),
+	}
+}
+
+// SetLoadBalancer sets the load balancer forwarding the requests to the ServerDrainer, from which the draining servers are removed.
+func (d *ServerDrainer) SetLoadBalancer(lb healthcheck.LoadBalancer) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.lb = lb
+}
+
+func (d *ServerDrainer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	d.next.ServeHTTP(&drainResponseWriter{ResponseWriter: rw, drainer: d, server: utils.CopyURL(req.URL), start: d.now()}, req)
+}
+
+// report removes the server from the load balancer when draining, and adds it back when it stopped draining.
+func (d *ServerDrainer) report(server *url.URL, value string, start time.Time) {
+	draining, _ := strconv.ParseBool(value)
+	key := server.String()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.lb == nil {
+		return
+	}
+	state, ok := d.draining[key]
+	if !draining {
+		if ok && start.After(state.since) {
+			log.Debugf("Server %s stopped draining", key)
+			d.enable(key, server)
+		}
+		return
+	}
+	if ok {
+		return
+	}
+
+	if len(d.lb.Servers()) <= 1 {
+		log.Warnf("Not draining server %s, the last one of its load balancer", key)
+		return
+	}
+	// The servers removed by the health check, or by a new configuration, are not added back.
+	if err := d.lb.RemoveServer(server); err != nil {
+		return
+	}
+	log.Debugf("Draining server %s", key)
+	d.draining[key] = &drainingServer{
+		since: d.now(),
+		timer: time.AfterFunc(d.timeout, func() {
+			d.lock.Lock()
+			defer d.lock.Unlock()
+			if _, ok := d.draining[key]; ok {
+				log.Debugf("Draining timeout of server %s", key)
+				d.enable(key, server)
+			}
+		}),
+	}
+}
+
+// enable adds back a draining server to the load balancer, d.lock being held.
+func (d *ServerDrainer) enable(key string, server *url.URL) {
+	d.draining[key].timer.Stop()
+	delete(d.draining, key)
+	weight := d.weights[key]
+	if weight <= 0 {
+		weight = 1
+	}
+	if err := d.lb.UpsertServer(server, roundrobin.Weight(weight)); err != nil {
+		log.Errorf("Error adding back drained server %s: %v", key, err)
+	}
+}
+
+// drainResponseWriter reports whether the server is draining from the response headers, and removes it from them.
+type drainResponseWriter struct {
+	http.ResponseWriter
+	drainer     *ServerDrainer
+	server      *url.URL
+	start       time.Time
+	wroteHeader bool
+}
+
+func (w *drainResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.drainer.report(w.server, w.Header().Get(w.drainer.header), w.start)
+	w.Header().Del(w.drainer.header)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *drainResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (w *drainResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *drainResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *drainResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
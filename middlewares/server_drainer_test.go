@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// drainingBackend answers with the drain header for the draining servers, and counts the requests by server.
+type drainingBackend struct {
+	lock     sync.Mutex
+	draining map[string]bool
+	requests map[string]int
+}
+
+func (b *drainingBackend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.requests[req.URL.Host]++
+	if b.draining[req.URL.Host] {
+		rw.Header().Set("X-Draining", "true")
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (b *drainingBackend) setDraining(server string, draining bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.draining[server] = draining
+}
+
+func (b *drainingBackend) resetRequests() map[string]int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	requests := b.requests
+	b.requests = make(map[string]int)
+	return requests
+}
+
+func newDrainedBalancer(t *testing.T, backend http.Handler, timeout time.Duration) (*ServerDrainer, *roundrobin.RoundRobin) {
+	drainer := NewServerDrainer(backend, "", timeout, map[string]int{"http://server1": 2})
+	rr, err := roundrobin.New(drainer)
+	require.NoError(t, err)
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://server1"), roundrobin.Weight(2)))
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://server2"), roundrobin.Weight(1)))
+	drainer.SetLoadBalancer(rr)
+	return drainer, rr
+}
+
+func serveDrained(t *testing.T, handler http.Handler, count int) {
+	for i := 0; i < count; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("X-Draining"), "the drain header must be removed from the responses")
+	}
+}
+
+func TestServerDrainer(t *testing.T) {
+	backend := &drainingBackend{draining: map[string]bool{"server1": true}, requests: make(map[string]int)}
+	_, rr := newDrainedBalancer(t, backend, time.Hour)
+
+	// The draining server stops receiving new requests once it answered with the header.
+	serveDrained(t, rr, 10)
+	requests := backend.resetRequests()
+	assert.Equal(t, 1, requests["server1"])
+	assert.Equal(t, 9, requests["server2"])
+	assert.Len(t, rr.Servers(), 1)
+
+	serveDrained(t, rr, 10)
+	assert.Equal(t, map[string]int{"server2": 10}, backend.resetRequests())
+}
+
+func TestServerDrainerTimeout(t *testing.T) {
+	backend := &drainingBackend{draining: map[string]bool{"server1": true}, requests: make(map[string]int)}
+	_, rr := newDrainedBalancer(t, backend, 20*time.Millisecond)
+
+	serveDrained(t, rr, 3)
+	require.Len(t, rr.Servers(), 1)
+
+	// The server is added back with its weight after the timeout, once it stopped draining.
+	backend.setDraining("server1", false)
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, rr.Servers(), 2)
+	weight, ok := rr.ServerWeight(testhelpers.MustParseURL("http://server1"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, weight)
+
+	backend.resetRequests()
+	serveDrained(t, rr, 30)
+	assert.Equal(t, map[string]int{"server1": 20, "server2": 10}, backend.resetRequests())
+}
+
+func TestServerDrainerStoppedDraining(t *testing.T) {
+	backend := &drainingBackend{draining: map[string]bool{"server1": true}, requests: make(map[string]int)}
+	drainer, rr := newDrainedBalancer(t, backend, time.Hour)
+
+	serveDrained(t, rr, 3)
+	require.Len(t, rr.Servers(), 1)
+
+	// A response without the header, to a request sent while the server was draining, adds it back.
+	backend.setDraining("server1", false)
+	start := drainer.now()
+	drainer.report(testhelpers.MustParseURL("http://server1"), "", start.Add(-time.Hour))
+	assert.Len(t, rr.Servers(), 1, "the request was sent before the server started draining")
+	drainer.report(testhelpers.MustParseURL("http://server1"), "", start)
+	assert.Len(t, rr.Servers(), 2)
+}
+
+func TestServerDrainerLastServer(t *testing.T) {
+	backend := &drainingBackend{draining: map[string]bool{"server1": true, "server2": true}, requests: make(map[string]int)}
+	_, rr := newDrainedBalancer(t, backend, time.Hour)
+
+	serveDrained(t, rr, 10)
+	assert.Len(t, rr.Servers(), 1, "the last server must not be drained")
+}
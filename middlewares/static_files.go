@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+// StaticFiles is a middleware serving small files, e.g. a robots.txt, from their content by path, before the frontends
+// of the entrypoint are matched and their redirects applied. Only the GET and HEAD requests are served, the other ones
+// being passed to the next handler.
+type StaticFiles struct {
+	files   map[string][]byte
+	modTime time.Time
+}
+
+// NewStaticFiles creates a new StaticFiles serving the content of the files by path.
+func NewStaticFiles(files map[string][]byte) *StaticFiles {
+	return &StaticFiles{files: files, modTime: time.Now()}
+}
+
+func (s *StaticFiles) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	content, ok := s.files[r.URL.Path]
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		next(rw, r)
+		return
+	}
+	// The content type is detected from the extension of the path, or else from the content.
+	http.ServeContent(rw, r, r.URL.Path, s.modTime, bytes.NewReader(content))
+}
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticFiles(t *testing.T) {
+	staticFiles := NewStaticFiles(map[string][]byte{
+		"/robots.txt": []byte("User-agent: *\nDisallow: /"),
+	})
+	next := func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}
+
+	testCases := []struct {
+		desc         string
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			desc:         "static file",
+			method:       http.MethodGet,
+			path:         "/robots.txt",
+			expectedCode: http.StatusOK,
+			expectedBody: "User-agent: *\nDisallow: /",
+		},
+		{
+			desc:         "static file head",
+			method:       http.MethodHead,
+			path:         "/robots.txt",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "other method",
+			method:       http.MethodPost,
+			path:         "/robots.txt",
+			expectedCode: http.StatusTeapot,
+		},
+		{
+			desc:         "other path",
+			method:       http.MethodGet,
+			path:         "/robots.txt/",
+			expectedCode: http.StatusTeapot,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			staticFiles.ServeHTTP(recorder, testhelpers.MustNewRequest(test.method, "http://example.com"+test.path, nil), next)
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			assert.Equal(t, test.expectedBody, recorder.Body.String())
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -23,10 +23,13 @@ var statsdTicker *time.Ticker
 // and the number of requests partitioned by status code and method.
 // - number of requests partitioned by status code and method
 // - request durations
+// - request and response body sizes
 // - amount of retries happened
 type Statsd struct {
 	reqsCounter          metrics.Counter
 	reqDurationHistogram metrics.Histogram
+	reqSizeHistogram     metrics.Histogram
+	respSizeHistogram    metrics.Histogram
 	retryCounter         metrics.Counter
 }
 
@@ -38,6 +41,14 @@ func (s *Statsd) getReqDurationHistogram() metrics.Histogram {
 	return s.reqDurationHistogram
 }
 
+func (s *Statsd) getReqSizeHistogram() metrics.Histogram {
+	return s.reqSizeHistogram
+}
+
+func (s *Statsd) getRespSizeHistogram() metrics.Histogram {
+	return s.respSizeHistogram
+}
+
 func (s *Statsd) getRetryCounter() metrics.Counter {
 	return s.retryCounter
 }
@@ -48,6 +59,8 @@ func NewStatsD(name string) *Statsd {
 
 	m.reqsCounter = statsdClient.NewCounter(ddMetricsReqsName, 1.0).With("service", name)
 	m.reqDurationHistogram = statsdClient.NewTiming(ddMetricsLatencyName, 1.0).With("service", name)
+	m.reqSizeHistogram = statsdClient.NewTiming(ddMetricsReqSizeName, 1.0).With("service", name)
+	m.respSizeHistogram = statsdClient.NewTiming(ddMetricsRespSizeName, 1.0).With("service", name)
 
 	return &m
 }
@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// StatusRewrite is a middleware replacing the status codes of the responses, e.g. to remap the non-standard
+// status codes returned by a backend. The headers and bodies of the responses are left untouched.
+type StatusRewrite struct {
+	rewrites map[int]int
+}
+
+// NewStatusRewrite creates a StatusRewrite middleware from the replacement status codes, by status code.
+func NewStatusRewrite(rewrites map[string]int) (*StatusRewrite, error) {
+	statusRewrite := &StatusRewrite{rewrites: make(map[int]int, len(rewrites))}
+	for code, replacement := range rewrites {
+		statusCode, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q: %s", code, err)
+		}
+		if replacement < 100 || replacement > 999 {
+			return nil, fmt.Errorf("invalid replacement status code %d for %d", replacement, statusCode)
+		}
+		statusRewrite.rewrites[statusCode] = replacement
+	}
+	return statusRewrite, nil
+}
+
+func (s *StatusRewrite) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(&statusRewriteResponseWriter{ResponseWriter: rw, rewrites: s.rewrites}, r)
+}
+
+// statusRewriteResponseWriter replaces the status code of the response when it is written.
+type statusRewriteResponseWriter struct {
+	http.ResponseWriter
+	rewrites    map[int]int
+	wroteHeader bool
+}
+
+func (w *statusRewriteResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if replacement, ok := w.rewrites[code]; ok {
+		code = replacement
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRewriteResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack hijacks the connection
+func (w *statusRewriteResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify returns a channel that receives at most a
+// single value (true) when the client connection has gone
+// away.
+func (w *statusRewriteResponseWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush sends any buffered data to the client.
+func (w *statusRewriteResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
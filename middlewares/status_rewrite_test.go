@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusRewrite(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		status       int
+		expectedCode int
+	}{
+		{
+			desc:         "mapped status",
+			status:       http.StatusTeapot,
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "other mapped status",
+			status:       http.StatusBadGateway,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc:         "unmapped status untouched",
+			status:       http.StatusNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			statusRewrite, err := NewStatusRewrite(map[string]int{"418": http.StatusOK, "502": http.StatusServiceUnavailable})
+			require.NoError(t, err)
+
+			next := func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("X-Backend", "teapot")
+				rw.WriteHeader(test.status)
+				rw.Write([]byte("backend body"))
+			}
+
+			recorder := httptest.NewRecorder()
+			statusRewrite.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://localhost", nil), next)
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			assert.Equal(t, "backend body", recorder.Body.String())
+			assert.Equal(t, "teapot", recorder.Header().Get("X-Backend"))
+		})
+	}
+}
+
+func TestNewStatusRewriteInvalid(t *testing.T) {
+	_, err := NewStatusRewrite(map[string]int{"teapot": http.StatusOK})
+	assert.Error(t, err)
+
+	_, err = NewStatusRewrite(map[string]int{"418": 42})
+	assert.Error(t, err)
+}
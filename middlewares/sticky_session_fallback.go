@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+)
+
+// StickySessionFallback is a middleware dropping the sticky session cookie of the requests
+// pinned to a server that is no longer in the load balancer (removed from the backend or by
+// its health check), or whose value is not a valid URL.
+// The load balancer then picks a new server for the request and resets the cookie, instead of failing.
+type StickySessionFallback struct {
+	lb         healthcheck.LoadBalancer
+	cookieName string
+	next       http.Handler
+}
+
+// NewStickySessionFallback creates a new StickySessionFallback for the sticky sessions
+// of the load balancer, stored in the cookieName cookie.
+func NewStickySessionFallback(lb healthcheck.LoadBalancer, cookieName string, next http.Handler) *StickySessionFallback {
+	return &StickySessionFallback{lb: lb, cookieName: cookieName, next: next}
+}
+
+func (s *StickySessionFallback) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(s.cookieName)
+	if err != nil || s.isServerAvailable(cookie.Value) {
+		s.next.ServeHTTP(rw, r)
+		return
+	}
+
+	log.Debugf("Sticky session server %s is not available anymore, rebalancing", cookie.Value)
+	outReq := new(http.Request)
+	*outReq = *r
+	outReq.Header = make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		if name != "Cookie" {
+			outReq.Header[name] = values
+		}
+	}
+	for _, c := range r.Cookies() {
+		if c.Name != s.cookieName {
+			outReq.AddCookie(c)
+		}
+	}
+	s.next.ServeHTTP(rw, outReq)
+}
+
+func (s *StickySessionFallback) isServerAvailable(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	for _, server := range s.lb.Servers() {
+		if server.Scheme == u.Scheme && server.Host == u.Host && server.Path == u.Path {
+			return true
+		}
+	}
+	return false
+}
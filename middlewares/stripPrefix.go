@@ -34,11 +34,20 @@ func (s *StripPrefix) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *StripPrefix) serveRequest(w http.ResponseWriter, r *http.Request, prefix string) {
-	r.Header.Add(ForwardedPrefixHeader, prefix)
+	addForwardedPrefix(r, prefix)
 	r.RequestURI = r.URL.RequestURI()
 	s.Handler.ServeHTTP(w, r)
 }
 
+// addForwardedPrefix sets the prefix stripped from the request in the X-Forwarded-Prefix header, after the prefix
+// stripped by a previous middleware or proxy, if any, for the header to hold the whole prefix stripped from the path.
+func addForwardedPrefix(r *http.Request, prefix string) {
+	if forwarded := r.Header.Get(ForwardedPrefixHeader); len(forwarded) > 0 {
+		prefix = strings.TrimSuffix(forwarded, "/") + "/" + strings.TrimPrefix(prefix, "/")
+	}
+	r.Header.Set(ForwardedPrefixHeader, prefix)
+}
+
 // SetHandler sets handler
 func (s *StripPrefix) SetHandler(Handler http.Handler) {
 	s.Handler = Handler
@@ -113,3 +113,22 @@ func TestStripPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestStripPrefixForwardedPrefix(t *testing.T) {
+	var actualPath, actualHeader string
+	handler := &StripPrefix{
+		Prefixes: []string{"/v1"},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actualPath = r.URL.Path
+			actualHeader = r.Header.Get(ForwardedPrefixHeader)
+		}),
+	}
+
+	// The prefix already stripped by a proxy in front comes first.
+	req := testhelpers.MustNewRequest(http.MethodGet, "http://localhost/v1/users", nil)
+	req.Header.Set(ForwardedPrefixHeader, "/api/")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "/users", actualPath)
+	assert.Equal(t, "/api/v1", actualHeader)
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// TrailingSlashRedirect is a middleware redirecting permanently the requests to a set of paths
+// to the same paths ending with a slash, the other requests being passed to the next handler.
+type TrailingSlashRedirect struct {
+	next  http.Handler
+	paths map[string]bool
+}
+
+// NewTrailingSlashRedirect creates a new TrailingSlashRedirect for the paths.
+func NewTrailingSlashRedirect(next http.Handler, paths []string) *TrailingSlashRedirect {
+	redirect := &TrailingSlashRedirect{next: next, paths: make(map[string]bool)}
+	for _, path := range paths {
+		redirect.paths[path] = true
+	}
+	return redirect
+}
+
+func (t *TrailingSlashRedirect) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !t.paths[r.URL.Path] {
+		t.next.ServeHTTP(rw, r)
+		return
+	}
+	u := *r.URL
+	u.Path += "/"
+	if len(u.RawPath) > 0 {
+		u.RawPath += "/"
+	}
+	http.Redirect(rw, r, u.String(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrailingSlashRedirect(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		url              string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			desc:             "redirected path",
+			url:              "http://foo.bar/products?sort=asc",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "http://foo.bar/products/?sort=asc",
+		},
+		{
+			desc:           "path with a trailing slash",
+			url:            "http://foo.bar/products/",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "other path",
+			url:            "http://foo.bar/products/shoes",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	redirect := NewTrailingSlashRedirect(next, []string{"/products"})
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			redirect.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, test.url, nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vulcand/oxy/forward"
+)
+
+type websocketSubprotocolsKey struct{}
+
+// NewWebsocketSubprotocols returns a handler keeping the subprotocols offered by the websocket clients with their
+// requests, the forwarder removing the Sec-WebSocket-Protocol header from the requests dialing the backends.
+// They are offered back to the backends by the WebsocketSubprotocolsRewriter of the forwarder, the subprotocol
+// selected by a backend being relayed to the client with its upgrade response.
+func NewWebsocketSubprotocols(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		subprotocols, ok := req.Header[forward.SecWebsocketProtocol]
+		if !ok {
+			next.ServeHTTP(rw, req)
+			return
+		}
+		next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), websocketSubprotocolsKey{}, subprotocols)))
+	})
+}
+
+// WebsocketSubprotocolsRewriter is a websocket request rewriter of the forwarder, offering the subprotocols kept
+// by NewWebsocketSubprotocols to the backends.
+type WebsocketSubprotocolsRewriter struct {
+	next forward.ReqRewriter
+}
+
+// NewWebsocketSubprotocolsRewriter creates a WebsocketSubprotocolsRewriter setting the subprotocols once the request
+// has been rewritten by next, if not nil.
+func NewWebsocketSubprotocolsRewriter(next forward.ReqRewriter) *WebsocketSubprotocolsRewriter {
+	return &WebsocketSubprotocolsRewriter{next: next}
+}
+
+// Rewrite sets the Sec-WebSocket-Protocol header of the request dialing the backend.
+func (r *WebsocketSubprotocolsRewriter) Rewrite(req *http.Request) {
+	if r.next != nil {
+		r.next.Rewrite(req)
+	}
+	if subprotocols, ok := req.Context().Value(websocketSubprotocolsKey{}).([]string); ok {
+		req.Header[forward.SecWebsocketProtocol] = subprotocols
+	}
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/forward"
+)
+
+func TestWebsocketSubprotocols(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"chat.v2", "chat.v1"}}
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer backend.Close()
+
+	fwd, err := forward.New(forward.WebsocketRewriter(NewWebsocketSubprotocolsRewriter(nil)))
+	require.NoError(t, err)
+	backendURL := testhelpers.MustParseURL(backend.URL)
+	proxy := httptest.NewServer(NewWebsocketSubprotocols(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.URL = backendURL
+		fwd.ServeHTTP(rw, req)
+	})))
+	defer proxy.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"chat.v1", "chat.v2"}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "chat.v2", resp.Header.Get("Sec-Websocket-Protocol"))
+	assert.Equal(t, "chat.v2", conn.Subprotocol())
+}
+
+func TestWebsocketSubprotocolsRewriterNoSubprotocols(t *testing.T) {
+	req := testhelpers.MustNewRequest(http.MethodGet, "ws://backend/", nil)
+	NewWebsocketSubprotocolsRewriter(NewHostRewriter(nil, "internal.svc")).Rewrite(req)
+
+	assert.Equal(t, "internal.svc", req.Host)
+	_, ok := req.Header[forward.SecWebsocketProtocol]
+	assert.False(t, ok)
+}
@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/containous/traefik/healthcheck"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// ZoneAffinity is a middleware sending the requests to the servers of the load balancer located in the zone of
+// Traefik, balanced by smooth weighted round robin. The requests fall back to next, balancing them across all the
+// zones, when none of the local servers is in the load balancer anymore (removed by its health check or drained).
+// The requests pinned by the sticky session to an available server are sent to next, the new ones are pinned
+// to the local server they are sent to.
+type ZoneAffinity struct {
+	lb      healthcheck.LoadBalancer
+	sticky  *roundrobin.StickySession
+	forward http.Handler
+	next    http.Handler
+	// weights are the configured weights of the local servers, by URL.
+	weights map[string]int
+
+	lock    sync.Mutex
+	current map[string]int
+}
+
+// NewZoneAffinity creates a new ZoneAffinity sending the requests to the local servers of the load balancer, given
+// with their weights by URL, with forward, and the other ones to next. The sticky session is optional.
+func NewZoneAffinity(lb healthcheck.LoadBalancer, sticky *roundrobin.StickySession, weights map[string]int, forward http.Handler, next http.Handler) *ZoneAffinity {
+	return &ZoneAffinity{
+		lb:      lb,
+		sticky:  sticky,
+		forward: forward,
+		next:    next,
+		weights: weights,
+		current: make(map[string]int, len(weights)),
+	}
+}
+
+func (z *ZoneAffinity) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if z.sticky != nil {
+		if _, ok, _ := z.sticky.GetBackend(r, z.lb.Servers()); ok {
+			z.next.ServeHTTP(rw, r)
+			return
+		}
+	}
+	server := z.pickServer()
+	if server == nil {
+		z.next.ServeHTTP(rw, r)
+		return
+	}
+	if z.sticky != nil {
+		z.sticky.StickBackend(server, &rw)
+	}
+
+	// make shallow copy of request before changing anything to avoid side effects
+	newReq := *r
+	newReq.URL = server
+	z.forward.ServeHTTP(rw, &newReq)
+}
+
+// pickServer returns the next local server of the load balancer by smooth weighted round robin, if any.
+func (z *ZoneAffinity) pickServer() *url.URL {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	var picked *url.URL
+	var pickedKey string
+	total := 0
+	for _, server := range z.lb.Servers() {
+		key := server.String()
+		weight, ok := z.weights[key]
+		if !ok {
+			continue
+		}
+		if weight <= 0 {
+			weight = 1
+		}
+		z.current[key] += weight
+		total += weight
+		if picked == nil || z.current[key] > z.current[pickedKey] {
+			picked = server
+			pickedKey = key
+		}
+	}
+	if picked != nil {
+		z.current[pickedKey] -= total
+	}
+	return picked
+}
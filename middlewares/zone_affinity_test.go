@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+// zoneBackend counts the requests by server.
+type zoneBackend map[string]int
+
+func (b zoneBackend) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b[req.URL.Host]++
+	rw.WriteHeader(http.StatusOK)
+}
+
+func newZoneBalancer(t *testing.T, backend http.Handler, sticky *roundrobin.StickySession) (*ZoneAffinity, *roundrobin.RoundRobin) {
+	var options []roundrobin.LBOption
+	if sticky != nil {
+		options = append(options, roundrobin.EnableStickySession(sticky))
+	}
+	rr, err := roundrobin.New(backend, options...)
+	require.NoError(t, err)
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://local1"), roundrobin.Weight(2)))
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://local2"), roundrobin.Weight(1)))
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://remote"), roundrobin.Weight(1)))
+	weights := map[string]int{"http://local1": 2, "http://local2": 1}
+	return NewZoneAffinity(rr, sticky, weights, backend, rr), rr
+}
+
+func TestZoneAffinity(t *testing.T) {
+	backend := zoneBackend{}
+	zone, rr := newZoneBalancer(t, backend, nil)
+
+	serve := func(count int) {
+		for i := 0; i < count; i++ {
+			recorder := httptest.NewRecorder()
+			zone.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		}
+	}
+
+	serve(30)
+	assert.Equal(t, zoneBackend{"local1": 20, "local2": 10}, backend)
+
+	// The local servers removed by the health check do not receive requests anymore.
+	require.NoError(t, rr.RemoveServer(testhelpers.MustParseURL("http://local1")))
+	serve(10)
+	assert.Equal(t, zoneBackend{"local1": 20, "local2": 20}, backend)
+
+	// The requests cross zones once no local server is available.
+	require.NoError(t, rr.RemoveServer(testhelpers.MustParseURL("http://local2")))
+	serve(10)
+	assert.Equal(t, zoneBackend{"local1": 20, "local2": 20, "remote": 10}, backend)
+
+	// They go back to the local servers when they recover.
+	require.NoError(t, rr.UpsertServer(testhelpers.MustParseURL("http://local2"), roundrobin.Weight(1)))
+	serve(10)
+	assert.Equal(t, zoneBackend{"local1": 20, "local2": 30, "remote": 10}, backend)
+}
+
+func TestZoneAffinityStickySession(t *testing.T) {
+	backend := zoneBackend{}
+	zone, _ := newZoneBalancer(t, backend, roundrobin.NewStickySession("sticky"))
+
+	// The new sessions are pinned to a local server.
+	recorder := httptest.NewRecorder()
+	zone.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "http://local1", cookies[0].Value)
+
+	// The existing sessions stay on their server, even in another zone.
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "sticky", Value: "http://remote"})
+		zone.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, zoneBackend{"local1": 1, "remote": 10}, backend)
+}
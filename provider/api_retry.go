@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/log"
+)
+
+// DefaultAPIRetryBackoff is the delay before the first retry of a failed API call, when not configured.
+const DefaultAPIRetryBackoff = time.Second
+
+// APIRetry holds the retries of the failed calls of a provider to its API, with an exponential backoff.
+type APIRetry struct {
+	Attempts int            `description:"Number of attempts of the API calls, including the first one"`
+	Backoff  flaeg.Duration `description:"Delay before the first retry of a failed API call, doubled after each one"`
+}
+
+// Do calls the API until it succeeds or the attempts are exhausted, and returns the last error.
+// The call is only done once when the APIRetry is nil.
+func (r *APIRetry) Do(name string, call func() error) error {
+	attempts := 1
+	delay := DefaultAPIRetryBackoff
+	if r != nil {
+		if r.Attempts > 1 {
+			attempts = r.Attempts
+		}
+		if r.Backoff > 0 {
+			delay = time.Duration(r.Backoff)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= attempts {
+			return err
+		}
+		log.Warnf("%s failed (attempt %d of %d): %s, retrying in %s", name, attempt, attempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
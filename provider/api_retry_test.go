@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIRetryDo(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		retry            *APIRetry
+		failures         int
+		expectedError    bool
+		expectedAttempts int
+	}{
+		{
+			desc:             "no retry",
+			failures:         1,
+			expectedError:    true,
+			expectedAttempts: 1,
+		},
+		{
+			desc:             "success after retries",
+			retry:            &APIRetry{Attempts: 3, Backoff: flaeg.Duration(time.Millisecond)},
+			failures:         2,
+			expectedAttempts: 3,
+		},
+		{
+			desc:             "attempts exhausted",
+			retry:            &APIRetry{Attempts: 3, Backoff: flaeg.Duration(time.Millisecond)},
+			failures:         5,
+			expectedError:    true,
+			expectedAttempts: 3,
+		},
+		{
+			desc:             "immediate success",
+			retry:            &APIRetry{Attempts: 3, Backoff: flaeg.Duration(time.Millisecond)},
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts int
+			err := test.retry.Do("test call", func() error {
+				attempts++
+				if attempts <= test.failures {
+					return errors.New("API error")
+				}
+				return nil
+			})
+			if test.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedAttempts, attempts)
+		})
+	}
+}
@@ -2,7 +2,8 @@ package consul
 
 import (
 	"bytes"
-	"errors"
+	"net"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/BurntSushi/ty/fun"
 	"github.com/cenk/backoff"
+	"github.com/containous/flaeg"
 	"github.com/containous/traefik/job"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/provider"
@@ -29,19 +31,32 @@ var _ provider.Provider = (*CatalogProvider)(nil)
 // CatalogProvider holds configurations of the Consul catalog provider.
 type CatalogProvider struct {
 	provider.BaseProvider `mapstructure:",squash"`
-	Endpoint              string `description:"Consul server endpoint"`
-	Domain                string `description:"Default domain used"`
-	Prefix                string `description:"Prefix used for Consul catalog tags"`
-	FrontEndRule          string `description:"Frontend rule used for Consul services"`
+	Endpoint              string             `description:"Consul server endpoint"`
+	Domain                string             `description:"Default domain used"`
+	Prefix                string             `description:"Prefix used for Consul catalog tags"`
+	FrontEndRule          string             `description:"Frontend rule used for Consul services"`
+	Datacenters           Datacenters        `description:"Consul datacenters to discover services in, defaults to the agent one"`
+	DialTimeout           flaeg.Duration     `description:"Timeout to connect to the Consul API"`
+	ReadTimeout           flaeg.Duration     `description:"Timeout of the responses of the Consul API, added to the wait time of the watches"`
+	Retry                 *provider.APIRetry `description:"Retry the failed calls to the Consul API"`
+	DetectHTTPSPort       bool               `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
 	client                *api.Client
-	frontEndRuleTemplate  *template.Template
+	// watchClient sends the blocking queries of the watches, waiting up to DefaultWatchWaitTime.
+	watchClient          *api.Client
+	frontEndRuleTemplate *template.Template
 }
 
 type serviceUpdate struct {
 	ServiceName string
+	Datacenter  string
 	Attributes  []string
 }
 
+type datacenterIndex struct {
+	Datacenter string
+	Services   map[string][]string
+}
+
 type catalogUpdate struct {
 	Service *serviceUpdate
 	Nodes   []*api.ServiceEntry
@@ -86,15 +101,15 @@ func getChangedKeys(currState map[string][]string, prevState map[string][]string
 	return fun.Keys(addedKeys).([]string), fun.Keys(removedKeys).([]string)
 }
 
-func (p *CatalogProvider) watchHealthState(stopCh <-chan struct{}, watchCh chan<- map[string][]string) {
-	health := p.client.Health()
+func (p *CatalogProvider) watchHealthState(datacenter string, stopCh <-chan struct{}, watchCh chan<- datacenterIndex, errorCh chan<- error) {
+	health := p.watchClient.Health()
 	catalog := p.client.Catalog()
 
 	safe.Go(func() {
 		// variable to hold previous state
 		var flashback map[string][]string
 
-		options := &api.QueryOptions{WaitTime: DefaultWatchWaitTime}
+		options := &api.QueryOptions{WaitTime: DefaultWatchWaitTime, Datacenter: datacenter}
 
 		for {
 			select {
@@ -106,9 +121,15 @@ func (p *CatalogProvider) watchHealthState(stopCh <-chan struct{}, watchCh chan<
 			// Listening to changes that leads to `passing` state or degrades from it.
 			// The call is used just as a trigger for further actions
 			// (intentionally there is no interest in the received data).
-			_, meta, err := health.State("passing", options)
+			var meta *api.QueryMeta
+			err := p.Retry.Do("Consul health checks retrieval", func() error {
+				var err error
+				_, meta, err = health.State("passing", options)
+				return err
+			})
 			if err != nil {
 				log.WithError(err).Error("Failed to retrieve health checks")
+				errorCh <- err
 				return
 			}
 
@@ -121,9 +142,15 @@ func (p *CatalogProvider) watchHealthState(stopCh <-chan struct{}, watchCh chan<
 			options.WaitIndex = meta.LastIndex
 
 			// The response should be unified with watchCatalogServices
-			data, _, err := catalog.Services(&api.QueryOptions{})
+			var data map[string][]string
+			err = p.Retry.Do("Consul services listing", func() error {
+				var err error
+				data, _, err = catalog.Services(&api.QueryOptions{Datacenter: datacenter})
+				return err
+			})
 			if err != nil {
 				log.Errorf("Failed to list services: %s", err)
+				errorCh <- err
 				return
 			}
 
@@ -135,13 +162,21 @@ func (p *CatalogProvider) watchHealthState(stopCh <-chan struct{}, watchCh chan<
 
 				if len(addedKeys) > 0 {
 					log.WithField("DiscoveredServices", addedKeys).Debug("Health State change detected.")
-					watchCh <- data
+					select {
+					case watchCh <- datacenterIndex{Datacenter: datacenter, Services: data}:
+					case <-stopCh:
+						return
+					}
 					flashback = data
 				}
 
 				if len(removedKeys) > 0 {
 					log.WithField("MissingServices", removedKeys).Debug("Health State change detected.")
-					watchCh <- data
+					select {
+					case watchCh <- datacenterIndex{Datacenter: datacenter, Services: data}:
+					case <-stopCh:
+						return
+					}
 					flashback = data
 				}
 			}
@@ -149,14 +184,14 @@ func (p *CatalogProvider) watchHealthState(stopCh <-chan struct{}, watchCh chan<
 	})
 }
 
-func (p *CatalogProvider) watchCatalogServices(stopCh <-chan struct{}, watchCh chan<- map[string][]string) {
-	catalog := p.client.Catalog()
+func (p *CatalogProvider) watchCatalogServices(datacenter string, stopCh <-chan struct{}, watchCh chan<- datacenterIndex, errorCh chan<- error) {
+	catalog := p.watchClient.Catalog()
 
 	safe.Go(func() {
 		// variable to hold previous state
 		var flashback map[string][]string
 
-		options := &api.QueryOptions{WaitTime: DefaultWatchWaitTime}
+		options := &api.QueryOptions{WaitTime: DefaultWatchWaitTime, Datacenter: datacenter}
 
 		for {
 			select {
@@ -165,9 +200,16 @@ func (p *CatalogProvider) watchCatalogServices(stopCh <-chan struct{}, watchCh c
 			default:
 			}
 
-			data, meta, err := catalog.Services(options)
+			var data map[string][]string
+			var meta *api.QueryMeta
+			err := p.Retry.Do("Consul services listing", func() error {
+				var err error
+				data, meta, err = catalog.Services(options)
+				return err
+			})
 			if err != nil {
 				log.Errorf("Failed to list services: %s", err)
+				errorCh <- err
 				return
 			}
 
@@ -185,13 +227,21 @@ func (p *CatalogProvider) watchCatalogServices(stopCh <-chan struct{}, watchCh c
 
 				if len(addedKeys) > 0 {
 					log.WithField("DiscoveredServices", addedKeys).Debug("Catalog Services change detected.")
-					watchCh <- data
+					select {
+					case watchCh <- datacenterIndex{Datacenter: datacenter, Services: data}:
+					case <-stopCh:
+						return
+					}
 					flashback = data
 				}
 
 				if len(removedKeys) > 0 {
 					log.WithField("MissingServices", removedKeys).Debug("Catalog Services change detected.")
-					watchCh <- data
+					select {
+					case watchCh <- datacenterIndex{Datacenter: datacenter, Services: data}:
+					case <-stopCh:
+						return
+					}
 					flashback = data
 				}
 			}
@@ -199,18 +249,33 @@ func (p *CatalogProvider) watchCatalogServices(stopCh <-chan struct{}, watchCh c
 	})
 }
 
-func (p *CatalogProvider) healthyNodes(service string) (catalogUpdate, error) {
+func (p *CatalogProvider) healthyNodes(service string, datacenter string) (catalogUpdate, error) {
 	health := p.client.Health()
-	opts := &api.QueryOptions{}
-	data, _, err := health.Service(service, "", true, opts)
+	opts := &api.QueryOptions{Datacenter: datacenter}
+	var data []*api.ServiceEntry
+	err := p.Retry.Do("Consul service "+service+" retrieval", func() error {
+		var err error
+		data, _, err = health.Service(service, "", true, opts)
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Errorf("Failed to fetch details of %s", service)
 		return catalogUpdate{}, err
 	}
 
+	serviceName := service
+	if len(p.Datacenters) > 1 {
+		// The same service can be registered in several datacenters,
+		// qualify its name so that each one gets its own backend and frontend.
+		serviceName = service + "." + datacenter
+		for _, node := range data {
+			node.Service.Service = serviceName
+		}
+	}
+
 	nodes := fun.Filter(func(node *api.ServiceEntry) bool {
 		constraintTags := p.getConstraintTags(node.Service.Tags)
-		ok, failingConstraint := p.MatchConstraints(constraintTags)
+		ok, failingConstraint := p.MatchConstraintsWithMetadata(constraintTags, getConstraintMetadata(node.Service.Tags))
 		if !ok && failingConstraint != nil {
 			log.Debugf("Service %v pruned by '%v' constraint", service, failingConstraint.String())
 		}
@@ -227,7 +292,8 @@ func (p *CatalogProvider) healthyNodes(service string) (catalogUpdate, error) {
 
 	return catalogUpdate{
 		Service: &serviceUpdate{
-			ServiceName: service,
+			ServiceName: serviceName,
+			Datacenter:  datacenter,
 			Attributes:  tags,
 		},
 		Nodes: nodes,
@@ -264,10 +330,12 @@ func (p *CatalogProvider) getFrontendRule(service serviceUpdate) string {
 
 	templateObjects := struct {
 		ServiceName string
+		Datacenter  string
 		Domain      string
 		Attributes  []string
 	}{
 		ServiceName: service.ServiceName,
+		Datacenter:  service.Datacenter,
 		Domain:      p.Domain,
 		Attributes:  service.Attributes,
 	}
@@ -289,6 +357,10 @@ func (p *CatalogProvider) getBackendAddress(node *api.ServiceEntry) string {
 	return node.Node.Address
 }
 
+func (p *CatalogProvider) getProtocol(node *api.ServiceEntry) string {
+	return p.getAttribute("protocol", node.Service.Tags, provider.GetDefaultProtocol(strconv.Itoa(node.Service.Port), p.DetectHTTPSPort))
+}
+
 func (p *CatalogProvider) getBackendName(node *api.ServiceEntry, index int) string {
 	serviceName := strings.ToLower(node.Service.Service) + "--" + node.Service.Address + "--" + strconv.Itoa(node.Service.Port)
 
@@ -348,12 +420,25 @@ func (p *CatalogProvider) getConstraintTags(tags []string) []string {
 	return list
 }
 
+// getConstraintMetadata returns the metadata of a service matched by the constraint expressions,
+// from its tags of the form key=value.
+func getConstraintMetadata(tags []string) map[string]string {
+	metadata := make(map[string]string)
+	for _, tag := range tags {
+		if kv := strings.SplitN(tag, "=", 2); len(kv) == 2 {
+			metadata[kv[0]] = kv[1]
+		}
+	}
+	return metadata
+}
+
 func (p *CatalogProvider) buildConfig(catalog []catalogUpdate) *types.Configuration {
 	var FuncMap = template.FuncMap{
 		"getBackend":           p.getBackend,
 		"getFrontendRule":      p.getFrontendRule,
 		"getBackendName":       p.getBackendName,
 		"getBackendAddress":    p.getBackendAddress,
+		"getProtocol":          p.getProtocol,
 		"getAttribute":         p.getAttribute,
 		"getTag":               p.getTag,
 		"hasTag":               p.hasTag,
@@ -402,48 +487,65 @@ func (p *CatalogProvider) hasMaxconnAttributes(attributes []string) bool {
 	return false
 }
 
-func (p *CatalogProvider) getNodes(index map[string][]string) ([]catalogUpdate, error) {
-	visited := make(map[string]bool)
-
+func (p *CatalogProvider) getNodes(indexes map[string]map[string][]string) ([]catalogUpdate, error) {
 	nodes := []catalogUpdate{}
-	for service := range index {
-		name := strings.ToLower(service)
-		if !strings.Contains(name, " ") && !visited[name] {
-			visited[name] = true
-			log.WithField("service", name).Debug("Fetching service")
-			healthy, err := p.healthyNodes(name)
-			if err != nil {
-				return nil, err
-			}
-			// healthy.Nodes can be empty if constraints do not match, without throwing error
-			if healthy.Service != nil && len(healthy.Nodes) > 0 {
-				nodes = append(nodes, healthy)
+	for _, datacenter := range p.getDatacenters() {
+		visited := make(map[string]bool)
+
+		for service := range indexes[datacenter] {
+			name := strings.ToLower(service)
+			if !strings.Contains(name, " ") && !visited[name] {
+				visited[name] = true
+				log.WithField("service", name).WithField("datacenter", datacenter).Debug("Fetching service")
+				healthy, err := p.healthyNodes(name, datacenter)
+				if err != nil {
+					return nil, err
+				}
+				// healthy.Nodes can be empty if constraints do not match, without throwing error
+				if healthy.Service != nil && len(healthy.Nodes) > 0 {
+					nodes = append(nodes, healthy)
+				}
 			}
 		}
 	}
 	return nodes, nil
 }
 
+// getDatacenters returns the datacenters to discover services in,
+// the empty one standing for the datacenter of the Consul agent.
+func (p *CatalogProvider) getDatacenters() []string {
+	if len(p.Datacenters) > 0 {
+		return []string(p.Datacenters)
+	}
+	return []string{""}
+}
+
 func (p *CatalogProvider) watch(configurationChan chan<- types.ConfigMessage, stop chan bool) error {
 	stopCh := make(chan struct{})
-	watchCh := make(chan map[string][]string)
+	watchCh := make(chan datacenterIndex)
+	// Each watch sends at most one error before returning.
+	errorCh := make(chan error, 2*len(p.getDatacenters()))
 
-	p.watchHealthState(stopCh, watchCh)
-	p.watchCatalogServices(stopCh, watchCh)
+	for _, datacenter := range p.getDatacenters() {
+		p.watchHealthState(datacenter, stopCh, watchCh, errorCh)
+		p.watchCatalogServices(datacenter, stopCh, watchCh, errorCh)
+	}
 
+	// The watches stop sending once stopCh is closed, watchCh is left open for them not to send on a closed channel.
 	defer close(stopCh)
-	defer close(watchCh)
 
+	indexes := make(map[string]map[string][]string)
 	for {
 		select {
 		case <-stop:
 			return nil
-		case index, ok := <-watchCh:
-			if !ok {
-				return errors.New("Consul service list nil")
-			}
-			log.Debug("List of services changed")
-			nodes, err := p.getNodes(index)
+		case err := <-errorCh:
+			// The current configuration is kept until the watch is restarted.
+			return err
+		case index := <-watchCh:
+			log.WithField("datacenter", index.Datacenter).Debug("List of services changed")
+			indexes[index.Datacenter] = index.Services
+			nodes, err := p.getNodes(indexes)
 			if err != nil {
 				return err
 			}
@@ -469,13 +571,16 @@ func (p *CatalogProvider) setupFrontEndTemplate() {
 // Provide allows the consul catalog provider to provide configurations to traefik
 // using the given configuration channel.
 func (p *CatalogProvider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
-	config := api.DefaultConfig()
-	config.Address = p.Endpoint
-	client, err := api.NewClient(config)
+	client, err := p.createClient(0)
 	if err != nil {
 		return err
 	}
 	p.client = client
+	// Consul adds a jitter of up to a sixteenth of the wait time to the blocking queries.
+	p.watchClient, err = p.createClient(DefaultWatchWaitTime + DefaultWatchWaitTime/16)
+	if err != nil {
+		return err
+	}
 	p.Constraints = append(p.Constraints, constraints...)
 	p.setupFrontEndTemplate()
 
@@ -494,3 +599,21 @@ func (p *CatalogProvider) Provide(configurationChan chan<- types.ConfigMessage,
 
 	return err
 }
+
+// createClient creates a client of the Consul API, with the read timeout added to the given wait of its calls.
+func (p *CatalogProvider) createClient(wait time.Duration) (*api.Client, error) {
+	config := api.DefaultConfig()
+	config.Address = p.Endpoint
+	if p.DialTimeout > 0 {
+		if transport, ok := config.HttpClient.Transport.(*http.Transport); ok {
+			transport.DialContext = (&net.Dialer{
+				Timeout:   time.Duration(p.DialTimeout),
+				KeepAlive: 30 * time.Second,
+			}).DialContext
+		}
+	}
+	if p.ReadTimeout > 0 {
+		config.HttpClient.Timeout = wait + time.Duration(p.ReadTimeout)
+	}
+	return api.NewClient(config)
+}
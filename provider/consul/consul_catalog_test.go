@@ -1,14 +1,24 @@
 package consul
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/BurntSushi/ty/fun"
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/types"
 	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConsulCatalogGetFrontendRule(t *testing.T) {
@@ -254,6 +264,65 @@ func TestConsulCatalogGetBackendAddress(t *testing.T) {
 	}
 }
 
+func TestConsulCatalogGetProtocol(t *testing.T) {
+	services := []struct {
+		desc            string
+		tags            []string
+		port            int
+		detectHTTPSPort bool
+		expected        string
+	}{
+		{
+			desc:     "no protocol tag",
+			port:     443,
+			expected: "http",
+		},
+		{
+			desc:     "protocol tag",
+			tags:     []string{"traefik.protocol=https"},
+			port:     80,
+			expected: "https",
+		},
+		{
+			desc:            "HTTPS port detected",
+			port:            443,
+			detectHTTPSPort: true,
+			expected:        "https",
+		},
+		{
+			desc:            "other port",
+			port:            8080,
+			detectHTTPSPort: true,
+			expected:        "http",
+		},
+		{
+			desc:            "protocol tag on HTTPS port",
+			tags:            []string{"traefik.protocol=http"},
+			port:            443,
+			detectHTTPSPort: true,
+			expected:        "http",
+		},
+	}
+
+	for _, e := range services {
+		provider := &CatalogProvider{
+			Domain: "localhost",
+			Prefix: "traefik",
+		}
+		provider.DetectHTTPSPort = e.detectHTTPSPort
+		node := &api.ServiceEntry{
+			Service: &api.AgentService{
+				Tags: e.tags,
+				Port: e.port,
+			},
+		}
+		actual := provider.getProtocol(node)
+		if actual != e.expected {
+			t.Fatalf("%s: expected %s, got %s", e.desc, e.expected, actual)
+		}
+	}
+}
+
 func TestConsulCatalogGetBackendName(t *testing.T) {
 	provider := &CatalogProvider{
 		Domain: "localhost",
@@ -416,6 +485,78 @@ func TestConsulCatalogBuildConfig(t *testing.T) {
 	}
 }
 
+func TestConsulCatalogBuildConfigCustomTemplate(t *testing.T) {
+	provider := &CatalogProvider{
+		BaseProvider: provider.BaseProvider{
+			Template: `[backends]
+{{range .Nodes}}
+  [backends.{{.Service.Service | tolower}}.servers.{{.Node.Node}}]
+  url = "http://{{getBackendAddress .}}:{{.Service.Port}}"
+  weight = {{getAttribute "backend.weight" .Service.Tags "1"}}
+{{end}}
+[frontends]
+{{range .Services}}
+  [frontends.{{.ServiceName | tolower}}]
+  backend = "{{.ServiceName | tolower}}"
+    [frontends.{{.ServiceName | tolower}}.routes.main]
+    rule = "PathPrefix:/{{.ServiceName | tolower}}"
+{{end}}`,
+		},
+		Prefix: "traefik",
+	}
+
+	nodes := []catalogUpdate{
+		{
+			Service: &serviceUpdate{
+				ServiceName: "Orders",
+				Attributes:  []string{},
+			},
+			Nodes: []*api.ServiceEntry{
+				{
+					Service: &api.AgentService{
+						Service: "Orders",
+						Address: "10.0.0.1",
+						Port:    8080,
+						Tags:    []string{"traefik.backend.weight=5"},
+					},
+					Node: &api.Node{
+						Node:    "node1",
+						Address: "10.0.0.1",
+					},
+				},
+			},
+		},
+	}
+
+	actualConfig := provider.buildConfig(nodes)
+	expectedBackends := map[string]*types.Backend{
+		"orders": {
+			Servers: map[string]types.Server{
+				"node1": {
+					URL:    "http://10.0.0.1:8080",
+					Weight: 5,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actualConfig.Backends, expectedBackends) {
+		t.Fatalf("expected %#v, got %#v", expectedBackends, actualConfig.Backends)
+	}
+	expectedFrontends := map[string]*types.Frontend{
+		"orders": {
+			Backend: "orders",
+			Routes: map[string]types.Route{
+				"main": {
+					Rule: "PathPrefix:/orders",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actualConfig.Frontends, expectedFrontends) {
+		t.Fatalf("expected %#v, got %#v", expectedFrontends, actualConfig.Frontends)
+	}
+}
+
 func TestConsulCatalogNodeSorter(t *testing.T) {
 	cases := []struct {
 		nodes    []*api.ServiceEntry
@@ -750,3 +891,183 @@ func TestConsulCatalogGetChangedKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestConsulCatalogGetNodesFromDatacenters(t *testing.T) {
+	entries := map[string][]*api.ServiceEntry{
+		"dc1": {
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.1.1", Port: 80},
+				Node:    &api.Node{Node: "node1", Address: "10.0.1.1"},
+			},
+		},
+		"dc2": {
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.2.1", Port: 80},
+				Node:    &api.Node{Node: "node2", Address: "10.0.2.1"},
+			},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		datacenterEntries, ok := entries[req.URL.Query().Get("dc")]
+		if req.URL.Path != "/v1/health/service/web" || !ok {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("X-Consul-Index", "1")
+		json.NewEncoder(rw).Encode(datacenterEntries)
+	}))
+	defer ts.Close()
+
+	config := api.DefaultConfig()
+	config.Address = strings.TrimPrefix(ts.URL, "http://")
+	client, err := api.NewClient(config)
+	require.NoError(t, err)
+
+	provider := &CatalogProvider{
+		Domain:               "localhost",
+		Prefix:               "traefik",
+		FrontEndRule:         "Host:{{.ServiceName}}.{{.Domain}}",
+		Datacenters:          Datacenters{"dc1", "dc2"},
+		client:               client,
+		frontEndRuleTemplate: template.New("consul catalog frontend rule"),
+	}
+
+	nodes, err := provider.getNodes(map[string]map[string][]string{
+		"dc1": {"web": {}},
+		"dc2": {"web": {}},
+	})
+	require.NoError(t, err)
+
+	configuration := provider.buildConfig(nodes)
+
+	assert.Equal(t, map[string]*types.Backend{
+		"backend-web.dc1": {
+			Servers: map[string]types.Server{
+				"web-dc1--10-0-1-1--80--0": {URL: "http://10.0.1.1:80"},
+			},
+		},
+		"backend-web.dc2": {
+			Servers: map[string]types.Server{
+				"web-dc2--10-0-2-1--80--1": {URL: "http://10.0.2.1:80"},
+			},
+		},
+	}, configuration.Backends)
+	assert.Equal(t, map[string]*types.Frontend{
+		"frontend-web.dc1": {
+			Backend:        "backend-web.dc1",
+			PassHostHeader: true,
+			Routes: map[string]types.Route{
+				"route-host-web.dc1": {Rule: "Host:web.dc1.localhost"},
+			},
+		},
+		"frontend-web.dc2": {
+			Backend:        "backend-web.dc2",
+			PassHostHeader: true,
+			Routes: map[string]types.Route{
+				"route-host-web.dc2": {Rule: "Host:web.dc2.localhost"},
+			},
+		},
+	}, configuration.Frontends)
+}
+
+func TestConsulCatalogGetNodesAPIRetry(t *testing.T) {
+	var lock sync.Mutex
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		calls++
+		call := calls
+		lock.Unlock()
+		switch {
+		case req.URL.Query().Get("dc") == "slow":
+			time.Sleep(500 * time.Millisecond)
+		case call <= 2:
+			http.Error(rw, "Consul unavailable", http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("X-Consul-Index", "1")
+		json.NewEncoder(rw).Encode([]*api.ServiceEntry{{
+			Service: &api.AgentService{Service: "web", Address: "10.0.1.1", Port: 80},
+			Node:    &api.Node{Node: "node1", Address: "10.0.1.1"},
+		}})
+	}))
+	defer ts.Close()
+
+	p := &CatalogProvider{
+		Endpoint:    strings.TrimPrefix(ts.URL, "http://"),
+		ReadTimeout: flaeg.Duration(50 * time.Millisecond),
+		Retry:       &provider.APIRetry{Attempts: 3, Backoff: flaeg.Duration(time.Millisecond)},
+	}
+	client, err := p.createClient(0)
+	require.NoError(t, err)
+	p.client = client
+
+	// The failed calls are retried.
+	nodes, err := p.getNodes(map[string]map[string][]string{"": {"web": {}}})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Len(t, nodes[0].Nodes, 1)
+	lock.Lock()
+	assert.Equal(t, 3, calls)
+	lock.Unlock()
+
+	// The calls time out, and fail once the attempts are exhausted.
+	p.Datacenters = Datacenters{"slow"}
+	start := time.Now()
+	_, err = p.getNodes(map[string]map[string][]string{"slow": {"web": {}}})
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 400*time.Millisecond, "the API calls must time out")
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 6, calls)
+}
+
+func TestConsulCatalogGetNodesConstraintExpression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("X-Consul-Index", "1")
+		json.NewEncoder(rw).Encode([]*api.ServiceEntry{
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.1.1", Port: 80, Tags: []string{"environment=prod", "tier=frontend"}},
+				Node:    &api.Node{Node: "node1", Address: "10.0.1.1"},
+			},
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.1.2", Port: 80, Tags: []string{"environment=prod", "tier=batch"}},
+				Node:    &api.Node{Node: "node2", Address: "10.0.1.2"},
+			},
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.1.3", Port: 80, Tags: []string{"environment=staging", "traefik.tags=critical"}},
+				Node:    &api.Node{Node: "node3", Address: "10.0.1.3"},
+			},
+			{
+				Service: &api.AgentService{Service: "web", Address: "10.0.1.4", Port: 80, Tags: []string{"environment=staging"}},
+				Node:    &api.Node{Node: "node4", Address: "10.0.1.4"},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	constraint, err := types.NewConstraint("environment==prod && tier!=batch || tag==critical")
+	require.NoError(t, err)
+	p := &CatalogProvider{
+		Endpoint: strings.TrimPrefix(ts.URL, "http://"),
+		Prefix:   "traefik",
+		Retry:    &provider.APIRetry{Attempts: 1},
+	}
+	p.Constraints = types.Constraints{constraint}
+	client, err := p.createClient(0)
+	require.NoError(t, err)
+	p.client = client
+
+	nodes, err := p.getNodes(map[string]map[string][]string{"": {"web": {}}})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	var addresses []string
+	for _, node := range nodes[0].Nodes {
+		addresses = append(addresses, node.Service.Address)
+	}
+	assert.Equal(t, []string{"10.0.1.1", "10.0.1.3"}, addresses)
+}
@@ -0,0 +1,32 @@
+package consul
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Datacenters holds Consul datacenters
+type Datacenters []string
+
+// Set adds strings elem into the the parser
+// it splits str on , and ;
+func (d *Datacenters) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*d = append(*d, slice...)
+	return nil
+}
+
+// Get []string
+func (d *Datacenters) Get() interface{} { return Datacenters(*d) }
+
+// String return slice in a string
+func (d *Datacenters) String() string { return fmt.Sprintf("%v", *d) }
+
+// SetValue sets []string into the parser
+func (d *Datacenters) SetValue(val interface{}) {
+	*d = Datacenters(val.(Datacenters))
+}
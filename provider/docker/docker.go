@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -40,6 +41,9 @@ const (
 	labelBackendLoadbalancerSwarm = "traefik.backend.loadbalancer.swarm"
 	labelDockerComposeProject     = "com.docker.compose.project"
 	labelDockerComposeService     = "com.docker.compose.service"
+
+	// defaultLabelPrefix is the prefix of the Traefik labels constants
+	defaultLabelPrefix = "traefik"
 )
 
 var _ provider.Provider = (*Provider)(nil)
@@ -48,11 +52,16 @@ var _ provider.Provider = (*Provider)(nil)
 type Provider struct {
 	provider.BaseProvider `mapstructure:",squash"`
 	Endpoint              string              `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint"`
+	Endpoints             Endpoints           `description:"Docker server endpoints to aggregate, overriding Endpoint. Can be tcp or unix socket endpoints"`
 	Domain                string              `description:"Default domain used"`
 	TLS                   *provider.ClientTLS `description:"Enable Docker TLS support"`
 	ExposedByDefault      bool                `description:"Expose containers by default"`
 	UseBindPortIP         bool                `description:"Use the ip address from the bound port, rather than from the inner network"`
 	SwarmMode             bool                `description:"Use Docker on Swarm Mode"`
+	Prefix                string              `description:"Prefix used for Traefik labels"`
+	DetectHTTPSPort       bool                `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
+	endpointsLock         sync.Mutex
+	endpointsData         [][]dockerData
 }
 
 // dockerData holds the need data to the Provider p
@@ -62,6 +71,7 @@ type dockerData struct {
 	Labels          map[string]string // List of labels set to container or service
 	NetworkSettings networkSettings
 	Health          string
+	EndpointIndex   int // Index of the Docker endpoint the container or service was listed from
 }
 
 // NetworkSettings holds the networks data to the Provider p
@@ -80,7 +90,7 @@ type networkData struct {
 	ID       string
 }
 
-func (p *Provider) createClient() (client.APIClient, error) {
+func (p *Provider) createClient(endpoint string) (client.APIClient, error) {
 	var httpClient *http.Client
 	httpHeaders := map[string]string{
 		"User-Agent": "Traefik " + version.Version,
@@ -93,7 +103,7 @@ func (p *Provider) createClient() (client.APIClient, error) {
 		tr := &http.Transport{
 			TLSClientConfig: config,
 		}
-		proto, addr, _, err := client.ParseHost(p.Endpoint)
+		proto, addr, _, err := client.ParseHost(endpoint)
 		if err != nil {
 			return nil, err
 		}
@@ -111,7 +121,7 @@ func (p *Provider) createClient() (client.APIClient, error) {
 	} else {
 		version = DockerAPIVersion
 	}
-	return client.NewClient(p.Endpoint, version, httpClient, httpHeaders)
+	return client.NewClient(endpoint, version, httpClient, httpHeaders)
 
 }
 
@@ -119,14 +129,30 @@ func (p *Provider) createClient() (client.APIClient, error) {
 // using the given configuration channel.
 func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
 	p.Constraints = append(p.Constraints, constraints...)
+	for endpointIndex, endpoint := range p.getEndpoints() {
+		p.provideEndpoint(endpointIndex, endpoint, configurationChan, pool)
+	}
+	return nil
+}
+
+func (p *Provider) getEndpoints() []string {
+	if len(p.Endpoints) > 0 {
+		return []string(p.Endpoints)
+	}
+	return []string{p.Endpoint}
+}
+
+// provideEndpoint watches a Docker endpoint, providing the configuration built
+// from the containers of all the endpoints each time the endpoint ones change.
+func (p *Provider) provideEndpoint(endpointIndex int, endpoint string, configurationChan chan<- types.ConfigMessage, pool *safe.Pool) {
 	// TODO register this routine in pool, and watch for stop channel
 	safe.Go(func() {
 		operation := func() error {
 			var err error
 
-			dockerClient, err := p.createClient()
+			dockerClient, err := p.createClient(endpoint)
 			if err != nil {
-				log.Errorf("Failed to create a client for docker, error: %s", err)
+				log.Errorf("Failed to create a client for docker endpoint %s, error: %s", endpoint, err)
 				return err
 			}
 
@@ -148,11 +174,7 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 				}
 			}
 
-			configuration := p.loadDockerConfig(dockerDataList)
-			configurationChan <- types.ConfigMessage{
-				ProviderName:  "docker",
-				Configuration: configuration,
-			}
+			p.sendEndpointDockerConfig(configurationChan, endpointIndex, dockerDataList)
 			if p.Watch {
 				ctx, cancel := context.WithCancel(ctx)
 				if p.SwarmMode {
@@ -170,13 +192,7 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 									errChan <- err
 									return
 								}
-								configuration := p.loadDockerConfig(services)
-								if configuration != nil {
-									configurationChan <- types.ConfigMessage{
-										ProviderName:  "docker",
-										Configuration: configuration,
-									}
-								}
+								p.sendEndpointDockerConfig(configurationChan, endpointIndex, services)
 
 							case <-stop:
 								ticker.Stop()
@@ -215,13 +231,7 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 							cancel()
 							return
 						}
-						configuration := p.loadDockerConfig(containers)
-						if configuration != nil {
-							configurationChan <- types.ConfigMessage{
-								ProviderName:  "docker",
-								Configuration: configuration,
-							}
-						}
+						p.sendEndpointDockerConfig(configurationChan, endpointIndex, containers)
 					}
 
 					eventsc, errc := dockerClient.Events(ctx, options)
@@ -244,19 +254,51 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 		}
 		err := backoff.RetryNotify(safe.OperationWithRecover(operation), job.NewBackOff(backoff.NewExponentialBackOff()), notify)
 		if err != nil {
-			log.Errorf("Cannot connect to docker server %+v", err)
+			log.Errorf("Cannot connect to docker server %s %+v", endpoint, err)
 		}
 	})
+}
 
-	return nil
+// sendEndpointDockerConfig records the containers listed from an endpoint, and sends the configuration
+// built from the containers of all the endpoints.
+// Containers of the same service running on several endpoints are merged into a single backend.
+func (p *Provider) sendEndpointDockerConfig(configurationChan chan<- types.ConfigMessage, endpointIndex int, dockerDataList []dockerData) {
+	p.endpointsLock.Lock()
+	defer p.endpointsLock.Unlock()
+
+	configuration := p.loadEndpointDockerConfig(endpointIndex, dockerDataList)
+	if configuration != nil {
+		configurationChan <- types.ConfigMessage{
+			ProviderName:  "docker",
+			Configuration: configuration,
+		}
+	}
+}
+
+func (p *Provider) loadEndpointDockerConfig(endpointIndex int, dockerDataList []dockerData) *types.Configuration {
+	for len(p.endpointsData) <= endpointIndex {
+		p.endpointsData = append(p.endpointsData, nil)
+	}
+	for i := range dockerDataList {
+		dockerDataList[i].EndpointIndex = endpointIndex
+	}
+	p.endpointsData[endpointIndex] = dockerDataList
+
+	var allDockerData []dockerData
+	for _, endpointData := range p.endpointsData {
+		allDockerData = append(allDockerData, endpointData...)
+	}
+	return p.loadDockerConfig(allDockerData)
 }
 
 func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Configuration {
 	var DockerFuncMap = template.FuncMap{
 		"getBackend":                  p.getBackend,
+		"getServerName":               p.getServerName,
 		"getIPAddress":                p.getIPAddress,
 		"getPort":                     p.getPort,
 		"getWeight":                   p.getWeight,
+		"getZone":                     p.getZone,
 		"getDomain":                   p.getDomain,
 		"getProtocol":                 p.getProtocol,
 		"getPassHostHeader":           p.getPassHostHeader,
@@ -271,6 +313,7 @@ func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Con
 		"hasMaxConnLabels":            p.hasMaxConnLabels,
 		"getMaxConnAmount":            p.getMaxConnAmount,
 		"getMaxConnExtractorFunc":     p.getMaxConnExtractorFunc,
+		"getCustomHost":               p.getCustomHost,
 		"getSticky":                   p.getSticky,
 		"getIsBackendLBSwarm":         p.getIsBackendLBSwarm,
 		"hasServices":                 p.hasServices,
@@ -285,6 +328,10 @@ func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Con
 		"getServicePriority":          p.getServicePriority,
 		"getServiceBackend":           p.getServiceBackend,
 		"getWhitelistSourceRange":     p.getWhitelistSourceRange,
+		"getHostOverride":             p.getHostOverride,
+		"hasMaxInFlightReqLabels":     p.hasMaxInFlightReqLabels,
+		"getMaxInFlightReqAmount":     p.getMaxInFlightReqAmount,
+		"getMaxInFlightReqExtractor":  p.getMaxInFlightReqExtractorFunc,
 	}
 	// filter containers
 	filteredContainers := fun.Filter(func(container dockerData) bool {
@@ -323,8 +370,18 @@ func (p *Provider) loadDockerConfig(containersInspected []dockerData) *types.Con
 	return configuration
 }
 
+// getServerName returns the name of the server of a container, which is suffixed with the index of its
+// Docker endpoint so that containers with the same name on several endpoints remain distinct servers.
+func (p *Provider) getServerName(container dockerData) string {
+	serverName := strings.Replace(strings.Replace(container.Name, "/", "", -1), ".", "-", -1)
+	if container.EndpointIndex > 0 {
+		serverName += "-" + strconv.Itoa(container.EndpointIndex)
+	}
+	return serverName
+}
+
 func (p *Provider) hasCircuitBreakerLabel(container dockerData) bool {
-	if _, err := getLabel(container, types.LabelBackendCircuitbreakerExpression); err != nil {
+	if _, err := p.getLabel(container, types.LabelBackendCircuitbreakerExpression); err != nil {
 		return false
 	}
 	return true
@@ -332,7 +389,18 @@ func (p *Provider) hasCircuitBreakerLabel(container dockerData) bool {
 
 // Regexp used to extract the name of the service and the name of the property for this service
 // All properties are under the format traefik.<servicename>.frontent.*= except the port/weight/protocol directly after traefik.<servicename>.
-var servicesPropertiesRegexp = regexp.MustCompile(`^traefik\.(?P<service_name>.*?)\.(?P<property_name>port|weight|protocol|frontend\.(.*))$`)
+var servicesPropertiesRegexp = newServicesPropertiesRegexp(defaultLabelPrefix)
+
+func newServicesPropertiesRegexp(prefix string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `\.(?P<service_name>.*?)\.(?P<property_name>port|weight|protocol|frontend\.(.*))$`)
+}
+
+func (p *Provider) getServicesPropertiesRegexp() *regexp.Regexp {
+	if len(p.Prefix) == 0 || p.Prefix == defaultLabelPrefix {
+		return servicesPropertiesRegexp
+	}
+	return newServicesPropertiesRegexp(p.Prefix)
+}
 
 // Map of services properties
 // we can get it with label[serviceName][propertyName] and we got the propertyValue
@@ -340,18 +408,18 @@ type labelServiceProperties map[string]map[string]string
 
 // Check if for the given container, we find labels that are defining services
 func (p *Provider) hasServices(container dockerData) bool {
-	return len(extractServicesLabels(container.Labels)) > 0
+	return len(extractServicesLabels(container.Labels, p.getServicesPropertiesRegexp())) > 0
 }
 
 // Extract the service labels from container labels of dockerData struct
-func extractServicesLabels(labels map[string]string) labelServiceProperties {
+func extractServicesLabels(labels map[string]string, propertiesRegexp *regexp.Regexp) labelServiceProperties {
 	v := make(labelServiceProperties)
 
 	for index, serviceProperty := range labels {
-		matches := servicesPropertiesRegexp.FindStringSubmatch(index)
+		matches := propertiesRegexp.FindStringSubmatch(index)
 		if matches != nil {
 			result := make(map[string]string)
-			for i, name := range servicesPropertiesRegexp.SubexpNames() {
+			for i, name := range propertiesRegexp.SubexpNames() {
 				if i != 0 {
 					result[name] = matches[i]
 				}
@@ -368,14 +436,14 @@ func extractServicesLabels(labels map[string]string) labelServiceProperties {
 }
 
 // Gets the entry for a service label searching in all labels of the given container
-func getContainerServiceLabel(container dockerData, serviceName string, entry string) (string, bool) {
-	value, ok := extractServicesLabels(container.Labels)[serviceName][entry]
+func (p *Provider) getContainerServiceLabel(container dockerData, serviceName string, entry string) (string, bool) {
+	value, ok := extractServicesLabels(container.Labels, p.getServicesPropertiesRegexp())[serviceName][entry]
 	return value, ok
 }
 
 // Gets array of service names for a given container
 func (p *Provider) getServiceNames(container dockerData) []string {
-	labelServiceProperties := extractServicesLabels(container.Labels)
+	labelServiceProperties := extractServicesLabels(container.Labels, p.getServicesPropertiesRegexp())
 	keys := make([]string, 0, len(labelServiceProperties))
 	for k := range labelServiceProperties {
 		keys = append(keys, k)
@@ -385,7 +453,7 @@ func (p *Provider) getServiceNames(container dockerData) []string {
 
 // Extract entrypoints from labels for a given service and a given docker container
 func (p *Provider) getServiceEntryPoints(container dockerData, serviceName string) []string {
-	if entryPoints, ok := getContainerServiceLabel(container, serviceName, "frontend.entryPoints"); ok {
+	if entryPoints, ok := p.getContainerServiceLabel(container, serviceName, "frontend.entryPoints"); ok {
 		return strings.Split(entryPoints, ",")
 	}
 	return p.getEntryPoints(container)
@@ -394,7 +462,7 @@ func (p *Provider) getServiceEntryPoints(container dockerData, serviceName strin
 
 // Extract basic auth from labels for a given service and a given docker container
 func (p *Provider) getServiceBasicAuth(container dockerData, serviceName string) []string {
-	if basicAuth, ok := getContainerServiceLabel(container, serviceName, "frontend.auth.basic"); ok {
+	if basicAuth, ok := p.getContainerServiceLabel(container, serviceName, "frontend.auth.basic"); ok {
 		return strings.Split(basicAuth, ",")
 	}
 	return p.getBasicAuth(container)
@@ -403,7 +471,7 @@ func (p *Provider) getServiceBasicAuth(container dockerData, serviceName string)
 
 // Extract passHostHeader from labels for a given service and a given docker container
 func (p *Provider) getServicePassHostHeader(container dockerData, serviceName string) string {
-	if servicePassHostHeader, ok := getContainerServiceLabel(container, serviceName, "frontend.passHostHeader"); ok {
+	if servicePassHostHeader, ok := p.getContainerServiceLabel(container, serviceName, "frontend.passHostHeader"); ok {
 		return servicePassHostHeader
 	}
 	return p.getPassHostHeader(container)
@@ -411,7 +479,7 @@ func (p *Provider) getServicePassHostHeader(container dockerData, serviceName st
 
 // Extract priority from labels for a given service and a given docker container
 func (p *Provider) getServicePriority(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "frontend.priority"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "frontend.priority"); ok {
 		return value
 	}
 	return p.getPriority(container)
@@ -420,7 +488,7 @@ func (p *Provider) getServicePriority(container dockerData, serviceName string)
 
 // Extract backend from labels for a given service and a given docker container
 func (p *Provider) getServiceBackend(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "frontend.backend"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "frontend.backend"); ok {
 		return value
 	}
 	return p.getBackend(container) + "-" + provider.Normalize(serviceName)
@@ -428,7 +496,7 @@ func (p *Provider) getServiceBackend(container dockerData, serviceName string) s
 
 // Extract rule from labels for a given service and a given docker container
 func (p *Provider) getServiceFrontendRule(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "frontend.rule"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "frontend.rule"); ok {
 		return value
 	}
 	return p.getFrontendRule(container)
@@ -437,7 +505,7 @@ func (p *Provider) getServiceFrontendRule(container dockerData, serviceName stri
 
 // Extract port from labels for a given service and a given docker container
 func (p *Provider) getServicePort(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "port"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "port"); ok {
 		return value
 	}
 	return p.getPort(container)
@@ -445,7 +513,7 @@ func (p *Provider) getServicePort(container dockerData, serviceName string) stri
 
 // Extract weight from labels for a given service and a given docker container
 func (p *Provider) getServiceWeight(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "weight"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "weight"); ok {
 		return value
 	}
 	return p.getWeight(container)
@@ -453,15 +521,18 @@ func (p *Provider) getServiceWeight(container dockerData, serviceName string) st
 
 // Extract protocol from labels for a given service and a given docker container
 func (p *Provider) getServiceProtocol(container dockerData, serviceName string) string {
-	if value, ok := getContainerServiceLabel(container, serviceName, "protocol"); ok {
+	if value, ok := p.getContainerServiceLabel(container, serviceName, "protocol"); ok {
 		return value
 	}
-	return p.getProtocol(container)
+	if label, err := p.getLabel(container, types.LabelProtocol); err == nil {
+		return label
+	}
+	return provider.GetDefaultProtocol(p.getServicePort(container, serviceName), p.DetectHTTPSPort)
 }
 
 func (p *Provider) hasLoadBalancerLabel(container dockerData) bool {
-	_, errMethod := getLabel(container, types.LabelBackendLoadbalancerMethod)
-	_, errSticky := getLabel(container, types.LabelBackendLoadbalancerSticky)
+	_, errMethod := p.getLabel(container, types.LabelBackendLoadbalancerMethod)
+	_, errSticky := p.getLabel(container, types.LabelBackendLoadbalancerSticky)
 	if errMethod != nil && errSticky != nil {
 		return false
 	}
@@ -469,31 +540,55 @@ func (p *Provider) hasLoadBalancerLabel(container dockerData) bool {
 }
 
 func (p *Provider) hasMaxConnLabels(container dockerData) bool {
-	if _, err := getLabel(container, types.LabelBackendMaxconnAmount); err != nil {
+	if _, err := p.getLabel(container, types.LabelBackendMaxconnAmount); err != nil {
 		return false
 	}
-	if _, err := getLabel(container, types.LabelBackendMaxconnExtractorfunc); err != nil {
+	if _, err := p.getLabel(container, types.LabelBackendMaxconnExtractorfunc); err != nil {
 		return false
 	}
 	return true
 }
 
+func (p *Provider) hasMaxInFlightReqLabels(container dockerData) bool {
+	_, err := p.getLabel(container, types.LabelFrontendMaxInFlightReq)
+	return err == nil
+}
+
+func (p *Provider) getMaxInFlightReqAmount(container dockerData) int64 {
+	if label, err := p.getLabel(container, types.LabelFrontendMaxInFlightReq); err == nil {
+		i, errConv := strconv.ParseInt(label, 10, 64)
+		if errConv != nil {
+			log.Errorf("Unable to parse %s %s", types.LabelFrontendMaxInFlightReq, label)
+			return math.MaxInt64
+		}
+		return i
+	}
+	return math.MaxInt64
+}
+
+func (p *Provider) getMaxInFlightReqExtractorFunc(container dockerData) string {
+	if label, err := p.getLabel(container, types.LabelFrontendMaxInFlightReqExtractorFunc); err == nil {
+		return label
+	}
+	return "global"
+}
+
 func (p *Provider) getCircuitBreakerExpression(container dockerData) string {
-	if label, err := getLabel(container, types.LabelBackendCircuitbreakerExpression); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackendCircuitbreakerExpression); err == nil {
 		return label
 	}
 	return "NetworkErrorRatio() > 1"
 }
 
 func (p *Provider) getLoadBalancerMethod(container dockerData) string {
-	if label, err := getLabel(container, types.LabelBackendLoadbalancerMethod); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackendLoadbalancerMethod); err == nil {
 		return label
 	}
 	return "wrr"
 }
 
 func (p *Provider) getMaxConnAmount(container dockerData) int64 {
-	if label, err := getLabel(container, types.LabelBackendMaxconnAmount); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackendMaxconnAmount); err == nil {
 		i, errConv := strconv.ParseInt(label, 10, 64)
 		if errConv != nil {
 			log.Errorf("Unable to parse traefik.backend.maxconn.amount %s", label)
@@ -505,26 +600,40 @@ func (p *Provider) getMaxConnAmount(container dockerData) int64 {
 }
 
 func (p *Provider) getMaxConnExtractorFunc(container dockerData) string {
-	if label, err := getLabel(container, types.LabelBackendMaxconnExtractorfunc); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackendMaxconnExtractorfunc); err == nil {
 		return label
 	}
 	return "request.host"
 }
 
+func (p *Provider) getCustomHost(container dockerData) string {
+	if label, err := p.getLabel(container, types.LabelBackendCustomHost); err == nil {
+		return label
+	}
+	return ""
+}
+
+func (p *Provider) getHostOverride(container dockerData) string {
+	if label, err := p.getLabel(container, types.LabelFrontendHeadersHostOverride); err == nil {
+		return label
+	}
+	return ""
+}
+
 func (p *Provider) containerFilter(container dockerData) bool {
-	_, err := strconv.Atoi(container.Labels[types.LabelPort])
+	_, err := strconv.Atoi(container.Labels[p.getPrefixedLabel(types.LabelPort)])
 	if len(container.NetworkSettings.Ports) == 0 && err != nil {
 		log.Debugf("Filtering container without port and no traefik.port label %s", container.Name)
 		return false
 	}
 
-	if !isContainerEnabled(container, p.ExposedByDefault) {
+	if !p.isContainerEnabled(container) {
 		log.Debugf("Filtering disabled container %s", container.Name)
 		return false
 	}
 
-	constraintTags := strings.Split(container.Labels[types.LabelTags], ",")
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
+	constraintTags := strings.Split(container.Labels[p.getPrefixedLabel(types.LabelTags)], ",")
+	if ok, failingConstraint := p.MatchConstraintsWithMetadata(constraintTags, container.Labels); !ok {
 		if failingConstraint != nil {
 			log.Debugf("Container %v pruned by '%v' constraint", container.Name, failingConstraint.String())
 		}
@@ -552,7 +661,7 @@ func (p *Provider) getFrontendName(container dockerData) string {
 // GetFrontendRule returns the frontend rule for the specified container, using
 // it's label. It returns a default one (Host) if the label is not present.
 func (p *Provider) getFrontendRule(container dockerData) string {
-	if label, err := getLabel(container, types.LabelFrontendRule); err == nil {
+	if label, err := p.getLabel(container, types.LabelFrontendRule); err == nil {
 		return label
 	}
 	if labels, err := getLabels(container, []string{labelDockerComposeProject, labelDockerComposeService}); err == nil {
@@ -565,7 +674,7 @@ func (p *Provider) getFrontendRule(container dockerData) string {
 }
 
 func (p *Provider) getBackend(container dockerData) string {
-	if label, err := getLabel(container, types.LabelBackend); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackend); err == nil {
 		return provider.Normalize(label)
 	}
 	if labels, err := getLabels(container, []string{labelDockerComposeProject, labelDockerComposeService}); err == nil {
@@ -575,7 +684,7 @@ func (p *Provider) getBackend(container dockerData) string {
 }
 
 func (p *Provider) getIPAddress(container dockerData) string {
-	if label, err := getLabel(container, labelDockerNetwork); err == nil && label != "" {
+	if label, err := p.getLabel(container, labelDockerNetwork); err == nil && label != "" {
 		networkSettings := container.NetworkSettings
 		if networkSettings.Networks != nil {
 			network := networkSettings.Networks[label]
@@ -611,7 +720,7 @@ func (p *Provider) getIPAddress(container dockerData) string {
 }
 
 func (p *Provider) getPort(container dockerData) string {
-	if label, err := getLabel(container, types.LabelPort); err == nil {
+	if label, err := p.getLabel(container, types.LabelPort); err == nil {
 		return label
 	}
 
@@ -635,42 +744,49 @@ func (p *Provider) getPort(container dockerData) string {
 }
 
 func (p *Provider) getWeight(container dockerData) string {
-	if label, err := getLabel(container, types.LabelWeight); err == nil {
+	if label, err := p.getLabel(container, types.LabelWeight); err == nil {
 		return label
 	}
 	return "0"
 }
 
+func (p *Provider) getZone(container dockerData) string {
+	if label, err := p.getLabel(container, types.LabelZone); err == nil {
+		return label
+	}
+	return ""
+}
+
 func (p *Provider) getSticky(container dockerData) string {
-	if label, err := getLabel(container, types.LabelBackendLoadbalancerSticky); err == nil {
+	if label, err := p.getLabel(container, types.LabelBackendLoadbalancerSticky); err == nil {
 		return label
 	}
 	return "false"
 }
 
 func (p *Provider) getIsBackendLBSwarm(container dockerData) string {
-	if label, err := getLabel(container, labelBackendLoadbalancerSwarm); err == nil {
+	if label, err := p.getLabel(container, labelBackendLoadbalancerSwarm); err == nil {
 		return label
 	}
 	return "false"
 }
 
 func (p *Provider) getDomain(container dockerData) string {
-	if label, err := getLabel(container, types.LabelDomain); err == nil {
+	if label, err := p.getLabel(container, types.LabelDomain); err == nil {
 		return label
 	}
 	return p.Domain
 }
 
 func (p *Provider) getProtocol(container dockerData) string {
-	if label, err := getLabel(container, types.LabelProtocol); err == nil {
+	if label, err := p.getLabel(container, types.LabelProtocol); err == nil {
 		return label
 	}
-	return "http"
+	return provider.GetDefaultProtocol(p.getPort(container), p.DetectHTTPSPort)
 }
 
 func (p *Provider) getPassHostHeader(container dockerData) string {
-	if passHostHeader, err := getLabel(container, types.LabelFrontendPassHostHeader); err == nil {
+	if passHostHeader, err := p.getLabel(container, types.LabelFrontendPassHostHeader); err == nil {
 		return passHostHeader
 	}
 	return "true"
@@ -679,36 +795,50 @@ func (p *Provider) getPassHostHeader(container dockerData) string {
 func (p *Provider) getWhitelistSourceRange(container dockerData) []string {
 	var whitelistSourceRange []string
 
-	if whitelistSourceRangeLabel, err := getLabel(container, types.LabelTraefikFrontendWhitelistSourceRange); err == nil {
+	if whitelistSourceRangeLabel, err := p.getLabel(container, types.LabelTraefikFrontendWhitelistSourceRange); err == nil {
 		whitelistSourceRange = provider.SplitAndTrimString(whitelistSourceRangeLabel)
 	}
 	return whitelistSourceRange
 }
 
 func (p *Provider) getPriority(container dockerData) string {
-	if priority, err := getLabel(container, types.LabelFrontendPriority); err == nil {
+	if priority, err := p.getLabel(container, types.LabelFrontendPriority); err == nil {
 		return priority
 	}
 	return "0"
 }
 
 func (p *Provider) getEntryPoints(container dockerData) []string {
-	if entryPoints, err := getLabel(container, types.LabelFrontendEntryPoints); err == nil {
+	if entryPoints, err := p.getLabel(container, types.LabelFrontendEntryPoints); err == nil {
 		return strings.Split(entryPoints, ",")
 	}
 	return []string{}
 }
 
 func (p *Provider) getBasicAuth(container dockerData) []string {
-	if basicAuth, err := getLabel(container, types.LabelFrontendAuthBasic); err == nil {
+	if basicAuth, err := p.getLabel(container, types.LabelFrontendAuthBasic); err == nil {
 		return strings.Split(basicAuth, ",")
 	}
 
 	return []string{}
 }
 
-func isContainerEnabled(container dockerData, exposedByDefault bool) bool {
-	return exposedByDefault && container.Labels[types.LabelEnable] != "false" || container.Labels[types.LabelEnable] == "true"
+func (p *Provider) isContainerEnabled(container dockerData) bool {
+	enable := container.Labels[p.getPrefixedLabel(types.LabelEnable)]
+	return p.ExposedByDefault && enable != "false" || enable == "true"
+}
+
+// getPrefixedLabel replaces the default prefix of a Traefik label by the configured one
+func (p *Provider) getPrefixedLabel(label string) string {
+	if len(p.Prefix) == 0 || p.Prefix == defaultLabelPrefix {
+		return label
+	}
+	return p.Prefix + strings.TrimPrefix(label, defaultLabelPrefix)
+}
+
+// getLabel gets a Traefik label of the container, using the configured prefix
+func (p *Provider) getLabel(container dockerData, label string) (string, error) {
+	return getLabel(container, p.getPrefixedLabel(label))
 }
 
 func getLabel(container dockerData, label string) (string, error) {
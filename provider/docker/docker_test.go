@@ -1,7 +1,9 @@
 package docker
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -9,7 +11,9 @@ import (
 	"github.com/containous/traefik/types"
 	docker "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+	"golang.org/x/net/context"
 )
 
 func TestDockerGetFrontendName(t *testing.T) {
@@ -305,6 +309,37 @@ func TestDockerGetWeight(t *testing.T) {
 	}
 }
 
+func TestDockerGetZone(t *testing.T) {
+	containers := []struct {
+		container docker.ContainerJSON
+		expected  string
+	}{
+		{
+			container: containerJSON(),
+			expected:  "",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				types.LabelZone: "eu-west-1a",
+			})),
+			expected: "eu-west-1a",
+		},
+	}
+
+	for containerID, e := range containers {
+		e := e
+		t.Run(strconv.Itoa(containerID), func(t *testing.T) {
+			t.Parallel()
+			dockerData := parseContainer(e.container)
+			provider := &Provider{}
+			actual := provider.getZone(dockerData)
+			if actual != e.expected {
+				t.Errorf("expected %q, got %q", e.expected, actual)
+			}
+		})
+	}
+}
+
 func TestDockerGetDomain(t *testing.T) {
 	containers := []struct {
 		container docker.ContainerJSON
@@ -340,8 +375,9 @@ func TestDockerGetDomain(t *testing.T) {
 
 func TestDockerGetProtocol(t *testing.T) {
 	containers := []struct {
-		container docker.ContainerJSON
-		expected  string
+		container       docker.ContainerJSON
+		detectHTTPSPort bool
+		expected        string
 	}{
 		{
 			container: containerJSON(),
@@ -353,6 +389,42 @@ func TestDockerGetProtocol(t *testing.T) {
 			})),
 			expected: "https",
 		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"443/tcp": {},
+			})),
+			expected: "http",
+		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"443/tcp": {},
+			})),
+			detectHTTPSPort: true,
+			expected:        "https",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				types.LabelPort: "8443",
+			})),
+			detectHTTPSPort: true,
+			expected:        "https",
+		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"8080/tcp": {},
+			})),
+			detectHTTPSPort: true,
+			expected:        "http",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				types.LabelProtocol: "http",
+			}), ports(nat.PortMap{
+				"443/tcp": {},
+			})),
+			detectHTTPSPort: true,
+			expected:        "http",
+		},
 	}
 
 	for containerID, e := range containers {
@@ -361,6 +433,7 @@ func TestDockerGetProtocol(t *testing.T) {
 			t.Parallel()
 			dockerData := parseContainer(e.container)
 			provider := &Provider{}
+			provider.DetectHTTPSPort = e.detectHTTPSPort
 			actual := provider.getProtocol(dockerData)
 			if actual != e.expected {
 				t.Errorf("expected %q, got %q", e.expected, actual)
@@ -400,6 +473,68 @@ func TestDockerGetPassHostHeader(t *testing.T) {
 	}
 }
 
+func TestDockerGetCustomHost(t *testing.T) {
+	containers := []struct {
+		container docker.ContainerJSON
+		expected  string
+	}{
+		{
+			container: containerJSON(),
+			expected:  "",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				types.LabelBackendCustomHost: "internal.svc",
+			})),
+			expected: "internal.svc",
+		},
+	}
+
+	for containerID, e := range containers {
+		e := e
+		t.Run(strconv.Itoa(containerID), func(t *testing.T) {
+			t.Parallel()
+			dockerData := parseContainer(e.container)
+			provider := &Provider{}
+			actual := provider.getCustomHost(dockerData)
+			if actual != e.expected {
+				t.Errorf("expected %q, got %q", e.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDockerGetHostOverride(t *testing.T) {
+	containers := []struct {
+		container docker.ContainerJSON
+		expected  string
+	}{
+		{
+			container: containerJSON(),
+			expected:  "",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				types.LabelFrontendHeadersHostOverride: "canonical.svc",
+			})),
+			expected: "canonical.svc",
+		},
+	}
+
+	for containerID, e := range containers {
+		e := e
+		t.Run(strconv.Itoa(containerID), func(t *testing.T) {
+			t.Parallel()
+			dockerData := parseContainer(e.container)
+			provider := &Provider{}
+			actual := provider.getHostOverride(dockerData)
+			if actual != e.expected {
+				t.Errorf("expected %q, got %q", e.expected, actual)
+			}
+		})
+	}
+}
+
 func TestDockerGetWhitelistSourceRange(t *testing.T) {
 	containers := []struct {
 		desc      string
@@ -549,6 +684,64 @@ func TestDockerGetLabels(t *testing.T) {
 	}
 }
 
+func TestDockerContainerFilterConstraintExpression(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		container docker.ContainerJSON
+		expected  bool
+	}{
+		{
+			desc: "matching labels",
+			container: containerJSON(name("api"), ports(nat.PortMap{"80/tcp": {}}), labels(map[string]string{
+				"environment": "prod",
+				"tier":        "frontend",
+			})),
+			expected: true,
+		},
+		{
+			desc: "excluded tier",
+			container: containerJSON(name("batch"), ports(nat.PortMap{"80/tcp": {}}), labels(map[string]string{
+				"environment": "prod",
+				"tier":        "batch",
+			})),
+			expected: false,
+		},
+		{
+			desc: "other environment",
+			container: containerJSON(name("staging"), ports(nat.PortMap{"80/tcp": {}}), labels(map[string]string{
+				"environment": "staging",
+			})),
+			expected: false,
+		},
+		{
+			desc: "other environment with a matching tag",
+			container: containerJSON(name("critical"), ports(nat.PortMap{"80/tcp": {}}), labels(map[string]string{
+				"environment":   "staging",
+				types.LabelTags: "critical",
+			})),
+			expected: true,
+		},
+	}
+
+	constraint, err := types.NewConstraint("(environment==prod && !tier==batch) || tag==critical")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			provider := &Provider{Domain: "test", ExposedByDefault: true}
+			provider.Constraints = types.Constraints{constraint}
+			actual := provider.containerFilter(parseContainer(test.container))
+			if actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
 func TestDockerTraefikFilter(t *testing.T) {
 	containers := []struct {
 		container docker.ContainerJSON
@@ -977,12 +1170,14 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 				containerJSON(
 					name("test1"),
 					labels(map[string]string{
-						types.LabelBackend:                         "foobar",
-						types.LabelFrontendEntryPoints:             "http,https",
-						types.LabelBackendMaxconnAmount:            "1000",
-						types.LabelBackendMaxconnExtractorfunc:     "somethingelse",
-						types.LabelBackendLoadbalancerMethod:       "drr",
-						types.LabelBackendCircuitbreakerExpression: "NetworkErrorRatio() > 0.5",
+						types.LabelBackend:                             "foobar",
+						types.LabelFrontendEntryPoints:                 "http,https",
+						types.LabelBackendMaxconnAmount:                "1000",
+						types.LabelBackendMaxconnExtractorfunc:         "somethingelse",
+						types.LabelBackendLoadbalancerMethod:           "drr",
+						types.LabelBackendCircuitbreakerExpression:     "NetworkErrorRatio() > 0.5",
+						types.LabelFrontendMaxInFlightReq:              "10",
+						types.LabelFrontendMaxInFlightReqExtractorFunc: "client.ip",
 					}),
 					ports(nat.PortMap{
 						"80/tcp": {},
@@ -996,6 +1191,10 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 					PassHostHeader: true,
 					EntryPoints:    []string{"http", "https"},
 					BasicAuth:      []string{},
+					MaxInFlightReq: &types.MaxInFlightReq{
+						Amount:        10,
+						ExtractorFunc: "client.ip",
+					},
 					Routes: map[string]types.Route{
 						"route-frontend-Host-test1-docker-localhost": {
 							Rule: "Host:test1.docker.localhost",
@@ -1024,6 +1223,44 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("test"),
+					labels(map[string]string{
+						types.LabelFrontendHeadersHostOverride: "canonical.svc",
+					}),
+					ports(nat.PortMap{
+						"80/tcp": {},
+					}),
+					withNetwork("bridge", ipv4("127.0.0.1")),
+				),
+			},
+			expectedFrontends: map[string]*types.Frontend{
+				"frontend-Host-test-docker-localhost": {
+					Backend:        "backend-test",
+					PassHostHeader: true,
+					EntryPoints:    []string{},
+					BasicAuth:      []string{},
+					Headers:        types.Headers{HostOverride: "canonical.svc"},
+					Routes: map[string]types.Route{
+						"route-frontend-Host-test-docker-localhost": {
+							Rule: "Host:test.docker.localhost",
+						},
+					},
+				},
+			},
+			expectedBackends: map[string]*types.Backend{
+				"backend-test": {
+					Servers: map[string]types.Server{
+						"server-test": {
+							URL:    "http://127.0.0.1:80",
+							Weight: 0,
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for caseID, c := range cases {
@@ -1051,3 +1288,195 @@ func TestDockerLoadDockerConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDockerLoadDockerConfigWithPrefix(t *testing.T) {
+	containers := []docker.ContainerJSON{
+		containerJSON(
+			name("test1"),
+			labels(map[string]string{
+				"custom.backend":        "foobar",
+				"custom.port":           "8080",
+				"custom.frontend.rule":  "Host:custom.localhost",
+				types.LabelBackend:      "ignored",
+				types.LabelPort:         "9090",
+				types.LabelFrontendRule: "Host:ignored.localhost",
+			}),
+			ports(nat.PortMap{
+				"80/tcp": {},
+			}),
+			withNetwork("bridge", ipv4("127.0.0.1")),
+		),
+		containerJSON(
+			name("test2"),
+			labels(map[string]string{
+				"custom.enable":   "false",
+				types.LabelEnable: "true",
+			}),
+			ports(nat.PortMap{
+				"80/tcp": {},
+			}),
+			withNetwork("bridge", ipv4("127.0.0.2")),
+		),
+	}
+	expectedFrontends := map[string]*types.Frontend{
+		"frontend-Host-custom-localhost": {
+			Backend:        "backend-foobar",
+			PassHostHeader: true,
+			EntryPoints:    []string{},
+			BasicAuth:      []string{},
+			Routes: map[string]types.Route{
+				"route-frontend-Host-custom-localhost": {
+					Rule: "Host:custom.localhost",
+				},
+			},
+		},
+	}
+	expectedBackends := map[string]*types.Backend{
+		"backend-foobar": {
+			Servers: map[string]types.Server{
+				"server-test1": {
+					URL:    "http://127.0.0.1:8080",
+					Weight: 0,
+				},
+			},
+			CircuitBreaker: nil,
+		},
+	}
+
+	var dockerDataList []dockerData
+	for _, container := range containers {
+		dockerDataList = append(dockerDataList, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		Prefix:           "custom",
+	}
+	actualConfig := provider.loadDockerConfig(dockerDataList)
+	if !reflect.DeepEqual(actualConfig.Backends, expectedBackends) {
+		t.Errorf("expected %#v, got %#v", expectedBackends, actualConfig.Backends)
+	}
+	if !reflect.DeepEqual(actualConfig.Frontends, expectedFrontends) {
+		t.Errorf("expected %#v, got %#v", expectedFrontends, actualConfig.Frontends)
+	}
+}
+
+func TestDockerGetServiceNamesWithPrefix(t *testing.T) {
+	container := parseContainer(containerJSON(labels(map[string]string{
+		"custom.myservice.port":     "2503",
+		"traefik.otherservice.port": "2504",
+	})))
+
+	provider := &Provider{Prefix: "custom"}
+	if names := provider.getServiceNames(container); !reflect.DeepEqual(names, []string{"myservice"}) {
+		t.Errorf("expected [myservice], got %v", names)
+	}
+	if port := provider.getServicePort(container, "myservice"); port != "2503" {
+		t.Errorf("expected 2503, got %s", port)
+	}
+}
+
+type fakeContainersClient struct {
+	dockerclient.ContainerAPIClient
+	containers []docker.ContainerJSON
+}
+
+func (c *fakeContainersClient) ContainerList(ctx context.Context, options docker.ContainerListOptions) ([]docker.Container, error) {
+	var containerList []docker.Container
+	for _, container := range c.containers {
+		containerList = append(containerList, docker.Container{ID: container.Name})
+	}
+	return containerList, nil
+}
+
+func (c *fakeContainersClient) ContainerInspect(ctx context.Context, containerID string) (docker.ContainerJSON, error) {
+	for _, container := range c.containers {
+		if container.Name == containerID {
+			return container, nil
+		}
+	}
+	return docker.ContainerJSON{}, fmt.Errorf("no such container %s", containerID)
+}
+
+func TestDockerLoadEndpointsDockerConfig(t *testing.T) {
+	endpointsClients := []*fakeContainersClient{
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("web"),
+					labels(map[string]string{types.LabelBackend: "web"}),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("bridge", ipv4("10.0.0.1")),
+				),
+			},
+		},
+		{
+			containers: []docker.ContainerJSON{
+				containerJSON(
+					name("web"),
+					labels(map[string]string{types.LabelBackend: "web"}),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("bridge", ipv4("10.0.0.2")),
+				),
+				containerJSON(
+					name("api"),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("bridge", ipv4("10.0.0.3")),
+				),
+			},
+		},
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	var actualConfig *types.Configuration
+	for endpointIndex, endpointClient := range endpointsClients {
+		dockerDataList, err := listContainers(context.Background(), endpointClient)
+		if err != nil {
+			t.Fatalf("unexpected error listing containers of endpoint %d: %s", endpointIndex, err)
+		}
+		actualConfig = provider.loadEndpointDockerConfig(endpointIndex, dockerDataList)
+	}
+
+	expectedBackends := map[string]*types.Backend{
+		"backend-web": {
+			Servers: map[string]types.Server{
+				"server-web": {
+					URL:    "http://10.0.0.1:80",
+					Weight: 0,
+				},
+				"server-web-1": {
+					URL:    "http://10.0.0.2:80",
+					Weight: 0,
+				},
+			},
+			CircuitBreaker: nil,
+		},
+		"backend-api": {
+			Servers: map[string]types.Server{
+				"server-api-1": {
+					URL:    "http://10.0.0.3:80",
+					Weight: 0,
+				},
+			},
+			CircuitBreaker: nil,
+		},
+	}
+	if !reflect.DeepEqual(actualConfig.Backends, expectedBackends) {
+		t.Errorf("expected %#v, got %#v", expectedBackends, actualConfig.Backends)
+	}
+
+	expectedFrontends := []string{"frontend-Host-api-docker-localhost", "frontend-Host-web-docker-localhost"}
+	var actualFrontends []string
+	for frontendName := range actualConfig.Frontends {
+		actualFrontends = append(actualFrontends, frontendName)
+	}
+	sort.Strings(actualFrontends)
+	if !reflect.DeepEqual(actualFrontends, expectedFrontends) {
+		t.Errorf("expected %v, got %v", expectedFrontends, actualFrontends)
+	}
+}
@@ -0,0 +1,32 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Endpoints holds Docker server endpoints
+type Endpoints []string
+
+// Set adds strings elem into the the parser
+// it splits str on , and ;
+func (e *Endpoints) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*e = append(*e, slice...)
+	return nil
+}
+
+// Get []string
+func (e *Endpoints) Get() interface{} { return Endpoints(*e) }
+
+// String return slice in a string
+func (e *Endpoints) String() string { return fmt.Sprintf("%v", *e) }
+
+// SetValue sets []string into the parser
+func (e *Endpoints) SetValue(val interface{}) {
+	*e = Endpoints(val.(Endpoints))
+}
@@ -34,6 +34,7 @@ type Provider struct {
 	Domain           string `description:"Default domain used"`
 	ExposedByDefault bool   `description:"Expose containers by default"`
 	RefreshSeconds   int    `description:"Polling interval (in seconds)"`
+	DetectHTTPSPort  bool   `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
 
 	// Provider lookup parameters
 	Cluster         string `description:"ECS Cluster Name"`
@@ -50,6 +51,7 @@ type ecsInstance struct {
 	container           *ecs.Container
 	containerDefinition *ecs.ContainerDefinition
 	machine             *ec2.Instance
+	detectHTTPSPort     bool
 }
 
 type awsClient struct {
@@ -289,6 +291,7 @@ func (p *Provider) listInstances(ctx context.Context, client *awsClient) ([]ecsI
 				container,
 				containerDefinition,
 				machines[machineIdx],
+				p.DetectHTTPSPort,
 			})
 		}
 	}
@@ -440,7 +443,7 @@ func (i ecsInstance) Protocol() string {
 	if label := i.label(types.LabelProtocol); label != "" {
 		return label
 	}
-	return "http"
+	return provider.GetDefaultProtocol(i.Port(), i.detectHTTPSPort)
 }
 
 func (i ecsInstance) Host() string {
@@ -458,6 +461,16 @@ func (i ecsInstance) Weight() string {
 	return "0"
 }
 
+func (i ecsInstance) Zone() string {
+	if label := i.label(types.LabelZone); label != "" {
+		return label
+	}
+	if i.machine.Placement != nil && i.machine.Placement.AvailabilityZone != nil {
+		return *i.machine.Placement.AvailabilityZone
+	}
+	return ""
+}
+
 func (i ecsInstance) PassHostHeader() string {
 	if label := i.label(types.LabelFrontendPassHostHeader); label != "" {
 		return label
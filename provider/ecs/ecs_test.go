@@ -145,6 +145,40 @@ func TestEcsWeight(t *testing.T) {
 	}
 }
 
+func TestEcsZone(t *testing.T) {
+	placed := simpleEcsInstance(map[string]*string{})
+	placed.machine.Placement = &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")}
+	labelled := simpleEcsInstance(map[string]*string{
+		types.LabelZone: aws.String("rack1"),
+	})
+	labelled.machine.Placement = &ec2.Placement{AvailabilityZone: aws.String("us-east-1a")}
+
+	cases := []struct {
+		expected     string
+		instanceInfo ecsInstance
+	}{
+		{
+			expected:     "",
+			instanceInfo: simpleEcsInstance(map[string]*string{}),
+		},
+		{
+			expected:     "us-east-1a",
+			instanceInfo: placed,
+		},
+		{
+			expected:     "rack1",
+			instanceInfo: labelled,
+		},
+	}
+
+	for i, c := range cases {
+		value := c.instanceInfo.Zone()
+		if value != c.expected {
+			t.Fatalf("Should have been %v, got %v (case %d)", c.expected, value, i)
+		}
+	}
+}
+
 func TestEcsPassHostHeader(t *testing.T) {
 	cases := []struct {
 		expected     string
@@ -1,10 +1,12 @@
 package file
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/containous/traefik/log"
@@ -15,11 +17,14 @@ import (
 )
 
 var _ provider.Provider = (*Provider)(nil)
+var _ provider.Refresher = (*Provider)(nil)
 
 // Provider holds configurations of the provider.
 type Provider struct {
 	provider.BaseProvider `mapstructure:",squash"`
 	Directory             string `description:"Load configuration from one or more .toml files in a directory"`
+	configurationChan     chan<- types.ConfigMessage
+	lock                  sync.Mutex
 }
 
 // Provide allows the file provider to provide configurations to traefik
@@ -31,6 +36,10 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 		return err
 	}
 
+	p.lock.Lock()
+	p.configurationChan = configurationChan
+	p.lock.Unlock()
+
 	if p.Watch {
 		var watchItem string
 
@@ -49,6 +58,23 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
+// Refresh reads the configuration files again.
+func (p *Provider) Refresh() error {
+	p.lock.Lock()
+	configurationChan := p.configurationChan
+	p.lock.Unlock()
+	if configurationChan == nil {
+		return errors.New("provider not started")
+	}
+
+	configuration, err := p.loadConfig()
+	if err != nil {
+		return err
+	}
+	sendConfigToChannel(configurationChan, configuration)
+	return nil
+}
+
 func (p *Provider) addWatcher(pool *safe.Pool, directory string, configurationChan chan<- types.ConfigMessage, callback func(chan<- types.ConfigMessage, fsnotify.Event)) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -102,6 +128,7 @@ func loadFileConfigFromDirectory(directory string) (*types.Configuration, error)
 	configuration := &types.Configuration{
 		Frontends: make(map[string]*types.Frontend),
 		Backends:  make(map[string]*types.Backend),
+		Chains:    make(map[string]*types.Chain),
 	}
 
 	for _, file := range fileList {
@@ -131,6 +158,14 @@ func loadFileConfigFromDirectory(directory string) (*types.Configuration, error)
 				configuration.Frontends[frontendName] = frontend
 			}
 		}
+
+		for chainName, chain := range c.Chains {
+			if _, exists := configuration.Chains[chainName]; exists {
+				log.Warnf("Chain %s already configured, skipping", chainName)
+			} else {
+				configuration.Chains[chainName] = chain
+			}
+		}
 	}
 
 	return configuration, nil
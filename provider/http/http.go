@@ -0,0 +1,198 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+)
+
+// maxConfigurationSize is the maximum size of the configurations read from the endpoint.
+const maxConfigurationSize = 10 << 20
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	provider.BaseProvider `mapstructure:",squash"`
+	Endpoint              string              `description:"URL of the endpoint serving the configuration as JSON"`
+	PollInterval          flaeg.Duration      `description:"Interval of the polling of the endpoint"`
+	PollTimeout           flaeg.Duration      `description:"Timeout of the requests to the endpoint"`
+	Username              string              `description:"Basic authentication User"`
+	Password              string              `description:"Basic authentication Password"`
+	Token                 string              `description:"Bearer token, sent in the Authorization header instead of the basic authentication"`
+	TLS                   *provider.ClientTLS `description:"Enable TLS support"`
+	client                *http.Client
+	// etag and body are the ETag and the body of the last configuration sent.
+	etag string
+	body []byte
+}
+
+// Provide allows the http provider to provide configurations to traefik
+// using the given configuration channel.
+func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, _ types.Constraints) error {
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil || len(endpoint.Host) == 0 {
+		return fmt.Errorf("invalid endpoint %q for the http provider", p.Endpoint)
+	}
+	tlsConfig, err := p.TLS.CreateTLSConfig()
+	if err != nil {
+		return err
+	}
+	p.client = &http.Client{
+		Timeout:   time.Duration(p.PollTimeout),
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	pool.Go(func(stop chan bool) {
+		p.sendConfiguration(configurationChan)
+		if !p.Watch {
+			return
+		}
+
+		interval := time.Duration(p.PollInterval)
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.sendConfiguration(configurationChan)
+			}
+		}
+	})
+	return nil
+}
+
+// sendConfiguration fetches the configuration and sends it when it changed.
+// On error, the running configuration is kept.
+func (p *Provider) sendConfiguration(configurationChan chan<- types.ConfigMessage) {
+	configuration, err := p.fetchConfiguration()
+	if err != nil {
+		log.Errorf("Error fetching the configuration from %s, keeping the current one: %s", p.Endpoint, err)
+		return
+	}
+	if configuration == nil {
+		log.Debugf("Configuration unchanged at %s", p.Endpoint)
+		return
+	}
+	configurationChan <- types.ConfigMessage{
+		ProviderName:  "http",
+		Configuration: configuration,
+	}
+}
+
+// fetchConfiguration requests the configuration to the endpoint, and returns it when valid,
+// or nil when it did not change since the last one.
+func (p *Provider) fetchConfiguration() (*types.Configuration, error) {
+	request, err := http.NewRequest(http.MethodGet, p.Endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if len(p.etag) > 0 {
+		request.Header.Set("If-None-Match", p.etag)
+	}
+	if len(p.Token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+p.Token)
+	} else if len(p.Username) > 0 {
+		request.SetBasicAuth(p.Username, p.Password)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint answered %d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxConfigurationSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxConfigurationSize {
+		return nil, fmt.Errorf("configuration larger than %d bytes", maxConfigurationSize)
+	}
+	// The endpoints without ETag support serve the same body.
+	if p.body != nil && bytes.Equal(body, p.body) {
+		return nil, nil
+	}
+
+	configuration, err := decodeConfiguration(body)
+	if err != nil {
+		return nil, err
+	}
+	p.etag = response.Header.Get("ETag")
+	p.body = body
+	return configuration, nil
+}
+
+// decodeConfiguration decodes and validates a JSON configuration.
+func decodeConfiguration(body []byte) (*types.Configuration, error) {
+	configuration := new(types.Configuration)
+	if err := json.Unmarshal(body, configuration); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err)
+	}
+	if err := validateConfiguration(configuration); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err)
+	}
+	return configuration, nil
+}
+
+// validateConfiguration checks the references of the frontends, and the URLs of the servers.
+func validateConfiguration(configuration *types.Configuration) error {
+	for frontendName, frontend := range configuration.Frontends {
+		if frontend == nil {
+			return fmt.Errorf("empty frontend %s", frontendName)
+		}
+		if _, ok := configuration.Backends[frontend.Backend]; !ok {
+			return fmt.Errorf("undefined backend %q for frontend %s", frontend.Backend, frontendName)
+		}
+		if len(frontend.Routes) == 0 {
+			return fmt.Errorf("no route for frontend %s", frontendName)
+		}
+		for routeName, route := range frontend.Routes {
+			if len(route.Rule) == 0 {
+				return fmt.Errorf("empty rule for route %s of frontend %s", routeName, frontendName)
+			}
+		}
+		for _, chainName := range frontend.Chains {
+			if _, ok := configuration.Chains[chainName]; !ok {
+				return fmt.Errorf("undefined chain %q for frontend %s", chainName, frontendName)
+			}
+		}
+	}
+	for backendName, backend := range configuration.Backends {
+		if backend == nil {
+			return fmt.Errorf("empty backend %s", backendName)
+		}
+		for serverName, server := range backend.Servers {
+			serverURL, err := url.Parse(server.URL)
+			if err != nil {
+				return fmt.Errorf("invalid URL for server %s of backend %s: %s", serverName, backendName, err)
+			}
+			if len(serverURL.Scheme) == 0 || len(serverURL.Host) == 0 {
+				return fmt.Errorf("invalid URL %q for server %s of backend %s", server.URL, serverName, backendName)
+			}
+		}
+	}
+	return nil
+}
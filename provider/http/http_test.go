@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validConfiguration = `{
+	"backends": {"backend": {"servers": {"server": {"url": "http://10.0.0.1:8080", "weight": 1}}}},
+	"frontends": {"frontend": {"backend": "backend", "routes": {"route": {"rule": "Host:test.localhost"}}}}
+}`
+
+// configurationEndpoint serves its configuration with its ETag, and records the requests.
+type configurationEndpoint struct {
+	lock          sync.Mutex
+	configuration string
+	etag          string
+	requests      []*http.Request
+}
+
+func (e *configurationEndpoint) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.requests = append(e.requests, req)
+	if len(e.etag) > 0 {
+		if req.Header.Get("If-None-Match") == e.etag {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		rw.Header().Set("ETag", e.etag)
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write([]byte(e.configuration))
+}
+
+func (e *configurationEndpoint) set(configuration, etag string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.configuration = configuration
+	e.etag = etag
+}
+
+func (e *configurationEndpoint) lastRequest() *http.Request {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.requests[len(e.requests)-1]
+}
+
+func newTestProvider(endpoint string) *Provider {
+	return &Provider{
+		Endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func TestFetchConfiguration(t *testing.T) {
+	endpoint := &configurationEndpoint{configuration: validConfiguration, etag: `"v1"`}
+	ts := httptest.NewServer(endpoint)
+	defer ts.Close()
+	p := newTestProvider(ts.URL)
+
+	configuration, err := p.fetchConfiguration()
+	require.NoError(t, err)
+	require.NotNil(t, configuration)
+	assert.Equal(t, "backend", configuration.Frontends["frontend"].Backend)
+	assert.Equal(t, "http://10.0.0.1:8080", configuration.Backends["backend"].Servers["server"].URL)
+	assert.Empty(t, endpoint.lastRequest().Header.Get("If-None-Match"))
+
+	// The configuration is not sent again until its ETag changes.
+	configuration, err = p.fetchConfiguration()
+	require.NoError(t, err)
+	assert.Nil(t, configuration)
+	assert.Equal(t, `"v1"`, endpoint.lastRequest().Header.Get("If-None-Match"))
+
+	endpoint.set(`{"backends": {}}`, `"v2"`)
+	configuration, err = p.fetchConfiguration()
+	require.NoError(t, err)
+	require.NotNil(t, configuration)
+	assert.Empty(t, configuration.Backends)
+}
+
+func TestFetchConfigurationWithoutETag(t *testing.T) {
+	endpoint := &configurationEndpoint{configuration: validConfiguration}
+	ts := httptest.NewServer(endpoint)
+	defer ts.Close()
+	p := newTestProvider(ts.URL)
+
+	configuration, err := p.fetchConfiguration()
+	require.NoError(t, err)
+	require.NotNil(t, configuration)
+
+	configuration, err = p.fetchConfiguration()
+	require.NoError(t, err)
+	assert.Nil(t, configuration, "an unchanged body must not be sent again")
+}
+
+func TestFetchConfigurationAuthentication(t *testing.T) {
+	endpoint := &configurationEndpoint{configuration: validConfiguration}
+	ts := httptest.NewServer(endpoint)
+	defer ts.Close()
+
+	p := newTestProvider(ts.URL)
+	p.Username = "user"
+	p.Password = "secret"
+	_, err := p.fetchConfiguration()
+	require.NoError(t, err)
+	user, password, ok := endpoint.lastRequest().BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "secret", password)
+
+	p = newTestProvider(ts.URL)
+	p.Token = "token"
+	_, err = p.fetchConfiguration()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token", endpoint.lastRequest().Header.Get("Authorization"))
+}
+
+func TestFetchConfigurationInvalid(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		configuration string
+	}{
+		{
+			desc:          "malformed JSON",
+			configuration: `{"backends": `,
+		},
+		{
+			desc:          "undefined backend",
+			configuration: `{"frontends": {"frontend": {"backend": "backend", "routes": {"route": {"rule": "Host:test.localhost"}}}}}`,
+		},
+		{
+			desc:          "no route",
+			configuration: `{"backends": {"backend": {}}, "frontends": {"frontend": {"backend": "backend"}}}`,
+		},
+		{
+			desc:          "undefined chain",
+			configuration: `{"backends": {"backend": {}}, "frontends": {"frontend": {"backend": "backend", "chains": ["chain"], "routes": {"route": {"rule": "Host:test.localhost"}}}}}`,
+		},
+		{
+			desc:          "invalid server URL",
+			configuration: `{"backends": {"backend": {"servers": {"server": {"url": "10.0.0.1:8080"}}}}}`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(&configurationEndpoint{configuration: test.configuration})
+			defer ts.Close()
+
+			_, err := newTestProvider(ts.URL).fetchConfiguration()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProvide(t *testing.T) {
+	endpoint := &configurationEndpoint{configuration: validConfiguration}
+	ts := httptest.NewServer(endpoint)
+	defer ts.Close()
+
+	p := &Provider{
+		Endpoint:     ts.URL,
+		PollInterval: flaeg.Duration(10 * time.Millisecond),
+	}
+	p.Watch = true
+	configurationChan := make(chan types.ConfigMessage, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	require.NoError(t, p.Provide(configurationChan, pool, nil))
+
+	expectMessage := func() *types.Configuration {
+		select {
+		case message := <-configurationChan:
+			assert.Equal(t, "http", message.ProviderName)
+			return message.Configuration
+		case <-time.After(5 * time.Second):
+			t.Fatal("no configuration received")
+		}
+		return nil
+	}
+	assert.Contains(t, expectMessage().Frontends, "frontend")
+
+	// The invalid configurations are not sent, keeping the running one.
+	endpoint.set(`{"frontends": {"other": {"backend": "undefined"}}}`, "")
+	time.Sleep(50 * time.Millisecond)
+	endpoint.set(`{"backends": {"other": {}}}`, "")
+	assert.Contains(t, expectMessage().Backends, "other")
+}
+
+func TestProvideInvalidEndpoint(t *testing.T) {
+	p := &Provider{Endpoint: "foo"}
+	err := p.Provide(make(chan types.ConfigMessage), safe.NewPool(context.Background()), nil)
+	assert.Error(t, err)
+}
@@ -29,7 +29,9 @@ type Client interface {
 	GetService(namespace, name string) (*v1.Service, bool, error)
 	GetSecret(namespace, name string) (*v1.Secret, bool, error)
 	GetEndpoints(namespace, name string) (*v1.Endpoints, bool, error)
-	WatchAll(labelSelector string, stopCh <-chan struct{}) (<-chan interface{}, error)
+	GetIngressRoutes(namespaces Namespaces) []*IngressRoute
+	GetMiddleware(namespace, name string) (*Middleware, bool, error)
+	WatchAll(labelSelector string, customResources bool, stopCh <-chan struct{}) (<-chan interface{}, error)
 }
 
 type clientImpl struct {
@@ -37,13 +39,18 @@ type clientImpl struct {
 	svcController *cache.Controller
 	epController  *cache.Controller
 	secController *cache.Controller
+	irController  *cache.Controller
+	mwController  *cache.Controller
 
 	ingStore cache.Store
 	svcStore cache.Store
 	epStore  cache.Store
 	secStore cache.Store
+	irStore  cache.Store
+	mwStore  cache.Store
 
 	clientset *kubernetes.Clientset
+	crdClient *rest.RESTClient
 }
 
 // NewInClusterClient returns a new Provider client that is expected to run
@@ -92,8 +99,14 @@ func createClientFromConfig(c *rest.Config) (Client, error) {
 		return nil, err
 	}
 
+	crdClient, err := newCustomResourcesClient(c)
+	if err != nil {
+		return nil, err
+	}
+
 	return &clientImpl{
 		clientset: clientset,
+		crdClient: crdClient,
 	}, nil
 }
 
@@ -236,9 +249,73 @@ func (c *clientImpl) WatchSecrets(watchCh chan<- interface{}, stopCh <-chan stru
 	})
 }
 
+// GetIngressRoutes returns all IngressRoute custom resources in the cluster
+func (c *clientImpl) GetIngressRoutes(namespaces Namespaces) []*IngressRoute {
+	irList := c.irStore.List()
+	result := make([]*IngressRoute, 0, len(irList))
+
+	for _, obj := range irList {
+		ingressRoute := obj.(*IngressRoute)
+		if inNamespaces(ingressRoute.ObjectMeta.Namespace, namespaces) {
+			result = append(result, ingressRoute)
+		}
+	}
+
+	return result
+}
+
+// WatchIngressRoutes starts the watch of IngressRoute custom resources and updates the corresponding store
+func (c *clientImpl) WatchIngressRoutes(labelSelector labels.Selector, watchCh chan<- interface{}, stopCh <-chan struct{}) {
+	source := NewListWatchFromClient(
+		c.crdClient,
+		"ingressroutes",
+		api.NamespaceAll,
+		fields.Everything(),
+		labelSelector)
+
+	c.irStore, c.irController = cache.NewInformer(
+		source,
+		&IngressRoute{},
+		resyncPeriod,
+		newResourceEventHandlerFuncs(watchCh))
+	safe.Go(func() {
+		c.irController.Run(stopCh)
+	})
+}
+
+// GetMiddleware returns the named Middleware custom resource from the named namespace
+func (c *clientImpl) GetMiddleware(namespace, name string) (*Middleware, bool, error) {
+	var middleware *Middleware
+	item, exists, err := c.mwStore.GetByKey(namespace + "/" + name)
+	if item != nil {
+		middleware = item.(*Middleware)
+	}
+
+	return middleware, exists, err
+}
+
+// WatchMiddlewares starts the watch of Middleware custom resources and updates the corresponding store
+func (c *clientImpl) WatchMiddlewares(watchCh chan<- interface{}, stopCh <-chan struct{}) {
+	source := cache.NewListWatchFromClient(
+		c.crdClient,
+		"middlewares",
+		api.NamespaceAll,
+		fields.Everything())
+
+	c.mwStore, c.mwController = cache.NewInformer(
+		source,
+		&Middleware{},
+		resyncPeriod,
+		newResourceEventHandlerFuncs(watchCh))
+	safe.Go(func() {
+		c.mwController.Run(stopCh)
+	})
+}
+
 // WatchAll returns events in the cluster and updates the stores via informer
-// Filters ingresses by labelSelector
-func (c *clientImpl) WatchAll(labelSelector string, stopCh <-chan struct{}) (<-chan interface{}, error) {
+// Filters ingresses and ingress routes by labelSelector
+// The Traefik custom resources are only watched if customResources is true
+func (c *clientImpl) WatchAll(labelSelector string, customResources bool, stopCh <-chan struct{}) (<-chan interface{}, error) {
 	watchCh := make(chan interface{}, 1)
 	eventCh := make(chan interface{}, 1)
 
@@ -251,6 +328,10 @@ func (c *clientImpl) WatchAll(labelSelector string, stopCh <-chan struct{}) (<-c
 	c.WatchServices(eventCh, stopCh)
 	c.WatchEndpoints(eventCh, stopCh)
 	c.WatchSecrets(eventCh, stopCh)
+	if customResources {
+		c.WatchIngressRoutes(kubeLabelSelector, eventCh, stopCh)
+		c.WatchMiddlewares(eventCh, stopCh)
+	}
 
 	safe.Go(func() {
 		defer close(watchCh)
@@ -275,16 +356,23 @@ func (c *clientImpl) fireEvent(event interface{}, eventCh chan interface{}) {
 	if !c.ingController.HasSynced() || !c.svcController.HasSynced() || !c.epController.HasSynced() {
 		return
 	}
+	if c.irController != nil && (!c.irController.HasSynced() || !c.mwController.HasSynced()) {
+		return
+	}
 	eventHandlerFunc(eventCh, event)
 }
 
 // HasNamespace checks if the ingress is in one of the namespaces
 func HasNamespace(ingress *v1beta1.Ingress, namespaces Namespaces) bool {
+	return inNamespaces(ingress.ObjectMeta.Namespace, namespaces)
+}
+
+func inNamespaces(namespace string, namespaces Namespaces) bool {
 	if len(namespaces) == 0 {
 		return true
 	}
 	for _, n := range namespaces {
-		if ingress.ObjectMeta.Namespace == n {
+		if namespace == n {
 			return true
 		}
 	}
@@ -0,0 +1,143 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/api/unversioned"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/pkg/runtime/serializer"
+	"k8s.io/client-go/pkg/util/intstr"
+	"k8s.io/client-go/rest"
+
+	"github.com/containous/traefik/types"
+)
+
+const (
+	// CustomResourcesGroup is the API group of the Traefik custom resources.
+	CustomResourcesGroup = "traefik.containo.us"
+	// CustomResourcesVersion is the API version of the Traefik custom resources.
+	CustomResourcesVersion = "v1alpha1"
+)
+
+var customResourcesGroupVersion = unversioned.GroupVersion{Group: CustomResourcesGroup, Version: CustomResourcesVersion}
+
+// IngressRoute is a custom resource describing Traefik frontends and their backends,
+// one frontend being created per route.
+type IngressRoute struct {
+	unversioned.TypeMeta `json:",inline"`
+	v1.ObjectMeta        `json:"metadata"`
+	Spec                 IngressRouteSpec `json:"spec"`
+}
+
+// IngressRouteSpec holds the routes of an IngressRoute.
+type IngressRouteSpec struct {
+	EntryPoints []string `json:"entryPoints,omitempty"`
+	Routes      []Route  `json:"routes"`
+}
+
+// Route holds a frontend rule, the middlewares applied to its requests,
+// and the services load-balanced by its backend.
+type Route struct {
+	Match       string    `json:"match"`
+	Priority    int       `json:"priority,omitempty"`
+	Middlewares []string  `json:"middlewares,omitempty"`
+	Services    []Service `json:"services"`
+}
+
+// Service references a Kubernetes service port, its endpoints being weighted with Weight.
+type Service struct {
+	Name   string             `json:"name"`
+	Port   intstr.IntOrString `json:"port"`
+	Weight int                `json:"weight,omitempty"`
+}
+
+// IngressRouteList is a list of IngressRoutes.
+type IngressRouteList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata"`
+	Items                []IngressRoute `json:"items"`
+}
+
+// Middleware is a custom resource describing a middleware chain,
+// which the routes of the IngressRoutes of its namespace reference by name.
+type Middleware struct {
+	unversioned.TypeMeta `json:",inline"`
+	v1.ObjectMeta        `json:"metadata"`
+	Spec                 types.ChainMiddleware `json:"spec"`
+}
+
+// MiddlewareList is a list of Middlewares.
+type MiddlewareList struct {
+	unversioned.TypeMeta `json:",inline"`
+	unversioned.ListMeta `json:"metadata"`
+	Items                []Middleware `json:"items"`
+}
+
+// GetObjectKind returns the kind of the object.
+func (i *IngressRoute) GetObjectKind() unversioned.ObjectKind { return &i.TypeMeta }
+
+// GetObjectKind returns the kind of the object.
+func (l *IngressRouteList) GetObjectKind() unversioned.ObjectKind { return &l.TypeMeta }
+
+// GetObjectKind returns the kind of the object.
+func (m *Middleware) GetObjectKind() unversioned.ObjectKind { return &m.TypeMeta }
+
+// GetObjectKind returns the kind of the object.
+func (l *MiddlewareList) GetObjectKind() unversioned.ObjectKind { return &l.TypeMeta }
+
+// The client-go JSON decoder does not handle the embedded types of the custom resources,
+// they are decoded with encoding/json instead.
+type ingressRouteCopy IngressRoute
+type ingressRouteListCopy IngressRouteList
+type middlewareCopy Middleware
+type middlewareListCopy MiddlewareList
+
+// UnmarshalJSON decodes an IngressRoute.
+func (i *IngressRoute) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*ingressRouteCopy)(i))
+}
+
+// UnmarshalJSON decodes an IngressRouteList.
+func (l *IngressRouteList) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*ingressRouteListCopy)(l))
+}
+
+// UnmarshalJSON decodes a Middleware.
+func (m *Middleware) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*middlewareCopy)(m))
+}
+
+// UnmarshalJSON decodes a MiddlewareList.
+func (l *MiddlewareList) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*middlewareListCopy)(l))
+}
+
+func addCustomResourcesToScheme(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(customResourcesGroupVersion,
+		&IngressRoute{},
+		&IngressRouteList{},
+		&Middleware{},
+		&MiddlewareList{},
+		&api.ListOptions{},
+		&api.DeleteOptions{},
+	)
+	return nil
+}
+
+// newCustomResourcesClient creates a REST client of the Traefik custom resources API group.
+func newCustomResourcesClient(c *rest.Config) (*rest.RESTClient, error) {
+	schemeBuilder := runtime.NewSchemeBuilder(addCustomResourcesToScheme)
+	if err := schemeBuilder.AddToScheme(api.Scheme); err != nil {
+		return nil, err
+	}
+
+	config := *c
+	config.GroupVersion = &customResourcesGroupVersion
+	config.APIPath = "/apis"
+	config.ContentType = runtime.ContentTypeJSON
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: api.Codecs}
+
+	return rest.RESTClientFor(&config)
+}
@@ -49,6 +49,7 @@ type Provider struct {
 	DisablePassHostHeaders bool       `description:"Kubernetes disable PassHost Headers"`
 	Namespaces             Namespaces `description:"Kubernetes namespaces"`
 	LabelSelector          string     `description:"Kubernetes api label selector to use"`
+	CustomResources        bool       `description:"Watch the Traefik IngressRoute and Middleware custom resources"`
 	lastConfiguration      safe.Safe
 }
 
@@ -88,7 +89,7 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 				stopWatch := make(chan struct{}, 1)
 				defer close(stopWatch)
 				log.Debugf("Using label selector: '%s'", p.LabelSelector)
-				eventsChan, err := k8sClient.WatchAll(p.LabelSelector, stopWatch)
+				eventsChan, err := k8sClient.WatchAll(p.LabelSelector, p.CustomResources, stopWatch)
 				if err != nil {
 					log.Errorf("Error watching kubernetes events: %v", err)
 					timer := time.NewTimer(1 * time.Second)
@@ -109,6 +110,11 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 						if err != nil {
 							return err
 						}
+						if p.CustomResources {
+							if err := p.loadIngressRoutes(k8sClient, templateObjects); err != nil {
+								return err
+							}
+						}
 						if reflect.DeepEqual(p.lastConfiguration.Get(), templateObjects) {
 							log.Debugf("Skipping event from kubernetes %+v", event)
 						} else {
@@ -237,14 +243,15 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 					continue
 				}
 
-				if expression := service.Annotations[types.LabelTraefikBackendCircuitbreaker]; expression != "" {
+				if expression := getCircuitBreakerExpression(service); expression != "" {
 					templateObjects.Backends[r.Host+pa.Path].CircuitBreaker = &types.CircuitBreaker{
 						Expression: expression,
 					}
 				}
 
-				if service.Annotations[types.LabelBackendLoadbalancerMethod] == "drr" {
-					templateObjects.Backends[r.Host+pa.Path].LoadBalancer.Method = "drr"
+				switch method := service.Annotations[types.LabelBackendLoadbalancerMethod]; method {
+				case "drr", "ewma":
+					templateObjects.Backends[r.Host+pa.Path].LoadBalancer.Method = method
 				}
 
 				if service.Annotations[types.LabelBackendLoadbalancerSticky] == "true" {
@@ -283,19 +290,7 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 								break
 							}
 
-							for _, subset := range endpoints.Subsets {
-								for _, address := range subset.Addresses {
-									url := protocol + "://" + address.IP + ":" + strconv.Itoa(endpointPortNumber(port, subset.Ports))
-									name := url
-									if address.TargetRef != nil && address.TargetRef.Name != "" {
-										name = address.TargetRef.Name
-									}
-									templateObjects.Backends[r.Host+pa.Path].Servers[name] = types.Server{
-										URL:    url,
-										Weight: 1,
-									}
-								}
-							}
+							loadEndpointServers(templateObjects.Backends[r.Host+pa.Path].Servers, protocol, port, endpoints, 1)
 						}
 						break
 					}
@@ -306,6 +301,146 @@ func (p *Provider) loadIngresses(k8sClient Client) (*types.Configuration, error)
 	return &templateObjects, nil
 }
 
+// loadIngressRoutes adds to templateObjects a frontend and its backend per route of the IngressRoute custom resources,
+// and a chain per Middleware custom resource referenced by the routes.
+func (p *Provider) loadIngressRoutes(k8sClient Client, templateObjects *types.Configuration) error {
+	if templateObjects.Chains == nil {
+		templateObjects.Chains = map[string]*types.Chain{}
+	}
+
+	for _, ingressRoute := range k8sClient.GetIngressRoutes(p.Namespaces) {
+		namespace := ingressRoute.ObjectMeta.Namespace
+
+	route:
+		for index, r := range ingressRoute.Spec.Routes {
+			name := namespace + "/" + ingressRoute.ObjectMeta.Name + "/" + strconv.Itoa(index)
+
+			var chains []string
+			for _, middlewareName := range r.Middlewares {
+				chainName := namespace + "/" + middlewareName
+				if _, exists := templateObjects.Chains[chainName]; !exists {
+					middleware, exists, err := k8sClient.GetMiddleware(namespace, middlewareName)
+					if err != nil {
+						log.Errorf("Error while retrieving middleware information from k8s API %s: %v", chainName, err)
+						return err
+					}
+					if !exists {
+						log.Errorf("Middleware not found for %s, skipping route %s", chainName, name)
+						continue route
+					}
+					templateObjects.Chains[chainName] = &types.Chain{
+						Middlewares: []types.ChainMiddleware{middleware.Spec},
+					}
+				}
+				chains = append(chains, chainName)
+			}
+
+			backend := &types.Backend{
+				Servers: make(map[string]types.Server),
+				LoadBalancer: &types.LoadBalancer{
+					Method: "wrr",
+				},
+			}
+			for _, s := range r.Services {
+				service, exists, err := k8sClient.GetService(namespace, s.Name)
+				if err != nil {
+					log.Errorf("Error while retrieving service information from k8s API %s/%s: %v", namespace, s.Name, err)
+					return err
+				}
+				if !exists {
+					log.Errorf("Service not found for %s/%s, skipping route %s", namespace, s.Name, name)
+					continue route
+				}
+
+				weight := s.Weight
+				if weight == 0 {
+					weight = 1
+				}
+				if err := loadServiceServers(k8sClient, backend, service, s.Port, weight); err != nil {
+					return err
+				}
+			}
+
+			templateObjects.Backends[name] = backend
+			templateObjects.Frontends[name] = &types.Frontend{
+				Backend:        name,
+				EntryPoints:    ingressRoute.Spec.EntryPoints,
+				PassHostHeader: p.getPassHostHeader(),
+				Priority:       r.Priority,
+				Chains:         chains,
+				Routes: map[string]types.Route{
+					"match": {
+						Rule: r.Match,
+					},
+				},
+			}
+		}
+	}
+	return nil
+}
+
+// loadServiceServers adds to backend a server per endpoint of the given service port, weighted with weight.
+func loadServiceServers(k8sClient Client, backend *types.Backend, service *v1.Service, servicePort intstr.IntOrString, weight int) error {
+	for _, port := range service.Spec.Ports {
+		if !equalPorts(port, servicePort) {
+			continue
+		}
+
+		protocol := "http"
+		if port.Port == 443 {
+			protocol = "https"
+		}
+
+		if service.Spec.Type == "ExternalName" {
+			url := protocol + "://" + service.Spec.ExternalName
+			backend.Servers[url] = types.Server{
+				URL:    url,
+				Weight: weight,
+			}
+			return nil
+		}
+
+		endpoints, exists, err := k8sClient.GetEndpoints(service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+		if err != nil {
+			log.Errorf("Error retrieving endpoints %s/%s: %v", service.ObjectMeta.Namespace, service.ObjectMeta.Name, err)
+			return err
+		}
+		if !exists || len(endpoints.Subsets) == 0 {
+			log.Warnf("Endpoints not available for %s/%s", service.ObjectMeta.Namespace, service.ObjectMeta.Name)
+			return nil
+		}
+
+		loadEndpointServers(backend.Servers, protocol, port, endpoints, weight)
+		return nil
+	}
+	return nil
+}
+
+// loadEndpointServers adds to servers a server per ready address of the endpoints, on the port of their subset
+// matching the service port. The not ready addresses, and the subsets without the port, are skipped.
+func loadEndpointServers(servers map[string]types.Server, protocol string, port v1.ServicePort, endpoints *v1.Endpoints, weight int) {
+	for _, subset := range endpoints.Subsets {
+		portNumber, ok := endpointPortNumber(port, subset.Ports)
+		if !ok {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			url := protocol + "://" + address.IP + ":" + strconv.Itoa(portNumber)
+			name := url
+			if address.TargetRef != nil && address.TargetRef.Name != "" {
+				name = address.TargetRef.Name
+			}
+			servers[name] = types.Server{
+				URL:    url,
+				Weight: weight,
+			}
+		}
+		if len(subset.NotReadyAddresses) > 0 {
+			log.Debugf("Skipping %d not ready addresses of the endpoints %s/%s", len(subset.NotReadyAddresses), endpoints.ObjectMeta.Namespace, endpoints.ObjectMeta.Name)
+		}
+	}
+}
+
 func getRuleForPath(pa v1beta1.HTTPIngressPath, i *v1beta1.Ingress) string {
 	if len(pa.Path) == 0 {
 		return ""
@@ -325,6 +460,15 @@ func getRuleForPath(pa v1beta1.HTTPIngressPath, i *v1beta1.Ingress) string {
 	return rule
 }
 
+// getCircuitBreakerExpression returns the circuit breaker expression of the service,
+// from the traefik.backend.circuitbreaker.expression annotation or the older traefik.backend.circuitbreaker one.
+func getCircuitBreakerExpression(service *v1.Service) string {
+	if expression := service.Annotations[types.LabelBackendCircuitbreakerExpression]; expression != "" {
+		return expression
+	}
+	return service.Annotations[types.LabelTraefikBackendCircuitbreaker]
+}
+
 func (p *Provider) getPriority(path v1beta1.HTTPIngressPath, i *v1beta1.Ingress) int {
 	priority := len(path.Path)
 
@@ -393,18 +537,22 @@ func loadAuthCredentials(namespace, secretName string, k8sClient Client) ([]stri
 	return creds, nil
 }
 
-func endpointPortNumber(servicePort v1.ServicePort, endpointPorts []v1.EndpointPort) int {
-	if len(endpointPorts) > 0 {
-		//name is optional if there is only one port
-		port := endpointPorts[0]
-		for _, endpointPort := range endpointPorts {
-			if servicePort.Name == endpointPort.Name {
-				port = endpointPort
-			}
+// endpointPortNumber returns the port of the endpoints of a subset matching the service port, by name,
+// and whether the subset has it. The service port is used when the subset has no ports.
+func endpointPortNumber(servicePort v1.ServicePort, endpointPorts []v1.EndpointPort) (int, bool) {
+	if len(endpointPorts) == 0 {
+		return int(servicePort.Port), true
+	}
+	for _, endpointPort := range endpointPorts {
+		if servicePort.Name == endpointPort.Name {
+			return int(endpointPort.Port), true
 		}
-		return int(port.Port)
 	}
-	return int(servicePort.Port)
+	//name is optional if there is only one port
+	if len(endpointPorts) == 1 && (servicePort.Name == "" || endpointPorts[0].Name == "") {
+		return int(endpointPorts[0].Port), true
+	}
+	return 0, false
 }
 
 func equalPorts(servicePort v1.ServicePort, ingressPort intstr.IntOrString) bool {
@@ -438,6 +586,9 @@ func (p *Provider) loadConfig(templateObjects types.Configuration) *types.Config
 	configuration, err := p.GetConfiguration("templates/kubernetes.tmpl", FuncMap, templateObjects)
 	if err != nil {
 		log.Error(err)
+		return configuration
 	}
+	// The chains built from Middleware custom resources are not rendered by the template.
+	configuration.Chains = templateObjects.Chains
 	return configuration
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/containous/traefik/types"
@@ -2309,6 +2310,194 @@ func TestMissingResources(t *testing.T) {
 	}
 }
 
+func TestLoadIngressesEndpointSubsets(t *testing.T) {
+	ingresses := []*v1beta1.Ingress{{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "testing",
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{
+				{
+					Host: "foo",
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{
+								{
+									Backend: v1beta1.IngressBackend{
+										ServiceName: "service1",
+										ServicePort: intstr.FromString("http"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+	services := []*v1.Service{
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service1",
+				UID:       "1",
+				Namespace: "testing",
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "10.0.0.1",
+				Ports: []v1.ServicePort{
+					{
+						Name: "http",
+						Port: 80,
+					},
+					{
+						Name: "metrics",
+						Port: 9090,
+					},
+				},
+			},
+		},
+	}
+	endpoints := func(ready, notReady []string) []*v1.Endpoints {
+		addresses := func(ips []string) []v1.EndpointAddress {
+			var addresses []v1.EndpointAddress
+			for _, ip := range ips {
+				addresses = append(addresses, v1.EndpointAddress{IP: ip})
+			}
+			return addresses
+		}
+		return []*v1.Endpoints{
+			{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "service1",
+					UID:       "1",
+					Namespace: "testing",
+				},
+				Subsets: []v1.EndpointSubset{
+					{
+						Addresses:         addresses(ready),
+						NotReadyAddresses: addresses(notReady),
+						Ports: []v1.EndpointPort{
+							{
+								Name: "metrics",
+								Port: 9100,
+							},
+							{
+								Name: "http",
+								Port: 8080,
+							},
+						},
+					},
+					{
+						Addresses: addresses([]string{"10.10.0.3"}),
+						Ports: []v1.EndpointPort{
+							{
+								Name: "http",
+								Port: 8081,
+							},
+						},
+					},
+					{
+						Addresses: addresses([]string{"10.10.0.4"}),
+						Ports: []v1.EndpointPort{
+							{
+								Name: "metrics",
+								Port: 9100,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		desc            string
+		ready           []string
+		notReady        []string
+		expectedServers []string
+	}{
+		{
+			desc:            "mixed ready and not ready addresses",
+			ready:           []string{"10.10.0.1"},
+			notReady:        []string{"10.10.0.2"},
+			expectedServers: []string{"http://10.10.0.1:8080", "http://10.10.0.3:8081"},
+		},
+		{
+			desc:            "address becoming not ready",
+			notReady:        []string{"10.10.0.1", "10.10.0.2"},
+			expectedServers: []string{"http://10.10.0.3:8081"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := clientMock{
+				ingresses: ingresses,
+				services:  services,
+				endpoints: endpoints(test.ready, test.notReady),
+			}
+			provider := Provider{}
+			actual, err := provider.loadIngresses(client)
+			if err != nil {
+				t.Fatalf("error %+v", err)
+			}
+
+			expected := map[string]types.Server{}
+			for _, url := range test.expectedServers {
+				expected[url] = types.Server{URL: url, Weight: 1}
+			}
+			assert.Equal(t, expected, actual.Backends["foo"].Servers)
+		})
+	}
+}
+
+func TestGetCircuitBreakerExpression(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			desc:     "no annotation",
+			expected: "",
+		},
+		{
+			desc: "expression annotation",
+			annotations: map[string]string{
+				types.LabelBackendCircuitbreakerExpression: "NetworkErrorRatio() > 0.5",
+			},
+			expected: "NetworkErrorRatio() > 0.5",
+		},
+		{
+			desc: "legacy annotation",
+			annotations: map[string]string{
+				types.LabelTraefikBackendCircuitbreaker: "LatencyAtQuantileMS(50.0) > 50",
+			},
+			expected: "LatencyAtQuantileMS(50.0) > 50",
+		},
+		{
+			desc: "expression annotation over the legacy one",
+			annotations: map[string]string{
+				types.LabelBackendCircuitbreakerExpression: "NetworkErrorRatio() > 0.5",
+				types.LabelTraefikBackendCircuitbreaker:    "LatencyAtQuantileMS(50.0) > 50",
+			},
+			expected: "NetworkErrorRatio() > 0.5",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+			service := &v1.Service{ObjectMeta: v1.ObjectMeta{Annotations: test.annotations}}
+			assert.Equal(t, test.expected, getCircuitBreakerExpression(service))
+		})
+	}
+}
+
 func TestBasicAuthInTemplate(t *testing.T) {
 	ingresses := []*v1beta1.Ingress{
 		{
@@ -2396,12 +2585,276 @@ func TestBasicAuthInTemplate(t *testing.T) {
 	}
 }
 
+func TestLoadIngressRoutes(t *testing.T) {
+	var ingressRoute IngressRoute
+	err := json.Unmarshal([]byte(`{
+		"apiVersion": "traefik.containo.us/v1alpha1",
+		"kind": "IngressRoute",
+		"metadata": {"name": "whoami", "namespace": "testing"},
+		"spec": {
+			"entryPoints": ["https"],
+			"routes": [
+				{
+					"match": "Host:foo.example.com;PathPrefix:/api",
+					"priority": 10,
+					"middlewares": ["secured"],
+					"services": [
+						{"name": "service1", "port": 80, "weight": 3},
+						{"name": "service2", "port": "http"}
+					]
+				},
+				{
+					"match": "Host:bar.example.com",
+					"middlewares": ["missing"],
+					"services": [{"name": "service1", "port": 80}]
+				}
+			]
+		}
+	}`), &ingressRoute)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	var middleware Middleware
+	err = json.Unmarshal([]byte(`{
+		"apiVersion": "traefik.containo.us/v1alpha1",
+		"kind": "Middleware",
+		"metadata": {"name": "secured", "namespace": "testing"},
+		"spec": {
+			"whitelistSourceRange": ["10.0.0.0/8"],
+			"headers": {"customRequestHeaders": {"X-Secured": "true"}}
+		}
+	}`), &middleware)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	services := []*v1.Service{
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service1",
+				UID:       "1",
+				Namespace: "testing",
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "10.0.0.1",
+				Ports: []v1.ServicePort{
+					{
+						Port: 80,
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service2",
+				UID:       "2",
+				Namespace: "testing",
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP:    "10.0.0.2",
+				Type:         "ExternalName",
+				ExternalName: "example.com",
+				Ports: []v1.ServicePort{
+					{
+						Name: "http",
+						Port: 80,
+					},
+				},
+			},
+		},
+	}
+	endpoints := []*v1.Endpoints{
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service1",
+				UID:       "1",
+				Namespace: "testing",
+			},
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses: []v1.EndpointAddress{
+						{
+							IP: "10.10.0.1",
+						},
+						{
+							IP: "10.10.0.2",
+						},
+					},
+					Ports: []v1.EndpointPort{
+						{
+							Port: 8080,
+						},
+					},
+				},
+			},
+		},
+	}
+	client := clientMock{
+		services:      services,
+		endpoints:     endpoints,
+		ingressRoutes: []*IngressRoute{&ingressRoute},
+		middlewares:   []*Middleware{&middleware},
+		watchChan:     make(chan interface{}),
+	}
+	provider := Provider{CustomResources: true}
+	actual, err := provider.loadIngresses(client)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	err = provider.loadIngressRoutes(client, actual)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	expected := &types.Configuration{
+		Backends: map[string]*types.Backend{
+			"testing/whoami/0": {
+				Servers: map[string]types.Server{
+					"http://10.10.0.1:8080": {
+						URL:    "http://10.10.0.1:8080",
+						Weight: 3,
+					},
+					"http://10.10.0.2:8080": {
+						URL:    "http://10.10.0.2:8080",
+						Weight: 3,
+					},
+					"http://example.com": {
+						URL:    "http://example.com",
+						Weight: 1,
+					},
+				},
+				LoadBalancer: &types.LoadBalancer{
+					Method: "wrr",
+				},
+			},
+		},
+		Frontends: map[string]*types.Frontend{
+			"testing/whoami/0": {
+				Backend:        "testing/whoami/0",
+				EntryPoints:    []string{"https"},
+				PassHostHeader: true,
+				Priority:       10,
+				Chains:         []string{"testing/secured"},
+				Routes: map[string]types.Route{
+					"match": {
+						Rule: "Host:foo.example.com;PathPrefix:/api",
+					},
+				},
+			},
+		},
+		Chains: map[string]*types.Chain{
+			"testing/secured": {
+				Middlewares: []types.ChainMiddleware{
+					{
+						WhitelistSourceRange: []string{"10.0.0.0/8"},
+						Headers: types.Headers{
+							CustomRequestHeaders: map[string]string{"X-Secured": "true"},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, actual)
+
+	rendered := provider.loadConfig(*actual)
+	assert.Equal(t, []string{"https"}, rendered.Frontends["testing/whoami/0"].EntryPoints)
+	assert.Equal(t, []string{"testing/secured"}, rendered.Frontends["testing/whoami/0"].Chains)
+	assert.Equal(t, expected.Chains, rendered.Chains)
+}
+
+func TestLoadIngressRoutesServiceSharesFollowReadyEndpoints(t *testing.T) {
+	var ingressRoute IngressRoute
+	err := json.Unmarshal([]byte(`{
+		"apiVersion": "traefik.containo.us/v1alpha1",
+		"kind": "IngressRoute",
+		"metadata": {"name": "whoami", "namespace": "testing"},
+		"spec": {
+			"routes": [
+				{
+					"match": "Host:foo.example.com",
+					"services": [
+						{"name": "blue", "port": 80},
+						{"name": "green", "port": 80}
+					]
+				}
+			]
+		}
+	}`), &ingressRoute)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	service := func(name, clusterIP string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "testing"},
+			Spec: v1.ServiceSpec{
+				ClusterIP: clusterIP,
+				Ports:     []v1.ServicePort{{Port: 80}},
+			},
+		}
+	}
+	endpoints := func(name string, ready, notReady []string) *v1.Endpoints {
+		addresses := func(ips []string) []v1.EndpointAddress {
+			var addresses []v1.EndpointAddress
+			for _, ip := range ips {
+				addresses = append(addresses, v1.EndpointAddress{IP: ip, TargetRef: &v1.ObjectReference{Name: name + "-" + ip}})
+			}
+			return addresses
+		}
+		return &v1.Endpoints{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "testing"},
+			Subsets: []v1.EndpointSubset{
+				{
+					Addresses:         addresses(ready),
+					NotReadyAddresses: addresses(notReady),
+					Ports:             []v1.EndpointPort{{Port: 8080}},
+				},
+			},
+		}
+	}
+	client := clientMock{
+		services: []*v1.Service{service("blue", "10.0.0.1"), service("green", "10.0.0.2")},
+		endpoints: []*v1.Endpoints{
+			endpoints("blue", []string{"10.10.0.1", "10.10.0.2", "10.10.0.3"}, []string{"10.10.0.4"}),
+			endpoints("green", []string{"10.10.1.1"}, nil),
+		},
+		ingressRoutes: []*IngressRoute{&ingressRoute},
+		watchChan:     make(chan interface{}),
+	}
+
+	provider := Provider{CustomResources: true}
+	configuration := &types.Configuration{
+		Backends:  map[string]*types.Backend{},
+		Frontends: map[string]*types.Frontend{},
+	}
+	err = provider.loadIngressRoutes(client, configuration)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	// Each ready endpoint is a server weighted with its service weight, the share of a service following its replicas.
+	shares := map[string]int{}
+	for name, server := range configuration.Backends["testing/whoami/0"].Servers {
+		switch {
+		case strings.HasPrefix(name, "blue-"):
+			shares["blue"] += server.Weight
+		case strings.HasPrefix(name, "green-"):
+			shares["green"] += server.Weight
+		}
+	}
+	assert.Equal(t, map[string]int{"blue": 3, "green": 1}, shares)
+}
+
 type clientMock struct {
-	ingresses []*v1beta1.Ingress
-	services  []*v1.Service
-	secrets   []*v1.Secret
-	endpoints []*v1.Endpoints
-	watchChan chan interface{}
+	ingresses     []*v1beta1.Ingress
+	services      []*v1.Service
+	secrets       []*v1.Secret
+	endpoints     []*v1.Endpoints
+	ingressRoutes []*IngressRoute
+	middlewares   []*Middleware
+	watchChan     chan interface{}
 
 	apiServiceError   error
 	apiSecretError    error
@@ -2459,6 +2912,26 @@ func (c clientMock) GetEndpoints(namespace, name string) (*v1.Endpoints, bool, e
 	return &v1.Endpoints{}, false, nil
 }
 
-func (c clientMock) WatchAll(labelString string, stopCh <-chan struct{}) (<-chan interface{}, error) {
+func (c clientMock) GetIngressRoutes(namespaces Namespaces) []*IngressRoute {
+	result := make([]*IngressRoute, 0, len(c.ingressRoutes))
+
+	for _, ingressRoute := range c.ingressRoutes {
+		if inNamespaces(ingressRoute.Namespace, namespaces) {
+			result = append(result, ingressRoute)
+		}
+	}
+	return result
+}
+
+func (c clientMock) GetMiddleware(namespace, name string) (*Middleware, bool, error) {
+	for _, middleware := range c.middlewares {
+		if middleware.Namespace == namespace && middleware.Name == name {
+			return middleware, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (c clientMock) WatchAll(labelString string, customResources bool, stopCh <-chan struct{}) (<-chan interface{}, error) {
 	return c.watchChan, nil
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -28,6 +29,8 @@ type Provider struct {
 	Password              string              `description:"KV Password"`
 	storeType             store.Backend
 	kvclient              store.Store
+	configurationChan     chan<- types.ConfigMessage
+	lock                  sync.Mutex
 }
 
 // CreateStore create the K/V store
@@ -101,6 +104,9 @@ func (p *Provider) watchKv(configurationChan chan<- types.ConfigMessage, prefix
 // Provide provides the configuration to traefik via the configuration channel
 func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
 	p.Constraints = append(p.Constraints, constraints...)
+	p.lock.Lock()
+	p.configurationChan = configurationChan
+	p.lock.Unlock()
 	operation := func() error {
 		if _, err := p.kvclient.Exists("qmslkjdfmqlskdjfmqlksjazçueznbvbwzlkajzebvkwjdcqmlsfj"); err != nil {
 			return fmt.Errorf("Failed to test KV store connection: %v", err)
@@ -130,6 +136,26 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
+// Refresh sends the configuration of the KV store again.
+func (p *Provider) Refresh() error {
+	p.lock.Lock()
+	configurationChan := p.configurationChan
+	p.lock.Unlock()
+	if configurationChan == nil {
+		return errors.New("provider not started")
+	}
+
+	configuration := p.loadConfig()
+	if configuration == nil {
+		return errors.New("cannot load the KV configuration")
+	}
+	configurationChan <- types.ConfigMessage{
+		ProviderName:  string(p.storeType),
+		Configuration: configuration,
+	}
+	return nil
+}
+
 func (p *Provider) loadConfig() *types.Configuration {
 	templateObjects := struct {
 		Prefix string
@@ -149,6 +175,7 @@ func (p *Provider) loadConfig() *types.Configuration {
 	configuration, err := p.GetConfiguration("templates/kv.tmpl", KvFuncMap, templateObjects)
 	if err != nil {
 		log.Error(err)
+		return nil
 	}
 
 	for key, frontend := range configuration.Frontends {
@@ -0,0 +1,182 @@
+package marathon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cenk/backoff"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+	"github.com/donovanhide/eventsource"
+	"github.com/gambol99/go-marathon"
+)
+
+const (
+	eventStreamPath     = "/v2/events"
+	defaultPollInterval = 15 * time.Second
+)
+
+// watch sends the configuration of the Marathon applications on each application event of the event stream,
+// and on each (re)connection to the stream, as events may have been missed while it was unavailable.
+// The stream is reconnected with an exponential backoff, the applications being polled in the meantime.
+// When the polling is forced, the event stream is not used at all.
+func (p *Provider) watch(config marathon.Config, configurationChan chan<- types.ConfigMessage, stop chan bool) {
+	if p.Polling {
+		p.poll(configurationChan, stop, nil)
+		return
+	}
+
+	streamBackOff := backoff.NewExponentialBackOff()
+	streamBackOff.MaxElapsedTime = 0
+	for {
+		err := p.listenEventStream(config, configurationChan, stop, streamBackOff.Reset)
+		if err == nil {
+			return
+		}
+		retry := streamBackOff.NextBackOff()
+		log.Errorf("Marathon event stream error: %s, polling the applications until reconnecting in %s", err, retry)
+		if !p.poll(configurationChan, stop, time.After(retry)) {
+			return
+		}
+	}
+}
+
+// poll sends the configuration of the Marathon applications every poll interval until the until channel fires,
+// returning false if it has been stopped.
+func (p *Provider) poll(configurationChan chan<- types.ConfigMessage, stop chan bool, until <-chan time.Time) bool {
+	ticker := time.NewTicker(p.getPollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-until:
+			return true
+		case <-ticker.C:
+			p.sendMarathonConfig(configurationChan)
+		}
+	}
+}
+
+// listenEventStream connects to the event stream of the first available Marathon endpoint,
+// calling connected and resynchronizing the configuration once connected.
+// It returns nil once stopped, or the error ending the stream.
+func (p *Provider) listenEventStream(config marathon.Config, configurationChan chan<- types.ConfigMessage, stop chan bool, connected func()) error {
+	stream, err := connectEventStream(config)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	connected()
+	log.Debug("Connected to the Marathon event stream")
+	p.sendMarathonConfig(configurationChan)
+
+	done := make(chan struct{})
+	defer close(done)
+	events := make(chan eventsource.Event)
+	errs := make(chan error, 1)
+	go func() {
+		decoder := eventsource.NewDecoder(stream)
+		for {
+			event, err := decoder.Decode()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-errs:
+			return err
+		case event := <-events:
+			if !isApplicationEvent(event.Event()) {
+				continue
+			}
+			log.Debug("Provider event received", event.Event())
+			p.sendMarathonConfig(configurationChan)
+		}
+	}
+}
+
+// connectEventStream opens the event stream of the first available Marathon endpoint.
+func connectEventStream(config marathon.Config) (io.ReadCloser, error) {
+	client := http.DefaultClient
+	if config.HTTPClient != nil {
+		// The stream is long-lived, no timeout must apply to it.
+		client = &http.Client{Transport: config.HTTPClient.Transport}
+	}
+
+	var errs []string
+	for _, endpoint := range getEndpoints(config.URL) {
+		request, err := http.NewRequest(http.MethodGet, endpoint+eventStreamPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		if config.HTTPBasicAuthUser != "" && config.HTTPBasicPassword != "" {
+			request.SetBasicAuth(config.HTTPBasicAuthUser, config.HTTPBasicPassword)
+		}
+		if config.DCOSToken != "" {
+			request.Header.Add("Authorization", "token="+config.DCOSToken)
+		}
+		request.Header.Set("Accept", "text/event-stream")
+
+		response, err := client.Do(request)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			errs = append(errs, fmt.Sprintf("unexpected status %d from %s", response.StatusCode, endpoint))
+			continue
+		}
+		return response.Body, nil
+	}
+	return nil, fmt.Errorf("cannot connect to the event stream of %s: %s", config.URL, strings.Join(errs, ", "))
+}
+
+// getEndpoints splits the Marathon endpoints, the ones without scheme taking the scheme of the first one.
+func getEndpoints(urls string) []string {
+	var endpoints []string
+	scheme := "http"
+	for i, endpoint := range strings.Split(urls, ",") {
+		endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+		if index := strings.Index(endpoint, "://"); index >= 0 {
+			if i == 0 {
+				scheme = endpoint[:index]
+			}
+		} else {
+			endpoint = scheme + "://" + endpoint
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+func isApplicationEvent(name string) bool {
+	event, err := marathon.GetEvent(name)
+	if err != nil {
+		return false
+	}
+	return event.ID&marathon.EventIDApplications != 0
+}
+
+func (p *Provider) getPollInterval() time.Duration {
+	if p.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return time.Duration(p.PollInterval)
+}
@@ -0,0 +1,139 @@
+package marathon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/types"
+	"github.com/gambol99/go-marathon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func createRunningApplications(name string) *marathon.Applications {
+	application := createApplication(appID(name), appPorts(80))
+	task := createLocalhostTask(taskPorts(80), state(taskStateRunning))
+	application.Tasks = []*marathon.Task{&task}
+	return &marathon.Applications{Apps: []marathon.Application{application}}
+}
+
+func receiveConfiguration(t *testing.T, configurationChan chan types.ConfigMessage) *types.Configuration {
+	select {
+	case message := <-configurationChan:
+		return message.Configuration
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no configuration received")
+		return nil
+	}
+}
+
+// stopWatch stops the watch, draining the configurations it could be sending meanwhile.
+func stopWatch(stop chan bool, stopped chan struct{}, configurationChan chan types.ConfigMessage) {
+	stop <- true
+	for {
+		select {
+		case <-configurationChan:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+func TestMarathonWatchEventStreamResync(t *testing.T) {
+	fakeClient := new(fakeClient)
+	fakeClient.On("Applications", mock.Anything).Return(createRunningApplications("before"), nil).Once()
+	fakeClient.On("Applications", mock.Anything).Return(createRunningApplications("after"), nil)
+
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		// The first stream is dropped, the application having changed before the reconnection without any event.
+		if atomic.AddInt32(&connections, 1) == 1 {
+			return
+		}
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		PollInterval:     flaeg.Duration(time.Hour),
+		marathonClient:   fakeClient,
+	}
+	configurationChan := make(chan types.ConfigMessage)
+	stop := make(chan bool, 1)
+	stopped := make(chan struct{})
+	go func() {
+		provider.watch(marathon.Config{URL: server.URL}, configurationChan, stop)
+		close(stopped)
+	}()
+
+	assert.Contains(t, receiveConfiguration(t, configurationChan).Backends, "backend-before")
+	assert.Contains(t, receiveConfiguration(t, configurationChan).Backends, "backend-after")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&connections))
+
+	stopWatch(stop, stopped, configurationChan)
+}
+
+func TestMarathonWatchPolling(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		polling             bool
+		expectedConnections bool
+	}{
+		{
+			desc:                "event stream unavailable",
+			expectedConnections: true,
+		},
+		{
+			desc:    "polling forced",
+			polling: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fakeClient := newFakeClient(false, *createRunningApplications("app"))
+
+			var connections int32
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&connections, 1)
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				Polling:          test.polling,
+				PollInterval:     flaeg.Duration(10 * time.Millisecond),
+				marathonClient:   fakeClient,
+			}
+			configurationChan := make(chan types.ConfigMessage)
+			stop := make(chan bool, 1)
+			stopped := make(chan struct{})
+			go func() {
+				provider.watch(marathon.Config{URL: server.URL}, configurationChan, stop)
+				close(stopped)
+			}()
+
+			for i := 0; i < 2; i++ {
+				assert.Contains(t, receiveConfiguration(t, configurationChan).Backends, "backend-app")
+			}
+			assert.Equal(t, test.expectedConnections, atomic.LoadInt32(&connections) > 0)
+
+			stopWatch(stop, stopped, configurationChan)
+		})
+	}
+}
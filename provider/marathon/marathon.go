@@ -49,9 +49,14 @@ type Provider struct {
 	MarathonLBCompatibility bool                `description:"Add compatibility with marathon-lb labels"`
 	TLS                     *provider.ClientTLS `description:"Enable Docker TLS support"`
 	DialerTimeout           flaeg.Duration      `description:"Set a non-default connection timeout for Marathon"`
+	ReadTimeout             flaeg.Duration      `description:"Timeout of the calls to the Marathon API, the event stream excepted"`
+	Retry                   *provider.APIRetry  `description:"Retry the failed calls to the Marathon API"`
 	KeepAlive               flaeg.Duration      `description:"Set a non-default TCP Keep Alive time in seconds"`
 	ForceTaskHostname       bool                `description:"Force to use the task's hostname."`
 	Basic                   *Basic              `description:"Enable basic authentication"`
+	Polling                 bool                `description:"Poll the Marathon applications instead of listening to the event stream"`
+	PollInterval            flaeg.Duration      `description:"Interval of the polling of the Marathon applications, also used while the event stream is unavailable"`
+	DetectHTTPSPort         bool                `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
 	marathonClient          marathon.Marathon
 }
 
@@ -70,32 +75,10 @@ type lightMarathonClient interface {
 func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
 	p.Constraints = append(p.Constraints, constraints...)
 	operation := func() error {
-		config := marathon.NewDefaultConfig()
-		config.URL = p.Endpoint
-		config.EventsTransport = marathon.EventsTransportSSE
-		if p.Trace {
-			config.LogOutput = log.CustomWriterLevel(logrus.DebugLevel, traceMaxScanTokenSize)
-		}
-		if p.Basic != nil {
-			config.HTTPBasicAuthUser = p.Basic.HTTPBasicAuthUser
-			config.HTTPBasicPassword = p.Basic.HTTPBasicPassword
-		}
-		if len(p.DCOSToken) > 0 {
-			config.DCOSToken = p.DCOSToken
-		}
-		TLSConfig, err := p.TLS.CreateTLSConfig()
+		config, err := p.createMarathonConfig()
 		if err != nil {
 			return err
 		}
-		config.HTTPClient = &http.Client{
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					KeepAlive: time.Duration(p.KeepAlive),
-					Timeout:   time.Duration(p.DialerTimeout),
-				}).DialContext,
-				TLSClientConfig: TLSConfig,
-			},
-		}
 		client, err := marathon.NewClient(config)
 		if err != nil {
 			log.Errorf("Failed to create a client for marathon, error: %s", err)
@@ -104,35 +87,11 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 		p.marathonClient = client
 
 		if p.Watch {
-			update, err := client.AddEventsListener(marathon.EventIDApplications)
-			if err != nil {
-				log.Errorf("Failed to register for events, %s", err)
-				return err
-			}
 			pool.Go(func(stop chan bool) {
-				defer close(update)
-				for {
-					select {
-					case <-stop:
-						return
-					case event := <-update:
-						log.Debug("Provider event received", event)
-						configuration := p.loadMarathonConfig()
-						if configuration != nil {
-							configurationChan <- types.ConfigMessage{
-								ProviderName:  "marathon",
-								Configuration: configuration,
-							}
-						}
-					}
-				}
+				p.watch(config, configurationChan, stop)
 			})
 		}
-		configuration := p.loadMarathonConfig()
-		configurationChan <- types.ConfigMessage{
-			ProviderName:  "marathon",
-			Configuration: configuration,
-		}
+		p.sendMarathonConfig(configurationChan)
 		return nil
 	}
 
@@ -146,6 +105,48 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
+// createMarathonConfig creates the configuration of the Marathon client.
+func (p *Provider) createMarathonConfig() (marathon.Config, error) {
+	config := marathon.NewDefaultConfig()
+	config.URL = p.Endpoint
+	if p.Trace {
+		config.LogOutput = log.CustomWriterLevel(logrus.DebugLevel, traceMaxScanTokenSize)
+	}
+	if p.Basic != nil {
+		config.HTTPBasicAuthUser = p.Basic.HTTPBasicAuthUser
+		config.HTTPBasicPassword = p.Basic.HTTPBasicPassword
+	}
+	if len(p.DCOSToken) > 0 {
+		config.DCOSToken = p.DCOSToken
+	}
+	TLSConfig, err := p.TLS.CreateTLSConfig()
+	if err != nil {
+		return config, err
+	}
+	config.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				KeepAlive: time.Duration(p.KeepAlive),
+				Timeout:   time.Duration(p.DialerTimeout),
+			}).DialContext,
+			TLSClientConfig: TLSConfig,
+		},
+		Timeout: time.Duration(p.ReadTimeout),
+	}
+	return config, nil
+}
+
+// sendMarathonConfig sends the configuration of the Marathon applications, unless they cannot be retrieved.
+func (p *Provider) sendMarathonConfig(configurationChan chan<- types.ConfigMessage) {
+	configuration := p.loadMarathonConfig()
+	if configuration != nil {
+		configurationChan <- types.ConfigMessage{
+			ProviderName:  "marathon",
+			Configuration: configuration,
+		}
+	}
+}
+
 func (p *Provider) loadMarathonConfig() *types.Configuration {
 	var MarathonFuncMap = template.FuncMap{
 		"getBackend":                  p.getBackend,
@@ -175,9 +176,14 @@ func (p *Provider) loadMarathonConfig() *types.Configuration {
 
 	v := url.Values{}
 	v.Add("embed", "apps.tasks")
-	applications, err := p.marathonClient.Applications(v)
+	var applications *marathon.Applications
+	err := p.Retry.Do("Marathon applications retrieval", func() error {
+		var err error
+		applications, err = p.marathonClient.Applications(v)
+		return err
+	})
 	if err != nil {
-		log.Errorf("Failed to retrieve Marathon applications: %s", err)
+		log.Errorf("Failed to retrieve Marathon applications, keeping the current configuration: %s", err)
 		return nil
 	}
 
@@ -218,7 +224,11 @@ func (p *Provider) applicationFilter(app marathon.Application) bool {
 			constraintTags = append(constraintTags, label)
 		}
 	}
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
+	var labels map[string]string
+	if app.Labels != nil {
+		labels = *app.Labels
+	}
+	if ok, failingConstraint := p.MatchConstraintsWithMetadata(constraintTags, labels); !ok {
 		if failingConstraint != nil {
 			log.Debugf("Filtering Marathon application %v pruned by '%v' constraint", app.ID, failingConstraint.String())
 		}
@@ -298,11 +308,11 @@ func (p *Provider) getDomain(application marathon.Application) string {
 	return p.Domain
 }
 
-func (p *Provider) getProtocol(application marathon.Application) string {
+func (p *Provider) getProtocol(task marathon.Task, application marathon.Application) string {
 	if label, ok := p.getLabel(application, types.LabelProtocol); ok {
 		return label
 	}
-	return "http"
+	return provider.GetDefaultProtocol(p.getPort(task, application), p.DetectHTTPSPort)
 }
 
 func (p *Provider) getSticky(application marathon.Application) string {
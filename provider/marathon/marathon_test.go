@@ -4,15 +4,22 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/provider/marathon/mocks"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
 	"github.com/gambol99/go-marathon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeClient struct {
@@ -42,6 +49,51 @@ func TestMarathonLoadConfigAPIErrors(t *testing.T) {
 	}
 }
 
+func TestMarathonLoadConfigAPIRetry(t *testing.T) {
+	fakeClient := new(fakeClient)
+	fakeClient.On("Applications", mock.Anything).Return(nil, errors.New("fake Marathon server error")).Twice()
+	fakeClient.On("Applications", mock.Anything).Return(&marathon.Applications{}, nil).Once()
+	p := &Provider{
+		marathonClient: fakeClient,
+		Retry:          &provider.APIRetry{Attempts: 3, Backoff: flaeg.Duration(time.Millisecond)},
+	}
+	actualConfig := p.loadMarathonConfig()
+	fakeClient.AssertExpectations(t)
+	assert.NotNil(t, actualConfig, "the configuration must be loaded once the API answers")
+}
+
+func TestMarathonLoadConfigAPITimeout(t *testing.T) {
+	var lock sync.Mutex
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v2/apps" {
+			lock.Lock()
+			calls++
+			lock.Unlock()
+			time.Sleep(500 * time.Millisecond)
+		}
+		rw.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	p := &Provider{
+		Endpoint:    ts.URL,
+		ReadTimeout: flaeg.Duration(50 * time.Millisecond),
+		Retry:       &provider.APIRetry{Attempts: 2, Backoff: flaeg.Duration(10 * time.Millisecond)},
+	}
+	config, err := p.createMarathonConfig()
+	require.NoError(t, err)
+	p.marathonClient, err = marathon.NewClient(config)
+	require.NoError(t, err)
+
+	start := time.Now()
+	assert.Nil(t, p.loadMarathonConfig(), "no configuration must be sent while the API times out")
+	assert.True(t, time.Since(start) < 400*time.Millisecond, "the API calls must time out")
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, calls >= 2, "the timed out call must be retried")
+}
+
 func TestMarathonLoadConfigNonAPIErrors(t *testing.T) {
 	cases := []struct {
 		desc              string
@@ -445,6 +497,48 @@ func TestMarathonApplicationFilterConstraints(t *testing.T) {
 	}
 }
 
+func TestMarathonApplicationFilterConstraintExpression(t *testing.T) {
+	cases := []struct {
+		desc        string
+		application marathon.Application
+		expected    bool
+	}{
+		{
+			desc:        "matching labels",
+			application: createApplication(label("environment", "prod"), label("tier", "frontend")),
+			expected:    true,
+		},
+		{
+			desc:        "excluded tier",
+			application: createApplication(label("environment", "prod"), label("tier", "batch")),
+			expected:    false,
+		},
+		{
+			desc:        "other environment with a matching tag",
+			application: createApplication(label("environment", "staging"), label(types.LabelTags, "critical")),
+			expected:    true,
+		},
+		{
+			desc:        "no labels",
+			application: createApplication(),
+			expected:    false,
+		},
+	}
+
+	constraint, err := types.NewConstraint("environment==prod && tier!=batch || tag==critical")
+	require.NoError(t, err)
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			t.Parallel()
+			provider := &Provider{ExposedByDefault: true}
+			provider.Constraints = types.Constraints{constraint}
+			assert.Equal(t, c.expected, provider.applicationFilter(c.application))
+		})
+	}
+}
+
 func TestMarathonApplicationFilterEnabled(t *testing.T) {
 	cases := []struct {
 		desc             string
@@ -660,20 +754,38 @@ func TestMarathonGetDomain(t *testing.T) {
 
 func TestMarathonGetProtocol(t *testing.T) {
 	cases := []struct {
-		desc        string
-		application marathon.Application
-		expected    string
+		desc            string
+		application     marathon.Application
+		task            marathon.Task
+		detectHTTPSPort bool
+		expected        string
 	}{
 		{
 			desc:        "label missing",
 			application: createApplication(),
+			task:        createTask(taskPorts(443)),
 			expected:    "http",
 		},
 		{
 			desc:        "label existing",
 			application: createApplication(label(types.LabelProtocol, "https")),
+			task:        createTask(taskPorts(80)),
 			expected:    "https",
 		},
+		{
+			desc:            "HTTPS port detected",
+			application:     createApplication(),
+			task:            createTask(taskPorts(443)),
+			detectHTTPSPort: true,
+			expected:        "https",
+		},
+		{
+			desc:            "label existing on HTTPS port",
+			application:     createApplication(label(types.LabelProtocol, "http")),
+			task:            createTask(taskPorts(8443)),
+			detectHTTPSPort: true,
+			expected:        "http",
+		},
 	}
 
 	for _, c := range cases {
@@ -681,7 +793,8 @@ func TestMarathonGetProtocol(t *testing.T) {
 		t.Run(c.desc, func(t *testing.T) {
 			t.Parallel()
 			provider := &Provider{}
-			actual := provider.getProtocol(c.application)
+			provider.DetectHTTPSPort = c.detectHTTPSPort
+			actual := provider.getProtocol(c.task, c.application)
 			if actual != c.expected {
 				t.Errorf("actual %q, expected %q", actual, c.expected)
 			}
@@ -38,6 +38,7 @@ type Provider struct {
 	RefreshSeconds     int    `description:"Polling interval (in seconds)"`
 	IPSources          string `description:"IPSources (e.g. host, docker, mesos, rkt)"` // e.g. "host", "docker", "mesos", "rkt"
 	StateTimeoutSecond int    `description:"HTTP Timeout (in seconds)"`
+	DetectHTTPSPort    bool   `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
 	Masters            []string
 }
 
@@ -322,7 +323,7 @@ func (p *Provider) getProtocol(task state.Task, applications []state.Task) strin
 	if label, err := p.getLabel(application, types.LabelProtocol); err == nil {
 		return label
 	}
-	return "http"
+	return provider.GetDefaultProtocol(p.getPort(task, applications), p.DetectHTTPSPort)
 }
 
 func (p *Provider) getPassHostHeader(task state.Task) string {
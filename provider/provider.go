@@ -9,9 +9,11 @@ import (
 	"os"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 
 	"github.com/BurntSushi/toml"
+	"github.com/containous/flaeg"
 	"github.com/containous/traefik/autogen"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/safe"
@@ -25,25 +27,52 @@ type Provider interface {
 	Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error
 }
 
+// Refresher is implemented by the providers able to send their configuration again on demand,
+// without waiting for their next watch event or poll.
+type Refresher interface {
+	// Refresh sends the current configuration of the provider on the configuration channel it was started with.
+	Refresh() error
+}
+
 // BaseProvider should be inherited by providers
 type BaseProvider struct {
 	Watch       bool              `description:"Watch provider"`
 	Filename    string            `description:"Override default configuration template. For advanced users :)"`
+	Template    string            `description:"Override default configuration template with an inline one. For advanced users :)"`
 	Constraints types.Constraints `description:"Filter services by constraint, matching with Traefik tags."`
 	Trace       bool              `description:"Display additional provider logs (if available)."`
+	// ThrottleDuration overrides the global providersThrottleDuration, MaxThrottleDuration being 5 times it by default.
+	ThrottleDuration    flaeg.Duration `description:"Minimum duration without new configuration from the provider before applying its last one, overriding providersThrottleDuration"`
+	MaxThrottleDuration flaeg.Duration `description:"Maximum duration a configuration of the provider waits to be applied while the provider keeps sending new ones"`
+}
+
+// Throttled is implemented by the providers overriding the throttle of their configurations.
+type Throttled interface {
+	// GetThrottleDurations returns the throttle durations of the provider, 0 when unset.
+	GetThrottleDurations() (throttle time.Duration, maxThrottle time.Duration)
+}
+
+// GetThrottleDurations returns the throttle durations of the provider, 0 when unset.
+func (p *BaseProvider) GetThrottleDurations() (time.Duration, time.Duration) {
+	return time.Duration(p.ThrottleDuration), time.Duration(p.MaxThrottleDuration)
 }
 
 // MatchConstraints must match with EVERY single contraint
 // returns first constraint that do not match or nil
 func (p *BaseProvider) MatchConstraints(tags []string) (bool, *types.Constraint) {
+	return p.MatchConstraintsWithMetadata(tags, nil)
+}
+
+// MatchConstraintsWithMetadata behaves as MatchConstraints, the constraint expressions being also
+// evaluated against the metadata of the service, such as its labels.
+func (p *BaseProvider) MatchConstraintsWithMetadata(tags []string, metadata map[string]string) (bool, *types.Constraint) {
 	// if there is no tags and no contraints, filtering is disabled
 	if len(tags) == 0 && len(p.Constraints) == 0 {
 		return true, nil
 	}
 
 	for _, constraint := range p.Constraints {
-		// xor: if ok and constraint.MustMatch are equal, then no tag is currently matching with the constraint
-		if ok := constraint.MatchConstraintWithAtLeastOneTag(tags); ok != constraint.MustMatch {
+		if !constraint.Match(tags, metadata) {
 			return false, constraint
 		}
 	}
@@ -52,7 +81,8 @@ func (p *BaseProvider) MatchConstraints(tags []string) (bool, *types.Constraint)
 	return true, nil
 }
 
-// GetConfiguration return the provider configuration using templating
+// GetConfiguration return the provider configuration using templating.
+// The default template is overridden by the inline Template, or the template read from Filename.
 func (p *BaseProvider) GetConfiguration(defaultTemplateFile string, funcMap template.FuncMap, templateObjects interface{}) (*types.Configuration, error) {
 	var (
 		buf []byte
@@ -60,11 +90,16 @@ func (p *BaseProvider) GetConfiguration(defaultTemplateFile string, funcMap temp
 	)
 	configuration := new(types.Configuration)
 	var defaultFuncMap = template.FuncMap{
-		"replace":   Replace,
-		"tolower":   strings.ToLower,
-		"normalize": Normalize,
-		"split":     split,
-		"contains":  contains,
+		"replace":    Replace,
+		"tolower":    strings.ToLower,
+		"toupper":    strings.ToUpper,
+		"normalize":  Normalize,
+		"split":      split,
+		"join":       join,
+		"contains":   contains,
+		"hasPrefix":  hasPrefix,
+		"trimPrefix": trimPrefix,
+		"default":    defaultValue,
 	}
 
 	for funcID, funcElement := range funcMap {
@@ -72,7 +107,12 @@ func (p *BaseProvider) GetConfiguration(defaultTemplateFile string, funcMap temp
 	}
 
 	tmpl := template.New(p.Filename).Funcs(defaultFuncMap)
-	if len(p.Filename) > 0 {
+	if len(p.Template) > 0 {
+		if len(p.Filename) > 0 {
+			return nil, fmt.Errorf("both an inline template and the template file %s are configured", p.Filename)
+		}
+		buf = []byte(p.Template)
+	} else if len(p.Filename) > 0 {
 		buf, err = ioutil.ReadFile(p.Filename)
 		if err != nil {
 			return nil, err
@@ -115,6 +155,26 @@ func split(sep, s string) []string {
 	return strings.Split(s, sep)
 }
 
+func join(sep string, elems []string) string {
+	return strings.Join(elems, sep)
+}
+
+func hasPrefix(prefix, s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+func trimPrefix(prefix, s string) string {
+	return strings.TrimPrefix(s, prefix)
+}
+
+// defaultValue returns the value, or defaultValue if it is empty.
+func defaultValue(defaultValue, value string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
 // Normalize transform a string that work with the rest of traefik
 func Normalize(name string) string {
 	fargs := func(c rune) bool {
@@ -192,3 +252,15 @@ func (clientTLS *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
 	}
 	return TLSConfig, nil
 }
+
+// httpsPorts are the well-known ports of the servers speaking HTTPS.
+var httpsPorts = map[string]bool{"443": true, "8443": true}
+
+// GetDefaultProtocol returns the protocol of a server on the port when no protocol is set for it:
+// https on a well-known HTTPS port when the HTTPS ports are detected, and http otherwise.
+func GetDefaultProtocol(port string, detectHTTPSPort bool) string {
+	if detectHTTPSPort && httpsPorts[port] {
+		return "https"
+	}
+	return "http"
+}
@@ -380,3 +380,78 @@ func TestDefaultFuncMap(t *testing.T) {
 		t.Fatal("Frontend frontend-1 should exists, but it not")
 	}
 }
+
+func TestGetConfigurationInlineTemplate(t *testing.T) {
+	provider := &myProvider{
+		BaseProvider{
+			Template: `[backends]
+  [backends.{{ .Name | trimPrefix "app-" | toupper }}]
+    [backends.{{ .Name | trimPrefix "app-" | toupper }}.servers.server1]
+    url = "{{ "" | default "http://127.0.0.1:80" }}"
+
+[frontends]
+  [frontends.{{ split "." .Domain | join "-" }}]
+  backend = "{{ .Name | trimPrefix "app-" | toupper }}"
+  {{ if .Name | hasPrefix "app-" }}
+  passHostHeader = true
+  {{ end }}`,
+		},
+		nil,
+	}
+	configuration, err := provider.GetConfiguration("templates/unused.tmpl", nil, struct{ Name, Domain string }{Name: "app-web", Domain: "web.localhost"})
+	if err != nil {
+		t.Fatalf("Shouldn't have error out, got %v", err)
+	}
+	backend, ok := configuration.Backends["WEB"]
+	if !ok {
+		t.Fatal("Backend WEB should exists, but it not")
+	}
+	if url := backend.Servers["server1"].URL; url != "http://127.0.0.1:80" {
+		t.Fatalf("Server URL should be http://127.0.0.1:80, got %s", url)
+	}
+	frontend, ok := configuration.Frontends["web-localhost"]
+	if !ok {
+		t.Fatal("Frontend web-localhost should exists, but it not")
+	}
+	if !frontend.PassHostHeader {
+		t.Fatal("Frontend web-localhost should pass the host header")
+	}
+}
+
+func TestGetConfigurationInlineTemplateAndFilename(t *testing.T) {
+	provider := &myProvider{
+		BaseProvider{
+			Filename: "/path/to/template.tmpl",
+			Template: "[backends]",
+		},
+		nil,
+	}
+	configuration, err := provider.GetConfiguration("templates/unused.tmpl", nil, nil)
+	if err == nil {
+		t.Fatal("Should have error out")
+	}
+	if configuration != nil {
+		t.Fatalf("shouldn't have return a configuration object : %v", configuration)
+	}
+}
+
+func TestGetDefaultProtocol(t *testing.T) {
+	cases := []struct {
+		port            string
+		detectHTTPSPort bool
+		expected        string
+	}{
+		{port: "443", expected: "http"},
+		{port: "443", detectHTTPSPort: true, expected: "https"},
+		{port: "8443", detectHTTPSPort: true, expected: "https"},
+		{port: "80", detectHTTPSPort: true, expected: "http"},
+		{port: "", detectHTTPSPort: true, expected: "http"},
+	}
+
+	for _, c := range cases {
+		actual := GetDefaultProtocol(c.port, c.detectHTTPSPort)
+		if actual != c.expected {
+			t.Errorf("port %q detected %t: expected %q, got %q", c.port, c.detectHTTPSPort, c.expected, actual)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package rancher
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -15,17 +16,24 @@ import (
 	rancher "github.com/rancher/go-rancher-metadata/metadata"
 )
 
+const defaultMetadataEndpoint = "http://rancher-metadata.rancher.internal"
+
 // MetadataConfiguration contains configuration properties specific to
 // the Rancher metadata service provider.
 type MetadataConfiguration struct {
 	IntervalPoll bool   `description:"Poll the Rancher metadata service every 'rancher.refreshseconds' (less accurate)"`
 	Prefix       string `description:"Prefix used for accessing the Rancher metadata service"`
+	Endpoint     string `description:"Rancher metadata service endpoint"`
 }
 
 func (p *Provider) metadataProvide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, constraints types.Constraints) error {
 	p.Constraints = append(p.Constraints, constraints...)
 
-	metadataServiceURL := fmt.Sprintf("http://rancher-metadata.rancher.internal/%s", p.Metadata.Prefix)
+	endpoint := p.Metadata.Endpoint
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+	metadataServiceURL := fmt.Sprintf("%s/%s", strings.TrimSuffix(endpoint, "/"), p.Metadata.Prefix)
 
 	safe.Go(func() {
 		operation := func() error {
@@ -38,14 +46,11 @@ func (p *Provider) metadataProvide(configurationChan chan<- types.ConfigMessage,
 			updateConfiguration := func(version string) {
 				log.WithField("metadata_version", version).Debugln("Refreshing configuration from Rancher metadata service")
 
-				services, err := client.GetServices()
+				configuration, err := p.loadMetadataConfig(client)
 				if err != nil {
 					log.Errorf("Failed to query Rancher metadata service: %s", err)
 					return
 				}
-
-				rancherData := parseMetadataSourcedRancherData(services)
-				configuration := p.loadRancherConfig(rancherData)
 				configurationChan <- types.ConfigMessage{
 					ProviderName:  "rancher",
 					Configuration: configuration,
@@ -85,7 +90,7 @@ func (p *Provider) intervalPoll(client rancher.Client, updateConfiguration func(
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	ticker := time.NewTicker(time.Duration(p.RefreshSeconds))
+	ticker := time.NewTicker(time.Duration(p.RefreshSeconds) * time.Second)
 	defer ticker.Stop()
 
 	var version string
@@ -118,21 +123,64 @@ func (p *Provider) longPoll(client rancher.Client, updateConfiguration func(stri
 	<-stop
 }
 
+// loadMetadataConfig builds the configuration from the services known by the
+// Rancher metadata service.
+func (p *Provider) loadMetadataConfig(client rancher.Client) (*types.Configuration, error) {
+	services, err := client.GetServices()
+	if err != nil {
+		return nil, err
+	}
+	return p.loadRancherConfig(parseMetadataSourcedRancherData(services)), nil
+}
+
 func parseMetadataSourcedRancherData(services []rancher.Service) (rancherDataList []rancherData) {
 	for _, service := range services {
-		var containerIPAddresses []string
+		containerIPAddresses := []string{}
 		for _, container := range service.Containers {
 			if containerFilter(container.Name, container.HealthState, container.State) {
 				containerIPAddresses = append(containerIPAddresses, container.PrimaryIp)
 			}
 		}
 
+		labels := service.Labels
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+
 		rancherDataList = append(rancherDataList, rancherData{
-			Name:       service.Name,
+			Name:       service.StackName + "/" + service.Name,
+			Health:     getMetadataServiceHealth(service.Containers),
 			State:      service.State,
-			Labels:     service.Labels,
+			Labels:     labels,
 			Containers: containerIPAddresses,
 		})
 	}
 	return rancherDataList
 }
+
+// getMetadataServiceHealth aggregates the health states of the containers of
+// a service, as the Rancher API does for the service health state, which the
+// metadata service does not expose.
+func getMetadataServiceHealth(containers []rancher.Container) string {
+	var healthy, unhealthy int
+	for _, container := range containers {
+		switch container.HealthState {
+		case "":
+		case "healthy", "updating-healthy":
+			healthy++
+		default:
+			unhealthy++
+		}
+	}
+
+	switch {
+	case unhealthy == 0 && healthy == 0:
+		return ""
+	case unhealthy == 0:
+		return "healthy"
+	case healthy == 0:
+		return "unhealthy"
+	default:
+		return "degraded"
+	}
+}
@@ -0,0 +1,157 @@
+package rancher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/containous/traefik/types"
+	rancher "github.com/rancher/go-rancher-metadata/metadata"
+	rancherAPI "github.com/rancher/go-rancher/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testContainer struct {
+	name        string
+	ip          string
+	healthState string
+	state       string
+}
+
+func TestRancherMetadataConfigMatchesAPIConfig(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		containers         []testContainer
+		apiHealthState     string
+		serviceState       string
+		expectedServersURL []string
+	}{
+		{
+			desc: "healthy service",
+			containers: []testContainer{
+				{name: "web-1", ip: "10.0.0.1", healthState: "healthy", state: "running"},
+				{name: "web-2", ip: "10.0.0.2", healthState: "healthy", state: "running"},
+			},
+			apiHealthState:     "healthy",
+			serviceState:       "active",
+			expectedServersURL: []string{"http://10.0.0.1:80", "http://10.0.0.2:80"},
+		},
+		{
+			desc: "service without health check",
+			containers: []testContainer{
+				{name: "web-1", ip: "10.0.0.1", state: "running"},
+			},
+			serviceState:       "active",
+			expectedServersURL: []string{"http://10.0.0.1:80"},
+		},
+		{
+			desc: "degraded service",
+			containers: []testContainer{
+				{name: "web-1", ip: "10.0.0.1", healthState: "healthy", state: "running"},
+				{name: "web-2", ip: "10.0.0.2", healthState: "unhealthy", state: "running"},
+			},
+			apiHealthState: "degraded",
+			serviceState:   "active",
+		},
+		{
+			desc: "inactive service",
+			containers: []testContainer{
+				{name: "web-1", ip: "10.0.0.1", healthState: "healthy", state: "running"},
+			},
+			apiHealthState: "healthy",
+			serviceState:   "inactive",
+		},
+	}
+
+	provider := &Provider{
+		Domain:                    "rancher.localhost",
+		ExposedByDefault:          true,
+		EnableServiceHealthFilter: true,
+	}
+	labels := map[string]string{
+		types.LabelPort: "80",
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			metadataService := rancher.Service{
+				Name:      "web",
+				StackName: "stack",
+				State:     test.serviceState,
+				Labels:    labels,
+			}
+			apiService := &rancherAPI.Service{
+				Name:          "web",
+				EnvironmentId: "1e1",
+				HealthState:   test.apiHealthState,
+				State:         test.serviceState,
+				LaunchConfig:  &rancherAPI.LaunchConfig{Labels: map[string]interface{}{types.LabelPort: "80"}},
+			}
+			var apiContainers []*rancherAPI.Container
+			for _, container := range test.containers {
+				metadataService.Containers = append(metadataService.Containers, rancher.Container{
+					Name:        container.name,
+					PrimaryIp:   container.ip,
+					HealthState: container.healthState,
+					State:       container.state,
+				})
+				apiContainers = append(apiContainers, &rancherAPI.Container{
+					Name:             container.name,
+					PrimaryIpAddress: container.ip,
+					HealthState:      container.healthState,
+					State:            container.state,
+					Labels:           map[string]interface{}{labelRancheStackServiceName: "stack/web"},
+				})
+			}
+
+			server := newMetadataServer(t, []rancher.Service{metadataService})
+			defer server.Close()
+
+			metadataConfig, err := provider.loadMetadataConfig(rancher.NewClient(server.URL))
+			require.NoError(t, err)
+
+			environments := []*rancherAPI.Project{{Resource: rancherAPI.Resource{Id: "1e1"}, Name: "stack"}}
+			apiConfig := provider.loadRancherConfig(parseAPISourcedRancherData(environments, []*rancherAPI.Service{apiService}, apiContainers))
+
+			assert.Equal(t, apiConfig, metadataConfig)
+
+			var serversURL []string
+			for _, backend := range metadataConfig.Backends {
+				for _, server := range backend.Servers {
+					serversURL = append(serversURL, server.URL)
+				}
+			}
+			sort.Strings(serversURL)
+			assert.Equal(t, test.expectedServersURL, serversURL)
+		})
+	}
+}
+
+func TestRancherLoadMetadataConfigError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	provider := &Provider{Domain: "rancher.localhost"}
+	_, err := provider.loadMetadataConfig(rancher.NewClient(server.URL))
+
+	assert.Error(t, err)
+}
+
+func newMetadataServer(t *testing.T, services []rancher.Service) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/services" {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(services); err != nil {
+			t.Errorf("failed to encode services: %s", err)
+		}
+	}))
+}
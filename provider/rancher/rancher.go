@@ -26,6 +26,7 @@ type Provider struct {
 	RefreshSeconds            int                      `description:"Polling interval (in seconds)"`
 	ExposedByDefault          bool                     `description:"Expose services by default"`
 	EnableServiceHealthFilter bool                     `description:"Filter services with unhealthy states and inactive states"`
+	DetectHTTPSPort           bool                     `description:"Default the protocol of the servers on port 443 or 8443 to https, rather than http"`
 }
 
 type rancherData struct {
@@ -138,7 +139,7 @@ func (p *Provider) getProtocol(service rancherData) string {
 	if label, err := getServiceLabel(service, types.LabelProtocol); err == nil {
 		return label
 	}
-	return "http"
+	return provider.GetDefaultProtocol(p.getPort(service), p.DetectHTTPSPort)
 }
 
 func (p *Provider) getWeight(service rancherData) string {
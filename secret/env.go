@@ -0,0 +1,18 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnvResolver resolves the env://<NAME> references to the value of the environment variable NAME.
+type EnvResolver struct{}
+
+// Resolve returns the value of the referenced environment variable.
+func (r *EnvResolver) Resolve(reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", reference)
+	}
+	return value, nil
+}
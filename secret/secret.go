@@ -0,0 +1,74 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const schemeSeparator = "://"
+
+// Resolver resolves the references to the secrets of a scheme.
+// The reference given to Resolve is the part following the scheme, e.g. "BACKEND_PASS" for "env://BACKEND_PASS".
+type Resolver interface {
+	Resolve(reference string) (string, error)
+}
+
+var (
+	resolversLock sync.RWMutex
+	resolvers     = map[string]Resolver{
+		"env":   &EnvResolver{},
+		"vault": &VaultResolver{},
+	}
+)
+
+// RegisterResolver registers the resolver of the references of a scheme, replacing any previous one.
+func RegisterResolver(scheme string, resolver Resolver) {
+	resolversLock.Lock()
+	defer resolversLock.Unlock()
+	resolvers[scheme] = resolver
+}
+
+// IsReference returns whether the value is a reference to a secret of a registered scheme.
+func IsReference(value string) bool {
+	_, _, resolver := lookup(value)
+	return resolver != nil
+}
+
+// Resolve returns the secret referenced by the value, or the value itself when it is not a secret reference.
+func Resolve(value string) (string, error) {
+	scheme, reference, resolver := lookup(value)
+	if resolver == nil {
+		return value, nil
+	}
+	secret, err := resolver.Resolve(reference)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve secret %s%s%s: %v", scheme, schemeSeparator, reference, err)
+	}
+	return secret, nil
+}
+
+// ResolveAll resolves all the values, see Resolve.
+func ResolveAll(values []string) ([]string, error) {
+	var resolved []string
+	for _, value := range values {
+		secret, err := Resolve(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, secret)
+	}
+	return resolved, nil
+}
+
+func lookup(value string) (string, string, Resolver) {
+	index := strings.Index(value, schemeSeparator)
+	if index <= 0 {
+		return "", "", nil
+	}
+	scheme := value[:index]
+
+	resolversLock.RLock()
+	defer resolversLock.RUnlock()
+	return scheme, value[index+len(schemeSeparator):], resolvers[scheme]
+}
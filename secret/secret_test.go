@@ -0,0 +1,126 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVaultClient map[string]map[string]interface{}
+
+func (c fakeVaultClient) Read(path string) (map[string]interface{}, error) {
+	data, ok := c[path]
+	if !ok {
+		return nil, errors.New("secret not found")
+	}
+	return data, nil
+}
+
+func TestResolve(t *testing.T) {
+	os.Setenv("TRAEFIK_SECRET_TEST_PASS", "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/")
+	defer os.Unsetenv("TRAEFIK_SECRET_TEST_PASS")
+
+	testCases := []struct {
+		desc          string
+		value         string
+		expected      string
+		expectedError bool
+	}{
+		{
+			desc:     "plain value",
+			value:    "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+			expected: "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+		},
+		{
+			desc:     "value with an unknown scheme",
+			value:    "http://foo.bar",
+			expected: "http://foo.bar",
+		},
+		{
+			desc:     "environment variable",
+			value:    "env://TRAEFIK_SECRET_TEST_PASS",
+			expected: "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+		},
+		{
+			desc:          "unset environment variable",
+			value:         "env://TRAEFIK_SECRET_TEST_UNSET",
+			expectedError: true,
+		},
+		{
+			desc:          "vault without client",
+			value:         "vault://secret/foo#password",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			actual, err := Resolve(test.value)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestResolveAllUnresolvedReference(t *testing.T) {
+	_, err := ResolveAll([]string{"test:test", "env://TRAEFIK_SECRET_TEST_UNSET"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "env://TRAEFIK_SECRET_TEST_UNSET")
+}
+
+func TestVaultResolver(t *testing.T) {
+	resolver := &VaultResolver{Client: fakeVaultClient{
+		"secret/foo": {"password": "bar"},
+	}}
+
+	testCases := []struct {
+		desc          string
+		reference     string
+		expected      string
+		expectedError bool
+	}{
+		{
+			desc:      "existing key",
+			reference: "secret/foo#password",
+			expected:  "bar",
+		},
+		{
+			desc:          "missing key",
+			reference:     "secret/foo#user",
+			expectedError: true,
+		},
+		{
+			desc:          "missing secret",
+			reference:     "secret/bar#password",
+			expectedError: true,
+		},
+		{
+			desc:          "reference without key",
+			reference:     "secret/foo",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := resolver.Resolve(test.reference)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VaultClient reads the secrets stored in Vault.
+type VaultClient interface {
+	Read(path string) (map[string]interface{}, error)
+}
+
+// VaultResolver resolves the vault://<path>#<key> references to the value of the key of the secret stored at path.
+// It requires a Vault client, and is meant to be registered with RegisterResolver once the client is created.
+type VaultResolver struct {
+	Client VaultClient
+}
+
+// Resolve returns the value of the referenced key of the Vault secret.
+func (r *VaultResolver) Resolve(reference string) (string, error) {
+	if r.Client == nil {
+		return "", errors.New("no Vault client configured")
+	}
+	index := strings.LastIndex(reference, "#")
+	if index < 0 || index == len(reference)-1 {
+		return "", fmt.Errorf("missing key in Vault reference %s, expected <path>#<key>", reference)
+	}
+	path, key := reference[:index], reference[index+1:]
+
+	data, err := r.Client.Read(path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in Vault secret %s", key, path)
+	}
+	return fmt.Sprint(value), nil
+}
@@ -2,8 +2,11 @@ package server
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares"
+	gokitmetrics "github.com/go-kit/kit/metrics"
 )
 
 // OxyLogger implements oxy Logger interface with logrus.
@@ -25,6 +28,65 @@ func (oxylogger *OxyLogger) Errorf(format string, args ...interface{}) {
 	log.Warningf(format, args...)
 }
 
+const tlsHandshakeErrorPrefix = "http: TLS handshake error from "
+
+// tlsHandshakeErrorReasons maps the TLS handshake error messages of crypto/tls to the reasons
+// reported in the metrics. The first matching message wins.
+var tlsHandshakeErrorReasons = []struct {
+	message string
+	reason  string
+}{
+	{message: "no certificates configured", reason: "no_certificate"},
+	{message: "no certificate available", reason: "no_certificate"},
+	{message: "client didn't provide a certificate", reason: "client_cert_required"},
+	{message: "failed to verify client's certificate", reason: "client_cert_required"},
+	{message: "unsupported versions", reason: "protocol_version"},
+	{message: "unsupported, maximum protocol version", reason: "protocol_version"},
+	{message: "protocol version not supported", reason: "protocol_version"},
+	{message: "no cipher suite supported", reason: "cipher_suite"},
+	{message: "does not look like a TLS handshake", reason: "not_tls"},
+}
+
+// httpServerErrorLogger receives the errors logged by the http.Server of an entrypoint,
+// and records the TLS handshake failures.
+type httpServerErrorLogger struct {
+	entryPointName         string
+	handshakeErrorsCounter gokitmetrics.Counter
+	logHandshakeErrors     bool
+}
+
+func (l *httpServerErrorLogger) Write(p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+	if !strings.HasPrefix(message, tlsHandshakeErrorPrefix) {
+		log.Error(message)
+		return len(p), nil
+	}
+
+	reason := getTLSHandshakeErrorReason(message)
+	if l.handshakeErrorsCounter != nil {
+		l.handshakeErrorsCounter.With("entrypoint", l.entryPointName, "reason", reason).Add(1)
+	}
+	if l.logHandshakeErrors {
+		log.Warnf("TLS handshake error on entrypoint %s (%s): %s", l.entryPointName, reason, strings.TrimPrefix(message, tlsHandshakeErrorPrefix))
+	} else {
+		log.Debugf("TLS handshake error on entrypoint %s (%s): %s", l.entryPointName, reason, strings.TrimPrefix(message, tlsHandshakeErrorPrefix))
+	}
+	return len(p), nil
+}
+
+func getTLSHandshakeErrorReason(message string) string {
+	for _, handshakeErrorReason := range tlsHandshakeErrorReasons {
+		if strings.Contains(message, handshakeErrorReason.message) {
+			return handshakeErrorReason.reason
+		}
+	}
+	return "other"
+}
+
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	if middlewares.ProblemDetailsEnabled(r) {
+		middlewares.WriteProblem(w, http.StatusNotFound, middlewares.ProblemTypeNotFound, "No frontend matches the request")
+		return
+	}
 	http.NotFound(w, r)
 }
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/tls"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/middlewares"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTLSHandshakeErrorReason(t *testing.T) {
+	testCases := []struct {
+		message        string
+		expectedReason string
+	}{
+		{
+			message:        "http: TLS handshake error from 127.0.0.1:1234: tls: no certificates configured",
+			expectedReason: "no_certificate",
+		},
+		{
+			message:        "http: TLS handshake error from 127.0.0.1:1234: tls: client didn't provide a certificate",
+			expectedReason: "client_cert_required",
+		},
+		{
+			message:        "http: TLS handshake error from 127.0.0.1:1234: tls: client offered only unsupported versions: [301]",
+			expectedReason: "protocol_version",
+		},
+		{
+			message:        "http: TLS handshake error from 127.0.0.1:1234: tls: no cipher suite supported by both client and server",
+			expectedReason: "cipher_suite",
+		},
+		{
+			message:        "http: TLS handshake error from 127.0.0.1:1234: EOF",
+			expectedReason: "other",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expectedReason, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedReason, getTLSHandshakeErrorReason(test.message))
+		})
+	}
+}
+
+func TestTLSHandshakeErrorsCounter(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		serverConfig   *tls.Config
+		clientConfig   *tls.Config
+		expectedReason string
+	}{
+		{
+			desc:           "client offering an unsupported protocol version",
+			serverConfig:   &tls.Config{MinVersion: tls.VersionTLS12},
+			clientConfig:   &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS10},
+			expectedReason: "protocol_version",
+		},
+		{
+			desc:           "client without the required certificate",
+			serverConfig:   &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert},
+			clientConfig:   &tls.Config{InsecureSkipVerify: true},
+			expectedReason: "client_cert_required",
+		},
+	}
+
+	counter, collector, err := middlewares.NewPrometheusTLSHandshakeErrorsCounter()
+	require.NoError(t, err)
+	counterVec := collector.(*prometheus.CounterVec)
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			entryPointName := "https-" + test.expectedReason
+
+			ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+			ts.TLS = test.serverConfig
+			ts.Config.ErrorLog = stdlog.New(&httpServerErrorLogger{
+				entryPointName:         entryPointName,
+				handshakeErrorsCounter: counter,
+			}, "", 0)
+			ts.StartTLS()
+			defer ts.Close()
+
+			conn, err := tls.Dial("tcp", strings.TrimPrefix(ts.URL, "https://"), test.clientConfig)
+			if err == nil {
+				// The client certificate is only verified by the server once the client sent its first data.
+				conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+				conn.Read(make([]byte, 1))
+				conn.Close()
+			}
+
+			var value float64
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				metric := &dto.Metric{}
+				require.NoError(t, counterVec.WithLabelValues(entryPointName, test.expectedReason).Write(metric))
+				if value = metric.GetCounter().GetValue(); value > 0 {
+					break
+				}
+			}
+			assert.Equal(t, float64(1), value)
+		})
+	}
+}
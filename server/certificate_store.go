@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+
+	"github.com/containous/traefik/log"
+)
+
+const (
+	// ecdsaWithSHA1 is the legacy TLS 1.2 ECDSA signature scheme with SHA1.
+	ecdsaWithSHA1 tls.SignatureScheme = 0x0203
+	// tlsVersion13 is the TLS 1.3 version offered by the clients, whose cipher suites do not depend on the certificate key.
+	tlsVersion13 = 0x0304
+)
+
+// ecdsaCipherSuites are the TLS 1.2 cipher suites authenticated by an ECDSA certificate.
+var ecdsaCipherSuites = map[uint16]bool{
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: true,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  true,
+}
+
+// certificateStore holds the certificates of a TLS configuration by name, several certificates being kept for the same
+// name, e.g. an ECDSA and an RSA one. The first certificate of the name supported by the client is served during the
+// handshake, the ECDSA certificates being preferred, and the first one of the name when the client supports none.
+// The names without certificate are resolved by the fallback, if any.
+type certificateStore struct {
+	certificates map[string][]*tls.Certificate
+	fallback     func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// newCertificateStore creates a new certificateStore of the certificates, by common name and subject alternative names,
+// parsing their leaf when it is not yet.
+func newCertificateStore(certificates []tls.Certificate, fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *certificateStore {
+	store := &certificateStore{
+		certificates: make(map[string][]*tls.Certificate),
+		fallback:     fallback,
+	}
+	for i := range certificates {
+		cert := &certificates[i]
+		if cert.Leaf == nil {
+			if len(cert.Certificate) == 0 {
+				continue
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				log.Debugf("Unable to parse certificate %d: %s", i, err)
+				continue
+			}
+			cert.Leaf = leaf
+		}
+
+		names := map[string]bool{}
+		if len(cert.Leaf.Subject.CommonName) > 0 {
+			names[strings.ToLower(cert.Leaf.Subject.CommonName)] = true
+		}
+		for _, name := range cert.Leaf.DNSNames {
+			names[strings.ToLower(name)] = true
+		}
+		for name := range names {
+			if _, ok := cert.PrivateKey.(*ecdsa.PrivateKey); ok {
+				store.certificates[name] = append([]*tls.Certificate{cert}, store.certificates[name]...)
+			} else {
+				store.certificates[name] = append(store.certificates[name], cert)
+			}
+		}
+	}
+	return store
+}
+
+// hasAlternatives checks if a name of the store has several certificates to choose from.
+func (s *certificateStore) hasAlternatives() bool {
+	for _, certificates := range s.certificates {
+		if len(certificates) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// withCertificates returns a store of the certificates with the same fallback.
+func (s *certificateStore) withCertificates(certificates []tls.Certificate) *certificateStore {
+	return newCertificateStore(certificates, s.fallback)
+}
+
+// getCertificate returns the certificate of the server name of the client, exact names being preferred to wildcards.
+func (s *certificateStore) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(clientHello.ServerName), ".")
+
+	certificates, ok := s.certificates[name]
+	if !ok {
+		if i := strings.Index(name, "."); i > 0 {
+			certificates, ok = s.certificates["*"+name[i:]]
+		}
+	}
+	if !ok {
+		if s.fallback != nil {
+			return s.fallback(clientHello)
+		}
+		return nil, nil
+	}
+
+	for _, cert := range certificates {
+		if supportsCertificate(clientHello, cert) {
+			return cert, nil
+		}
+	}
+	return certificates[0], nil
+}
+
+// supportsCertificate checks if the client supports the key of the certificate, matched by its server name already:
+// its signature schemes and cipher suites, the TLS 1.3 ones being given by the versions, and for an ECDSA key its curve.
+// The lists the client does not send are assumed to support the key.
+func supportsCertificate(clientHello *tls.ClientHelloInfo, cert *tls.Certificate) bool {
+	var ecdsaKey bool
+	switch key := cert.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		ecdsaKey = true
+		if !supportsCurve(clientHello, key.Curve) {
+			return false
+		}
+	case *rsa.PrivateKey:
+	default:
+		return true
+	}
+
+	if len(clientHello.SignatureSchemes) > 0 {
+		var supported bool
+		for _, scheme := range clientHello.SignatureSchemes {
+			if supportsSignatureScheme(scheme, ecdsaKey) {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+
+	for _, version := range clientHello.SupportedVersions {
+		if version == tlsVersion13 {
+			return true
+		}
+	}
+	if len(clientHello.CipherSuites) == 0 {
+		return true
+	}
+	for _, suite := range clientHello.CipherSuites {
+		if ecdsaCipherSuites[suite] == ecdsaKey {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsCurve checks if the curve of an ECDSA key is one of the curves supported by the client, if it sent them.
+func supportsCurve(clientHello *tls.ClientHelloInfo, curve elliptic.Curve) bool {
+	if len(clientHello.SupportedCurves) == 0 {
+		return true
+	}
+	var id tls.CurveID
+	switch curve {
+	case elliptic.P256():
+		id = tls.CurveP256
+	case elliptic.P384():
+		id = tls.CurveP384
+	case elliptic.P521():
+		id = tls.CurveP521
+	default:
+		return false
+	}
+	for _, supported := range clientHello.SupportedCurves {
+		if supported == id {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsSignatureScheme checks if the signature scheme is signed by an ECDSA key, or else by an RSA one.
+func supportsSignatureScheme(scheme tls.SignatureScheme, ecdsaKey bool) bool {
+	switch scheme {
+	case ecdsaWithSHA1, tls.ECDSAWithP256AndSHA256, tls.ECDSAWithP384AndSHA384, tls.ECDSAWithP521AndSHA512:
+		return ecdsaKey
+	case tls.PKCS1WithSHA1, tls.PKCS1WithSHA256, tls.PKCS1WithSHA384, tls.PKCS1WithSHA512,
+		tls.PSSWithSHA256, tls.PSSWithSHA384, tls.PSSWithSHA512:
+		return !ecdsaKey
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns the PEM certificate and key of a self-signed certificate of the names signed by key.
+func newTestCertificate(t *testing.T, key crypto.Signer, names ...string) (FileOrContent, FileOrContent) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	var keyPEM []byte
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	case *rsa.PrivateKey:
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	}
+	return FileOrContent(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), FileOrContent(keyPEM)
+}
+
+// handshakeCertificate returns the leaf certificate served by a TLS server with the configuration to the client.
+func handshakeCertificate(t *testing.T, config *tls.Config, clientConfig *tls.Config) *x509.Certificate {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", listener.Addr().String(), clientConfig)
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0]
+}
+
+func TestCreateTLSConfigDualCertificates(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaCert, rsaCertKey := newTestCertificate(t, rsaKey, "dual.test")
+	ecdsaCert, ecdsaCertKey := newTestCertificate(t, ecdsaKey, "dual.test")
+	otherCert, otherCertKey := newTestCertificate(t, otherKey, "*.other.test")
+	tlsOption := &TLS{
+		Certificates: Certificates{
+			{CertFile: rsaCert, KeyFile: rsaCertKey},
+			{CertFile: ecdsaCert, KeyFile: ecdsaCertKey},
+			{CertFile: otherCert, KeyFile: otherCertKey},
+		},
+	}
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"https": &EntryPoint{Address: "127.0.0.1:0", TLS: tlsOption},
+		},
+	}
+	srv := NewServer(globalConfig)
+	config, err := srv.createTLSConfig("https", tlsOption, nil)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc              string
+		clientConfig      *tls.Config
+		expectedAlgorithm x509.PublicKeyAlgorithm
+		expectedName      string
+	}{
+		{
+			desc:              "ECDSA capable client",
+			clientConfig:      &tls.Config{ServerName: "dual.test", InsecureSkipVerify: true},
+			expectedAlgorithm: x509.ECDSA,
+			expectedName:      "dual.test",
+		},
+		{
+			desc: "RSA only client",
+			clientConfig: &tls.Config{
+				ServerName:         "dual.test",
+				InsecureSkipVerify: true,
+				MaxVersion:         tls.VersionTLS12,
+				CipherSuites:       []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			},
+			expectedAlgorithm: x509.RSA,
+			expectedName:      "dual.test",
+		},
+		{
+			desc:              "wildcard certificate",
+			clientConfig:      &tls.Config{ServerName: "foo.other.test", InsecureSkipVerify: true},
+			expectedAlgorithm: x509.ECDSA,
+			expectedName:      "*.other.test",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			cert := handshakeCertificate(t, config, test.clientConfig)
+			assert.Equal(t, test.expectedAlgorithm, cert.PublicKeyAlgorithm)
+			assert.Equal(t, []string{test.expectedName}, cert.DNSNames)
+		})
+	}
+}
+
+func TestSupportsCertificate(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecdsaCert := &tls.Certificate{PrivateKey: ecdsaKey}
+	rsaCert := &tls.Certificate{PrivateKey: rsaKey}
+
+	testCases := []struct {
+		desc          string
+		clientHello   *tls.ClientHelloInfo
+		expectedECDSA bool
+		expectedRSA   bool
+	}{
+		{
+			desc:          "client sending no list",
+			clientHello:   &tls.ClientHelloInfo{},
+			expectedECDSA: true,
+			expectedRSA:   true,
+		},
+		{
+			desc: "TLS 1.2 client with both suites",
+			clientHello: &tls.ClientHelloInfo{
+				CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP384AndSHA384, tls.PKCS1WithSHA256},
+				SupportedCurves:  []tls.CurveID{tls.CurveP256, tls.CurveP384},
+			},
+			expectedECDSA: true,
+			expectedRSA:   true,
+		},
+		{
+			desc: "TLS 1.2 client with RSA suites only",
+			clientHello: &tls.ClientHelloInfo{
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			},
+			expectedRSA: true,
+		},
+		{
+			desc: "TLS 1.2 client with ECDSA suites only",
+			clientHello: &tls.ClientHelloInfo{
+				CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			},
+			expectedECDSA: true,
+		},
+		{
+			desc: "client without the curve of the key",
+			clientHello: &tls.ClientHelloInfo{
+				SupportedCurves: []tls.CurveID{tls.CurveP256},
+			},
+			expectedRSA: true,
+		},
+		{
+			desc: "client with RSA signature schemes only",
+			clientHello: &tls.ClientHelloInfo{
+				SignatureSchemes: []tls.SignatureScheme{tls.PSSWithSHA256, tls.PKCS1WithSHA256},
+			},
+			expectedRSA: true,
+		},
+		{
+			desc: "TLS 1.3 client with ECDSA signature schemes only",
+			clientHello: &tls.ClientHelloInfo{
+				CipherSuites:      []uint16{0x1301},
+				SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP384AndSHA384},
+				SupportedVersions: []uint16{tlsVersion13, tls.VersionTLS12},
+			},
+			expectedECDSA: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			assert.Equal(t, test.expectedECDSA, supportsCertificate(test.clientHello, ecdsaCert), "ECDSA certificate")
+			assert.Equal(t, test.expectedRSA, supportsCertificate(test.clientHello, rsaCert), "RSA certificate")
+		})
+	}
+}
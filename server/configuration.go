@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,17 +21,26 @@ import (
 	"github.com/containous/traefik/provider/etcd"
 	"github.com/containous/traefik/provider/eureka"
 	"github.com/containous/traefik/provider/file"
+	"github.com/containous/traefik/provider/http"
 	"github.com/containous/traefik/provider/kubernetes"
 	"github.com/containous/traefik/provider/marathon"
 	"github.com/containous/traefik/provider/mesos"
 	"github.com/containous/traefik/provider/rancher"
 	"github.com/containous/traefik/provider/zk"
+	"github.com/containous/traefik/secret"
 	"github.com/containous/traefik/types"
 )
 
 // DefaultHealthCheckInterval is the default health check interval.
 const DefaultHealthCheckInterval = 30 * time.Second
 
+// DefaultDialTimeout is the default maximum amount of time to establish a connection to a backend server.
+const DefaultDialTimeout = 30 * time.Second
+
+// DefaultDNSCacheTTL is the default time during which the resolution of a backend server hostname is cached,
+// when the TTL of its records is unknown.
+const DefaultDNSCacheTTL = 30 * time.Second
+
 // TraefikConfiguration holds GlobalConfiguration and other stuff
 type TraefikConfiguration struct {
 	GlobalConfiguration `mapstructure:",squash"`
@@ -57,8 +67,13 @@ type GlobalConfiguration struct {
 	IdleTimeout               flaeg.Duration          `description:"maximum amount of time an idle (keep-alive) connection will remain idle before closing itself."`
 	InsecureSkipVerify        bool                    `description:"Disable SSL certificate verification"`
 	RootCAs                   RootCAs                 `description:"Add cert file for self-signed certicate"`
+	TrustedProxies            types.TrustedProxies    `description:"IPs and CIDRs of the proxies allowed to set the forwarded headers. Whitelists and access logs only follow X-Forwarded-For for requests coming from these proxies"`
+	StrictRequestTarget       bool                    `description:"Disable the normalization of the Host and absolute-form URI of the requests before matching the rules of the frontends"`
 	Retry                     *Retry                  `description:"Enable retry sending request if network error"`
 	HealthCheck               *HealthCheckConfig      `description:"Health check parameters"`
+	Zone                      string                  `description:"Zone of Traefik, whose servers are preferred by the load balancers over the ones of other zones"`
+	ForwardingTimeouts        *ForwardingTimeouts     `description:"Timeouts of the requests forwarded to the backend servers"`
+	DNSCache                  *DNSCache               `description:"Enable the caching of the resolution of the backend server hostnames"`
 	Docker                    *docker.Provider        `description:"Enable Docker backend with default settings"`
 	File                      *file.Provider          `description:"Enable File backend with default settings"`
 	Web                       *WebProvider            `description:"Enable Web backend with default settings"`
@@ -74,6 +89,7 @@ type GlobalConfiguration struct {
 	ECS                       *ecs.Provider           `description:"Enable ECS backend with default settings"`
 	Rancher                   *rancher.Provider       `description:"Enable Rancher backend with default settings"`
 	DynamoDB                  *dynamodb.Provider      `description:"Enable DynamoDB backend with default settings"`
+	HTTP                      *http.Provider          `description:"Enable HTTP backend with default settings"`
 }
 
 // DefaultEntryPoints holds default entry points
@@ -125,6 +141,13 @@ func (f FileOrContent) String() string {
 }
 
 func (f FileOrContent) Read() ([]byte, error) {
+	if secret.IsReference(f.String()) {
+		content, err := secret.Resolve(f.String())
+		if err != nil {
+			return nil, err
+		}
+		return []byte(content), nil
+	}
 	var content []byte
 	if _, err := os.Stat(f.String()); err == nil {
 		content, err = ioutil.ReadFile(f.String())
@@ -189,7 +212,7 @@ func (ep *EntryPoints) String() string {
 // Set's argument is a string to be parsed to set the flag.
 // It's a comma-separated list, so we split it.
 func (ep *EntryPoints) Set(value string) error {
-	regex := regexp.MustCompile("(?:Name:(?P<Name>\\S*))\\s*(?:Address:(?P<Address>\\S*))?\\s*(?:TLS:(?P<TLS>\\S*))?\\s*((?P<TLSACME>TLS))?\\s*(?:CA:(?P<CA>\\S*))?\\s*(?:Redirect.EntryPoint:(?P<RedirectEntryPoint>\\S*))?\\s*(?:Redirect.Regex:(?P<RedirectRegex>\\S*))?\\s*(?:Redirect.Replacement:(?P<RedirectReplacement>\\S*))?\\s*(?:Compress:(?P<Compress>\\S*))?\\s*(?:WhiteListSourceRange:(?P<WhiteListSourceRange>\\S*))?")
+	regex := regexp.MustCompile("(?:Name:(?P<Name>\\S*))\\s*(?:Address:(?P<Address>\\S*))?\\s*(?:TLS:(?P<TLS>\\S*))?\\s*((?P<TLSACME>TLS))?\\s*(?:CA:(?P<CA>\\S*))?\\s*(?:Redirect.EntryPoint:(?P<RedirectEntryPoint>\\S*))?\\s*(?:Redirect.Regex:(?P<RedirectRegex>\\S*))?\\s*(?:Redirect.Replacement:(?P<RedirectReplacement>\\S*))?\\s*(?:Compress:(?P<Compress>\\S*))?\\s*(?:WhiteListSourceRange:(?P<WhiteListSourceRange>\\S*))?\\s*(?:MaxHeaderBytes:(?P<MaxHeaderBytes>\\S*))?\\s*(?:MaxConnections:(?P<MaxConnections>\\S*))?\\s*(?:DisableHTTP2:(?P<DisableHTTP2>\\S*))?\\s*(?:MaxConcurrentStreams:(?P<MaxConcurrentStreams>\\S*))?")
 	match := regex.FindAllStringSubmatch(value, -1)
 	if match == nil {
 		return fmt.Errorf("bad EntryPoints format: %s", value)
@@ -238,12 +261,52 @@ func (ep *EntryPoints) Set(value string) error {
 		whiteListSourceRange = strings.Split(result["WhiteListSourceRange"], ",")
 	}
 
+	maxHeaderBytes := 0
+	if len(result["MaxHeaderBytes"]) > 0 {
+		var err error
+		maxHeaderBytes, err = strconv.Atoi(result["MaxHeaderBytes"])
+		if err != nil {
+			return fmt.Errorf("bad MaxHeaderBytes value %q: %v", result["MaxHeaderBytes"], err)
+		}
+	}
+
+	maxConnections := 0
+	if len(result["MaxConnections"]) > 0 {
+		var err error
+		maxConnections, err = strconv.Atoi(result["MaxConnections"])
+		if err != nil {
+			return fmt.Errorf("bad MaxConnections value %q: %v", result["MaxConnections"], err)
+		}
+	}
+
+	disableHTTP2 := false
+	if len(result["DisableHTTP2"]) > 0 {
+		var err error
+		disableHTTP2, err = strconv.ParseBool(result["DisableHTTP2"])
+		if err != nil {
+			return fmt.Errorf("bad DisableHTTP2 value %q: %v", result["DisableHTTP2"], err)
+		}
+	}
+
+	var maxConcurrentStreams uint64
+	if len(result["MaxConcurrentStreams"]) > 0 {
+		var err error
+		maxConcurrentStreams, err = strconv.ParseUint(result["MaxConcurrentStreams"], 10, 32)
+		if err != nil {
+			return fmt.Errorf("bad MaxConcurrentStreams value %q: %v", result["MaxConcurrentStreams"], err)
+		}
+	}
+
 	(*ep)[result["Name"]] = &EntryPoint{
 		Address:              result["Address"],
 		TLS:                  tls,
 		Redirect:             redirect,
 		Compress:             compress,
 		WhitelistSourceRange: whiteListSourceRange,
+		MaxHeaderBytes:       maxHeaderBytes,
+		MaxConnections:       maxConnections,
+		DisableHTTP2:         disableHTTP2,
+		MaxConcurrentStreams: uint32(maxConcurrentStreams),
 	}
 
 	return nil
@@ -273,10 +336,32 @@ type EntryPoint struct {
 	Auth                 *types.Auth
 	WhitelistSourceRange []string
 	Compress             bool
+	CompressionLevel     int
+	CompressTypes        []string
+	MaxHeaderBytes       int
+	MaxConnections       int
+	Chain                *types.Chain
+	DisableHTTP2         bool
+	MaxConcurrentStreams uint32
+	ProblemDetails       bool
+	TrustForwardedProto  bool
+	StaticFiles          map[string]FileOrContent
+	MaxRequestBodyBytes  int64
+}
+
+// Redirect configures a redirection of an entry point to another, or to an URL.
+// The rules redirect the requests under their path prefix elsewhere, the first matching one being applied,
+// and the requests matching no rule are redirected by the entry point or the regex of the redirect, if any.
+type Redirect struct {
+	EntryPoint  string
+	Regex       string
+	Replacement string
+	Rules       []RedirectRule
 }
 
-// Redirect configures a redirection of an entry point to another, or to an URL
-type Redirect struct {
+// RedirectRule configures the redirection of the requests under a path prefix, to an entry point or to an URL
+type RedirectRule struct {
+	PathPrefix  string
 	EntryPoint  string
 	Regex       string
 	Replacement string
@@ -284,10 +369,19 @@ type Redirect struct {
 
 // TLS configures TLS for an entry point
 type TLS struct {
-	MinVersion    string
-	CipherSuites  []string
-	Certificates  Certificates
-	ClientCAFiles []string
+	MinVersion         string
+	CipherSuites       []string
+	Certificates       Certificates
+	ClientCAFiles      []string
+	LogHandshakeErrors bool
+	OCSPStapling       bool
+	SessionTickets     *SessionTickets
+}
+
+// SessionTickets contains the configuration of the keys encrypting the TLS session tickets of an entrypoint.
+type SessionTickets struct {
+	KeysFile         string         `description:"File of the keys shared by several instances, one base64 encoded 32 bytes key per line, the first one encrypting the new tickets. If empty, the keys are generated"`
+	RotationInterval flaeg.Duration `description:"Interval between two rotations of the generated keys, or two reloads of the keys file"`
 }
 
 // Map of allowed TLS minimum versions
@@ -403,7 +497,10 @@ type Certificate struct {
 
 // Retry contains request retry config
 type Retry struct {
-	Attempts int `description:"Number of attempts"`
+	Attempts           int            `description:"Number of attempts"`
+	Backoff            string         `description:"Backoff between the attempts: constant or exponential"`
+	BackoffInterval    flaeg.Duration `description:"Delay before a retry, doubled after each one with the exponential backoff. If zero, the requests are retried immediately"`
+	MaxBackoffInterval flaeg.Duration `description:"Maximum delay before a retry with the exponential backoff. If zero, no maximum exists"`
 }
 
 // HealthCheckConfig contains health check configuration parameters.
@@ -411,6 +508,20 @@ type HealthCheckConfig struct {
 	Interval flaeg.Duration `description:"Default periodicity of enabled health checks"`
 }
 
+// ForwardingTimeouts contains the timeouts of the requests forwarded to the backend servers.
+type ForwardingTimeouts struct {
+	DialTimeout           flaeg.Duration `description:"Maximum amount of time to establish a connection to a backend server. If zero, no timeout exists"`
+	ResponseHeaderTimeout flaeg.Duration `description:"Maximum amount of time to wait for the response headers of a backend server, once the request is written. If zero, no timeout exists"`
+	ForwardingTimeout     flaeg.Duration `description:"Maximum amount of time of a whole forwarded request, response body included. If zero, no timeout exists"`
+}
+
+// DNSCache contains the configuration of the cache of the resolution of the backend server hostnames.
+type DNSCache struct {
+	Nameserver string         `description:"Nameserver (host:port) queried for the records of the hostnames, whose TTL is honored. If empty, the system resolver is used"`
+	DefaultTTL flaeg.Duration `description:"Time during which a resolution is cached when the TTL of its records is unknown, as with the system resolver"`
+	MaxTTL     flaeg.Duration `description:"Maximum time during which a resolution is cached, whatever the TTL of its records. If zero, no maximum exists"`
+}
+
 // NewTraefikDefaultPointersConfiguration creates a TraefikConfiguration with pointers default values
 func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	//default Docker
@@ -419,6 +530,7 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultDocker.ExposedByDefault = true
 	defaultDocker.Endpoint = "unix:///var/run/docker.sock"
 	defaultDocker.SwarmMode = false
+	defaultDocker.Prefix = "traefik"
 
 	// default File
 	var defaultFile file.Provider
@@ -455,6 +567,7 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultMarathon.Constraints = types.Constraints{}
 	defaultMarathon.DialerTimeout = flaeg.Duration(60 * time.Second)
 	defaultMarathon.KeepAlive = flaeg.Duration(10 * time.Second)
+	defaultMarathon.PollInterval = flaeg.Duration(15 * time.Second)
 
 	// default Consul
 	var defaultConsul consul.Provider
@@ -529,6 +642,12 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultDynamoDB.TableName = "traefik"
 	defaultDynamoDB.Watch = true
 
+	// default HTTP
+	var defaultHTTP http.Provider
+	defaultHTTP.Watch = true
+	defaultHTTP.PollInterval = flaeg.Duration(30 * time.Second)
+	defaultHTTP.PollTimeout = flaeg.Duration(10 * time.Second)
+
 	// default AccessLog
 	defaultAccessLog := types.AccessLog{
 		Format:   accesslog.CommonFormat,
@@ -550,9 +669,16 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 		ECS:           &defaultECS,
 		Rancher:       &defaultRancher,
 		DynamoDB:      &defaultDynamoDB,
+		HTTP:          &defaultHTTP,
 		Retry:         &Retry{},
 		HealthCheck:   &HealthCheckConfig{},
-		AccessLog:     &defaultAccessLog,
+		ForwardingTimeouts: &ForwardingTimeouts{
+			DialTimeout: flaeg.Duration(DefaultDialTimeout),
+		},
+		DNSCache: &DNSCache{
+			DefaultTTL: flaeg.Duration(DefaultDNSCacheTTL),
+		},
+		AccessLog: &defaultAccessLog,
 	}
 
 	return &TraefikConfiguration{
@@ -577,6 +703,9 @@ func NewTraefikConfiguration() *TraefikConfiguration {
 			HealthCheck: &HealthCheckConfig{
 				Interval: flaeg.Duration(DefaultHealthCheckInterval),
 			},
+			ForwardingTimeouts: &ForwardingTimeouts{
+				DialTimeout: flaeg.Duration(DefaultDialTimeout),
+			},
 			CheckNewVersion: true,
 		},
 		ConfigFile: "",
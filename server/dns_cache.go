@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/miekg/dns"
+)
+
+// unknownTTL is the TTL of the resolutions of a resolver not knowing the TTL of the records.
+const unknownTTL = time.Duration(-1)
+
+// hostResolver resolves the addresses of a host, along with the time they can be cached for.
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// systemResolver resolves the hosts with the resolver of the system, which does not expose the TTL of the records.
+type systemResolver struct{}
+
+func (systemResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addrs, unknownTTL, err
+}
+
+// nameserverResolver resolves the hosts by querying the A and AAAA records of a nameserver,
+// the resolutions being cached for the lowest TTL of the records.
+type nameserverResolver struct {
+	nameserver string
+	client     *dns.Client
+}
+
+func newNameserverResolver(nameserver string, timeout time.Duration) *nameserverResolver {
+	return &nameserverResolver{
+		nameserver: nameserver,
+		client:     &dns.Client{Timeout: timeout},
+	}
+}
+
+func (r *nameserverResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	var addrs []string
+	ttl := unknownTTL
+	var lastErr error
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(host), qtype)
+		response, _, err := r.client.Exchange(msg, r.nameserver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if response.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("lookup %s on %s: %s", host, r.nameserver, dns.RcodeToString[response.Rcode])
+			continue
+		}
+		for _, answer := range response.Answer {
+			recordTTL := time.Duration(answer.Header().Ttl) * time.Second
+			if ttl == unknownTTL || recordTTL < ttl {
+				ttl = recordTTL
+			}
+			switch record := answer.(type) {
+			case *dns.A:
+				addrs = append(addrs, record.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, record.AAAA.String())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("lookup %s on %s: no such host", host, r.nameserver)
+		}
+		return nil, 0, lastErr
+	}
+	return addrs, ttl, nil
+}
+
+type dnsCacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache caches the resolutions of the backend server hostnames for the TTL of their records, capped by maxTTL,
+// or for defaultTTL when it is unknown.
+type dnsCache struct {
+	resolver   hostResolver
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	now        func() time.Time
+	lock       sync.Mutex
+	entries    map[string]dnsCacheEntry
+}
+
+func newDNSCache(config *DNSCache) *dnsCache {
+	var resolver hostResolver = systemResolver{}
+	if len(config.Nameserver) > 0 {
+		resolver = newNameserverResolver(config.Nameserver, DefaultDialTimeout)
+	}
+	defaultTTL := time.Duration(config.DefaultTTL)
+	if defaultTTL <= 0 {
+		defaultTTL = DefaultDNSCacheTTL
+	}
+	return &dnsCache{
+		resolver:   resolver,
+		defaultTTL: defaultTTL,
+		maxTTL:     time.Duration(config.MaxTTL),
+		now:        time.Now,
+		entries:    make(map[string]dnsCacheEntry),
+	}
+}
+
+// lookupHost returns the cached addresses of the host, resolving them once expired.
+func (c *dnsCache) lookupHost(ctx context.Context, host string) ([]string, error) {
+	c.lock.Lock()
+	entry, ok := c.entries[host]
+	c.lock.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, ttl, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if ttl == unknownTTL {
+		ttl = c.defaultTTL
+	}
+	if c.maxTTL > 0 && ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	log.Debugf("Resolved backend host %s to %v, cached for %s", host, addrs, ttl)
+
+	c.lock.Lock()
+	c.entries[host] = dnsCacheEntry{addrs: addrs, expires: c.now().Add(ttl)}
+	c.lock.Unlock()
+	return addrs, nil
+}
+
+// dialContext returns a dial function connecting to the cached addresses of the hosts with dialer,
+// trying them in turn. The IP addresses are dialed as is.
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := c.lookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
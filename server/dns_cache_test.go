@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver resolves all the hosts to addrs with ttl, counting its lookups.
+type fakeResolver struct {
+	addrs   []string
+	ttl     time.Duration
+	lookups int
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	r.lookups++
+	return r.addrs, r.ttl, nil
+}
+
+func TestDNSCacheLookupHost(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		recordTTL   time.Duration
+		maxTTL      time.Duration
+		expectedTTL time.Duration
+	}{
+		{
+			desc:        "unknown record TTL",
+			recordTTL:   unknownTTL,
+			expectedTTL: DefaultDNSCacheTTL,
+		},
+		{
+			desc:        "short record TTL",
+			recordTTL:   2 * time.Second,
+			expectedTTL: 2 * time.Second,
+		},
+		{
+			desc:        "record TTL over the maximum",
+			recordTTL:   time.Hour,
+			maxTTL:      time.Minute,
+			expectedTTL: time.Minute,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			resolver := &fakeResolver{addrs: []string{"10.0.0.1"}, ttl: test.recordTTL}
+			now := time.Now()
+			cache := newDNSCache(&DNSCache{})
+			cache.resolver = resolver
+			cache.maxTTL = test.maxTTL
+			cache.now = func() time.Time { return now }
+
+			lookup := func() {
+				addrs, err := cache.lookupHost(context.Background(), "backend.test")
+				require.NoError(t, err)
+				assert.Equal(t, []string{"10.0.0.1"}, addrs)
+			}
+
+			lookup()
+			assert.Equal(t, 1, resolver.lookups)
+
+			now = now.Add(test.expectedTTL - time.Millisecond)
+			lookup()
+			assert.Equal(t, 1, resolver.lookups, "cache hit within the TTL")
+
+			now = now.Add(time.Millisecond)
+			lookup()
+			assert.Equal(t, 2, resolver.lookups, "refresh after expiry")
+		})
+	}
+}
+
+func TestDNSCacheDial(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(backendURL.Host)
+	require.NoError(t, err)
+
+	resolver := &fakeResolver{addrs: []string{"127.0.0.2", "127.0.0.1"}, ttl: time.Minute}
+	cache := newDNSCache(&DNSCache{})
+	cache.resolver = resolver
+
+	transport := createHTTPTransport(nil, ForwardingTimeouts{DialTimeout: flaeg.Duration(time.Second)}, cache, false)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get("http://backend.test:" + port)
+		require.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		// Do not reuse the connection, the host must be dialed again.
+		transport.(*http.Transport).CloseIdleConnections()
+	}
+	assert.Equal(t, 1, resolver.lookups)
+}
@@ -23,6 +23,12 @@ func NewRecordingErrorHandler(recorder middlewares.NetErrorRecorder) *RecordingE
 func (eh *RecordingErrorHandler) ServeHTTP(w http.ResponseWriter, req *http.Request, err error) {
 	statusCode := http.StatusInternalServerError
 
+	// The body of the request exceeded the limit of its frontend while being forwarded.
+	if maxBytes, ok := middlewares.BodyLimitExceeded(req); ok {
+		middlewares.WriteRequestTooLarge(w, req, maxBytes)
+		return
+	}
+
 	if e, ok := err.(net.Error); ok {
 		eh.netErrorRecorder.Record(req.Context())
 		if e.Timeout() {
@@ -35,6 +41,17 @@ func (eh *RecordingErrorHandler) ServeHTTP(w http.ResponseWriter, req *http.Requ
 		statusCode = http.StatusBadGateway
 	}
 
+	if middlewares.ProblemDetailsEnabled(req) {
+		switch statusCode {
+		case http.StatusGatewayTimeout:
+			middlewares.WriteProblem(w, statusCode, middlewares.ProblemTypeGatewayTimeout, "The backend server did not answer in time")
+		case http.StatusBadGateway:
+			middlewares.WriteProblem(w, statusCode, middlewares.ProblemTypeBadGateway, "The backend server could not be reached")
+		default:
+			middlewares.WriteProblem(w, statusCode, middlewares.ProblemTypeInternalError, "")
+		}
+		return
+	}
 	w.WriteHeader(statusCode)
 	w.Write([]byte(http.StatusText(statusCode)))
 }
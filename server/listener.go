@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares"
+	gokitmetrics "github.com/go-kit/kit/metrics"
+	"github.com/urfave/negroni"
+)
+
+const (
+	// maxRawHeaderLineLength bounds the lines buffered to capture the original header names, the scan of the
+	// connection stopping on longer ones.
+	maxRawHeaderLineLength = 64 * 1024
+	// maxPendingRawHeaderNames bounds the requests read ahead on a connection whose header names are kept.
+	maxPendingRawHeaderNames = 16
+	// connLimitWait is how long a connection accepted while an entrypoint has its maximum amount of connections open
+	// waits for another one to be closed, before being rejected.
+	connLimitWait = 100 * time.Millisecond
+)
+
+// headerTooLargeResponse is how net/http answers requests with headers exceeding http.Server.MaxHeaderBytes.
+var headerTooLargeResponse = []byte("HTTP/1.1 " + strconv.Itoa(http.StatusRequestHeaderFieldsTooLarge) + " ")
+
+// tcpKeepAliveListener sets the TCP keep-alive timeouts on the accepted connections, as net/http does for
+// http.Server.ListenAndServe and ListenAndServeTLS, for the dead TCP connections to go away eventually.
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+}
+
+func (l tcpKeepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetKeepAlive(true)
+	conn.SetKeepAlivePeriod(3 * time.Minute)
+	return conn, nil
+}
+
+// serve serves the entrypoint on the listener, see entryPointListener.
+// The original names of the request headers are captured while preserveHeaderCase, if not nil, is set.
+func serve(srv *http.Server, listener net.Listener, preserveHeaderCase *int32) error {
+	return srv.Serve(&entryPointListener{Listener: listener, addr: srv.Addr, preserveHeaderCase: preserveHeaderCase})
+}
+
+// entryPointListener wraps the connections of the entrypoints without TLS, to:
+// - log the requests rejected by net/http because their headers exceed the maximum header size,
+// - capture the original names of the request headers, which net/http canonicalizes, on the connections accepted
+// while a backend of the entrypoint preserves the case of the header names.
+type entryPointListener struct {
+	net.Listener
+	addr string
+	// preserveHeaderCase, accessed atomically, is 1 while a backend of the entrypoint preserves the case of the header names.
+	preserveHeaderCase *int32
+}
+
+func (l *entryPointListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	entryPointConn := &entryPointConn{Conn: conn, addr: l.addr}
+	if l.preserveHeaderCase != nil && atomic.LoadInt32(l.preserveHeaderCase) == 1 {
+		entryPointConn.rawHeaders = &rawHeaderScanner{}
+	}
+	return entryPointConn, nil
+}
+
+type entryPointConn struct {
+	net.Conn
+	addr string
+	// rawHeaders, if any, captures the original names of the request headers.
+	rawHeaders *rawHeaderScanner
+}
+
+// Write logs the rejections of the requests whose headers exceed the maximum header size, net/http writing them
+// at once to the connection, whether they are the first requests of the connection or follow kept-alive ones.
+func (c *entryPointConn) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, headerTooLargeResponse) {
+		log.Warnf("Rejected request from %s on %s: request headers exceed the maximum header size", c.RemoteAddr(), c.addr)
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *entryPointConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.rawHeaders != nil {
+		c.rawHeaders.scan(p[:n])
+	}
+	return n, err
+}
+
+// LocalAddr returns the local address of the connection, which net/http gives to the handlers of its requests
+// as the http.LocalAddrContextKey value of their context: the handlers retrieve the header names captured
+// on the connection through it.
+func (c *entryPointConn) LocalAddr() net.Addr {
+	if c.rawHeaders == nil {
+		return c.Conn.LocalAddr()
+	}
+	return &rawHeaderNamesAddr{Addr: c.Conn.LocalAddr(), rawHeaders: c.rawHeaders}
+}
+
+// rawHeaderNamesAddr is the local address of a connection capturing the original names of the request headers.
+type rawHeaderNamesAddr struct {
+	net.Addr
+	rawHeaders *rawHeaderScanner
+}
+
+type rawHeaderScanState int
+
+const (
+	scanRequestLine rawHeaderScanState = iota
+	scanHeaders
+	scanBody
+	scanChunkSize
+	scanChunkData
+	scanTrailer
+	scanStopped
+)
+
+// rawHeaderBlock holds the original header names of a request read from a connection, indexed by their canonical form.
+// Only the names which are not in canonical form are kept.
+type rawHeaderBlock struct {
+	method        string
+	requestURI    string
+	names         map[string]string
+	contentLength int64
+	chunked       bool
+	upgrade       bool
+}
+
+// rawHeaderScanner captures the original header names of the requests read from a connection.
+// It follows the requests as net/http reads them, skipping their bodies by their Content-Length or their chunks,
+// and stops at the first request it can't follow, e.g. an upgraded or a malformed one, after which net/http hands
+// the connection over or closes it.
+type rawHeaderScanner struct {
+	lock  sync.Mutex
+	state rawHeaderScanState
+	// line buffers the line being read.
+	line []byte
+	// remaining is the amount of bytes left in the body or the chunk being read.
+	remaining int64
+	current   rawHeaderBlock
+	pending   []rawHeaderBlock
+}
+
+func (s *rawHeaderScanner) scan(p []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for len(p) > 0 && s.state != scanStopped {
+		if s.state == scanBody || s.state == scanChunkData {
+			skipped := int64(len(p))
+			if skipped > s.remaining {
+				skipped = s.remaining
+			}
+			s.remaining -= skipped
+			p = p[skipped:]
+			if s.remaining == 0 {
+				if s.state == scanBody {
+					s.state = scanRequestLine
+				} else {
+					s.state = scanChunkSize
+				}
+			}
+			continue
+		}
+
+		index := bytes.IndexByte(p, '\n')
+		if index < 0 {
+			index = len(p)
+		}
+		if len(s.line)+index > maxRawHeaderLineLength {
+			s.state = scanStopped
+			return
+		}
+		s.line = append(s.line, p[:index]...)
+		if index == len(p) {
+			return
+		}
+		s.scanLine(string(bytes.TrimSuffix(s.line, []byte("\r"))))
+		s.line = s.line[:0]
+		p = p[index+1:]
+	}
+}
+
+func (s *rawHeaderScanner) scanLine(line string) {
+	switch s.state {
+	case scanRequestLine:
+		if len(line) == 0 {
+			return
+		}
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) != 3 || !strings.HasPrefix(parts[2], "HTTP/") {
+			s.state = scanStopped
+			return
+		}
+		s.current = rawHeaderBlock{method: parts[0], requestURI: parts[1], upgrade: parts[0] == http.MethodConnect}
+		s.state = scanHeaders
+	case scanHeaders:
+		if len(line) == 0 {
+			s.endHeaders()
+			return
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// continuation of the previous header value
+			return
+		}
+		index := strings.IndexByte(line, ':')
+		if index <= 0 {
+			s.state = scanStopped
+			return
+		}
+		name := line[:index]
+		canonicalName := textproto.CanonicalMIMEHeaderKey(name)
+		if canonicalName != name {
+			if s.current.names == nil {
+				s.current.names = make(map[string]string)
+			}
+			s.current.names[canonicalName] = name
+		}
+		value := strings.TrimSpace(line[index+1:])
+		switch canonicalName {
+		case "Content-Length":
+			contentLength, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || contentLength < 0 {
+				s.state = scanStopped
+				return
+			}
+			s.current.contentLength = contentLength
+		case "Transfer-Encoding":
+			s.current.chunked = s.current.chunked || strings.Contains(strings.ToLower(value), "chunked")
+		case "Upgrade":
+			s.current.upgrade = true
+		}
+	case scanChunkSize:
+		if index := strings.IndexByte(line, ';'); index >= 0 {
+			line = line[:index]
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+		if err != nil || size < 0 {
+			s.state = scanStopped
+			return
+		}
+		if size == 0 {
+			s.state = scanTrailer
+			return
+		}
+		// the chunk data is followed by CRLF
+		s.remaining = size + 2
+		s.state = scanChunkData
+	case scanTrailer:
+		if len(line) == 0 {
+			s.state = scanRequestLine
+		}
+	}
+}
+
+// endHeaders records the header names of the request whose headers have been read, and skips to its body.
+func (s *rawHeaderScanner) endHeaders() {
+	if len(s.pending) < maxPendingRawHeaderNames {
+		s.pending = append(s.pending, s.current)
+	}
+	switch {
+	case s.current.upgrade:
+		s.state = scanStopped
+	case s.current.chunked:
+		s.state = scanChunkSize
+	case s.current.contentLength > 0:
+		s.remaining = s.current.contentLength
+		s.state = scanBody
+	default:
+		s.state = scanRequestLine
+	}
+}
+
+// next returns the original header names of the request, from the first pending block with its request line.
+// The blocks read before it are dropped: their requests were answered by net/http without reaching the handlers.
+func (s *rawHeaderScanner) next(req *http.Request) map[string]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, block := range s.pending {
+		if block.method == req.Method && block.requestURI == req.RequestURI {
+			s.pending = s.pending[i+1:]
+			return block.names
+		}
+	}
+	return nil
+}
+
+// rawHeaderNamesHandler attaches the original names of the request headers to the requests
+// received by an entryPointConn capturing them.
+var rawHeaderNamesHandler = negroni.HandlerFunc(func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(*rawHeaderNamesAddr); ok {
+		if headerNames := addr.rawHeaders.next(r); len(headerNames) > 0 {
+			r = middlewares.WithRawHeaderNames(r, headerNames)
+		}
+	}
+	next(rw, r)
+})
+
+// connLimitListener bounds the amount of connections open at once on an entrypoint, and tracks it in a gauge.
+// The connections accepted over the limit are closed, unless another one is closed within connLimitWait.
+type connLimitListener struct {
+	net.Listener
+	entryPointName string
+	slots          chan struct{}
+	wait           time.Duration
+	openConns      gokitmetrics.Gauge
+
+	lock  sync.Mutex
+	count int
+}
+
+// newConnLimitListener wraps listener to allow at most maxConns connections open at once, if positive,
+// and to count them in openConns, if not nil.
+func newConnLimitListener(listener net.Listener, entryPointName string, maxConns int, openConns gokitmetrics.Gauge) net.Listener {
+	if maxConns <= 0 && openConns == nil {
+		return listener
+	}
+	l := &connLimitListener{
+		Listener:       listener,
+		entryPointName: entryPointName,
+		wait:           connLimitWait,
+	}
+	if maxConns > 0 {
+		l.slots = make(chan struct{}, maxConns)
+	}
+	if openConns != nil {
+		l.openConns = openConns.With("entrypoint", entryPointName)
+	}
+	return l
+}
+
+func (l *connLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.acquire() {
+			l.updateOpenConns(1)
+			return &limitedConn{Conn: conn, listener: l}, nil
+		}
+		log.Warnf("Rejected connection from %s on entrypoint %s: too many open connections", conn.RemoteAddr(), l.entryPointName)
+		conn.Close()
+	}
+}
+
+// acquire reserves a connection slot, waiting for one to be released for at most the wait of the listener.
+func (l *connLimitListener) acquire() bool {
+	if l.slots == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *connLimitListener) release() {
+	l.updateOpenConns(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *connLimitListener) updateOpenConns(delta int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.count += delta
+	if l.openConns != nil {
+		l.openConns.Set(float64(l.count))
+	}
+}
+
+// limitedConn releases its slot of the connLimitListener once closed.
+type limitedConn struct {
+	net.Conn
+	listener *connLimitListener
+	once     sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.listener.release)
+	return err
+}
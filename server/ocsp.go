@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspRetryInterval is the delay before fetching again an OCSP response which could not be fetched.
+	ocspRetryInterval = 5 * time.Minute
+	// ocspDefaultRefresh is the delay before refreshing an OCSP response without next update.
+	ocspDefaultRefresh = time.Hour
+	// ocspMinRefresh is the minimum delay before refreshing an OCSP response.
+	ocspMinRefresh = time.Minute
+	// ocspTimeout bounds the requests to the OCSP responders.
+	ocspTimeout = 10 * time.Second
+	// ocspMaxResponseSize is the maximum size of the responses read from the OCSP responders.
+	ocspMaxResponseSize = 1 << 20
+)
+
+var ocspStatusNames = map[int]string{
+	ocsp.Good:         "good",
+	ocsp.Revoked:      "revoked",
+	ocsp.Unknown:      "unknown",
+	ocsp.ServerFailed: "server failed",
+}
+
+// ocspStaple is the OCSP response stapled to a certificate, with the leaf and issuer certificates to fetch it.
+type ocspStaple struct {
+	leaf       *x509.Certificate
+	issuer     *x509.Certificate
+	response   []byte
+	nextUpdate time.Time
+	refresh    time.Time
+}
+
+// ocspStapler staples the OCSP responses of the certificates of a TLS configuration, fetched from the OCSP responder
+// of each certificate, with its issuer certificate taken from its chain. The responses are refreshed halfway through
+// their validity, and a certificate whose response cannot be fetched is served without staple once the previous one expired.
+// The configuration with the stapled certificates is returned to the clients by getConfigForClient.
+type ocspStapler struct {
+	base   *tls.Config
+	store  *certificateStore
+	client *http.Client
+	now    func() time.Time
+	// staples are the staples of the certificates of base, by index, nil for the certificates without OCSP responder.
+	staples []*ocspStaple
+	config  atomic.Value
+}
+
+func newOCSPStapler(config *tls.Config, store *certificateStore) *ocspStapler {
+	stapler := &ocspStapler{
+		base:    config,
+		store:   store,
+		client:  &http.Client{Timeout: ocspTimeout},
+		now:     time.Now,
+		staples: make([]*ocspStaple, len(config.Certificates)),
+	}
+	for i := range config.Certificates {
+		leaf, issuer, err := ocspCertificates(&config.Certificates[i])
+		if err != nil {
+			log.Debugf("No OCSP stapling for certificate %d: %s", i, err)
+			continue
+		}
+		stapler.staples[i] = &ocspStaple{leaf: leaf, issuer: issuer}
+	}
+	stapler.publish()
+	return stapler
+}
+
+// ocspCertificates returns the leaf certificate of a certificate with an OCSP responder, and its issuer certificate.
+func ocspCertificates(cert *tls.Certificate) (*x509.Certificate, *x509.Certificate, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, errors.New("empty certificate")
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil, fmt.Errorf("no OCSP responder for %s", leaf.Subject.CommonName)
+	}
+	if len(cert.Certificate) < 2 {
+		return nil, nil, fmt.Errorf("no issuer certificate in the chain of %s", leaf.Subject.CommonName)
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return leaf, issuer, nil
+}
+
+// getConfigForClient returns the configuration with the stapled certificates.
+func (s *ocspStapler) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return s.config.Load().(*tls.Config), nil
+}
+
+// run refreshes the OCSP responses until stop is closed.
+func (s *ocspStapler) run(stop chan bool) {
+	for {
+		next := s.refresh()
+		if next.IsZero() {
+			<-stop
+			return
+		}
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh fetches the OCSP responses which are due, drops the expired ones, and returns the time of the next refresh,
+// zero if none.
+func (s *ocspStapler) refresh() time.Time {
+	now := s.now()
+	changed := false
+	var next time.Time
+	for _, staple := range s.staples {
+		if staple == nil {
+			continue
+		}
+		if !now.Before(staple.refresh) {
+			response, parsed, err := s.fetch(staple)
+			if err != nil {
+				log.Warnf("Error fetching the OCSP response of certificate %s: %s", staple.leaf.Subject.CommonName, err)
+				staple.refresh = now.Add(ocspRetryInterval)
+			} else {
+				log.Debugf("Fetched the OCSP response of certificate %s, valid until %s", staple.leaf.Subject.CommonName, parsed.NextUpdate)
+				staple.response = response
+				staple.nextUpdate = parsed.NextUpdate
+				staple.refresh = ocspRefreshTime(parsed, now)
+				changed = true
+			}
+		}
+		if staple.response != nil && !staple.nextUpdate.IsZero() {
+			if !now.Before(staple.nextUpdate) {
+				log.Warnf("OCSP response of certificate %s expired, serving it without staple", staple.leaf.Subject.CommonName)
+				staple.response = nil
+				changed = true
+			} else if staple.nextUpdate.Before(staple.refresh) {
+				staple.refresh = staple.nextUpdate
+			}
+		}
+		if next.IsZero() || staple.refresh.Before(next) {
+			next = staple.refresh
+		}
+	}
+	if changed {
+		s.publish()
+	}
+	return next
+}
+
+// ocspRefreshTime returns the time halfway through the validity of the response.
+func ocspRefreshTime(response *ocsp.Response, now time.Time) time.Time {
+	if response.NextUpdate.IsZero() {
+		return now.Add(ocspDefaultRefresh)
+	}
+	refresh := response.ThisUpdate.Add(response.NextUpdate.Sub(response.ThisUpdate) / 2)
+	if refresh.Before(now.Add(ocspMinRefresh)) {
+		return now.Add(ocspMinRefresh)
+	}
+	return refresh
+}
+
+// fetch requests the OCSP response of the certificate to its responder.
+func (s *ocspStapler) fetch(staple *ocspStaple) ([]byte, *ocsp.Response, error) {
+	request, err := ocsp.CreateRequest(staple.leaf, staple.issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.client.Post(staple.leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder answered %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	response, err := ioutil.ReadAll(io.LimitReader(resp.Body, ocspMaxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := ocsp.ParseResponse(response, staple.issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parsed.Status != ocsp.Good {
+		return nil, nil, fmt.Errorf("OCSP status %s", ocspStatusNames[parsed.Status])
+	}
+	if parsed.SerialNumber == nil || parsed.SerialNumber.Cmp(staple.leaf.SerialNumber) != 0 {
+		return nil, nil, errors.New("OCSP response for another certificate")
+	}
+	return response, parsed, nil
+}
+
+// publish stores the configuration with the current staples of the certificates.
+func (s *ocspStapler) publish() {
+	config := s.base.Clone()
+	config.GetConfigForClient = nil
+	config.Certificates = make([]tls.Certificate, len(s.base.Certificates))
+	for i, cert := range s.base.Certificates {
+		if staple := s.staples[i]; staple != nil {
+			cert.OCSPStaple = staple.response
+		}
+		config.Certificates[i] = cert
+	}
+	config.NameToCertificate = nil
+	config.BuildNameToCertificate()
+	if s.store != nil {
+		config.GetCertificate = s.store.withCertificates(config.Certificates).getCertificate
+	}
+	s.config.Store(config)
+}
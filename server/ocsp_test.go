@@ -0,0 +1,282 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The ASN.1 structures of the OCSP responses, see RFC 6960, section 4.2.1.
+type testOCSPResponse struct {
+	Status   asn1.Enumerated
+	Response testOCSPResponseBytes `asn1:"explicit,tag:0"`
+}
+
+type testOCSPResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type testOCSPBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type testOCSPResponseData struct {
+	KeyHash    []byte `asn1:"explicit,tag:2"`
+	ProducedAt time.Time
+	Responses  []testOCSPSingleResponse
+}
+
+type testOCSPSingleResponse struct {
+	CertID     testOCSPCertID
+	Good       asn1.Flag `asn1:"tag:0,optional"`
+	Unknown    asn1.Flag `asn1:"tag:2,optional"`
+	ThisUpdate time.Time
+	NextUpdate time.Time `asn1:"explicit,tag:0,optional"`
+}
+
+type testOCSPCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+// ocspResponder is a mock OCSP responder, answering with a good status for its certificate, or failing.
+type ocspResponder struct {
+	issuer     *x509.Certificate
+	issuerKey  *ecdsa.PrivateKey
+	serial     *big.Int
+	thisUpdate time.Time
+	nextUpdate time.Time
+
+	lock     sync.Mutex
+	failing  bool
+	requests int
+	response []byte
+}
+
+func (o *ocspResponder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.requests++
+	if o.failing || req.Header.Get("Content-Type") != "application/ocsp-request" {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Write(o.response)
+}
+
+func (o *ocspResponder) requestCount() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.requests
+}
+
+func (o *ocspResponder) createResponse(t *testing.T) []byte {
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err := asn1.Unmarshal(o.issuer.RawSubjectPublicKeyInfo, &publicKeyInfo)
+	require.NoError(t, err)
+	keyHash := sha1.Sum(publicKeyInfo.PublicKey.RightAlign())
+	nameHash := sha1.Sum(o.issuer.RawSubject)
+
+	tbsResponseData, err := asn1.Marshal(testOCSPResponseData{
+		KeyHash:    keyHash[:],
+		ProducedAt: o.thisUpdate.UTC().Truncate(time.Second),
+		Responses: []testOCSPSingleResponse{{
+			CertID: testOCSPCertID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, Parameters: asn1.RawValue{Tag: asn1.TagNull}},
+				NameHash:      nameHash[:],
+				IssuerKeyHash: keyHash[:],
+				SerialNumber:  o.serial,
+			},
+			Good:       true,
+			ThisUpdate: o.thisUpdate.UTC().Truncate(time.Second),
+			NextUpdate: o.nextUpdate.UTC().Truncate(time.Second),
+		}},
+	})
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(tbsResponseData)
+	signature, err := o.issuerKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	basicResponse, err := asn1.Marshal(testOCSPBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsResponseData},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	require.NoError(t, err)
+
+	response, err := asn1.Marshal(testOCSPResponse{
+		Response: testOCSPResponseBytes{
+			ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1},
+			Response:     basicResponse,
+		},
+	})
+	require.NoError(t, err)
+	return response
+}
+
+// newOCSPTestCertificate creates a certificate issued by a new CA, served with its issuer by the responder at ocspURL, if any.
+func newOCSPTestCertificate(t *testing.T, ocspURL string) (tls.Certificate, *ocspResponder) {
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "ocsp.test"},
+		DNSNames:     []string{"ocsp.test"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if len(ocspURL) > 0 {
+		template.OCSPServer = []string{ocspURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	require.NoError(t, err)
+
+	responder := &ocspResponder{
+		issuer:     ca,
+		issuerKey:  caKey,
+		serial:     template.SerialNumber,
+		thisUpdate: now.Add(-time.Minute),
+		nextUpdate: now.Add(4 * time.Hour),
+	}
+	responder.response = responder.createResponse(t)
+	return cert, responder
+}
+
+// handshakeOCSPResponse returns the OCSP response stapled by a TLS server with the configuration.
+func handshakeOCSPResponse(t *testing.T, config *tls.Config) []byte {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", listener.Addr().String(), &tls.Config{
+		ServerName:         "ocsp.test",
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.OCSPResponse()
+}
+
+func TestOCSPStapler(t *testing.T) {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	cert, responder := newOCSPTestCertificate(t, ts.URL)
+	mux.Handle("/", responder)
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	stapler := newOCSPStapler(config, nil)
+	config.GetConfigForClient = stapler.getConfigForClient
+
+	assert.Empty(t, handshakeOCSPResponse(t, config), "no staple before the first fetch")
+
+	next := stapler.refresh()
+	assert.Equal(t, 1, responder.requestCount())
+	assert.Equal(t, responder.response, handshakeOCSPResponse(t, config))
+	halfway := responder.thisUpdate.Add(responder.nextUpdate.Sub(responder.thisUpdate) / 2)
+	assert.WithinDuration(t, halfway, next, time.Second, "the response must be refreshed halfway through its validity")
+
+	// Nothing is fetched before the refresh time.
+	stapler.refresh()
+	assert.Equal(t, 1, responder.requestCount())
+}
+
+func TestOCSPStaplerFetchFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	cert, responder := newOCSPTestCertificate(t, ts.URL)
+	mux.Handle("/", responder)
+	responder.failing = true
+
+	now := time.Now()
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	stapler := newOCSPStapler(config, nil)
+	stapler.now = func() time.Time { return now }
+	config.GetConfigForClient = stapler.getConfigForClient
+
+	next := stapler.refresh()
+	assert.Equal(t, now.Add(ocspRetryInterval), next)
+	assert.Empty(t, handshakeOCSPResponse(t, config), "the certificate must be served without staple")
+
+	// A fetched response is kept while the responder fails, until it expires.
+	responder.failing = false
+	now = next
+	stapler.refresh()
+	assert.Equal(t, responder.response, handshakeOCSPResponse(t, config))
+
+	responder.failing = true
+	now = responder.nextUpdate.Add(time.Second)
+	stapler.refresh()
+	assert.Empty(t, handshakeOCSPResponse(t, config), "the expired staple must be dropped")
+}
+
+func TestOCSPStaplerNoResponder(t *testing.T) {
+	cert, _ := newOCSPTestCertificate(t, "")
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	stapler := newOCSPStapler(config, nil)
+
+	assert.Nil(t, stapler.staples[0])
+	assert.True(t, stapler.refresh().IsZero(), "nothing to refresh")
+}
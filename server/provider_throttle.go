@@ -0,0 +1,116 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// defaultMaxThrottleFactor is the factor of the throttle duration of a provider giving its default maximum one.
+const defaultMaxThrottleFactor = 5
+
+// providerThrottle coalesces the bursts of configurations of a provider into a single reload.
+// A configuration received while the provider was quiet for the throttle duration is applied at once.
+// Otherwise, only the last configuration is applied, once the provider sent no new one for the throttle duration,
+// and at the latest the maximum throttle duration after the first one waiting, for the reloads to keep up
+// with a sustained stream of changes at a bounded rate.
+type providerThrottle struct {
+	throttle    time.Duration
+	maxThrottle time.Duration
+	apply       func(types.ConfigMessage)
+	now         func() time.Time
+
+	lock         sync.Mutex
+	pending      *types.ConfigMessage
+	firstPending time.Time
+	lastReceived time.Time
+	timer        *time.Timer
+	// generation identifies the last scheduled timer, the previous ones being ignored when they fire.
+	generation int
+}
+
+func newProviderThrottle(throttle, maxThrottle time.Duration, apply func(types.ConfigMessage)) *providerThrottle {
+	if maxThrottle < throttle {
+		maxThrottle = throttle
+	}
+	return &providerThrottle{
+		throttle:    throttle,
+		maxThrottle: maxThrottle,
+		apply:       apply,
+		now:         time.Now,
+	}
+}
+
+// receive applies the configuration, or keeps it to be applied once the provider is quiet.
+// The configurations are applied under the lock, for them to be applied in the order they were received.
+func (t *providerThrottle) receive(configMsg types.ConfigMessage) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	lastReceived := t.lastReceived
+	t.lastReceived = now
+	if t.pending == nil && now.Sub(lastReceived) >= t.throttle {
+		log.Debugf("Last %s config received more than %s ago, OK", configMsg.ProviderName, t.throttle)
+		t.apply(configMsg)
+		return
+	}
+
+	log.Debugf("Last %s config received less than %s ago, waiting...", configMsg.ProviderName, t.throttle)
+	if t.pending == nil {
+		t.firstPending = now
+	}
+	t.pending = &configMsg
+	delay := t.throttle
+	if deadline := t.firstPending.Add(t.maxThrottle); now.Add(delay).After(deadline) {
+		delay = deadline.Sub(now)
+	}
+	if delay <= 0 {
+		// The maximum throttle duration is over before the timer could apply the pending configuration.
+		log.Debugf("Waited for %s config, OK", configMsg.ProviderName)
+		t.pending = nil
+		t.schedule(-1)
+		t.apply(configMsg)
+		return
+	}
+	t.schedule(delay)
+}
+
+// discard drops the configuration waiting to be applied, if any, e.g. when the provider sent the current one again.
+func (t *providerThrottle) discard() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.pending = nil
+	t.schedule(-1)
+}
+
+// schedule replaces the timer applying the pending configuration, none being scheduled with a negative delay.
+// The lock must be held.
+func (t *providerThrottle) schedule(delay time.Duration) {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.generation++
+	if delay < 0 {
+		return
+	}
+	generation := t.generation
+	t.timer = time.AfterFunc(delay, func() { t.flush(generation) })
+}
+
+func (t *providerThrottle) flush(generation int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if generation != t.generation || t.pending == nil {
+		return
+	}
+	configMsg := *t.pending
+	t.pending = nil
+	t.timer = nil
+	log.Debugf("Waited for %s config, OK", configMsg.ProviderName)
+	t.apply(configMsg)
+}
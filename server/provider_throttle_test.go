@@ -0,0 +1,116 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/containous/traefik/provider/file"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenProvidersThrottle(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		ProvidersThrottleDuration: flaeg.Duration(50 * time.Millisecond),
+	}
+	srv := NewServer(globalConfig)
+	stop := make(chan bool)
+	defer close(stop)
+	go srv.listenProviders(stop)
+
+	// A sustained stream of changes, for longer than the maximum throttle duration of 250ms.
+	const changes = 100
+	for i := 1; i <= changes; i++ {
+		srv.configurationChan <- types.ConfigMessage{
+			ProviderName:  "mock",
+			Configuration: buildDynamicConfig(withFrontend("frontend"+strconv.Itoa(i), buildFrontend())),
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var reloads []types.ConfigMessage
+	timeout := time.After(5 * time.Second)
+	for len(reloads) == 0 || !hasFrontend(reloads[len(reloads)-1], "frontend"+strconv.Itoa(changes)) {
+		select {
+		case configMsg := <-srv.configurationValidatedChan:
+			reloads = append(reloads, configMsg)
+		case <-timeout:
+			require.Fail(t, "the last configuration was not applied", "%d reloads", len(reloads))
+		}
+	}
+
+	select {
+	case configMsg := <-srv.configurationValidatedChan:
+		assert.Fail(t, "unexpected reload after the last configuration", "%v", configMsg.Configuration.Frontends)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.True(t, len(reloads) >= 2, "the changes should be applied during the stream, got %d reloads", len(reloads))
+	assert.True(t, len(reloads) <= 6, "the reloads should be throttled, got %d reloads", len(reloads))
+	assert.True(t, hasFrontend(reloads[0], "frontend1"), "the first change should be applied at once")
+}
+
+func hasFrontend(configMsg types.ConfigMessage, frontendName string) bool {
+	_, ok := configMsg.Configuration.Frontends[frontendName]
+	return ok
+}
+
+func TestProviderThrottle(t *testing.T) {
+	applied := make(chan types.ConfigMessage, 10)
+	throttle := newProviderThrottle(time.Hour, time.Hour, func(configMsg types.ConfigMessage) {
+		applied <- configMsg
+	})
+	now := time.Now()
+	throttle.now = func() time.Time { return now }
+
+	throttle.receive(types.ConfigMessage{ProviderName: "first"})
+	require.Len(t, applied, 1, "the configuration of a quiet provider should be applied at once")
+	assert.Equal(t, "first", (<-applied).ProviderName)
+
+	now = now.Add(time.Minute)
+	throttle.receive(types.ConfigMessage{ProviderName: "second"})
+	throttle.receive(types.ConfigMessage{ProviderName: "third"})
+	assert.Empty(t, applied)
+	require.NotNil(t, throttle.pending)
+	assert.Equal(t, "third", throttle.pending.ProviderName)
+
+	throttle.discard()
+	assert.Nil(t, throttle.pending)
+	assert.Nil(t, throttle.timer)
+
+	now = now.Add(time.Hour)
+	throttle.receive(types.ConfigMessage{ProviderName: "fourth"})
+	require.Len(t, applied, 1)
+	assert.Equal(t, "fourth", (<-applied).ProviderName)
+
+	// A configuration received once the maximum throttle duration is over is applied at once.
+	now = now.Add(time.Minute)
+	throttle.receive(types.ConfigMessage{ProviderName: "fifth"})
+	now = now.Add(time.Hour)
+	throttle.receive(types.ConfigMessage{ProviderName: "sixth"})
+	require.Len(t, applied, 1)
+	assert.Equal(t, "sixth", (<-applied).ProviderName)
+	assert.Nil(t, throttle.pending)
+	assert.Nil(t, throttle.timer)
+}
+
+func TestNewProviderThrottleDurations(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		ProvidersThrottleDuration: flaeg.Duration(2 * time.Second),
+		File:                      &file.Provider{},
+	}
+	globalConfig.File.ThrottleDuration = flaeg.Duration(10 * time.Second)
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+
+	throttle := srv.newProviderThrottle("file")
+	assert.Equal(t, 10*time.Second, throttle.throttle)
+	assert.Equal(t, 50*time.Second, throttle.maxThrottle)
+
+	throttle = srv.newProviderThrottle("web")
+	assert.Equal(t, 2*time.Second, throttle.throttle)
+	assert.Equal(t, 10*time.Second, throttle.maxThrottle)
+}
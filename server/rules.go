@@ -1,12 +1,17 @@
 package server
 
 import (
+	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
+	"mime"
 	"net"
 	"net/http"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/BurntSushi/ty/fun"
@@ -18,16 +23,23 @@ import (
 type Rules struct {
 	route *serverRoute
 	err   error
+	// proxyChecker tells the proxies trusted to forward the client IP matched by ClientIP.
+	proxyChecker *types.ProxyChecker
 }
 
 func (r *Rules) host(hosts ...string) *mux.Route {
+	for _, host := range hosts {
+		if types.IsWildcardDomain(host) {
+			r.route.wildcardHost = true
+		}
+	}
 	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
 		reqHost, _, err := net.SplitHostPort(req.Host)
 		if err != nil {
 			reqHost = req.Host
 		}
 		for _, host := range hosts {
-			if types.CanonicalDomain(reqHost) == types.CanonicalDomain(host) {
+			if types.MatchDomain(reqHost, host) {
 				return true
 			}
 		}
@@ -45,8 +57,22 @@ func (r *Rules) hostRegexp(hosts ...string) *mux.Route {
 
 func (r *Rules) path(paths ...string) *mux.Route {
 	router := r.route.route.Subrouter()
+	switch r.route.trailingSlash {
+	case types.TrailingSlashEquivalent:
+		router.StrictSlash(false)
+	case types.TrailingSlashRedirect:
+		router.StrictSlash(true)
+	}
 	for _, path := range paths {
-		router.Path(strings.TrimSpace(path))
+		path = strings.TrimSpace(path)
+		router.Path(path)
+		if r.route.trailingSlash == types.TrailingSlashEquivalent && path != "/" {
+			if strings.HasSuffix(path, "/") {
+				router.Path(strings.TrimSuffix(path, "/"))
+			} else {
+				router.Path(path + "/")
+			}
+		}
 	}
 	return r.route.route
 }
@@ -56,6 +82,21 @@ func (r *Rules) pathPrefix(paths ...string) *mux.Route {
 	for _, path := range paths {
 		router.PathPrefix(strings.TrimSpace(path))
 	}
+	if r.route.trailingSlash != types.TrailingSlashEquivalent && r.route.trailingSlash != types.TrailingSlashRedirect {
+		return r.route.route
+	}
+	// The prefixes ending with a slash also match their path without it, which is redirected to them if need be.
+	router.StrictSlash(false)
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "/" || !strings.HasSuffix(path, "/") {
+			continue
+		}
+		router.Path(strings.TrimSuffix(path, "/"))
+		if r.route.trailingSlash == types.TrailingSlashRedirect {
+			r.route.slashRedirects = append(r.route.slashRedirects, strings.TrimSuffix(path, "/"))
+		}
+	}
 	return r.route.route
 }
 
@@ -123,12 +164,335 @@ func (r *Rules) methods(methods ...string) *mux.Route {
 	return r.route.route.Methods(methods...)
 }
 
+func (r *Rules) clientCertOU(organizationalUnits ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		cert := verifiedClientCertificate(req)
+		if cert == nil {
+			return false
+		}
+		for _, organizationalUnit := range cert.Subject.OrganizationalUnit {
+			for _, expected := range organizationalUnits {
+				if organizationalUnit == expected {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (r *Rules) clientCertSAN(sans ...string) *mux.Route {
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		cert := verifiedClientCertificate(req)
+		if cert == nil {
+			return false
+		}
+		certSANs := append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			certSANs = append(certSANs, ip.String())
+		}
+		certSANs = append(certSANs, certificateURIs(cert)...)
+		for _, certSAN := range certSANs {
+			for _, expected := range sans {
+				if strings.EqualFold(certSAN, expected) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// oidExtensionSubjectAltName is the identifier of the subject alternative name extension of the certificates.
+var oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// certificateURIs returns the URI subject alternative names of the certificate, read from its extension
+// as the x509 package of the supported Go versions does not parse them.
+func certificateURIs(cert *x509.Certificate) []string {
+	var uris []string
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+		var names asn1.RawValue
+		if _, err := asn1.Unmarshal(extension.Value, &names); err != nil || !names.IsCompound || names.Tag != asn1.TagSequence {
+			return nil
+		}
+		for rest := names.Bytes; len(rest) > 0; {
+			var name asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+				return nil
+			}
+			// uniformResourceIdentifier [6] IA5String
+			if name.Class == asn1.ClassContextSpecific && name.Tag == 6 {
+				uris = append(uris, string(name.Bytes))
+			}
+		}
+	}
+	return uris
+}
+
+// clientIP matches the requests of the clients whose IP belongs to one of the source ranges, IPs or CIDRs.
+// The client IP is taken from X-Forwarded-For only for requests coming from a trusted proxy.
+func (r *Rules) clientIP(sourceRanges ...string) *mux.Route {
+	var clientNets []*net.IPNet
+	for _, sourceRange := range sourceRanges {
+		if ip := net.ParseIP(sourceRange); ip != nil {
+			sourceRange = ip.String() + "/128"
+			if ip.To4() != nil {
+				sourceRange = ip.String() + "/32"
+			}
+		}
+		_, clientNet, err := net.ParseCIDR(sourceRange)
+		if err != nil {
+			r.err = fmt.Errorf("invalid client IP range %s: %v", sourceRange, err)
+			return r.route.route
+		}
+		clientNets = append(clientNets, clientNet)
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		ip := net.ParseIP(r.proxyChecker.ClientIP(req))
+		if ip == nil {
+			return false
+		}
+		for _, clientNet := range clientNets {
+			if clientNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// scheme matches the requests by their scheme, http or https.
+func (r *Rules) scheme(schemes ...string) *mux.Route {
+	for i, scheme := range schemes {
+		schemes[i] = strings.ToLower(strings.TrimSpace(scheme))
+		if schemes[i] != "http" && schemes[i] != "https" {
+			r.err = fmt.Errorf("invalid scheme %s, must be http or https", scheme)
+			return r.route.route
+		}
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		requestScheme := r.requestScheme(req)
+		for _, scheme := range schemes {
+			if scheme == requestScheme {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// requestScheme returns the scheme of the request as sent by the client: the X-Forwarded-Proto of the requests
+// coming from a trusted proxy, otherwise https when received over TLS and http when not.
+func (r *Rules) requestScheme(req *http.Request) string {
+	if r.proxyChecker.IsTrusted(req.RemoteAddr) {
+		if proto := req.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+			return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+		}
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// verifiedClientCertificate returns the client certificate of the request,
+// given the client authenticated with a certificate verified by the entrypoint CAs.
+func verifiedClientCertificate(req *http.Request) *x509.Certificate {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
+
+// headers matches the requests with all the headers equal to the values, given either as Key=value pairs,
+// or as a key, value list. An absent header is matched as an empty one by the pairs only, the headers of the list
+// having to be present as with mux, whose empty values match any of theirs.
 func (r *Rules) headers(headers ...string) *mux.Route {
-	return r.route.route.Headers(headers...)
+	pairs, explicit, err := headerPairs(headers)
+	if err != nil {
+		r.err = err
+		return r.route.route
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		for _, pair := range pairs {
+			expected := pair[1]
+			match := func(value string) bool { return value == expected }
+			if !explicit && len(expected) == 0 {
+				match = func(string) bool { return true }
+			}
+			if !matchHeader(req, pair[0], match, explicit) {
+				return false
+			}
+		}
+		return true
+	})
 }
 
+// headersRegexp matches the requests with all the headers matching the regular expressions, given either as
+// Key=regexp pairs, or as a key, regexp list. An absent header is matched as an empty one by the pairs only,
+// e.g. with ^$, the headers of the list having to be present as with mux.
 func (r *Rules) headersRegexp(headers ...string) *mux.Route {
-	return r.route.route.HeadersRegexp(headers...)
+	pairs, explicit, err := headerPairs(headers)
+	if err != nil {
+		r.err = err
+		return r.route.route
+	}
+	regexps := make([]*regexp.Regexp, len(pairs))
+	for i, pair := range pairs {
+		if regexps[i], err = regexp.Compile(pair[1]); err != nil {
+			r.err = fmt.Errorf("invalid regular expression for header %s: %v", pair[0], err)
+			return r.route.route
+		}
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		for i, pair := range pairs {
+			if !matchHeader(req, pair[0], regexps[i].MatchString, explicit) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// headerPairs returns the header key and value pairs of the Key=value arguments, explicit being true,
+// or of the key, value list when any argument is not a pair.
+func headerPairs(headers []string) (pairs [][2]string, explicit bool, err error) {
+	for _, header := range headers {
+		index := strings.Index(header, "=")
+		if index <= 0 {
+			pairs = nil
+			break
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(header[:index]), strings.TrimSpace(header[index+1:])})
+	}
+	if pairs != nil {
+		return pairs, true, nil
+	}
+
+	if len(headers)%2 != 0 {
+		return nil, false, fmt.Errorf("headers must be given as Key=value pairs or as a key, value list: %s", strings.Join(headers, ", "))
+	}
+	for i := 0; i < len(headers); i += 2 {
+		pairs = append(pairs, [2]string{headers[i], headers[i+1]})
+	}
+	return pairs, false, nil
+}
+
+// matchHeader returns whether any value of the header of the request matches.
+// An absent header matches as an empty value when matchAbsent is true, and never matches otherwise.
+func matchHeader(req *http.Request, key string, match func(value string) bool, matchAbsent bool) bool {
+	values := req.Header[http.CanonicalHeaderKey(key)]
+	if len(values) == 0 {
+		return matchAbsent && match("")
+	}
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts matches the requests accepting one of the media types among the ones they prefer: its quality in the Accept
+// headers must be positive, and at least the one of any other media type they name. The wildcard ranges are ignored,
+// for the requests accepting anything not to be routed to a specific media type.
+func (r *Rules) accepts(mediaTypes ...string) *mux.Route {
+	for i, mediaType := range mediaTypes {
+		parsed, err := parseRuleMediaType(mediaType)
+		if err != nil {
+			r.err = err
+			return r.route.route
+		}
+		if strings.Contains(parsed, "*") {
+			r.err = fmt.Errorf("invalid media type %s, Accepts does not match wildcards", mediaType)
+			return r.route.route
+		}
+		mediaTypes[i] = parsed
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		qualities := acceptedQualities(req)
+		var best, matched float64
+		for _, quality := range qualities {
+			if quality > best {
+				best = quality
+			}
+		}
+		for _, mediaType := range mediaTypes {
+			if qualities[mediaType] > matched {
+				matched = qualities[mediaType]
+			}
+		}
+		return matched > 0 && matched >= best
+	})
+}
+
+// acceptedQualities returns the qualities of the media types named by the Accept headers of the request,
+// without the wildcard ranges and the elements with an invalid quality.
+func acceptedQualities(req *http.Request) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, header := range req.Header[http.CanonicalHeaderKey("Accept")] {
+		for _, element := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(element)
+			if err != nil || strings.Contains(mediaType, "*") {
+				continue
+			}
+			quality := 1.0
+			if value, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(value, 64); err != nil || quality < 0 || quality > 1 {
+					continue
+				}
+			}
+			if current, ok := qualities[mediaType]; !ok || quality > current {
+				qualities[mediaType] = quality
+			}
+		}
+	}
+	return qualities
+}
+
+// contentType matches the requests whose Content-Type is one of the media types, its parameters being ignored.
+// A media type may end with a wildcard subtype, e.g. application/*.
+func (r *Rules) contentType(mediaTypes ...string) *mux.Route {
+	for i, mediaType := range mediaTypes {
+		parsed, err := parseRuleMediaType(mediaType)
+		if err != nil {
+			r.err = err
+			return r.route.route
+		}
+		if strings.Contains(strings.TrimSuffix(parsed, "/*"), "*") {
+			r.err = fmt.Errorf("invalid media type %s, only the subtype can be a wildcard", mediaType)
+			return r.route.route
+		}
+		mediaTypes[i] = parsed
+	}
+	return r.route.route.MatcherFunc(func(req *http.Request, route *mux.RouteMatch) bool {
+		requestType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, mediaType := range mediaTypes {
+			if mediaType == requestType || (strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(requestType, strings.TrimSuffix(mediaType, "*"))) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// parseRuleMediaType returns the lower case type/subtype of a media type given to a rule.
+func parseRuleMediaType(mediaType string) (string, error) {
+	parsed, params, err := mime.ParseMediaType(mediaType)
+	if err == nil && (len(params) > 0 || !strings.Contains(parsed, "/")) {
+		err = errors.New("expected type/subtype")
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid media type %s: %v", mediaType, err)
+	}
+	return parsed, nil
 }
 
 func (r *Rules) parseRules(expression string, onRule func(functionName string, function interface{}, arguments []string) error) error {
@@ -146,6 +510,12 @@ func (r *Rules) parseRules(expression string, onRule func(functionName string, f
 		"HeadersRegexp":        r.headersRegexp,
 		"AddPrefix":            r.addPrefix,
 		"ReplacePath":          r.replacePath,
+		"ClientCertOU":         r.clientCertOU,
+		"ClientCertSAN":        r.clientCertSAN,
+		"ClientIP":             r.clientIP,
+		"Scheme":               r.scheme,
+		"Accepts":              r.accepts,
+		"ContentType":          r.contentType,
 	}
 
 	if len(expression) == 0 {
@@ -225,6 +595,33 @@ func (r *Rules) Parse(expression string) (*mux.Route, error) {
 	return resultRoute, nil
 }
 
+// pathFunctions are the rule functions matching or modifying the path of the requests.
+var pathFunctions = map[string]bool{
+	"Path":                 true,
+	"PathStrip":            true,
+	"PathStripRegex":       true,
+	"PathPrefix":           true,
+	"PathPrefixStrip":      true,
+	"PathPrefixStripRegex": true,
+	"AddPrefix":            true,
+	"ReplacePath":          true,
+}
+
+// ParseWithoutPaths parses rules expressions and returns them without their path rules
+func (r *Rules) ParseWithoutPaths(expression string) (string, error) {
+	var rules []string
+	err := r.parseRules(expression, func(functionName string, function interface{}, arguments []string) error {
+		if !pathFunctions[functionName] {
+			rules = append(rules, functionName+":"+strings.Join(arguments, ","))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error parsing rule: %v", err)
+	}
+	return strings.Join(rules, ";"), nil
+}
+
 // ParseDomains parses rules expressions and returns domains
 func (r *Rules) ParseDomains(expression string) ([]string, error) {
 	domains := []string{}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"net/http"
 	"net/url"
 	"testing"
 
 	"github.com/containous/mux"
 	"github.com/containous/traefik/testhelpers"
+	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -49,6 +54,138 @@ func TestParseTwoRules(t *testing.T) {
 	assert.True(t, routeMatch, "Rule %s don't match.", expression)
 }
 
+func TestParseWildcardHostRule(t *testing.T) {
+	router := mux.NewRouter()
+	route := router.NewRoute()
+	serverRoute := &serverRoute{route: route}
+	rules := &Rules{route: serverRoute}
+
+	expression := "Host:*.example.com"
+	routeResult, err := rules.Parse(expression)
+	require.NoError(t, err, "Error while building route for %s", expression)
+	assert.True(t, serverRoute.wildcardHost)
+
+	testCases := []struct {
+		url      string
+		expected bool
+	}{
+		{url: "http://api.example.com", expected: true},
+		{url: "http://api.example.com:8080", expected: true},
+		{url: "http://a.b.example.com", expected: false},
+		{url: "http://example.com", expected: false},
+	}
+
+	for _, test := range testCases {
+		request := testhelpers.MustNewRequest(http.MethodGet, test.url, nil)
+		routeMatch := routeResult.Match(request, &mux.RouteMatch{Route: routeResult})
+
+		assert.Equal(t, test.expected, routeMatch, "Rule %s on %s", expression, test.url)
+	}
+}
+
+func TestExactHostRulePrecedesWildcard(t *testing.T) {
+	router := mux.NewRouter()
+
+	handlers := map[string]*fakeHandler{}
+	for _, rule := range []string{"Host:*.example.com", "Host:a.example.com", "Host:api.example.com"} {
+		serverRoute := &serverRoute{route: router.NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, nil)
+		require.NoError(t, err, "Error while building route for %s", rule)
+
+		handlers[rule] = &fakeHandler{name: rule}
+		serverRoute.route.Handler(handlers[rule])
+	}
+	router.SortRoutes()
+
+	testCases := []struct {
+		host         string
+		expectedRule string
+	}{
+		{host: "a.example.com", expectedRule: "Host:a.example.com"},
+		{host: "api.example.com", expectedRule: "Host:api.example.com"},
+		{host: "web.example.com", expectedRule: "Host:*.example.com"},
+	}
+
+	for _, test := range testCases {
+		routeMatch := &mux.RouteMatch{}
+		matched := router.Match(testhelpers.MustNewRequest(http.MethodGet, "http://"+test.host, nil), routeMatch)
+
+		require.True(t, matched, "No route matched %s", test.host)
+		assert.Equal(t, handlers[test.expectedRule], routeMatch.Handler, "Wrong route matched %s", test.host)
+	}
+}
+
+func TestClientCertRules(t *testing.T) {
+	router := mux.NewRouter()
+
+	handlers := map[string]*fakeHandler{}
+	for _, rule := range []string{"ClientCertOU:engineering", "ClientCertSAN:ops.example.com", "ClientCertSAN:spiffe://traefik.io/api", "PathPrefix:/"} {
+		serverRoute := &serverRoute{route: router.NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, nil)
+		require.NoError(t, err, "Error while building route for %s", rule)
+
+		handlers[rule] = &fakeHandler{name: rule}
+		serverRoute.route.Handler(handlers[rule])
+	}
+	router.SortRoutes()
+
+	engineeringCert := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"engineering"}}}
+	opsCert := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"ops"}}, DNSNames: []string{"ops.example.com"}}
+	subjectAltName, err := asn1.Marshal([]asn1.RawValue{
+		{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte("api.example.com")},
+		{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte("spiffe://traefik.io/api")},
+	})
+	require.NoError(t, err)
+	apiCert := &x509.Certificate{Extensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: subjectAltName}}}
+
+	testCases := []struct {
+		desc         string
+		tlsState     *tls.ConnectionState
+		expectedRule string
+	}{
+		{
+			desc:         "without TLS",
+			expectedRule: "PathPrefix:/",
+		},
+		{
+			desc:         "without verified client certificate",
+			tlsState:     &tls.ConnectionState{PeerCertificates: []*x509.Certificate{engineeringCert}},
+			expectedRule: "PathPrefix:/",
+		},
+		{
+			desc:         "matching organizational unit",
+			tlsState:     &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{engineeringCert}}},
+			expectedRule: "ClientCertOU:engineering",
+		},
+		{
+			desc:         "matching subject alternative name",
+			tlsState:     &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{opsCert}}},
+			expectedRule: "ClientCertSAN:ops.example.com",
+		},
+		{
+			desc:         "matching URI subject alternative name",
+			tlsState:     &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{apiCert}}},
+			expectedRule: "ClientCertSAN:spiffe://traefik.io/api",
+		},
+		{
+			desc:         "no matching attribute",
+			tlsState:     &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}},
+			expectedRule: "PathPrefix:/",
+		},
+	}
+
+	for _, test := range testCases {
+		request := testhelpers.MustNewRequest(http.MethodGet, "https://foo.bar/", nil)
+		request.TLS = test.tlsState
+
+		routeMatch := &mux.RouteMatch{}
+		matched := router.Match(request, routeMatch)
+
+		require.True(t, matched, "No route matched %s", test.desc)
+		assert.Equal(t, handlers[test.expectedRule], routeMatch.Handler, "Wrong route matched %s", test.desc)
+	}
+}
+
 func TestParseDomains(t *testing.T) {
 	rules := &Rules{}
 
@@ -87,6 +224,43 @@ func TestParseDomains(t *testing.T) {
 	}
 }
 
+func TestParseWithoutPaths(t *testing.T) {
+	rules := &Rules{}
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{
+			expression: "Host:foo.bar,test.bar",
+			expected:   "Host:foo.bar,test.bar",
+		},
+		{
+			expression: "PathPrefixStrip:/test",
+			expected:   "",
+		},
+		{
+			expression: "Host: foo.bar ;PathPrefix:/api;AddPrefix:/v1",
+			expected:   "Host:foo.bar",
+		},
+		{
+			expression: "Path:/test;Headers:X-Env,prod;Method:GET,POST",
+			expected:   "Headers:X-Env,prod;Method:GET,POST",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			expression, err := rules.ParseWithoutPaths(test.expression)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, expression)
+		})
+	}
+}
+
 func TestPriorites(t *testing.T) {
 	router := mux.NewRouter()
 	router.StrictSlash(true)
@@ -141,3 +315,469 @@ type fakeHandler struct {
 }
 
 func (h *fakeHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func TestClientIPRules(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"172.16.0.1"})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+
+	handlers := map[string]*fakeHandler{}
+	for _, rule := range []string{"ClientIP:10.0.0.0/8, 192.168.1.1", "ClientIP:10.0.0.0/8;PathPrefix:/api", "PathPrefix:/"} {
+		serverRoute := &serverRoute{route: router.NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, proxyChecker)
+		require.NoError(t, err, "Error while building route for %s", rule)
+
+		handlers[rule] = &fakeHandler{name: rule}
+		serverRoute.route.Handler(handlers[rule])
+	}
+	router.SortRoutes()
+
+	testCases := []struct {
+		desc          string
+		remoteAddr    string
+		xForwardedFor string
+		path          string
+		expectedRule  string
+	}{
+		{
+			desc:         "client inside the CIDR",
+			remoteAddr:   "10.1.2.3:1234",
+			path:         "/",
+			expectedRule: "ClientIP:10.0.0.0/8, 192.168.1.1",
+		},
+		{
+			desc:         "client matching the IP",
+			remoteAddr:   "192.168.1.1:1234",
+			path:         "/",
+			expectedRule: "ClientIP:10.0.0.0/8, 192.168.1.1",
+		},
+		{
+			desc:         "client inside the CIDR combined with a path",
+			remoteAddr:   "10.1.2.3:1234",
+			path:         "/api/users",
+			expectedRule: "ClientIP:10.0.0.0/8;PathPrefix:/api",
+		},
+		{
+			desc:         "client outside the CIDR",
+			remoteAddr:   "8.8.8.8:1234",
+			path:         "/api/users",
+			expectedRule: "PathPrefix:/",
+		},
+		{
+			desc:          "client inside the CIDR behind a trusted proxy",
+			remoteAddr:    "172.16.0.1:1234",
+			xForwardedFor: "10.0.0.5",
+			path:          "/",
+			expectedRule:  "ClientIP:10.0.0.0/8, 192.168.1.1",
+		},
+		{
+			desc:          "forwarded client IP from an untrusted proxy",
+			remoteAddr:    "8.8.8.8:1234",
+			xForwardedFor: "10.0.0.5",
+			path:          "/",
+			expectedRule:  "PathPrefix:/",
+		},
+	}
+
+	for _, test := range testCases {
+		request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar"+test.path, nil)
+		request.RemoteAddr = test.remoteAddr
+		if len(test.xForwardedFor) > 0 {
+			request.Header.Set("X-Forwarded-For", test.xForwardedFor)
+		}
+
+		routeMatch := &mux.RouteMatch{}
+		matched := router.Match(request, routeMatch)
+
+		require.True(t, matched, "No route matched %s", test.desc)
+		assert.Equal(t, handlers[test.expectedRule], routeMatch.Handler, "Wrong route matched %s", test.desc)
+	}
+}
+
+func TestClientIPRuleInvalidRange(t *testing.T) {
+	serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+	err := getRoute(serverRoute, &types.Route{Rule: "ClientIP:10.0.0.0/33"}, nil)
+	assert.Error(t, err)
+}
+
+func TestSchemeRules(t *testing.T) {
+	proxyChecker, err := types.NewProxyChecker(types.TrustedProxies{"172.16.0.1"})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+
+	handlers := map[string]*fakeHandler{}
+	for _, rule := range []string{"Host:foo.bar;Scheme:https", "Host:foo.bar;Scheme:http"} {
+		serverRoute := &serverRoute{route: router.NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, proxyChecker)
+		require.NoError(t, err, "Error while building route for %s", rule)
+
+		handlers[rule] = &fakeHandler{name: rule}
+		serverRoute.route.Handler(handlers[rule])
+	}
+	router.SortRoutes()
+
+	testCases := []struct {
+		desc           string
+		remoteAddr     string
+		tls            bool
+		forwardedProto string
+		expectedRule   string
+	}{
+		{
+			desc:         "http request",
+			remoteAddr:   "10.1.2.3:1234",
+			expectedRule: "Host:foo.bar;Scheme:http",
+		},
+		{
+			desc:         "https request",
+			remoteAddr:   "10.1.2.3:1234",
+			tls:          true,
+			expectedRule: "Host:foo.bar;Scheme:https",
+		},
+		{
+			desc:           "https terminated by a trusted proxy",
+			remoteAddr:     "172.16.0.1:1234",
+			forwardedProto: "https",
+			expectedRule:   "Host:foo.bar;Scheme:https",
+		},
+		{
+			desc:           "http forwarded over TLS by a trusted proxy",
+			remoteAddr:     "172.16.0.1:1234",
+			tls:            true,
+			forwardedProto: "HTTP",
+			expectedRule:   "Host:foo.bar;Scheme:http",
+		},
+		{
+			desc:           "forwarded scheme from an untrusted proxy",
+			remoteAddr:     "8.8.8.8:1234",
+			forwardedProto: "https",
+			expectedRule:   "Host:foo.bar;Scheme:http",
+		},
+	}
+
+	for _, test := range testCases {
+		request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/", nil)
+		request.RemoteAddr = test.remoteAddr
+		if test.tls {
+			request.TLS = &tls.ConnectionState{}
+		}
+		if len(test.forwardedProto) > 0 {
+			request.Header.Set("X-Forwarded-Proto", test.forwardedProto)
+		}
+
+		routeMatch := &mux.RouteMatch{}
+		matched := router.Match(request, routeMatch)
+
+		require.True(t, matched, "No route matched %s", test.desc)
+		assert.Equal(t, handlers[test.expectedRule], routeMatch.Handler, "Wrong route matched %s", test.desc)
+	}
+
+	request := testhelpers.MustNewRequest(http.MethodGet, "http://other.bar/", nil)
+	assert.False(t, router.Match(request, &mux.RouteMatch{}), "the scheme must be combined with the host")
+}
+
+func TestSchemeRuleInvalid(t *testing.T) {
+	serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+	err := getRoute(serverRoute, &types.Route{Rule: "Scheme:ftp"}, nil)
+	assert.Error(t, err)
+}
+
+func TestHeadersRules(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		rule     string
+		headers  map[string][]string
+		expected bool
+	}{
+		{
+			desc:     "exact match",
+			rule:     "Headers:X-Foo=bar",
+			headers:  map[string][]string{"X-Foo": {"bar"}},
+			expected: true,
+		},
+		{
+			desc:     "exact mismatch",
+			rule:     "Headers:X-Foo=bar",
+			headers:  map[string][]string{"X-Foo": {"barbar"}},
+			expected: false,
+		},
+		{
+			desc:     "exact match of any value",
+			rule:     "Headers:x-foo=bar",
+			headers:  map[string][]string{"X-Foo": {"baz", "bar"}},
+			expected: true,
+		},
+		{
+			desc:     "exact match of a key, value list",
+			rule:     "Headers: Content-Type, application/json",
+			headers:  map[string][]string{"Content-Type": {"application/json"}},
+			expected: true,
+		},
+		{
+			desc:     "all the headers must match",
+			rule:     "Headers:X-Foo=bar, X-Bar=foo",
+			headers:  map[string][]string{"X-Foo": {"bar"}},
+			expected: false,
+		},
+		{
+			desc:     "all the header matchers must match",
+			rule:     "Headers:X-Foo=bar;HeadersRegexp:X-Bar=^f",
+			headers:  map[string][]string{"X-Foo": {"bar"}, "X-Bar": {"foo"}},
+			expected: true,
+		},
+		{
+			desc:     "all the header matchers must match, mismatch",
+			rule:     "Headers:X-Foo=bar;HeadersRegexp:X-Bar=^f",
+			headers:  map[string][]string{"X-Foo": {"bar"}, "X-Bar": {"oof"}},
+			expected: false,
+		},
+		{
+			desc:     "regexp match",
+			rule:     "HeadersRegexp:Content-Type=application/(text|json)",
+			headers:  map[string][]string{"Content-Type": {"application/json"}},
+			expected: true,
+		},
+		{
+			desc:     "regexp match of a key, value list",
+			rule:     "HeadersRegexp: Content-Type, application/(text|json)",
+			headers:  map[string][]string{"Content-Type": {"application/xml"}},
+			expected: false,
+		},
+		{
+			desc:     "regexp missing header",
+			rule:     "HeadersRegexp:X-Foo=^b",
+			expected: false,
+		},
+		{
+			desc:     "absent header",
+			rule:     "HeadersRegexp:X-Foo=^$",
+			expected: true,
+		},
+		{
+			desc:     "absent header, present",
+			rule:     "HeadersRegexp:X-Foo=^$",
+			headers:  map[string][]string{"X-Foo": {"bar"}},
+			expected: false,
+		},
+		{
+			desc:     "empty exact value",
+			rule:     "Headers:X-Foo=",
+			expected: true,
+		},
+		{
+			desc:     "empty exact value, present",
+			rule:     "Headers:X-Foo=",
+			headers:  map[string][]string{"X-Foo": {"bar"}},
+			expected: false,
+		},
+		{
+			desc:     "key, value list, absent",
+			rule:     "Headers: X-Foo, bar",
+			expected: false,
+		},
+		{
+			desc:     "regexp presence of a key, value list",
+			rule:     "HeadersRegexp: X-Foo, .*",
+			headers:  map[string][]string{"X-Foo": {""}},
+			expected: true,
+		},
+		{
+			desc:     "regexp presence of a key, value list, absent",
+			rule:     "HeadersRegexp: X-Foo, .*",
+			expected: false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+			err := getRoute(serverRoute, &types.Route{Rule: test.rule}, nil)
+			require.NoError(t, err)
+
+			request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/", nil)
+			for key, values := range test.headers {
+				request.Header[key] = values
+			}
+			assert.Equal(t, test.expected, serverRoute.route.Match(request, &mux.RouteMatch{}))
+		})
+	}
+}
+
+func TestHeadersRulesInvalid(t *testing.T) {
+	for _, rule := range []string{"Headers:X-Foo, bar, X-Bar", "HeadersRegexp:X-Foo=(bar"} {
+		serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, nil)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestAcceptsRules(t *testing.T) {
+	router := mux.NewRouter()
+
+	handlers := map[string]*fakeHandler{}
+	for _, rule := range []string{"PathPrefix:/api;Accepts:application/vnd.api.v2+json", "PathPrefix:/api;Accepts:application/vnd.api.v1+json, application/json", "PathPrefix:/api"} {
+		serverRoute := &serverRoute{route: router.NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, nil)
+		require.NoError(t, err, "Error while building route for %s", rule)
+
+		handlers[rule] = &fakeHandler{name: rule}
+		serverRoute.route.Handler(handlers[rule])
+	}
+	router.SortRoutes()
+
+	testCases := []struct {
+		desc         string
+		accept       []string
+		expectedRule string
+	}{
+		{
+			desc:         "exact media type",
+			accept:       []string{"application/vnd.api.v2+json"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v2+json",
+		},
+		{
+			desc:         "any of the media types",
+			accept:       []string{"application/json"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v1+json, application/json",
+		},
+		{
+			desc:         "case insensitive with parameters",
+			accept:       []string{"Application/VND.API.v2+JSON; charset=utf-8"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v2+json",
+		},
+		{
+			desc:         "highest quality",
+			accept:       []string{"application/vnd.api.v1+json;q=0.5, application/vnd.api.v2+json;q=0.8"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v2+json",
+		},
+		{
+			desc:         "highest quality over several headers",
+			accept:       []string{"application/vnd.api.v2+json;q=0.3", "application/vnd.api.v1+json;q=0.9"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v1+json, application/json",
+		},
+		{
+			desc:         "preferred media type without rule",
+			accept:       []string{"text/html, application/vnd.api.v2+json;q=0.9"},
+			expectedRule: "PathPrefix:/api",
+		},
+		{
+			desc:         "not acceptable",
+			accept:       []string{"application/vnd.api.v2+json;q=0"},
+			expectedRule: "PathPrefix:/api",
+		},
+		{
+			desc:         "wildcards ignored",
+			accept:       []string{"*/*, application/*, application/vnd.api.v2+json;q=0.1"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v2+json",
+		},
+		{
+			desc:         "anything accepted",
+			accept:       []string{"*/*"},
+			expectedRule: "PathPrefix:/api",
+		},
+		{
+			desc:         "invalid quality ignored",
+			accept:       []string{"application/vnd.api.v2+json;q=high, application/json;q=0.1"},
+			expectedRule: "PathPrefix:/api;Accepts:application/vnd.api.v1+json, application/json",
+		},
+		{
+			desc:         "no Accept header",
+			expectedRule: "PathPrefix:/api",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			request := testhelpers.MustNewRequest(http.MethodGet, "http://foo.bar/api/users", nil)
+			request.Header["Accept"] = test.accept
+
+			routeMatch := &mux.RouteMatch{}
+			require.True(t, router.Match(request, routeMatch))
+			assert.Equal(t, test.expectedRule, routeMatch.Handler.(*fakeHandler).name)
+		})
+	}
+}
+
+func TestContentTypeRules(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		rule        string
+		contentType string
+		expected    bool
+	}{
+		{
+			desc:        "exact media type",
+			rule:        "ContentType:application/json",
+			contentType: "application/json",
+			expected:    true,
+		},
+		{
+			desc:        "parameters ignored",
+			rule:        "ContentType:application/json",
+			contentType: "Application/JSON; charset=utf-8",
+			expected:    true,
+		},
+		{
+			desc:        "any of the media types",
+			rule:        "ContentType:application/xml, application/json",
+			contentType: "application/json",
+			expected:    true,
+		},
+		{
+			desc:        "other media type",
+			rule:        "ContentType:application/json",
+			contentType: "application/vnd.api.v2+json",
+			expected:    false,
+		},
+		{
+			desc:        "wildcard subtype",
+			rule:        "ContentType:application/*",
+			contentType: "application/vnd.api.v2+json",
+			expected:    true,
+		},
+		{
+			desc:        "wildcard subtype of another type",
+			rule:        "ContentType:application/*",
+			contentType: "text/plain",
+			expected:    false,
+		},
+		{
+			desc:     "no Content-Type",
+			rule:     "ContentType:application/json",
+			expected: false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+			err := getRoute(serverRoute, &types.Route{Rule: test.rule}, nil)
+			require.NoError(t, err)
+
+			request := testhelpers.MustNewRequest(http.MethodPost, "http://foo.bar/", nil)
+			if len(test.contentType) > 0 {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+			assert.Equal(t, test.expected, serverRoute.route.Match(request, &mux.RouteMatch{}))
+		})
+	}
+}
+
+func TestContentNegotiationRulesInvalid(t *testing.T) {
+	for _, rule := range []string{"Accepts:json", "Accepts:application/*", "Accepts:*/*", "ContentType:*/json", "ContentType:application/json=1"} {
+		serverRoute := &serverRoute{route: mux.NewRouter().NewRoute()}
+		err := getRoute(serverRoute, &types.Route{Rule: rule}, nil)
+		assert.Error(t, err, rule)
+	}
+}
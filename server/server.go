@@ -6,7 +6,11 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	stdlog "log"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,10 +18,14 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/containous/flaeg"
 	"github.com/containous/mux"
 	"github.com/containous/traefik/cluster"
 	"github.com/containous/traefik/healthcheck"
@@ -26,14 +34,16 @@ import (
 	"github.com/containous/traefik/middlewares/accesslog"
 	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/secret"
 	"github.com/containous/traefik/types"
-	"github.com/streamrail/concurrent-map"
+	gokitmetrics "github.com/go-kit/kit/metrics"
 	"github.com/urfave/negroni"
 	"github.com/vulcand/oxy/cbreaker"
 	"github.com/vulcand/oxy/connlimit"
 	"github.com/vulcand/oxy/forward"
 	"github.com/vulcand/oxy/roundrobin"
 	"github.com/vulcand/oxy/utils"
+	"golang.org/x/net/http2"
 )
 
 var oxyLogger = &OxyLogger{}
@@ -46,11 +56,24 @@ type Server struct {
 	signals                    chan os.Signal
 	stopChan                   chan bool
 	providers                  []provider.Provider
+	providersByName            map[string]provider.Provider
 	currentConfigurations      safe.Safe
 	globalConfiguration        GlobalConfiguration
 	accessLoggerMiddleware     *accesslog.LogHandler
+	proxyChecker               *types.ProxyChecker
 	routinesPool               *safe.Pool
 	leadership                 *cluster.Leadership
+	dnsCache                   *dnsCache
+	// retryAttemptsCounter and circuitBreakerTransitionsCounter are registered whether Prometheus is configured or not.
+	retryAttemptsCounter             gokitmetrics.Counter
+	circuitBreakerTransitionsCounter gokitmetrics.Counter
+	// prometheusDrainer drains the metrics of the removed backends, nil unless a Prometheus drain policy is configured.
+	prometheusDrainer *middlewares.PrometheusDrainer
+	// loadBalancers are the load balancers of the backends, by backend name, as of the last configuration loaded.
+	loadBalancers safe.Safe
+	// configured is set once a configuration of the providers is loaded, and terminating once the server stops.
+	configured  int32
+	terminating int32
 }
 
 type serverEntryPoints map[string]*serverEntryPoint
@@ -58,6 +81,26 @@ type serverEntryPoints map[string]*serverEntryPoint
 type serverEntryPoint struct {
 	httpServer *http.Server
 	httpRouter *middlewares.HandlerSwitcher
+	// preserveHeaderCase, accessed atomically, is 1 when a backend of the entrypoint preserves the case of the header names.
+	preserveHeaderCase int32
+	// transports are the transports of the backends of the entrypoint.
+	transports []idleConnectionsCloser
+}
+
+// idleConnectionsCloser is a transport whose idle connections can be closed, such as http.Transport.
+type idleConnectionsCloser interface {
+	CloseIdleConnections()
+}
+
+// update switches the entrypoint to the router and the settings of the entrypoint built from a new configuration.
+// The idle connections of the replaced transports are closed, the ones still in use being closed by their idle timeout.
+func (s *serverEntryPoint) update(newServerEntryPoint *serverEntryPoint) {
+	s.httpRouter.UpdateHandler(newServerEntryPoint.httpRouter.GetHandler())
+	atomic.StoreInt32(&s.preserveHeaderCase, atomic.LoadInt32(&newServerEntryPoint.preserveHeaderCase))
+	for _, transport := range s.transports {
+		transport.CloseIdleConnections()
+	}
+	s.transports = newServerEntryPoint.transports
 }
 
 type serverRoute struct {
@@ -66,6 +109,11 @@ type serverRoute struct {
 	stripPrefixesRegex []string
 	addPrefix          string
 	replacePath        string
+	wildcardHost       bool
+	passOriginalURI    bool
+	trailingSlash      string
+	// slashRedirects holds the paths redirected to the PathPrefix rules of the same path ending with a slash.
+	slashRedirects []string
 }
 
 // NewServer returns an initialized Server.
@@ -78,7 +126,8 @@ func NewServer(globalConfiguration GlobalConfiguration) *Server {
 	server.signals = make(chan os.Signal, 1)
 	server.stopChan = make(chan bool, 1)
 	server.providers = []provider.Provider{}
-	signal.Notify(server.signals, syscall.SIGINT, syscall.SIGTERM)
+	server.providersByName = make(map[string]provider.Provider)
+	signal.Notify(server.signals, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}, rotateLogsSignals...)...)
 	currentConfigurations := make(configs)
 	server.currentConfigurations.Set(currentConfigurations)
 	server.globalConfiguration = globalConfiguration
@@ -88,15 +137,33 @@ func NewServer(globalConfiguration GlobalConfiguration) *Server {
 		server.leadership = cluster.NewLeadership(server.routinesPool.Ctx(), globalConfiguration.Cluster)
 	}
 
+	if len(globalConfiguration.TrustedProxies) > 0 {
+		var err error
+		server.proxyChecker, err = types.NewProxyChecker(globalConfiguration.TrustedProxies)
+		if err != nil {
+			log.Fatalf("Error creating trusted proxies checker: %s", err)
+		}
+	}
+
+	if globalConfiguration.DNSCache != nil {
+		server.dnsCache = newDNSCache(globalConfiguration.DNSCache)
+	}
+
+	server.retryAttemptsCounter = newRetryAttemptsCounter()
+	server.circuitBreakerTransitionsCounter = newCircuitBreakerTransitionsCounter()
+	server.prometheusDrainer = newPrometheusDrainer(globalConfiguration)
+
 	if globalConfiguration.AccessLogsFile != "" {
 		globalConfiguration.AccessLog = &types.AccessLog{FilePath: globalConfiguration.AccessLogsFile, Format: accesslog.CommonFormat}
 	}
 
 	if globalConfiguration.AccessLog != nil {
 		var err error
-		server.accessLoggerMiddleware, err = accesslog.NewLogHandler(globalConfiguration.AccessLog)
+		server.accessLoggerMiddleware, err = accesslog.NewLogHandler(globalConfiguration.AccessLog, server.proxyChecker)
 		if err != nil {
 			log.Warnf("Unable to create log handler: %s", err)
+		} else if counter := newAccessLogDroppedCounter(globalConfiguration); counter != nil {
+			server.accessLoggerMiddleware.SetDroppedEntriesCounter(counter)
 		}
 	}
 	return server
@@ -125,6 +192,7 @@ func (server *Server) Wait() {
 // Stop stops the server
 func (server *Server) Stop() {
 	defer log.Info("Server stopped")
+	atomic.StoreInt32(&server.terminating, 1)
 	var wg sync.WaitGroup
 	for sepn, sep := range server.serverEntryPoints {
 		wg.Add(1)
@@ -145,6 +213,17 @@ func (server *Server) Stop() {
 	server.stopChan <- true
 }
 
+// checkReadiness returns an error until a configuration of the providers is loaded, and once the server is stopping.
+func (server *Server) checkReadiness() error {
+	if atomic.LoadInt32(&server.terminating) == 1 {
+		return errors.New("Terminating")
+	}
+	if atomic.LoadInt32(&server.configured) == 0 {
+		return errors.New("No configuration loaded")
+	}
+	return nil
+}
+
 // Close destroys the server
 func (server *Server) Close() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(server.globalConfiguration.GraceTimeOut))
@@ -190,12 +269,23 @@ func (server *Server) startHTTPServers() {
 
 	for newServerEntryPointName, newServerEntryPoint := range server.serverEntryPoints {
 		serverEntryPoint := server.setupServerEntryPoint(newServerEntryPointName, newServerEntryPoint)
-		go server.startServer(serverEntryPoint.httpServer, server.globalConfiguration)
+		// The entrypoints are bound before serving any of them, for an unavailable address to fail the startup.
+		listener, err := server.listenEntryPoint(newServerEntryPointName, serverEntryPoint.httpServer, server.globalConfiguration)
+		if err != nil {
+			log.Fatalf("Error binding entrypoint %s on address %q: %s", newServerEntryPointName, serverEntryPoint.httpServer.Addr, err)
+		}
+		go server.startServer(serverEntryPoint, listener)
 	}
 }
 
 func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newServerEntryPoint *serverEntryPoint) *serverEntryPoint {
-	serverMiddlewares := []negroni.Handler{middlewares.NegroniRecoverHandler(), metrics}
+	serverMiddlewares := []negroni.Handler{middlewares.NegroniRecoverHandler(), metrics, rawHeaderNamesHandler}
+	if server.globalConfiguration.EntryPoints[newServerEntryPointName].ProblemDetails {
+		serverMiddlewares = append([]negroni.Handler{middlewares.NewProblemDetails()}, serverMiddlewares...)
+	}
+	if server.proxyChecker != nil {
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewForwardedHeaders(server.proxyChecker))
+	}
 	if server.accessLoggerMiddleware != nil {
 		serverMiddlewares = append(serverMiddlewares, server.accessLoggerMiddleware)
 	}
@@ -209,22 +299,57 @@ func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newS
 		serverMiddlewares = append(serverMiddlewares, statsRecorder)
 	}
 	if server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth != nil {
-		authMiddleware, err := middlewares.NewAuthenticator(server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth)
+		authMiddleware, err := middlewares.NewAuthenticator(server.globalConfiguration.EntryPoints[newServerEntryPointName].Auth, nil)
 		if err != nil {
 			log.Fatal("Error starting server: ", err)
 		}
 		serverMiddlewares = append(serverMiddlewares, authMiddleware)
 	}
-	if server.globalConfiguration.EntryPoints[newServerEntryPointName].Compress {
-		serverMiddlewares = append(serverMiddlewares, &middlewares.Compress{})
+	if entryPoint := server.globalConfiguration.EntryPoints[newServerEntryPointName]; entryPoint.Compress {
+		compressMiddleware, err := middlewares.NewCompress(entryPoint.CompressionLevel, entryPoint.CompressTypes)
+		if err != nil {
+			log.Fatal("Error starting server: ", err)
+		}
+		serverMiddlewares = append(serverMiddlewares, compressMiddleware)
 	}
 	if len(server.globalConfiguration.EntryPoints[newServerEntryPointName].WhitelistSourceRange) > 0 {
-		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(server.globalConfiguration.EntryPoints[newServerEntryPointName].WhitelistSourceRange)
+		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(server.globalConfiguration.EntryPoints[newServerEntryPointName].WhitelistSourceRange, server.proxyChecker, nil)
 		if err != nil {
 			log.Fatal("Error starting server: ", err)
 		}
 		serverMiddlewares = append(serverMiddlewares, ipWhitelistMiddleware)
 	}
+	if chain := server.globalConfiguration.EntryPoints[newServerEntryPointName].Chain; chain != nil {
+		// The middlewares of the frontends are applied after the ones of their entrypoint.
+		resolvedChain, err := resolveChainSecrets(chain)
+		if err != nil {
+			log.Fatal("Error starting server: ", err)
+		}
+		chainMiddlewares, err := server.buildChainMiddlewares(resolvedChain, nil)
+		if err != nil {
+			log.Fatal("Error starting server: ", err)
+		}
+		serverMiddlewares = append(serverMiddlewares, chainMiddlewares...)
+	}
+	if !server.globalConfiguration.StrictRequestTarget {
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewRequestNormalizer())
+	}
+	// The static files and the ACME challenges are served after the middlewares of the entrypoint, its authentication
+	// and whitelist included, and only ahead of its frontends and redirect.
+	if staticFiles := server.globalConfiguration.EntryPoints[newServerEntryPointName].StaticFiles; len(staticFiles) > 0 {
+		files := make(map[string][]byte, len(staticFiles))
+		for path, file := range staticFiles {
+			content, err := file.Read()
+			if err != nil {
+				log.Fatalf("Error reading static file %s: %s", path, err)
+			}
+			files[path] = content
+		}
+		serverMiddlewares = append(serverMiddlewares, middlewares.NewStaticFiles(files))
+	}
+	if acmeConfig := server.globalConfiguration.ACME; acmeConfig != nil && acmeConfig.HTTPChallenge != nil && acmeConfig.HTTPChallenge.EntryPoint == newServerEntryPointName {
+		serverMiddlewares = append(serverMiddlewares, negroni.HandlerFunc(acmeConfig.HTTPChallengeHandler()))
+	}
 	newsrv, err := server.prepareServer(newServerEntryPointName, newServerEntryPoint.httpRouter, server.globalConfiguration.EntryPoints[newServerEntryPointName], serverMiddlewares...)
 	if err != nil {
 		log.Fatal("Error preparing server: ", err)
@@ -236,8 +361,12 @@ func (server *Server) setupServerEntryPoint(newServerEntryPointName string, newS
 }
 
 func (server *Server) listenProviders(stop chan bool) {
-	lastReceivedConfiguration := safe.New(time.Unix(0, 0))
-	lastConfigs := cmap.New()
+	throttles := make(map[string]*providerThrottle)
+	defer func() {
+		for _, throttle := range throttles {
+			throttle.discard()
+		}
+	}()
 	for {
 		select {
 		case <-stop:
@@ -250,37 +379,44 @@ func (server *Server) listenProviders(stop chan bool) {
 			currentConfigurations := server.currentConfigurations.Get().(configs)
 			jsonConf, _ := json.Marshal(configMsg.Configuration)
 			log.Debugf("Configuration received from provider %s: %s", configMsg.ProviderName, string(jsonConf))
+			throttle, ok := throttles[configMsg.ProviderName]
+			if !ok {
+				throttle = server.newProviderThrottle(configMsg.ProviderName)
+				throttles[configMsg.ProviderName] = throttle
+			}
 			if configMsg.Configuration == nil || configMsg.Configuration.Backends == nil && configMsg.Configuration.Frontends == nil {
 				log.Infof("Skipping empty Configuration for provider %s", configMsg.ProviderName)
 			} else if reflect.DeepEqual(currentConfigurations[configMsg.ProviderName], configMsg.Configuration) {
 				log.Infof("Skipping same configuration for provider %s", configMsg.ProviderName)
+				// The configuration waiting to be applied, if any, is superseded by the current one.
+				throttle.discard()
 			} else {
-				lastConfigs.Set(configMsg.ProviderName, &configMsg)
-				lastReceivedConfigurationValue := lastReceivedConfiguration.Get().(time.Time)
-				providersThrottleDuration := time.Duration(server.globalConfiguration.ProvidersThrottleDuration)
-				if time.Now().After(lastReceivedConfigurationValue.Add(providersThrottleDuration)) {
-					log.Debugf("Last %s config received more than %s, OK", configMsg.ProviderName, server.globalConfiguration.ProvidersThrottleDuration.String())
-					// last config received more than n s ago
-					server.configurationValidatedChan <- configMsg
-				} else {
-					log.Debugf("Last %s config received less than %s, waiting...", configMsg.ProviderName, server.globalConfiguration.ProvidersThrottleDuration.String())
-					safe.Go(func() {
-						<-time.After(providersThrottleDuration)
-						lastReceivedConfigurationValue := lastReceivedConfiguration.Get().(time.Time)
-						if time.Now().After(lastReceivedConfigurationValue.Add(time.Duration(providersThrottleDuration))) {
-							log.Debugf("Waited for %s config, OK", configMsg.ProviderName)
-							if lastConfig, ok := lastConfigs.Get(configMsg.ProviderName); ok {
-								server.configurationValidatedChan <- *lastConfig.(*types.ConfigMessage)
-							}
-						}
-					})
-				}
-				lastReceivedConfiguration.Set(time.Now())
+				throttle.receive(configMsg)
 			}
 		}
 	}
 }
 
+// newProviderThrottle creates the throttle of the configurations of the named provider, with its own durations
+// if it overrides the global one.
+func (server *Server) newProviderThrottle(providerName string) *providerThrottle {
+	throttle := time.Duration(server.globalConfiguration.ProvidersThrottleDuration)
+	var maxThrottle time.Duration
+	if throttled, ok := server.providersByName[providerName].(provider.Throttled); ok {
+		var overridden time.Duration
+		overridden, maxThrottle = throttled.GetThrottleDurations()
+		if overridden > 0 {
+			throttle = overridden
+		}
+	}
+	if maxThrottle <= 0 {
+		maxThrottle = defaultMaxThrottleFactor * throttle
+	}
+	return newProviderThrottle(throttle, maxThrottle, func(configMsg types.ConfigMessage) {
+		server.configurationValidatedChan <- configMsg
+	})
+}
+
 func (server *Server) defaultConfigurationValues(configuration *types.Configuration) {
 	if configuration == nil || configuration.Frontends == nil {
 		return
@@ -307,14 +443,18 @@ func (server *Server) listenConfigurations(stop chan bool) {
 			}
 			newConfigurations[configMsg.ProviderName] = configMsg.Configuration
 
+			// The routers of the entry points are fully built before replacing the current ones at once,
+			// the requests in flight completing on the previous routers: no request hits a partial configuration.
 			newServerEntryPoints, err := server.loadConfig(newConfigurations, server.globalConfiguration)
 			if err == nil {
 				for newServerEntryPointName, newServerEntryPoint := range newServerEntryPoints {
-					server.serverEntryPoints[newServerEntryPointName].httpRouter.UpdateHandler(newServerEntryPoint.httpRouter.GetHandler())
+					server.serverEntryPoints[newServerEntryPointName].update(newServerEntryPoint)
 					log.Infof("Server configuration reloaded on %s", server.serverEntryPoints[newServerEntryPointName].httpServer.Addr)
 				}
 				server.currentConfigurations.Set(newConfigurations)
+				atomic.StoreInt32(&server.configured, 1)
 				server.postLoadConfig()
+				server.drainRemovedBackendsMetrics(newConfigurations)
 			} else {
 				log.Error("Error loading new configuration, aborted ", err)
 			}
@@ -330,6 +470,7 @@ func (server *Server) postLoadConfig() {
 		return
 	}
 	if server.globalConfiguration.ACME.OnHostRule {
+		var hostRuleDomains [][]string
 		currentConfigurations := server.currentConfigurations.Get().(configs)
 		for _, configuration := range currentConfigurations {
 			for _, frontend := range configuration.Frontends {
@@ -351,65 +492,84 @@ func (server *Server) postLoadConfig() {
 						if err != nil {
 							log.Errorf("Error parsing domains: %v", err)
 						} else {
-							server.globalConfiguration.ACME.LoadCertificateForDomains(domains)
+							var acmeDomains []string
+							for _, domain := range domains {
+								if types.IsWildcardDomain(domain) {
+									log.Debugf("Skipping ACME certificate request for wildcard domain %s", domain)
+									continue
+								}
+								acmeDomains = append(acmeDomains, domain)
+							}
+							hostRuleDomains = append(hostRuleDomains, acmeDomains)
+							server.globalConfiguration.ACME.LoadCertificateForDomains(acmeDomains)
 						}
 					}
 				}
 			}
 		}
+		server.globalConfiguration.ACME.UpdateHostRuleDomains(hostRuleDomains)
 	}
 }
 
 func (server *Server) configureProviders() {
 	// configure providers
 	if server.globalConfiguration.Docker != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Docker)
+		server.addProvider("docker", server.globalConfiguration.Docker)
 	}
 	if server.globalConfiguration.Marathon != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Marathon)
+		server.addProvider("marathon", server.globalConfiguration.Marathon)
 	}
 	if server.globalConfiguration.File != nil {
-		server.providers = append(server.providers, server.globalConfiguration.File)
+		server.addProvider("file", server.globalConfiguration.File)
 	}
 	if server.globalConfiguration.Web != nil {
 		server.globalConfiguration.Web.server = server
-		server.providers = append(server.providers, server.globalConfiguration.Web)
+		server.addProvider("web", server.globalConfiguration.Web)
 	}
 	if server.globalConfiguration.Consul != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Consul)
+		server.addProvider("consul", server.globalConfiguration.Consul)
 	}
 	if server.globalConfiguration.ConsulCatalog != nil {
-		server.providers = append(server.providers, server.globalConfiguration.ConsulCatalog)
+		server.addProvider("consul_catalog", server.globalConfiguration.ConsulCatalog)
 	}
 	if server.globalConfiguration.Etcd != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Etcd)
+		server.addProvider("etcd", server.globalConfiguration.Etcd)
 	}
 	if server.globalConfiguration.Zookeeper != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Zookeeper)
+		server.addProvider("zk", server.globalConfiguration.Zookeeper)
 	}
 	if server.globalConfiguration.Boltdb != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Boltdb)
+		server.addProvider("boltdb", server.globalConfiguration.Boltdb)
 	}
 	if server.globalConfiguration.Kubernetes != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Kubernetes)
+		server.addProvider("kubernetes", server.globalConfiguration.Kubernetes)
 	}
 	if server.globalConfiguration.Mesos != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Mesos)
+		server.addProvider("mesos", server.globalConfiguration.Mesos)
 	}
 	if server.globalConfiguration.Eureka != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Eureka)
+		server.addProvider("eureka", server.globalConfiguration.Eureka)
 	}
 	if server.globalConfiguration.ECS != nil {
-		server.providers = append(server.providers, server.globalConfiguration.ECS)
+		server.addProvider("ecs", server.globalConfiguration.ECS)
 	}
 	if server.globalConfiguration.Rancher != nil {
-		server.providers = append(server.providers, server.globalConfiguration.Rancher)
+		server.addProvider("rancher", server.globalConfiguration.Rancher)
 	}
 	if server.globalConfiguration.DynamoDB != nil {
-		server.providers = append(server.providers, server.globalConfiguration.DynamoDB)
+		server.addProvider("dynamodb", server.globalConfiguration.DynamoDB)
+	}
+	if server.globalConfiguration.HTTP != nil {
+		server.addProvider("http", server.globalConfiguration.HTTP)
 	}
 }
 
+// addProvider adds a provider, by the name of its configuration messages.
+func (server *Server) addProvider(name string, provider provider.Provider) {
+	server.providers = append(server.providers, provider)
+	server.providersByName[name] = provider
+}
+
 func (server *Server) startProviders() {
 	// start providers
 	for _, provider := range server.providers {
@@ -427,10 +587,64 @@ func (server *Server) startProviders() {
 }
 
 func (server *Server) listenSignals() {
-	sig := <-server.signals
-	log.Infof("I have to go... %+v", sig)
-	log.Info("Stopping server")
-	server.Stop()
+	for sig := range server.signals {
+		if sig == syscall.SIGHUP {
+			server.toggleDebugLogLevel()
+			continue
+		}
+		if isRotateLogsSignal(sig) {
+			server.rotateLogs()
+			continue
+		}
+		log.Infof("I have to go... %+v", sig)
+		log.Info("Stopping server")
+		server.Stop()
+		return
+	}
+}
+
+func isRotateLogsSignal(sig os.Signal) bool {
+	for _, rotateLogsSignal := range rotateLogsSignals {
+		if sig == rotateLogsSignal {
+			return true
+		}
+	}
+	return false
+}
+
+// rotateLogs reopens the access log and Traefik log files, for them to follow their paths after a log rotation.
+func (server *Server) rotateLogs() {
+	log.Info("Reopening the log files")
+	if server.accessLoggerMiddleware != nil {
+		if err := server.accessLoggerMiddleware.Rotate(); err != nil {
+			log.Errorf("Error rotating access log: %s", err)
+		}
+	}
+	if err := log.RotateFile(); err != nil {
+		log.Errorf("Error rotating Traefik log: %s", err)
+	}
+}
+
+// toggleDebugLogLevel switches the log level to DEBUG, or back to the configured level when it already is DEBUG.
+func (server *Server) toggleDebugLogLevel() {
+	level := logrus.DebugLevel.String()
+	if log.GetLevel() == logrus.DebugLevel {
+		level = server.globalConfiguration.LogLevel
+	}
+	if err := setLogLevel(level); err != nil {
+		log.Errorf("Error setting the log level to %s: %v", level, err)
+	}
+}
+
+// setLogLevel sets the level of the logs, given in any case, from the next log line on.
+func setLogLevel(level string) error {
+	logLevel, err := logrus.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return err
+	}
+	log.SetLevel(logLevel)
+	log.Infof("Log level set to %s", logLevel)
+	return nil
 }
 
 func createClientTLSConfig(tlsOption *TLS) (*tls.Config, error) {
@@ -471,8 +685,11 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 		return nil, err
 	}
 
-	// ensure http2 enabled
+	// ensure http2 enabled, unless disabled on the entrypoint
 	config.NextProtos = []string{"h2", "http/1.1"}
+	if server.globalConfiguration.EntryPoints[entryPointName].DisableHTTP2 {
+		config.NextProtos = []string{"http/1.1"}
+	}
 
 	if len(tlsOption.ClientCAFiles) > 0 {
 		pool := x509.NewCertPool()
@@ -517,6 +734,11 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 		} else {
 			return nil, errors.New("Unknown entrypoint " + server.globalConfiguration.ACME.EntryPoint + " for ACME configuration")
 		}
+		if httpChallenge := server.globalConfiguration.ACME.HTTPChallenge; httpChallenge != nil {
+			if _, ok := server.serverEntryPoints[httpChallenge.EntryPoint]; !ok {
+				return nil, errors.New("Unknown entrypoint " + httpChallenge.EntryPoint + " for ACME HTTP challenge")
+			}
+		}
 	}
 	if len(config.Certificates) == 0 {
 		return nil, errors.New("No certificates found for TLS entrypoint " + entryPointName)
@@ -524,6 +746,13 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 	// BuildNameToCertificate parses the CommonName and SubjectAlternateName fields
 	// in each certificate and populates the config.NameToCertificate map.
 	config.BuildNameToCertificate()
+	// NameToCertificate holds a single certificate per name, the store chooses between the ones of the names having several.
+	store := newCertificateStore(config.Certificates, config.GetCertificate)
+	if store.hasAlternatives() {
+		config.GetCertificate = store.getCertificate
+	} else {
+		store = nil
+	}
 	//Set the minimum TLS version if set in the config TOML
 	if minConst, exists := minVersion[server.globalConfiguration.EntryPoints[entryPointName].TLS.MinVersion]; exists {
 		config.PreferServerCipherSuites = true
@@ -543,22 +772,71 @@ func (server *Server) createTLSConfig(entryPointName string, tlsOption *TLS, rou
 		}
 	}
 
+	if tlsOption.OCSPStapling {
+		stapler := newOCSPStapler(config, store)
+		config.GetConfigForClient = stapler.getConfigForClient
+		server.routinesPool.Go(stapler.run)
+	}
+
+	// The keys are applied to the stapled configurations too.
+	if tlsOption.SessionTickets != nil {
+		ticketKeys, err := newSessionTicketKeys(tlsOption.SessionTickets)
+		if err != nil {
+			return nil, err
+		}
+		ticketKeys.apply(config)
+		server.routinesPool.Go(ticketKeys.run)
+	}
+
 	return config, nil
 }
 
-func (server *Server) startServer(srv *http.Server, globalConfiguration GlobalConfiguration) {
-	log.Infof("Starting server on %s", srv.Addr)
+// listenEntryPoint binds the address of the entrypoint, a host:port where the host is a local IP address
+// or a hostname resolved to one, or empty to listen on all of them, and limits its connections.
+func (server *Server) listenEntryPoint(entryPointName string, srv *http.Server, globalConfiguration GlobalConfiguration) (net.Listener, error) {
+	var maxConns int
+	if entryPoint, ok := globalConfiguration.EntryPoints[entryPointName]; ok {
+		maxConns = entryPoint.MaxConnections
+	}
+	listener, err := listen(srv)
+	if err != nil {
+		return nil, err
+	}
+	return newConnLimitListener(listener, entryPointName, maxConns, newEntryPointOpenConnsGauge(globalConfiguration)), nil
+}
+
+func (server *Server) startServer(serverEntryPoint *serverEntryPoint, listener net.Listener) {
+	log.Infof("Starting server on %s", listener.Addr())
+	srv := serverEntryPoint.httpServer
 	var err error
 	if srv.TLSConfig != nil {
-		err = srv.ListenAndServeTLS("", "")
+		// The TLS configuration is served as is, for the rotations of its session ticket keys to apply.
+		err = srv.Serve(tls.NewListener(listener, srv.TLSConfig))
 	} else {
-		err = srv.ListenAndServe()
+		err = serve(srv, listener, &serverEntryPoint.preserveHeaderCase)
 	}
 	if err != nil {
 		log.Error("Error creating server: ", err)
 	}
 }
 
+// listen binds the address of the server, defaulting and enabling the TCP keep-alives as http.Server.ListenAndServe
+// and ListenAndServeTLS.
+func listen(srv *http.Server) (net.Listener, error) {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+		if srv.TLSConfig != nil {
+			addr = ":https"
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tcpKeepAliveListener{listener.(*net.TCPListener)}, nil
+}
+
 func (server *Server) prepareServer(entryPointName string, router *middlewares.HandlerSwitcher, entryPoint *EntryPoint, middlewares ...negroni.Handler) (*http.Server, error) {
 	log.Infof("Preparing server %s %+v", entryPointName, entryPoint)
 	// middlewares
@@ -573,18 +851,46 @@ func (server *Server) prepareServer(entryPointName string, router *middlewares.H
 		return nil, err
 	}
 
-	return &http.Server{
-		Addr:        entryPoint.Address,
-		Handler:     negroni,
-		TLSConfig:   tlsConfig,
-		IdleTimeout: time.Duration(server.globalConfiguration.IdleTimeout),
-	}, nil
+	httpServer := &http.Server{
+		Addr:           entryPoint.Address,
+		Handler:        negroni,
+		TLSConfig:      tlsConfig,
+		IdleTimeout:    time.Duration(server.globalConfiguration.IdleTimeout),
+		MaxHeaderBytes: entryPoint.MaxHeaderBytes,
+	}
+	if entryPoint.DisableHTTP2 {
+		// net/http only configures its HTTP/2 server when no TLSNextProto is set
+		httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	} else if tlsConfig != nil && entryPoint.MaxConcurrentStreams > 0 {
+		err = http2.ConfigureServer(httpServer, &http2.Server{MaxConcurrentStreams: entryPoint.MaxConcurrentStreams})
+		if err != nil {
+			log.Errorf("Error configuring HTTP/2 on entrypoint %s: %s", entryPointName, err)
+			return nil, err
+		}
+	}
+	if tlsConfig != nil {
+		httpServer.ErrorLog = stdlog.New(&httpServerErrorLogger{
+			entryPointName:         entryPointName,
+			handshakeErrorsCounter: newTLSHandshakeErrorsCounter(server.globalConfiguration),
+			logHandshakeErrors:     entryPoint.TLS.LogHandshakeErrors,
+		}, "", 0)
+	}
+	return httpServer, nil
 }
 
 func (server *Server) buildEntryPoints(globalConfiguration GlobalConfiguration) map[string]*serverEntryPoint {
 	serverEntryPoints := make(map[string]*serverEntryPoint)
 	for entryPointName := range globalConfiguration.EntryPoints {
 		router := server.buildDefaultHTTPRouter()
+		if web := globalConfiguration.Web; web != nil && web.EntryPoint == entryPointName {
+			// The dashboard and API are routed ahead of the frontends, whatever their rules and priorities,
+			// unless served on the root path, routed like a frontend with a PathPrefix rule not to shadow all of them.
+			priority := math.MaxInt32
+			if web.getPath() == "/" {
+				priority = len("PathPrefix:/")
+			}
+			router.NewRoute().Name("web").PathPrefix(web.getPath()).Priority(priority).Handler(web)
+		}
 		serverEntryPoints[entryPointName] = &serverEntryPoint{
 			httpRouter: middlewares.NewHandlerSwitcher(router),
 		}
@@ -592,15 +898,162 @@ func (server *Server) buildEntryPoints(globalConfiguration GlobalConfiguration)
 	return serverEntryPoints
 }
 
-// clientTLSRoundTripper is used for forwarding client authentication to
-// backend server
-func clientTLSRoundTripper(config *tls.Config) http.RoundTripper {
-	if config == nil {
-		return http.DefaultTransport
+// newForwardHeaderRewriter returns the default request rewriter of the forwarders, setting the X-Forwarded headers.
+func newForwardHeaderRewriter() forward.ReqRewriter {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return &forward.HeaderRewriter{TrustForwardHeader: true, Hostname: hostname}
+}
+
+// createHTTPTransport creates the transport forwarding the requests to the backend servers
+// with the given timeouts, and the client authentication config when not nil.
+func createHTTPTransport(tlsConfig *tls.Config, timeouts ForwardingTimeouts, cache *dnsCache, disableKeepAlives bool) http.RoundTripper {
+	// The settings of http.DefaultTransport, with the dialer and the timeouts of the backend.
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           createDialer(timeouts, cache),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: time.Duration(timeouts.ResponseHeaderTimeout),
+		DisableKeepAlives:     disableKeepAlives,
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+		// Do not share the HTTP/2 connections of the default transport, established without the client certificate.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	if timeouts.ForwardingTimeout > 0 {
+		return middlewares.NewForwardingTimeoutRoundTripper(transport, time.Duration(timeouts.ForwardingTimeout))
+	}
+	return transport
+}
+
+// createGrpcTransport creates the transport of the requests forwarded to a gRPC backend, over HTTP/2:
+// in clear text (h2c) to the http servers, and over TLS to the https ones.
+// The response header timeout does not apply to gRPC backends.
+func createGrpcTransport(tlsConfig *tls.Config, timeouts ForwardingTimeouts, cache *dnsCache) http.RoundTripper {
+	dial := createDialer(timeouts, cache)
+	var transport http.RoundTripper = &grpcTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), network, addr)
+			},
+		},
+		h2: &http2.Transport{
+			TLSClientConfig: tlsConfig,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				conn, err := dial(context.Background(), network, addr)
+				if err != nil {
+					return nil, err
+				}
+				tlsConn := tls.Client(conn, cfg)
+				if err := tlsConn.Handshake(); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
+			},
+		},
+	}
+
+	if timeouts.ForwardingTimeout > 0 {
+		return middlewares.NewForwardingTimeoutRoundTripper(transport, time.Duration(timeouts.ForwardingTimeout))
+	}
+	return transport
+}
+
+// createDialer creates the dial function of the connections to the backend servers,
+// resolving their hostnames through the DNS cache if any.
+func createDialer(timeouts ForwardingTimeouts, cache *dnsCache) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(timeouts.DialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	if cache != nil {
+		return cache.dialContext(dialer)
+	}
+	return dialer.DialContext
+}
+
+type grpcTransport struct {
+	h2c http.RoundTripper
+	h2  http.RoundTripper
+}
+
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The forwarder removes the TE hop-by-hop header, which gRPC servers expect.
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = make(http.Header, len(req.Header)+1)
+	for name, values := range req.Header {
+		outReq.Header[name] = values
+	}
+	outReq.Header.Set("Te", "trailers")
+
+	if outReq.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(outReq)
+	}
+	return t.h2.RoundTrip(outReq)
+}
+
+// CloseIdleConnections closes the idle connections of the HTTP/2 transports.
+func (t *grpcTransport) CloseIdleConnections() {
+	for _, transport := range []http.RoundTripper{t.h2c, t.h2} {
+		if closer, ok := transport.(idleConnectionsCloser); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+}
+
+// getBackendProtocol returns the protocol spoken by the servers of the backend, http by default.
+func getBackendProtocol(backend *types.Backend) string {
+	if backend == nil || len(backend.Protocol) == 0 {
+		return "http"
 	}
-	return &http.Transport{
-		TLSClientConfig: config,
+	return backend.Protocol
+}
+
+// getForwardingTimeouts returns the timeouts of the requests forwarded to the backend,
+// its own timeouts overriding the global ones.
+func getForwardingTimeouts(backendName string, backend *types.Backend, globalTimeouts *ForwardingTimeouts) ForwardingTimeouts {
+	timeouts := ForwardingTimeouts{DialTimeout: flaeg.Duration(DefaultDialTimeout)}
+	if globalTimeouts != nil {
+		timeouts = *globalTimeouts
+	}
+	if backend == nil || backend.ForwardingTimeouts == nil {
+		return timeouts
+	}
+
+	overrides := []struct {
+		name  string
+		value string
+		field *flaeg.Duration
+	}{
+		{name: "dial timeout", value: backend.ForwardingTimeouts.DialTimeout, field: &timeouts.DialTimeout},
+		{name: "response header timeout", value: backend.ForwardingTimeouts.ResponseHeaderTimeout, field: &timeouts.ResponseHeaderTimeout},
+		{name: "forwarding timeout", value: backend.ForwardingTimeouts.ForwardingTimeout, field: &timeouts.ForwardingTimeout},
+	}
+	for _, override := range overrides {
+		if override.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(override.value)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal %s for backend '%s': %s", override.name, backendName, err)
+		case duration < 0:
+			log.Errorf("Negative %s for backend '%s'", override.name, backendName)
+		default:
+			*override.field = flaeg.Duration(duration)
+		}
 	}
+	return timeouts
 }
 
 // LoadConfig returns a new gorilla.mux Route from the specified global configuration and the dynamic
@@ -610,9 +1063,14 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 	redirectHandlers := make(map[string]negroni.Handler)
 	backends := map[string]http.Handler{}
 	backendsHealthcheck := map[string]*healthcheck.BackendHealthCheck{}
+	loadBalancers := map[string][]healthcheck.LoadBalancer{}
 	errorHandler := NewRecordingErrorHandler(middlewares.DefaultNetErrorRecorder{})
 
-	for _, configuration := range configurations {
+	for providerName, providerConfiguration := range configurations {
+		configuration, err := resolveConfigurationSecrets(providerConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving secrets of provider %s: %v", providerName, err)
+		}
 		frontendNames := sortedFrontendNamesForConfig(configuration)
 	frontend:
 		for _, frontendName := range frontendNames {
@@ -626,6 +1084,69 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 				continue frontend
 			}
 
+			for _, chainName := range frontend.Chains {
+				if _, ok := configuration.Chains[chainName]; !ok {
+					log.Errorf("Undefined chain '%s' for frontend %s", chainName, frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+			}
+
+			if frontend.Canary != nil {
+				if len(frontend.Canary.Backend) == 0 {
+					log.Errorf("No canary backend defined for frontend %s", frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+				if frontend.Canary.Percent < 0 || frontend.Canary.Percent > 100 {
+					log.Errorf("Invalid canary percentage %d for frontend %s", frontend.Canary.Percent, frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+			}
+
+			if frontend.Mirror != nil {
+				if len(frontend.Mirror.Backend) == 0 {
+					log.Errorf("No mirror backend defined for frontend %s", frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+				if frontend.Mirror.Percent < 0 || frontend.Mirror.Percent > 100 {
+					log.Errorf("Invalid mirror percentage %d for frontend %s", frontend.Mirror.Percent, frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+			}
+
+			switch frontend.TrailingSlash {
+			case "", types.TrailingSlashStrict, types.TrailingSlashEquivalent, types.TrailingSlashRedirect:
+			default:
+				log.Errorf("Invalid trailing slash mode %q for frontend %s", frontend.TrailingSlash, frontendName)
+				log.Errorf("Skipping frontend %s...", frontendName)
+				continue frontend
+			}
+
+			// The fallback route matches the requests of the frontend but for their path, with the lowest priority.
+			var fallbackRules []string
+			if len(frontend.FallbackBackend) > 0 {
+				for _, route := range frontend.Routes {
+					rule, err := (&Rules{}).ParseWithoutPaths(route.Rule)
+					if err != nil {
+						log.Errorf("Error creating fallback route for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					if len(rule) > 0 {
+						fallbackRules = append(fallbackRules, rule)
+					}
+				}
+				if len(fallbackRules) == 0 {
+					log.Errorf("Fallback backend of frontend %s requires a rule not matching the path, such as Host", frontendName)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
+			}
+
 			for _, entryPointName := range frontend.EntryPoints {
 				log.Debugf("Wiring frontend %s to entryPoint %s", frontendName, entryPointName)
 				if _, ok := serverEntryPoints[entryPointName]; !ok {
@@ -634,9 +1155,9 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 					continue frontend
 				}
 
-				newServerRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName)}
+				newServerRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName), passOriginalURI: frontend.PassOriginalURI, trailingSlash: frontend.TrailingSlash}
 				for routeName, route := range frontend.Routes {
-					err := getRoute(newServerRoute, &route)
+					err := getRoute(newServerRoute, &route, server.proxyChecker)
 					if err != nil {
 						log.Errorf("Error creating route for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
@@ -646,238 +1167,596 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 				}
 
 				entryPoint := globalConfiguration.EntryPoints[entryPointName]
-				negroni := negroni.New()
-				if entryPoint.Redirect != nil {
+				// The redirect is not part of the backend handler, as frontends sharing a backend may redirect differently.
+				var redirectHandler negroni.Handler
+				switch {
+				case frontend.Redirect != nil && frontend.Redirect.Disabled:
+					log.Debugf("Redirect of entrypoint %s disabled for frontend %s", entryPointName, frontendName)
+				case frontend.Redirect != nil && len(frontend.Redirect.EntryPoint) > 0:
+					if frontend.Redirect.EntryPoint == entryPointName {
+						break
+					}
+					handler, err := server.buildRedirectHandler(entryPointName, &Redirect{EntryPoint: frontend.Redirect.EntryPoint})
+					if err != nil {
+						log.Errorf("Error creating redirect for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					redirectHandler = handler
+					if server.accessLoggerMiddleware != nil {
+						redirectHandler = accesslog.NewSaveNegroniFrontend(handler, frontendName)
+					}
+				case entryPoint.Redirect != nil:
 					if redirectHandlers[entryPointName] != nil {
-						negroni.Use(redirectHandlers[entryPointName])
-					} else if handler, err := server.loadEntryPointConfig(entryPointName, entryPoint); err != nil {
+						redirectHandler = redirectHandlers[entryPointName]
+					} else if handler, err := server.buildRedirectHandler(entryPointName, entryPoint.Redirect); err != nil {
 						log.Errorf("Error loading entrypoint configuration for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
 					} else {
+						redirectHandler = handler
 						if server.accessLoggerMiddleware != nil {
-							saveFrontend := accesslog.NewSaveNegroniFrontend(handler, frontendName)
-							negroni.Use(saveFrontend)
-							redirectHandlers[entryPointName] = saveFrontend
-						} else {
-							negroni.Use(handler)
-							redirectHandlers[entryPointName] = handler
+							redirectHandler = accesslog.NewSaveNegroniFrontend(handler, frontendName)
 						}
+						redirectHandlers[entryPointName] = redirectHandler
 					}
 				}
-				if backends[entryPointName+frontend.Backend] == nil {
-					log.Debugf("Creating backend %s", frontend.Backend)
+				rejections := frontend.Rejections
+				if rejections == nil {
+					rejections = &types.RejectionResponses{}
+				}
+
+				bypasses, err := getBypassPatterns(frontend.Bypasses)
+				if err != nil {
+					log.Errorf("Error creating bypasses for frontend %s: %v", frontendName, err)
+					log.Errorf("Skipping frontend %s...", frontendName)
+					continue frontend
+				}
 
-					var (
-						tlsConfig *tls.Config
-						err       error
-						lb        http.Handler
-					)
+				var retryBackoff *middlewares.RetryBackoff
+				if globalConfiguration.Retry != nil && frontend.RetryBackoff != nil {
+					backoff := getRetryBackoff(frontendName, frontend.RetryBackoff, globalConfiguration.Retry)
+					retryBackoff = &backoff
+				}
 
-					if frontend.PassTLSCert {
-						tlsConfig, err = createClientTLSConfig(entryPoint.TLS)
+				// The middlewares of the frontend wrap its handlers rather than the backend handlers, which are shared
+				// with the other frontends of the entrypoint using the same backends.
+				var frontendMiddlewares []negroni.Handler
+				for _, errorPage := range frontend.Errors {
+					if configuration.Backends[errorPage.Backend] != nil && configuration.Backends[errorPage.Backend].Servers["error"].URL != "" {
+						errorPageHandler, err := middlewares.NewErrorPagesHandler(errorPage, configuration.Backends[errorPage.Backend].Servers["error"].URL)
 						if err != nil {
-							log.Errorf("Failed to create TLS config for frontend %s: %v", frontendName, err)
-							continue frontend
+							log.Errorf("Error creating custom error page middleware, %v", err)
+						} else {
+							frontendMiddlewares = append(frontendMiddlewares, errorPageHandler)
 						}
+					} else {
+						log.Errorf("Error Page is configured for Frontend %s, but either Backend %s is not set or Backend URL is missing", frontendName, errorPage.Backend)
+					}
+				}
+
+				ipWhitelistMiddleware, err := configureIPWhitelistMiddleware(frontend.WhitelistSourceRange, server.proxyChecker, rejections.Whitelist)
+				if err != nil {
+					log.Fatalf("Error creating IP Whitelister: %s", err)
+				} else if ipWhitelistMiddleware != nil {
+					frontendMiddlewares = append(frontendMiddlewares, middlewares.NewBypass(ipWhitelistMiddleware, bypassWhitelist, bypasses[bypassWhitelist]))
+					log.Infof("Configured IP Whitelists: %s", frontend.WhitelistSourceRange)
+				}
+
+				if len(frontend.BasicAuth) > 0 {
+					users := types.Users{}
+					for _, user := range frontend.BasicAuth {
+						users = append(users, user)
+					}
+
+					auth := &types.Auth{}
+					auth.Basic = &types.Basic{
+						Users: users,
+					}
+					authMiddleware, err := middlewares.NewAuthenticator(auth, rejections.Auth)
+					if err != nil {
+						log.Errorf("Error creating Auth: %s", err)
+					} else {
+						frontendMiddlewares = append(frontendMiddlewares, middlewares.NewBypass(authMiddleware, bypassAuth, bypasses[bypassAuth]))
 					}
+				}
 
-					// passing nil will use the roundtripper http.DefaultTransport
-					rt := clientTLSRoundTripper(tlsConfig)
+				if frontend.Headers.HasCustomHeadersDefined() {
+					log.Debugf("Adding header middleware for frontend %s", frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, middlewares.NewHeaderFromStruct(frontend.Headers))
+				}
+				if frontend.Headers.HasSecureHeadersDefined() {
+					secureMiddleware := middlewares.NewSecure(frontend.Headers)
+					log.Debugf("Adding secure middleware for frontend %s", frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, negroni.HandlerFunc(secureMiddleware.HandlerFuncWithNext))
+				}
 
-					fwd, err := forward.New(
-						forward.Logger(oxyLogger),
-						forward.PassHostHeader(frontend.PassHostHeader),
-						forward.RoundTripper(rt),
-						forward.ErrorHandler(errorHandler),
-					)
+				headerRewriteNames := make([]string, 0, len(frontend.HeaderRewrites))
+				for headerRewriteName := range frontend.HeaderRewrites {
+					headerRewriteNames = append(headerRewriteNames, headerRewriteName)
+				}
+				sort.Strings(headerRewriteNames)
+				for _, headerRewriteName := range headerRewriteNames {
+					headerRewrite := frontend.HeaderRewrites[headerRewriteName]
+					headerRewriteMiddleware, err := middlewares.NewHeaderRewrite(headerRewrite.Header, headerRewrite.Regex, headerRewrite.Replacement)
 					if err != nil {
-						log.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
+						log.Errorf("Error creating header rewrite %s: %v", headerRewriteName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
 					}
+					log.Debugf("Adding header rewrite %s for frontend %s", headerRewriteName, frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, headerRewriteMiddleware)
+				}
 
-					var rr *roundrobin.RoundRobin
-					var saveFrontend http.Handler
-					if server.accessLoggerMiddleware != nil {
-						saveBackend := accesslog.NewSaveBackend(fwd, frontend.Backend)
-						saveFrontend = accesslog.NewSaveFrontend(saveBackend, frontendName)
-						rr, _ = roundrobin.New(saveFrontend)
-					} else {
-						rr, _ = roundrobin.New(fwd)
+				if len(frontend.StatusRewrites) > 0 {
+					statusRewriteMiddleware, err := middlewares.NewStatusRewrite(frontend.StatusRewrites)
+					if err != nil {
+						log.Errorf("Error creating status rewrites: %v", err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
 					}
+					log.Debugf("Adding status rewrites %v for frontend %s", frontend.StatusRewrites, frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, statusRewriteMiddleware)
+				}
 
-					if configuration.Backends[frontend.Backend] == nil {
-						log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
+				for _, chainName := range frontend.Chains {
+					chainMiddlewares, err := server.buildChainMiddlewares(configuration.Chains[chainName], rejections)
+					if err != nil {
+						log.Errorf("Error creating chain %s: %v", chainName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
 					}
+					log.Debugf("Adding chain %s for frontend %s", chainName, frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, chainMiddlewares...)
+				}
 
-					lbMethod, err := types.NewLoadBalancerMethod(configuration.Backends[frontend.Backend].LoadBalancer)
+				if frontend.MaxInFlightReq != nil {
+					inFlightReqMiddleware, err := middlewares.NewInFlightReq(frontend.MaxInFlightReq.Amount, frontend.MaxInFlightReq.ExtractorFunc, server.proxyChecker, rejections.MaxInFlightReq)
 					if err != nil {
-						log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", configuration.Backends[frontend.Backend].LoadBalancer, frontendName, err)
+						log.Errorf("Error creating in-flight requests limiter for frontend %s: %v", frontendName, err)
 						log.Errorf("Skipping frontend %s...", frontendName)
 						continue frontend
 					}
+					log.Debugf("Limiting frontend %s to %d in-flight requests", frontendName, frontend.MaxInFlightReq.Amount)
+					frontendMiddlewares = append(frontendMiddlewares, middlewares.NewBypass(inFlightReqMiddleware, bypassMaxInFlightReq, bypasses[bypassMaxInFlightReq]))
+				}
 
-					stickysession := configuration.Backends[frontend.Backend].LoadBalancer.Sticky
-					cookiename := "_TRAEFIK_BACKEND_" + frontend.Backend
-					var sticky *roundrobin.StickySession
+				if frontend.Idempotency != nil {
+					idempotencyMiddleware, err := middlewares.NewIdempotency(frontend.Idempotency)
+					if err != nil {
+						log.Errorf("Error creating idempotency middleware for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
+					}
+					log.Debugf("Replaying the responses of frontend %s to the requests with the same idempotency key", frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, idempotencyMiddleware)
+				}
 
-					if stickysession {
-						sticky = roundrobin.NewStickySession(cookiename)
+				if frontend.Cache != nil {
+					cacheMiddleware, err := middlewares.NewResponseCache(frontend.Cache)
+					if err != nil {
+						log.Errorf("Error creating response cache for frontend %s: %v", frontendName, err)
+						log.Errorf("Skipping frontend %s...", frontendName)
+						continue frontend
 					}
+					log.Debugf("Caching the responses of frontend %s", frontendName)
+					frontendMiddlewares = append(frontendMiddlewares, cacheMiddleware)
+				}
 
-					switch lbMethod {
-					case types.Drr:
-						log.Debugf("Creating load-balancer drr")
-						rebalancer, _ := roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
-						if stickysession {
-							log.Debugf("Sticky session with cookie %v", cookiename)
-							rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
+				// The canary, mirror and fallback backends of the frontend, if any, are built along with its backend.
+				for _, backendName := range getFrontendBackendNames(frontend) {
+					backendFrontend := *frontend
+					backendFrontend.Backend = backendName
+					frontend := &backendFrontend
+
+					if backends[entryPointName+frontend.Backend] == nil {
+						log.Debugf("Creating backend %s", frontend.Backend)
+						negroni := negroni.New()
+
+						var (
+							tlsConfig *tls.Config
+							err       error
+							lb        http.Handler
+						)
+
+						if frontend.PassTLSCert {
+							tlsConfig, err = createClientTLSConfig(entryPoint.TLS)
+							if err != nil {
+								log.Errorf("Failed to create TLS config for frontend %s: %v", frontendName, err)
+								continue frontend
+							}
 						}
-						lb = rebalancer
-						if err := configureLBServers(rebalancer, configuration, frontend); err != nil {
+
+						timeouts := getForwardingTimeouts(frontend.Backend, configuration.Backends[frontend.Backend], globalConfiguration.ForwardingTimeouts)
+						var rt http.RoundTripper
+						switch protocol := getBackendProtocol(configuration.Backends[frontend.Backend]); protocol {
+						case "http":
+							backend := configuration.Backends[frontend.Backend]
+							rt = createHTTPTransport(tlsConfig, timeouts, server.dnsCache, backend != nil && backend.DisableKeepAlives)
+							if backend != nil && backend.HTTPFallback {
+								rt = middlewares.NewHTTPFallbackRoundTripper(rt)
+							}
+						case "grpc":
+							if backend := configuration.Backends[frontend.Backend]; backend != nil && backend.DisableKeepAlives {
+								log.Warnf("Keep-alive cannot be disabled for the gRPC backend %s", frontend.Backend)
+							}
+							rt = createGrpcTransport(tlsConfig, timeouts, server.dnsCache)
+						default:
+							log.Errorf("Unknown protocol '%s' for backend %s", protocol, frontend.Backend)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+						if transport, ok := rt.(idleConnectionsCloser); ok {
+							serverEntryPoints[entryPointName].transports = append(serverEntryPoints[entryPointName].transports, transport)
 						}
-						lb = middlewares.NewEmptyBackendHandler(rebalancer, lb)
-					case types.Wrr:
-						log.Debugf("Creating load-balancer wrr")
-						if stickysession {
-							log.Debugf("Sticky session with cookie %v", cookiename)
-							if server.accessLoggerMiddleware != nil {
-								rr, _ = roundrobin.New(saveFrontend, roundrobin.EnableStickySession(sticky))
-							} else {
-								rr, _ = roundrobin.New(fwd, roundrobin.EnableStickySession(sticky))
+						if backend := configuration.Backends[frontend.Backend]; backend != nil && backend.PreserveHeaderCase {
+							rt = middlewares.NewHeaderCaseRoundTripper(rt)
+							atomic.StoreInt32(&serverEntryPoints[entryPointName].preserveHeaderCase, 1)
+						}
+						if backend := configuration.Backends[frontend.Backend]; backend != nil && backend.AWSSigning != nil {
+							signingRoundTripper, err := middlewares.NewAWSSigningRoundTripper(rt, backend.AWSSigning)
+							if err != nil {
+								log.Errorf("Error creating the AWS request signing of backend %s: %v", frontend.Backend, err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
 							}
+							rt = signingRoundTripper
+						}
+
+						// The forwarder is shared by the frontends of the backend: the rewriters send its custom Host,
+						// unless the frontend of the request overrides it, see middlewares.NewHostOverride.
+						var host string
+						if backend := configuration.Backends[frontend.Backend]; backend != nil {
+							host = backend.CustomHost
 						}
-						lb = rr
-						if err := configureLBServers(rr, configuration, frontend); err != nil {
+						httpRewriter := forward.Rewriter(middlewares.NewHostRewriter(newForwardHeaderRewriter(), host))
+						websocketRewriter := forward.WebsocketRewriter(middlewares.NewWebsocketSubprotocolsRewriter(middlewares.NewHostRewriter(nil, host)))
+
+						fwd, err := forward.New(
+							forward.Logger(oxyLogger),
+							forward.PassHostHeader(frontend.PassHostHeader),
+							forward.RoundTripper(rt),
+							forward.ErrorHandler(errorHandler),
+							httpRewriter,
+							websocketRewriter,
+						)
+						if err != nil {
+							log.Errorf("Error creating forwarder for frontend %s: %v", frontendName, err)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						hcOpts := parseHealthCheckOptions(rr, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
-						if hcOpts != nil {
-							log.Debugf("Setting up backend health check %s", *hcOpts)
-							backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+
+						var forwarder http.Handler = middlewares.NewServerDebug(middlewares.NewWebsocketSubprotocols(fwd))
+						if server.accessLoggerMiddleware != nil {
+							saveBackend := accesslog.NewSaveBackend(forwarder, frontend.Backend)
+							forwarder = accesslog.NewSaveFrontend(saveBackend, frontendName)
 						}
-						lb = middlewares.NewEmptyBackendHandler(rr, lb)
-					}
 
-					if len(frontend.Errors) > 0 {
-						for _, errorPage := range frontend.Errors {
-							if configuration.Backends[errorPage.Backend] != nil && configuration.Backends[errorPage.Backend].Servers["error"].URL != "" {
-								errorPageHandler, err := middlewares.NewErrorPagesHandler(errorPage, configuration.Backends[errorPage.Backend].Servers["error"].URL)
-								if err != nil {
-									log.Errorf("Error creating custom error page middleware, %v", err)
-								} else {
-									negroni.Use(errorPageHandler)
+						if configuration.Backends[frontend.Backend] == nil {
+							log.Errorf("Undefined backend '%s' for frontend %s", frontend.Backend, frontendName)
+							log.Errorf("Skipping frontend %s...", frontendName)
+							continue frontend
+						}
+
+						var drainer *middlewares.ServerDrainer
+						if drain := configuration.Backends[frontend.Backend].Drain; drain != nil {
+							var timeout time.Duration
+							if len(drain.Timeout) > 0 {
+								timeout, err = time.ParseDuration(drain.Timeout)
+								if err != nil {
+									log.Errorf("Error parsing the drain timeout of backend %s: %v", frontend.Backend, err)
+									log.Errorf("Skipping frontend %s...", frontendName)
+									continue frontend
 								}
-							} else {
-								log.Errorf("Error Page is configured for Frontend %s, but either Backend %s is not set or Backend URL is missing", frontendName, errorPage.Backend)
 							}
+							drainer = middlewares.NewServerDrainer(forwarder, drain.Header, timeout, getServerWeights(configuration.Backends[frontend.Backend]))
+							forwarder = drainer
 						}
-					}
+						rr, _ := roundrobin.New(forwarder)
 
-					maxConns := configuration.Backends[frontend.Backend].MaxConn
-					if maxConns != nil && maxConns.Amount != 0 {
-						extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
+						lbMethod, err := types.NewLoadBalancerMethod(configuration.Backends[frontend.Backend].LoadBalancer)
 						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
+							log.Errorf("Error loading load balancer method '%+v' for frontend %s: %v", configuration.Backends[frontend.Backend].LoadBalancer, frontendName, err)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-						log.Debugf("Creating load-balancer connlimit")
-						lb, err = connlimit.New(lb, extractFunc, maxConns.Amount, connlimit.Logger(oxyLogger))
-						if err != nil {
-							log.Errorf("Error creating connlimit: %v", err)
+
+						stickysession := configuration.Backends[frontend.Backend].LoadBalancer.Sticky
+						cookiename := "_TRAEFIK_BACKEND_" + frontend.Backend
+						var sticky *roundrobin.StickySession
+
+						if stickysession {
+							sticky = roundrobin.NewStickySession(cookiename)
+						}
+						loadBalancer := configuration.Backends[frontend.Backend].LoadBalancer
+						if len(loadBalancer.StickyHeader) > 0 && len(loadBalancer.StickyCookie) > 0 {
+							log.Errorf("The requests of backend %s can be pinned by either a sticky header or a sticky cookie", frontend.Backend)
 							log.Errorf("Skipping frontend %s...", frontendName)
 							continue frontend
 						}
-					}
-
-					metrics := newMetrics(server.globalConfiguration, frontend.Backend)
-
-					if globalConfiguration.Retry != nil {
-						retryListener := middlewares.NewMetricsRetryListener(metrics)
-						lb = registerRetryMiddleware(lb, globalConfiguration, configuration, frontend.Backend, retryListener)
-					}
-					if metrics != nil {
-						negroni.Use(middlewares.NewMetricsWrapper(metrics))
-					}
-
-					ipWhitelistMiddleware, err := configureIPWhitelistMiddleware(frontend.WhitelistSourceRange)
-					if err != nil {
-						log.Fatalf("Error creating IP Whitelister: %s", err)
-					} else if ipWhitelistMiddleware != nil {
-						negroni.Use(ipWhitelistMiddleware)
-						log.Infof("Configured IP Whitelists: %s", frontend.WhitelistSourceRange)
-					}
+						localWeights := getLocalServerWeights(configuration.Backends[frontend.Backend], globalConfiguration.Zone)
+
+						switch lbMethod {
+						case types.Drr:
+							log.Debugf("Creating load-balancer drr")
+							rebalancer, _ := roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger))
+							if stickysession {
+								log.Debugf("Sticky session with cookie %v", cookiename)
+								rebalancer, _ = roundrobin.NewRebalancer(rr, roundrobin.RebalancerLogger(oxyLogger), roundrobin.RebalancerStickySession(sticky))
+							}
+							lb = rebalancer
+							if err := configureLBServers(rebalancer, configuration, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							loadBalancers[frontend.Backend] = append(loadBalancers[frontend.Backend], rebalancer)
+							if drainer != nil {
+								drainer.SetLoadBalancer(rebalancer)
+							}
+							hcOpts := parseHealthCheckOptions(rebalancer, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							if hcOpts != nil {
+								if hcOpts.SlowStart > 0 {
+									log.Warnf("Slow start is not supported by the drr load balancer of backend %s", frontend.Backend)
+									hcOpts.SlowStart = 0
+								}
+								hcOpts.Weights = getServerWeights(configuration.Backends[frontend.Backend])
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+							}
+							lb = wrapLoadBalancerAffinities(lb, rebalancer, loadBalancer, sticky, cookiename, localWeights, forwarder)
+						case types.Wrr:
+							log.Debugf("Creating load-balancer wrr")
+							if stickysession {
+								log.Debugf("Sticky session with cookie %v", cookiename)
+								rr, _ = roundrobin.New(forwarder, roundrobin.EnableStickySession(sticky))
+							}
+							lb = rr
+							if err := configureLBServers(rr, configuration, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							loadBalancers[frontend.Backend] = append(loadBalancers[frontend.Backend], rr)
+							if drainer != nil {
+								drainer.SetLoadBalancer(rr)
+							}
+							hcOpts := parseHealthCheckOptions(rr, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							if hcOpts != nil {
+								hcOpts.Weights = getServerWeights(configuration.Backends[frontend.Backend])
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+							}
+							lb = wrapLoadBalancerAffinities(lb, rr, loadBalancer, sticky, cookiename, localWeights, forwarder)
+						case types.Ewma:
+							log.Debugf("Creating load-balancer ewma")
+							if stickysession {
+								log.Debugf("Sticky session with cookie %v", cookiename)
+							}
+							balancer := middlewares.NewEWMABalancer(forwarder, sticky)
+							lb = balancer
+							if err := configureLBServers(balancer, configuration, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							loadBalancers[frontend.Backend] = append(loadBalancers[frontend.Backend], balancer)
+							if drainer != nil {
+								drainer.SetLoadBalancer(balancer)
+							}
+							hcOpts := parseHealthCheckOptions(balancer, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							if hcOpts != nil {
+								hcOpts.Weights = getServerWeights(configuration.Backends[frontend.Backend])
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+							}
+							lb = wrapLoadBalancerAffinities(lb, balancer, loadBalancer, sticky, cookiename, localWeights, forwarder)
+						case types.Adaptive:
+							log.Debugf("Creating load-balancer adaptive")
+							if stickysession {
+								log.Debugf("Sticky session with cookie %v", cookiename)
+							}
+							balancer := middlewares.NewAdaptiveBalancer(forwarder, sticky, configuration.Backends[frontend.Backend].LoadBalancer.LoadHeader)
+							lb = balancer
+							if err := configureLBServers(balancer, configuration, frontend); err != nil {
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							loadBalancers[frontend.Backend] = append(loadBalancers[frontend.Backend], balancer)
+							if drainer != nil {
+								drainer.SetLoadBalancer(balancer)
+							}
+							hcOpts := parseHealthCheckOptions(balancer, frontend.Backend, configuration.Backends[frontend.Backend].HealthCheck, globalConfiguration.HealthCheck)
+							if hcOpts != nil {
+								hcOpts.Weights = getServerWeights(configuration.Backends[frontend.Backend])
+								log.Debugf("Setting up backend health check %s", *hcOpts)
+								backendsHealthcheck[frontend.Backend] = healthcheck.NewBackendHealthCheck(*hcOpts)
+							}
+							lb = wrapLoadBalancerAffinities(lb, balancer, loadBalancer, sticky, cookiename, localWeights, forwarder)
+						}
 
-					if len(frontend.BasicAuth) > 0 {
-						users := types.Users{}
-						for _, user := range frontend.BasicAuth {
-							users = append(users, user)
+						maxConns := configuration.Backends[frontend.Backend].MaxConn
+						if maxConns != nil && maxConns.Amount != 0 {
+							extractFunc, err := utils.NewExtractor(maxConns.ExtractorFunc)
+							if err != nil {
+								log.Errorf("Error creating connlimit: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							log.Debugf("Creating load-balancer connlimit")
+							if len(maxConns.Policy) > 0 {
+								lb, err = middlewares.NewMaxConnLimiter(lb, extractFunc, maxConns)
+							} else {
+								lb, err = connlimit.New(lb, extractFunc, maxConns.Amount, connlimit.Logger(oxyLogger), connlimit.ErrorHandler(middlewares.NewConnLimitErrorHandler()))
+							}
+							if err != nil {
+								log.Errorf("Error creating connlimit: %v", err)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
 						}
 
-						auth := &types.Auth{}
-						auth.Basic = &types.Basic{
-							Users: users,
+						metrics := newMetrics(server.globalConfiguration, frontend.Backend)
+
+						if globalConfiguration.Retry != nil {
+							retryListener := middlewares.NewMetricsRetryListener(metrics)
+							if server.retryAttemptsCounter != nil {
+								retryListener = middlewares.RetryListeners{retryListener, middlewares.NewCounterRetryListener(server.retryAttemptsCounter.With("backend", frontend.Backend))}
+							}
+							lb = registerRetryMiddleware(lb, globalConfiguration, configuration, frontendName, frontend.Backend, time.Duration(timeouts.ForwardingTimeout), retryListener)
 						}
-						authMiddleware, err := middlewares.NewAuthenticator(auth)
-						if err != nil {
-							log.Errorf("Error creating Auth: %s", err)
-						} else {
-							negroni.Use(authMiddleware)
+						if metrics != nil {
+							negroni.Use(middlewares.NewMetricsWrapper(metrics))
 						}
-					}
 
-					if frontend.Headers.HasCustomHeadersDefined() {
-						headerMiddleware := middlewares.NewHeaderFromStruct(frontend.Headers)
-						log.Debugf("Adding header middleware for frontend %s", frontendName)
-						negroni.Use(headerMiddleware)
-					}
-					if frontend.Headers.HasSecureHeadersDefined() {
-						secureMiddleware := middlewares.NewSecure(frontend.Headers)
-						log.Debugf("Adding secure middleware for frontend %s", frontendName)
-						negroni.UseFunc(secureMiddleware.HandlerFuncWithNext)
-					}
+						if configuration.Backends[frontend.Backend].GrpcWeb {
+							if getBackendProtocol(configuration.Backends[frontend.Backend]) != "grpc" {
+								log.Errorf("grpc-web translation requires the grpc protocol for backend %s", frontend.Backend)
+								log.Errorf("Skipping frontend %s...", frontendName)
+								continue frontend
+							}
+							log.Debugf("Translating grpc-web requests for backend %s", frontend.Backend)
+							negroni.Use(middlewares.NewGrpcWeb())
+						}
 
-					if configuration.Backends[frontend.Backend].CircuitBreaker != nil {
-						log.Debugf("Creating circuit breaker %s", configuration.Backends[frontend.Backend].CircuitBreaker.Expression)
-						cbreaker, err := middlewares.NewCircuitBreaker(lb, configuration.Backends[frontend.Backend].CircuitBreaker.Expression, cbreaker.Logger(oxyLogger))
-						if err != nil {
-							log.Errorf("Error creating circuit breaker: %v", err)
-							log.Errorf("Skipping frontend %s...", frontendName)
-							continue frontend
+						if configuration.Backends[frontend.Backend].CircuitBreaker != nil {
+							log.Debugf("Creating circuit breaker %s", configuration.Backends[frontend.Backend].CircuitBreaker.Expression)
+							cbreakerOptions := []cbreaker.CircuitBreakerOption{cbreaker.Logger(oxyLogger)}
+							if server.circuitBreakerTransitionsCounter != nil {
+								cbreakerOptions = append(cbreakerOptions, middlewares.CircuitBreakerTransitions(server.circuitBreakerTransitionsCounter.With("backend", frontend.Backend))...)
+							}
+							cbreaker, err := middlewares.NewCircuitBreaker(lb, configuration.Backends[frontend.Backend].CircuitBreaker.Expression, cbreakerOptions...)
+							if err != nil {
+								log.Warnf("Error creating circuit breaker for backend %s, disabling it: %v", frontend.Backend, err)
+								negroni.UseHandler(lb)
+							} else {
+								negroni.Use(cbreaker)
+							}
+						} else {
+							negroni.UseHandler(lb)
 						}
-						negroni.Use(cbreaker)
+						backends[entryPointName+frontend.Backend] = negroni
 					} else {
-						negroni.UseHandler(lb)
+						log.Debugf("Reusing backend %s", frontend.Backend)
 					}
-					backends[entryPointName+frontend.Backend] = negroni
-				} else {
-					log.Debugf("Reusing backend %s", frontend.Backend)
 				}
 				if frontend.Priority > 0 {
 					newServerRoute.route.Priority(frontend.Priority)
 				}
-				server.wireFrontendBackend(newServerRoute, backends[entryPointName+frontend.Backend])
+				handler := backends[entryPointName+frontend.Backend]
+				if frontend.Canary != nil {
+					handler = middlewares.NewCanary(handler, backends[entryPointName+frontend.Canary.Backend], frontend.Canary)
+				}
+				if frontend.Mirror != nil {
+					var mirror http.Handler = backends[entryPointName+frontend.Mirror.Backend]
+					if server.accessLoggerMiddleware != nil {
+						mirror = accesslog.NewDiscardedLogData(mirror)
+					}
+					handler = middlewares.NewMirror(handler, mirror, frontend.Mirror)
+				}
+				if rejections.MaxConn != nil {
+					handler = middlewares.NewMaxConnRejection(handler, rejections.MaxConn)
+				}
+				if retryBackoff != nil {
+					handler = middlewares.NewRetryBackoff(handler, *retryBackoff)
+				}
+				if len(frontendMiddlewares) > 0 {
+					n := negroni.New(frontendMiddlewares...)
+					n.UseHandler(handler)
+					handler = n
+				}
+				if len(frontend.Headers.HostOverride) > 0 {
+					handler = middlewares.NewHostOverride(handler, frontend.Headers.HostOverride)
+				}
+				maxRequestBodyBytes := getMaxRequestBodyBytes(entryPoint, frontend)
+				if maxRequestBodyBytes > 0 {
+					n := negroni.New()
+					n.Use(middlewares.NewBodyLimit(maxRequestBodyBytes))
+					n.UseHandler(handler)
+					handler = n
+				}
+				if frontend.ProblemDetails {
+					handler = middlewares.NewProblemDetailsHandler(handler)
+				}
+				if redirectHandler != nil {
+					n := negroni.New()
+					n.Use(redirectHandler)
+					n.UseHandler(handler)
+					handler = n
+				}
+				if frontend.RouteHeaders {
+					n := negroni.New()
+					n.Use(middlewares.NewRouteHeaders(frontendName, frontend.Backend))
+					n.UseHandler(handler)
+					handler = n
+				}
+				if frontend.DebugHeader {
+					n := negroni.New()
+					n.Use(middlewares.NewRoutingDebug(getFrontendRule(frontend)))
+					n.UseHandler(handler)
+					handler = n
+				}
+				server.wireFrontendBackend(newServerRoute, handler)
 
-				err := newServerRoute.route.GetError()
-				if err != nil {
+				if err := newServerRoute.route.GetError(); err != nil {
 					log.Errorf("Error building route: %s", err)
 				}
+
+				if len(fallbackRules) > 0 {
+					fallbackRoute := &serverRoute{route: serverEntryPoints[entryPointName].httpRouter.GetHandler().NewRoute().Name(frontendName + "-fallback"), passOriginalURI: frontend.PassOriginalURI}
+					for _, rule := range fallbackRules {
+						if err := getRoute(fallbackRoute, &types.Route{Rule: rule}, server.proxyChecker); err != nil {
+							log.Errorf("Error creating fallback route for frontend %s: %v", frontendName, err)
+							continue frontend
+						}
+					}
+					fallbackRoute.route.Priority(1)
+					var fallbackHandler http.Handler = backends[entryPointName+frontend.FallbackBackend]
+					if rejections.MaxConn != nil {
+						fallbackHandler = middlewares.NewMaxConnRejection(fallbackHandler, rejections.MaxConn)
+					}
+					if retryBackoff != nil {
+						fallbackHandler = middlewares.NewRetryBackoff(fallbackHandler, *retryBackoff)
+					}
+					if len(frontend.Headers.HostOverride) > 0 {
+						fallbackHandler = middlewares.NewHostOverride(fallbackHandler, frontend.Headers.HostOverride)
+					}
+					if len(frontendMiddlewares) > 0 {
+						n := negroni.New(frontendMiddlewares...)
+						n.UseHandler(fallbackHandler)
+						fallbackHandler = n
+					}
+					if maxRequestBodyBytes > 0 {
+						n := negroni.New()
+						n.Use(middlewares.NewBodyLimit(maxRequestBodyBytes))
+						n.UseHandler(fallbackHandler)
+						fallbackHandler = n
+					}
+					if frontend.ProblemDetails {
+						fallbackHandler = middlewares.NewProblemDetailsHandler(fallbackHandler)
+					}
+					if redirectHandler != nil {
+						n := negroni.New()
+						n.Use(redirectHandler)
+						n.UseHandler(fallbackHandler)
+						fallbackHandler = n
+					}
+					if frontend.RouteHeaders {
+						n := negroni.New()
+						n.Use(middlewares.NewRouteHeaders(frontendName, frontend.FallbackBackend))
+						n.UseHandler(fallbackHandler)
+						fallbackHandler = n
+					}
+					if frontend.DebugHeader {
+						n := negroni.New()
+						n.Use(middlewares.NewRoutingDebug(strings.Join(fallbackRules, ";")))
+						n.UseHandler(fallbackHandler)
+						fallbackHandler = n
+					}
+					server.wireFrontendBackend(fallbackRoute, fallbackHandler)
+				}
 			}
 		}
 	}
 	healthcheck.GetHealthCheck().SetBackendsConfiguration(server.routinesPool.Ctx(), backendsHealthcheck)
+	server.loadBalancers.Set(loadBalancers)
 	//sort routes
 	for _, serverEntryPoint := range serverEntryPoints {
 		serverEntryPoint.httpRouter.GetHandler().SortRoutes()
@@ -885,8 +1764,87 @@ func (server *Server) loadConfig(configurations configs, globalConfiguration Glo
 	return serverEntryPoints, nil
 }
 
+const (
+	serverStatusUp   = "up"
+	serverStatusDown = "down"
+)
+
+// getServerStatus returns whether the server of the named backend is up, or down for having been removed
+// from the load balancer by the backend health check.
+func getServerStatus(backendName string, server types.Server) string {
+	backendHealthCheck, ok := healthcheck.GetHealthCheck().GetBackend(backendName)
+	if !ok {
+		return serverStatusUp
+	}
+	serverURL, err := url.Parse(server.URL)
+	if err != nil || backendHealthCheck.IsServerUp(serverURL) {
+		return serverStatusUp
+	}
+	return serverStatusDown
+}
+
+// getServerWeights returns the configured weights of the servers of the backend, by URL.
+func getServerWeights(backend *types.Backend) map[string]int {
+	weights := make(map[string]int, len(backend.Servers))
+	for _, server := range backend.Servers {
+		weights[server.URL] = server.Weight
+	}
+	return weights
+}
+
+// The middlewares of a frontend that can be bypassed.
+const (
+	bypassAuth           = "auth"
+	bypassWhitelist      = "whitelist"
+	bypassMaxInFlightReq = "maxInFlightReq"
+)
+
+// getBypassPatterns returns the path patterns bypassing each middleware of the frontend, by middleware name.
+func getBypassPatterns(bypasses map[string]types.Bypass) (map[string][]string, error) {
+	patterns := make(map[string][]string)
+	for bypassName, bypass := range bypasses {
+		if len(bypass.Paths) == 0 || len(bypass.Middlewares) == 0 {
+			return nil, fmt.Errorf("bypass %s requires paths and middlewares", bypassName)
+		}
+		for _, pattern := range bypass.Paths {
+			if err := middlewares.ValidateBypassPattern(pattern); err != nil {
+				return nil, fmt.Errorf("bypass %s: %v", bypassName, err)
+			}
+		}
+		for _, middleware := range bypass.Middlewares {
+			switch middleware {
+			case bypassAuth, bypassWhitelist, bypassMaxInFlightReq:
+				patterns[middleware] = append(patterns[middleware], bypass.Paths...)
+			default:
+				return nil, fmt.Errorf("bypass %s: unknown middleware %q", bypassName, middleware)
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// getLocalServerWeights returns the configured weights of the servers of the backend located in the zone, by URL,
+// or nil when the zone is empty or all the servers of the backend, or none of them, are in it.
+func getLocalServerWeights(backend *types.Backend, zone string) map[string]int {
+	if len(zone) == 0 {
+		return nil
+	}
+	weights := make(map[string]int)
+	for _, server := range backend.Servers {
+		if server.Zone == zone {
+			weights[server.URL] = server.Weight
+		}
+	}
+	if len(weights) == 0 || len(weights) == len(backend.Servers) {
+		return nil
+	}
+	return weights
+}
+
 func configureLBServers(lb healthcheck.LoadBalancer, config *types.Configuration, frontend *types.Frontend) error {
-	for serverName, server := range config.Backends[frontend.Backend].Servers {
+	backend := config.Backends[frontend.Backend]
+	for _, serverName := range getServerNames(backend) {
+		server := backend.Servers[serverName]
 		u, err := url.Parse(server.URL)
 		if err != nil {
 			log.Errorf("Error parsing server URL %s: %v", server.URL, err)
@@ -901,10 +1859,69 @@ func configureLBServers(lb healthcheck.LoadBalancer, config *types.Configuration
 	return nil
 }
 
-func configureIPWhitelistMiddleware(whitelistSourceRanges []string) (negroni.Handler, error) {
+// getServerNames returns the names of the servers of the backend in the order they are added to its load balancer,
+// by URL then name when the servers of the backend are sorted, and in the random order of the map otherwise.
+func getServerNames(backend *types.Backend) []string {
+	names := make([]string, 0, len(backend.Servers))
+	for serverName := range backend.Servers {
+		names = append(names, serverName)
+	}
+	if backend.LoadBalancer != nil && backend.LoadBalancer.SortServers {
+		sort.Slice(names, func(i, j int) bool {
+			urlI, urlJ := backend.Servers[names[i]].URL, backend.Servers[names[j]].URL
+			if urlI != urlJ {
+				return urlI < urlJ
+			}
+			return names[i] < names[j]
+		})
+	}
+	return names
+}
+
+// setServerWeight applies the weight of a server to the load balancers of its backend at once, a zero weight taking
+// the server out of them. The weight holds until the next configuration reload rebuilds the load balancers.
+// The servers removed by the health check keep being out of the load balancers until they recover with this weight.
+func (server *Server) setServerWeight(backendName string, serverURL *url.URL, weight int) error {
+	backendHealthCheck, hasHealthCheck := healthcheck.GetHealthCheck().GetBackend(backendName)
+	if hasHealthCheck {
+		backendHealthCheck.SetServerWeight(serverURL, weight)
+	}
+
+	loadBalancers, _ := server.loadBalancers.Get().(map[string][]healthcheck.LoadBalancer)
+	for _, lb := range loadBalancers[backendName] {
+		if weight == 0 {
+			if !hasServer(lb, serverURL) {
+				continue
+			}
+			if err := lb.RemoveServer(serverURL); err != nil {
+				return err
+			}
+			continue
+		}
+		if hasHealthCheck && !backendHealthCheck.IsServerUp(serverURL) {
+			continue
+		}
+		if err := lb.UpsertServer(serverURL, roundrobin.Weight(weight)); err != nil {
+			return err
+		}
+	}
+	log.Infof("Weight of server %s of backend %s set to %d", serverURL, backendName, weight)
+	return nil
+}
+
+func hasServer(lb healthcheck.LoadBalancer, serverURL *url.URL) bool {
+	for _, u := range lb.Servers() {
+		if u.String() == serverURL.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func configureIPWhitelistMiddleware(whitelistSourceRanges []string, proxyChecker *types.ProxyChecker, rejection *types.RejectionResponse) (negroni.Handler, error) {
 	if len(whitelistSourceRanges) > 0 {
 		ipSourceRanges := whitelistSourceRanges
-		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(ipSourceRanges)
+		ipWhitelistMiddleware, err := middlewares.NewIPWhitelister(ipSourceRanges, proxyChecker, rejection)
 
 		if err != nil {
 			return nil, err
@@ -916,6 +1933,107 @@ func configureIPWhitelistMiddleware(whitelistSourceRanges []string) (negroni.Han
 	return nil, nil
 }
 
+// resolveConfigurationSecrets returns a copy of the configuration in which the secret references of the
+// basic auth users and of the AWS secret access keys are resolved, leaving the configuration, as exposed by the API, untouched.
+func resolveConfigurationSecrets(configuration *types.Configuration) (*types.Configuration, error) {
+	if configuration == nil {
+		return nil, nil
+	}
+	resolved := *configuration
+
+	resolved.Frontends = make(map[string]*types.Frontend, len(configuration.Frontends))
+	for frontendName, frontend := range configuration.Frontends {
+		resolvedFrontend := *frontend
+		users, err := secret.ResolveAll(frontend.BasicAuth)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth of frontend %s: %v", frontendName, err)
+		}
+		resolvedFrontend.BasicAuth = users
+		resolved.Frontends[frontendName] = &resolvedFrontend
+	}
+
+	resolved.Backends = make(map[string]*types.Backend, len(configuration.Backends))
+	for backendName, backend := range configuration.Backends {
+		if backend == nil || backend.AWSSigning == nil {
+			resolved.Backends[backendName] = backend
+			continue
+		}
+		resolvedBackend := *backend
+		awsSigning := *backend.AWSSigning
+		secretAccessKey, err := secret.Resolve(awsSigning.SecretAccessKey)
+		if err != nil {
+			return nil, fmt.Errorf("AWS secret access key of backend %s: %v", backendName, err)
+		}
+		awsSigning.SecretAccessKey = secretAccessKey
+		resolvedBackend.AWSSigning = &awsSigning
+		resolved.Backends[backendName] = &resolvedBackend
+	}
+
+	resolved.Chains = make(map[string]*types.Chain, len(configuration.Chains))
+	for chainName, chain := range configuration.Chains {
+		resolvedChain, err := resolveChainSecrets(chain)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth of chain %s: %v", chainName, err)
+		}
+		resolved.Chains[chainName] = resolvedChain
+	}
+	return &resolved, nil
+}
+
+// resolveChainSecrets returns a copy of the chain in which the secret references of the basic auth users are resolved.
+func resolveChainSecrets(chain *types.Chain) (*types.Chain, error) {
+	resolvedChain := &types.Chain{}
+	for _, middleware := range chain.Middlewares {
+		users, err := secret.ResolveAll(middleware.BasicAuth)
+		if err != nil {
+			return nil, err
+		}
+		middleware.BasicAuth = users
+		resolvedChain.Middlewares = append(resolvedChain.Middlewares, middleware)
+	}
+	return resolvedChain, nil
+}
+
+// buildChainMiddlewares returns the middlewares of a chain, in the order they are declared.
+// Their rejected requests are answered with the given rejection responses, if any.
+func (server *Server) buildChainMiddlewares(chain *types.Chain, rejections *types.RejectionResponses) ([]negroni.Handler, error) {
+	if rejections == nil {
+		rejections = &types.RejectionResponses{}
+	}
+
+	var handlers []negroni.Handler
+	for _, middleware := range chain.Middlewares {
+		ipWhitelistMiddleware, err := configureIPWhitelistMiddleware(middleware.WhitelistSourceRange, server.proxyChecker, rejections.Whitelist)
+		if err != nil {
+			return nil, err
+		}
+		if ipWhitelistMiddleware != nil {
+			handlers = append(handlers, ipWhitelistMiddleware)
+		}
+
+		if len(middleware.BasicAuth) > 0 {
+			auth := &types.Auth{
+				Basic: &types.Basic{
+					Users: types.Users(middleware.BasicAuth),
+				},
+			}
+			authMiddleware, err := middlewares.NewAuthenticator(auth, rejections.Auth)
+			if err != nil {
+				return nil, err
+			}
+			handlers = append(handlers, authMiddleware)
+		}
+
+		if middleware.Headers.HasCustomHeadersDefined() {
+			handlers = append(handlers, middlewares.NewHeaderFromStruct(middleware.Headers))
+		}
+		if middleware.Headers.HasSecureHeadersDefined() {
+			handlers = append(handlers, negroni.HandlerFunc(middlewares.NewSecure(middleware.Headers).HandlerFuncWithNext))
+		}
+	}
+	return handlers, nil
+}
+
 func (server *Server) wireFrontendBackend(serverRoute *serverRoute, handler http.Handler) {
 	// path replace - This needs to always be the very last on the handler chain (first in the order in this function)
 	// -- Replacing Path should happen at the very end of the Modifier chain, after all the Matcher+Modifiers ran
@@ -948,25 +2066,70 @@ func (server *Server) wireFrontendBackend(serverRoute *serverRoute, handler http
 		handler = middlewares.NewStripPrefixRegex(handler, serverRoute.stripPrefixesRegex)
 	}
 
+	// original URI - This needs to be the first on the handler chain, before any rewrite of the path
+	if serverRoute.passOriginalURI {
+		handler = &middlewares.ForwardedURI{Handler: handler}
+	}
+
+	// trailing slash redirect - This needs to be before anything else on the handler chain, the request being redirected
+	if len(serverRoute.slashRedirects) > 0 {
+		handler = middlewares.NewTrailingSlashRedirect(handler, serverRoute.slashRedirects)
+	}
+
 	serverRoute.route.Handler(handler)
 }
 
-func (server *Server) loadEntryPointConfig(entryPointName string, entryPoint *EntryPoint) (negroni.Handler, error) {
-	regex := entryPoint.Redirect.Regex
-	replacement := entryPoint.Redirect.Replacement
-	if len(entryPoint.Redirect.EntryPoint) > 0 {
+// buildRedirectHandler returns the handler redirecting the requests of the given entry point,
+// either to another entry point or with a regex replacement of their URL, depending on their path with rules.
+func (server *Server) buildRedirectHandler(entryPointName string, redirect *Redirect) (negroni.Handler, error) {
+	if len(redirect.Rules) == 0 {
+		return server.buildRewriteRedirectHandler(entryPointName, redirect)
+	}
+
+	var rules []middlewares.PathRedirectRule
+	for _, rule := range redirect.Rules {
+		if len(rule.PathPrefix) == 0 {
+			return nil, errors.New("Missing path prefix in redirect rule")
+		}
+		if len(rule.EntryPoint) == 0 && len(rule.Regex) == 0 {
+			return nil, errors.New("Missing entrypoint or regex in redirect rule for path prefix " + rule.PathPrefix)
+		}
+		handler, err := server.buildRewriteRedirectHandler(entryPointName, &Redirect{EntryPoint: rule.EntryPoint, Regex: rule.Regex, Replacement: rule.Replacement})
+		if err != nil {
+			return nil, fmt.Errorf("Error creating redirect rule for path prefix %s: %v", rule.PathPrefix, err)
+		}
+		rules = append(rules, middlewares.PathRedirectRule{PathPrefix: rule.PathPrefix, Handler: handler})
+	}
+
+	var defaultHandler negroni.Handler
+	if len(redirect.EntryPoint) > 0 || len(redirect.Regex) > 0 {
+		handler, err := server.buildRewriteRedirectHandler(entryPointName, redirect)
+		if err != nil {
+			return nil, err
+		}
+		defaultHandler = handler
+	}
+	return middlewares.NewPathRedirect(rules, defaultHandler), nil
+}
+
+// buildRewriteRedirectHandler returns the handler redirecting all the requests of the given entry point,
+// either to another entry point or with a regex replacement of their URL.
+func (server *Server) buildRewriteRedirectHandler(entryPointName string, redirect *Redirect) (negroni.Handler, error) {
+	regex := redirect.Regex
+	replacement := redirect.Replacement
+	if len(redirect.EntryPoint) > 0 {
 		regex = "^(?:https?:\\/\\/)?([\\w\\._-]+)(?::\\d+)?(.*)$"
-		if server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint] == nil {
-			return nil, errors.New("Unknown entrypoint " + entryPoint.Redirect.EntryPoint)
+		if server.globalConfiguration.EntryPoints[redirect.EntryPoint] == nil {
+			return nil, errors.New("Unknown entrypoint " + redirect.EntryPoint)
 		}
 		protocol := "http"
-		if server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].TLS != nil {
+		if server.globalConfiguration.EntryPoints[redirect.EntryPoint].TLS != nil {
 			protocol = "https"
 		}
 		r, _ := regexp.Compile("(:\\d+)")
-		match := r.FindStringSubmatch(server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].Address)
+		match := r.FindStringSubmatch(server.globalConfiguration.EntryPoints[redirect.EntryPoint].Address)
 		if len(match) == 0 {
-			return nil, errors.New("Bad Address format: " + server.globalConfiguration.EntryPoints[entryPoint.Redirect.EntryPoint].Address)
+			return nil, errors.New("Bad Address format: " + server.globalConfiguration.EntryPoints[redirect.EntryPoint].Address)
 		}
 		replacement = protocol + "://$1" + match[0] + "$2"
 	}
@@ -974,8 +2137,11 @@ func (server *Server) loadEntryPointConfig(entryPointName string, entryPoint *En
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Creating entryPoint redirect %s -> %s : %s -> %s", entryPointName, entryPoint.Redirect.EntryPoint, regex, replacement)
+	log.Debugf("Creating entryPoint redirect %s -> %s : %s -> %s", entryPointName, redirect.EntryPoint, regex, replacement)
 
+	if strings.HasPrefix(replacement, "https://") && server.globalConfiguration.EntryPoints[entryPointName].TrustForwardedProto {
+		return middlewares.NewSecureRedirect(rewrite, server.proxyChecker), nil
+	}
 	return rewrite, nil
 }
 
@@ -988,7 +2154,15 @@ func (server *Server) buildDefaultHTTPRouter() *mux.Router {
 }
 
 func parseHealthCheckOptions(lb healthcheck.LoadBalancer, backend string, hc *types.HealthCheck, hcConfig *HealthCheckConfig) *healthcheck.Options {
-	if hc == nil || hc.Path == "" || hcConfig == nil {
+	if hc == nil || hcConfig == nil {
+		return nil
+	}
+	mode := hc.Mode
+	if mode != "" && mode != healthcheck.ModeHTTP && mode != healthcheck.ModeTCP {
+		log.Errorf("Illegal healthcheck mode for backend '%s': %s, must be %s or %s", backend, mode, healthcheck.ModeHTTP, healthcheck.ModeTCP)
+		mode = ""
+	}
+	if mode != healthcheck.ModeTCP && hc.Path == "" {
 		return nil
 	}
 
@@ -1005,24 +2179,96 @@ func parseHealthCheckOptions(lb healthcheck.LoadBalancer, backend string, hc *ty
 		}
 	}
 
+	var slowStart time.Duration
+	if hc.SlowStart != "" {
+		slowStartOverride, err := time.ParseDuration(hc.SlowStart)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal healthcheck slow start for backend '%s': %s", backend, err)
+		case slowStartOverride < 0:
+			log.Errorf("Healthcheck slow start smaller than zero for backend '%s'", backend)
+		default:
+			slowStart = slowStartOverride
+		}
+	}
+
+	healthyThreshold := hc.HealthyThreshold
+	if healthyThreshold < 0 {
+		log.Errorf("Healthcheck healthy threshold smaller than zero for backend '%s'", backend)
+		healthyThreshold = 0
+	}
+	unhealthyThreshold := hc.UnhealthyThreshold
+	if unhealthyThreshold < 0 {
+		log.Errorf("Healthcheck unhealthy threshold smaller than zero for backend '%s'", backend)
+		unhealthyThreshold = 0
+	}
+
+	var timeout time.Duration
+	if hc.Timeout != "" {
+		timeoutOverride, err := time.ParseDuration(hc.Timeout)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal healthcheck timeout for backend '%s': %s", backend, err)
+		case timeoutOverride <= 0:
+			log.Errorf("Healthcheck timeout smaller than zero for backend '%s'", backend)
+		default:
+			timeout = timeoutOverride
+		}
+	}
+
 	return &healthcheck.Options{
-		Path:     hc.Path,
-		Interval: interval,
-		LB:       lb,
+		Mode:               mode,
+		Path:               hc.Path,
+		Query:              hc.Query,
+		Headers:            hc.Headers,
+		Interval:           interval,
+		LB:                 lb,
+		SlowStart:          slowStart,
+		HealthyThreshold:   healthyThreshold,
+		UnhealthyThreshold: unhealthyThreshold,
+		Timeout:            timeout,
 	}
 }
 
-func getRoute(serverRoute *serverRoute, route *types.Route) error {
-	rules := Rules{route: serverRoute}
+func getRoute(serverRoute *serverRoute, route *types.Route, proxyChecker *types.ProxyChecker) error {
+	rules := Rules{route: serverRoute, proxyChecker: proxyChecker}
 	newRoute, err := rules.Parse(route.Rule)
 	if err != nil {
 		return err
 	}
-	newRoute.Priority(serverRoute.route.GetPriority() + len(route.Rule))
+	priority := len(route.Rule)
+	if serverRoute.wildcardHost {
+		// Exact host rules take precedence over wildcard ones of the same length
+		priority--
+	}
+	newRoute.Priority(serverRoute.route.GetPriority() + priority)
 	serverRoute.route = newRoute
 	return nil
 }
 
+// getMaxRequestBodyBytes returns the maximum size of the request bodies of a frontend on an entry point:
+// the one of the frontend, when set, with no limit when negative, or else the one of the entry point.
+func getMaxRequestBodyBytes(entryPoint *EntryPoint, frontend *types.Frontend) int64 {
+	if frontend.MaxRequestBodyBytes != 0 {
+		return frontend.MaxRequestBodyBytes
+	}
+	return entryPoint.MaxRequestBodyBytes
+}
+
+// getFrontendRule returns the rule matched by the requests of a frontend, all the rules of its routes being matched.
+func getFrontendRule(frontend *types.Frontend) string {
+	var routeNames []string
+	for routeName := range frontend.Routes {
+		routeNames = append(routeNames, routeName)
+	}
+	sort.Strings(routeNames)
+	var rules []string
+	for _, routeName := range routeNames {
+		rules = append(rules, frontend.Routes[routeName].Rule)
+	}
+	return strings.Join(rules, ";")
+}
+
 func sortedFrontendNamesForConfig(configuration *types.Configuration) []string {
 	keys := []string{}
 	for key := range configuration.Frontends {
@@ -1041,19 +2287,36 @@ func (server *Server) configureFrontends(frontends map[string]*types.Frontend) {
 	}
 }
 
+// wrapLoadBalancerAffinities wraps the handler balancing the requests over the servers of the load balancer of a backend
+// with the affinities of its configuration pinning them to a server: the local zone, the sticky session cookie, and the
+// hash of a header or cookie, the outermost one being tried first. An empty load balancer answers its requests with
+// 503 Service Unavailable.
+func wrapLoadBalancerAffinities(handler http.Handler, balancer healthcheck.LoadBalancer, loadBalancer *types.LoadBalancer, sticky *roundrobin.StickySession, cookieName string, localWeights map[string]int, forwarder http.Handler) http.Handler {
+	if localWeights != nil {
+		handler = middlewares.NewZoneAffinity(balancer, sticky, localWeights, forwarder, handler)
+	}
+	if sticky != nil {
+		handler = middlewares.NewStickySessionFallback(balancer, cookieName, handler)
+	}
+	if len(loadBalancer.StickyHeader) > 0 || len(loadBalancer.StickyCookie) > 0 {
+		handler = middlewares.NewHeaderAffinity(balancer, loadBalancer.StickyHeader, loadBalancer.StickyCookie, loadBalancer.StickyVirtualNodes, forwarder, handler)
+	}
+	return middlewares.NewEmptyBackendHandler(balancer, handler)
+}
+
 func (*Server) configureBackends(backends map[string]*types.Backend) {
 	for backendName, backend := range backends {
 		_, err := types.NewLoadBalancerMethod(backend.LoadBalancer)
 		if err != nil {
 			log.Debugf("Validation of load balancer method for backend %s failed: %s. Using default method wrr.", backendName, err)
-			var sticky bool
+			loadBalancer := &types.LoadBalancer{Method: "wrr"}
 			if backend.LoadBalancer != nil {
-				sticky = backend.LoadBalancer.Sticky
-			}
-			backend.LoadBalancer = &types.LoadBalancer{
-				Method: "wrr",
-				Sticky: sticky,
+				loadBalancer.Sticky = backend.LoadBalancer.Sticky
+				loadBalancer.StickyHeader = backend.LoadBalancer.StickyHeader
+				loadBalancer.StickyCookie = backend.LoadBalancer.StickyCookie
+				loadBalancer.StickyVirtualNodes = backend.LoadBalancer.StickyVirtualNodes
 			}
+			backend.LoadBalancer = loadBalancer
 		}
 	}
 }
@@ -1091,6 +2354,112 @@ func newMetrics(globalConfig GlobalConfiguration, name string) middlewares.Metri
 	return nil
 }
 
+// newTLSHandshakeErrorsCounter returns the counter of the TLS handshake errors.
+// Note that given there is no Prometheus metrics configured, it will return nil.
+func newTLSHandshakeErrorsCounter(globalConfig GlobalConfiguration) gokitmetrics.Counter {
+	if globalConfig.Web == nil || globalConfig.Web.Metrics == nil || globalConfig.Web.Metrics.Prometheus == nil {
+		return nil
+	}
+	counter, _, err := middlewares.NewPrometheusTLSHandshakeErrorsCounter()
+	if err != nil {
+		log.Errorf("Error creating Prometheus TLS handshake errors counter: %s", err)
+		return nil
+	}
+	return counter
+}
+
+// newAccessLogDroppedCounter returns the counter of the access log entries dropped by the asynchronous writes.
+// Note that given there is no Prometheus metrics configured, it will return nil.
+func newAccessLogDroppedCounter(globalConfig GlobalConfiguration) gokitmetrics.Counter {
+	if globalConfig.Web == nil || globalConfig.Web.Metrics == nil || globalConfig.Web.Metrics.Prometheus == nil {
+		return nil
+	}
+	counter, _, err := middlewares.NewPrometheusAccessLogDroppedCounter()
+	if err != nil {
+		log.Errorf("Error creating Prometheus access log dropped entries counter: %s", err)
+		return nil
+	}
+	return counter
+}
+
+// newRetryAttemptsCounter returns the counter of the retried attempts of the requests.
+func newRetryAttemptsCounter() gokitmetrics.Counter {
+	counter, _, err := middlewares.NewPrometheusRetryAttemptsCounter()
+	if err != nil {
+		log.Errorf("Error creating Prometheus retry attempts counter: %s", err)
+		return nil
+	}
+	return counter
+}
+
+// newCircuitBreakerTransitionsCounter returns the counter of the transitions of the circuit breakers.
+func newCircuitBreakerTransitionsCounter() gokitmetrics.Counter {
+	counter, _, err := middlewares.NewPrometheusCircuitBreakerTransitionsCounter()
+	if err != nil {
+		log.Errorf("Error creating Prometheus circuit breaker transitions counter: %s", err)
+		return nil
+	}
+	return counter
+}
+
+// newPrometheusDrainer returns the drainer of the metrics of the removed backends.
+// Note that given there is no Prometheus drain policy configured, it will return nil.
+func newPrometheusDrainer(globalConfig GlobalConfiguration) *middlewares.PrometheusDrainer {
+	if globalConfig.Web == nil || globalConfig.Web.Metrics == nil || globalConfig.Web.Metrics.Prometheus == nil || globalConfig.Web.Metrics.Prometheus.Drain == nil {
+		return nil
+	}
+	drainer, err := middlewares.NewPrometheusDrainer(globalConfig.Web.Metrics.Prometheus)
+	if err != nil {
+		log.Errorf("Error creating Prometheus metrics drainer: %s", err)
+		return nil
+	}
+	return drainer
+}
+
+// drainRemovedBackendsMetrics drains the metrics of the backends no frontend of the configurations forwards to anymore.
+func (server *Server) drainRemovedBackendsMetrics(configurations configs) {
+	if server.prometheusDrainer == nil {
+		return
+	}
+	var backends []string
+	for _, configuration := range configurations {
+		for _, frontend := range configuration.Frontends {
+			backends = append(backends, getFrontendBackendNames(frontend)...)
+		}
+	}
+	server.prometheusDrainer.Update(backends)
+}
+
+// getFrontendBackendNames returns the names of the backends the frontend forwards to: its backend,
+// followed by its canary, mirror and fallback backends, if any.
+func getFrontendBackendNames(frontend *types.Frontend) []string {
+	backendNames := []string{frontend.Backend}
+	if frontend.Canary != nil {
+		backendNames = append(backendNames, frontend.Canary.Backend)
+	}
+	if frontend.Mirror != nil {
+		backendNames = append(backendNames, frontend.Mirror.Backend)
+	}
+	if len(frontend.FallbackBackend) > 0 {
+		backendNames = append(backendNames, frontend.FallbackBackend)
+	}
+	return backendNames
+}
+
+// newEntryPointOpenConnsGauge returns the gauge of the connections open on the entrypoints.
+// Note that given there is no Prometheus metrics configured, it will return nil.
+func newEntryPointOpenConnsGauge(globalConfig GlobalConfiguration) gokitmetrics.Gauge {
+	if globalConfig.Web == nil || globalConfig.Web.Metrics == nil || globalConfig.Web.Metrics.Prometheus == nil {
+		return nil
+	}
+	gauge, _, err := middlewares.NewPrometheusEntryPointOpenConnsGauge()
+	if err != nil {
+		log.Errorf("Error creating Prometheus entrypoint open connections gauge: %s", err)
+		return nil
+	}
+	return gauge
+}
+
 func initializeMetricsClients(globalConfig GlobalConfiguration) {
 	metricsEnabled := globalConfig.Web != nil && globalConfig.Web.Metrics != nil
 	if metricsEnabled {
@@ -1112,16 +2481,68 @@ func registerRetryMiddleware(
 	httpHandler http.Handler,
 	globalConfig GlobalConfiguration,
 	config *types.Configuration,
-	backend string,
+	frontendName string,
+	backendName string,
+	timeout time.Duration,
 	listener middlewares.RetryListener,
 ) http.Handler {
-	retries := len(config.Backends[backend].Servers)
+	retries := len(config.Backends[backendName].Servers)
 	if globalConfig.Retry.Attempts > 0 {
 		retries = globalConfig.Retry.Attempts
 	}
 
-	httpHandler = middlewares.NewRetry(retries, httpHandler, listener)
+	// The retry middleware is shared by the frontends of the backend, the ones with their own backoff
+	// overriding the global one for their requests.
+	backoff := getRetryBackoff(frontendName, nil, globalConfig.Retry)
+	httpHandler = middlewares.NewRetryWithBackoff(retries, backoff, timeout, httpHandler, listener)
 	log.Debugf("Creating retries max attempts %d", retries)
 
 	return httpHandler
 }
+
+// getRetryBackoff returns the backoff between the retried attempts of the requests of the frontend,
+// its own retryBackoff, if any, overriding the global one.
+func getRetryBackoff(frontendName string, retryBackoff *types.RetryBackoff, globalRetry *Retry) middlewares.RetryBackoff {
+	backoff := middlewares.RetryBackoff{
+		Interval:    time.Duration(globalRetry.BackoffInterval),
+		MaxInterval: time.Duration(globalRetry.MaxBackoffInterval),
+	}
+	mode := globalRetry.Backoff
+	if retryBackoff != nil && retryBackoff.Mode != "" {
+		mode = retryBackoff.Mode
+	}
+	switch mode {
+	case "", "constant":
+	case "exponential":
+		backoff.Exponential = true
+	default:
+		log.Errorf("Unknown retry backoff '%s' for frontend %s, using a constant backoff", mode, frontendName)
+	}
+	if retryBackoff == nil {
+		return backoff
+	}
+
+	overrides := []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{name: "retry backoff interval", value: retryBackoff.Interval, field: &backoff.Interval},
+		{name: "retry backoff max interval", value: retryBackoff.MaxInterval, field: &backoff.MaxInterval},
+	}
+	for _, override := range overrides {
+		if override.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(override.value)
+		switch {
+		case err != nil:
+			log.Errorf("Illegal %s for frontend %s: %s", override.name, frontendName, err)
+		case duration < 0:
+			log.Errorf("Negative %s for frontend %s", override.name, frontendName)
+		default:
+			*override.field = duration
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHTTPTransportDialTimeout(t *testing.T) {
+	// A listener without accept backlog: once a connection is pending,
+	// the following connection attempts are left unanswered.
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	require.NoError(t, err)
+	require.NoError(t, syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}))
+	require.NoError(t, syscall.Listen(fd, 0))
+	file := os.NewFile(uintptr(fd), "listener")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	pendingConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer pendingConn.Close()
+
+	transport := createHTTPTransport(nil, ForwardingTimeouts{
+		DialTimeout:           flaeg.Duration(200 * time.Millisecond),
+		ResponseHeaderTimeout: flaeg.Duration(5 * time.Second),
+	}, nil, false)
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String(), nil)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = transport.RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the request should have been interrupted by the dial timeout")
+	if opErr, ok := err.(*net.OpError); assert.True(t, ok, "error %v should be a *net.OpError", err) {
+		assert.Equal(t, "dial", opErr.Op)
+		assert.True(t, opErr.Timeout())
+	}
+}
@@ -1,25 +1,46 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/containous/flaeg"
 	"github.com/containous/mux"
+	"github.com/containous/traefik/acme"
 	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
 	"github.com/containous/traefik/testhelpers"
 	"github.com/containous/traefik/types"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/negroni"
 	"github.com/vulcand/oxy/roundrobin"
+	"golang.org/x/net/http2"
 )
 
 type testLoadBalancer struct{}
@@ -115,6 +136,78 @@ func TestServerMultipleFrontendRules(t *testing.T) {
 	}
 }
 
+func TestServerWireFrontendBackendOriginalURI(t *testing.T) {
+	testCases := []struct {
+		desc                    string
+		expression              string
+		passOriginalURI         bool
+		requestURL              string
+		expectedURL             string
+		expectedForwardedURI    string
+		expectedForwardedPrefix string
+	}{
+		{
+			desc:                    "stripped prefix",
+			expression:              "PathPrefixStrip:/api",
+			passOriginalURI:         true,
+			requestURL:              "http://foo.bar/api/users?id=1&sort=name",
+			expectedURL:             "http://foo.bar/users?id=1&sort=name",
+			expectedForwardedURI:    "/api/users?id=1&sort=name",
+			expectedForwardedPrefix: "/api",
+		},
+		{
+			desc:                    "stripped regex prefix and added prefix",
+			expression:              "PathPrefixStripRegex:/v{version:[0-9]+};AddPrefix:/internal",
+			passOriginalURI:         true,
+			requestURL:              "http://foo.bar/v2/users?q=a%20b",
+			expectedURL:             "http://foo.bar/internal/users?q=a%20b",
+			expectedForwardedURI:    "/v2/users?q=a%20b",
+			expectedForwardedPrefix: "/v2",
+		},
+		{
+			desc:                 "without stripping",
+			expression:           "PathPrefix:/api",
+			passOriginalURI:      true,
+			requestURL:           "http://foo.bar/api/users?id=1",
+			expectedURL:          "http://foo.bar/api/users?id=1",
+			expectedForwardedURI: "/api/users?id=1",
+		},
+		{
+			desc:                    "original URI not passed",
+			expression:              "PathPrefixStrip:/api",
+			requestURL:              "http://foo.bar/api/users?id=1",
+			expectedURL:             "http://foo.bar/users?id=1",
+			expectedForwardedPrefix: "/api",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			serverRoute := &serverRoute{route: mux.NewRouter().NewRoute(), passOriginalURI: test.passOriginalURI}
+			route, err := (&Rules{route: serverRoute}).Parse(test.expression)
+			require.NoError(t, err)
+
+			request := testhelpers.MustNewRequest(http.MethodGet, test.requestURL, nil)
+			require.True(t, route.Match(request, &mux.RouteMatch{Route: route}), "rule %s doesn't match", test.expression)
+
+			var forwardedURL, forwardedURI, forwardedPrefix string
+			new(Server).wireFrontendBackend(serverRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwardedURL = r.URL.String()
+				forwardedURI = r.Header.Get("X-Forwarded-Uri")
+				forwardedPrefix = r.Header.Get("X-Forwarded-Prefix")
+			}))
+			serverRoute.route.GetHandler().ServeHTTP(httptest.NewRecorder(), request)
+
+			assert.Equal(t, test.expectedURL, forwardedURL)
+			assert.Equal(t, test.expectedForwardedURI, forwardedURI)
+			assert.Equal(t, test.expectedForwardedPrefix, forwardedPrefix)
+		})
+	}
+}
+
 func TestServerLoadConfigHealthCheckOptions(t *testing.T) {
 	healthChecks := []*types.HealthCheck{
 		nil,
@@ -232,6 +325,77 @@ func TestServerParseHealthCheckOptions(t *testing.T) {
 				LB:       lb,
 			},
 		},
+		{
+			desc: "slow start",
+			hc: &types.HealthCheck{
+				Path:      "/path",
+				SlowStart: "1m",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:      "/path",
+				Interval:  globalInterval,
+				LB:        lb,
+				SlowStart: time.Minute,
+			},
+		},
+		{
+			desc: "thresholds",
+			hc: &types.HealthCheck{
+				Path:               "/path",
+				HealthyThreshold:   2,
+				UnhealthyThreshold: -1,
+			},
+			wantOpts: &healthcheck.Options{
+				Path:             "/path",
+				Interval:         globalInterval,
+				LB:               lb,
+				HealthyThreshold: 2,
+			},
+		},
+		{
+			desc: "tcp mode without path",
+			hc: &types.HealthCheck{
+				Mode:    "tcp",
+				Timeout: "2s",
+			},
+			wantOpts: &healthcheck.Options{
+				Mode:     healthcheck.ModeTCP,
+				Interval: globalInterval,
+				LB:       lb,
+				Timeout:  2 * time.Second,
+			},
+		},
+		{
+			desc: "unknown mode",
+			hc: &types.HealthCheck{
+				Mode: "udp",
+				Path: "/path",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				LB:       lb,
+			},
+		},
+		{
+			desc: "http mode without path",
+			hc: &types.HealthCheck{
+				Mode: "http",
+			},
+			wantOpts: nil,
+		},
+		{
+			desc: "sub-zero timeout",
+			hc: &types.HealthCheck{
+				Path:    "/path",
+				Timeout: "-1s",
+			},
+			wantOpts: &healthcheck.Options{
+				Path:     "/path",
+				Interval: globalInterval,
+				LB:       lb,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -281,7 +445,7 @@ func TestNewServerWithWhitelistSourceRange(t *testing.T) {
 		tc := tc
 		t.Run(tc.desc, func(t *testing.T) {
 			t.Parallel()
-			middleware, err := configureIPWhitelistMiddleware(tc.whitelistStrings)
+			middleware, err := configureIPWhitelistMiddleware(tc.whitelistStrings, nil, nil)
 
 			if tc.errMessage != "" {
 				require.EqualError(t, err, tc.errMessage)
@@ -340,10 +504,12 @@ func TestConfigureBackends(t *testing.T) {
 	defaultMethod := "wrr"
 
 	tests := []struct {
-		desc       string
-		lb         *types.LoadBalancer
-		wantMethod string
-		wantSticky bool
+		desc             string
+		lb               *types.LoadBalancer
+		wantMethod       string
+		wantSticky       bool
+		wantStickyHeader string
+		wantStickyCookie string
 	}{
 		{
 			desc: "valid load balancer method with sticky enabled",
@@ -381,6 +547,24 @@ func TestConfigureBackends(t *testing.T) {
 			wantMethod: defaultMethod,
 			wantSticky: false,
 		},
+		{
+			desc: "invalid load balancer method with sticky header",
+			lb: &types.LoadBalancer{
+				Method:       "Invalid",
+				StickyHeader: "X-Tenant",
+			},
+			wantMethod:       defaultMethod,
+			wantStickyHeader: "X-Tenant",
+		},
+		{
+			desc: "invalid load balancer method with sticky cookie",
+			lb: &types.LoadBalancer{
+				Method:       "Invalid",
+				StickyCookie: "session_id",
+			},
+			wantMethod:       defaultMethod,
+			wantStickyCookie: "session_id",
+		},
 		{
 			desc:       "missing load balancer",
 			lb:         nil,
@@ -403,8 +587,10 @@ func TestConfigureBackends(t *testing.T) {
 			})
 
 			wantLB := types.LoadBalancer{
-				Method: test.wantMethod,
-				Sticky: test.wantSticky,
+				Method:       test.wantMethod,
+				Sticky:       test.wantSticky,
+				StickyHeader: test.wantStickyHeader,
+				StickyCookie: test.wantStickyCookie,
 			}
 			if !reflect.DeepEqual(*backend.LoadBalancer, wantLB) {
 				t.Errorf("got backend load-balancer\n%v\nwant\n%v\n", spew.Sdump(backend.LoadBalancer), spew.Sdump(wantLB))
@@ -451,6 +637,49 @@ func TestNewMetrics(t *testing.T) {
 	}
 }
 
+func TestServerDrainRemovedBackendsMetrics(t *testing.T) {
+	prometheusConfig := &types.Prometheus{Drain: &types.PrometheusDrain{Policy: middlewares.PrometheusDrainZero, GracePeriod: "1h"}}
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+		Web: &WebProvider{Metrics: &types.Metrics{Prometheus: prometheusConfig}},
+	}
+	srv := NewServer(globalConfig)
+	require.NotNil(t, srv.prometheusDrainer)
+
+	_, collectors, err := middlewares.NewPrometheus("drained-backend", prometheusConfig)
+	require.NoError(t, err)
+	defer func() {
+		for _, collector := range collectors {
+			prometheus.Unregister(collector)
+		}
+	}()
+	counter := collectors[0].(*prometheus.CounterVec).WithLabelValues("200", http.MethodGet)
+	counter.Add(3)
+	requests := func() float64 {
+		metric := &dto.Metric{}
+		require.NoError(t, collectors[0].(*prometheus.CounterVec).WithLabelValues("200", http.MethodGet).Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+
+	drained := buildFrontend(withRoute("drained", "Path:/drained"))
+	drained.Backend = "drained-backend"
+	srv.drainRemovedBackendsMetrics(configs{"config": buildDynamicConfig(withFrontend("drained", drained))})
+	assert.Equal(t, float64(3), requests())
+
+	// The backend removed as the backend of its frontend is still the canary backend of another one.
+	canary := buildFrontend(withRoute("canary", "Path:/canary"))
+	canary.Canary = &types.Canary{Backend: "drained-backend", Percent: 10}
+	srv.drainRemovedBackendsMetrics(configs{"config": buildDynamicConfig(withFrontend("canary", canary))})
+	assert.Equal(t, float64(3), requests(), "the metrics of the canary backend should have been kept")
+
+	srv.drainRemovedBackendsMetrics(configs{"config": buildDynamicConfig(withFrontend("other", buildFrontend(withRoute("other", "Path:/other"))))})
+	assert.Zero(t, requests(), "the metrics of the removed backend should have been zeroed")
+
+	assert.Nil(t, newPrometheusDrainer(GlobalConfiguration{Web: &WebProvider{Metrics: &types.Metrics{Prometheus: &types.Prometheus{}}}}))
+}
+
 func TestRegisterRetryMiddleware(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -498,7 +727,7 @@ func TestRegisterRetryMiddleware(t *testing.T) {
 				},
 			}
 
-			httpHandlerWithRetry := registerRetryMiddleware(httpHandler, tc.globalConfig, dynamicConfig, "backend", retryListener)
+			httpHandlerWithRetry := registerRetryMiddleware(httpHandler, tc.globalConfig, dynamicConfig, "frontend", "backend", 0, retryListener)
 
 			retry, ok := httpHandlerWithRetry.(*middlewares.Retry)
 			if !ok {
@@ -578,170 +807,3700 @@ func TestServerEntrypointWhitelistConfig(t *testing.T) {
 	}
 }
 
-func TestServerResponseEmptyBackend(t *testing.T) {
-	const requestPath = "/path"
-	const routeRule = "Path:" + requestPath
-
+func TestServerEntrypointMaxHeaderBytes(t *testing.T) {
 	testCases := []struct {
 		desc           string
-		dynamicConfig  func(testServerURL string) *types.Configuration
-		wantStatusCode int
+		maxHeaderBytes int
+		headerSize     int
+		expectedStatus int
 	}{
 		{
-			desc: "Ok",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withServer("testServer", testServerURL))),
-				)
-			},
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			desc: "No Frontend",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig()
-			},
-			wantStatusCode: http.StatusNotFound,
-		},
-		{
-			desc: "Empty Backend LB-Drr",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Drr", false))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
-		},
-		{
-			desc: "Empty Backend LB-Drr Sticky",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Drr", true))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:           "default limit",
+			headerSize:     8192,
+			expectedStatus: http.StatusNotFound,
 		},
 		{
-			desc: "Empty Backend LB-Wrr",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Wrr", false))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:           "header under the configured limit",
+			maxHeaderBytes: 1024,
+			headerSize:     100,
+			expectedStatus: http.StatusNotFound,
 		},
 		{
-			desc: "Empty Backend LB-Wrr Sticky",
-			dynamicConfig: func(testServerURL string) *types.Configuration {
-				return buildDynamicConfig(
-					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
-					withBackend("backend", buildBackend(withLoadBalancer("Wrr", true))),
-				)
-			},
-			wantStatusCode: http.StatusServiceUnavailable,
+			desc:           "header over the configured limit",
+			maxHeaderBytes: 1024,
+			headerSize:     8192,
+			expectedStatus: http.StatusRequestHeaderFieldsTooLarge,
 		},
 	}
 
 	for _, test := range testCases {
 		test := test
-
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-				rw.WriteHeader(http.StatusOK)
-			}))
-			defer testServer.Close()
-
-			globalConfig := GlobalConfiguration{
-				EntryPoints: EntryPoints{
-					"http": &EntryPoint{},
+			srv := Server{
+				globalConfiguration: GlobalConfiguration{
+					EntryPoints: map[string]*EntryPoint{
+						"http": {Address: "127.0.0.1:0", MaxHeaderBytes: test.maxHeaderBytes},
+					},
 				},
 			}
-			dynamicConfigs := configs{"config": test.dynamicConfig(testServer.URL)}
-
-			srv := NewServer(globalConfig)
-			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
-			if err != nil {
-				t.Fatalf("error loading config: %s", err)
-			}
+			srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+			httpServer := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"]).httpServer
 
-			responseRecorder := &httptest.ResponseRecorder{}
-			request := httptest.NewRequest(http.MethodGet, testServer.URL+requestPath, nil)
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go serve(httpServer, listener, nil)
+			defer httpServer.Close()
 
-			entryPoints["http"].httpRouter.ServeHTTP(responseRecorder, request)
+			req := testhelpers.MustNewRequest(http.MethodGet, "http://"+listener.Addr().String(), nil)
+			req.Header.Set("X-Large", strings.Repeat("a", test.headerSize))
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
 
-			if responseRecorder.Result().StatusCode != test.wantStatusCode {
-				t.Errorf("got status code %d, want %d", responseRecorder.Result().StatusCode, test.wantStatusCode)
-			}
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
 		})
 	}
-}
 
-func buildDynamicConfig(dynamicConfigBuilders ...func(*types.Configuration)) *types.Configuration {
-	config := &types.Configuration{
-		Frontends: make(map[string]*types.Frontend),
-		Backends:  make(map[string]*types.Backend),
+	// The log output is global, the requests over the limit of a kept-alive connection are checked before
+	// the parallel subtests run.
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	defer log.SetOutput(os.Stderr)
+
+	srv := Server{
+		globalConfiguration: GlobalConfiguration{
+			EntryPoints: map[string]*EntryPoint{
+				"http": {Address: "127.0.0.1:0", MaxHeaderBytes: 1024},
+			},
+		},
 	}
-	for _, build := range dynamicConfigBuilders {
-		build(config)
+	srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+	httpServer := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"]).httpServer
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go serve(httpServer, listener, nil)
+	defer httpServer.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for _, test := range []struct {
+		headerSize     int
+		expectedStatus int
+	}{
+		{headerSize: 100, expectedStatus: http.StatusNotFound},
+		// net/http reads ahead the start of the requests following kept-alive ones before limiting their size.
+		{headerSize: 16384, expectedStatus: http.StatusRequestHeaderFieldsTooLarge},
+	} {
+		req := testhelpers.MustNewRequest(http.MethodGet, "http://"+listener.Addr().String(), nil)
+		req.Header.Set("X-Large", strings.Repeat("a", test.headerSize))
+		require.NoError(t, req.Write(conn))
+		resp, err := http.ReadResponse(reader, req)
+		require.NoError(t, err)
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, test.expectedStatus, resp.StatusCode)
 	}
-	return config
+	assert.Contains(t, output.String(), "request headers exceed the maximum header size")
 }
 
-func withFrontend(frontendName string, frontend *types.Frontend) func(*types.Configuration) {
-	return func(config *types.Configuration) {
-		config.Frontends[frontendName] = frontend
-	}
+func TestServerToggleDebugLogLevel(t *testing.T) {
+	// The log level is global, the test must not run in parallel.
+	previousLevel := log.GetLevel()
+	defer log.SetLevel(previousLevel)
+	log.SetLevel(logrus.InfoLevel)
+
+	srv := Server{globalConfiguration: GlobalConfiguration{LogLevel: "INFO"}}
+
+	srv.toggleDebugLogLevel()
+	assert.Equal(t, logrus.DebugLevel, log.GetLevel())
+
+	srv.toggleDebugLogLevel()
+	assert.Equal(t, logrus.InfoLevel, log.GetLevel())
 }
 
-func withBackend(backendName string, backend *types.Backend) func(*types.Configuration) {
-	return func(config *types.Configuration) {
-		config.Backends[backendName] = backend
+func TestServerEntrypointMaxConnections(t *testing.T) {
+	gauge, collector, err := middlewares.NewPrometheusEntryPointOpenConnsGauge()
+	require.NoError(t, err)
+	openConns := func() float64 {
+		metric := &dto.Metric{}
+		require.NoError(t, collector.(*prometheus.GaugeVec).WithLabelValues("http-limited").Write(metric))
+		return metric.GetGauge().GetValue()
 	}
-}
 
-func buildFrontend(frontendBuilders ...func(*types.Frontend)) *types.Frontend {
-	fe := &types.Frontend{
-		EntryPoints: []string{"http"},
-		Backend:     "backend",
-		Routes:      make(map[string]types.Route),
+	srv := Server{
+		globalConfiguration: GlobalConfiguration{
+			EntryPoints: map[string]*EntryPoint{
+				"http-limited": {Address: "127.0.0.1:0", MaxConnections: 1},
+			},
+		},
 	}
-	for _, build := range frontendBuilders {
-		build(fe)
+	srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+	httpServer := srv.setupServerEntryPoint("http-limited", srv.serverEntryPoints["http-limited"]).httpServer
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go serve(httpServer, newConnLimitListener(listener, "http-limited", 1, gauge), nil)
+	defer httpServer.Close()
+
+	request := func(conn net.Conn) {
+		_, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 	}
-	return fe
+
+	first, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	request(first)
+	assert.Equal(t, float64(1), openConns())
+
+	second, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	require.Error(t, err)
+	if netErr, ok := err.(net.Error); ok {
+		assert.False(t, netErr.Timeout(), "the connection over the limit must be closed")
+	}
+	assert.Equal(t, float64(1), openConns())
+
+	first.Close()
+	for deadline := time.Now().Add(5 * time.Second); openConns() > 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, float64(0), openConns())
+
+	third, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer third.Close()
+	request(third)
 }
 
-func withRoute(routeName, rule string) func(*types.Frontend) {
-	return func(fe *types.Frontend) {
-		fe.Routes[routeName] = types.Route{Rule: rule}
+func TestServerEntrypointHTTP2(t *testing.T) {
+	certificates := Certificates{{
+		CertFile: FileOrContent("../integration/fixtures/https/snitest.com.cert"),
+		KeyFile:  FileOrContent("../integration/fixtures/https/snitest.com.key"),
+	}}
+	srv := Server{
+		globalConfiguration: GlobalConfiguration{
+			EntryPoints: map[string]*EntryPoint{
+				"https":       {Address: "127.0.0.1:0", TLS: &TLS{Certificates: certificates}, MaxConcurrentStreams: 42},
+				"https-http1": {Address: "127.0.0.1:0", TLS: &TLS{Certificates: certificates}, DisableHTTP2: true},
+			},
+		},
+	}
+	srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+
+	var httpServers []*http.Server
+	defer func() {
+		for _, httpServer := range httpServers {
+			httpServer.Close()
+		}
+	}()
+	start := func(entryPointName string) string {
+		serverEntryPoint := srv.setupServerEntryPoint(entryPointName, srv.serverEntryPoints[entryPointName])
+		httpServer := serverEntryPoint.httpServer
+		listener, err := srv.listenEntryPoint(entryPointName, httpServer, srv.globalConfiguration)
+		require.NoError(t, err)
+		go srv.startServer(serverEntryPoint, listener)
+		httpServers = append(httpServers, httpServer)
+		return listener.Addr().String()
+	}
+	// negotiate returns the protocol negotiated by ALPN, and the settings sent by the server over HTTP/2.
+	negotiate := func(addr string) (string, map[http2.SettingID]uint32) {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+		require.NoError(t, err)
+		defer conn.Close()
+		protocol := conn.ConnectionState().NegotiatedProtocol
+		if protocol != "h2" {
+			return protocol, nil
+		}
+
+		_, err = conn.Write([]byte(http2.ClientPreface))
+		require.NoError(t, err)
+		framer := http2.NewFramer(conn, conn)
+		require.NoError(t, framer.WriteSettings())
+		frame, err := framer.ReadFrame()
+		require.NoError(t, err)
+		settingsFrame, ok := frame.(*http2.SettingsFrame)
+		require.True(t, ok, "the server must start with its settings")
+		settings := make(map[http2.SettingID]uint32)
+		settingsFrame.ForeachSetting(func(setting http2.Setting) error {
+			settings[setting.ID] = setting.Val
+			return nil
+		})
+		return protocol, settings
 	}
+
+	protocol, settings := negotiate(start("https"))
+	assert.Equal(t, "h2", protocol)
+	assert.Equal(t, uint32(42), settings[http2.SettingMaxConcurrentStreams])
+
+	protocol, _ = negotiate(start("https-http1"))
+	assert.Equal(t, "http/1.1", protocol)
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	require.NoError(t, http2.ConfigureTransport(transport))
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get("https://" + start("https-http1"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
 }
 
-func buildBackend(backendBuilders ...func(*types.Backend)) *types.Backend {
-	be := &types.Backend{
-		Servers:      make(map[string]types.Server),
-		LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+func TestServerListenEntryPointAddress(t *testing.T) {
+	// Reserve a free port, on all the addresses.
+	reserved, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(reserved.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, reserved.Close())
+
+	srv := Server{
+		globalConfiguration: GlobalConfiguration{
+			EntryPoints: map[string]*EntryPoint{
+				"http": {Address: "127.0.0.1:" + port},
+			},
+		},
 	}
-	for _, build := range backendBuilders {
-		build(be)
+	srv.serverEntryPoints = srv.buildEntryPoints(srv.globalConfiguration)
+	serverEntryPoint := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"])
+	httpServer := serverEntryPoint.httpServer
+
+	listener, err := srv.listenEntryPoint("http", httpServer, srv.globalConfiguration)
+	require.NoError(t, err)
+	go srv.startServer(serverEntryPoint, listener)
+	defer httpServer.Close()
+
+	resp, err := http.Get("http://127.0.0.1:" + port)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// The whole 127.0.0.0/8 range is local, the entrypoint must only be bound to 127.0.0.1.
+	conn, err := net.DialTimeout("tcp", "127.0.0.2:"+port, time.Second)
+	if err == nil {
+		conn.Close()
 	}
-	return be
+	assert.Error(t, err, "the entrypoint must not be reachable on another local address")
 }
 
-func withServer(name, url string) func(backend *types.Backend) {
-	return func(be *types.Backend) {
-		be.Servers[name] = types.Server{URL: url}
+func TestServerListenEntryPointIPv6Address(t *testing.T) {
+	probe, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %s", err)
 	}
+	probe.Close()
+
+	listener, err := listen(&http.Server{Addr: "[::1]:0"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	host, _, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, "::1", host)
 }
 
-func withLoadBalancer(method string, sticky bool) func(*types.Backend) {
-	return func(be *types.Backend) {
-		be.LoadBalancer = &types.LoadBalancer{Method: method, Sticky: sticky}
+func TestServerListenEntryPointInvalidAddress(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		address string
+	}{
+		{
+			desc:    "missing port",
+			address: "127.0.0.1",
+		},
+		{
+			desc:    "invalid port",
+			address: "127.0.0.1:http-alt-foo",
+		},
+		{
+			desc:    "unbracketed IPv6 address",
+			address: "::1:80",
+		},
+		{
+			desc:    "non-local address",
+			address: "192.0.2.1:0",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			listener, err := listen(&http.Server{Addr: test.address})
+			if err == nil {
+				listener.Close()
+			}
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestServerPreserveHeaderCase(t *testing.T) {
+	// The body of the first request and the request answered by net/http must not shift the header names of the next ones.
+	fakeRequest := "GET /foo HTTP/1.1\r\nX-CLIENTID: fake\r\n\r\n"
+	pipelinedRequests := "POST /foo HTTP/1.1\r\nHost: localhost\r\nX-ClientId: a\r\nContent-Length: " + strconv.Itoa(len(fakeRequest)) + "\r\n\r\n" + fakeRequest +
+		"OPTIONS * HTTP/1.1\r\nHost: localhost\r\nx-clientid: skipped\r\n\r\n" +
+		"POST /foo HTTP/1.1\r\nHost: localhost\r\nX-ClientId: b\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nx-cli\r\n0\r\n\r\n" +
+		"GET /foo HTTP/1.1\r\nHost: localhost\r\nX-ClientId: c\r\n\r\n"
+
+	testCases := []struct {
+		desc                string
+		preserveHeaderCase  bool
+		expectedHeaderLines []string
+	}{
+		{
+			desc:                "canonical header names by default",
+			expectedHeaderLines: []string{"X-Clientid: a", "X-Clientid: b", "X-Clientid: c"},
+		},
+		{
+			desc:                "original header names preserved",
+			preserveHeaderCase:  true,
+			expectedHeaderLines: []string{"X-ClientId: a", "X-ClientId: b", "X-ClientId: c"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			backendRequests := make(chan string, 3)
+			backendListener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer backendListener.Close()
+			go func() {
+				for {
+					conn, err := backendListener.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer conn.Close()
+						for {
+							raw := &bytes.Buffer{}
+							request, err := http.ReadRequest(bufio.NewReader(io.TeeReader(conn, raw)))
+							if err != nil {
+								return
+							}
+							ioutil.ReadAll(request.Body)
+							backendRequests <- raw.String()
+							conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+						}
+					}()
+				}
+			}()
+
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+				withBackend("backend", buildBackend(withServer("server", "http://"+backendListener.Addr().String()))),
+			)
+			dynamicConfig.Backends["backend"].PreserveHeaderCase = test.preserveHeaderCase
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{Address: "127.0.0.1:0"},
+				},
+			}
+			srv := NewServer(globalConfig)
+			srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+			entryPoint := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"])
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+			entryPoint.update(entryPoints["http"])
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go serve(entryPoint.httpServer, listener, &entryPoint.preserveHeaderCase)
+			defer entryPoint.httpServer.Close()
+
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			_, err = conn.Write([]byte(pipelinedRequests))
+			require.NoError(t, err)
+			reader := bufio.NewReader(conn)
+			for i := 0; i < 4; i++ {
+				response, err := http.ReadResponse(reader, nil)
+				require.NoError(t, err, "response %d", i)
+				ioutil.ReadAll(response.Body)
+				response.Body.Close()
+				assert.Equal(t, http.StatusOK, response.StatusCode, "response %d", i)
+			}
+
+			for _, expectedHeaderLine := range test.expectedHeaderLines {
+				select {
+				case backendRequest := <-backendRequests:
+					assert.Contains(t, backendRequest, "\r\n"+expectedHeaderLine+"\r\n")
+				case <-time.After(5 * time.Second):
+					t.Fatal("the request did not reach the backend")
+				}
+			}
+		})
+	}
+}
+
+func TestEntryPointListenerCapturesHeaderNamesOnDemand(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var preserveHeaderCase int32
+	entryPointListener := &entryPointListener{Listener: listener, preserveHeaderCase: &preserveHeaderCase}
+	defer entryPointListener.Close()
+
+	accept := func() *entryPointConn {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+		conn, err := entryPointListener.Accept()
+		require.NoError(t, err)
+		conn.Close()
+		return conn.(*entryPointConn)
+	}
+
+	conn := accept()
+	assert.Nil(t, conn.rawHeaders, "the connections should not be scanned without a backend preserving the header case")
+	assert.IsType(t, &net.TCPAddr{}, conn.LocalAddr())
+
+	atomic.StoreInt32(&preserveHeaderCase, 1)
+	conn = accept()
+	assert.NotNil(t, conn.rawHeaders)
+	assert.IsType(t, &rawHeaderNamesAddr{}, conn.LocalAddr())
+}
+
+func TestServerKeepAliveWithClosingBackend(t *testing.T) {
+	backendListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer backendListener.Close()
+	go func() {
+		for {
+			conn, err := backendListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				// The body of the response is delimited by the close of the connection.
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\n\r\nstreamed"))
+			}()
+		}
+	}()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+		withBackend("backend", buildBackend(withServer("server", "http://"+backendListener.Addr().String()))),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+	entryPoint := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"])
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	entryPoint.httpRouter.UpdateHandler(entryPoints["http"].httpRouter.GetHandler())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go serve(entryPoint.httpServer, listener, nil)
+	defer entryPoint.httpServer.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// The client connection is kept alive for the following requests.
+	for i := 0; i < 2; i++ {
+		_, err = conn.Write([]byte("GET /foo HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+
+		response, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err, "request %d", i)
+		body, err := ioutil.ReadAll(response.Body)
+		require.NoError(t, err)
+		response.Body.Close()
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "streamed", string(body))
+		assert.Empty(t, response.Header.Get("Connection"))
+		assert.False(t, response.Close, "the client connection must be kept alive")
+	}
+}
+
+func TestServerBackendCustomHost(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		passHostHeader bool
+		customHost     string
+		hostOverride   string
+		expectedHost   func(backendHost string) string
+	}{
+		{
+			desc:           "client host passed",
+			passHostHeader: true,
+			expectedHost:   func(string) string { return "traefik.test" },
+		},
+		{
+			desc:         "backend host",
+			expectedHost: func(backendHost string) string { return backendHost },
+		},
+		{
+			desc:           "custom host overriding the client host",
+			passHostHeader: true,
+			customHost:     "internal.svc",
+			expectedHost:   func(string) string { return "internal.svc" },
+		},
+		{
+			desc:         "custom host overriding the backend host",
+			customHost:   "internal.svc",
+			expectedHost: func(string) string { return "internal.svc" },
+		},
+		{
+			desc:           "frontend host override overriding the client host",
+			passHostHeader: true,
+			hostOverride:   "canonical.svc",
+			expectedHost:   func(string) string { return "canonical.svc" },
+		},
+		{
+			desc:         "frontend host override overriding the backend host",
+			hostOverride: "canonical.svc",
+			expectedHost: func(string) string { return "canonical.svc" },
+		},
+		{
+			desc:           "frontend host override overriding the custom host",
+			passHostHeader: true,
+			customHost:     "internal.svc",
+			hostOverride:   "canonical.svc",
+			expectedHost:   func(string) string { return "canonical.svc" },
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Received-Host", req.Host)
+			}))
+			defer backend.Close()
+
+			frontend := buildFrontend(withRoute("/foo", "Path:/foo"))
+			frontend.PassHostHeader = test.passHostHeader
+			frontend.Headers.HostOverride = test.hostOverride
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withBackend("backend", buildBackend(withServer("server", backend.URL))),
+			)
+			dynamicConfig.Backends["backend"].CustomHost = test.customHost
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			request := testhelpers.MustNewRequest(http.MethodGet, "http://traefik.test/foo", nil)
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expectedHost(strings.TrimPrefix(backend.URL, "http://")), recorder.Header().Get("X-Received-Host"))
+		})
+	}
+}
+
+func TestServerLoadConfigHostOverrideSharedBackend(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Received-Host", req.Host)
+	}))
+	defer backend.Close()
+
+	overridden := buildFrontend(withRoute("/foo", "Path:/foo"))
+	overridden.PassHostHeader = true
+	overridden.Headers.HostOverride = "canonical.svc"
+	passed := buildFrontend(withRoute("/bar", "Path:/bar"))
+	passed.PassHostHeader = true
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("overridden", overridden),
+		withFrontend("passed", passed),
+		withBackend("backend", buildBackend(withServer("server", backend.URL))),
+	)
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	// The frontends share the forwarder of the backend, the Host override only applying to one of them.
+	for path, expectedHost := range map[string]string{"/foo": "canonical.svc", "/bar": "traefik.test"} {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, testhelpers.MustNewRequest(http.MethodGet, "http://traefik.test"+path, nil))
+		require.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.Equal(t, expectedHost, recorder.Header().Get("X-Received-Host"), path)
+	}
+}
+
+func TestServerLoadConfigHostOverrideSecondaryBackends(t *testing.T) {
+	type receivedRequest struct {
+		backend string
+		host    string
+	}
+	received := make(chan receivedRequest, 10)
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			received <- receivedRequest{backend: name, host: req.Host}
+		}))
+	}
+	dynamicConfig := buildDynamicConfig()
+	for _, name := range []string{"backend", "canary", "mirror", "fallback"} {
+		server := newTestServer(name)
+		defer server.Close()
+		withBackend(name, buildBackend(withServer("server", server.URL)))(dynamicConfig)
+	}
+
+	testCases := []struct {
+		desc            string
+		configure       func(frontend *types.Frontend)
+		path            string
+		expectedBackend string
+	}{
+		{
+			desc: "canary",
+			configure: func(frontend *types.Frontend) {
+				frontend.Canary = &types.Canary{Backend: "canary", Percent: 100}
+			},
+			path:            "/foo",
+			expectedBackend: "canary",
+		},
+		{
+			desc: "mirror",
+			configure: func(frontend *types.Frontend) {
+				frontend.Mirror = &types.Mirror{Backend: "mirror", Percent: 100}
+			},
+			path:            "/foo",
+			expectedBackend: "mirror",
+		},
+		{
+			desc: "fallback",
+			configure: func(frontend *types.Frontend) {
+				frontend.FallbackBackend = "fallback"
+			},
+			path:            "/bar",
+			expectedBackend: "fallback",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			frontend := buildFrontend(withRoute("/foo", "Host:traefik.test;Path:/foo"))
+			frontend.PassHostHeader = true
+			frontend.Headers.HostOverride = "canonical.svc"
+			test.configure(frontend)
+			dynamicConfig.Frontends = map[string]*types.Frontend{"frontend": frontend}
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, test.path, nil)
+			request.Host = "traefik.test"
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			// The mirrored requests are sent asynchronously, after the one of the backend of the frontend.
+			timeout := time.After(5 * time.Second)
+			for {
+				select {
+				case request := <-received:
+					assert.Equal(t, "canonical.svc", request.host, request.backend)
+					if request.backend != test.expectedBackend {
+						continue
+					}
+				case <-timeout:
+					t.Fatalf("no request received by backend %s", test.expectedBackend)
+				}
+				break
+			}
+		})
+	}
+}
+
+func TestServerResponseEmptyBackend(t *testing.T) {
+	const requestPath = "/path"
+	const routeRule = "Path:" + requestPath
+
+	testCases := []struct {
+		desc           string
+		dynamicConfig  func(testServerURL string) *types.Configuration
+		wantStatusCode int
+	}{
+		{
+			desc: "Ok",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withServer("testServer", testServerURL))),
+				)
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc: "No Frontend",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig()
+			},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			desc: "Empty Backend LB-Drr",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Drr", false))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Drr Sticky",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Drr", true))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Wrr",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Wrr", false))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc: "Empty Backend LB-Wrr Sticky",
+			dynamicConfig: func(testServerURL string) *types.Configuration {
+				return buildDynamicConfig(
+					withFrontend("frontend", buildFrontend(withRoute(requestPath, routeRule))),
+					withBackend("backend", buildBackend(withLoadBalancer("Wrr", true))),
+				)
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			defer testServer.Close()
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			dynamicConfigs := configs{"config": test.dynamicConfig(testServer.URL)}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+			if err != nil {
+				t.Fatalf("error loading config: %s", err)
+			}
+
+			responseRecorder := &httptest.ResponseRecorder{}
+			request := httptest.NewRequest(http.MethodGet, testServer.URL+requestPath, nil)
+
+			entryPoints["http"].httpRouter.ServeHTTP(responseRecorder, request)
+
+			if responseRecorder.Result().StatusCode != test.wantStatusCode {
+				t.Errorf("got status code %d, want %d", responseRecorder.Result().StatusCode, test.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigProblemDetails(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("empty", buildFrontend(withRoute("/empty", "Path:/empty"))),
+		withFrontend("plain", buildFrontend(withRoute("/plain", "Path:/plain"))),
+		withFrontend("unreachable", buildFrontend(withRoute("/unreachable", "Path:/unreachable"))),
+		withBackend("backend", buildBackend(withLoadBalancer("Wrr", false))),
+		withBackend("unreachable", buildBackend(withServer("unreachable", unreachable.URL))),
+	)
+	dynamicConfig.Frontends["empty"].ProblemDetails = true
+	dynamicConfig.Frontends["unreachable"].ProblemDetails = true
+	dynamicConfig.Frontends["unreachable"].Backend = "unreachable"
+	dynamicConfig.Frontends["plain"].EntryPoints = []string{"http", "api"}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+			"api":  &EntryPoint{ProblemDetails: true},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = entryPoints
+
+	testCases := []struct {
+		desc            string
+		entryPoint      string
+		path            string
+		expectedStatus  int
+		expectedProblem *middlewares.Problem
+	}{
+		{
+			desc:           "no healthy server",
+			entryPoint:     "http",
+			path:           "/empty",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedProblem: &middlewares.Problem{
+				Type:   middlewares.ProblemTypeNoAvailableServer,
+				Title:  "Service Unavailable",
+				Status: http.StatusServiceUnavailable,
+				Detail: "No server of the backend is available",
+			},
+		},
+		{
+			desc:           "unreachable server",
+			entryPoint:     "http",
+			path:           "/unreachable",
+			expectedStatus: http.StatusBadGateway,
+			expectedProblem: &middlewares.Problem{
+				Type:   middlewares.ProblemTypeBadGateway,
+				Title:  "Bad Gateway",
+				Status: http.StatusBadGateway,
+				Detail: "The backend server could not be reached",
+			},
+		},
+		{
+			desc:           "disabled for the frontend",
+			entryPoint:     "http",
+			path:           "/plain",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			desc:           "enabled for the entrypoint",
+			entryPoint:     "api",
+			path:           "/plain",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedProblem: &middlewares.Problem{
+				Type:   middlewares.ProblemTypeNoAvailableServer,
+				Title:  "Service Unavailable",
+				Status: http.StatusServiceUnavailable,
+				Detail: "No server of the backend is available",
+			},
+		},
+		{
+			desc:           "no matching frontend",
+			entryPoint:     "api",
+			path:           "/unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedProblem: &middlewares.Problem{
+				Type:   middlewares.ProblemTypeNotFound,
+				Title:  "Not Found",
+				Status: http.StatusNotFound,
+				Detail: "No frontend matches the request",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			handler := srv.setupServerEntryPoint(test.entryPoint, entryPoints[test.entryPoint]).httpServer.Handler
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			if test.expectedProblem == nil {
+				assert.NotEqual(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+				assert.Equal(t, http.StatusText(test.expectedStatus), recorder.Body.String())
+				return
+			}
+			assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			problem := &middlewares.Problem{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), problem))
+			assert.Equal(t, test.expectedProblem, problem)
+		})
+	}
+}
+
+func TestServerLoadConfigWithChains(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Chain-Request", req.Header.Get("X-Chain"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend1", buildFrontend(withRoute("/foo", "Path:/foo"), withChains("common"))),
+		withFrontend("frontend2", buildFrontend(withRoute("/bar", "Path:/bar"), withChains("common"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+		withBackend("backend2", buildBackend(withServer("testServer", testServer.URL))),
+		withChain("common", &types.Chain{
+			Middlewares: []types.ChainMiddleware{
+				{
+					Headers: types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Chain": "first"},
+						CustomResponseHeaders: map[string]string{"X-Order": "first"},
+					},
+				},
+				{
+					Headers: types.Headers{
+						CustomRequestHeaders:  map[string]string{"X-Chain": "second"},
+						CustomResponseHeaders: map[string]string{"X-Order": "second"},
+					},
+				},
+			},
+		}),
+	)
+	dynamicConfig.Frontends["frontend2"].Backend = "backend2"
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/foo", "/bar"} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, testServer.URL+path, nil)
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code, path)
+		assert.Equal(t, "second", recorder.Header().Get("X-Chain-Request"), path)
+		assert.Equal(t, []string{"first", "second"}, recorder.Header()["X-Order"], path)
+	}
+}
+
+func TestServerLoadConfigFrontendMiddlewaresSharedBackend(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Received-Test", req.Header.Get("X-Test"))
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc           string
+		configure      func(config *types.Configuration, frontend *types.Frontend)
+		expectedStatus int
+		expectedHeader string
+	}{
+		{
+			desc: "chains",
+			configure: func(config *types.Configuration, frontend *types.Frontend) {
+				config.Chains = map[string]*types.Chain{
+					"auth": {Middlewares: []types.ChainMiddleware{{BasicAuth: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}}}},
+				}
+				frontend.Chains = []string{"auth"}
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc: "header rewrites",
+			configure: func(config *types.Configuration, frontend *types.Frontend) {
+				frontend.HeaderRewrites = map[string]types.HeaderRewrite{
+					"test": {Header: "X-Received-Test", Regex: "value", Replacement: "rewritten"},
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedHeader: "rewritten",
+		},
+		{
+			desc: "status rewrites",
+			configure: func(config *types.Configuration, frontend *types.Frontend) {
+				frontend.StatusRewrites = map[string]int{"200": 299}
+			},
+			expectedStatus: 299,
+			expectedHeader: "value",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			// Frontend a, configured with the middleware, builds the backend shared with frontend b.
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("a", buildFrontend(withRoute("/a", "Path:/a"))),
+				withFrontend("b", buildFrontend(withRoute("/b", "Path:/b"))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			test.configure(dynamicConfig, dynamicConfig.Frontends["a"])
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := testhelpers.MustNewRequest(http.MethodGet, "http://traefik.test/a", nil)
+			request.Header.Set("X-Test", "value")
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			assert.Equal(t, test.expectedStatus, recorder.Code, "/a")
+			assert.Equal(t, test.expectedHeader, recorder.Header().Get("X-Received-Test"), "/a")
+
+			recorder = httptest.NewRecorder()
+			request = testhelpers.MustNewRequest(http.MethodGet, "http://traefik.test/b", nil)
+			request.Header.Set("X-Test", "value")
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusOK, recorder.Code, "/b")
+			assert.Equal(t, "value", recorder.Header().Get("X-Received-Test"), "/b")
+		})
+	}
+}
+
+func TestServerLoadConfigMaxInFlightReqSharedBackend(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/limited" {
+			close(started)
+			<-release
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	limited := buildFrontend(withRoute("/limited", "Path:/limited"))
+	limited.MaxInFlightReq = &types.MaxInFlightReq{Amount: 1}
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("limited", limited),
+		withFrontend("unlimited", buildFrontend(withRoute("/unlimited", "Path:/unlimited"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	limitedCode := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test/limited", nil))
+		limitedCode <- recorder.Code
+	}()
+	select {
+	case <-started:
+	case code := <-limitedCode:
+		t.Fatalf("unexpected response %d to the request of the limited frontend", code)
+	}
+
+	// The in-flight request of the limited frontend does not count against the other frontend of the backend.
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test/unlimited", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-limitedCode)
+}
+
+func TestServerLoadConfigBypassesSharedBackend(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	bypassing := buildFrontend(withRoute("/a", "Path:/a"))
+	bypassing.BasicAuth = []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}
+	bypassing.Bypasses = map[string]types.Bypass{"probes": {Paths: []string{"/b"}, Middlewares: []string{"auth"}}}
+	authenticated := buildFrontend(withRoute("/b", "Path:/b"))
+	authenticated.BasicAuth = []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("a", bypassing),
+		withFrontend("b", authenticated),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	// The bypass of frontend a does not apply to the paths of the other frontend of the backend.
+	for _, path := range []string{"/a", "/b"} {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test"+path, nil))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, path)
+	}
+}
+
+func TestServerLoadConfigMaxConnRejectionsSharedBackend(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/a/hold" {
+			close(started)
+			<-release
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	rejecting := buildFrontend(withRoute("/a", "PathPrefix:/a"))
+	rejecting.Rejections = &types.RejectionResponses{
+		MaxConn: &types.RejectionResponse{StatusCode: http.StatusTooManyRequests, Body: "busy"},
+	}
+	backend := buildBackend(withServer("testServer", testServer.URL))
+	backend.MaxConn = &types.MaxConn{Amount: 1, ExtractorFunc: "request.host", Policy: middlewares.MaxConnPolicyFailFast}
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("a", rejecting),
+		withFrontend("b", buildFrontend(withRoute("/b", "Path:/b"))),
+		withBackend("backend", backend),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	holdCode := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test/a/hold", nil))
+		holdCode <- recorder.Code
+	}()
+	select {
+	case <-started:
+	case code := <-holdCode:
+		t.Fatalf("unexpected response %d to the request holding the connection", code)
+	}
+
+	// Each frontend of the backend rejects the requests over its maximum amount of connections with its own response.
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test/a", nil))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(t, "busy", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test/b", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-holdCode)
+}
+
+func TestServerLoadConfigRetryBackoffSharedBackend(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	backingOff := buildFrontend(withRoute("/a", "Path:/a"))
+	backingOff.RetryBackoff = &types.RetryBackoff{Interval: "200ms"}
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("a", backingOff),
+		withFrontend("b", buildFrontend(withRoute("/b", "Path:/b"))),
+		withBackend("backend", buildBackend(withServer("unreachable", unreachable.URL))),
+	)
+	dynamicConfig.Backends["backend"].CircuitBreaker = nil
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+		Retry: &Retry{Attempts: 2},
+	}
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	// Only the requests of frontend a wait for its backoff before being retried.
+	for path, backingOff := range map[string]bool{"/a": true, "/b": false} {
+		recorder := httptest.NewRecorder()
+		start := time.Now()
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://traefik.test"+path, nil))
+		assert.Equal(t, http.StatusBadGateway, recorder.Code, path)
+		assert.Equal(t, backingOff, time.Since(start) >= 200*time.Millisecond, path)
+	}
+}
+
+func TestServerLoadConfigReplaysSharedBackend(t *testing.T) {
+	var count int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(rw, atomic.AddInt32(&count, 1))
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc      string
+		configure func(frontend *types.Frontend)
+		method    string
+		header    http.Header
+	}{
+		{
+			desc: "response cache",
+			configure: func(frontend *types.Frontend) {
+				frontend.Cache = &types.ResponseCache{}
+			},
+			method: http.MethodGet,
+		},
+		{
+			desc: "idempotency",
+			configure: func(frontend *types.Frontend) {
+				frontend.Idempotency = &types.Idempotency{}
+			},
+			method: http.MethodPost,
+			header: http.Header{middlewares.DefaultIdempotencyHeader: {"key"}},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			// Frontend a, replaying its responses, builds the backend shared with frontend b.
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("a", buildFrontend(withRoute("/a", "Path:/a"))),
+				withFrontend("b", buildFrontend(withRoute("/b", "Path:/b"))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			test.configure(dynamicConfig.Frontends["a"])
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			for path, replayed := range map[string]bool{"/a": true, "/b": false} {
+				var bodies []string
+				for i := 0; i < 2; i++ {
+					recorder := httptest.NewRecorder()
+					request := httptest.NewRequest(test.method, "http://traefik.test"+path, nil)
+					for name, values := range test.header {
+						request.Header[name] = values
+					}
+					entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+					require.Equal(t, http.StatusOK, recorder.Code, path)
+					bodies = append(bodies, recorder.Body.String())
+				}
+				assert.Equal(t, replayed, bodies[0] == bodies[1], path)
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigWithFrontendRedirects(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("public", buildFrontend(withRoute("/public", "Path:/public"))),
+		withFrontend("internal", buildFrontend(withRoute("/internal", "Path:/internal"))),
+		withFrontend("admin", buildFrontend(withRoute("/admin", "Path:/admin"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+	dynamicConfig.Frontends["internal"].Redirect = &types.FrontendRedirect{Disabled: true}
+	dynamicConfig.Frontends["admin"].EntryPoints = []string{"http", "internal"}
+	dynamicConfig.Frontends["admin"].Redirect = &types.FrontendRedirect{EntryPoint: "https"}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http":     &EntryPoint{Address: ":80", Redirect: &Redirect{EntryPoint: "https"}},
+			"https":    &EntryPoint{Address: ":443", TLS: &TLS{}},
+			"internal": &EntryPoint{Address: ":8080"},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		entryPoint       string
+		path             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{entryPoint: "http", path: "/public", expectedCode: http.StatusFound, expectedLocation: "https://example.com:443/public"},
+		{entryPoint: "http", path: "/internal", expectedCode: http.StatusOK},
+		{entryPoint: "http", path: "/admin", expectedCode: http.StatusFound, expectedLocation: "https://example.com:443/admin"},
+		{entryPoint: "internal", path: "/admin", expectedCode: http.StatusFound, expectedLocation: "https://example.com:443/admin"},
+	}
+
+	for _, test := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		entryPoints[test.entryPoint].httpRouter.ServeHTTP(recorder, request)
+
+		assert.Equal(t, test.expectedCode, recorder.Code, test.entryPoint+test.path)
+		assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"), test.entryPoint+test.path)
+	}
+}
+
+func TestServerLoadConfigSortedServers(t *testing.T) {
+	var urls []string
+	names := map[string]string{}
+	backendBuilders := []func(*types.Backend){}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		name := name
+		testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+		defer testServer.Close()
+		urls = append(urls, testServer.URL)
+		names[testServer.URL] = name
+		backendBuilders = append(backendBuilders, withServer(name, testServer.URL))
+	}
+	sort.Strings(urls)
+	var expectedRotation []string
+	for _, u := range urls {
+		expectedRotation = append(expectedRotation, names[u])
+	}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+
+	// Each reload rebuilds the load balancer from a configuration with the same servers.
+	for reload := 0; reload < 2; reload++ {
+		dynamicConfig := buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("all", "PathPrefix:/"))),
+			withBackend("backend", buildBackend(backendBuilders...)),
+		)
+		dynamicConfig.Backends["backend"].LoadBalancer.SortServers = true
+
+		srv := NewServer(globalConfig)
+		entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+		require.NoError(t, err)
+
+		var rotation []string
+		for range urls {
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			rotation = append(rotation, recorder.Body.String())
+		}
+		assert.Equal(t, expectedRotation, rotation, "reload %d", reload)
+	}
+}
+
+func TestServerEntryPointStaticFilesAndHTTPChallenge(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dir, err := ioutil.TempDir("", "static")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	humansFile := dir + "/humans.txt"
+	require.NoError(t, ioutil.WriteFile(humansFile, []byte("Team: Traefik"), 0644))
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("all", "PathPrefix:/"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{
+				Address:  ":80",
+				Redirect: &Redirect{EntryPoint: "https"},
+				StaticFiles: map[string]FileOrContent{
+					"/robots.txt": "User-agent: *\nDisallow: /",
+					"/humans.txt": FileOrContent(humansFile),
+				},
+			},
+			"https": &EntryPoint{Address: ":443", TLS: &TLS{}},
+		},
+		ACME: &acme.ACME{EntryPoint: "https", HTTPChallenge: &acme.HTTPChallenge{EntryPoint: "http"}},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = entryPoints
+	handler := srv.setupServerEntryPoint("http", entryPoints["http"]).httpServer.Handler
+
+	testCases := []struct {
+		desc             string
+		method           string
+		path             string
+		expectedCode     int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{
+			desc:         "static file content",
+			method:       http.MethodGet,
+			path:         "/robots.txt",
+			expectedCode: http.StatusOK,
+			expectedBody: "User-agent: *\nDisallow: /",
+		},
+		{
+			desc:         "static file read from a file",
+			method:       http.MethodGet,
+			path:         "/humans.txt",
+			expectedCode: http.StatusOK,
+			expectedBody: "Team: Traefik",
+		},
+		{
+			desc:         "ACME challenge not in progress",
+			method:       http.MethodGet,
+			path:         "/.well-known/acme-challenge/token",
+			expectedCode: http.StatusNotFound,
+			expectedBody: "404 page not found\n",
+		},
+		{
+			desc:             "static file path with another method",
+			method:           http.MethodPost,
+			path:             "/robots.txt",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://example.com:443/robots.txt",
+		},
+		{
+			desc:             "other path",
+			method:           http.MethodGet,
+			path:             "/index.html",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://example.com:443/index.html",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+			if len(test.expectedBody) > 0 {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerEntryPointStaticFilesWhitelisted(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{
+				Address:              ":80",
+				Redirect:             &Redirect{EntryPoint: "https"},
+				WhitelistSourceRange: []string{"10.0.0.0/8"},
+				StaticFiles: map[string]FileOrContent{
+					"/robots.txt": "User-agent: *\nDisallow: /",
+				},
+			},
+			"https": &EntryPoint{Address: ":443", TLS: &TLS{}},
+		},
+		ACME: &acme.ACME{EntryPoint: "https", HTTPChallenge: &acme.HTTPChallenge{EntryPoint: "http"}},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{}, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = entryPoints
+	handler := srv.setupServerEntryPoint("http", entryPoints["http"]).httpServer.Handler
+
+	testCases := []struct {
+		desc         string
+		remoteAddr   string
+		path         string
+		expectedCode int
+	}{
+		{
+			desc:         "static file from a whitelisted address",
+			remoteAddr:   "10.0.0.1:1234",
+			path:         "/robots.txt",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "static file from another address",
+			remoteAddr:   "192.0.2.1:1234",
+			path:         "/robots.txt",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			desc:         "ACME challenge from another address",
+			remoteAddr:   "192.0.2.1:1234",
+			path:         "/.well-known/acme-challenge/token",
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.RemoteAddr = test.remoteAddr
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+		})
+	}
+}
+func TestServerLoadConfigWithTrustedForwardedProto(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("all", "PathPrefix:/"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+
+	testCases := []struct {
+		desc                string
+		trustForwardedProto bool
+		forwardedProto      string
+		expectedCode        int
+	}{
+		{
+			desc:                "trusted forwarded HTTPS",
+			trustForwardedProto: true,
+			forwardedProto:      "https",
+			expectedCode:        http.StatusOK,
+		},
+		{
+			desc:                "no forwarded proto",
+			trustForwardedProto: true,
+			expectedCode:        http.StatusFound,
+		},
+		{
+			desc:           "forwarded proto not trusted by the entrypoint",
+			forwardedProto: "https",
+			expectedCode:   http.StatusFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				TrustedProxies: types.TrustedProxies{"192.0.2.0/24"},
+				EntryPoints: EntryPoints{
+					"http":  &EntryPoint{Address: ":80", Redirect: &Redirect{EntryPoint: "https"}, TrustForwardedProto: test.trustForwardedProto},
+					"https": &EntryPoint{Address: ":443", TLS: &TLS{}},
+				},
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			// httptest.NewRequest comes from 192.0.2.1.
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(test.forwardedProto) > 0 {
+				req.Header.Set("X-Forwarded-Proto", test.forwardedProto)
+			}
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestServerLoadConfigWithPathRedirects(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("all", "PathPrefix:/"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+
+	testCases := []struct {
+		desc             string
+		redirect         *Redirect
+		path             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			desc:             "rule to an entrypoint",
+			redirect:         &Redirect{EntryPoint: "https", Rules: []RedirectRule{{PathPrefix: "/api", EntryPoint: "api"}}},
+			path:             "/api/users?page=2",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://example.com:8443/api/users?page=2",
+		},
+		{
+			desc: "rule with a regex",
+			redirect: &Redirect{EntryPoint: "https", Rules: []RedirectRule{
+				{PathPrefix: "/api", EntryPoint: "api"},
+				{PathPrefix: "/blog", Regex: "^https?://[^/]+/blog/(.*)$", Replacement: "https://blog.example.com/$1"},
+			}},
+			path:             "/blog/2017/post?lang=en",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://blog.example.com/2017/post?lang=en",
+		},
+		{
+			desc:             "default redirect",
+			redirect:         &Redirect{EntryPoint: "https", Rules: []RedirectRule{{PathPrefix: "/api", EntryPoint: "api"}}},
+			path:             "/apiv2/users",
+			expectedCode:     http.StatusFound,
+			expectedLocation: "https://example.com:443/apiv2/users",
+		},
+		{
+			desc:         "no default redirect",
+			redirect:     &Redirect{Rules: []RedirectRule{{PathPrefix: "/api", EntryPoint: "api"}}},
+			path:         "/users",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http":  &EntryPoint{Address: ":80", Redirect: test.redirect},
+					"https": &EntryPoint{Address: ":443", TLS: &TLS{}},
+					"api":   &EntryPoint{Address: ":8443", TLS: &TLS{}},
+				},
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServerLoadConfigWithInvalidPathRedirect(t *testing.T) {
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("all", "PathPrefix:/"))),
+		withBackend("backend", buildBackend(withServer("testServer", "http://127.0.0.1"))),
+	)
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: ":80", Redirect: &Redirect{Rules: []RedirectRule{{PathPrefix: "/api"}}}},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	// The frontend is skipped.
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestServerLoadConfigGrpcWebBackend(t *testing.T) {
+	// A native gRPC server, reached over clear text HTTP/2.
+	grpcHandler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil || req.ProtoMajor != 2 || req.Header.Get("Te") != "trailers" ||
+			req.Header.Get("Content-Type") != "application/grpc+proto" || len(body) < 5 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		message := "hello " + string(body[5:])
+		rw.Header().Set("Content-Type", "application/grpc+proto")
+		rw.Header().Set("Trailer", "Grpc-Status")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(append([]byte{0, 0, 0, 0, byte(len(message))}, message...))
+		rw.Header().Set("Grpc-Status", "0")
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go (&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: grpcHandler})
+		}
+	}()
+
+	backend := buildBackend(withServer("grpc", "http://"+listener.Addr().String()))
+	backend.Protocol = "grpc"
+	backend.GrpcWeb = true
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("grpc", "PathPrefix:/helloworld.Greeter/"))),
+		withBackend("backend", backend),
+	)
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/helloworld.Greeter/SayHello", bytes.NewReader([]byte{0, 0, 0, 0, 5, 'w', 'o', 'r', 'l', 'd'}))
+	request.Header.Set("Content-Type", "application/grpc-web+proto")
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/grpc-web+proto", recorder.Header().Get("Content-Type"))
+	trailers := "grpc-status: 0\r\n"
+	expectedBody := append([]byte{0, 0, 0, 0, 11}, "hello world"...)
+	expectedBody = append(expectedBody, 0x80, 0, 0, 0, byte(len(trailers)))
+	expectedBody = append(expectedBody, trailers...)
+	assert.Equal(t, expectedBody, recorder.Body.Bytes())
+}
+
+func TestServerLoadConfigStickySessionFallback(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+			rw.WriteHeader(http.StatusOK)
+		}))
+	}
+	server1 := newTestServer("server1")
+	defer server1.Close()
+	server2 := newTestServer("server2")
+	defer server2.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	srv := NewServer(globalConfig)
+	serve := func(servers map[string]string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		backend := buildBackend(withLoadBalancer("Wrr", true))
+		for name, serverURL := range servers {
+			withServer(name, serverURL)(backend)
+		}
+		dynamicConfig := buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+			withBackend("backend", backend),
+		)
+		entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/app", nil)
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+		return recorder
+	}
+	stickyCookie := func(recorder *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range (&http.Response{Header: recorder.Header()}).Cookies() {
+			if cookie.Name == "_TRAEFIK_BACKEND_backend" {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	recorder := serve(map[string]string{"server1": server1.URL}, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "server1", recorder.Header().Get("X-Server"))
+	cookie := stickyCookie(recorder)
+	require.NotNil(t, cookie)
+	assert.Equal(t, server1.URL, cookie.Value)
+
+	// The session stays on its server while it is in the backend.
+	recorder = serve(map[string]string{"server1": server1.URL, "server2": server2.URL}, cookie)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "server1", recorder.Header().Get("X-Server"))
+
+	// The pinned server is removed mid-session.
+	recorder = serve(map[string]string{"server2": server2.URL}, cookie)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "server2", recorder.Header().Get("X-Server"))
+	if updatedCookie := stickyCookie(recorder); assert.NotNil(t, updatedCookie) {
+		assert.Equal(t, server2.URL, updatedCookie.Value)
+	}
+
+	// An invalid cookie is rebalanced as well.
+	recorder = serve(map[string]string{"server2": server2.URL}, &http.Cookie{Name: "_TRAEFIK_BACKEND_backend", Value: "%zz"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "server2", recorder.Header().Get("X-Server"))
+	if updatedCookie := stickyCookie(recorder); assert.NotNil(t, updatedCookie) {
+		assert.Equal(t, server2.URL, updatedCookie.Value)
+	}
+}
+
+func TestServerLoadConfigStickyCookie(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+		}))
+	}
+	server1 := newTestServer("server1")
+	defer server1.Close()
+	server2 := newTestServer("server2")
+	defer server2.Close()
+
+	testCases := []struct {
+		desc           string
+		stickyHeader   string
+		expectedStatus int
+	}{
+		{
+			desc:           "hashing the cookie",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "with both a cookie and a header",
+			stickyHeader:   "X-Session",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			backend := buildBackend(withServer("server1", server1.URL), withServer("server2", server2.URL))
+			backend.LoadBalancer.StickyCookie = "session_id"
+			backend.LoadBalancer.StickyVirtualNodes = 10
+			backend.LoadBalancer.StickyHeader = test.stickyHeader
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+				withBackend("backend", backend),
+			)
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			var picked string
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/app", nil)
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: "session"})
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, req)
+				require.Equal(t, test.expectedStatus, recorder.Code)
+				if i > 0 {
+					assert.Equal(t, picked, recorder.Header().Get("X-Server"), "the session moved to another server")
+				}
+				picked = recorder.Header().Get("X-Server")
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigZoneAffinity(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+		}))
+	}
+	local := newTestServer("local")
+	defer local.Close()
+	remote := newTestServer("remote")
+	defer remote.Close()
+
+	for _, method := range []string{"Wrr", "Drr", "Ewma", "Adaptive"} {
+		t.Run(method, func(t *testing.T) {
+			backend := buildBackend(withLoadBalancer(method, false))
+			backend.Servers["local"] = types.Server{URL: local.URL, Weight: 1, Zone: "zone1"}
+			backend.Servers["remote"] = types.Server{URL: remote.URL, Weight: 1, Zone: "zone2"}
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+				withBackend("backend", backend),
+			)
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+				Zone: "zone1",
+			}
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			for i := 0; i < 10; i++ {
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/app", nil))
+				require.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, "local", recorder.Header().Get("X-Server"))
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigDrain(t *testing.T) {
+	newTestServer := func(name string, draining bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+			if draining {
+				rw.Header().Set("X-Shutting-Down", "true")
+			}
+			rw.WriteHeader(http.StatusOK)
+		}))
+	}
+	server1 := newTestServer("server1", true)
+	defer server1.Close()
+	server2 := newTestServer("server2", false)
+	defer server2.Close()
+
+	for _, method := range []string{"Wrr", "Drr", "Ewma", "Adaptive"} {
+		t.Run(method, func(t *testing.T) {
+			backend := buildBackend(withLoadBalancer(method, false), withServer("server1", server1.URL), withServer("server2", server2.URL))
+			backend.Drain = &types.Drain{Header: "X-Shutting-Down", Timeout: "1h"}
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+				withBackend("backend", backend),
+			)
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			// The draining server gets no new request once it answered with the drain header.
+			requests := make(map[string]int)
+			for i := 0; i < 10; i++ {
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/app", nil))
+				require.Equal(t, http.StatusOK, recorder.Code)
+				assert.Empty(t, recorder.Header().Get("X-Shutting-Down"))
+				requests[recorder.Header().Get("X-Server")]++
+			}
+			assert.True(t, requests["server1"] <= 1, "the draining server got %d requests", requests["server1"])
+			assert.True(t, requests["server2"] >= 9)
+		})
+	}
+}
+
+func TestServerReloadKeepsRoutes(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+	srv.serverEntryPoints["http"].httpServer = &http.Server{}
+	handler := srv.serverEntryPoints["http"].httpRouter
+
+	// Each configuration keeps the stable frontend, and changes the other ones.
+	buildConfig := func(generation int) types.ConfigMessage {
+		return types.ConfigMessage{
+			ProviderName: "file",
+			Configuration: buildDynamicConfig(
+				withFrontend("stable", buildFrontend(withRoute("stable", "Path:/stable"))),
+				withFrontend(fmt.Sprintf("frontend%d", generation), buildFrontend(withRoute("route", fmt.Sprintf("Path:/generation/%d", generation)))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			),
+		}
+	}
+
+	stop := make(chan bool)
+	defer close(stop)
+	go srv.listenConfigurations(stop)
+	srv.configurationValidatedChan <- buildConfig(0)
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.checkReadiness() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, srv.checkReadiness())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var requests, failures int64
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stable", nil))
+				atomic.AddInt64(&requests, 1)
+				if recorder.Code != http.StatusOK {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}()
+	}
+
+	for generation := 1; generation <= 50; generation++ {
+		srv.configurationValidatedChan <- buildConfig(generation)
+	}
+	deadline = time.Now().Add(10 * time.Second)
+	for len(srv.configurationValidatedChan) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	assert.NotZero(t, atomic.LoadInt64(&requests))
+	assert.Zero(t, atomic.LoadInt64(&failures), "requests to a route present in all the configurations must never fail")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/generation/50", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "the last configuration must be applied")
+}
+
+func TestServerEntryPointChain(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend1", buildFrontend(withRoute("/foo", "Path:/foo"))),
+		withFrontend("frontend2", buildFrontend(withRoute("/bar", "Path:/bar"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+		withBackend("backend2", buildBackend(withServer("testServer", testServer.URL))),
+	)
+	dynamicConfig.Frontends["frontend2"].Backend = "backend2"
+	dynamicConfig.Frontends["frontend2"].Headers = types.Headers{
+		CustomResponseHeaders: map[string]string{"X-Layer": "frontend"},
+	}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{
+				Chain: &types.Chain{
+					Middlewares: []types.ChainMiddleware{
+						{
+							Headers: types.Headers{
+								CustomResponseHeaders: map[string]string{"X-Layer": "entrypoint"},
+								FrameDeny:             true,
+								STSSeconds:            31536000,
+								ForceSTSHeader:        true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = entryPoints
+	handler := srv.setupServerEntryPoint("http", entryPoints["http"]).httpServer.Handler
+
+	testCases := []struct {
+		path          string
+		expectedCode  int
+		expectedLayer []string
+	}{
+		{path: "/foo", expectedCode: http.StatusOK, expectedLayer: []string{"entrypoint"}},
+		{path: "/bar", expectedCode: http.StatusOK, expectedLayer: []string{"entrypoint", "frontend"}},
+		{path: "/unknown", expectedCode: http.StatusNotFound, expectedLayer: []string{"entrypoint"}},
+	}
+
+	for _, test := range testCases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		assert.Equal(t, test.expectedCode, recorder.Code, test.path)
+		assert.Equal(t, "DENY", recorder.Header().Get("X-Frame-Options"), test.path)
+		assert.Equal(t, "max-age=31536000", recorder.Header().Get("Strict-Transport-Security"), test.path)
+		assert.Equal(t, test.expectedLayer, recorder.Header()["X-Layer"], test.path)
+	}
+}
+
+func TestServerRequestNormalization(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Request-URI", req.RequestURI)
+		rw.Header().Set("X-Host", req.Host)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc               string
+		strict             bool
+		target             string
+		host               string
+		expectedCode       int
+		expectedRequestURI string
+		expectedHost       string
+	}{
+		{
+			desc:               "absolute-form request URI",
+			target:             "http://foo.bar/path",
+			expectedCode:       http.StatusOK,
+			expectedRequestURI: "/path",
+			expectedHost:       "foo.bar",
+		},
+		{
+			desc:               "absolute-form request URI with the default port",
+			target:             "http://Foo.Bar:80/path",
+			expectedCode:       http.StatusOK,
+			expectedRequestURI: "/path",
+			expectedHost:       "foo.bar",
+		},
+		{
+			desc:               "Host header with a port",
+			target:             "/path",
+			host:               "Foo.Bar:80",
+			expectedCode:       http.StatusOK,
+			expectedRequestURI: "/path",
+			expectedHost:       "foo.bar",
+		},
+		{
+			desc:         "strict absolute-form request URI with the default port",
+			strict:       true,
+			target:       "http://foo.bar:80/path",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			desc:         "strict uppercase Host header",
+			strict:       true,
+			target:       "/path",
+			host:         "Foo.Bar",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("route", "HostRegexp:foo.bar;Path:/path"))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			dynamicConfig.Frontends["frontend"].PassHostHeader = true
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints:         EntryPoints{"http": &EntryPoint{}},
+				StrictRequestTarget: test.strict,
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+			srv.serverEntryPoints = entryPoints
+			handler := srv.setupServerEntryPoint("http", entryPoints["http"]).httpServer.Handler
+
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.host != "" {
+				req.Host = test.host
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.expectedCode, recorder.Code)
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, test.expectedRequestURI, recorder.Header().Get("X-Request-URI"))
+				assert.Equal(t, test.expectedHost, recorder.Header().Get("X-Host"))
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigInvalidCircuitBreaker(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+	dynamicConfig.Backends["backend"].CircuitBreaker = &types.CircuitBreaker{Expression: "NetworkErrorRatio() >"}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	// The frontend is kept, with its backend served without circuit breaker.
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestServerLoadConfigUndefinedChain(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"), withChains("missing"))),
+		withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+	)
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, testServer.URL+"/foo", nil)
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestServerLoadConfigBasicAuthSecrets(t *testing.T) {
+	os.Setenv("TRAEFIK_TEST_BASIC_AUTH", "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/")
+	defer os.Unsetenv("TRAEFIK_TEST_BASIC_AUTH")
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc          string
+		basicAuth     string
+		expectedError bool
+	}{
+		{
+			desc:      "resolved reference",
+			basicAuth: "env://TRAEFIK_TEST_BASIC_AUTH",
+		},
+		{
+			desc:          "unresolved reference",
+			basicAuth:     "env://TRAEFIK_TEST_BASIC_AUTH_UNSET",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			dynamicConfig.Frontends["frontend"].BasicAuth = []string{test.basicAuth}
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{test.basicAuth}, dynamicConfig.Frontends["frontend"].BasicAuth)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testServer.URL+"/foo", nil)
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+			recorder = httptest.NewRecorder()
+			request.SetBasicAuth("test", "test")
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+}
+
+func TestServerLoadConfigAWSSigningSecrets(t *testing.T) {
+	os.Setenv("TRAEFIK_TEST_AWS_SECRET", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	defer os.Unsetenv("TRAEFIK_TEST_AWS_SECRET")
+
+	authorizations := make(chan string, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authorizations <- req.Header.Get("Authorization")
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc            string
+		secretAccessKey string
+		expectedError   bool
+	}{
+		{
+			desc:            "resolved reference",
+			secretAccessKey: "env://TRAEFIK_TEST_AWS_SECRET",
+		},
+		{
+			desc:            "unresolved reference",
+			secretAccessKey: "env://TRAEFIK_TEST_AWS_SECRET_UNSET",
+			expectedError:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			dynamicConfig.Backends["backend"].AWSSigning = &types.AWSSigning{
+				Service:         "execute-api",
+				Region:          "eu-west-1",
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: test.secretAccessKey,
+			}
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.secretAccessKey, dynamicConfig.Backends["backend"].AWSSigning.SecretAccessKey)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testServer.URL+"/foo", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Contains(t, <-authorizations, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/")
+		})
+	}
+}
+
+func TestServerLoadConfigBypass(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	testCases := []struct {
+		desc           string
+		bypass         types.Bypass
+		path           string
+		credentials    bool
+		expectedStatus int
+	}{
+		{
+			desc:           "bypassed path",
+			bypass:         types.Bypass{Paths: []string{"/metrics"}, Middlewares: []string{"auth"}},
+			path:           "/metrics",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "other path",
+			bypass:         types.Bypass{Paths: []string{"/metrics"}, Middlewares: []string{"auth"}},
+			path:           "/app",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:           "other path with credentials",
+			bypass:         types.Bypass{Paths: []string{"/metrics"}, Middlewares: []string{"auth"}},
+			path:           "/app",
+			credentials:    true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "other middleware bypassed",
+			bypass:         types.Bypass{Paths: []string{"/metrics"}, Middlewares: []string{"maxInFlightReq"}},
+			path:           "/metrics",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:           "unknown middleware",
+			bypass:         types.Bypass{Paths: []string{"/metrics"}, Middlewares: []string{"headers"}},
+			path:           "/metrics",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "malformed pattern",
+			bypass:         types.Bypass{Paths: []string{"/metrics["}, Middlewares: []string{"auth"}},
+			path:           "/metrics",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			frontend := buildFrontend(withRoute("/", "PathPrefix:/"))
+			frontend.BasicAuth = []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}
+			frontend.MaxInFlightReq = &types.MaxInFlightReq{Amount: 10}
+			frontend.Bypasses = map[string]types.Bypass{"probes": test.bypass}
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withBackend("backend", buildBackend(withServer("testServer", testServer.URL))),
+			)
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.credentials {
+				request.SetBasicAuth("test", "test")
+			}
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func buildDynamicConfig(dynamicConfigBuilders ...func(*types.Configuration)) *types.Configuration {
+	config := &types.Configuration{
+		Frontends: make(map[string]*types.Frontend),
+		Backends:  make(map[string]*types.Backend),
+	}
+	for _, build := range dynamicConfigBuilders {
+		build(config)
+	}
+	return config
+}
+
+func withFrontend(frontendName string, frontend *types.Frontend) func(*types.Configuration) {
+	return func(config *types.Configuration) {
+		config.Frontends[frontendName] = frontend
+	}
+}
+
+func withChain(chainName string, chain *types.Chain) func(*types.Configuration) {
+	return func(config *types.Configuration) {
+		if config.Chains == nil {
+			config.Chains = make(map[string]*types.Chain)
+		}
+		config.Chains[chainName] = chain
+	}
+}
+
+func withBackend(backendName string, backend *types.Backend) func(*types.Configuration) {
+	return func(config *types.Configuration) {
+		config.Backends[backendName] = backend
+	}
+}
+
+func buildFrontend(frontendBuilders ...func(*types.Frontend)) *types.Frontend {
+	fe := &types.Frontend{
+		EntryPoints: []string{"http"},
+		Backend:     "backend",
+		Routes:      make(map[string]types.Route),
+	}
+	for _, build := range frontendBuilders {
+		build(fe)
+	}
+	return fe
+}
+
+func withRoute(routeName, rule string) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.Routes[routeName] = types.Route{Rule: rule}
+	}
+}
+
+func withChains(chainNames ...string) func(*types.Frontend) {
+	return func(fe *types.Frontend) {
+		fe.Chains = chainNames
+	}
+}
+
+func buildBackend(backendBuilders ...func(*types.Backend)) *types.Backend {
+	be := &types.Backend{
+		Servers:      make(map[string]types.Server),
+		LoadBalancer: &types.LoadBalancer{Method: "Wrr"},
+	}
+	for _, build := range backendBuilders {
+		build(be)
+	}
+	return be
+}
+
+func withServer(name, url string) func(backend *types.Backend) {
+	return func(be *types.Backend) {
+		be.Servers[name] = types.Server{URL: url}
+	}
+}
+
+func withLoadBalancer(method string, sticky bool) func(*types.Backend) {
+	return func(be *types.Backend) {
+		be.LoadBalancer = &types.LoadBalancer{Method: method, Sticky: sticky}
+	}
+}
+
+func TestGetForwardingTimeouts(t *testing.T) {
+	globalTimeouts := &ForwardingTimeouts{
+		DialTimeout:           flaeg.Duration(10 * time.Second),
+		ResponseHeaderTimeout: flaeg.Duration(20 * time.Second),
+	}
+
+	testCases := []struct {
+		desc             string
+		backend          *types.Backend
+		globalTimeouts   *ForwardingTimeouts
+		expectedTimeouts ForwardingTimeouts
+	}{
+		{
+			desc:             "no global timeouts",
+			backend:          &types.Backend{},
+			expectedTimeouts: ForwardingTimeouts{DialTimeout: flaeg.Duration(DefaultDialTimeout)},
+		},
+		{
+			desc:             "global timeouts",
+			backend:          &types.Backend{},
+			globalTimeouts:   globalTimeouts,
+			expectedTimeouts: *globalTimeouts,
+		},
+		{
+			desc: "backend timeouts",
+			backend: &types.Backend{
+				ForwardingTimeouts: &types.ForwardingTimeouts{
+					ResponseHeaderTimeout: "5s",
+					ForwardingTimeout:     "1m",
+				},
+			},
+			globalTimeouts: globalTimeouts,
+			expectedTimeouts: ForwardingTimeouts{
+				DialTimeout:           flaeg.Duration(10 * time.Second),
+				ResponseHeaderTimeout: flaeg.Duration(5 * time.Second),
+				ForwardingTimeout:     flaeg.Duration(time.Minute),
+			},
+		},
+		{
+			desc: "illegal backend timeouts",
+			backend: &types.Backend{
+				ForwardingTimeouts: &types.ForwardingTimeouts{
+					DialTimeout:           "-1s",
+					ResponseHeaderTimeout: "foo",
+				},
+			},
+			globalTimeouts:   globalTimeouts,
+			expectedTimeouts: *globalTimeouts,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedTimeouts, getForwardingTimeouts("backend", test.backend, test.globalTimeouts))
+		})
+	}
+}
+
+func TestGetRetryBackoff(t *testing.T) {
+	globalRetry := &Retry{
+		Backoff:            "exponential",
+		BackoffInterval:    flaeg.Duration(100 * time.Millisecond),
+		MaxBackoffInterval: flaeg.Duration(time.Second),
+	}
+
+	testCases := []struct {
+		desc            string
+		retryBackoff    *types.RetryBackoff
+		globalRetry     *Retry
+		expectedBackoff middlewares.RetryBackoff
+	}{
+		{
+			desc:            "no backoff",
+			globalRetry:     &Retry{},
+			expectedBackoff: middlewares.RetryBackoff{},
+		},
+		{
+			desc:        "global backoff",
+			globalRetry: globalRetry,
+			expectedBackoff: middlewares.RetryBackoff{
+				Exponential: true,
+				Interval:    100 * time.Millisecond,
+				MaxInterval: time.Second,
+			},
+		},
+		{
+			desc: "frontend backoff",
+			retryBackoff: &types.RetryBackoff{
+				Mode:     "constant",
+				Interval: "50ms",
+			},
+			globalRetry: globalRetry,
+			expectedBackoff: middlewares.RetryBackoff{
+				Interval:    50 * time.Millisecond,
+				MaxInterval: time.Second,
+			},
+		},
+		{
+			desc: "illegal frontend backoff",
+			retryBackoff: &types.RetryBackoff{
+				Interval:    "-1s",
+				MaxInterval: "foo",
+			},
+			globalRetry: globalRetry,
+			expectedBackoff: middlewares.RetryBackoff{
+				Exponential: true,
+				Interval:    100 * time.Millisecond,
+				MaxInterval: time.Second,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expectedBackoff, getRetryBackoff("frontend", test.retryBackoff, test.globalRetry))
+		})
+	}
+}
+
+func TestCreateHTTPTransportResponseHeaderTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-req.Context().Done():
+			}
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	transport := createHTTPTransport(nil, ForwardingTimeouts{
+		DialTimeout:           flaeg.Duration(5 * time.Second),
+		ResponseHeaderTimeout: flaeg.Duration(200 * time.Millisecond),
+	}, nil, false)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/fast", nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/slow", nil)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = transport.RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the request should have been interrupted by the response header timeout")
+	if netErr, ok := err.(net.Error); assert.True(t, ok, "error %v should be a net.Error", err) {
+		assert.True(t, netErr.Timeout())
+	}
+}
+
+func TestCreateHTTPTransportForwardingTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		select {
+		case <-time.After(5 * time.Second):
+		case <-req.Context().Done():
+		}
+	}))
+	defer ts.Close()
+
+	transport := createHTTPTransport(nil, ForwardingTimeouts{
+		DialTimeout:       flaeg.Duration(5 * time.Second),
+		ForwardingTimeout: flaeg.Duration(200 * time.Millisecond),
+	}, nil, false)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err, "the response headers are sent before the forwarding timeout")
+	_, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the body read should have been interrupted by the forwarding timeout")
+}
+
+func TestServerEntryPointUpdateClosesReplacedTransports(t *testing.T) {
+	closed := make(chan bool, 10)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- true
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("/foo", "Path:/foo"))),
+		withBackend("backend", buildBackend(withServer("server", ts.URL))),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+	entryPoint := srv.setupServerEntryPoint("http", srv.serverEntryPoints["http"])
+	load := func() {
+		entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+		require.NoError(t, err)
+		require.Len(t, entryPoints["http"].transports, 1)
+		entryPoint.update(entryPoints["http"])
+	}
+
+	load()
+	recorder := httptest.NewRecorder()
+	entryPoint.httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/foo", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// The idle connection of the replaced transport is closed by the reload.
+	load()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle connection of the replaced transport should have been closed")
+	}
+}
+
+func TestServerLoadConfigRetryAndCircuitBreakerMetrics(t *testing.T) {
+	_, retryCollector, err := middlewares.NewPrometheusRetryAttemptsCounter()
+	require.NoError(t, err)
+	_, transitionsCollector, err := middlewares.NewPrometheusCircuitBreakerTransitionsCounter()
+	require.NoError(t, err)
+	counterValue := func(collector prometheus.Collector, labels ...string) float64 {
+		metric := &dto.Metric{}
+		require.NoError(t, collector.(*prometheus.CounterVec).WithLabelValues(labels...).Write(metric))
+		return metric.GetCounter().GetValue()
+	}
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	// No metrics backend is configured, the counters being registered anyway.
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+		Retry: &Retry{Attempts: 3},
+	}
+	breaker := buildFrontend(withRoute("breaker", "Path:/breaker"))
+	breaker.Backend = "metrics-breaker"
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("retried", buildFrontend(withRoute("retried", "Path:/retried"))),
+		withFrontend("breaker", breaker),
+		withBackend("backend", buildBackend(withServer("unreachable", unreachable.URL), withLoadBalancer("Wrr", false))),
+		withBackend("metrics-breaker", buildBackend(withServer("unreachable", unreachable.URL), withLoadBalancer("Wrr", false))),
+	)
+	dynamicConfig.Backends["backend"].CircuitBreaker = nil
+	dynamicConfig.Backends["metrics-breaker"].CircuitBreaker = &types.CircuitBreaker{Expression: "NetworkErrorRatio() > 0.5"}
+
+	retriesBefore := counterValue(retryCollector, "backend")
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/retried", nil))
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.Equal(t, retriesBefore+2, counterValue(retryCollector, "backend"), "the request should have been retried twice")
+
+	trippedBefore := counterValue(transitionsCollector, "metrics-breaker", "open")
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/breaker", nil))
+	// The side effects of the transitions are executed asynchronously.
+	deadline := time.Now().Add(5 * time.Second)
+	for counterValue(transitionsCollector, "metrics-breaker", "open") == trippedBefore && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, trippedBefore+1, counterValue(transitionsCollector, "metrics-breaker", "open"))
+
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/breaker", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code, "the circuit breaker should be open")
+}
+
+func TestServerLoadConfigDisableKeepAlives(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		disableKeepAlives   bool
+		expectedConnections int32
+	}{
+		{
+			desc:                "keep-alive by default",
+			expectedConnections: 1,
+		},
+		{
+			desc:                "keep-alive disabled",
+			disableKeepAlives:   true,
+			expectedConnections: 3,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			var connections int32
+			backend := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			}))
+			backend.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt32(&connections, 1)
+				}
+			}
+			backend.Start()
+			defer backend.Close()
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("test", "Path:/test"))),
+				withBackend("backend", buildBackend(withServer("server", backend.URL), withLoadBalancer("Wrr", false))),
+			)
+			dynamicConfig.Backends["backend"].DisableKeepAlives = test.disableKeepAlives
+
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				recorder := httptest.NewRecorder()
+				entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/test", nil))
+				require.Equal(t, http.StatusOK, recorder.Code)
+			}
+			assert.Equal(t, test.expectedConnections, atomic.LoadInt32(&connections))
+		})
+	}
+}
+
+func TestServerLoadConfigMaxRequestBodyBytes(t *testing.T) {
+	var received int64
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		size, _ := io.Copy(ioutil.Discard, req.Body)
+		atomic.StoreInt64(&received, size)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{MaxRequestBodyBytes: 1024},
+		},
+	}
+	uploads := buildFrontend(withRoute("uploads", "Path:/uploads"))
+	uploads.MaxRequestBodyBytes = 4096
+	unlimited := buildFrontend(withRoute("unlimited", "Path:/unlimited"))
+	unlimited.MaxRequestBodyBytes = -1
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("api", buildFrontend(withRoute("api", "Path:/api"))),
+		withFrontend("uploads", uploads),
+		withFrontend("unlimited", unlimited),
+		withBackend("backend", buildBackend(withServer("server", backend.URL), withLoadBalancer("Wrr", false))),
+	)
+
+	srv := NewServer(globalConfig)
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	srv.serverEntryPoints = entryPoints
+	frontend := httptest.NewServer(srv.setupServerEntryPoint("http", entryPoints["http"]).httpServer.Handler)
+	defer frontend.Close()
+
+	testCases := []struct {
+		desc           string
+		path           string
+		size           int
+		chunked        bool
+		expectedStatus int
+	}{
+		{
+			desc:           "content length under the entry point limit",
+			path:           "/api",
+			size:           1024,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "content length over the entry point limit",
+			path:           "/api",
+			size:           1025,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "chunked under the entry point limit",
+			path:           "/api",
+			size:           1024,
+			chunked:        true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "chunked over the entry point limit",
+			path:           "/api",
+			size:           64 * 1024,
+			chunked:        true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "content length under the frontend limit",
+			path:           "/uploads",
+			size:           4096,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "chunked over the frontend limit",
+			path:           "/uploads",
+			size:           64 * 1024,
+			chunked:        true,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:           "limit disabled for the frontend",
+			path:           "/unlimited",
+			size:           64 * 1024,
+			chunked:        true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			atomic.StoreInt64(&received, -1)
+			var body io.Reader = bytes.NewReader(make([]byte, test.size))
+			if test.chunked {
+				// Hide the size of the body, for it to be sent chunked.
+				body = ioutil.NopCloser(body)
+			}
+			req, err := http.NewRequest(http.MethodPost, frontend.URL+test.path, body)
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			if test.expectedStatus == http.StatusOK {
+				assert.EqualValues(t, test.size, atomic.LoadInt64(&received))
+			} else {
+				assert.True(t, atomic.LoadInt64(&received) < int64(test.size), "the whole body should not have been forwarded")
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigHTTPFallback(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		desc           string
+		httpFallback   bool
+		expectedStatus int
+	}{
+		{
+			desc:           "https only by default",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			desc:           "fallback to HTTP",
+			httpFallback:   true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", buildFrontend(withRoute("test", "Path:/test"))),
+				withBackend("backend", buildBackend(withServer("server", strings.Replace(backend.URL, "http://", "https://", 1)), withLoadBalancer("Wrr", false))),
+			)
+			dynamicConfig.Backends["backend"].HTTPFallback = test.httpFallback
+
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/test", nil))
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestServerLoadConfigFallbackBackend(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+			rw.Header().Set("X-Path", req.URL.Path)
+			rw.WriteHeader(http.StatusOK)
+		}))
+	}
+	api := newTestServer("api")
+	defer api.Close()
+	spa := newTestServer("spa")
+	defer spa.Close()
+	other := newTestServer("other")
+	defer other.Close()
+
+	testCases := []struct {
+		desc           string
+		rule           string
+		host           string
+		path           string
+		expectedStatus int
+		expectedServer string
+		expectedPath   string
+	}{
+		{
+			desc:           "known path",
+			rule:           "Host:foo.bar;PathPrefixStrip:/api",
+			host:           "foo.bar",
+			path:           "/api/users",
+			expectedStatus: http.StatusOK,
+			expectedServer: "api",
+			expectedPath:   "/users",
+		},
+		{
+			desc:           "unknown sub-path",
+			rule:           "Host:foo.bar;PathPrefixStrip:/api",
+			host:           "foo.bar",
+			path:           "/dashboard/settings",
+			expectedStatus: http.StatusOK,
+			expectedServer: "spa",
+			expectedPath:   "/dashboard/settings",
+		},
+		{
+			desc:           "path of another frontend",
+			rule:           "Host:foo.bar;PathPrefixStrip:/api",
+			host:           "foo.bar",
+			path:           "/other",
+			expectedStatus: http.StatusOK,
+			expectedServer: "other",
+			expectedPath:   "/other",
+		},
+		{
+			desc:           "another host",
+			rule:           "Host:foo.bar;PathPrefixStrip:/api",
+			host:           "test.bar",
+			path:           "/dashboard",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "without host rule",
+			rule:           "PathPrefixStrip:/api",
+			host:           "foo.bar",
+			path:           "/api/users",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			frontend := buildFrontend(withRoute("api", test.rule))
+			frontend.FallbackBackend = "spa"
+			otherFrontend := buildFrontend(withRoute("other", "Host:foo.bar;Path:/other"))
+			otherFrontend.Backend = "other"
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withFrontend("other", otherFrontend),
+				withBackend("backend", buildBackend(withServer("api", api.URL), withLoadBalancer("Wrr", false))),
+				withBackend("spa", buildBackend(withServer("spa", spa.URL), withLoadBalancer("Wrr", false))),
+				withBackend("other", buildBackend(withServer("other", other.URL), withLoadBalancer("Wrr", false))),
+			)
+
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "http://"+test.host+test.path, nil)
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			require.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedServer, recorder.Header().Get("X-Server"))
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(t, test.expectedPath, recorder.Header().Get("X-Path"))
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigContentNegotiation(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(name))
+		}))
+	}
+	v1 := newBackend("v1")
+	defer v1.Close()
+	v2 := newBackend("v2")
+	defer v2.Close()
+	upload := newBackend("upload")
+	defer upload.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	v2Frontend := buildFrontend(withRoute("v2", "PathPrefix:/api;Accepts:application/vnd.api.v2+json"))
+	v2Frontend.Backend = "v2"
+	uploadFrontend := buildFrontend(withRoute("upload", "PathPrefix:/api;ContentType:multipart/*"))
+	uploadFrontend.Backend = "upload"
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("v1", buildFrontend(withRoute("v1", "PathPrefix:/api"))),
+		withFrontend("v2", v2Frontend),
+		withFrontend("upload", uploadFrontend),
+		withBackend("backend", buildBackend(withServer("v1", v1.URL), withLoadBalancer("Wrr", false))),
+		withBackend("v2", buildBackend(withServer("v2", v2.URL), withLoadBalancer("Wrr", false))),
+		withBackend("upload", buildBackend(withServer("upload", upload.URL), withLoadBalancer("Wrr", false))),
+	)
+
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc            string
+		accept          string
+		contentType     string
+		expectedBackend string
+	}{
+		{
+			desc:            "version 2 accepted",
+			accept:          "application/vnd.api.v2+json",
+			expectedBackend: "v2",
+		},
+		{
+			desc:            "version 2 preferred",
+			accept:          "application/json;q=0.5, application/vnd.api.v2+json",
+			expectedBackend: "v2",
+		},
+		{
+			desc:            "json preferred",
+			accept:          "application/json, application/vnd.api.v2+json;q=0.5",
+			expectedBackend: "v1",
+		},
+		{
+			desc:            "anything accepted",
+			accept:          "*/*",
+			expectedBackend: "v1",
+		},
+		{
+			desc:            "multipart content",
+			contentType:     "multipart/form-data; boundary=frontier",
+			expectedBackend: "upload",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "http://foo.bar/api/users", nil)
+			if len(test.accept) > 0 {
+				request.Header.Set("Accept", test.accept)
+			}
+			if len(test.contentType) > 0 {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expectedBackend, recorder.Body.String())
+		})
+	}
+}
+
+func TestServerLoadConfigRouteHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	api := buildFrontend(withRoute("api", "Host:foo.bar;PathPrefix:/api"))
+	api.Backend = "api"
+	api.FallbackBackend = "spa"
+	api.RouteHeaders = true
+	admin := buildFrontend(withRoute("admin", "Host:foo.bar;Path:/admin"))
+	admin.Backend = "admin"
+	admin.RouteHeaders = true
+	plain := buildFrontend(withRoute("plain", "Host:foo.bar;Path:/plain"))
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("api", api),
+		withFrontend("admin", admin),
+		withFrontend("plain", plain),
+		withBackend("api", buildBackend(withServer("api", backend.URL), withLoadBalancer("Wrr", false))),
+		withBackend("spa", buildBackend(withServer("spa", backend.URL), withLoadBalancer("Wrr", false))),
+		withBackend("admin", buildBackend(withServer("admin", backend.URL), withLoadBalancer("Wrr", false))),
+		withBackend("backend", buildBackend(withServer("plain", backend.URL), withLoadBalancer("Wrr", false))),
+	)
+
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc             string
+		path             string
+		expectedFrontend string
+		expectedBackend  string
+	}{
+		{
+			desc:             "api frontend",
+			path:             "/api/users",
+			expectedFrontend: "api",
+			expectedBackend:  "api",
+		},
+		{
+			desc:             "admin frontend",
+			path:             "/admin",
+			expectedFrontend: "admin",
+			expectedBackend:  "admin",
+		},
+		{
+			desc:             "fallback backend",
+			path:             "/dashboard",
+			expectedFrontend: "api",
+			expectedBackend:  "spa",
+		},
+		{
+			desc: "disabled by default",
+			path: "/plain",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "http://foo.bar"+test.path, nil)
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expectedFrontend, recorder.Header().Get(middlewares.RouteHeaderFrontend))
+			assert.Equal(t, test.expectedBackend, recorder.Header().Get(middlewares.RouteHeaderBackend))
+			if len(test.expectedFrontend) == 0 {
+				assert.NotContains(t, recorder.Header(), middlewares.RouteHeaderFrontend)
+				assert.NotContains(t, recorder.Header(), middlewares.RouteHeaderBackend)
+			}
+		})
+	}
+}
+
+func TestServerLoadConfigDebugHeader(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+	}
+	api := buildFrontend(withRoute("host", "Host:foo.bar"), withRoute("path", "PathPrefix:/api"))
+	api.DebugHeader = true
+	plain := buildFrontend(withRoute("plain", "Host:foo.bar;Path:/plain"))
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("api", api),
+		withFrontend("plain", plain),
+		withBackend("backend", buildBackend(withServer("server", backend.URL), withLoadBalancer("Wrr", false))),
+	)
+
+	entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/api/users", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	expected := regexp.MustCompile(`^rule="Host:foo.bar;PathPrefix:/api"; server="` + regexp.QuoteMeta(backend.URL) + `"; selection=\S+s$`)
+	assert.Regexp(t, expected, recorder.Header().Get(middlewares.DebugHeader))
+
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://foo.bar/plain", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotContains(t, recorder.Header(), middlewares.DebugHeader, "the header should be disabled by default")
+}
+
+func TestServerLoadConfigTrailingSlash(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Path", req.URL.Path)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		desc             string
+		trailingSlash    string
+		rule             string
+		path             string
+		expectedStatus   int
+		expectedPath     string
+		expectedLocation string
+	}{
+		{
+			desc:           "strict path",
+			rule:           "Path:/products",
+			path:           "/products",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products",
+		},
+		{
+			desc:             "strict path with a trailing slash",
+			rule:             "Path:/products",
+			path:             "/products/",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "http://foo.bar/products",
+		},
+		{
+			desc:           "strict prefix without trailing slash",
+			trailingSlash:  types.TrailingSlashStrict,
+			rule:           "PathPrefix:/products/",
+			path:           "/products",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "equivalent path with a trailing slash",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "Path:/products",
+			path:           "/products/",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products/",
+		},
+		{
+			desc:           "equivalent path without trailing slash",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "Path:/products/",
+			path:           "/products",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products",
+		},
+		{
+			desc:           "equivalent path as written",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "Path:/products/",
+			path:           "/products/",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products/",
+		},
+		{
+			desc:           "equivalent prefix without trailing slash",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "PathPrefix:/products/",
+			path:           "/products",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products",
+		},
+		{
+			desc:           "equivalent prefix sub-path",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "PathPrefix:/products/",
+			path:           "/products/shoes",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products/shoes",
+		},
+		{
+			desc:           "equivalent prefix of another path",
+			trailingSlash:  types.TrailingSlashEquivalent,
+			rule:           "PathPrefix:/products/",
+			path:           "/productsx",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:             "redirected path without trailing slash",
+			trailingSlash:    types.TrailingSlashRedirect,
+			rule:             "Path:/products/",
+			path:             "/products?sort=asc",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "http://foo.bar/products/?sort=asc",
+		},
+		{
+			desc:             "redirected prefix without trailing slash",
+			trailingSlash:    types.TrailingSlashRedirect,
+			rule:             "Host:foo.bar;PathPrefix:/products/",
+			path:             "/products?sort=asc",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "http://foo.bar/products/?sort=asc",
+		},
+		{
+			desc:           "redirected prefix with a trailing slash",
+			trailingSlash:  types.TrailingSlashRedirect,
+			rule:           "PathPrefix:/products/",
+			path:           "/products/",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/products/",
+		},
+		{
+			desc:           "invalid mode",
+			trailingSlash:  "loose",
+			rule:           "Path:/products",
+			path:           "/products",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			frontend := buildFrontend(withRoute("products", test.rule))
+			frontend.TrailingSlash = test.trailingSlash
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withBackend("backend", buildBackend(withServer("server", backend.URL), withLoadBalancer("Wrr", false))),
+			)
+
+			entryPoints, err := NewServer(globalConfig).loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "http://foo.bar"+test.path, nil)
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			require.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedPath, recorder.Header().Get("X-Path"))
+			assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServerLoadConfigMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Server", "primary")
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer primary.Close()
+	mirrored := make(chan string, 10)
+	mirror := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		mirrored <- string(body)
+		rw.Header().Set("X-Server", "mirror")
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mirror.Close()
+
+	frontend := buildFrontend(withRoute("/app", "PathPrefix:/app"))
+	frontend.Mirror = &types.Mirror{Backend: "mirror", Percent: 50}
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", frontend),
+		withBackend("backend", buildBackend(withServer("primary", primary.URL), withLoadBalancer("Wrr", false))),
+		withBackend("mirror", buildBackend(withServer("mirror", mirror.URL), withLoadBalancer("Wrr", false))),
+	)
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{},
+		},
+		AccessLog: &types.AccessLog{FilePath: filepath.Join(os.TempDir(), "traefik-mirror-access.log"), Format: "common"},
+	}
+	defer os.Remove(globalConfig.AccessLog.FilePath)
+	srv := NewServer(globalConfig)
+	defer srv.accessLoggerMiddleware.Close()
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	handler := negroni.New(srv.accessLoggerMiddleware)
+	handler.UseHandler(entryPoints["http"].httpRouter)
+
+	for i := 0; i < 4; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/app", strings.NewReader("payload")))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "primary", recorder.Header().Get("X-Server"))
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case body := <-mirrored:
+			assert.Equal(t, "payload", body)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the mirror did not receive its share of the requests")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, mirrored)
+}
+
+func TestServerLoadConfigCanary(t *testing.T) {
+	newTestServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Server", name)
+			rw.WriteHeader(http.StatusOK)
+		}))
+	}
+	stable := newTestServer("stable")
+	defer stable.Close()
+	canary := newTestServer("canary")
+	defer canary.Close()
+
+	testCases := []struct {
+		desc           string
+		canary         *types.Canary
+		header         string
+		cookie         *http.Cookie
+		expectedServer string
+	}{
+		{
+			desc:           "default stable routing",
+			canary:         &types.Canary{Backend: "canary", Headers: map[string]string{"X-Canary": "true"}},
+			expectedServer: "stable",
+		},
+		{
+			desc:           "header forced canary",
+			canary:         &types.Canary{Backend: "canary", Headers: map[string]string{"X-Canary": "true"}},
+			header:         "true",
+			expectedServer: "canary",
+		},
+		{
+			desc:           "header with another value",
+			canary:         &types.Canary{Backend: "canary", Headers: map[string]string{"X-Canary": "true"}},
+			header:         "false",
+			expectedServer: "stable",
+		},
+		{
+			desc:           "cookie forced canary",
+			canary:         &types.Canary{Backend: "canary", Cookies: map[string]string{"canary": ""}},
+			cookie:         &http.Cookie{Name: "canary", Value: "1"},
+			expectedServer: "canary",
+		},
+		{
+			desc:           "percentage",
+			canary:         &types.Canary{Backend: "canary", Percent: 100},
+			expectedServer: "canary",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{},
+				},
+			}
+			frontend := buildFrontend(withRoute("/app", "PathPrefix:/app"))
+			frontend.Canary = test.canary
+			dynamicConfig := buildDynamicConfig(
+				withFrontend("frontend", frontend),
+				withBackend("backend", buildBackend(withServer("stable", stable.URL), withLoadBalancer("Wrr", false))),
+				withBackend("canary", buildBackend(withServer("canary", canary.URL), withLoadBalancer("Wrr", false))),
+			)
+
+			srv := NewServer(globalConfig)
+			entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/app", nil)
+			if test.header != "" {
+				request.Header.Set("X-Canary", test.header)
+			}
+			if test.cookie != nil {
+				request.AddCookie(test.cookie)
+			}
+			entryPoints["http"].httpRouter.ServeHTTP(recorder, request)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expectedServer, recorder.Header().Get("X-Server"))
+		})
 	}
 }
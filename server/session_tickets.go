@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+const (
+	// sessionTicketDefaultRotationInterval is the default interval between two rotations of the session ticket keys.
+	sessionTicketDefaultRotationInterval = 12 * time.Hour
+	// sessionTicketGeneratedKeys is the number of generated keys decrypting the session tickets, the first one
+	// encrypting the new ones, a ticket being resumed until its key is rotated out.
+	sessionTicketGeneratedKeys = 3
+)
+
+// sessionTicketKeys holds the keys encrypting the TLS session tickets of an entrypoint, generated and rotated every
+// interval, or read from a file shared by several instances and reloaded every interval.
+// The keys are set on the TLS configurations of the entrypoint, and on the configurations returned to the clients
+// by their GetConfigForClient: the rotations apply to the live connections without dropping them.
+type sessionTicketKeys struct {
+	keysFile string
+	interval time.Duration
+
+	lock sync.Mutex
+	keys [][32]byte
+	// generation is incremented by each rotation of the keys.
+	generation int
+	configs    []*tls.Config
+}
+
+func newSessionTicketKeys(sessionTickets *SessionTickets) (*sessionTicketKeys, error) {
+	ticketKeys := &sessionTicketKeys{
+		keysFile: sessionTickets.KeysFile,
+		interval: time.Duration(sessionTickets.RotationInterval),
+	}
+	if ticketKeys.interval < 0 {
+		return nil, fmt.Errorf("session ticket rotation interval must not be negative, got %s", ticketKeys.interval)
+	}
+	if ticketKeys.interval == 0 {
+		ticketKeys.interval = sessionTicketDefaultRotationInterval
+	}
+	if err := ticketKeys.rotate(); err != nil {
+		return nil, err
+	}
+	return ticketKeys, nil
+}
+
+// apply sets the keys of the TLS configuration, and of the configurations returned by its GetConfigForClient, if any,
+// for the rotations to apply to them.
+func (k *sessionTicketKeys) apply(config *tls.Config) {
+	k.lock.Lock()
+	k.configs = append(k.configs, config)
+	config.SetSessionTicketKeys(k.keys)
+	k.lock.Unlock()
+
+	getConfigForClient := config.GetConfigForClient
+	if getConfigForClient == nil {
+		return
+	}
+	// The configurations returned to the clients, e.g. the ones with the OCSP staples, are replaced over time:
+	// the keys are set on the last one when it or the keys changed.
+	var last *tls.Config
+	var generation int
+	config.GetConfigForClient = func(clientHello *tls.ClientHelloInfo) (*tls.Config, error) {
+		clientConfig, err := getConfigForClient(clientHello)
+		if clientConfig == nil {
+			return clientConfig, err
+		}
+		k.lock.Lock()
+		defer k.lock.Unlock()
+		if clientConfig != last || generation != k.generation {
+			clientConfig.SetSessionTicketKeys(k.keys)
+			last = clientConfig
+			generation = k.generation
+		}
+		return clientConfig, err
+	}
+}
+
+// run rotates the keys every interval until stop is closed.
+func (k *sessionTicketKeys) run(stop chan bool) {
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := k.rotate(); err != nil {
+				log.Warnf("Error rotating the session ticket keys, keeping the current ones: %s", err)
+			}
+		}
+	}
+}
+
+// rotate reloads the keys from the file, or generates a new one encrypting the new tickets, the oldest one being dropped.
+func (k *sessionTicketKeys) rotate() error {
+	var keys [][32]byte
+	if len(k.keysFile) > 0 {
+		var err error
+		if keys, err = readSessionTicketKeys(k.keysFile); err != nil {
+			return err
+		}
+	} else {
+		var key [32]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		keys = append([][32]byte{key}, k.keys...)
+		if len(keys) > sessionTicketGeneratedKeys {
+			keys = keys[:sessionTicketGeneratedKeys]
+		}
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.keys = keys
+	k.generation++
+	for _, config := range k.configs {
+		config.SetSessionTicketKeys(keys)
+	}
+	return nil
+}
+
+// readSessionTicketKeys reads the keys of a file, one base64 encoded 32 bytes key per line, the empty lines being ignored.
+func readSessionTicketKeys(keysFile string) ([][32]byte, error) {
+	data, err := ioutil.ReadFile(keysFile)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][32]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		value := strings.TrimSpace(scanner.Text())
+		if len(value) == 0 {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session ticket key at line %d of %s: %s", line, keysFile, err)
+		}
+		var key [32]byte
+		if len(decoded) != len(key) {
+			return nil, fmt.Errorf("invalid session ticket key at line %d of %s: %d bytes instead of %d", line, keysFile, len(decoded), len(key))
+		}
+		copy(key[:], decoded)
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no session ticket key in " + keysFile)
+	}
+	return keys, nil
+}
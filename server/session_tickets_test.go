@@ -0,0 +1,200 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containous/flaeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSessionTicketKeys writes a keys file with the keys, each one made of a repeated byte.
+func writeSessionTicketKeys(t *testing.T, path string, keys ...byte) {
+	var lines []string
+	for _, key := range keys {
+		lines = append(lines, base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(key), 32))))
+	}
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n\n")+"\n"), 0600))
+}
+
+// serveSessionTickets serves the TLS configuration, returning the address of the server and the function closing it.
+func serveSessionTickets(t *testing.T, config *tls.Config) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})}
+	go srv.Serve(tls.NewListener(listener, config))
+	return listener.Addr().String(), func() { srv.Close() }
+}
+
+// resumed sends a request to the address on a new connection, and returns whether the TLS session was resumed.
+func resumed(t *testing.T, cache tls.ClientSessionCache, addr string) bool {
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ServerName: "ocsp.test", ClientSessionCache: cache},
+		DisableKeepAlives: true,
+	}}
+	resp, err := client.Get("https://" + addr)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.TLS.DidResume
+}
+
+func newSessionTicketsTestConfig(t *testing.T, ticketKeys *sessionTicketKeys) *tls.Config {
+	cert, _ := newOCSPTestCertificate(t, "")
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	ticketKeys.apply(config)
+	return config
+}
+
+func TestSessionTicketKeysGenerated(t *testing.T) {
+	ticketKeys, err := newSessionTicketKeys(&SessionTickets{})
+	require.NoError(t, err)
+	assert.Equal(t, sessionTicketDefaultRotationInterval, ticketKeys.interval)
+	require.Len(t, ticketKeys.keys, 1)
+
+	// The configuration returned to the clients by GetConfigForClient keeps the rotated keys.
+	cert, _ := newOCSPTestCertificate(t, "")
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	clientConfig := config.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return clientConfig, nil
+	}
+	ticketKeys.apply(config)
+	addr, closeServer := serveSessionTickets(t, config)
+	defer closeServer()
+	cache := tls.NewLRUClientSessionCache(10)
+	assert.False(t, resumed(t, cache, addr))
+	assert.True(t, resumed(t, cache, addr))
+
+	expiredCache := tls.NewLRUClientSessionCache(10)
+	assert.False(t, resumed(t, expiredCache, addr))
+	first := ticketKeys.keys[0]
+	for i := 1; i < sessionTicketGeneratedKeys; i++ {
+		require.NoError(t, ticketKeys.rotate())
+		assert.Len(t, ticketKeys.keys, i+1)
+		assert.NotEqual(t, first, ticketKeys.keys[0], "a new key must encrypt the new tickets")
+		assert.Equal(t, first, ticketKeys.keys[i])
+	}
+	assert.True(t, resumed(t, cache, addr), "the tickets of the previous keys must still be resumed")
+
+	require.NoError(t, ticketKeys.rotate())
+	assert.Len(t, ticketKeys.keys, sessionTicketGeneratedKeys)
+	assert.False(t, resumed(t, expiredCache, addr), "the tickets of the dropped keys must not be resumed")
+}
+
+func TestSessionTicketKeysFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session-tickets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keysFile := filepath.Join(dir, "ticket.keys")
+	writeSessionTicketKeys(t, keysFile, 'a', 'b')
+
+	sessionTickets := &SessionTickets{KeysFile: keysFile, RotationInterval: flaeg.Duration(1)}
+	instance1, err := newSessionTicketKeys(sessionTickets)
+	require.NoError(t, err)
+	require.Len(t, instance1.keys, 2)
+	instance2, err := newSessionTicketKeys(sessionTickets)
+	require.NoError(t, err)
+
+	// The instances sharing the keys resume the sessions of each other.
+	addr1, closeServer1 := serveSessionTickets(t, newSessionTicketsTestConfig(t, instance1))
+	defer closeServer1()
+	addr2, closeServer2 := serveSessionTickets(t, newSessionTicketsTestConfig(t, instance2))
+	defer closeServer2()
+	cache := tls.NewLRUClientSessionCache(10)
+	assert.False(t, resumed(t, cache, addr1))
+	assert.True(t, resumed(t, cache, addr2))
+
+	// The rotated file is reloaded, the tickets of its previous keys still being resumed.
+	writeSessionTicketKeys(t, keysFile, 'c', 'a')
+	require.NoError(t, instance1.rotate())
+	assert.True(t, resumed(t, cache, addr1))
+
+	writeSessionTicketKeys(t, keysFile, 'd')
+	require.NoError(t, instance1.rotate())
+	require.NoError(t, instance2.rotate())
+	assert.False(t, resumed(t, cache, addr2), "the tickets of the removed keys must not be resumed")
+
+	// The current keys are kept when the file cannot be reloaded.
+	require.NoError(t, os.Remove(keysFile))
+	assert.Error(t, instance1.rotate())
+	assert.True(t, resumed(t, cache, addr1))
+}
+
+func TestServerEntrypointSessionTickets(t *testing.T) {
+	cert, _ := newOCSPTestCertificate(t, "")
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	certificates := Certificates{{
+		CertFile: FileOrContent(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})),
+		KeyFile:  FileOrContent(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}}
+	dir, err := ioutil.TempDir("", "session-tickets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keysFile := filepath.Join(dir, "ticket.keys")
+	writeSessionTicketKeys(t, keysFile, 'a')
+	sessionTickets := &SessionTickets{KeysFile: keysFile}
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"https":       {Address: "127.0.0.1:0", TLS: &TLS{Certificates: certificates, SessionTickets: sessionTickets}},
+			"https-other": {Address: "127.0.0.1:0", TLS: &TLS{Certificates: certificates, SessionTickets: sessionTickets}},
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+	var httpServers []*http.Server
+	defer func() {
+		for _, httpServer := range httpServers {
+			httpServer.Close()
+		}
+	}()
+	start := func(entryPointName string) string {
+		serverEntryPoint := srv.setupServerEntryPoint(entryPointName, srv.serverEntryPoints[entryPointName])
+		httpServer := serverEntryPoint.httpServer
+		listener, err := srv.listenEntryPoint(entryPointName, httpServer, globalConfig)
+		require.NoError(t, err)
+		go srv.startServer(serverEntryPoint, listener)
+		httpServers = append(httpServers, httpServer)
+		return listener.Addr().String()
+	}
+
+	cache := tls.NewLRUClientSessionCache(10)
+	assert.False(t, resumed(t, cache, start("https")))
+	assert.True(t, resumed(t, cache, start("https-other")), "the entrypoints sharing the keys must resume the sessions of each other")
+}
+
+func TestNewSessionTicketKeysInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "session-tickets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	emptyFile := filepath.Join(dir, "empty.keys")
+	require.NoError(t, ioutil.WriteFile(emptyFile, []byte("\n"), 0600))
+	invalidFile := filepath.Join(dir, "invalid.keys")
+	require.NoError(t, ioutil.WriteFile(invalidFile, []byte("not base64\n"), 0600))
+	shortFile := filepath.Join(dir, "short.keys")
+	require.NoError(t, ioutil.WriteFile(shortFile, []byte(base64.StdEncoding.EncodeToString([]byte("short"))), 0600))
+
+	for _, sessionTickets := range []SessionTickets{
+		{KeysFile: filepath.Join(dir, "missing.keys")},
+		{KeysFile: emptyFile},
+		{KeysFile: invalidFile},
+		{KeysFile: shortFile},
+		{RotationInterval: flaeg.Duration(-1)},
+	} {
+		sessionTickets := sessionTickets
+		_, err := newSessionTicketKeys(&sessionTickets)
+		assert.Error(t, err, "%+v", sessionTickets)
+	}
+}
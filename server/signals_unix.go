@@ -0,0 +1,11 @@
+// +build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// rotateLogsSignals are the signals reopening the log files, after they were renamed by a log rotation.
+var rotateLogsSignals = []os.Signal{syscall.SIGUSR1}
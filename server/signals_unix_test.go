@@ -0,0 +1,79 @@
+// +build !windows
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/middlewares/accesslog"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerRotateLogsSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traefik-rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	accessLogPath := filepath.Join(dir, "access.log")
+	traefikLogPath := filepath.Join(dir, "traefik.log")
+
+	require.NoError(t, log.OpenFile(traefikLogPath))
+	defer log.SetOutput(os.Stderr)
+	defer log.CloseFile()
+
+	srv := NewServer(GlobalConfiguration{AccessLog: &types.AccessLog{FilePath: accessLogPath, Format: accesslog.CommonFormat}})
+	require.NotNil(t, srv.accessLoggerMiddleware)
+	defer srv.accessLoggerMiddleware.Close()
+	go srv.listenSignals()
+	defer func() {
+		signal.Stop(srv.signals)
+		close(srv.signals)
+	}()
+
+	logLines := func(path string) {
+		srv.accessLoggerMiddleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil), func(rw http.ResponseWriter, req *http.Request) {})
+		log.Info("Served " + path)
+	}
+	fileContent := func(path string) string {
+		content, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	logLines("/before")
+	require.NoError(t, os.Rename(accessLogPath, accessLogPath+".1"))
+	require.NoError(t, os.Rename(traefikLogPath, traefikLogPath+".1"))
+	// The renamed files keep receiving the logs until they are reopened.
+	logLines("/rotating")
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		_, accessErr := os.Stat(accessLogPath)
+		_, traefikErr := os.Stat(traefikLogPath)
+		if accessErr == nil && traefikErr == nil {
+			break
+		}
+	}
+	logLines("/after")
+
+	for _, path := range []string{accessLogPath, traefikLogPath} {
+		rotated := fileContent(path + ".1")
+		assert.Contains(t, rotated, "/before")
+		assert.Contains(t, rotated, "/rotating")
+		assert.NotContains(t, rotated, "/after")
+
+		current := fileContent(path)
+		assert.Contains(t, current, "/after")
+		assert.NotContains(t, current, "/before")
+	}
+}
@@ -0,0 +1,6 @@
+package server
+
+import "os"
+
+// rotateLogsSignals are the signals reopening the log files, none being available on Windows.
+var rotateLogsSignals []os.Signal
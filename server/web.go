@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"runtime"
+	"strings"
 
 	"github.com/containous/mux"
 	"github.com/containous/traefik/autogen"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/middlewares"
+	traefikprovider "github.com/containous/traefik/provider"
 	"github.com/containous/traefik/safe"
 	"github.com/containous/traefik/types"
 	"github.com/containous/traefik/version"
@@ -37,7 +40,9 @@ type WebProvider struct {
 	Statistics *types.Statistics `description:"Enable more detailed statistics"`
 	Metrics    *types.Metrics    `description:"Enable a metrics exporter"`
 	Path       string            `description:"Root path for dashboard and API"`
+	EntryPoint string            `description:"Entrypoint serving the dashboard and API under their root path, instead of listening on Address"`
 	server     *Server
+	handler    safe.Safe
 	Auth       *types.Auth
 }
 
@@ -45,6 +50,7 @@ var (
 	templatesRenderer = render.New(render.Options{
 		Directory: "nowhere",
 	})
+	dashboardAssets http.FileSystem = &assetfs.AssetFS{Asset: autogen.Asset, AssetInfo: autogen.AssetInfo, AssetDir: autogen.AssetDir, Prefix: "static"}
 )
 
 func init() {
@@ -59,37 +65,41 @@ func goroutines() interface{} {
 // using the given configuration channel.
 func (provider *WebProvider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool, _ types.Constraints) error {
 
-	systemRouter := mux.NewRouter()
-
-	if provider.Path == "" {
-		provider.Path = "/"
+	if len(provider.EntryPoint) > 0 {
+		if _, ok := provider.server.globalConfiguration.EntryPoints[provider.EntryPoint]; !ok {
+			return fmt.Errorf("undefined entrypoint '%s' for the web provider", provider.EntryPoint)
+		}
 	}
 
-	if provider.Path != "/" {
-		if provider.Path[len(provider.Path)-1:] != "/" {
-			provider.Path += "/"
-		}
+	systemRouter := mux.NewRouter()
+
+	path := provider.getPath()
+	// On an entrypoint, the requests outside of the root path are left to the frontends.
+	if path != "/" && len(provider.EntryPoint) == 0 {
 		systemRouter.Methods("GET").Path("/").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-			http.Redirect(response, request, provider.Path, 302)
+			http.Redirect(response, request, path, 302)
 		})
 	}
 
 	// Prometheus route
 	if provider.Metrics != nil && provider.Metrics.Prometheus != nil {
-		systemRouter.Methods("GET").Path(provider.Path + "metrics").Handler(promhttp.Handler())
+		systemRouter.Methods("GET").Path(path + "metrics").Handler(promhttp.Handler())
 	}
 
 	// health route
-	systemRouter.Methods("GET").Path(provider.Path + "health").HandlerFunc(provider.getHealthHandler)
+	systemRouter.Methods("GET").Path(path + "health").HandlerFunc(provider.getHealthHandler)
 
 	// ping route
-	systemRouter.Methods("GET", "HEAD").Path(provider.Path + "ping").HandlerFunc(provider.getPingHandler)
+	systemRouter.Methods("GET", "HEAD").Path(path + "ping").HandlerFunc(provider.getPingHandler)
+	systemRouter.Methods("GET", "HEAD").Path(path + "ready").HandlerFunc(provider.getReadyHandler)
 	// API routes
-	systemRouter.Methods("GET").Path(provider.Path + "api").HandlerFunc(provider.getConfigHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/version").HandlerFunc(provider.getVersionHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers").HandlerFunc(provider.getConfigHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}").HandlerFunc(provider.getProviderHandler)
-	systemRouter.Methods("PUT").Path(provider.Path + "api/providers/{provider}").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+	systemRouter.Methods("GET").Path(path + "api").HandlerFunc(provider.getConfigHandler)
+	systemRouter.Methods("GET").Path(path + "api/version").HandlerFunc(provider.getVersionHandler)
+	systemRouter.Methods("GET").Path(path + "api/loglevel").HandlerFunc(provider.getLogLevelHandler)
+	systemRouter.Methods("PUT").Path(path + "api/loglevel").HandlerFunc(provider.setLogLevelHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers").HandlerFunc(provider.getConfigHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}").HandlerFunc(provider.getProviderHandler)
+	systemRouter.Methods("PUT").Path(path + "api/providers/{provider}").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
 		if provider.ReadOnly {
 			response.WriteHeader(http.StatusForbidden)
 			fmt.Fprint(response, "REST API is in read-only mode")
@@ -113,39 +123,50 @@ func (provider *WebProvider) Provide(configurationChan chan<- types.ConfigMessag
 			http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
 		}
 	})
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends").HandlerFunc(provider.getBackendsHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends/{backend}").HandlerFunc(provider.getBackendHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers").HandlerFunc(provider.getServersHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/backends/{backend}/servers/{server}").HandlerFunc(provider.getServerHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends").HandlerFunc(provider.getFrontendsHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}").HandlerFunc(provider.getFrontendHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes").HandlerFunc(provider.getRoutesHandler)
-	systemRouter.Methods("GET").Path(provider.Path + "api/providers/{provider}/frontends/{frontend}/routes/{route}").HandlerFunc(provider.getRouteHandler)
+	systemRouter.Methods("POST").Path(path + "api/providers/{provider}/refresh").HandlerFunc(provider.refreshProviderHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/backends").HandlerFunc(provider.getBackendsHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/backends/{backend}").HandlerFunc(provider.getBackendHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/backends/{backend}/servers").HandlerFunc(provider.getServersHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/backends/{backend}/servers/{server}").HandlerFunc(provider.getServerHandler)
+	systemRouter.Methods("PUT").Path(path + "api/providers/{provider}/backends/{backend}/servers/{server}/weight").HandlerFunc(provider.setServerWeightHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/frontends").HandlerFunc(provider.getFrontendsHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/frontends/{frontend}").HandlerFunc(provider.getFrontendHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/frontends/{frontend}/routes").HandlerFunc(provider.getRoutesHandler)
+	systemRouter.Methods("GET").Path(path + "api/providers/{provider}/frontends/{frontend}/routes/{route}").HandlerFunc(provider.getRouteHandler)
 
 	// Expose dashboard
-	systemRouter.Methods("GET").Path(provider.Path).HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-		http.Redirect(response, request, provider.Path+"dashboard/", 302)
+	systemRouter.Methods("GET").Path(path).HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.Redirect(response, request, path+"dashboard/", 302)
+	})
+	// The dashboard references its assets and the API relatively, the trailing slash is required to resolve them.
+	systemRouter.Methods("GET").Path(path + "dashboard").HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.Redirect(response, request, path+"dashboard/", 302)
 	})
-	systemRouter.Methods("GET").PathPrefix(provider.Path + "dashboard/").
-		Handler(http.StripPrefix(provider.Path+"dashboard/", http.FileServer(&assetfs.AssetFS{Asset: autogen.Asset, AssetInfo: autogen.AssetInfo, AssetDir: autogen.AssetDir, Prefix: "static"})))
+	systemRouter.Methods("GET").PathPrefix(path + "dashboard/").
+		Handler(http.StripPrefix(path+"dashboard/", http.FileServer(dashboardAssets)))
 
 	// expvars
 	if provider.server.globalConfiguration.Debug {
-		systemRouter.Methods("GET").Path(provider.Path + "debug/vars").HandlerFunc(expvarHandler)
+		systemRouter.Methods("GET").Path(path + "debug/vars").HandlerFunc(expvarHandler)
 	}
 
-	safe.Go(func() {
-		var err error
-		var negroni = negroni.New()
-		if provider.Auth != nil {
-			authMiddleware, err := middlewares.NewAuthenticator(provider.Auth)
-			if err != nil {
-				log.Fatal("Error creating Auth: ", err)
-			}
-			negroni.Use(authMiddleware)
+	var negroni = negroni.New()
+	if provider.Auth != nil {
+		authMiddleware, err := middlewares.NewAuthenticator(provider.Auth, nil)
+		if err != nil {
+			log.Fatal("Error creating Auth: ", err)
 		}
-		negroni.UseHandler(systemRouter)
+		negroni.Use(authMiddleware)
+	}
+	negroni.UseHandler(systemRouter)
+
+	if len(provider.EntryPoint) > 0 {
+		provider.handler.Set(negroni)
+		return nil
+	}
 
+	safe.Go(func() {
+		var err error
 		if len(provider.CertFile) > 0 && len(provider.KeyFile) > 0 {
 			err = http.ListenAndServeTLS(provider.Address, provider.CertFile, provider.KeyFile, negroni)
 		} else {
@@ -159,6 +180,51 @@ func (provider *WebProvider) Provide(configurationChan chan<- types.ConfigMessag
 	return nil
 }
 
+// getPath returns the root path of the dashboard and API, ending with a slash.
+func (provider *WebProvider) getPath() string {
+	if provider.Path == "" {
+		return "/"
+	}
+	if !strings.HasSuffix(provider.Path, "/") {
+		return provider.Path + "/"
+	}
+	return provider.Path
+}
+
+// ServeHTTP serves the dashboard and API on the entrypoint set by EntryPoint.
+func (provider *WebProvider) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	handler, ok := provider.handler.Get().(http.Handler)
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	handler.ServeHTTP(response, request)
+}
+
+// serverResponse is the representation of a backend server, along with its health-check status.
+type serverResponse struct {
+	types.Server
+	Status string `json:"status"`
+}
+
+// backendResponse is the representation of a backend, whose servers carry their status.
+type backendResponse struct {
+	*types.Backend
+	Servers map[string]serverResponse `json:"servers,omitempty"`
+}
+
+func newServersResponse(backendName string, backend *types.Backend) map[string]serverResponse {
+	servers := make(map[string]serverResponse, len(backend.Servers))
+	for serverName, server := range backend.Servers {
+		servers[serverName] = serverResponse{Server: server, Status: getServerStatus(backendName, server)}
+	}
+	return servers
+}
+
+func newBackendResponse(backendName string, backend *types.Backend) backendResponse {
+	return backendResponse{Backend: backend, Servers: newServersResponse(backendName, backend)}
+}
+
 // healthResponse combines data returned by thoas/stats with statistics (if
 // they are enabled).
 type healthResponse struct {
@@ -178,6 +244,16 @@ func (provider *WebProvider) getPingHandler(response http.ResponseWriter, reques
 	fmt.Fprint(response, "OK")
 }
 
+// getReadyHandler answers OK once Traefik serves a configuration of the providers, until it stops.
+// Unlike the ping, it answers 503 Service Unavailable before and during the graceful shutdown.
+func (provider *WebProvider) getReadyHandler(response http.ResponseWriter, request *http.Request) {
+	if err := provider.server.checkReadiness(); err != nil {
+		http.Error(response, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(response, "OK")
+}
+
 func (provider *WebProvider) getConfigHandler(response http.ResponseWriter, request *http.Request) {
 	currentConfigurations := provider.server.currentConfigurations.Get().(configs)
 	templatesRenderer.JSON(response, http.StatusOK, currentConfigurations)
@@ -194,6 +270,63 @@ func (provider *WebProvider) getVersionHandler(response http.ResponseWriter, req
 	templatesRenderer.JSON(response, http.StatusOK, v)
 }
 
+type logLevelRepresentation struct {
+	LogLevel string
+}
+
+func (provider *WebProvider) getLogLevelHandler(response http.ResponseWriter, request *http.Request) {
+	templatesRenderer.JSON(response, http.StatusOK, logLevelRepresentation{LogLevel: log.GetLevel().String()})
+}
+
+// setLogLevelHandler changes the log level at runtime, without reloading the configuration.
+func (provider *WebProvider) setLogLevelHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+
+	var level logLevelRepresentation
+	if err := json.NewDecoder(request.Body).Decode(&level); err != nil {
+		http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
+		return
+	}
+	if err := setLogLevel(level.LogLevel); err != nil {
+		http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
+		return
+	}
+	provider.getLogLevelHandler(response, request)
+}
+
+// refreshProviderHandler makes the provider send its configuration again, e.g. right after it has been changed
+// in a KV store, instead of waiting for the next watch event or poll.
+func (provider *WebProvider) refreshProviderHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+
+	providerID := mux.Vars(request)["provider"]
+	namedProvider, ok := provider.server.providersByName[providerID]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	refresher, ok := namedProvider.(traefikprovider.Refresher)
+	if !ok {
+		http.Error(response, fmt.Sprintf("Provider %s does not support refreshes", providerID), http.StatusNotImplemented)
+		return
+	}
+
+	if err := refresher.Refresh(); err != nil {
+		log.Errorf("Error refreshing provider %s: %v", providerID, err)
+		http.Error(response, fmt.Sprintf("Error refreshing provider %s: %v", providerID, err), http.StatusInternalServerError)
+		return
+	}
+	response.WriteHeader(http.StatusAccepted)
+}
+
 func (provider *WebProvider) getProviderHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
@@ -210,7 +343,11 @@ func (provider *WebProvider) getBackendsHandler(response http.ResponseWriter, re
 	providerID := vars["provider"]
 	currentConfigurations := provider.server.currentConfigurations.Get().(configs)
 	if provider, ok := currentConfigurations[providerID]; ok {
-		templatesRenderer.JSON(response, http.StatusOK, provider.Backends)
+		backends := make(map[string]backendResponse, len(provider.Backends))
+		for backendName, backend := range provider.Backends {
+			backends[backendName] = newBackendResponse(backendName, backend)
+		}
+		templatesRenderer.JSON(response, http.StatusOK, backends)
 	} else {
 		http.NotFound(response, request)
 	}
@@ -223,7 +360,7 @@ func (provider *WebProvider) getBackendHandler(response http.ResponseWriter, req
 	currentConfigurations := provider.server.currentConfigurations.Get().(configs)
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
-			templatesRenderer.JSON(response, http.StatusOK, backend)
+			templatesRenderer.JSON(response, http.StatusOK, newBackendResponse(backendID, backend))
 			return
 		}
 	}
@@ -237,7 +374,7 @@ func (provider *WebProvider) getServersHandler(response http.ResponseWriter, req
 	currentConfigurations := provider.server.currentConfigurations.Get().(configs)
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
-			templatesRenderer.JSON(response, http.StatusOK, backend.Servers)
+			templatesRenderer.JSON(response, http.StatusOK, newServersResponse(backendID, backend))
 			return
 		}
 	}
@@ -253,7 +390,7 @@ func (provider *WebProvider) getServerHandler(response http.ResponseWriter, requ
 	if provider, ok := currentConfigurations[providerID]; ok {
 		if backend, ok := provider.Backends[backendID]; ok {
 			if server, ok := backend.Servers[serverID]; ok {
-				templatesRenderer.JSON(response, http.StatusOK, server)
+				templatesRenderer.JSON(response, http.StatusOK, serverResponse{Server: server, Status: getServerStatus(backendID, server)})
 				return
 			}
 		}
@@ -261,6 +398,57 @@ func (provider *WebProvider) getServerHandler(response http.ResponseWriter, requ
 	http.NotFound(response, request)
 }
 
+type serverWeightRepresentation struct {
+	Weight int
+}
+
+// setServerWeightHandler changes the weight of a server in the load balancers of its backend at once, until the next
+// configuration reload of the providers.
+func (provider *WebProvider) setServerWeightHandler(response http.ResponseWriter, request *http.Request) {
+	if provider.ReadOnly {
+		response.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(response, "REST API is in read-only mode")
+		return
+	}
+
+	vars := mux.Vars(request)
+	providerID := vars["provider"]
+	backendID := vars["backend"]
+	serverID := vars["server"]
+	currentConfigurations := provider.server.currentConfigurations.Get().(configs)
+	configuration, ok := currentConfigurations[providerID]
+	if !ok || configuration.Backends[backendID] == nil {
+		http.NotFound(response, request)
+		return
+	}
+	server, ok := configuration.Backends[backendID].Servers[serverID]
+	if !ok {
+		http.NotFound(response, request)
+		return
+	}
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		http.Error(response, fmt.Sprintf("%+v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var weight serverWeightRepresentation
+	if err := json.NewDecoder(request.Body).Decode(&weight); err != nil {
+		http.Error(response, fmt.Sprintf("%+v", err), http.StatusBadRequest)
+		return
+	}
+	if weight.Weight < 0 {
+		http.Error(response, fmt.Sprintf("Weight must not be negative, got %d", weight.Weight), http.StatusBadRequest)
+		return
+	}
+	if err := provider.server.setServerWeight(backendID, serverURL, weight.Weight); err != nil {
+		log.Errorf("Error setting the weight of server %s of backend %s: %v", serverID, backendID, err)
+		http.Error(response, fmt.Sprintf("%+v", err), http.StatusInternalServerError)
+		return
+	}
+	templatesRenderer.JSON(response, http.StatusOK, weight)
+}
+
 func (provider *WebProvider) getFrontendsHandler(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	providerID := vars["provider"]
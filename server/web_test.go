@@ -0,0 +1,484 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/containous/traefik/healthcheck"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
+)
+
+func TestWebProviderOnEntryPoint(t *testing.T) {
+	assetsDir, err := ioutil.TempDir("", "traefik-dashboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(assetsDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(assetsDir, "index.html"), []byte(`<script src="app.js"></script>`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(assetsDir, "app.js"), []byte(`$resource('../api/version');`), 0644))
+
+	defaultDashboardAssets := dashboardAssets
+	dashboardAssets = http.Dir(assetsDir)
+	defer func() { dashboardAssets = defaultDashboardAssets }()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+		Web: &WebProvider{
+			Path:       "/traefik",
+			EntryPoint: "http",
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+	require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+	router := srv.buildEntryPoints(globalConfig)["http"].httpRouter
+
+	testCases := []struct {
+		desc             string
+		path             string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			desc:             "root path",
+			path:             "/traefik/",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/traefik/dashboard/",
+		},
+		{
+			desc:             "dashboard deep link without trailing slash",
+			path:             "/traefik/dashboard",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/traefik/dashboard/",
+		},
+		{
+			desc:           "dashboard",
+			path:           "/traefik/dashboard/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `<script src="app.js"></script>`,
+		},
+		{
+			desc:           "outside of the root path",
+			path:           "/",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost"+test.path, nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			if len(test.expectedLocation) > 0 {
+				assert.Equal(t, test.expectedLocation, recorder.Header().Get("Location"))
+			}
+			if len(test.expectedBody) > 0 {
+				assert.Equal(t, test.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+
+	// The dashboard assets and API calls are referenced relatively to the dashboard page.
+	dashboardURL, err := url.Parse("http://localhost/traefik/dashboard/")
+	require.NoError(t, err)
+	for _, reference := range []string{"app.js", "../api/version"} {
+		referenceURL, err := url.Parse(reference)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, dashboardURL.ResolveReference(referenceURL).String(), nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, "reference %s", reference)
+	}
+
+	// A frontend matching the root path, whatever its priority, does not shadow the dashboard.
+	dynamicConfig := buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("catchall", "PathPrefix:/"))),
+		withBackend("backend", buildBackend(withServer("server", "http://127.0.0.1:1"))),
+	)
+	dynamicConfig.Frontends["frontend"].Priority = 1000
+	entryPoints, err := srv.loadConfig(configs{"config": dynamicConfig}, globalConfig)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/traefik/dashboard/", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `<script src="app.js"></script>`, recorder.Body.String())
+	recorder = httptest.NewRecorder()
+	entryPoints["http"].httpRouter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/other", nil))
+	assert.Equal(t, http.StatusBadGateway, recorder.Code, "the requests outside of the root path should be routed to the frontend")
+}
+
+func TestWebProviderUndefinedEntryPoint(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+		Web: &WebProvider{
+			EntryPoint: "admin",
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+
+	assert.Error(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+}
+
+func TestWebProviderBackendServersStatus(t *testing.T) {
+	healthyServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer healthyServer.Close()
+	sickServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer sickServer.Close()
+
+	lb, err := roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	for _, serverURL := range []string{healthyServer.URL, sickServer.URL} {
+		u, err := url.Parse(serverURL)
+		require.NoError(t, err)
+		require.NoError(t, lb.UpsertServer(u))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	healthcheck.GetHealthCheck().SetBackendsConfiguration(ctx, map[string]*healthcheck.BackendHealthCheck{
+		"backend": healthcheck.NewBackendHealthCheck(healthcheck.Options{Path: "/health", Interval: time.Hour, LB: lb}),
+	})
+	defer healthcheck.GetHealthCheck().SetBackendsConfiguration(ctx, map[string]*healthcheck.BackendHealthCheck{})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(lb.Servers()) > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, lb.Servers(), 1, "the sick server should have been removed by the health check")
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+		Web: &WebProvider{
+			EntryPoint: "http",
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+	require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+	router := srv.buildEntryPoints(globalConfig)["http"].httpRouter
+
+	backend := buildBackend(withServer("healthy", healthyServer.URL))
+	backend.Servers["sick"] = types.Server{URL: sickServer.URL, Weight: 3}
+	srv.currentConfigurations.Set(configs{"file": buildDynamicConfig(withBackend("backend", backend))})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/api/providers/file/backends/backend/servers", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"healthy": {"url": "`+healthyServer.URL+`", "weight": 0, "status": "up"},
+		"sick": {"url": "`+sickServer.URL+`", "weight": 3, "status": "down"}
+	}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/api/providers/file/backends/backend", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"servers": {
+			"healthy": {"url": "`+healthyServer.URL+`", "weight": 0, "status": "up"},
+			"sick": {"url": "`+sickServer.URL+`", "weight": 3, "status": "down"}
+		},
+		"loadBalancer": {"method": "Wrr"}
+	}`, recorder.Body.String())
+}
+
+func TestWebProviderSetServerWeight(t *testing.T) {
+	requests := map[string]int{}
+	var lock sync.Mutex
+	newBackendServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			lock.Lock()
+			requests[name]++
+			lock.Unlock()
+		}))
+	}
+	server1 := newBackendServer("server1")
+	defer server1.Close()
+	server2 := newBackendServer("server2")
+	defer server2.Close()
+
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+		Web: &WebProvider{
+			EntryPoint: "http",
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+	require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+
+	dynamicConfigs := configs{"file": buildDynamicConfig(
+		withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+		withBackend("backend", buildBackend(withServer("server1", server1.URL), withServer("server2", server2.URL))),
+	)}
+	srv.currentConfigurations.Set(dynamicConfigs)
+	entryPoints, err := srv.loadConfig(dynamicConfigs, globalConfig)
+	require.NoError(t, err)
+	router := entryPoints["http"].httpRouter
+
+	serveRequests := func() {
+		lock.Lock()
+		requests = map[string]int{}
+		lock.Unlock()
+		for i := 0; i < 10; i++ {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/app", nil))
+			require.Equal(t, http.StatusOK, recorder.Code)
+		}
+	}
+	setWeight := func(server, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		globalConfig.Web.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/providers/file/backends/backend/servers/"+server+"/weight", strings.NewReader(body)))
+		return recorder
+	}
+
+	serveRequests()
+	assert.Equal(t, map[string]int{"server1": 5, "server2": 5}, requests)
+
+	recorder := setWeight("server1", `{"Weight": 0}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"Weight": 0}`, recorder.Body.String())
+	serveRequests()
+	assert.Equal(t, map[string]int{"server2": 10}, requests, "the server of weight zero should not receive traffic")
+
+	require.Equal(t, http.StatusOK, setWeight("server1", `{"Weight": 4}`).Code)
+	serveRequests()
+	assert.Equal(t, map[string]int{"server1": 8, "server2": 2}, requests)
+
+	assert.Equal(t, http.StatusBadRequest, setWeight("server1", `{"Weight": -1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setWeight("server1", `weight`).Code)
+	assert.Equal(t, http.StatusNotFound, setWeight("unknown", `{"Weight": 1}`).Code)
+
+	globalConfig.Web.ReadOnly = true
+	assert.Equal(t, http.StatusForbidden, setWeight("server1", `{"Weight": 1}`).Code)
+}
+
+// refreshableProvider sends a new configuration, with one more frontend, on each refresh.
+type refreshableProvider struct {
+	configurationChan chan<- types.ConfigMessage
+	refreshes         int
+}
+
+func (p *refreshableProvider) Provide(configurationChan chan<- types.ConfigMessage, _ *safe.Pool, _ types.Constraints) error {
+	p.configurationChan = configurationChan
+	return nil
+}
+
+func (p *refreshableProvider) Refresh() error {
+	p.refreshes++
+	configuration := buildDynamicConfig(withBackend("backend", buildBackend()))
+	for i := 1; i <= p.refreshes; i++ {
+		withFrontend("frontend"+strconv.Itoa(i), buildFrontend())(configuration)
+	}
+	p.configurationChan <- types.ConfigMessage{ProviderName: "mock", Configuration: configuration}
+	return nil
+}
+
+type staticProvider struct{}
+
+func (p *staticProvider) Provide(chan<- types.ConfigMessage, *safe.Pool, types.Constraints) error {
+	return nil
+}
+
+func TestWebProviderRefreshProvider(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		readOnly       bool
+		provider       string
+		expectedStatus int
+		expectedConfig bool
+	}{
+		{
+			desc:           "refreshable provider",
+			provider:       "mock",
+			expectedStatus: http.StatusAccepted,
+			expectedConfig: true,
+		},
+		{
+			desc:           "provider without refresh",
+			provider:       "static",
+			expectedStatus: http.StatusNotImplemented,
+		},
+		{
+			desc:           "unknown provider",
+			provider:       "unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			desc:           "read-only API",
+			readOnly:       true,
+			provider:       "mock",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			globalConfig := GlobalConfiguration{
+				EntryPoints: EntryPoints{
+					"http": &EntryPoint{Address: "127.0.0.1:0"},
+				},
+				Web: &WebProvider{
+					EntryPoint: "http",
+					ReadOnly:   test.readOnly,
+				},
+			}
+			srv := NewServer(globalConfig)
+			srv.configureProviders()
+			mock := &refreshableProvider{}
+			srv.addProvider("mock", mock)
+			srv.addProvider("static", &staticProvider{})
+			require.NoError(t, mock.Provide(srv.configurationChan, nil, nil))
+			require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+
+			recorder := httptest.NewRecorder()
+			globalConfig.Web.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/providers/"+test.provider+"/refresh", nil))
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+
+			if !test.expectedConfig {
+				assert.Empty(t, srv.configurationChan)
+				return
+			}
+			select {
+			case message := <-srv.configurationChan:
+				assert.Equal(t, "mock", message.ProviderName)
+				assert.Contains(t, message.Configuration.Frontends, "frontend1")
+			default:
+				assert.Fail(t, "no configuration produced by the refresh")
+			}
+		})
+	}
+}
+
+func TestWebProviderReadiness(t *testing.T) {
+	globalConfig := GlobalConfiguration{
+		EntryPoints: EntryPoints{
+			"http": &EntryPoint{Address: "127.0.0.1:0"},
+		},
+		Web: &WebProvider{
+			EntryPoint: "http",
+		},
+	}
+	srv := NewServer(globalConfig)
+	srv.configureProviders()
+	require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+	srv.serverEntryPoints = srv.buildEntryPoints(globalConfig)
+	srv.serverEntryPoints["http"].httpServer = &http.Server{}
+
+	assertStatus := func(path string, expectedStatus int) {
+		recorder := httptest.NewRecorder()
+		globalConfig.Web.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, expectedStatus, recorder.Code, path)
+	}
+
+	// The process is alive but not ready before the providers delivered a configuration.
+	assertStatus("/ping", http.StatusOK)
+	assertStatus("/ready", http.StatusServiceUnavailable)
+
+	stop := make(chan bool)
+	defer close(stop)
+	go srv.listenConfigurations(stop)
+	srv.configurationValidatedChan <- types.ConfigMessage{
+		ProviderName: "file",
+		Configuration: buildDynamicConfig(
+			withFrontend("frontend", buildFrontend(withRoute("/app", "PathPrefix:/app"))),
+			withBackend("backend", buildBackend(withServer("server", "http://127.0.0.1"))),
+		),
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.checkReadiness() != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertStatus("/ping", http.StatusOK)
+	assertStatus("/ready", http.StatusOK)
+
+	// It is not ready anymore while draining, but still alive.
+	srv.Stop()
+	assertStatus("/ping", http.StatusOK)
+	assertStatus("/ready", http.StatusServiceUnavailable)
+}
+
+func TestWebProviderSetLogLevel(t *testing.T) {
+	// The log level and output are global, the test must not run in parallel.
+	previousLevel := log.GetLevel()
+	defer log.SetLevel(previousLevel)
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	defer log.SetOutput(os.Stderr)
+	log.SetLevel(logrus.InfoLevel)
+
+	newProvider := func(readOnly bool) *WebProvider {
+		globalConfig := GlobalConfiguration{
+			EntryPoints: EntryPoints{
+				"http": &EntryPoint{Address: "127.0.0.1:0"},
+			},
+			Web: &WebProvider{
+				EntryPoint: "http",
+				ReadOnly:   readOnly,
+			},
+		}
+		NewServer(globalConfig).configureProviders()
+		require.NoError(t, globalConfig.Web.Provide(make(chan types.ConfigMessage), nil, nil))
+		return globalConfig.Web
+	}
+	provider := newProvider(false)
+	readOnlyProvider := newProvider(true)
+
+	setLevel := func(provider *WebProvider, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		provider.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/api/loglevel", strings.NewReader(body)))
+		return recorder
+	}
+
+	log.Debug("debug line before the change")
+	assert.NotContains(t, output.String(), "debug line before the change")
+
+	assert.Equal(t, http.StatusForbidden, setLevel(readOnlyProvider, `{"LogLevel": "DEBUG"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, setLevel(provider, `{"LogLevel": "verbose"}`).Code)
+	assert.Equal(t, logrus.InfoLevel, log.GetLevel())
+
+	recorder := setLevel(provider, `{"LogLevel": "DEBUG"}`)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"LogLevel": "debug"}`, recorder.Body.String())
+
+	log.Debug("debug line after the change")
+	assert.Contains(t, output.String(), "debug line after the change")
+
+	recorder = httptest.NewRecorder()
+	provider.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/loglevel", nil))
+	assert.JSONEq(t, `{"LogLevel": "debug"}`, recorder.Body.String())
+}
@@ -15,6 +15,8 @@ const (
 	LabelTags = "traefik.tags"
 	// LabelWeight Traefik label
 	LabelWeight = "traefik.weight"
+	// LabelZone Traefik label
+	LabelZone = "traefik.zone"
 	// LabelFrontendAuthBasic Traefik label
 	LabelFrontendAuthBasic = "traefik.frontend.auth.basic"
 	// LabelFrontendEntryPoints Traefik label
@@ -31,6 +33,12 @@ const (
 	LabelTraefikFrontendValue = "traefik.frontend.value"
 	// LabelTraefikFrontendWhitelistSourceRange Traefik label
 	LabelTraefikFrontendWhitelistSourceRange = "traefik.frontend.whitelistSourceRange"
+	// LabelFrontendMaxInFlightReq Traefik label
+	LabelFrontendMaxInFlightReq = "traefik.frontend.maxInFlightReq"
+	// LabelFrontendMaxInFlightReqExtractorFunc Traefik label
+	LabelFrontendMaxInFlightReqExtractorFunc = "traefik.frontend.maxInFlightReq.extractorFunc"
+	// LabelFrontendHeadersHostOverride Traefik label
+	LabelFrontendHeadersHostOverride = "traefik.frontend.headers.hostOverride"
 	// LabelBackend Traefik label
 	LabelBackend = "traefik.backend"
 	// LabelBackendID Traefik label
@@ -51,4 +59,6 @@ const (
 	LabelBackendMaxconnAmount = "traefik.backend.maxconn.amount"
 	// LabelBackendMaxconnExtractorfunc Traefik label
 	LabelBackendMaxconnExtractorfunc = "traefik.backend.maxconn.extractorfunc"
+	// LabelBackendCustomHost Traefik label
+	LabelBackendCustomHost = "traefik.backend.customHost"
 )
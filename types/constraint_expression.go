@@ -0,0 +1,187 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ryanuber/go-glob"
+)
+
+// constraintExpression is a constraint expression over the tags and metadata of a service, e.g.
+// `environment==prod && (tier!=batch || tag==critical)`, combining comparisons with "&&", "||", "!" and parentheses.
+// A comparison key==glob matches the services having the key in their metadata with a value matching the glob,
+// and key!=glob the other ones; the "tag" key matches against the tags of the service instead, at least one of them.
+type constraintExpression interface {
+	match(tags []string, metadata map[string]string) bool
+}
+
+type constraintComparison struct {
+	key       string
+	mustMatch bool
+	glob      string
+}
+
+func (c *constraintComparison) match(tags []string, metadata map[string]string) bool {
+	if c.key == "tag" {
+		for _, tag := range tags {
+			if glob.Glob(c.glob, tag) {
+				return c.mustMatch
+			}
+		}
+		return !c.mustMatch
+	}
+	value, ok := metadata[c.key]
+	return (ok && glob.Glob(c.glob, value)) == c.mustMatch
+}
+
+type constraintAnd struct{ left, right constraintExpression }
+
+func (c *constraintAnd) match(tags []string, metadata map[string]string) bool {
+	return c.left.match(tags, metadata) && c.right.match(tags, metadata)
+}
+
+type constraintOr struct{ left, right constraintExpression }
+
+func (c *constraintOr) match(tags []string, metadata map[string]string) bool {
+	return c.left.match(tags, metadata) || c.right.match(tags, metadata)
+}
+
+type constraintNot struct{ expression constraintExpression }
+
+func (c *constraintNot) match(tags []string, metadata map[string]string) bool {
+	return !c.expression.match(tags, metadata)
+}
+
+// parseConstraintExpression parses a constraint expression, "&&" having precedence over "||".
+func parseConstraintExpression(exp string) (constraintExpression, error) {
+	parser := &constraintParser{input: exp}
+	expression, err := parser.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint expression %q: %v", exp, err)
+	}
+	parser.skipSpaces()
+	if parser.pos < len(parser.input) {
+		return nil, fmt.Errorf("invalid constraint expression %q: unexpected %q at position %d", exp, parser.input[parser.pos:], parser.pos)
+	}
+	return expression, nil
+}
+
+type constraintParser struct {
+	input string
+	pos   int
+}
+
+func (p *constraintParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume skips the token if it is the next one.
+func (p *constraintParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *constraintParser) parseOr() (constraintExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &constraintOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *constraintParser) parseAnd() (constraintExpression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &constraintAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *constraintParser) parseNot() (constraintExpression, error) {
+	if p.consume("!") {
+		expression, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &constraintNot{expression: expression}, nil
+	}
+	if p.consume("(") {
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		return expression, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *constraintParser) parseComparison() (constraintExpression, error) {
+	p.skipSpaces()
+	key := p.word()
+	if len(key) == 0 {
+		return nil, fmt.Errorf("missing key at position %d", p.pos)
+	}
+
+	comparison := &constraintComparison{key: key}
+	switch {
+	case p.consume("=="):
+		comparison.mustMatch = true
+	case p.consume("!="):
+		comparison.mustMatch = false
+	default:
+		return nil, fmt.Errorf("missing valid operator '==' or '!=' after %q", key)
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		end := strings.IndexByte(p.input[p.pos+1:], '"')
+		if end < 0 {
+			return nil, fmt.Errorf("missing closing quote at position %d", p.pos)
+		}
+		comparison.glob = p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return comparison, nil
+	}
+	comparison.glob = p.word()
+	if len(comparison.glob) == 0 {
+		return nil, fmt.Errorf("missing value for %q at position %d", key, p.pos)
+	}
+	return comparison, nil
+}
+
+// word reads the next key or value, up to a space, an operator or a parenthesis.
+func (p *constraintParser) word() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if unicode.IsSpace(rune(c)) || strings.IndexByte("=!&|()\"", c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
@@ -2,8 +2,11 @@ package types
 
 import (
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -13,23 +16,56 @@ import (
 
 // Backend holds backend configuration.
 type Backend struct {
-	Servers        map[string]Server `json:"servers,omitempty"`
-	CircuitBreaker *CircuitBreaker   `json:"circuitBreaker,omitempty"`
-	LoadBalancer   *LoadBalancer     `json:"loadBalancer,omitempty"`
-	MaxConn        *MaxConn          `json:"maxConn,omitempty"`
-	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
-}
-
-// MaxConn holds maximum connection configuration
+	Servers            map[string]Server   `json:"servers,omitempty"`
+	CircuitBreaker     *CircuitBreaker     `json:"circuitBreaker,omitempty"`
+	LoadBalancer       *LoadBalancer       `json:"loadBalancer,omitempty"`
+	MaxConn            *MaxConn            `json:"maxConn,omitempty"`
+	HealthCheck        *HealthCheck        `json:"healthCheck,omitempty"`
+	PreserveHeaderCase bool                `json:"preserveHeaderCase,omitempty"`
+	ForwardingTimeouts *ForwardingTimeouts `json:"forwardingTimeouts,omitempty"`
+	Protocol           string              `json:"protocol,omitempty"`
+	GrpcWeb            bool                `json:"grpcWeb,omitempty"`
+	AWSSigning         *AWSSigning         `json:"awsSigning,omitempty"`
+	CustomHost         string              `json:"customHost,omitempty"`
+	Drain              *Drain              `json:"drain,omitempty"`
+	DisableKeepAlives  bool                `json:"disableKeepAlives,omitempty"`
+	HTTPFallback       bool                `json:"httpFallback,omitempty"`
+}
+
+// Drain holds the draining of the servers of a backend: the servers answering with the Header set to true
+// are removed from the load balancer until they answer without it, or until Timeout.
+type Drain struct {
+	Header  string `json:"header,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// MaxConn holds maximum connection configuration.
+// The Policy applied to the requests over the limit is "failfast" or "queue", the QueueSize and QueueTimeout
+// bounding the queue; without Policy, the requests over the limit are rejected with 429 Too Many Requests.
 type MaxConn struct {
 	Amount        int64  `json:"amount,omitempty"`
 	ExtractorFunc string `json:"extractorFunc,omitempty"`
+	Policy        string `json:"policy,omitempty"`
+	QueueSize     int64  `json:"queueSize,omitempty"`
+	QueueTimeout  string `json:"queueTimeout,omitempty"`
 }
 
 // LoadBalancer holds load balancing configuration.
 type LoadBalancer struct {
 	Method string `json:"method,omitempty"`
 	Sticky bool   `json:"sticky,omitempty"`
+	// StickyHeader pins the requests carrying this header to a server picked from the hash of its value.
+	StickyHeader string `json:"stickyHeader,omitempty"`
+	// StickyCookie pins the requests carrying this cookie of the application, such as its session ID,
+	// to a server picked from the hash of its value, as StickyHeader.
+	StickyCookie string `json:"stickyCookie,omitempty"`
+	// StickyVirtualNodes is the number of hashes of each server the ones of StickyHeader and StickyCookie
+	// are compared to, 1 by default.
+	StickyVirtualNodes int `json:"stickyVirtualNodes,omitempty"`
+	// LoadHeader is the response header in which the servers report their load to the adaptive load balancer.
+	LoadHeader string `json:"loadHeader,omitempty"`
+	// SortServers adds the servers to the load balancer by URL order, for a rotation order stable across reloads.
+	SortServers bool `json:"sortServers,omitempty"`
 }
 
 // CircuitBreaker holds circuit breaker configuration.
@@ -39,14 +75,57 @@ type CircuitBreaker struct {
 
 // HealthCheck holds HealthCheck configuration
 type HealthCheck struct {
-	Path     string `json:"path,omitempty"`
-	Interval string `json:"interval,omitempty"`
+	// Mode is http, checking the servers with HTTP requests on the path, or tcp, checking that a TCP connection
+	// to them succeeds, http when unset.
+	Mode     string            `json:"mode,omitempty"`
+	Path     string            `json:"path,omitempty"`
+	Query    string            `json:"query,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Interval string            `json:"interval,omitempty"`
+	// SlowStart is the duration over which the weight of a server recovering from a failed health check ramps up.
+	SlowStart string `json:"slowStart,omitempty"`
+	// HealthyThreshold and UnhealthyThreshold are the numbers of consecutive successful and failed health checks
+	// changing the state of a server, 1 when unset.
+	HealthyThreshold   int `json:"healthyThreshold,omitempty"`
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+	// Timeout is the time within which a server must pass a health check, 5s when unset.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ForwardingTimeouts holds the timeouts of the requests forwarded to the backend servers,
+// overriding the global ones.
+type ForwardingTimeouts struct {
+	DialTimeout           string `json:"dialTimeout,omitempty"`
+	ResponseHeaderTimeout string `json:"responseHeaderTimeout,omitempty"`
+	ForwardingTimeout     string `json:"forwardingTimeout,omitempty"`
+}
+
+// AWSSigning holds the configuration signing the requests forwarded to the backend servers
+// with the AWS Signature Version 4. The credentials not configured are taken from the AWS credential chain.
+type AWSSigning struct {
+	Service         string `json:"service,omitempty"`
+	Region          string `json:"region,omitempty"`
+	AccessKeyID     string `json:"accessKeyID,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	// UnsignedPayload signs the requests without hashing their body, which is then not buffered.
+	UnsignedPayload bool `json:"unsignedPayload,omitempty"`
+	// MaxBodyBytes is the size of the largest body buffered to be hashed, the larger ones being sent with an unsigned payload.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+}
+
+// MarshalJSON marshals the configuration without its secret access key, for the API not to expose it.
+func (a AWSSigning) MarshalJSON() ([]byte, error) {
+	type awsSigning AWSSigning
+	masked := awsSigning(a)
+	masked.SecretAccessKey = ""
+	return json.Marshal(masked)
 }
 
 // Server holds server configuration.
 type Server struct {
 	URL    string `json:"url,omitempty"`
 	Weight int    `json:"weight"`
+	Zone   string `json:"zone,omitempty"`
 }
 
 // Route holds route configuration.
@@ -83,6 +162,9 @@ type Headers struct {
 	PublicKey               string            `json:"publicKey,omitempty"`
 	ReferrerPolicy          string            `json:"referrerPolicy,omitempty"`
 	IsDevelopment           bool              `json:"isDevelopment,omitempty"`
+	// HostOverride is the Host sent to the backend for the requests of the frontend,
+	// overriding both the client Host passed with passHostHeader and the customHost of the backend.
+	HostOverride string `json:"hostOverride,omitempty"`
 }
 
 // HasCustomHeadersDefined checks to see if any of the custom header elements have been set
@@ -115,16 +197,145 @@ func (h Headers) HasSecureHeadersDefined() bool {
 
 // Frontend holds frontend configuration.
 type Frontend struct {
-	EntryPoints          []string             `json:"entryPoints,omitempty"`
-	Backend              string               `json:"backend,omitempty"`
-	Routes               map[string]Route     `json:"routes,omitempty"`
-	PassHostHeader       bool                 `json:"passHostHeader,omitempty"`
-	PassTLSCert          bool                 `json:"passTLSCert,omitempty"`
-	Priority             int                  `json:"priority"`
-	BasicAuth            []string             `json:"basicAuth"`
-	WhitelistSourceRange []string             `json:"whitelistSourceRange,omitempty"`
-	Headers              Headers              `json:"headers,omitempty"`
-	Errors               map[string]ErrorPage `json:"errors,omitempty"`
+	EntryPoints          []string                 `json:"entryPoints,omitempty"`
+	Backend              string                   `json:"backend,omitempty"`
+	Routes               map[string]Route         `json:"routes,omitempty"`
+	PassHostHeader       bool                     `json:"passHostHeader,omitempty"`
+	PassTLSCert          bool                     `json:"passTLSCert,omitempty"`
+	PassOriginalURI      bool                     `json:"passOriginalURI,omitempty"`
+	RouteHeaders         bool                     `json:"routeHeaders,omitempty"`
+	DebugHeader          bool                     `json:"debugHeader,omitempty"`
+	TrailingSlash        string                   `json:"trailingSlash,omitempty"`
+	Priority             int                      `json:"priority"`
+	BasicAuth            []string                 `json:"basicAuth"`
+	WhitelistSourceRange []string                 `json:"whitelistSourceRange,omitempty"`
+	Headers              Headers                  `json:"headers,omitempty"`
+	Errors               map[string]ErrorPage     `json:"errors,omitempty"`
+	Chains               []string                 `json:"chains,omitempty"`
+	HeaderRewrites       map[string]HeaderRewrite `json:"headerRewrites,omitempty"`
+	MaxInFlightReq       *MaxInFlightReq          `json:"maxInFlightReq,omitempty"`
+	Redirect             *FrontendRedirect        `json:"redirect,omitempty"`
+	Rejections           *RejectionResponses      `json:"rejections,omitempty"`
+	Canary               *Canary                  `json:"canary,omitempty"`
+	Mirror               *Mirror                  `json:"mirror,omitempty"`
+	FallbackBackend      string                   `json:"fallbackBackend,omitempty"`
+	Bypasses             map[string]Bypass        `json:"bypasses,omitempty"`
+	StatusRewrites       map[string]int           `json:"statusRewrites,omitempty"`
+	RetryBackoff         *RetryBackoff            `json:"retryBackoff,omitempty"`
+	Cache                *ResponseCache           `json:"cache,omitempty"`
+	Idempotency          *Idempotency             `json:"idempotency,omitempty"`
+	ProblemDetails       bool                     `json:"problemDetails,omitempty"`
+	MaxRequestBodyBytes  int64                    `json:"maxRequestBodyBytes,omitempty"`
+}
+
+// The trailing slash modes of the Path and PathPrefix rules of a frontend.
+const (
+	// TrailingSlashStrict matches the paths as written in the rules, the Path rules redirecting to their own form.
+	TrailingSlashStrict = "strict"
+	// TrailingSlashEquivalent matches the paths with or without a trailing slash alike.
+	TrailingSlashEquivalent = "equivalent"
+	// TrailingSlashRedirect redirects the paths to the form of the rules, with or without a trailing slash.
+	TrailingSlashRedirect = "redirect"
+)
+
+// ResponseCache holds the configuration of the in-memory cache of the responses of a frontend:
+// the maximum sizes of each cached body and of all of them, in bytes, and the TTL of the responses
+// without any freshness information, which are not cached when empty.
+type ResponseCache struct {
+	MaxEntrySize int64  `json:"maxEntrySize,omitempty"`
+	MaxSize      int64  `json:"maxSize,omitempty"`
+	DefaultTTL   string `json:"defaultTTL,omitempty"`
+}
+
+// Idempotency holds the configuration of the replay of the responses of a frontend to the requests carrying the same
+// idempotency key in Header: the TTL of the replayed responses, and the maximum number of them and size of each body.
+type Idempotency struct {
+	Header       string `json:"header,omitempty"`
+	TTL          string `json:"ttl,omitempty"`
+	MaxEntries   int    `json:"maxEntries,omitempty"`
+	MaxEntrySize int64  `json:"maxEntrySize,omitempty"`
+}
+
+// RetryBackoff holds the backoff between the retried attempts of the requests of a frontend,
+// overriding the global one.
+type RetryBackoff struct {
+	Mode        string `json:"mode,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+	MaxInterval string `json:"maxInterval,omitempty"`
+}
+
+// Canary routes part of the requests of a frontend to a canary backend instead of its backend:
+// the requests carrying one of Headers or Cookies, with the given value or any value when empty,
+// and otherwise Percent of them.
+type Canary struct {
+	Backend string            `json:"backend,omitempty"`
+	Percent int               `json:"percent,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+}
+
+// Mirror duplicates Percent of the requests of a frontend to a mirror backend, whose responses are discarded.
+// The requests with a body larger than MaxBodySize bytes are not mirrored.
+type Mirror struct {
+	Backend     string `json:"backend,omitempty"`
+	Percent     int    `json:"percent,omitempty"`
+	MaxBodySize int64  `json:"maxBodySize,omitempty"`
+}
+
+// RejectionResponses overrides the responses of the requests rejected by the middlewares of a frontend.
+type RejectionResponses struct {
+	MaxInFlightReq *RejectionResponse `json:"maxInFlightReq,omitempty"`
+	MaxConn        *RejectionResponse `json:"maxConn,omitempty"`
+	Whitelist      *RejectionResponse `json:"whitelist,omitempty"`
+	Auth           *RejectionResponse `json:"auth,omitempty"`
+}
+
+// RejectionResponse is the response of a rejected request. An empty StatusCode keeps the status code of the
+// rejecting middleware, and an empty Body defaults to the status text.
+type RejectionResponse struct {
+	StatusCode int               `json:"statusCode,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// FrontendRedirect overrides the redirect of the entry points of a frontend:
+// its requests are redirected to EntryPoint instead, or passed through when Disabled is true.
+type FrontendRedirect struct {
+	EntryPoint string `json:"entryPoint,omitempty"`
+	Disabled   bool   `json:"disabled,omitempty"`
+}
+
+// MaxInFlightReq holds the maximum amount of requests processed simultaneously by a frontend,
+// per bucket of requests given by ExtractorFunc.
+type MaxInFlightReq struct {
+	Amount        int64  `json:"amount,omitempty"`
+	ExtractorFunc string `json:"extractorFunc,omitempty"`
+}
+
+// HeaderRewrite holds the rewrite of a response header: the parts of its values matching Regex are replaced with Replacement.
+type HeaderRewrite struct {
+	Header      string `json:"header,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Bypass holds the middlewares of a frontend skipped by the requests whose path matches one of Paths,
+// among "auth", "whitelist" and "maxInFlightReq".
+type Bypass struct {
+	Paths       []string `json:"paths,omitempty"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+// Chain holds an ordered group of middlewares that frontends can reference by name.
+type Chain struct {
+	Middlewares []ChainMiddleware `json:"middlewares,omitempty"`
+}
+
+// ChainMiddleware holds the configuration of a middleware of a chain.
+type ChainMiddleware struct {
+	BasicAuth            []string `json:"basicAuth,omitempty"`
+	WhitelistSourceRange []string `json:"whitelistSourceRange,omitempty"`
+	Headers              Headers  `json:"headers,omitempty"`
 }
 
 // LoadBalancerMethod holds the method of load balancing to use.
@@ -135,11 +346,17 @@ const (
 	Wrr LoadBalancerMethod = iota
 	// Drr = Dynamic Round Robin
 	Drr
+	// Ewma = Weighted least-time, using the moving averages of the response times
+	Ewma
+	// Adaptive = Weighted Round Robin, reducing the weights of the servers by the load they report
+	Adaptive
 )
 
 var loadBalancerMethodNames = []string{
 	"Wrr",
 	"Drr",
+	"Ewma",
+	"Adaptive",
 }
 
 // NewLoadBalancerMethod create a new LoadBalancerMethod from a given LoadBalancer.
@@ -160,6 +377,7 @@ func NewLoadBalancerMethod(loadBalancer *LoadBalancer) (LoadBalancerMethod, erro
 type Configuration struct {
 	Backends  map[string]*Backend  `json:"backends,omitempty"`
 	Frontends map[string]*Frontend `json:"frontends,omitempty"`
+	Chains    map[string]*Chain    `json:"chains,omitempty"`
 }
 
 // ConfigMessage hold configuration information exchanged between parts of traefik.
@@ -169,45 +387,40 @@ type ConfigMessage struct {
 }
 
 // Constraint hold a parsed constraint expresssion
+// A tag constraint, e.g. "tag==us-*", is held by its Key, MustMatch and Regex; any other expression,
+// over the metadata of the services or combining comparisons, is held by its Expression.
 type Constraint struct {
 	Key string
 	// MustMatch is true if operator is "==" or false if operator is "!="
 	MustMatch bool
 	// TODO: support regex
 	Regex string
+	// Expression is the constraint expression over the tags and metadata of the services, e.g. "environment==prod && tier!=batch".
+	Expression string
+	expression constraintExpression
 }
 
 // NewConstraint receive a string and return a *Constraint, after checking syntax and parsing the constraint expression
 func NewConstraint(exp string) (*Constraint, error) {
-	sep := ""
-	constraint := &Constraint{}
-
-	if strings.Contains(exp, "==") {
-		sep = "=="
-		constraint.MustMatch = true
-	} else if strings.Contains(exp, "!=") {
-		sep = "!="
-		constraint.MustMatch = false
-	} else {
-		return nil, errors.New("Constraint expression missing valid operator: '==' or '!='")
+	expression, err := parseConstraintExpression(exp)
+	if err != nil {
+		return nil, err
 	}
 
-	kv := strings.SplitN(exp, sep, 2)
-	if len(kv) == 2 {
-		// At the moment, it only supports tags
-		if kv[0] != "tag" {
-			return nil, errors.New("Constraint must be tag-based. Syntax: tag==us-*")
-		}
-
-		constraint.Key = kv[0]
-		constraint.Regex = kv[1]
-		return constraint, nil
+	if comparison, ok := expression.(*constraintComparison); ok && comparison.key == "tag" {
+		return &Constraint{
+			Key:       comparison.key,
+			MustMatch: comparison.mustMatch,
+			Regex:     comparison.glob,
+		}, nil
 	}
-
-	return nil, errors.New("Incorrect constraint expression: " + exp)
+	return &Constraint{Expression: strings.TrimSpace(exp), expression: expression}, nil
 }
 
 func (c *Constraint) String() string {
+	if c.expression != nil {
+		return c.Expression
+	}
 	if c.MustMatch {
 		return c.Key + "==" + c.Regex
 	}
@@ -222,9 +435,7 @@ func (c *Constraint) UnmarshalText(text []byte) error {
 	if err != nil {
 		return err
 	}
-	c.Key = constraint.Key
-	c.MustMatch = constraint.MustMatch
-	c.Regex = constraint.Regex
+	*c = *constraint
 	return nil
 }
 
@@ -245,6 +456,15 @@ func (c *Constraint) MatchConstraintWithAtLeastOneTag(tags []string) bool {
 	return false
 }
 
+// Match tests the constraint against the tags and metadata of a service.
+func (c *Constraint) Match(tags []string, metadata map[string]string) bool {
+	if c.expression != nil {
+		return c.expression.match(tags, metadata)
+	}
+	// xor: if ok and constraint.MustMatch are equal, then no tag is currently matching with the constraint
+	return c.MatchConstraintWithAtLeastOneTag(tags) == c.MustMatch
+}
+
 //Set []*Constraint
 func (cs *Constraints) Set(str string) error {
 	exps := strings.Split(str, ",")
@@ -293,9 +513,12 @@ type Cluster struct {
 }
 
 // Auth holds authentication configuration (BASIC, DIGEST, users)
+// Realm is the realm of the authentication challenge, "traefik" by default. With digest authentication,
+// only the users of this realm are authenticated.
 type Auth struct {
 	Basic       *Basic
 	Digest      *Digest
+	Realm       string
 	HeaderField string
 }
 
@@ -319,6 +542,27 @@ func CanonicalDomain(domain string) string {
 	return strings.ToLower(strings.TrimSpace(domain))
 }
 
+// IsWildcardDomain returns true if the leftmost label of the domain is a wildcard, e.g. *.example.com
+func IsWildcardDomain(domain string) bool {
+	return strings.HasPrefix(CanonicalDomain(domain), "*.")
+}
+
+// MatchDomain returns true if the domain is equal to the domain pattern, or
+// if the pattern is a wildcard domain and the domain only adds a single label
+// to it: *.example.com matches api.example.com but neither a.b.example.com nor example.com.
+func MatchDomain(domain, domainPattern string) bool {
+	domain = CanonicalDomain(domain)
+	domainPattern = CanonicalDomain(domainPattern)
+	if domain == domainPattern {
+		return true
+	}
+	if !IsWildcardDomain(domainPattern) {
+		return false
+	}
+	label := strings.TrimSuffix(domain, domainPattern[1:])
+	return label != domain && len(label) > 0 && !strings.Contains(label, ".")
+}
+
 // Statistics provides options for monitoring request and response stats
 type Statistics struct {
 	RecentErrors int `description:"Number of recent errors logged"`
@@ -333,7 +577,14 @@ type Metrics struct {
 
 // Prometheus can contain specific configuration used by the Prometheus Metrics exporter
 type Prometheus struct {
-	Buckets Buckets `description:"Buckets for latency metrics"`
+	Buckets Buckets          `description:"Buckets for latency metrics"`
+	Drain   *PrometheusDrain `description:"Drain the series of the removed backends before removing them. If not set, they are kept until Traefik stops"`
+}
+
+// PrometheusDrain contains the policy applied to the Prometheus series of the removed backends
+type PrometheusDrain struct {
+	Policy      string `description:"retain to keep the series as is, or zero to reset them, during the grace period before their removal"`
+	GracePeriod string `description:"Duration during which the series of a removed backend are exposed before their removal"`
 }
 
 // Datadog contains address and metrics pushing interval configuration
@@ -380,8 +631,141 @@ func (b *Buckets) SetValue(val interface{}) {
 	*b = Buckets(val.(Buckets))
 }
 
+// TrustedProxies holds the IPs and CIDRs of the proxies allowed to set forwarded headers
+type TrustedProxies []string
+
+//Set adds strings elem into the the parser
+//it splits str on "," and ";"
+func (tp *TrustedProxies) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*tp = append(*tp, slice...)
+	return nil
+}
+
+//Get []string
+func (tp *TrustedProxies) Get() interface{} { return TrustedProxies(*tp) }
+
+//String return slice in a string
+func (tp *TrustedProxies) String() string { return fmt.Sprintf("%v", *tp) }
+
+//SetValue sets []string into the parser
+func (tp *TrustedProxies) SetValue(val interface{}) {
+	*tp = TrustedProxies(val.(TrustedProxies))
+}
+
+// ProxyChecker tells whether a request comes from a trusted proxy, and
+// resolves the client IP accordingly.
+// A nil ProxyChecker trusts no proxy.
+type ProxyChecker struct {
+	trustedNets []*net.IPNet
+}
+
+// NewProxyChecker builds a ProxyChecker from a list of IPs and CIDRs
+func NewProxyChecker(trustedProxies TrustedProxies) (*ProxyChecker, error) {
+	checker := &ProxyChecker{}
+	for _, trustedProxy := range trustedProxies {
+		if ip := net.ParseIP(trustedProxy); ip != nil {
+			trustedProxy = ip.String() + "/128"
+			if ip.To4() != nil {
+				trustedProxy = ip.String() + "/32"
+			}
+		}
+		_, trustedNet, err := net.ParseCIDR(trustedProxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing trusted proxy %s: %v", trustedProxy, err)
+		}
+		checker.trustedNets = append(checker.trustedNets, trustedNet)
+	}
+	return checker, nil
+}
+
+// IsTrusted returns true if the given address (IP or IP:port) belongs to a trusted proxy
+func (c *ProxyChecker) IsTrusted(addr string) bool {
+	if c == nil {
+		return false
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, trustedNet := range c.trustedNets {
+		if trustedNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client that issued the request.
+// X-Forwarded-For is only followed while the hops are trusted proxies,
+// otherwise the host of the direct remote address is returned.
+func (c *ProxyChecker) ClientIP(req *http.Request) string {
+	clientIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		clientIP = req.RemoteAddr
+	}
+	if !c.IsTrusted(clientIP) {
+		return clientIP
+	}
+
+	var hops []string
+	for _, header := range req.Header["X-Forwarded-For"] {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		clientIP = hop
+		if !c.IsTrusted(hop) {
+			break
+		}
+	}
+	return clientIP
+}
+
 // AccessLog holds the configuration settings for the access logger (middlewares/accesslog).
 type AccessLog struct {
-	FilePath string `json:"file,omitempty" description:"Access log file path. Stdout is used when omitted or empty"`
-	Format   string `json:"format,omitempty" description:"Access log format: json | common"`
+	FilePath  string              `json:"file,omitempty" description:"Access log file path. Stdout is used when omitted or empty"`
+	Format    string              `json:"format,omitempty" description:"Access log format: json | common"`
+	Filters   []AccessLogFilter   `json:"filters,omitempty"`
+	Fields    *AccessLogFields    `json:"fields,omitempty"`
+	Buffering *AccessLogBuffering `json:"buffering,omitempty"`
+}
+
+// AccessLogBuffering configures the asynchronous writes of the access log: up to BufferSize entries wait to be written
+// in a buffer, flushed every FlushInterval (e.g. "1s") or once full. When BufferSize entries are already waiting,
+// the next ones are dropped if DropOnOverflow, and otherwise wait for room, blocking their requests.
+type AccessLogBuffering struct {
+	BufferSize     int    `json:"bufferSize,omitempty"`
+	FlushInterval  string `json:"flushInterval,omitempty"`
+	DropOnOverflow bool   `json:"dropOnOverflow,omitempty"`
+}
+
+// AccessLogFilter selects the requests matching all of its criteria: regular expressions matching the path and
+// the user agent, status codes (e.g. "404" or "500-599") and a minimum duration (e.g. "1s").
+// Action tells whether the selected requests are kept, dropped (the default) or sampled, logging only one in SampleRate.
+type AccessLogFilter struct {
+	Path        string   `json:"path,omitempty"`
+	UserAgent   string   `json:"userAgent,omitempty"`
+	StatusCodes []string `json:"statusCodes,omitempty"`
+	MinDuration string   `json:"minDuration,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	SampleRate  int      `json:"sampleRate,omitempty"`
+}
+
+// AccessLogFields holds the modes of the access log fields: keep, drop or redact.
+// Names overrides DefaultMode for the fields of the given names, and Headers for the request and response headers.
+type AccessLogFields struct {
+	DefaultMode string            `json:"defaultMode,omitempty"`
+	Names       map[string]string `json:"names,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
 }
@@ -1,9 +1,13 @@
 package types
 
 import (
+	"encoding/json"
+	"net/http"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHeaders_ShouldReturnFalseWhenNotHasCustomHeadersDefined(t *testing.T) {
@@ -35,3 +39,221 @@ func TestHeaders_ShouldReturnTrueWhenHasSecureHeadersDefined(t *testing.T) {
 
 	assert.True(t, headers.HasSecureHeadersDefined())
 }
+
+func TestNewProxyCheckerInvalidProxy(t *testing.T) {
+	_, err := NewProxyChecker(TrustedProxies{"10.0.0.0/8", "foo"})
+
+	assert.EqualError(t, err, "parsing trusted proxy foo: invalid CIDR address: foo")
+}
+
+func TestProxyCheckerClientIP(t *testing.T) {
+	proxyChecker, err := NewProxyChecker(TrustedProxies{"10.0.0.0/8", "192.168.1.1", "fe80::/16"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		desc          string
+		proxyChecker  *ProxyChecker
+		remoteAddr    string
+		xForwardedFor []string
+		expectedIP    string
+	}{
+		{
+			desc:          "no trusted proxies",
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expectedIP:    "10.0.0.1",
+		},
+		{
+			desc:          "untrusted source",
+			proxyChecker:  proxyChecker,
+			remoteAddr:    "5.6.7.8:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expectedIP:    "5.6.7.8",
+		},
+		{
+			desc:          "trusted proxy",
+			proxyChecker:  proxyChecker,
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"1.2.3.4"},
+			expectedIP:    "1.2.3.4",
+		},
+		{
+			desc:         "trusted proxy without X-Forwarded-For",
+			proxyChecker: proxyChecker,
+			remoteAddr:   "192.168.1.1:1234",
+			expectedIP:   "192.168.1.1",
+		},
+		{
+			desc:          "chain of trusted proxies",
+			proxyChecker:  proxyChecker,
+			remoteAddr:    "[fe80::1]:1234",
+			xForwardedFor: []string{"6.6.6.6, 1.2.3.4", "192.168.1.1,10.0.0.2"},
+			expectedIP:    "1.2.3.4",
+		},
+		{
+			desc:          "invalid hop",
+			proxyChecker:  proxyChecker,
+			remoteAddr:    "10.0.0.1:1234",
+			xForwardedFor: []string{"1.2.3.4, foo, 10.0.0.2"},
+			expectedIP:    "10.0.0.2",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := &http.Request{
+				RemoteAddr: test.remoteAddr,
+				Header:     http.Header{"X-Forwarded-For": test.xForwardedFor},
+			}
+
+			assert.Equal(t, test.expectedIP, test.proxyChecker.ClientIP(req))
+		})
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	testCases := []struct {
+		domain        string
+		domainPattern string
+		expected      bool
+	}{
+		{domain: "example.com", domainPattern: "example.com", expected: true},
+		{domain: "Example.COM", domainPattern: " example.com ", expected: true},
+		{domain: "api.example.com", domainPattern: "example.com", expected: false},
+		{domain: "api.example.com", domainPattern: "*.example.com", expected: true},
+		{domain: "API.example.com", domainPattern: "*.Example.com", expected: true},
+		{domain: "a.b.example.com", domainPattern: "*.example.com", expected: false},
+		{domain: "example.com", domainPattern: "*.example.com", expected: false},
+		{domain: ".example.com", domainPattern: "*.example.com", expected: false},
+		{domain: "api.example.org", domainPattern: "*.example.com", expected: false},
+		{domain: "apiexample.com", domainPattern: "*.example.com", expected: false},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.domain+" "+test.domainPattern, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, MatchDomain(test.domain, test.domainPattern))
+		})
+	}
+}
+
+func TestConstraintExpression(t *testing.T) {
+	services := map[string]struct {
+		tags     []string
+		metadata map[string]string
+	}{
+		"prod-api":     {tags: []string{"api"}, metadata: map[string]string{"environment": "prod", "tier": "frontend"}},
+		"prod-batch":   {tags: []string{"batch"}, metadata: map[string]string{"environment": "prod", "tier": "batch"}},
+		"staging-api":  {tags: []string{"api", "critical"}, metadata: map[string]string{"environment": "staging", "tier": "frontend"}},
+		"untagged-dev": {metadata: map[string]string{"environment": "dev"}},
+	}
+
+	testCases := []struct {
+		expression string
+		expected   []string
+	}{
+		{
+			expression: "environment==prod",
+			expected:   []string{"prod-api", "prod-batch"},
+		},
+		{
+			expression: "environment==prod && tier!=batch",
+			expected:   []string{"prod-api"},
+		},
+		{
+			expression: "environment==staging || tag==batch",
+			expected:   []string{"prod-batch", "staging-api"},
+		},
+		{
+			expression: "!(environment==prod)",
+			expected:   []string{"staging-api", "untagged-dev"},
+		},
+		{
+			expression: "tier!=batch",
+			expected:   []string{"prod-api", "staging-api", "untagged-dev"},
+		},
+		{
+			expression: "environment==prod && tier==batch || tag==critical",
+			expected:   []string{"prod-batch", "staging-api"},
+		},
+		{
+			expression: "environment==prod && (tier==batch || tag==critical)",
+			expected:   []string{"prod-batch"},
+		},
+		{
+			expression: `!tag==api && environment=="d*"`,
+			expected:   []string{"untagged-dev"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+			constraint, err := NewConstraint(test.expression)
+			require.NoError(t, err)
+			assert.Equal(t, test.expression, constraint.String())
+
+			var matching []string
+			for name, service := range services {
+				if constraint.Match(service.tags, service.metadata) {
+					matching = append(matching, name)
+				}
+			}
+			sort.Strings(matching)
+			assert.Equal(t, test.expected, matching)
+		})
+	}
+}
+
+func TestNewConstraintTag(t *testing.T) {
+	constraint, err := NewConstraint("tag!=us-*")
+	require.NoError(t, err)
+	assert.Equal(t, &Constraint{Key: "tag", MustMatch: false, Regex: "us-*"}, constraint)
+	assert.Equal(t, "tag!=us-*", constraint.String())
+}
+
+func TestNewConstraintInvalidExpression(t *testing.T) {
+	expressions := []string{
+		"",
+		"environment",
+		"environment=prod",
+		"environment==",
+		"environment==prod &&",
+		"(environment==prod || tier==batch",
+		"environment==prod)",
+		`environment=="prod`,
+		"environment==prod tier==batch",
+	}
+
+	for _, expression := range expressions {
+		expression := expression
+		t.Run(expression, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewConstraint(expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAWSSigningMarshalJSON(t *testing.T) {
+	backend := &Backend{AWSSigning: &AWSSigning{
+		Service:         "execute-api",
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}}
+	data, err := json.Marshal(backend)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"awsSigning": {"service": "execute-api", "region": "eu-west-1", "accessKeyID": "AKIDEXAMPLE"}}`, string(data))
+	assert.Equal(t, "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", backend.AWSSigning.SecretAccessKey, "the configuration must be left untouched")
+
+	decoded := &Backend{}
+	require.NoError(t, json.Unmarshal([]byte(`{"awsSigning": {"secretAccessKey": "env://AWS_SECRET"}}`), decoded))
+	assert.Equal(t, "env://AWS_SECRET", decoded.AWSSigning.SecretAccessKey)
+}